@@ -0,0 +1,38 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+)
+
+// DefaultAckTimeout bounds AckWithContext and NakWithContext when their context has no deadline
+const DefaultAckTimeout = 5 * time.Second
+
+// ErrAckTimeout is returned when the stream didn't confirm an ack or nak in time, e.g. after a
+// connection blip. The handler decides whether to retry, the message is redelivered otherwise.
+var ErrAckTimeout = errors.New("timed out waiting for the ack confirmation")
+
+// ackContext bounds the context with the DefaultAckTimeout when it has no deadline
+func ackContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, DefaultAckTimeout)
+}
+
+// ackError wraps the errors of acks which weren't confirmed in time with ErrAckTimeout
+func ackError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, nats.ErrTimeout) {
+		return errors.Wrap(ErrAckTimeout, err.Error())
+	}
+
+	return err
+}
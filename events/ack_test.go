@@ -0,0 +1,108 @@
+//nolint:all
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	natsTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+func TestAckWithContext(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName:                "TestAckWithContext",
+		PublisherSubjectPrefix: "pre",
+		Stream: &NatsStreamOptions{
+			Name:      "test_stream",
+			Subjects:  []string{"pre.test"},
+			Retention: "workQueue",
+		},
+		Consumer: &NatsConsumerOptions{
+			Name:              "test_consumer",
+			Pull:              true,
+			SubscribeSubjects: []string{"pre.test"},
+			FilterSubject:     "pre.test",
+			FetchMaxWait:      100 * time.Millisecond,
+		},
+	}
+	require.NoError(t, njs.addStream())
+	require.NoError(t, njs.addConsumer())
+
+	_, err := njs.Subscribe(context.TODO())
+	require.NoError(t, err)
+
+	require.NoError(t, njs.Publish(context.TODO(), "test", []byte("first")))
+
+	msgs, err := njs.PullMsg(context.TODO(), 1)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+
+	// naked messages are redelivered
+	require.NoError(t, msgs[0].NakWithContext(context.TODO()))
+
+	msgs, err = njs.PullMsg(context.TODO(), 1)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+
+	md, err := msgs[0].Metadata()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), md.NumDelivered)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	require.NoError(t, msgs[0].AckWithContext(ctx))
+
+	_, err = njs.PullMsg(context.TODO(), 1)
+	require.ErrorIs(t, err, nats.ErrTimeout)
+}
+
+func TestAckWithContextTimeout(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+
+	// the ack subject has a subscriber which never confirms, as after a connection blip
+	sub, err := jsConn.SubscribeSync("ack.blackhole")
+	require.NoError(t, err)
+
+	newMsg := func() Message {
+		return &natsMsg{msg: &nats.Msg{Subject: "pre.test", Reply: "ack.blackhole", Sub: sub}}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	err = newMsg().AckWithContext(ctx)
+	require.ErrorIs(t, err, ErrAckTimeout)
+	assert.Less(t, time.Since(start), time.Second)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	err = newMsg().NakWithContext(ctx)
+	require.ErrorIs(t, err, ErrAckTimeout)
+
+	// cancellations aren't timeouts
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	err = newMsg().AckWithContext(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+	assert.NotErrorIs(t, err, ErrAckTimeout)
+}
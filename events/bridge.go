@@ -0,0 +1,394 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// BridgeOriginHeader holds the regions a bridged message was published in before, one value
+	// for each. Messages are not bridged to a region they went through already, which keeps
+	// bridges running both ways between regions from looping messages.
+	BridgeOriginHeader = "Hollow-Bridge-Origin"
+
+	// number of messages a bridge fetches at once from a pull consumer.
+	bridgeFetchBatch = 10
+
+	// prefix of the headers set by the NATS server and client, these aren't carried over to the bridged message.
+	natsHeaderPrefix = "Nats-"
+)
+
+// ErrBridge is returned when a message can't be bridged.
+var ErrBridge = errors.New("error bridging message")
+
+// BridgeMapping maps the subjects consumed from the source region onto the subjects published in the
+// destination region. The * wildcards of the Destination are replaced with the subject tokens matched
+// by the Source ones, in order, and a trailing > with the tokens matched by the Source trailing >.
+//
+//	events.BridgeMapping{
+//		Source:      "com.hollow.sh.servers.*.>",
+//		Destination: "com.hollow.sh.replicated.servers.*.>",
+//	}
+type BridgeMapping struct {
+	// Source is the subject bridged, it may hold wildcards.
+	Source string `mapstructure:"source"`
+
+	// Destination is the subject messages are published on, the subject is kept when unset.
+	Destination string `mapstructure:"destination"`
+}
+
+func (m *BridgeMapping) validate() error {
+	if m.Source == "" {
+		return errors.Wrap(ErrNatsConfig, "bridge mappings require a Source")
+	}
+
+	if err := validateSubjectTokens(m.Source); err != nil {
+		return errors.Wrap(ErrNatsConfig, "bridge mapping Source: "+err.Error())
+	}
+
+	if m.Destination == "" {
+		return nil
+	}
+
+	if err := validateSubjectTokens(m.Destination); err != nil {
+		return errors.Wrap(ErrNatsConfig, "bridge mapping Destination: "+err.Error())
+	}
+
+	if strings.Count(m.Destination, "*") > strings.Count(m.Source, "*") {
+		return errors.Wrap(ErrNatsConfig, "bridge mapping Destination "+m.Destination+" has more * wildcards than its Source")
+	}
+
+	if strings.HasSuffix(m.Destination, ">") != strings.HasSuffix(m.Source, ">") {
+		return errors.Wrap(ErrNatsConfig, "bridge mapping Destination must end with > when, and only when, the Source does")
+	}
+
+	return nil
+}
+
+// destination returns the subject the message is published on in the destination region,
+// false when the subject doesn't match the mapping Source.
+func (m *BridgeMapping) destination(subject string) (string, bool) {
+	if !subjectIsSubset(subject, m.Source) {
+		return "", false
+	}
+
+	if m.Destination == "" {
+		return subject, true
+	}
+
+	st := strings.Split(subject, ".")
+
+	var (
+		wildcards []string
+		rest      []string
+	)
+
+	for i, token := range strings.Split(m.Source, ".") {
+		switch token {
+		case "*":
+			wildcards = append(wildcards, st[i])
+		case ">":
+			rest = st[i:]
+		}
+	}
+
+	dt := strings.Split(m.Destination, ".")
+	mapped := make([]string, 0, len(dt)+len(rest))
+
+	for _, token := range dt {
+		switch token {
+		case "*":
+			mapped = append(mapped, wildcards[0])
+			wildcards = wildcards[1:]
+		case ">":
+			mapped = append(mapped, rest...)
+		default:
+			mapped = append(mapped, token)
+		}
+	}
+
+	return strings.Join(mapped, "."), true
+}
+
+// BridgeOptions configures a Bridge.
+type BridgeOptions struct {
+	// SourceRegion names the region messages are consumed from, it is added to the BridgeOriginHeader.
+	SourceRegion string `mapstructure:"source_region"`
+
+	// DestinationRegion names the region messages are published in, messages which were
+	// published there before are acked without being bridged.
+	DestinationRegion string `mapstructure:"destination_region"`
+
+	// Mappings are the subjects bridged, messages are published with the first mapping matching
+	// their subject and acked without being bridged when none does.
+	Mappings []BridgeMapping `mapstructure:"mappings"`
+
+	// Logger reports the messages which couldn't be bridged, defaults to the global zap logger.
+	Logger *zap.Logger `mapstructure:"-"`
+}
+
+func (o *BridgeOptions) validate() error {
+	if o.SourceRegion == "" || o.DestinationRegion == "" {
+		return errors.Wrap(ErrNatsConfig, "bridge parameters require a SourceRegion and a DestinationRegion")
+	}
+
+	if o.SourceRegion == o.DestinationRegion {
+		return errors.Wrap(ErrNatsConfig, "bridge parameters require distinct source and destination regions")
+	}
+
+	if len(o.Mappings) == 0 {
+		return errors.Wrap(ErrNatsConfig, "bridge parameters require one or more Mappings")
+	}
+
+	for i := range o.Mappings {
+		if err := o.Mappings[i].validate(); err != nil {
+			return err
+		}
+	}
+
+	if o.Logger == nil {
+		o.Logger = zap.L()
+	}
+
+	return nil
+}
+
+// Bridge replicates the messages of a subset of subjects from a stream in one region, or NATS
+// cluster, to a stream in another. Messages are consumed with the consumer of the source stream,
+// published in the destination region then acked, and Nak'ed to be retried when they couldn't be.
+//
+// Bridged messages carry a message ID derived from their source stream sequence, so messages
+// bridged again after a redelivery are stored once within the destination stream DuplicateWindow.
+// The BridgeOriginHeader keeps messages from being bridged back to a region they came from.
+type Bridge struct {
+	source      *NatsJetstream
+	destination *NatsJetstream
+	opts        BridgeOptions
+
+	bridged uint64
+	skipped uint64
+}
+
+// NewBridge returns a Bridge consuming the messages from the source stream and publishing them
+// on the destination stream, both are expected to be opened.
+func NewBridge(source, destination *NatsJetstream, opts BridgeOptions) (*Bridge, error) {
+	if source == nil || destination == nil {
+		return nil, errors.Wrap(ErrNatsConfig, "bridge requires a source and a destination stream")
+	}
+
+	if err := opts.validate(); err != nil {
+		return nil, err
+	}
+
+	return &Bridge{source: source, destination: destination, opts: opts}, nil
+}
+
+// Run subscribes to the source stream and bridges the messages until the context is done or the
+// source stream is closed. Messages which couldn't be bridged are logged and Nak'ed.
+func (b *Bridge) Run(ctx context.Context) error {
+	msgCh, err := b.source.Subscribe(ctx)
+	if err != nil {
+		return err
+	}
+
+	if b.source.parameters.Consumer != nil && b.source.parameters.Consumer.Pull {
+		return b.runPull(ctx)
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-msgCh:
+			if !ok {
+				return nil
+			}
+
+			b.bridgeAndLog(ctx, msg)
+		}
+	}
+}
+
+func (b *Bridge) runPull(ctx context.Context) error {
+	for {
+		msgs, err := b.source.PullMsg(ctx, bridgeFetchBatch)
+
+		switch {
+		case errors.Is(err, nats.ErrTimeout):
+			continue
+		case errors.Is(err, ErrNatsClosed), errors.Is(err, ErrNatsDraining):
+			return nil
+		case err != nil:
+			return err
+		}
+
+		for _, msg := range msgs {
+			b.bridgeAndLog(ctx, msg)
+		}
+	}
+}
+
+func (b *Bridge) bridgeAndLog(ctx context.Context, msg Message) {
+	if err := b.BridgeMsg(ctx, msg); err != nil {
+		b.opts.Logger.Warn("message not bridged, retrying it later",
+			zap.String("subject", msg.Subject()),
+			zap.String("destination_region", b.opts.DestinationRegion),
+			zap.Error(err),
+		)
+	}
+}
+
+// BridgeMsg publishes the message in the destination region and acks it, the message is Nak'ed when
+// it couldn't be published. Messages not matching any mapping, or which were published in the
+// destination region before, are acked without being bridged.
+func (b *Bridge) BridgeMsg(ctx context.Context, msg Message) error {
+	nm, err := AsNatsMsg(msg)
+	if err != nil {
+		return errors.Wrap(ErrBridge, err.Error())
+	}
+
+	subject, ok := b.destinationSubject(nm.Subject)
+	if !ok || b.visited(nm.Header) {
+		atomic.AddUint64(&b.skipped, 1)
+		return msg.Ack()
+	}
+
+	out := nats.NewMsg(subject)
+	out.Data = nm.Data
+
+	for key, values := range nm.Header {
+		if strings.HasPrefix(key, natsHeaderPrefix) {
+			continue
+		}
+
+		out.Header[key] = append([]string(nil), values...)
+	}
+
+	out.Header.Add(BridgeOriginHeader, b.opts.SourceRegion)
+
+	if err := b.publish(ctx, out, b.msgID(msg, nm)); err != nil {
+		_ = msg.Nak()
+		return err
+	}
+
+	atomic.AddUint64(&b.bridged, 1)
+
+	return msg.Ack()
+}
+
+// Stats returns the number of messages bridged, and acked without being bridged.
+func (b *Bridge) Stats() (bridged, skipped uint64) {
+	return atomic.LoadUint64(&b.bridged), atomic.LoadUint64(&b.skipped)
+}
+
+func (b *Bridge) publish(ctx context.Context, msg *nats.Msg, msgID string) error {
+	if b.destination.jsctx == nil {
+		return errors.Wrap(ErrBridge, "destination Jetstream context is not setup")
+	}
+
+	if err := b.destination.validatePublishSubject(msg.Subject); err != nil {
+		return err
+	}
+
+	options := []nats.PubOpt{nats.Context(ctx)}
+
+	if msgID != "" {
+		options = append(options, nats.MsgId(msgID))
+	}
+
+	if _, err := b.destination.jsctx.PublishMsg(msg, options...); err != nil {
+		return errors.Wrap(ErrBridge, err.Error()+": "+msg.Subject)
+	}
+
+	return nil
+}
+
+func (b *Bridge) destinationSubject(subject string) (string, bool) {
+	for i := range b.opts.Mappings {
+		if mapped, ok := b.opts.Mappings[i].destination(subject); ok {
+			return mapped, true
+		}
+	}
+
+	return "", false
+}
+
+// visited returns true when the message was published in the destination region before.
+func (b *Bridge) visited(h nats.Header) bool {
+	for _, region := range h.Values(BridgeOriginHeader) {
+		if region == b.opts.DestinationRegion {
+			return true
+		}
+	}
+
+	return false
+}
+
+// msgID returns the ID deduplicating the bridged message, derived from its source stream sequence,
+// or from its own message ID when the source stream isn't known.
+func (b *Bridge) msgID(msg Message, nm *nats.Msg) string {
+	if md, err := msg.Metadata(); err == nil && md.Stream != "" {
+		return fmt.Sprintf("%s.%s.%d", b.opts.SourceRegion, md.Stream, md.StreamSequence)
+	}
+
+	if id := nm.Header.Get(nats.MsgIdHdr); id != "" {
+		return b.opts.SourceRegion + "." + id
+	}
+
+	return ""
+}
+
+// BridgeConfig configures a standalone bridge worker run with RunBridge.
+type BridgeConfig struct {
+	// Source configures the connection and consumer in the region messages are bridged from.
+	Source NatsOptions `mapstructure:"source"`
+
+	// Destination configures the connection in the region messages are bridged to.
+	Destination NatsOptions `mapstructure:"destination"`
+
+	// Bridge configures the regions and the subjects bridged.
+	Bridge BridgeOptions `mapstructure:"bridge"`
+}
+
+// RunBridge connects to both regions and bridges messages until the context is done,
+// it is meant to be run as a standalone worker. Both connections are closed on return.
+func RunBridge(ctx context.Context, cfg BridgeConfig) error {
+	source, err := NewNatsBroker(cfg.Source)
+	if err != nil {
+		return errors.Wrap(err, "bridge source")
+	}
+
+	destination, err := NewNatsBroker(cfg.Destination)
+	if err != nil {
+		return errors.Wrap(err, "bridge destination")
+	}
+
+	bridge, err := NewBridge(source, destination, cfg.Bridge)
+	if err != nil {
+		return err
+	}
+
+	if err := source.Open(); err != nil {
+		return errors.Wrap(err, "bridge source")
+	}
+
+	defer source.Close()
+
+	if err := destination.Open(); err != nil {
+		return errors.Wrap(err, "bridge destination")
+	}
+
+	defer destination.Close()
+
+	if err := bridge.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,194 @@
+//nolint:all
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	srvtest "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	natsTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+func TestBridgeMapping_destination(t *testing.T) {
+	tests := []struct {
+		name    string
+		mapping BridgeMapping
+		subject string
+		want    string
+		wantOK  bool
+	}{
+		{"subject kept", BridgeMapping{Source: "com.hollow.sh.>"}, "com.hollow.sh.servers.create", "com.hollow.sh.servers.create", true},
+		{"not matching", BridgeMapping{Source: "com.hollow.sh.servers.>"}, "com.hollow.sh.other.create", "", false},
+		{
+			"full wildcard carried over",
+			BridgeMapping{Source: "com.hollow.sh.servers.>", Destination: "replicated.servers.>"},
+			"com.hollow.sh.servers.ams1.create",
+			"replicated.servers.ams1.create",
+			true,
+		},
+		{
+			"wildcards in order",
+			BridgeMapping{Source: "legacy.*.*.events", Destination: "com.hollow.sh.*.*"},
+			"legacy.servers.create.events",
+			"com.hollow.sh.servers.create",
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.NoError(t, tt.mapping.validate())
+
+			got, ok := tt.mapping.destination(tt.subject)
+			assert.Equal(t, tt.wantOK, ok)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestBridgeOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name          string
+		opts          BridgeOptions
+		errorContains string
+	}{
+		{"regions required", BridgeOptions{Mappings: []BridgeMapping{{Source: "a.>"}}}, "require a SourceRegion and a DestinationRegion"},
+		{"distinct regions", BridgeOptions{SourceRegion: "ams", DestinationRegion: "ams", Mappings: []BridgeMapping{{Source: "a.>"}}}, "distinct"},
+		{"mappings required", BridgeOptions{SourceRegion: "ams", DestinationRegion: "dfw"}, "one or more Mappings"},
+		{
+			"destination wildcards",
+			BridgeOptions{SourceRegion: "ams", DestinationRegion: "dfw", Mappings: []BridgeMapping{{Source: "a.*", Destination: "b.*.*"}}},
+			"more * wildcards",
+		},
+		{
+			"destination full wildcard",
+			BridgeOptions{SourceRegion: "ams", DestinationRegion: "dfw", Mappings: []BridgeMapping{{Source: "a.>", Destination: "b.c"}}},
+			"must end with >",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opts.validate()
+			assert.ErrorIs(t, err, ErrNatsConfig)
+			assert.ErrorContains(t, err, tt.errorContains)
+		})
+	}
+}
+
+// startRegionServer starts a JetStream server storing its streams apart from the other servers.
+func startRegionServer(t *testing.T) *server.Server {
+	t.Helper()
+
+	opts := srvtest.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+
+	return srvtest.RunServer(&opts)
+}
+
+func TestBridge(t *testing.T) {
+	amsSrv := startRegionServer(t)
+	defer natsTest.ShutdownJetStream(t, amsSrv)
+
+	dfwSrv := startRegionServer(t)
+	defer natsTest.ShutdownJetStream(t, dfwSrv)
+
+	amsConn, amsJS := natsTest.JetStreamContext(t, amsSrv)
+	source := NewJetstreamFromConn(amsConn)
+	defer source.Close()
+
+	source.parameters = &NatsOptions{
+		AppName: "bridge",
+		Stream: &NatsStreamOptions{
+			Name:      "hollow",
+			Subjects:  []string{"com.hollow.sh.>"},
+			Retention: "limits",
+		},
+		Consumer: &NatsConsumerOptions{
+			Name:              "bridge-dfw",
+			Pull:              true,
+			SubscribeSubjects: []string{"com.hollow.sh.>"},
+		},
+	}
+	require.NoError(t, source.parameters.Consumer.validate())
+	require.NoError(t, source.addStream())
+	require.NoError(t, source.addConsumer())
+
+	dfwConn, dfwJS := natsTest.JetStreamContext(t, dfwSrv)
+	destination := NewJetstreamFromConn(dfwConn)
+	defer destination.Close()
+
+	destination.parameters = &NatsOptions{
+		AppName: "bridge",
+		Stream: &NatsStreamOptions{
+			Name:            "hollow",
+			Subjects:        []string{"com.hollow.sh.>"},
+			Retention:       "limits",
+			DuplicateWindow: time.Minute,
+		},
+	}
+	require.NoError(t, destination.addStream())
+
+	bridge, err := NewBridge(source, destination, BridgeOptions{
+		SourceRegion:      "ams",
+		DestinationRegion: "dfw",
+		Mappings: []BridgeMapping{
+			{Source: "com.hollow.sh.servers.>", Destination: "com.hollow.sh.replicated.servers.>"},
+		},
+	})
+	require.NoError(t, err)
+
+	bridged := nats.NewMsg("com.hollow.sh.servers.create")
+	bridged.Data = []byte("bridged")
+	bridged.Header.Set(HeaderContentType, "application/json")
+	bridged.Header.Set(nats.MsgIdHdr, "source-id")
+
+	// messages coming from the destination region aren't bridged back
+	loop := nats.NewMsg("com.hollow.sh.servers.update")
+	loop.Data = []byte("loop")
+	loop.Header.Add(BridgeOriginHeader, "dfw")
+
+	for _, msg := range []*nats.Msg{bridged, loop, {Subject: "com.hollow.sh.other.create", Data: []byte("not mapped")}} {
+		_, err := amsJS.PublishMsg(msg)
+		require.NoError(t, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error)
+	go func() { done <- bridge.Run(ctx) }()
+
+	require.Eventually(t, func() bool {
+		b, s := bridge.Stats()
+		return b == 1 && s == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+
+	info, err := dfwJS.StreamInfo("hollow")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), info.State.Msgs)
+
+	got, err := dfwJS.GetLastMsg("hollow", "com.hollow.sh.replicated.servers.create")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("bridged"), got.Data)
+	assert.Equal(t, "application/json", got.Header.Get(HeaderContentType))
+	assert.Equal(t, []string{"ams"}, got.Header.Values(BridgeOriginHeader))
+	// the ID is derived from the source stream sequence, not carried over
+	assert.Equal(t, "ams.hollow.1", got.Header.Get(nats.MsgIdHdr))
+
+	// all the messages were acked on the source
+	consumer, err := amsJS.ConsumerInfo("hollow", "bridge-dfw")
+	require.NoError(t, err)
+	assert.Equal(t, 0, consumer.NumAckPending)
+	assert.Equal(t, uint64(0), consumer.NumPending)
+}
@@ -0,0 +1,174 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
+)
+
+const (
+	// ChangeEventVersionV1 is the version of the ChangeEvent payload described by ChangeEventSchemaV1.
+	ChangeEventVersionV1 = "v1"
+
+	// ChangeEventSchemaV1ID identifies the JSON schema of v1 change events.
+	ChangeEventSchemaV1ID = "https://go.hollow.sh/toolbox/events/schemas/change-event/v1.json"
+
+	// ChangeEventSchemaV1 is the JSON schema of v1 change events, consumers in other languages
+	// can validate payloads against it.
+	ChangeEventSchemaV1 = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "$id": "` + ChangeEventSchemaV1ID + `",
+  "title": "ChangeEvent",
+  "type": "object",
+  "required": ["version", "resource_type", "event_type", "resource_id", "timestamp"],
+  "properties": {
+    "version": {"const": "v1"},
+    "resource_type": {"type": "string", "minLength": 1},
+    "event_type": {"type": "string", "minLength": 1},
+    "resource_id": {"type": "string", "format": "uuid"},
+    "urn": {"type": "string"},
+    "timestamp": {"type": "string", "format": "date-time"},
+    "actor_urn": {"type": "string"},
+    "data": {}
+  }
+}`
+)
+
+var (
+	// ErrInvalidChangeEvent is returned when a change event is missing required fields or can't be decoded.
+	ErrInvalidChangeEvent = errors.New("invalid change event")
+
+	// ErrUnsupportedChangeEventVersion is returned when parsing a change event of an unknown version.
+	ErrUnsupportedChangeEventVersion = errors.New("unsupported change event version")
+)
+
+// ChangeEvent is the standard payload published when a resource changes, so that
+// producers and consumers across services agree on its shape.
+type ChangeEvent struct {
+	// Version is the version of the payload, set to ChangeEventVersionV1 when published.
+	Version string `json:"version"`
+
+	// ResourceType is the kind of the resource that changed, e.g. "servers".
+	ResourceType ResourceType `json:"resource_type"`
+
+	// EventType is the change that occurred, e.g. Create.
+	EventType EventType `json:"event_type"`
+
+	// ResourceID identifies the resource that changed.
+	ResourceID uuid.UUID `json:"resource_id"`
+
+	// URN is the URN of the resource, see ResourceURN.
+	URN string `json:"urn,omitempty"`
+
+	// Timestamp is when the change occurred, set to the publish time when zero.
+	Timestamp time.Time `json:"timestamp"`
+
+	// ActorURN identifies who made the change.
+	ActorURN string `json:"actor_urn,omitempty"`
+
+	// Data holds the resource as it is after the change, or any other event specific data.
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// NewChangeEvent returns a ChangeEvent for the resource with the given data marshaled as JSON.
+func NewChangeEvent(resourceType ResourceType, eventType EventType, resourceID uuid.UUID, data interface{}) (*ChangeEvent, error) {
+	ev := &ChangeEvent{
+		Version:      ChangeEventVersionV1,
+		ResourceType: resourceType,
+		EventType:    eventType,
+		ResourceID:   resourceID,
+		Timestamp:    time.Now().UTC(),
+	}
+
+	if data != nil {
+		raw, err := json.Marshal(data)
+		if err != nil {
+			return nil, errors.Wrap(ErrInvalidChangeEvent, err.Error())
+		}
+
+		ev.Data = raw
+	}
+
+	return ev, ev.validate()
+}
+
+// ResourceURN returns the URN of a resource, e.g. "urn:hollow:servers:<uuid>".
+func ResourceURN(namespace string, resourceType ResourceType, resourceID uuid.UUID) string {
+	return strings.Join([]string{"urn", namespace, string(resourceType), resourceID.String()}, ":")
+}
+
+// ChangeEventSubject returns the subject change events of a resource type are published on, "<resource type>.<event type>".
+func ChangeEventSubject(resourceType ResourceType, eventType EventType) string {
+	return string(resourceType) + "." + string(eventType)
+}
+
+// UnmarshalData decodes the event data into v.
+func (e *ChangeEvent) UnmarshalData(v interface{}) error {
+	if len(e.Data) == 0 {
+		return errors.Wrap(ErrInvalidChangeEvent, "no data")
+	}
+
+	if err := json.Unmarshal(e.Data, v); err != nil {
+		return errors.Wrap(ErrInvalidChangeEvent, err.Error())
+	}
+
+	return nil
+}
+
+func (e *ChangeEvent) validate() error {
+	switch {
+	case e.ResourceType == "":
+		return errors.Wrap(ErrInvalidChangeEvent, "ResourceType required")
+	case e.EventType == "":
+		return errors.Wrap(ErrInvalidChangeEvent, "EventType required")
+	case e.ResourceID == uuid.Nil:
+		return errors.Wrap(ErrInvalidChangeEvent, "ResourceID required")
+	default:
+		return nil
+	}
+}
+
+// PublishChangeEvent publishes the change event on the stream, on the subject returned by ChangeEventSubject.
+func PublishChangeEvent(ctx context.Context, stream Stream, ev *ChangeEvent) error {
+	if ev.Version == "" {
+		ev.Version = ChangeEventVersionV1
+	}
+
+	if ev.Timestamp.IsZero() {
+		ev.Timestamp = time.Now().UTC()
+	}
+
+	if err := ev.validate(); err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(ev)
+	if err != nil {
+		return errors.Wrap(ErrInvalidChangeEvent, err.Error())
+	}
+
+	return stream.Publish(ctx, ChangeEventSubject(ev.ResourceType, ev.EventType), data)
+}
+
+// ParseChangeEvent decodes the change event held by a message received on the stream.
+func ParseChangeEvent(msg Message) (*ChangeEvent, error) {
+	ev := &ChangeEvent{}
+
+	if err := json.Unmarshal(msg.Data(), ev); err != nil {
+		return nil, errors.Wrap(ErrInvalidChangeEvent, err.Error())
+	}
+
+	if ev.Version != ChangeEventVersionV1 {
+		return nil, errors.Wrap(ErrUnsupportedChangeEventVersion, "version="+ev.Version)
+	}
+
+	if err := ev.validate(); err != nil {
+		return nil, err
+	}
+
+	return ev, nil
+}
@@ -0,0 +1,96 @@
+//nolint:all
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	natsTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+func TestPublishAndParseChangeEvent(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName: "TestPublishAndParseChangeEvent",
+		Stream: &NatsStreamOptions{
+			Name:      "test_stream",
+			Subjects:  []string{"pre.servers.create"},
+			Retention: "workQueue",
+		},
+		Consumer: &NatsConsumerOptions{
+			Name:              "test_consumer",
+			Pull:              true,
+			SubscribeSubjects: []string{"pre.servers.create"},
+			FilterSubject:     "pre.servers.create",
+		},
+		PublisherSubjectPrefix: "pre",
+	}
+	require.NoError(t, njs.addStream())
+	require.NoError(t, njs.addConsumer())
+
+	_, err := njs.Subscribe(context.TODO())
+	require.NoError(t, err)
+
+	id := uuid.New()
+
+	ev, err := NewChangeEvent("servers", Create, id, map[string]string{"name": "srv-1"})
+	require.NoError(t, err)
+
+	ev.URN = ResourceURN("hollow", "servers", id)
+	ev.ActorURN = "urn:hollow:users:" + uuid.NewString()
+
+	require.NoError(t, PublishChangeEvent(context.TODO(), njs, ev))
+
+	msgs, err := njs.PullMsg(context.TODO(), 1)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "pre.servers.create", msgs[0].Subject())
+
+	got, err := ParseChangeEvent(msgs[0])
+	require.NoError(t, err)
+
+	assert.Equal(t, ChangeEventVersionV1, got.Version)
+	assert.Equal(t, ResourceType("servers"), got.ResourceType)
+	assert.Equal(t, Create, got.EventType)
+	assert.Equal(t, id, got.ResourceID)
+	assert.Equal(t, "urn:hollow:servers:"+id.String(), got.URN)
+	assert.Equal(t, ev.ActorURN, got.ActorURN)
+	assert.True(t, ev.Timestamp.Equal(got.Timestamp))
+
+	var data map[string]string
+	require.NoError(t, got.UnmarshalData(&data))
+	assert.Equal(t, "srv-1", data["name"])
+}
+
+func TestChangeEventValidation(t *testing.T) {
+	_, err := NewChangeEvent("", Create, uuid.New(), nil)
+	assert.ErrorIs(t, err, ErrInvalidChangeEvent)
+
+	_, err = NewChangeEvent("servers", Create, uuid.Nil, nil)
+	assert.ErrorIs(t, err, ErrInvalidChangeEvent)
+
+	require.ErrorIs(t, PublishChangeEvent(context.TODO(), nil, &ChangeEvent{ResourceType: "servers"}), ErrInvalidChangeEvent)
+
+	_, err = ParseChangeEvent(&natsMsg{msg: &nats.Msg{Data: []byte(`{"version": "v2"}`)}})
+	assert.ErrorIs(t, err, ErrUnsupportedChangeEventVersion)
+
+	_, err = ParseChangeEvent(&natsMsg{msg: &nats.Msg{Data: []byte(`not json`)}})
+	assert.ErrorIs(t, err, ErrInvalidChangeEvent)
+
+	// the schema is valid JSON
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(ChangeEventSchemaV1), &schema))
+	assert.Equal(t, ChangeEventSchemaV1ID, schema["$id"])
+}
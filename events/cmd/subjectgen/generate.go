@@ -0,0 +1,258 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/format"
+	"go/token"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"unicode"
+
+	"gopkg.in/yaml.v3"
+)
+
+var errInvalidSpec = errors.New("invalid subject spec")
+
+// subject is a subject of the spec hierarchy.
+type subject struct {
+	// Name is the name of the constant, the function is named after it
+	Name string
+	// Tokens are the literal tokens of the subject, and the names of its parameters
+	Tokens []subjectToken
+}
+
+type subjectToken struct {
+	Value string
+	Param bool
+}
+
+// Pattern returns the subject with the * wildcard standing for the parameters.
+func (s subject) Pattern() string {
+	tokens := make([]string, len(s.Tokens))
+
+	for i, t := range s.Tokens {
+		tokens[i] = t.Value
+		if t.Param {
+			tokens[i] = "*"
+		}
+	}
+
+	return strings.Join(tokens, ".")
+}
+
+// Spec returns the subject as written in the spec, with the parameters in braces.
+func (s subject) Spec() string {
+	tokens := make([]string, len(s.Tokens))
+
+	for i, t := range s.Tokens {
+		tokens[i] = t.Value
+		if t.Param {
+			tokens[i] = "{" + t.Value + "}"
+		}
+	}
+
+	return strings.Join(tokens, ".")
+}
+
+// Params returns the parameters of the builder function.
+func (s subject) Params() string {
+	var params []string
+
+	for _, t := range s.Tokens {
+		if t.Param {
+			params = append(params, t.Value+" string")
+		}
+	}
+
+	return strings.Join(params, ", ")
+}
+
+// Args returns the arguments of the events.NewSubjectBuilder call.
+func (s subject) Args() string {
+	args := make([]string, len(s.Tokens))
+
+	for i, t := range s.Tokens {
+		args[i] = t.Value
+		if !t.Param {
+			args[i] = fmt.Sprintf("%q", t.Value)
+		}
+	}
+
+	return strings.Join(args, ", ")
+}
+
+var fileTemplate = template.Must(template.New("subjects").Parse(`// Code generated by subjectgen from {{ .Source }}; DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+	"fmt"
+
+	"go.hollow.sh/toolbox/events"
+)
+
+// The subjects of the spec, the * wildcard stands for their parameters.
+const (
+{{- range .Subjects }}
+	// {{ .Name }} is the {{ .Spec }} subject
+	{{ .Name }} = {{ printf "%q" .Pattern }}
+{{- end }}
+)
+{{ range .Subjects }}
+// {{ .Name }}Subject returns the builder of the {{ .Spec }} subject
+func {{ .Name }}Subject({{ .Params }}) events.SubjectBuilder {
+	return events.NewSubjectBuilder({{ .Args }})
+}
+{{ end }}
+// Patterns are all the subjects of the spec.
+var Patterns = []string{
+{{- range .Subjects }}
+	{{ .Name }},
+{{- end }}
+}
+
+// Validate returns an error wrapping events.ErrInvalidSubject when the subject isn't valid or
+// doesn't match any of the subjects of the spec.
+func Validate(subject string) error {
+	if err := events.ValidateSubject(subject); err != nil {
+		return err
+	}
+
+	for _, pattern := range Patterns {
+		if events.SubjectMatches(subject, pattern) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: %s isn't in the subject spec", events.ErrInvalidSubject, subject)
+}
+`))
+
+// generate returns the Go source of the constants and builders of the subjects in the spec.
+func generate(spec []byte, pkg, source string) ([]byte, error) {
+	var root struct {
+		Subjects yaml.Node `yaml:"subjects"`
+	}
+
+	if err := yaml.Unmarshal(spec, &root); err != nil {
+		return nil, fmt.Errorf("%w: %s", errInvalidSpec, err)
+	}
+
+	if root.Subjects.Kind != yaml.MappingNode || len(root.Subjects.Content) == 0 {
+		return nil, fmt.Errorf("%w: subjects must map one or more tokens", errInvalidSpec)
+	}
+
+	var subjects []subject
+
+	if err := walk(&root.Subjects, nil, &subjects); err != nil {
+		return nil, err
+	}
+
+	names := map[string]string{"Patterns": "", "Validate": ""}
+
+	for _, s := range subjects {
+		for _, name := range []string{s.Name, s.Name + "Subject"} {
+			if other, ok := names[name]; ok {
+				return nil, fmt.Errorf("%w: %s and %s are both named %s", errInvalidSpec, s.Spec(), other, name)
+			}
+
+			names[name] = s.Spec()
+		}
+	}
+
+	var buf bytes.Buffer
+
+	err := fileTemplate.Execute(&buf, map[string]interface{}{
+		"Source":   filepath.Base(source),
+		"Package":  pkg,
+		"Subjects": subjects,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return format.Source(buf.Bytes())
+}
+
+// walk appends the subjects of the mapping node, in the spec order, under the parent tokens.
+func walk(node *yaml.Node, parent []subjectToken, subjects *[]subject) error {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+
+		tok, err := parseToken(key.Value, parent)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", key.Line, err)
+		}
+
+		tokens := append(append([]subjectToken(nil), parent...), tok)
+
+		s := subject{Name: subjectName(tokens), Tokens: tokens}
+		if !token.IsIdentifier(s.Name) {
+			return fmt.Errorf("line %d: %w: %s doesn't make a Go identifier", key.Line, errInvalidSpec, s.Spec())
+		}
+
+		*subjects = append(*subjects, s)
+
+		switch {
+		case value.Kind == yaml.MappingNode:
+			if err := walk(value, tokens, subjects); err != nil {
+				return err
+			}
+		case value.Kind == yaml.ScalarNode && value.Tag == "!!null":
+			// the subject has no subjects under it
+		default:
+			return fmt.Errorf("line %d: %w: %s must map the tokens under it or be empty", value.Line, errInvalidSpec, s.Spec())
+		}
+	}
+
+	return nil
+}
+
+// parseToken returns the token of the spec key, a parameter when it is in braces.
+func parseToken(key string, parent []subjectToken) (subjectToken, error) {
+	if strings.HasPrefix(key, "{") && strings.HasSuffix(key, "}") {
+		name := key[1 : len(key)-1]
+
+		if !token.IsIdentifier(name) || token.IsKeyword(name) {
+			return subjectToken{}, fmt.Errorf("%w: parameter %s isn't a Go identifier", errInvalidSpec, key)
+		}
+
+		// the parameters would shadow the packages imported by the generated file
+		if name == "events" || name == "fmt" {
+			return subjectToken{}, fmt.Errorf("%w: parameter %s shadows an import", errInvalidSpec, key)
+		}
+
+		for _, t := range parent {
+			if t.Param && t.Value == name {
+				return subjectToken{}, fmt.Errorf("%w: parameter %s is repeated", errInvalidSpec, key)
+			}
+		}
+
+		return subjectToken{Value: name, Param: true}, nil
+	}
+
+	if key == "" || strings.ContainsAny(key, ".*>{} \t\r\n") {
+		return subjectToken{}, fmt.Errorf("%w: invalid token %q", errInvalidSpec, key)
+	}
+
+	return subjectToken{Value: key}, nil
+}
+
+// subjectName returns the CamelCase name of the subject, the tokens are split on dashes and underscores.
+func subjectName(tokens []subjectToken) string {
+	var b strings.Builder
+
+	for _, t := range tokens {
+		for _, word := range strings.FieldsFunc(t.Value, func(r rune) bool { return r == '-' || r == '_' }) {
+			runes := []rune(word)
+			runes[0] = unicode.ToUpper(runes[0])
+
+			b.WriteString(string(runes))
+		}
+	}
+
+	return b.String()
+}
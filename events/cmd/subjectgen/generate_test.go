@@ -0,0 +1,49 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateMatchesExample(t *testing.T) {
+	spec, err := os.ReadFile("internal/example/subjects.yaml")
+	require.NoError(t, err)
+
+	want, err := os.ReadFile("internal/example/subjects_gen.go")
+	require.NoError(t, err)
+
+	got, err := generate(spec, "example", "internal/example/subjects.yaml")
+	require.NoError(t, err)
+
+	// run go generate ./... when this fails after changing the generator
+	assert.Equal(t, string(want), string(got))
+}
+
+func TestGenerateInvalidSpec(t *testing.T) {
+	testCases := []struct {
+		name          string
+		spec          string
+		errorContains string
+	}{
+		{"no subjects", "other: {}", "subjects must map one or more tokens"},
+		{"dotted token", "subjects:\n  servers.create:\n", `invalid token "servers.create"`},
+		{"wildcard token", "subjects:\n  servers:\n    '*':\n", `invalid token "*"`},
+		{"invalid parameter", "subjects:\n  '{site-id}':\n", "parameter {site-id} isn't a Go identifier"},
+		{"shadowing parameter", "subjects:\n  '{events}':\n", "parameter {events} shadows an import"},
+		{"repeated parameter", "subjects:\n  '{site}':\n    '{site}':\n", "parameter {site} is repeated"},
+		{"not an identifier", "subjects:\n  1servers:\n", "1servers doesn't make a Go identifier"},
+		{"same names", "subjects:\n  bmc-reset:\n  bmc_reset:\n", "are both named BmcReset"},
+		{"list", "subjects:\n  servers: [create]\n", "servers must map the tokens under it or be empty"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := generate([]byte(tt.spec), "example", "subjects.yaml")
+			assert.ErrorIs(t, err, errInvalidSpec)
+			assert.ErrorContains(t, err, tt.errorContains)
+		})
+	}
+}
@@ -0,0 +1,5 @@
+// Package example holds the subjects generated by subjectgen from the example spec, it is
+// compared with the generator output by its tests.
+package example
+
+//go:generate go run go.hollow.sh/toolbox/events/cmd/subjectgen -spec subjects.yaml -out subjects_gen.go
@@ -0,0 +1,27 @@
+package example_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.hollow.sh/toolbox/events"
+	"go.hollow.sh/toolbox/events/cmd/subjectgen/internal/example"
+)
+
+func TestGeneratedSubjects(t *testing.T) {
+	subject, err := example.ServersSiteInventorySubject("ams1").Build()
+	require.NoError(t, err)
+	assert.Equal(t, "servers.ams1.inventory", subject)
+	assert.True(t, events.SubjectMatches(subject, example.ServersSiteInventory))
+
+	_, err = example.ServersSiteInventorySubject("ams1.dc2").Build()
+	assert.ErrorIs(t, err, events.ErrInvalidSubject)
+
+	assert.Equal(t, "servers.>", example.ServersSubject().All().String())
+
+	assert.NoError(t, example.Validate("jobs.42.status_update"))
+	assert.ErrorIs(t, example.Validate("jobs.42.status"), events.ErrInvalidSubject)
+	assert.ErrorIs(t, example.Validate("jobs..status_update"), events.ErrInvalidSubject)
+}
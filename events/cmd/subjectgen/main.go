@@ -0,0 +1,55 @@
+// Command subjectgen generates the Go constants and SubjectBuilder functions of a YAML subject
+// hierarchy spec, so services don't spell out subjects by hand. It is meant to be run with go generate:
+//
+//	//go:generate go run go.hollow.sh/toolbox/events/cmd/subjectgen -spec subjects.yaml -out subjects_gen.go
+//
+// The spec nests the subject tokens, a token in braces is a parameter of the builder functions:
+//
+//	subjects:
+//	  servers:
+//	    create:
+//	    "{site}":
+//	      inventory:
+//
+// Every subject of the hierarchy gets a constant, e.g. ServersCreate = "servers.create", parameters
+// being the * wildcard in it, a function returning its events.SubjectBuilder, e.g. ServersSiteInventorySubject(site),
+// and is listed in Patterns, which Validate matches subjects against.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+)
+
+// generatedFileMode is the mode the generated file is written with
+const generatedFileMode = 0o644
+
+func main() {
+	spec := flag.String("spec", "subjects.yaml", "path of the YAML subject spec")
+	out := flag.String("out", "subjects_gen.go", "path of the generated Go file")
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "package of the generated Go file, defaults to the package running go generate")
+
+	flag.Parse()
+
+	if *pkg == "" {
+		log.Fatal("subjectgen: -package is required outside of go generate")
+	}
+
+	data, err := os.ReadFile(*spec)
+	if err != nil {
+		log.Fatalf("subjectgen: %s", err)
+	}
+
+	src, err := generate(data, *pkg, *spec)
+	if err != nil {
+		log.Fatalf("subjectgen: %s: %s", *spec, err)
+	}
+
+	if err := os.WriteFile(*out, src, generatedFileMode); err != nil {
+		log.Fatalf("subjectgen: %s", err)
+	}
+
+	fmt.Printf("subjectgen: wrote %s\n", *out)
+}
@@ -0,0 +1,241 @@
+package events
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"sync"
+
+	"github.com/pkg/errors"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// HeaderContentType is the message header naming the codec the payload was marshaled with.
+	HeaderContentType = "Content-Type"
+
+	// HeaderContentEncoding is the message header naming the encoding applied to the marshaled payload.
+	HeaderContentEncoding = "Content-Encoding"
+
+	// ContentTypeJSON is the content type of JSON payloads, payloads without a content type are assumed to be JSON.
+	ContentTypeJSON = "application/json"
+
+	// ContentTypeProtobuf is the content type of protobuf payloads.
+	ContentTypeProtobuf = "application/protobuf"
+
+	// ContentEncodingGzip is the content encoding of gzip compressed payloads.
+	ContentEncodingGzip = "gzip"
+)
+
+var (
+	// ErrUnknownContentType is returned when no codec is registered for a content type.
+	ErrUnknownContentType = errors.New("unknown payload content type")
+
+	// ErrUnknownContentEncoding is returned when no encoding is registered for a content encoding.
+	ErrUnknownContentEncoding = errors.New("unknown payload content encoding")
+
+	// ErrPayloadCodec is returned when a payload can't be marshaled or unmarshaled.
+	ErrPayloadCodec = errors.New("error in payload codec")
+)
+
+// Codec marshals payloads of a content type.
+type Codec interface {
+	ContentType() string
+	Marshal(v interface{}) ([]byte, error)
+	Unmarshal(data []byte, v interface{}) error
+}
+
+// Encoding transforms marshaled payloads, e.g. to compress them.
+type Encoding interface {
+	Name() string
+	Encode(data []byte) ([]byte, error)
+	Decode(data []byte) ([]byte, error)
+}
+
+var (
+	codecsMu  sync.RWMutex
+	codecs    = map[string]Codec{}
+	encodings = map[string]Encoding{}
+)
+
+func init() {
+	RegisterCodec(JSONCodec{})
+	RegisterCodec(ProtobufCodec{})
+	RegisterEncoding(GzipEncoding{})
+}
+
+// RegisterCodec registers the codec for its content type, replacing any codec registered for it.
+func RegisterCodec(c Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	codecs[c.ContentType()] = c
+}
+
+// RegisterEncoding registers the encoding by name, replacing any encoding registered with the name.
+func RegisterEncoding(e Encoding) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	encodings[e.Name()] = e
+}
+
+// CodecFor returns the codec registered for the content type, JSON when the content type is empty.
+func CodecFor(contentType string) (Codec, error) {
+	if contentType == "" {
+		contentType = ContentTypeJSON
+	}
+
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	c, ok := codecs[contentType]
+	if !ok {
+		return nil, errors.Wrap(ErrUnknownContentType, contentType)
+	}
+
+	return c, nil
+}
+
+// EncodingFor returns the encoding registered with the name, nil when the name is empty.
+func EncodingFor(name string) (Encoding, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	e, ok := encodings[name]
+	if !ok {
+		return nil, errors.Wrap(ErrUnknownContentEncoding, name)
+	}
+
+	return e, nil
+}
+
+// EncodePayload marshals v with the codec of the content type and applies the content encoding when set.
+func EncodePayload(v interface{}, contentType, contentEncoding string) ([]byte, error) {
+	codec, err := CodecFor(contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	encoding, err := EncodingFor(contentEncoding)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return nil, errors.Wrap(ErrPayloadCodec, err.Error())
+	}
+
+	if encoding != nil {
+		if data, err = encoding.Encode(data); err != nil {
+			return nil, errors.Wrap(ErrPayloadCodec, err.Error())
+		}
+	}
+
+	return data, nil
+}
+
+// DecodePayload reverses the content encoding when set and unmarshals the payload into v
+// with the codec of the content type.
+func DecodePayload(data []byte, contentType, contentEncoding string, v interface{}) error {
+	codec, err := CodecFor(contentType)
+	if err != nil {
+		return err
+	}
+
+	encoding, err := EncodingFor(contentEncoding)
+	if err != nil {
+		return err
+	}
+
+	if encoding != nil {
+		if data, err = encoding.Decode(data); err != nil {
+			return errors.Wrap(ErrPayloadCodec, err.Error())
+		}
+	}
+
+	if err := codec.Unmarshal(data, v); err != nil {
+		return errors.Wrap(ErrPayloadCodec, err.Error())
+	}
+
+	return nil
+}
+
+// JSONCodec marshals payloads as JSON.
+type JSONCodec struct{}
+
+// ContentType returns ContentTypeJSON.
+func (JSONCodec) ContentType() string { return ContentTypeJSON }
+
+// Marshal returns the JSON encoding of v.
+func (JSONCodec) Marshal(v interface{}) ([]byte, error) { return json.Marshal(v) }
+
+// Unmarshal decodes the JSON data into v.
+func (JSONCodec) Unmarshal(data []byte, v interface{}) error { return json.Unmarshal(data, v) }
+
+// ProtobufCodec marshals protobuf messages, the values must implement proto.Message.
+type ProtobufCodec struct{}
+
+// ContentType returns ContentTypeProtobuf.
+func (ProtobufCodec) ContentType() string { return ContentTypeProtobuf }
+
+// Marshal returns the protobuf wire encoding of v.
+func (ProtobufCodec) Marshal(v interface{}) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, errors.New("value is not a proto.Message")
+	}
+
+	return proto.Marshal(m)
+}
+
+// Unmarshal decodes the protobuf data into v.
+func (ProtobufCodec) Unmarshal(data []byte, v interface{}) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return errors.New("value is not a proto.Message")
+	}
+
+	return proto.Unmarshal(data, m)
+}
+
+// GzipEncoding compresses payloads with gzip.
+type GzipEncoding struct{}
+
+// Name returns ContentEncodingGzip.
+func (GzipEncoding) Name() string { return ContentEncodingGzip }
+
+// Encode compresses the data.
+func (GzipEncoding) Encode(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	w := gzip.NewWriter(&buf)
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Decode decompresses the data.
+func (GzipEncoding) Decode(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
@@ -0,0 +1,118 @@
+//nolint:all
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+
+	natsTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+type codecTestPayload struct {
+	Name string `json:"name"`
+}
+
+func TestEncodeDecodePayload(t *testing.T) {
+	testcases := []struct {
+		name            string
+		contentType     string
+		contentEncoding string
+		in              interface{}
+		out             func() interface{}
+		err             error
+	}{
+		{"json", ContentTypeJSON, "", &codecTestPayload{Name: "foo"}, func() interface{} { return &codecTestPayload{} }, nil},
+		{"default json", "", "", &codecTestPayload{Name: "foo"}, func() interface{} { return &codecTestPayload{} }, nil},
+		{"gzip json", ContentTypeJSON, ContentEncodingGzip, &codecTestPayload{Name: "foo"}, func() interface{} { return &codecTestPayload{} }, nil},
+		{"protobuf", ContentTypeProtobuf, ContentEncodingGzip, wrapperspb.String("foo"), func() interface{} { return &wrapperspb.StringValue{} }, nil},
+		{"protobuf non proto value", ContentTypeProtobuf, "", &codecTestPayload{Name: "foo"}, nil, ErrPayloadCodec},
+		{"unknown content type", "application/xml", "", &codecTestPayload{}, nil, ErrUnknownContentType},
+		{"unknown content encoding", ContentTypeJSON, "br", &codecTestPayload{}, nil, ErrUnknownContentEncoding},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := EncodePayload(tc.in, tc.contentType, tc.contentEncoding)
+			if tc.err != nil {
+				assert.ErrorIs(t, err, tc.err)
+				return
+			}
+
+			require.NoError(t, err)
+
+			out := tc.out()
+			require.NoError(t, DecodePayload(data, tc.contentType, tc.contentEncoding, out))
+
+			if m, ok := tc.in.(*wrapperspb.StringValue); ok {
+				assert.Equal(t, m.GetValue(), out.(*wrapperspb.StringValue).GetValue())
+				return
+			}
+
+			assert.Equal(t, tc.in, out)
+		})
+	}
+}
+
+func TestPublishEncodedDecodeInto(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName: "TestPublishEncodedDecodeInto",
+		Stream: &NatsStreamOptions{
+			Name:      "test_stream",
+			Subjects:  []string{"pre.test"},
+			Retention: "limits",
+		},
+		Consumer: &NatsConsumerOptions{
+			Name:              "test_consumer",
+			Pull:              true,
+			SubscribeSubjects: []string{"pre.test"},
+			FilterSubject:     "pre.test",
+		},
+		PublisherSubjectPrefix: "pre",
+	}
+	require.NoError(t, njs.addStream())
+	require.NoError(t, njs.addConsumer())
+
+	_, err := njs.PublishEncoded(context.TODO(), "test", &codecTestPayload{Name: "foo"}, WithContentEncoding(ContentEncodingGzip))
+	require.NoError(t, err)
+
+	_, err = njs.PublishWithOptions(context.TODO(), "test", []byte(`{"name":"bar"}`))
+	require.NoError(t, err)
+
+	require.NoError(t, njs.Publish(context.TODO(), "test", []byte(`{"name":"baz"}`)))
+
+	sub, err := njs.jsctx.PullSubscribe("pre.test", "test_consumer", nats.Bind("test_stream", "test_consumer"))
+	require.NoError(t, err)
+
+	msgs, err := sub.Fetch(3)
+	require.NoError(t, err)
+	require.Len(t, msgs, 3)
+
+	assert.Equal(t, ContentTypeJSON, msgs[0].Header.Get(HeaderContentType))
+	assert.Equal(t, ContentEncodingGzip, msgs[0].Header.Get(HeaderContentEncoding))
+
+	var got codecTestPayload
+	require.NoError(t, njs.newMsg(msgs[0]).DecodeInto(&got))
+	assert.Equal(t, "foo", got.Name)
+
+	// messages without headers are decoded as JSON
+	require.NoError(t, njs.newMsg(msgs[1]).DecodeInto(&got))
+	assert.Equal(t, "bar", got.Name)
+
+	// Publish sets the JSON default
+	assert.Equal(t, ContentTypeJSON, msgs[2].Header.Get(HeaderContentType))
+
+	require.NoError(t, njs.newMsg(msgs[2]).DecodeInto(&got))
+	assert.Equal(t, "baz", got.Name)
+}
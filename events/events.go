@@ -3,6 +3,7 @@ package events
 
 import (
 	"context"
+	"time"
 )
 
 type (
@@ -42,15 +43,43 @@ type Stream interface {
 	Publish(ctx context.Context, subject string, msg []byte) error
 
 	// Subscribe subscribes to one or more subjects on the stream returning a message channel for subscribers to read from.
+	//
+	// The channel is closed when the stream is closed, subscribers ranging over it return then.
 	Subscribe(ctx context.Context) (MsgCh, error)
 
 	// PullMsg pulls upto batch count of messages from the stream through the pull based subscription.
+	//
+	// Fetching honors the context, the context error is returned once it's cancelled or its deadline passed.
+	// Once the stream is closed an error wrapping ErrNatsClosed is returned, ErrNatsDraining when it was drained.
 	PullMsg(ctx context.Context, batch int) ([]Message, error)
 
-	// Closes the connection to the stream, along with unsubscribing any subscriptions.
+	// Closes the connection to the stream, along with unsubscribing any subscriptions
+	// and closing the channel returned by Subscribe.
 	Close() error
 }
 
+// ConsumptionPauser is implemented by streams able to stop handing out messages
+// without dropping their subscriptions and consumers, e.g. during maintenance windows.
+//
+// Callers type assert the Stream to check for the capability.
+type ConsumptionPauser interface {
+	// PauseConsumption stops handing out messages until ResumeConsumption is called.
+	PauseConsumption() error
+
+	// ResumeConsumption resumes handing out messages.
+	ResumeConsumption() error
+}
+
+// SubjectSubscriber is implemented by streams able to create consumers filtered on a subject at
+// runtime, e.g. for workloads added dynamically, see NatsJetstream.SubscribeSubject.
+//
+// Callers type assert the Stream to check for the capability.
+type SubjectSubscriber interface {
+	// SubscribeSubject creates or binds a durable consumer filtered on the subject, returning a
+	// dedicated channel closed once the context is done or the stream is closed.
+	SubscribeSubject(ctx context.Context, subject string, opts SubjectConsumerOptions) (MsgCh, error)
+}
+
 // MsgCh is a channel over which messages arrive when subscribed.
 type MsgCh chan Message
 
@@ -64,10 +93,28 @@ type Message interface {
 	// Nak the message as not processed on the stream.
 	Nak() error
 
+	// AckWithContext acks the message and waits for the stream to confirm it until the context is done,
+	// or DefaultAckTimeout when it has no deadline. An error wrapping ErrAckTimeout is returned when it
+	// wasn't confirmed in time, the message may then be redelivered.
+	AckWithContext(ctx context.Context) error
+
+	// NakWithContext naks the message and waits for the stream to confirm it until the context is done,
+	// or DefaultAckTimeout when it has no deadline. An error wrapping ErrAckTimeout is returned when it
+	// wasn't confirmed in time, the message is then redelivered once its AckWait elapsed.
+	NakWithContext(ctx context.Context) error
+
 	// Term signals to the broker that the message processing has failed and the message
 	// must not be redelivered.
 	Term() error
 
+	// NakWithReason records why the message failed and Naks it, the redelivery is delayed when
+	// delay is positive. The reason is available to the next delivery, see FailureReason.
+	NakWithReason(reason string, delay time.Duration) error
+
+	// TermWithReason records why the message failed and terminates it, the reason is kept
+	// for the dead letter consumers.
+	TermWithReason(reason string) error
+
 	// InProgress resets the redelivery timer for the message on the stream
 	// to indicate the message is being worked on.
 	InProgress() error
@@ -80,9 +127,50 @@ type Message interface {
 
 	// ExtractOtelTraceContext returns a context populated with the parent trace if any.
 	ExtractOtelTraceContext(ctx context.Context) context.Context
+
+	// Metadata returns the stream metadata of the message, handlers may use it for ordering and idempotency.
+	//
+	// Brokers that don't keep such metadata return a zero MessageMetadata and a nil error.
+	Metadata() (MessageMetadata, error)
+
+	// DecodeInto unmarshals the message data into v with the codec and encoding named
+	// by the message Content-Type and Content-Encoding headers, JSON when no content type is set.
+	DecodeInto(v interface{}) error
+
+	// IsTombstone reports whether the message is a tombstone announcing the deletion of a resource,
+	// see ParseTombstone.
+	IsTombstone() bool
 }
 
-// NewStream returns a Stream implementation.
+// MessageMetadata holds the metadata the stream broker keeps for a message.
+type MessageMetadata struct {
+	// StreamSequence is the sequence of the message in the stream.
+	StreamSequence uint64
+
+	// ConsumerSequence is the sequence of the message delivery to the consumer.
+	ConsumerSequence uint64
+
+	// NumDelivered is the number of times the message was delivered, starting at 1.
+	NumDelivered uint64
+
+	// NumPending is the number of messages left for the consumer to process.
+	NumPending uint64
+
+	// Stream is the name of the stream the message was stored in.
+	Stream string
+
+	// Consumer is the name of the consumer the message was delivered to.
+	Consumer string
+
+	// Timestamp is when the message was published to the stream.
+	Timestamp time.Time
+}
+
+// NewStream returns a Stream implementation, a LogBroker when the NatsOptions enable the DevLogBroker.
 func NewStream(parameters StreamParameters) (Stream, error) {
+	if opts, ok := parameters.(NatsOptions); ok && opts.DevLogBroker {
+		return newLogBrokerFromOptions(opts), nil
+	}
+
 	return NewNatsBroker(parameters)
 }
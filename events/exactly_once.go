@@ -0,0 +1,277 @@
+package events
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DefaultExactlyOnceDuplicateWindow is the stream DuplicateWindow set by WithExactlyOnce when none is configured,
+	// it is the NATS server default.
+	DefaultExactlyOnceDuplicateWindow = 2 * time.Minute
+
+	// DefaultIdempotencyTTL is how long the in memory IdempotencyLedger of WithExactlyOnce remembers
+	// the processed messages.
+	DefaultIdempotencyTTL = time.Hour
+)
+
+// ErrIdempotencyLedger is returned when the processed messages couldn't be looked up or recorded.
+var ErrIdempotencyLedger = errors.New("error in idempotency ledger")
+
+// BrokerOption configures a NatsJetstream on construction, see NewNatsBroker.
+type BrokerOption func(*NatsJetstream)
+
+// WithExactlyOnce combines the stream deduplication and the IdempotencyLedger into an exactly-once
+// consumption mode:
+//
+//   - messages published without WithMsgID get an ID derived from their subject and data, so
+//     retried publishes within the stream DuplicateWindow are stored once,
+//   - the stream DuplicateWindow defaults to DefaultExactlyOnceDuplicateWindow,
+//   - the consumer acks with AckSync, once Ack returns the message won't be redelivered,
+//   - handlers wrapped with IdempotentHandler skip the messages recorded as processed in the ledger,
+//     NewMemoryIdempotencyLedger unless WithIdempotencyLedger is set.
+//
+// Identical payloads published on the same subject within the DuplicateWindow are stored once,
+// publishers of such events set their own WithMsgID.
+func WithExactlyOnce() BrokerOption {
+	return func(n *NatsJetstream) {
+		n.exactlyOnce = true
+	}
+}
+
+// WithIdempotencyLedger sets the ledger of the messages processed by the handlers wrapped with
+// IdempotentHandler, e.g. NewKVIdempotencyLedger to share it between the consumer instances.
+func WithIdempotencyLedger(ledger IdempotencyLedger) BrokerOption {
+	return func(n *NatsJetstream) {
+		n.ledger = ledger
+	}
+}
+
+// applyExactlyOnce sets the stream and consumer parameters of the exactly-once mode.
+func (n *NatsJetstream) applyExactlyOnce() {
+	if !n.exactlyOnce || n.parameters == nil {
+		return
+	}
+
+	if n.parameters.Stream != nil && n.parameters.Stream.DuplicateWindow == 0 {
+		n.parameters.Stream.DuplicateWindow = DefaultExactlyOnceDuplicateWindow
+	}
+
+	for i := range n.streams {
+		if n.streams[i].DuplicateWindow == 0 {
+			n.streams[i].DuplicateWindow = DefaultExactlyOnceDuplicateWindow
+		}
+	}
+
+	if n.parameters.Consumer != nil {
+		n.parameters.Consumer.AckSync = true
+	}
+}
+
+// exactlyOnceMsgID returns the ID of a message published without WithMsgID in the exactly-once mode,
+// empty otherwise.
+func (n *NatsJetstream) exactlyOnceMsgID(subject string, data []byte) string {
+	if !n.exactlyOnce {
+		return ""
+	}
+
+	sum := sha256.New()
+	sum.Write([]byte(subject))
+	sum.Write([]byte{0})
+	sum.Write(data)
+
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// IdempotencyLedger records the messages processed, by ID, so their redeliveries are skipped.
+type IdempotencyLedger interface {
+	// Processed returns true when the message was recorded as processed.
+	Processed(ctx context.Context, id string) (bool, error)
+
+	// MarkProcessed records the message as processed.
+	MarkProcessed(ctx context.Context, id string) error
+}
+
+// memoryIdempotencyLedger records the processed messages within the process.
+type memoryIdempotencyLedger struct {
+	mu        sync.Mutex
+	ttl       time.Duration
+	processed map[string]time.Time
+	now       func() time.Time
+}
+
+// NewMemoryIdempotencyLedger returns an IdempotencyLedger remembering the processed messages in
+// memory for the ttl, messages redelivered to other consumer instances aren't skipped.
+func NewMemoryIdempotencyLedger(ttl time.Duration) IdempotencyLedger {
+	if ttl <= 0 {
+		ttl = DefaultIdempotencyTTL
+	}
+
+	return &memoryIdempotencyLedger{ttl: ttl, processed: map[string]time.Time{}, now: time.Now}
+}
+
+func (l *memoryIdempotencyLedger) Processed(_ context.Context, id string) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	at, ok := l.processed[id]
+
+	return ok && l.now().Sub(at) < l.ttl, nil
+}
+
+func (l *memoryIdempotencyLedger) MarkProcessed(_ context.Context, id string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+
+	// expired entries are dropped as new ones are recorded
+	for key, at := range l.processed {
+		if now.Sub(at) >= l.ttl {
+			delete(l.processed, key)
+		}
+	}
+
+	l.processed[id] = now
+
+	return nil
+}
+
+// kvIdempotencyLedger records the processed messages in a JetStream KV bucket shared by the consumer instances.
+type kvIdempotencyLedger struct {
+	kv nats.KeyValue
+}
+
+// NewKVIdempotencyLedger returns an IdempotencyLedger recording the processed messages in the KV bucket,
+// so redeliveries to any of the consumer instances are skipped. The bucket is expected to have a TTL
+// to drop the records of messages which can't be redelivered anymore.
+func NewKVIdempotencyLedger(kv nats.KeyValue) IdempotencyLedger {
+	return &kvIdempotencyLedger{kv: kv}
+}
+
+func (l *kvIdempotencyLedger) Processed(_ context.Context, id string) (bool, error) {
+	_, err := l.kv.Get(processedKey(id))
+
+	switch {
+	case errors.Is(err, nats.ErrKeyNotFound):
+		return false, nil
+	case err != nil:
+		return false, errors.Wrap(ErrIdempotencyLedger, err.Error())
+	default:
+		return true, nil
+	}
+}
+
+func (l *kvIdempotencyLedger) MarkProcessed(_ context.Context, id string) error {
+	if _, err := l.kv.Put(processedKey(id), []byte(time.Now().UTC().Format(time.RFC3339Nano))); err != nil {
+		return errors.Wrap(ErrIdempotencyLedger, err.Error())
+	}
+
+	return nil
+}
+
+// processedKey encodes the message ID into a valid KV key.
+func processedKey(id string) string {
+	return "processed." + base64.RawURLEncoding.EncodeToString([]byte(id))
+}
+
+// idempotencyLedger returns the ledger set with WithIdempotencyLedger, an in memory one by default.
+func (n *NatsJetstream) idempotencyLedger() IdempotencyLedger {
+	n.ledgerMu.Lock()
+	defer n.ledgerMu.Unlock()
+
+	if n.ledger == nil {
+		n.ledger = NewMemoryIdempotencyLedger(DefaultIdempotencyTTL)
+	}
+
+	return n.ledger
+}
+
+// IdempotentHandler wraps the handler so the messages it acked are recorded in the IdempotencyLedger,
+// their redeliveries are acked and skipped without being handed to the handler.
+//
+// Messages are recorded by stream and sequence, redeliveries share them while the stream deduplicates
+// the messages published more than once. The message is recorded before it is acked, a message
+// whose ack failed is redelivered and skipped.
+//
+//	stream, err := events.NewNatsBroker(params, events.WithExactlyOnce())
+//	...
+//	dispatcher, err := events.NewKeyedDispatcher(8, stream.IdempotentHandler(handler))
+func (n *NatsJetstream) IdempotentHandler(handler MessageHandler) MessageHandler {
+	ledger := n.idempotencyLedger()
+
+	return func(ctx context.Context, msg Message) {
+		id := processedID(msg)
+		if id == "" {
+			handler(ctx, msg)
+			return
+		}
+
+		processed, err := ledger.Processed(ctx, id)
+		if err != nil {
+			// handled again rather than lost, the handler is expected to be retried anyway
+			log.Printf("idempotency ledger lookup of message id=%s failed: %s\n", id, err)
+		}
+
+		if processed {
+			atomic.AddUint64(&n.duplicateMessages, 1)
+
+			_ = msg.Ack()
+
+			return
+		}
+
+		handler(ctx, &idempotentMsg{Message: msg, ctx: ctx, id: id, ledger: ledger})
+	}
+}
+
+// DuplicateMessages returns the number of redelivered messages skipped by the IdempotentHandler.
+func (n *NatsJetstream) DuplicateMessages() uint64 {
+	return atomic.LoadUint64(&n.duplicateMessages)
+}
+
+// processedID returns the ID the message is recorded by in the ledger, its stream and sequence or
+// its nats.MsgIdHdr when it has no stream metadata.
+func processedID(msg Message) string {
+	if md, err := msg.Metadata(); err == nil && md.Stream != "" {
+		return fmt.Sprintf("%s.%d", md.Stream, md.StreamSequence)
+	}
+
+	return messageID(msg)
+}
+
+// idempotentMsg records the message in the ledger when it is acked.
+type idempotentMsg struct {
+	Message
+
+	ctx    context.Context
+	id     string
+	ledger IdempotencyLedger
+}
+
+func (m *idempotentMsg) Ack() error {
+	if err := m.ledger.MarkProcessed(m.ctx, m.id); err != nil {
+		// acked anyway, the message was processed and its redelivery would process it again
+		log.Printf("message id=%s not recorded in the idempotency ledger: %s\n", m.id, err)
+	}
+
+	return m.Message.Ack()
+}
+
+func (m *idempotentMsg) AckWithContext(ctx context.Context) error {
+	if err := m.ledger.MarkProcessed(ctx, m.id); err != nil {
+		log.Printf("message id=%s not recorded in the idempotency ledger: %s\n", m.id, err)
+	}
+
+	return m.Message.AckWithContext(ctx)
+}
@@ -0,0 +1,205 @@
+//nolint:all
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	natsTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+func TestNewNatsBrokerWithExactlyOnce(t *testing.T) {
+	params := NatsOptions{
+		URL:                    "nats://localhost:4222",
+		AppName:                "test",
+		CredsFile:              "creds",
+		ConnectTimeout:         time.Second,
+		PublisherSubjectPrefix: "pre",
+		Stream:                 &NatsStreamOptions{Name: "test_stream", Subjects: []string{"pre.>"}, Retention: "limits"},
+		Consumer:               &NatsConsumerOptions{Name: "test_consumer", Pull: true},
+	}
+
+	njs, err := NewNatsBroker(params, WithExactlyOnce())
+	require.NoError(t, err)
+
+	assert.Equal(t, DefaultExactlyOnceDuplicateWindow, njs.parameters.Stream.DuplicateWindow)
+	assert.True(t, njs.parameters.Consumer.AckSync)
+
+	// a configured DuplicateWindow is kept
+	params.Stream = &NatsStreamOptions{Name: "test_stream", Subjects: []string{"pre.>"}, Retention: "limits", DuplicateWindow: time.Hour}
+	params.Consumer = &NatsConsumerOptions{Name: "test_consumer", Pull: true}
+
+	njs, err = NewNatsBroker(params, WithExactlyOnce())
+	require.NoError(t, err)
+
+	assert.Equal(t, time.Hour, njs.parameters.Stream.DuplicateWindow)
+
+	// without the option nothing changes
+	params.Stream = &NatsStreamOptions{Name: "test_stream", Subjects: []string{"pre.>"}, Retention: "limits"}
+	params.Consumer = &NatsConsumerOptions{Name: "test_consumer", Pull: true}
+
+	njs, err = NewNatsBroker(params)
+	require.NoError(t, err)
+
+	assert.Zero(t, njs.parameters.Stream.DuplicateWindow)
+	assert.False(t, njs.parameters.Consumer.AckSync)
+}
+
+func TestExactlyOnce(t *testing.T) {
+	srv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, srv)
+
+	nc, js := natsTest.JetStreamContext(t, srv)
+	njs := NewJetstreamFromConn(nc)
+	defer njs.Close()
+
+	ledger := NewMemoryIdempotencyLedger(time.Hour)
+
+	WithExactlyOnce()(njs)
+	WithIdempotencyLedger(ledger)(njs)
+
+	njs.parameters = &NatsOptions{
+		AppName: "TestExactlyOnce",
+		Stream: &NatsStreamOptions{
+			Name:      "test_stream",
+			Subjects:  []string{"pre.>"},
+			Retention: "limits",
+		},
+		Consumer: &NatsConsumerOptions{
+			Name:              "test_consumer",
+			Pull:              true,
+			SubscribeSubjects: []string{"pre.test"},
+			FilterSubject:     "pre.test",
+		},
+		PublisherSubjectPrefix: "pre",
+	}
+	njs.applyExactlyOnce()
+
+	require.NoError(t, njs.addStream())
+	require.NoError(t, njs.addConsumer())
+
+	// retried publishes are stored once
+	require.NoError(t, njs.Publish(context.TODO(), "test", []byte("1")))
+	require.NoError(t, njs.Publish(context.TODO(), "test", []byte("1")))
+
+	seq, err := njs.PublishWithOptions(context.TODO(), "test", []byte("1"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), seq)
+
+	// publishers setting their own ID publish identical payloads again
+	seq, err = njs.PublishWithOptions(context.TODO(), "test", []byte("1"), WithMsgID("again"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), seq)
+
+	info, err := js.StreamInfo("test_stream")
+	require.NoError(t, err)
+	assert.Equal(t, DefaultExactlyOnceDuplicateWindow, info.Config.Duplicates)
+	assert.Equal(t, uint64(2), info.State.Msgs)
+
+	_, err = njs.Subscribe(context.TODO())
+	require.NoError(t, err)
+
+	msgs, err := njs.PullMsg(context.TODO(), 1)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+
+	var handled int
+
+	handler := njs.IdempotentHandler(func(_ context.Context, msg Message) {
+		handled++
+
+		// the handlers still reach the NATS message
+		_, err := AsNatsMsg(msg)
+		assert.NoError(t, err)
+
+		assert.NoError(t, msg.Ack())
+	})
+
+	handler(context.TODO(), msgs[0])
+
+	processed, err := ledger.Processed(context.TODO(), "test_stream.1")
+	require.NoError(t, err)
+	assert.True(t, processed)
+
+	// a redelivery of the message is skipped
+	handler(context.TODO(), msgs[0])
+
+	assert.Equal(t, 1, handled)
+	assert.Equal(t, uint64(1), njs.DuplicateMessages())
+}
+
+func TestIdempotentHandlerUnacked(t *testing.T) {
+	njs := &NatsJetstream{}
+
+	var handled int
+
+	handler := njs.IdempotentHandler(func(_ context.Context, msg Message) {
+		handled++
+
+		// the message isn't recorded unless it is acked
+		_ = msg.Nak()
+	})
+
+	msg := &natsMsg{msg: &nats.Msg{Subject: "test", Header: nats.Header{nats.MsgIdHdr: []string{"1"}}}}
+
+	handler(context.TODO(), msg)
+	handler(context.TODO(), msg)
+
+	assert.Equal(t, 2, handled)
+	assert.Zero(t, njs.DuplicateMessages())
+}
+
+func TestMemoryIdempotencyLedger(t *testing.T) {
+	now := time.Now()
+
+	ledger := NewMemoryIdempotencyLedger(time.Minute).(*memoryIdempotencyLedger)
+	ledger.now = func() time.Time { return now }
+
+	processed, err := ledger.Processed(context.TODO(), "1")
+	require.NoError(t, err)
+	assert.False(t, processed)
+
+	require.NoError(t, ledger.MarkProcessed(context.TODO(), "1"))
+
+	processed, err = ledger.Processed(context.TODO(), "1")
+	require.NoError(t, err)
+	assert.True(t, processed)
+
+	// the record expires after the ttl and is dropped with the next one
+	now = now.Add(time.Minute)
+
+	processed, err = ledger.Processed(context.TODO(), "1")
+	require.NoError(t, err)
+	assert.False(t, processed)
+
+	require.NoError(t, ledger.MarkProcessed(context.TODO(), "2"))
+	assert.Len(t, ledger.processed, 1)
+}
+
+func TestKVIdempotencyLedger(t *testing.T) {
+	srv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, srv)
+
+	_, js := natsTest.JetStreamContext(t, srv)
+
+	kv, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: "processed", TTL: time.Hour})
+	require.NoError(t, err)
+
+	ledger := NewKVIdempotencyLedger(kv)
+
+	processed, err := ledger.Processed(context.TODO(), "stream.1")
+	require.NoError(t, err)
+	assert.False(t, processed)
+
+	require.NoError(t, ledger.MarkProcessed(context.TODO(), "stream.1"))
+
+	// the ledger is shared by the instances using the bucket
+	processed, err = NewKVIdempotencyLedger(kv).Processed(context.TODO(), "stream.1")
+	require.NoError(t, err)
+	assert.True(t, processed)
+}
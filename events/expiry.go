@@ -0,0 +1,64 @@
+package events
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// MessageExpiresHeader holds the time, in RFC 3339 format, after which a message published WithTTL
+// is stale. Subscribers skip stale messages, see NatsJetstream.ExpiredMessages.
+const MessageExpiresHeader = "Hollow-Expires-At"
+
+// WithTTL sets how long the message is relevant for, e.g. presence pings. Messages handed out after
+// their TTL are acked and skipped by the subscribers instead, they stay stored on the stream.
+// Short-lived events are best published on a dedicated stream with a MaxAge so they're dropped from it too.
+func WithTTL(ttl time.Duration) PublishOption {
+	return func(o *publishOptions) {
+		o.ttl = ttl
+	}
+}
+
+// MessageExpiresAt returns the time after which the message is stale, false when it was published without a TTL.
+func MessageExpiresAt(msg Message) (time.Time, bool) {
+	nm, err := AsNatsMsg(msg)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return expiresAt(nm.Header)
+}
+
+func expiresAt(h nats.Header) (time.Time, bool) {
+	value := h.Get(MessageExpiresHeader)
+	if value == "" {
+		return time.Time{}, false
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
+
+// ExpiredMessages returns the number of messages skipped because their TTL passed before they were handed out.
+func (n *NatsJetstream) ExpiredMessages() uint64 {
+	return atomic.LoadUint64(&n.expiredMessages)
+}
+
+// skipExpired acks the message and returns true when its TTL passed.
+func (n *NatsJetstream) skipExpired(msg *nats.Msg) bool {
+	expires, ok := expiresAt(msg.Header)
+	if !ok || n.getClock().Now().Before(expires) {
+		return false
+	}
+
+	atomic.AddUint64(&n.expiredMessages, 1)
+
+	n.ackSkipped(msg)
+
+	return true
+}
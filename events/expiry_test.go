@@ -0,0 +1,80 @@
+//nolint:all
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	natsTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+func TestPublishWithTTL(t *testing.T) {
+	srv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, srv)
+
+	nc, js := natsTest.JetStreamContext(t, srv)
+	njs := NewJetstreamFromConn(nc)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName: "TestPublishWithTTL",
+		Stream: &NatsStreamOptions{
+			Name:      "presence",
+			Subjects:  []string{"pre.presence"},
+			Retention: "limits",
+			MaxAge:    time.Hour,
+		},
+		Consumer: &NatsConsumerOptions{
+			Name:              "presence_consumer",
+			Pull:              true,
+			SubscribeSubjects: []string{"pre.presence"},
+			FilterSubject:     "pre.presence",
+		},
+		PublisherSubjectPrefix: "pre",
+	}
+	require.NoError(t, njs.addStream())
+	require.NoError(t, njs.addConsumer())
+
+	info, err := js.StreamInfo("presence")
+	require.NoError(t, err)
+	assert.Equal(t, time.Hour, info.Config.MaxAge)
+
+	_, err = njs.Subscribe(context.TODO())
+	require.NoError(t, err)
+
+	_, err = njs.PublishWithOptions(context.TODO(), "presence", []byte("stale"), WithTTL(time.Millisecond))
+	require.NoError(t, err)
+
+	_, err = njs.PublishWithOptions(context.TODO(), "presence", []byte("fresh"), WithTTL(time.Hour))
+	require.NoError(t, err)
+
+	_, err = njs.PublishWithOptions(context.TODO(), "presence", []byte("no ttl"))
+	require.NoError(t, err)
+
+	time.Sleep(10 * time.Millisecond)
+
+	msgs, err := njs.PullMsg(context.TODO(), 3)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+
+	assert.Equal(t, []byte("fresh"), msgs[0].Data())
+	expires, ok := MessageExpiresAt(msgs[0])
+	assert.True(t, ok)
+	assert.WithinDuration(t, time.Now().Add(time.Hour), expires, time.Minute)
+
+	assert.Equal(t, []byte("no ttl"), msgs[1].Data())
+	_, ok = MessageExpiresAt(msgs[1])
+	assert.False(t, ok)
+
+	assert.Equal(t, uint64(1), njs.ExpiredMessages())
+
+	// the stale message was acked when skipped
+	require.Eventually(t, func() bool {
+		consumer, err := js.ConsumerInfo("presence", "presence_consumer")
+		return err == nil && consumer.NumAckPending == 2
+	}, time.Second, 10*time.Millisecond)
+}
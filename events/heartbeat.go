@@ -0,0 +1,267 @@
+package events
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// HeartbeatHeader holds the time, in RFC 3339 format, a heartbeat message was sent at.
+	HeartbeatHeader = "Hollow-Heartbeat"
+
+	// HeartbeatSourceHeader holds the AppName of the publisher of a heartbeat message.
+	HeartbeatSourceHeader = "Hollow-Heartbeat-Source"
+)
+
+// ErrHeartbeatConfig is returned when the heartbeat or heartbeat monitor options are invalid.
+var ErrHeartbeatConfig = errors.New("invalid heartbeat configuration")
+
+// HeartbeatOptions configures the heartbeats published by RunHeartbeats.
+type HeartbeatOptions struct {
+	// Subjects are the subject suffixes heartbeats are published on, the PublisherSubjectPrefix is prepended.
+	Subjects []string
+
+	// Interval is the time between heartbeats, a subject the publisher published a message on
+	// within the interval gets no heartbeat, the message proving the pipeline is alive already.
+	Interval time.Duration
+
+	// OnError is called when publishing a heartbeat failed, the heartbeats carry on.
+	OnError func(subjectSuffix string, err error)
+}
+
+func (o HeartbeatOptions) validate() error {
+	if len(o.Subjects) == 0 {
+		return errors.Wrap(ErrHeartbeatConfig, "one or more Subjects are required")
+	}
+
+	if o.Interval <= 0 {
+		return errors.Wrap(ErrHeartbeatConfig, "Interval must be positive")
+	}
+
+	return nil
+}
+
+// withHeartbeat publishes the message as a heartbeat sent at the time.
+func withHeartbeat(source string, sentAt time.Time) PublishOption {
+	return func(o *publishOptions) {
+		o.heartbeat = &heartbeat{source: source, sentAt: sentAt}
+	}
+}
+
+type heartbeat struct {
+	source string
+	sentAt time.Time
+}
+
+// RunHeartbeats publishes a heartbeat, an empty message with the HeartbeatHeader, on each of the
+// subjects at every interval until the context is done, so consumers can tell a quiet pipeline from
+// a broken one with a HeartbeatMonitor. Subjects are skipped while the broker publishes other
+// messages on them.
+//
+// Heartbeats are published to the stream like any other message, consumers skip them with IsHeartbeat.
+func (n *NatsJetstream) RunHeartbeats(ctx context.Context, opts HeartbeatOptions) error {
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	clock := n.getClock()
+
+	for {
+		for _, suffix := range opts.Subjects {
+			if n.publishedWithin(n.fullSubject(suffix), opts.Interval) {
+				continue
+			}
+
+			_, err := n.PublishWithOptions(ctx, suffix, nil, withHeartbeat(n.ensureParameters().AppName, clock.Now()))
+			if err != nil && opts.OnError != nil && ctx.Err() == nil {
+				opts.OnError(suffix, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-clock.After(opts.Interval):
+		}
+	}
+}
+
+// recordPublished records the time a message other than a heartbeat was published on the subject.
+func (n *NatsJetstream) recordPublished(subject string) {
+	n.lastPublished.Store(subject, n.getClock().Now())
+}
+
+// publishedWithin returns true when a message other than a heartbeat was published on the subject within the interval.
+func (n *NatsJetstream) publishedWithin(subject string, interval time.Duration) bool {
+	last, ok := n.lastPublished.Load(subject)
+
+	return ok && n.getClock().Now().Sub(last.(time.Time)) < interval
+}
+
+// IsHeartbeat returns true when the message is a heartbeat published by RunHeartbeats.
+func IsHeartbeat(msg Message) bool {
+	nm, err := AsNatsMsg(msg)
+
+	return err == nil && nm.Header != nil && nm.Header.Get(HeartbeatHeader) != ""
+}
+
+// HeartbeatMonitorOptions configures a HeartbeatMonitor.
+type HeartbeatMonitorOptions struct {
+	// Subjects are the full subjects watched, they are stale until a message is received on them
+	// within StaleAfter of the monitor being created.
+	Subjects []string
+
+	// StaleAfter is how long a subject may go without messages, heartbeats included, before it is stale.
+	// It is expected to be a few times the heartbeat Interval.
+	StaleAfter time.Duration
+
+	// OnStale is called once when a subject turns stale, with the time its last message was received.
+	OnStale func(subject string, lastSeen time.Time)
+
+	// OnRecovered is called when a message is received on a stale subject.
+	OnRecovered func(subject string)
+}
+
+// HeartbeatMonitor detects the subjects on which messages, heartbeats included, stopped arriving.
+// Consumers pass it the messages they receive before handling them:
+//
+//	if monitor.Observe(msg) {
+//		// heartbeats carry nothing to handle
+//		_ = msg.Ack()
+//		continue
+//	}
+type HeartbeatMonitor struct {
+	opts  HeartbeatMonitorOptions
+	clock Clock
+
+	mu       sync.Mutex
+	lastSeen map[string]time.Time
+	stale    map[string]bool
+}
+
+// NewHeartbeatMonitor returns a HeartbeatMonitor watching the subjects, Run checks them for staleness.
+func NewHeartbeatMonitor(opts HeartbeatMonitorOptions) (*HeartbeatMonitor, error) {
+	if len(opts.Subjects) == 0 {
+		return nil, errors.Wrap(ErrHeartbeatConfig, "one or more Subjects are required")
+	}
+
+	if opts.StaleAfter <= 0 {
+		return nil, errors.Wrap(ErrHeartbeatConfig, "StaleAfter must be positive")
+	}
+
+	m := &HeartbeatMonitor{
+		opts:     opts,
+		clock:    realClock{},
+		lastSeen: map[string]time.Time{},
+		stale:    map[string]bool{},
+	}
+
+	now := m.clock.Now()
+	for _, subject := range opts.Subjects {
+		m.lastSeen[subject] = now
+	}
+
+	return m, nil
+}
+
+// Observe records a message was received on its subject and returns true when it is a heartbeat.
+// Messages on subjects which aren't watched are ignored.
+func (m *HeartbeatMonitor) Observe(msg Message) bool {
+	subject := msg.Subject()
+
+	m.mu.Lock()
+
+	var recovered bool
+
+	if _, watched := m.lastSeen[subject]; watched {
+		m.lastSeen[subject] = m.clock.Now()
+
+		recovered = m.stale[subject]
+		delete(m.stale, subject)
+	}
+
+	m.mu.Unlock()
+
+	if recovered && m.opts.OnRecovered != nil {
+		m.opts.OnRecovered(subject)
+	}
+
+	return IsHeartbeat(msg)
+}
+
+// Run checks the subjects for staleness every quarter of StaleAfter until the context is done.
+func (m *HeartbeatMonitor) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-m.clock.After(m.opts.StaleAfter / 4):
+			m.check()
+		}
+	}
+}
+
+// check marks the subjects without messages for StaleAfter as stale, calling OnStale for those
+// which just turned stale.
+func (m *HeartbeatMonitor) check() {
+	type staleSubject struct {
+		subject  string
+		lastSeen time.Time
+	}
+
+	var turned []staleSubject
+
+	m.mu.Lock()
+
+	now := m.clock.Now()
+
+	for subject, lastSeen := range m.lastSeen {
+		if m.stale[subject] || now.Sub(lastSeen) < m.opts.StaleAfter {
+			continue
+		}
+
+		m.stale[subject] = true
+
+		turned = append(turned, staleSubject{subject, lastSeen})
+	}
+
+	m.mu.Unlock()
+
+	if m.opts.OnStale == nil {
+		return
+	}
+
+	for _, s := range turned {
+		m.opts.OnStale(s.subject, s.lastSeen)
+	}
+}
+
+// StaleSubjects returns the subjects currently stale, sorted, to be exported as a metric.
+func (m *HeartbeatMonitor) StaleSubjects() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	subjects := make([]string, 0, len(m.stale))
+	for subject := range m.stale {
+		subjects = append(subjects, subject)
+	}
+
+	sort.Strings(subjects)
+
+	return subjects
+}
+
+// LastSeen returns the time the last message was received on the subject, or the monitor was
+// created if none was, false when the subject isn't watched.
+func (m *HeartbeatMonitor) LastSeen(subject string) (time.Time, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	t, ok := m.lastSeen[subject]
+
+	return t, ok
+}
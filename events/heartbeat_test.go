@@ -0,0 +1,119 @@
+//nolint:all
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	natsTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+func TestRunHeartbeats(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, jsCtx := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName:                "heartbeats",
+		PublisherSubjectPrefix: "pre",
+		Stream: &NatsStreamOptions{
+			Name:      "test_stream",
+			Subjects:  []string{"pre.>"},
+			Retention: "limits",
+		},
+	}
+
+	require.NoError(t, njs.addStream())
+
+	clock := &fakeClock{now: time.Now(), fire: make(chan time.Time), requested: make(chan time.Duration, 1)}
+	njs.SetClock(clock)
+
+	err := njs.RunHeartbeats(context.Background(), HeartbeatOptions{Subjects: []string{"busy"}})
+	assert.ErrorIs(t, err, ErrHeartbeatConfig)
+
+	// a subject with a message published within the interval gets no heartbeat
+	require.NoError(t, njs.Publish(context.Background(), "busy", []byte("event")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+
+	go func() {
+		done <- njs.RunHeartbeats(ctx, HeartbeatOptions{Subjects: []string{"busy", "idle"}, Interval: time.Minute})
+	}()
+
+	assert.Equal(t, time.Minute, <-clock.requested)
+
+	cancel()
+	require.NoError(t, <-done)
+
+	info, err := jsCtx.StreamInfo("test_stream")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), info.State.Msgs)
+
+	last, err := jsCtx.GetLastMsg("test_stream", "pre.busy")
+	require.NoError(t, err)
+	assert.Empty(t, last.Header.Get(HeartbeatHeader))
+
+	last, err = jsCtx.GetLastMsg("test_stream", "pre.idle")
+	require.NoError(t, err)
+	assert.Equal(t, clock.now.UTC().Format(time.RFC3339Nano), last.Header.Get(HeartbeatHeader))
+	assert.Equal(t, "heartbeats", last.Header.Get(HeartbeatSourceHeader))
+
+	heartbeat := &natsMsg{msg: &nats.Msg{Subject: last.Subject, Header: last.Header}}
+	assert.True(t, IsHeartbeat(heartbeat))
+	assert.False(t, IsHeartbeat(&natsMsg{msg: nats.NewMsg("pre.busy")}))
+}
+
+func TestHeartbeatMonitor(t *testing.T) {
+	_, err := NewHeartbeatMonitor(HeartbeatMonitorOptions{Subjects: []string{"pre.idle"}})
+	assert.ErrorIs(t, err, ErrHeartbeatConfig)
+
+	var stale, recovered []string
+
+	monitor, err := NewHeartbeatMonitor(HeartbeatMonitorOptions{
+		Subjects:    []string{"pre.busy", "pre.idle"},
+		StaleAfter:  time.Minute,
+		OnStale:     func(subject string, _ time.Time) { stale = append(stale, subject) },
+		OnRecovered: func(subject string) { recovered = append(recovered, subject) },
+	})
+	require.NoError(t, err)
+
+	clock := &fakeClock{now: time.Now()}
+	monitor.clock = clock
+
+	heartbeat := nats.NewMsg("pre.busy")
+	heartbeat.Header.Set(HeartbeatHeader, clock.now.Format(time.RFC3339Nano))
+
+	clock.now = clock.now.Add(30 * time.Second)
+	assert.True(t, monitor.Observe(&natsMsg{msg: heartbeat}))
+	assert.False(t, monitor.Observe(&natsMsg{msg: nats.NewMsg("pre.other")}))
+
+	clock.now = clock.now.Add(45 * time.Second)
+	monitor.check()
+
+	assert.Equal(t, []string{"pre.idle"}, stale)
+	assert.Equal(t, []string{"pre.idle"}, monitor.StaleSubjects())
+
+	// stale subjects are reported once
+	monitor.check()
+	assert.Equal(t, []string{"pre.idle"}, stale)
+
+	assert.False(t, monitor.Observe(&natsMsg{msg: nats.NewMsg("pre.idle")}))
+	assert.Equal(t, []string{"pre.idle"}, recovered)
+	assert.Empty(t, monitor.StaleSubjects())
+
+	lastSeen, ok := monitor.LastSeen("pre.idle")
+	assert.True(t, ok)
+	assert.Equal(t, clock.now, lastSeen)
+
+	_, ok = monitor.LastSeen("pre.other")
+	assert.False(t, ok)
+}
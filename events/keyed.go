@@ -0,0 +1,216 @@
+package events
+
+import (
+	"context"
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// MessageKeyHeader is the header holding the key of messages published WithMessageKey.
+	MessageKeyHeader = "Hollow-Message-Key"
+
+	// DefaultKeyedLaneBuffer is the number of messages queued on each lane of a KeyedDispatcher.
+	DefaultKeyedLaneBuffer = 16
+)
+
+// ErrKeyedDispatcher is returned when a KeyedDispatcher can't dispatch a message.
+var ErrKeyedDispatcher = errors.New("error in keyed dispatcher")
+
+// WithMessageKey sets the MessageKeyHeader of the message, a KeyedDispatcher processes
+// messages with the same key in the order they were received.
+func WithMessageKey(key string) PublishOption {
+	return func(o *publishOptions) {
+		o.messageKey = key
+	}
+}
+
+// MessageHandler processes a message handed out by a KeyedDispatcher, it is responsible
+// for acking the message.
+type MessageHandler func(ctx context.Context, msg Message)
+
+// KeyFunc returns the ordering key of the message, messages without a key may be processed in any order.
+type KeyFunc func(msg Message) string
+
+// KeyFromHeader returns a KeyFunc reading the key from the message header.
+func KeyFromHeader(header string) KeyFunc {
+	return func(msg Message) string {
+		nm, err := AsNatsMsg(msg)
+		if err != nil || nm.Header == nil {
+			return ""
+		}
+
+		return nm.Header.Get(header)
+	}
+}
+
+// KeyedDispatcherOption configures a KeyedDispatcher.
+type KeyedDispatcherOption func(*KeyedDispatcher)
+
+// WithKeyFunc sets how the key of messages is read, defaults to KeyFromHeader(MessageKeyHeader).
+func WithKeyFunc(fn KeyFunc) KeyedDispatcherOption {
+	return func(d *KeyedDispatcher) {
+		d.keyFn = fn
+	}
+}
+
+// WithLaneBuffer sets the number of messages queued on each lane before Dispatch blocks,
+// defaults to DefaultKeyedLaneBuffer.
+func WithLaneBuffer(size int) KeyedDispatcherOption {
+	return func(d *KeyedDispatcher) {
+		d.buffer = size
+	}
+}
+
+// KeyedDispatcher provides partition like ordering on the consumer side. Message keys are hashed
+// onto a fixed number of lanes each processing its messages serially, messages with the same key
+// are processed in order while messages with different keys are processed concurrently.
+//
+// Messages without a key are spread over the lanes.
+type KeyedDispatcher struct {
+	handler MessageHandler
+	keyFn   KeyFunc
+	buffer  int
+
+	lanes  []chan Message
+	depths []int64
+	next   uint32
+	wg     sync.WaitGroup
+	ctx    context.Context
+	once   sync.Once
+}
+
+// NewKeyedDispatcher returns a KeyedDispatcher handing messages to the handler over the given number of lanes.
+func NewKeyedDispatcher(lanes int, handler MessageHandler, opts ...KeyedDispatcherOption) (*KeyedDispatcher, error) {
+	if lanes < 1 {
+		return nil, errors.Wrap(ErrKeyedDispatcher, "at least one lane is required")
+	}
+
+	if handler == nil {
+		return nil, errors.Wrap(ErrKeyedDispatcher, "a message handler is required")
+	}
+
+	d := &KeyedDispatcher{
+		handler: handler,
+		keyFn:   KeyFromHeader(MessageKeyHeader),
+		buffer:  DefaultKeyedLaneBuffer,
+		lanes:   make([]chan Message, lanes),
+		depths:  make([]int64, lanes),
+	}
+
+	for _, opt := range opts {
+		opt(d)
+	}
+
+	if d.buffer < 0 {
+		return nil, errors.Wrap(ErrKeyedDispatcher, "lane buffer must not be negative")
+	}
+
+	for i := range d.lanes {
+		d.lanes[i] = make(chan Message, d.buffer)
+	}
+
+	return d, nil
+}
+
+// Run dispatches the messages received on the channel until it is closed or the context is done,
+// then waits for the lanes to process the queued messages. Messages still queued once the context
+// is done are Nak'ed for redelivery. Run may only be called once.
+func (d *KeyedDispatcher) Run(ctx context.Context, msgs MsgCh) error {
+	d.start(ctx)
+	defer d.stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case msg, ok := <-msgs:
+			if !ok {
+				return nil
+			}
+
+			if err := d.Dispatch(ctx, msg); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// Dispatch queues the message on the lane of its key, blocking while the lane is full.
+// It may only be called while Run is running.
+func (d *KeyedDispatcher) Dispatch(ctx context.Context, msg Message) error {
+	lane := d.lane(d.keyFn(msg))
+
+	atomic.AddInt64(&d.depths[lane], 1)
+
+	select {
+	case d.lanes[lane] <- msg:
+		return nil
+	case <-ctx.Done():
+		atomic.AddInt64(&d.depths[lane], -1)
+
+		_ = msg.Nak()
+
+		return errors.Wrap(ErrKeyedDispatcher, ctx.Err().Error())
+	}
+}
+
+// LaneDepths returns the number of messages queued or being processed on each lane, to be
+// exported as a metric. Lanes which stay deep point to hot keys.
+func (d *KeyedDispatcher) LaneDepths() []int {
+	depths := make([]int, len(d.depths))
+
+	for i := range d.depths {
+		depths[i] = int(atomic.LoadInt64(&d.depths[i]))
+	}
+
+	return depths
+}
+
+func (d *KeyedDispatcher) lane(key string) int {
+	if key == "" {
+		return int(atomic.AddUint32(&d.next, 1) % uint32(len(d.lanes)))
+	}
+
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+
+	return int(h.Sum32() % uint32(len(d.lanes)))
+}
+
+func (d *KeyedDispatcher) start(ctx context.Context) {
+	d.once.Do(func() {
+		d.ctx = ctx
+
+		for i := range d.lanes {
+			d.wg.Add(1)
+
+			go d.process(i)
+		}
+	})
+}
+
+func (d *KeyedDispatcher) stop() {
+	for _, lane := range d.lanes {
+		close(lane)
+	}
+
+	d.wg.Wait()
+}
+
+func (d *KeyedDispatcher) process(lane int) {
+	defer d.wg.Done()
+
+	for msg := range d.lanes[lane] {
+		if d.ctx.Err() != nil {
+			_ = msg.Nak()
+		} else {
+			d.handler(d.ctx, msg)
+		}
+
+		atomic.AddInt64(&d.depths[lane], -1)
+	}
+}
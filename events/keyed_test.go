@@ -0,0 +1,155 @@
+//nolint:all
+package events
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func keyedTestMsg(key string, seq int) Message {
+	msg := nats.NewMsg("test")
+	msg.Data = []byte(fmt.Sprint(seq))
+
+	if key != "" {
+		msg.Header.Set(MessageKeyHeader, key)
+	}
+
+	return &natsMsg{msg: msg}
+}
+
+func TestKeyedDispatcherOrdering(t *testing.T) {
+	var (
+		mu        sync.Mutex
+		processed = map[string][]string{}
+		active    int32
+		maxActive int32
+	)
+
+	d, err := NewKeyedDispatcher(4, func(ctx context.Context, msg Message) {
+		n := atomic.AddInt32(&active, 1)
+		defer atomic.AddInt32(&active, -1)
+
+		for {
+			max := atomic.LoadInt32(&maxActive)
+			if n <= max || atomic.CompareAndSwapInt32(&maxActive, max, n) {
+				break
+			}
+		}
+
+		time.Sleep(time.Millisecond)
+
+		key := KeyFromHeader(MessageKeyHeader)(msg)
+
+		mu.Lock()
+		processed[key] = append(processed[key], string(msg.Data()))
+		mu.Unlock()
+	})
+	require.NoError(t, err)
+
+	keys := []string{"server-a", "server-b", "server-c", "server-d", "server-e"}
+
+	msgs := make(MsgCh)
+
+	done := make(chan error)
+	go func() {
+		done <- d.Run(context.Background(), msgs)
+	}()
+
+	for i := 0; i < 20; i++ {
+		for _, key := range keys {
+			msgs <- keyedTestMsg(key, i)
+		}
+	}
+
+	close(msgs)
+	require.NoError(t, <-done)
+
+	for _, key := range keys {
+		expected := make([]string, 20)
+		for i := range expected {
+			expected[i] = fmt.Sprint(i)
+		}
+
+		assert.Equal(t, expected, processed[key], "messages of %s out of order", key)
+	}
+
+	assert.Greater(t, atomic.LoadInt32(&maxActive), int32(1), "keys should be processed concurrently")
+	assert.Equal(t, []int{0, 0, 0, 0}, d.LaneDepths())
+}
+
+func TestKeyedDispatcherLaneDepths(t *testing.T) {
+	release := make(chan struct{})
+
+	d, err := NewKeyedDispatcher(2, func(ctx context.Context, msg Message) {
+		<-release
+	})
+	require.NoError(t, err)
+
+	msgs := make(MsgCh)
+
+	done := make(chan error)
+	go func() {
+		done <- d.Run(context.Background(), msgs)
+	}()
+
+	for i := 0; i < 3; i++ {
+		msgs <- keyedTestMsg("hot-key", i)
+	}
+
+	hot := d.lane("hot-key")
+
+	assert.Eventually(t, func() bool {
+		return d.LaneDepths()[hot] == 3
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, 0, d.LaneDepths()[1-hot])
+
+	close(release)
+	close(msgs)
+	require.NoError(t, <-done)
+
+	assert.Equal(t, []int{0, 0}, d.LaneDepths())
+}
+
+func TestKeyedDispatcherContextDone(t *testing.T) {
+	var handled int32
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	d, err := NewKeyedDispatcher(1, func(ctx context.Context, msg Message) {
+		atomic.AddInt32(&handled, 1)
+		cancel()
+	})
+	require.NoError(t, err)
+
+	msgs := make(MsgCh, 3)
+	for i := 0; i < 3; i++ {
+		msgs <- keyedTestMsg("", i)
+	}
+
+	require.NoError(t, d.Run(ctx, msgs))
+
+	// messages left once the context is done are not handled
+	assert.Equal(t, int32(1), atomic.LoadInt32(&handled))
+	assert.Equal(t, []int{0}, d.LaneDepths())
+}
+
+func TestNewKeyedDispatcherErrors(t *testing.T) {
+	handler := func(ctx context.Context, msg Message) {}
+
+	_, err := NewKeyedDispatcher(0, handler)
+	assert.ErrorIs(t, err, ErrKeyedDispatcher)
+
+	_, err = NewKeyedDispatcher(1, nil)
+	assert.ErrorIs(t, err, ErrKeyedDispatcher)
+
+	_, err = NewKeyedDispatcher(1, handler, WithLaneBuffer(-1))
+	assert.ErrorIs(t, err, ErrKeyedDispatcher)
+}
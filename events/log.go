@@ -0,0 +1,343 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+)
+
+// ErrLogBroker is returned by the LogBroker when it is used before being opened
+var ErrLogBroker = errors.New("error in log broker")
+
+// logBrokerStream is the stream name in the Metadata of the LogBroker messages
+const logBrokerStream = "log"
+
+// LogBroker is a Stream for local development without NATS, publishes are written as JSON lines to
+// its writer and looped back to its subscribers. Its messages are only delivered once, their acks are
+// no-ops.
+//
+// NewStream returns a LogBroker writing to stderr when NatsOptions.DevLogBroker is set.
+type LogBroker struct {
+	w        io.Writer
+	prefix   string
+	subjects []string
+
+	mu       sync.Mutex
+	opened   bool
+	closed   bool
+	sequence uint64
+	queue    []Message
+	queued   chan struct{}
+	closeCh  chan struct{}
+
+	subscriberCh MsgCh
+
+	// scrubbers redact the published payloads, see SetPayloadScrubbers
+	scrubbers payloadScrubbers
+}
+
+// NewLogBroker returns a LogBroker writing the published messages to w, stderr when nil.
+func NewLogBroker(w io.Writer) *LogBroker {
+	if w == nil {
+		w = os.Stderr
+	}
+
+	return &LogBroker{
+		w:       w,
+		queued:  make(chan struct{}, 1),
+		closeCh: make(chan struct{}),
+	}
+}
+
+// newLogBrokerFromOptions returns the LogBroker of the dev mode, publishing under the publisher
+// subject prefix and only looping back the subscribe subjects
+func newLogBrokerFromOptions(o NatsOptions) *LogBroker {
+	b := NewLogBroker(nil)
+	b.prefix = o.PublisherSubjectPrefix
+	b.subjects = o.SubscribeSubjects
+
+	return b
+}
+
+// Open opens the LogBroker, there is no connection to set up.
+func (b *LogBroker) Open() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrNatsClosed
+	}
+
+	b.opened = true
+
+	return nil
+}
+
+type logBrokerEntry struct {
+	Time     time.Time       `json:"time"`
+	Sequence uint64          `json:"seq"`
+	Subject  string          `json:"subject"`
+	Data     json.RawMessage `json:"data,omitempty"`
+	Text     string          `json:"text,omitempty"`
+}
+
+// Publish writes the message to the writer and hands it to the subscribers when its subject is one
+// of the subscribe subjects, all subjects when none are set.
+func (b *LogBroker) Publish(_ context.Context, subject string, data []byte) error {
+	if b.prefix != "" {
+		subject = b.prefix + "." + subject
+	}
+
+	if err := ValidateSubject(subject); err != nil {
+		return err
+	}
+
+	data, err := b.scrubbers.scrub(subject, data)
+	if err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrNatsClosed
+	}
+
+	if !b.opened {
+		return errors.Wrap(ErrLogBroker, "broker is not open")
+	}
+
+	b.sequence++
+
+	msg := &logMsg{subject: subject, data: data, sequence: b.sequence, timestamp: time.Now()}
+
+	entry := logBrokerEntry{Time: msg.timestamp.UTC(), Sequence: msg.sequence, Subject: subject}
+
+	// JSON payloads are logged as is, others as text
+	if json.Valid(data) {
+		entry.Data = data
+	} else {
+		entry.Text = string(data)
+	}
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(ErrLogBroker, err.Error())
+	}
+
+	if _, err := b.w.Write(append(line, '\n')); err != nil {
+		return errors.Wrap(ErrLogBroker, err.Error())
+	}
+
+	if !b.loopback(subject) {
+		return nil
+	}
+
+	b.queue = append(b.queue, msg)
+
+	select {
+	case b.queued <- struct{}{}:
+	default:
+	}
+
+	return nil
+}
+
+func (b *LogBroker) loopback(subject string) bool {
+	if len(b.subjects) == 0 {
+		return true
+	}
+
+	for _, pattern := range b.subjects {
+		if SubjectMatches(subject, pattern) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SetPayloadScrubbers sets the scrubbers applied in order to the payloads before they are written and
+// looped back, see NatsJetstream.SetPayloadScrubbers.
+func (b *LogBroker) SetPayloadScrubbers(scrubbers ...PayloadScrubber) {
+	b.scrubbers.set(scrubbers)
+}
+
+// Subscribe returns the channel the published messages are handed to, it is closed by Close.
+func (b *LogBroker) Subscribe(_ context.Context) (MsgCh, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrNatsClosed
+	}
+
+	if !b.opened {
+		return nil, errors.Wrap(ErrLogBroker, "broker is not open")
+	}
+
+	if b.subscriberCh == nil {
+		b.subscriberCh = make(MsgCh)
+
+		go b.deliver(b.subscriberCh)
+	}
+
+	return b.subscriberCh, nil
+}
+
+// deliver hands the queued messages to the subscribers until the broker is closed
+func (b *LogBroker) deliver(ch MsgCh) {
+	defer close(ch)
+
+	for {
+		msg, ok := b.next()
+		if !ok {
+			select {
+			case <-b.queued:
+				continue
+			case <-b.closeCh:
+				return
+			}
+		}
+
+		select {
+		case ch <- msg:
+		case <-b.closeCh:
+			return
+		}
+	}
+}
+
+func (b *LogBroker) next() (Message, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if len(b.queue) == 0 {
+		return nil, false
+	}
+
+	msg := b.queue[0]
+	b.queue = b.queue[1:]
+
+	return msg, true
+}
+
+// PullMsg returns up to batch of the queued messages, waiting for one to be published until the
+// context is done, or DefaultFetchMaxWait when it has no deadline.
+func (b *LogBroker) PullMsg(ctx context.Context, batch int) ([]Message, error) {
+	timer := time.NewTimer(DefaultFetchMaxWait)
+	defer timer.Stop()
+
+	for {
+		b.mu.Lock()
+
+		if b.closed {
+			b.mu.Unlock()
+			return nil, ErrNatsClosed
+		}
+
+		if !b.opened {
+			b.mu.Unlock()
+			return nil, errors.Wrap(ErrLogBroker, "broker is not open")
+		}
+
+		if len(b.queue) > 0 {
+			n := batch
+			if n <= 0 || n > len(b.queue) {
+				n = len(b.queue)
+			}
+
+			msgs := append([]Message(nil), b.queue[:n]...)
+			b.queue = b.queue[n:]
+
+			b.mu.Unlock()
+
+			return msgs, nil
+		}
+
+		b.mu.Unlock()
+
+		_, hasDeadline := ctx.Deadline()
+
+		select {
+		case <-b.queued:
+		case <-b.closeCh:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timer.C:
+			if !hasDeadline {
+				return nil, nats.ErrTimeout
+			}
+		}
+	}
+}
+
+// Close closes the channel returned by Subscribe, the messages not delivered yet are dropped.
+func (b *LogBroker) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil
+	}
+
+	b.closed = true
+	b.queue = nil
+
+	close(b.closeCh)
+
+	return nil
+}
+
+// logMsg is a message of the LogBroker, it is delivered once whatever is done with it
+type logMsg struct {
+	subject   string
+	data      []byte
+	sequence  uint64
+	timestamp time.Time
+}
+
+func (m *logMsg) Ack() error { return nil }
+
+func (m *logMsg) Nak() error { return nil }
+
+func (m *logMsg) AckWithContext(_ context.Context) error { return nil }
+
+func (m *logMsg) NakWithContext(_ context.Context) error { return nil }
+
+func (m *logMsg) Term() error { return nil }
+
+func (m *logMsg) NakWithReason(_ string, _ time.Duration) error { return nil }
+
+func (m *logMsg) TermWithReason(_ string) error { return nil }
+
+func (m *logMsg) InProgress() error { return nil }
+
+func (m *logMsg) Subject() string { return m.subject }
+
+func (m *logMsg) Data() []byte { return m.data }
+
+func (m *logMsg) ExtractOtelTraceContext(ctx context.Context) context.Context { return ctx }
+
+func (m *logMsg) Metadata() (MessageMetadata, error) {
+	return MessageMetadata{
+		StreamSequence:   m.sequence,
+		ConsumerSequence: m.sequence,
+		NumDelivered:     1,
+		Stream:           logBrokerStream,
+		Timestamp:        m.timestamp,
+	}, nil
+}
+
+func (m *logMsg) DecodeInto(v interface{}) error {
+	return DecodePayload(m.data, "", "", v)
+}
+
+func (m *logMsg) IsTombstone() bool { return false }
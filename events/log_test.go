@@ -0,0 +1,144 @@
+//nolint:all
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogBroker(t *testing.T) {
+	var buf bytes.Buffer
+
+	b := NewLogBroker(&buf)
+
+	err := b.Publish(context.Background(), "foo.bar", []byte(`{"id":1}`))
+	require.ErrorIs(t, err, ErrLogBroker)
+
+	require.NoError(t, b.Open())
+
+	ch, err := b.Subscribe(context.Background())
+	require.NoError(t, err)
+
+	require.NoError(t, b.Publish(context.Background(), "foo.bar", []byte(`{"id":1}`)))
+	require.NoError(t, b.Publish(context.Background(), "foo.baz", []byte("plain text")))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+
+	var entry struct {
+		Sequence uint64          `json:"seq"`
+		Subject  string          `json:"subject"`
+		Data     json.RawMessage `json:"data"`
+		Text     string          `json:"text"`
+	}
+
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, uint64(1), entry.Sequence)
+	assert.Equal(t, "foo.bar", entry.Subject)
+	assert.JSONEq(t, `{"id":1}`, string(entry.Data))
+
+	require.NoError(t, json.Unmarshal([]byte(lines[1]), &entry))
+	assert.Equal(t, "plain text", entry.Text)
+
+	msg := <-ch
+	assert.Equal(t, "foo.bar", msg.Subject())
+	assert.NoError(t, msg.Ack())
+
+	var v struct{ ID int }
+	require.NoError(t, msg.DecodeInto(&v))
+	assert.Equal(t, 1, v.ID)
+
+	md, err := msg.Metadata()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), md.StreamSequence)
+	assert.Equal(t, uint64(1), md.NumDelivered)
+
+	msg = <-ch
+	assert.Equal(t, "foo.baz", msg.Subject())
+	assert.NoError(t, msg.Nak())
+
+	require.NoError(t, b.Close())
+	require.NoError(t, b.Close())
+
+	_, ok := <-ch
+	assert.False(t, ok)
+
+	assert.ErrorIs(t, b.Publish(context.Background(), "foo.bar", nil), ErrNatsClosed)
+
+	_, err = b.PullMsg(context.Background(), 1)
+	assert.ErrorIs(t, err, ErrNatsClosed)
+}
+
+func TestLogBrokerPullMsg(t *testing.T) {
+	b := NewLogBroker(&bytes.Buffer{})
+	require.NoError(t, b.Open())
+
+	for _, subject := range []string{"a", "b", "c"} {
+		require.NoError(t, b.Publish(context.Background(), subject, []byte(subject)))
+	}
+
+	msgs, err := b.PullMsg(context.Background(), 2)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	assert.Equal(t, "a", msgs[0].Subject())
+	assert.Equal(t, "b", msgs[1].Subject())
+
+	msgs, err = b.PullMsg(context.Background(), 2)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "c", msgs[0].Subject())
+
+	// waits for a publish
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		_ = b.Publish(context.Background(), "d", nil)
+	}()
+
+	msgs, err = b.PullMsg(context.Background(), 2)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "d", msgs[0].Subject())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	_, err = b.PullMsg(ctx, 1)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.False(t, errors.Is(err, nats.ErrTimeout))
+}
+
+func TestNewStreamDevLogBroker(t *testing.T) {
+	stream, err := NewStream(NatsOptions{
+		DevLogBroker:           true,
+		PublisherSubjectPrefix: "com.hollow.sh.events",
+		SubscribeSubjects:      []string{"com.hollow.sh.events.servers.>"},
+	})
+	require.NoError(t, err)
+
+	b, ok := stream.(*LogBroker)
+	require.True(t, ok)
+
+	b.w = &bytes.Buffer{}
+
+	require.NoError(t, b.Open())
+	require.NoError(t, b.Publish(context.Background(), "sites.create", nil))
+	require.NoError(t, b.Publish(context.Background(), "servers.create", nil))
+
+	// only the subscribe subjects are looped back
+	msgs, err := b.PullMsg(context.Background(), 10)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "com.hollow.sh.events.servers.create", msgs[0].Subject())
+
+	_, err = NewStream(NatsOptions{})
+	assert.ErrorIs(t, err, ErrNatsConfig)
+}
@@ -7,6 +7,7 @@ package mock_events
 import (
 	context "context"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	events "go.hollow.sh/toolbox/events"
@@ -130,6 +131,95 @@ func (mr *MockStreamMockRecorder) Subscribe(ctx interface{}) *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Subscribe", reflect.TypeOf((*MockStream)(nil).Subscribe), ctx)
 }
 
+// MockConsumptionPauser is a mock of ConsumptionPauser interface.
+type MockConsumptionPauser struct {
+	ctrl     *gomock.Controller
+	recorder *MockConsumptionPauserMockRecorder
+}
+
+// MockConsumptionPauserMockRecorder is the mock recorder for MockConsumptionPauser.
+type MockConsumptionPauserMockRecorder struct {
+	mock *MockConsumptionPauser
+}
+
+// NewMockConsumptionPauser creates a new mock instance.
+func NewMockConsumptionPauser(ctrl *gomock.Controller) *MockConsumptionPauser {
+	mock := &MockConsumptionPauser{ctrl: ctrl}
+	mock.recorder = &MockConsumptionPauserMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockConsumptionPauser) EXPECT() *MockConsumptionPauserMockRecorder {
+	return m.recorder
+}
+
+// PauseConsumption mocks base method.
+func (m *MockConsumptionPauser) PauseConsumption() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PauseConsumption")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PauseConsumption indicates an expected call of PauseConsumption.
+func (mr *MockConsumptionPauserMockRecorder) PauseConsumption() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PauseConsumption", reflect.TypeOf((*MockConsumptionPauser)(nil).PauseConsumption))
+}
+
+// ResumeConsumption mocks base method.
+func (m *MockConsumptionPauser) ResumeConsumption() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ResumeConsumption")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ResumeConsumption indicates an expected call of ResumeConsumption.
+func (mr *MockConsumptionPauserMockRecorder) ResumeConsumption() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ResumeConsumption", reflect.TypeOf((*MockConsumptionPauser)(nil).ResumeConsumption))
+}
+
+// MockSubjectSubscriber is a mock of SubjectSubscriber interface.
+type MockSubjectSubscriber struct {
+	ctrl     *gomock.Controller
+	recorder *MockSubjectSubscriberMockRecorder
+}
+
+// MockSubjectSubscriberMockRecorder is the mock recorder for MockSubjectSubscriber.
+type MockSubjectSubscriberMockRecorder struct {
+	mock *MockSubjectSubscriber
+}
+
+// NewMockSubjectSubscriber creates a new mock instance.
+func NewMockSubjectSubscriber(ctrl *gomock.Controller) *MockSubjectSubscriber {
+	mock := &MockSubjectSubscriber{ctrl: ctrl}
+	mock.recorder = &MockSubjectSubscriberMockRecorder{mock}
+	return mock
+}
+
+// EXPECT returns an object that allows the caller to indicate expected use.
+func (m *MockSubjectSubscriber) EXPECT() *MockSubjectSubscriberMockRecorder {
+	return m.recorder
+}
+
+// SubscribeSubject mocks base method.
+func (m *MockSubjectSubscriber) SubscribeSubject(ctx context.Context, subject string, opts events.SubjectConsumerOptions) (events.MsgCh, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SubscribeSubject", ctx, subject, opts)
+	ret0, _ := ret[0].(events.MsgCh)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// SubscribeSubject indicates an expected call of SubscribeSubject.
+func (mr *MockSubjectSubscriberMockRecorder) SubscribeSubject(ctx, subject, opts interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SubscribeSubject", reflect.TypeOf((*MockSubjectSubscriber)(nil).SubscribeSubject), ctx, subject, opts)
+}
+
 // MockMessage is a mock of Message interface.
 type MockMessage struct {
 	ctrl     *gomock.Controller
@@ -167,6 +257,20 @@ func (mr *MockMessageMockRecorder) Ack() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Ack", reflect.TypeOf((*MockMessage)(nil).Ack))
 }
 
+// AckWithContext mocks base method.
+func (m *MockMessage) AckWithContext(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AckWithContext", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// AckWithContext indicates an expected call of AckWithContext.
+func (mr *MockMessageMockRecorder) AckWithContext(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AckWithContext", reflect.TypeOf((*MockMessage)(nil).AckWithContext), ctx)
+}
+
 // Data mocks base method.
 func (m *MockMessage) Data() []byte {
 	m.ctrl.T.Helper()
@@ -181,6 +285,20 @@ func (mr *MockMessageMockRecorder) Data() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Data", reflect.TypeOf((*MockMessage)(nil).Data))
 }
 
+// DecodeInto mocks base method.
+func (m *MockMessage) DecodeInto(v interface{}) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DecodeInto", v)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DecodeInto indicates an expected call of DecodeInto.
+func (mr *MockMessageMockRecorder) DecodeInto(v interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecodeInto", reflect.TypeOf((*MockMessage)(nil).DecodeInto), v)
+}
+
 // ExtractOtelTraceContext mocks base method.
 func (m *MockMessage) ExtractOtelTraceContext(ctx context.Context) context.Context {
 	m.ctrl.T.Helper()
@@ -209,6 +327,35 @@ func (mr *MockMessageMockRecorder) InProgress() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InProgress", reflect.TypeOf((*MockMessage)(nil).InProgress))
 }
 
+// IsTombstone mocks base method.
+func (m *MockMessage) IsTombstone() bool {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsTombstone")
+	ret0, _ := ret[0].(bool)
+	return ret0
+}
+
+// IsTombstone indicates an expected call of IsTombstone.
+func (mr *MockMessageMockRecorder) IsTombstone() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsTombstone", reflect.TypeOf((*MockMessage)(nil).IsTombstone))
+}
+
+// Metadata mocks base method.
+func (m *MockMessage) Metadata() (events.MessageMetadata, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Metadata")
+	ret0, _ := ret[0].(events.MessageMetadata)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Metadata indicates an expected call of Metadata.
+func (mr *MockMessageMockRecorder) Metadata() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Metadata", reflect.TypeOf((*MockMessage)(nil).Metadata))
+}
+
 // Nak mocks base method.
 func (m *MockMessage) Nak() error {
 	m.ctrl.T.Helper()
@@ -223,6 +370,34 @@ func (mr *MockMessageMockRecorder) Nak() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Nak", reflect.TypeOf((*MockMessage)(nil).Nak))
 }
 
+// NakWithContext mocks base method.
+func (m *MockMessage) NakWithContext(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NakWithContext", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// NakWithContext indicates an expected call of NakWithContext.
+func (mr *MockMessageMockRecorder) NakWithContext(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NakWithContext", reflect.TypeOf((*MockMessage)(nil).NakWithContext), ctx)
+}
+
+// NakWithReason mocks base method.
+func (m *MockMessage) NakWithReason(reason string, delay time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "NakWithReason", reason, delay)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// NakWithReason indicates an expected call of NakWithReason.
+func (mr *MockMessageMockRecorder) NakWithReason(reason, delay interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "NakWithReason", reflect.TypeOf((*MockMessage)(nil).NakWithReason), reason, delay)
+}
+
 // Subject mocks base method.
 func (m *MockMessage) Subject() string {
 	m.ctrl.T.Helper()
@@ -250,3 +425,17 @@ func (mr *MockMessageMockRecorder) Term() *gomock.Call {
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Term", reflect.TypeOf((*MockMessage)(nil).Term))
 }
+
+// TermWithReason mocks base method.
+func (m *MockMessage) TermWithReason(reason string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "TermWithReason", reason)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// TermWithReason indicates an expected call of TermWithReason.
+func (mr *MockMessageMockRecorder) TermWithReason(reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "TermWithReason", reflect.TypeOf((*MockMessage)(nil).TermWithReason), reason)
+}
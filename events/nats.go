@@ -6,6 +6,7 @@ import (
 	"log"
 	"reflect"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/hashicorp/go-multierror"
@@ -46,6 +47,10 @@ const (
 	consumerAckPolicy  = nats.AckExplicitPolicy
 )
 
+// DefaultFetchMaxWait is how long PullMsg waits for messages when neither the context has a deadline
+// nor the consumer FetchMaxWait is set.
+const DefaultFetchMaxWait = 5 * time.Second
+
 // NatsJetstream wraps the NATs JetStream connector to implement the Stream interface.
 type NatsJetstream struct {
 	jsctx         nats.JetStreamContext
@@ -53,6 +58,51 @@ type NatsJetstream struct {
 	parameters    *NatsOptions
 	subscriptions []*nats.Subscription
 	subscriberCh  MsgCh
+	clock         Clock
+	draining      int32
+	drainCh       chan struct{}
+	drainOnce     sync.Once
+	pauseMu       sync.Mutex
+	resumeCh      chan struct{}
+	validSubjects sync.Map
+	closed        int32
+
+	// callbacks tracks the subscription callbacks sending on the subscriberCh,
+	// it is closed once they returned.
+	callbacksMu        sync.Mutex
+	callbacks          sync.WaitGroup
+	subscriberChClosed bool
+	// subjectSubscriptions are the subscriptions made with SubscribeSubject
+	subjectSubscriptions []*subjectSubscription
+
+	coop cooperativeFetch
+
+	ackDeadlineWarnings uint64
+	expiredMessages     uint64
+	filteredMessages    uint64
+
+	// filter is matched by the messages of the consumer along with its HeaderFilters
+	filter MessageFilter
+
+	// reasons keeps the failure reasons of the messages, see SetReasonStore
+	reasonsMu sync.Mutex
+	reasons   ReasonStore
+
+	// streams are the streams of a MultiStreamBroker, publishes are routed to them by subject
+	streams []NatsStreamOptions
+
+	// lastPublished holds the time of the last message published on each subject, heartbeats excluded
+	lastPublished sync.Map
+
+	// exactlyOnce is set by WithExactlyOnce
+	exactlyOnce bool
+	// ledger records the messages processed by the IdempotentHandler
+	ledgerMu          sync.Mutex
+	ledger            IdempotencyLedger
+	duplicateMessages uint64
+
+	// scrubbers redact the published payloads, see SetPayloadScrubbers
+	scrubbers payloadScrubbers
 }
 
 // Add some conversions for functions/APIs that expect NATS primitive types. This allows consumers of
@@ -69,7 +119,7 @@ func AsNatsJetStreamContext(n *NatsJetstream) nats.JetStreamContext {
 }
 
 // NewNatsBroker validates the given stream broker parameters and returns a stream broker implementation.
-func NewNatsBroker(params StreamParameters) (*NatsJetstream, error) {
+func NewNatsBroker(params StreamParameters, opts ...BrokerOption) (*NatsJetstream, error) {
 	parameters, valid := params.(NatsOptions)
 	if !valid {
 		return nil, errors.Wrap(
@@ -82,7 +132,15 @@ func NewNatsBroker(params StreamParameters) (*NatsJetstream, error) {
 		return nil, err
 	}
 
-	return &NatsJetstream{parameters: &parameters}, nil
+	n := &NatsJetstream{parameters: &parameters, drainCh: make(chan struct{})}
+
+	for _, opt := range opts {
+		opt(n)
+	}
+
+	n.applyExactlyOnce()
+
+	return n, nil
 }
 
 // NewJetstreamFromConn takes an already established NATS connection pointer and returns a NatsJetstream pointer
@@ -91,8 +149,10 @@ func NewJetstreamFromConn(c *nats.Conn) *NatsJetstream {
 	// a guarantee that c has JetStream enabled.
 	js, _ := c.JetStream()
 	return &NatsJetstream{
-		conn:  c,
-		jsctx: js,
+		conn:         c,
+		jsctx:        js,
+		drainCh:      make(chan struct{}),
+		subscriberCh: make(MsgCh),
 	}
 }
 
@@ -128,6 +188,13 @@ func (n *NatsJetstream) Open() error {
 
 	n.conn = conn
 
+	if err := n.preflight(); err != nil {
+		n.conn.Close()
+		n.conn = nil
+
+		return err
+	}
+
 	// setup the channel for subscribers to read messages from.
 	n.subscriberCh = make(MsgCh)
 
@@ -143,7 +210,13 @@ func (n *NatsJetstream) setup() error {
 
 	n.jsctx = js
 
-	if n.parameters.Stream != nil {
+	for i := range n.streams {
+		if err := n.addStreamWith(&n.streams[i]); err != nil {
+			return err
+		}
+	}
+
+	if n.parameters.Stream != nil && len(n.streams) == 0 {
 		if err := n.addStream(); err != nil {
 			return err
 		}
@@ -159,39 +232,47 @@ func (n *NatsJetstream) setup() error {
 }
 
 func (n *NatsJetstream) addStream() error {
+	return n.addStreamWith(n.parameters.Stream)
+}
+
+// addStreamWith adds the stream, existing streams are only updated to apply their subject transform.
+func (n *NatsJetstream) addStreamWith(stream *NatsStreamOptions) error {
 	if n.jsctx == nil {
 		return errors.Wrap(ErrNatsJetstreamAddStream, "Jetstream context is not setup")
 	}
 
 	// check stream isn't already present
+	var exists bool
+
 	for name := range n.jsctx.StreamNames() {
-		if name == n.parameters.Stream.Name {
-			return nil
+		if name == stream.Name {
+			exists = true
 		}
 	}
 
-	var retention nats.RetentionPolicy
+	// existing streams are only updated to apply their subject transform
+	if exists && stream.SubjectTransform == nil {
+		return nil
+	}
 
-	switch n.parameters.Stream.Retention {
-	case "workQueue":
-		retention = nats.WorkQueuePolicy
-	case "limits":
-		retention = nats.LimitsPolicy
-	case "interest":
-		retention = nats.InterestPolicy
-	default:
-		return errors.Wrap(ErrNatsConfig, "unknown retention policy defined: "+n.parameters.Stream.Retention)
+	retention, err := natsRetention(stream.Retention)
+	if err != nil {
+		return err
 	}
 
-	_, err := n.jsctx.AddStream(
-		&nats.StreamConfig{
-			Name:      n.parameters.Stream.Name,
-			Subjects:  n.parameters.Stream.Subjects,
-			Retention: retention,
-		},
-	)
+	cfg := &nats.StreamConfig{
+		Name:       stream.Name,
+		Subjects:   stream.Subjects,
+		Retention:  retention,
+		Duplicates: stream.DuplicateWindow,
+		MaxAge:     stream.MaxAge,
+	}
 
-	if err != nil {
+	if stream.SubjectTransform != nil {
+		return n.addTransformedStream(cfg, stream.SubjectTransform, exists)
+	}
+
+	if _, err := n.jsctx.AddStream(cfg); err != nil {
 		return errors.Wrap(ErrNatsJetstreamAddStream, err.Error())
 	}
 
@@ -209,14 +290,21 @@ func (n *NatsJetstream) addConsumer() error {
 
 	// https://pkg.go.dev/github.com/nats-io/nats.go#ConsumerConfig
 	cfg := &nats.ConsumerConfig{
-		Durable:       n.parameters.Consumer.Name,
-		MaxDeliver:    -1,
-		AckPolicy:     nats.AckExplicitPolicy,
-		AckWait:       n.parameters.Consumer.AckWait,
-		MaxAckPending: n.parameters.Consumer.MaxAckPending,
-		DeliverPolicy: nats.DeliverAllPolicy,
-		DeliverGroup:  n.parameters.Consumer.QueueGroup,
-		FilterSubject: n.parameters.Consumer.FilterSubject,
+		Durable:           n.parameters.Consumer.Name,
+		MaxDeliver:        -1,
+		AckPolicy:         n.parameters.Consumer.natsAckPolicy(),
+		AckWait:           n.parameters.Consumer.AckWait,
+		MaxAckPending:     n.parameters.Consumer.MaxAckPending,
+		DeliverPolicy:     n.parameters.Consumer.natsDeliverPolicy(),
+		OptStartSeq:       n.parameters.Consumer.StartSequence,
+		OptStartTime:      n.parameters.Consumer.natsStartTime(),
+		DeliverGroup:      n.parameters.Consumer.QueueGroup,
+		FilterSubject:     n.parameters.Consumer.FilterSubject,
+		InactiveThreshold: n.parameters.Consumer.InactiveThreshold,
+	}
+
+	if len(n.parameters.Consumer.FilterSubjects) > 1 {
+		return n.addMultiFilterConsumer(cfg)
 	}
 
 	// Update consumer configuration when one exists
@@ -250,9 +338,13 @@ func (n *NatsJetstream) consumerConfigIsEqual(consumerInfo *nats.ConsumerInfo) b
 	switch {
 	case consumerInfo.Config.MaxDeliver != consumerMaxDeliver:
 		return false
-	case consumerInfo.Config.AckPolicy != consumerAckPolicy:
+	case consumerInfo.Config.AckPolicy != n.parameters.Consumer.natsAckPolicy():
+		return false
+	case consumerInfo.Config.DeliverPolicy != n.parameters.Consumer.natsDeliverPolicy():
 		return false
-	case consumerInfo.Config.DeliverPolicy != consumerDeliverPolicy:
+	case consumerInfo.Config.OptStartSeq != n.parameters.Consumer.StartSequence:
+		return false
+	case !startTimeEqual(consumerInfo.Config.OptStartTime, n.parameters.Consumer.natsStartTime()):
 		return false
 	case consumerInfo.Name != n.parameters.Consumer.Name:
 		return false
@@ -266,38 +358,350 @@ func (n *NatsJetstream) consumerConfigIsEqual(consumerInfo *nats.ConsumerInfo) b
 		return false
 	case consumerInfo.Config.FilterSubject != n.parameters.Consumer.FilterSubject:
 		return false
+	case consumerInfo.Config.InactiveThreshold != n.parameters.Consumer.InactiveThreshold:
+		return false
 	default:
 		return true
 	}
 }
 
+// startTimeEqual returns true when both start times are unset or the same instant.
+func startTimeEqual(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Equal(*b)
+}
+
 // Publish publishes an event onto the NATS Jetstream. The caller is responsible for message
-// addressing and data serialization. NOTE: The subject passed here will be prepended with any
-// configured PublisherSubjectPrefix.
+// addressing and data serialization, the Content-Type header is set to ContentTypeJSON.
+// NOTE: The subject passed here will be prepended with any configured PublisherSubjectPrefix.
 func (n *NatsJetstream) Publish(ctx context.Context, subjectSuffix string, data []byte) error {
 	if n.jsctx == nil {
 		return errors.Wrap(ErrNatsJetstreamAddConsumer, "Jetstream context is not setup")
 	}
 
+	subject := n.fullSubject(subjectSuffix)
+	if err := n.validatePublishSubject(subject); err != nil {
+		return err
+	}
+
+	data, err := n.scrubbers.scrub(subject, data)
+	if err != nil {
+		return err
+	}
+
 	// retry publishing for a while
 	options := []nats.PubOpt{
 		nats.RetryAttempts(-1),
 	}
 
-	fullSubject := strings.Join(
-		[]string{
-			n.parameters.PublisherSubjectPrefix,
-			subjectSuffix,
-		}, ".")
+	if stream := n.streamFor(subject); stream != "" {
+		options = append(options, nats.ExpectStream(stream))
+	}
+
+	if id := n.exactlyOnceMsgID(subject, data); id != "" {
+		options = append(options, nats.MsgId(id))
+	}
+
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+
+	// the caller serializes the data, JSON is the default codec
+	msg.Header.Set(HeaderContentType, ContentTypeJSON)
+
+	// inject otel trace context
+	injectOtelTraceContext(ctx, msg)
+
+	if _, err := n.jsctx.PublishMsg(msg, options...); err != nil {
+		return err
+	}
+
+	n.recordPublished(subject)
+
+	return nil
+}
+
+// PublishOption configures a message published with PublishWithOptions.
+type PublishOption func(*publishOptions)
+
+type publishOptions struct {
+	msgID                  string
+	expectLastSequence     *uint64
+	expectLastSubjSequence *uint64
+	contentType            string
+	contentEncoding        string
+	messageKey             string
+	tombstone              *Tombstone
+	ttl                    time.Duration
+	heartbeat              *heartbeat
+}
+
+// WithMsgID sets the message ID, messages published with the same ID within the
+// stream DuplicateWindow are stored only once.
+func WithMsgID(id string) PublishOption {
+	return func(o *publishOptions) {
+		o.msgID = id
+	}
+}
+
+// WithExpectLastSequence rejects the publish unless the last message in the stream has the given sequence.
+func WithExpectLastSequence(seq uint64) PublishOption {
+	return func(o *publishOptions) {
+		o.expectLastSequence = &seq
+	}
+}
+
+// WithExpectLastSequencePerSubject rejects the publish unless the last message on the subject has the given sequence.
+func WithExpectLastSequencePerSubject(seq uint64) PublishOption {
+	return func(o *publishOptions) {
+		o.expectLastSubjSequence = &seq
+	}
+}
+
+// WithContentType sets the Content-Type header of the message, see RegisterCodec.
+func WithContentType(contentType string) PublishOption {
+	return func(o *publishOptions) {
+		o.contentType = contentType
+	}
+}
+
+// WithContentEncoding sets the Content-Encoding header of the message, see RegisterEncoding.
+//
+// With PublishEncoded the payload is encoded accordingly, with PublishWithOptions the data
+// is expected to be encoded already.
+func WithContentEncoding(contentEncoding string) PublishOption {
+	return func(o *publishOptions) {
+		o.contentEncoding = contentEncoding
+	}
+}
+
+// PublishWithOptions publishes an event onto the NATS Jetstream and returns the stream sequence
+// it was stored at once the server acknowledged it.
+//
+// Combined with WithMsgID and WithExpectLastSequence this provides exactly-once publishing,
+// retried publishes are deduplicated by the stream and concurrent publishers are rejected
+// instead of interleaving. This costs a stream DuplicateWindow worth of message IDs
+// kept by the server, and publishers need to track the last sequence.
+//
+// NOTE: The subject passed here will be prepended with any configured PublisherSubjectPrefix.
+func (n *NatsJetstream) PublishWithOptions(ctx context.Context, subjectSuffix string, data []byte, opts ...PublishOption) (uint64, error) {
+	if n.jsctx == nil {
+		return 0, errors.Wrap(ErrNatsJetstreamAddConsumer, "Jetstream context is not setup")
+	}
+
+	subject := n.fullSubject(subjectSuffix)
+	if err := n.validatePublishSubject(subject); err != nil {
+		return 0, err
+	}
+
+	var po publishOptions
+	for _, opt := range opts {
+		opt(&po)
+	}
+
+	// encoded payloads are opaque, PublishEncoded scrubs them before encoding
+	if po.contentEncoding == "" {
+		var err error
+		if data, err = n.scrubbers.scrub(subject, data); err != nil {
+			return 0, err
+		}
+	}
 
-	msg := nats.NewMsg(fullSubject)
+	if po.msgID == "" {
+		po.msgID = n.exactlyOnceMsgID(subject, data)
+	}
+
+	options := []nats.PubOpt{
+		nats.RetryAttempts(-1),
+	}
+
+	if po.msgID != "" {
+		options = append(options, nats.MsgId(po.msgID))
+	}
+
+	if po.expectLastSequence != nil {
+		options = append(options, nats.ExpectLastSequence(*po.expectLastSequence))
+	}
+
+	if po.expectLastSubjSequence != nil {
+		options = append(options, nats.ExpectLastSequencePerSubject(*po.expectLastSubjSequence))
+	}
+
+	if stream := n.streamFor(subject); stream != "" {
+		options = append(options, nats.ExpectStream(stream))
+	}
+
+	msg := nats.NewMsg(subject)
 	msg.Data = data
 
+	if po.contentType != "" {
+		msg.Header.Set(HeaderContentType, po.contentType)
+	}
+
+	if po.contentEncoding != "" {
+		msg.Header.Set(HeaderContentEncoding, po.contentEncoding)
+	}
+
+	if po.messageKey != "" {
+		msg.Header.Set(MessageKeyHeader, po.messageKey)
+	}
+
+	if po.tombstone != nil {
+		po.tombstone.setHeaders(msg.Header)
+	}
+
+	if po.ttl > 0 {
+		msg.Header.Set(MessageExpiresHeader, n.getClock().Now().Add(po.ttl).UTC().Format(time.RFC3339Nano))
+	}
+
+	if po.heartbeat != nil {
+		msg.Header.Set(HeartbeatHeader, po.heartbeat.sentAt.UTC().Format(time.RFC3339Nano))
+		msg.Header.Set(HeartbeatSourceHeader, po.heartbeat.source)
+	}
+
 	// inject otel trace context
 	injectOtelTraceContext(ctx, msg)
 
-	_, err := n.jsctx.PublishMsg(msg, options...)
-	return err
+	ack, err := n.jsctx.PublishMsg(msg, options...)
+	if err != nil {
+		return 0, err
+	}
+
+	if po.heartbeat == nil {
+		n.recordPublished(subject)
+	}
+
+	return ack.Sequence, nil
+}
+
+// PublishEncoded marshals v with the codec registered for the WithContentType option, JSON when unset,
+// applies the WithContentEncoding option and publishes it with PublishWithOptions.
+//
+// The Content-Type and Content-Encoding headers are set on the message so subscribers
+// can decode it with Message.DecodeInto.
+func (n *NatsJetstream) PublishEncoded(ctx context.Context, subjectSuffix string, v interface{}, opts ...PublishOption) (uint64, error) {
+	var po publishOptions
+	for _, opt := range opts {
+		opt(&po)
+	}
+
+	if po.contentType == "" {
+		po.contentType = ContentTypeJSON
+	}
+
+	data, err := EncodePayload(v, po.contentType, "")
+	if err != nil {
+		return 0, err
+	}
+
+	// the payload is scrubbed before its encoding makes it opaque, PublishWithOptions scrubs it otherwise
+	if po.contentEncoding != "" {
+		if data, err = n.scrubbers.scrub(n.fullSubject(subjectSuffix), data); err != nil {
+			return 0, err
+		}
+
+		encoding, err := EncodingFor(po.contentEncoding)
+		if err != nil {
+			return 0, err
+		}
+
+		if data, err = encoding.Encode(data); err != nil {
+			return 0, errors.Wrap(ErrPayloadCodec, err.Error())
+		}
+	}
+
+	opts = append(opts, WithContentType(po.contentType))
+
+	return n.PublishWithOptions(ctx, subjectSuffix, data, opts...)
+}
+
+// AckFloor returns the stream sequence up to which all messages were acknowledged on the configured consumer.
+func (n *NatsJetstream) AckFloor() (uint64, error) {
+	if n.jsctx == nil {
+		return 0, errors.Wrap(ErrNatsJetstream, "Jetstream context is not setup")
+	}
+
+	if n.parameters == nil || n.parameters.Stream == nil || n.parameters.Consumer == nil {
+		return 0, errors.Wrap(ErrNatsConfig, "stream and consumer parameters required")
+	}
+
+	info, err := n.jsctx.ConsumerInfo(n.parameters.Stream.Name, n.parameters.Consumer.Name)
+	if err != nil {
+		return 0, errors.Wrap(ErrNatsJetstream, err.Error())
+	}
+
+	return info.AckFloor.Stream, nil
+}
+
+// CleanupStaleConsumers deletes the consumers on the configured stream which have no subscribers
+// and weren't active for longer than olderThan, returning the names of the deleted consumers.
+// The configured consumer is never deleted.
+//
+// This is meant for maintenance jobs, consumers created with an InactiveThreshold are cleaned up by the server.
+func (n *NatsJetstream) CleanupStaleConsumers(ctx context.Context, olderThan time.Duration) ([]string, error) {
+	if n.jsctx == nil {
+		return nil, errors.Wrap(ErrNatsJetstream, "Jetstream context is not setup")
+	}
+
+	if n.parameters == nil || n.parameters.Stream == nil {
+		return nil, errors.Wrap(ErrNatsConfig, "stream parameters required")
+	}
+
+	var deleted []string
+
+	for info := range n.jsctx.Consumers(n.parameters.Stream.Name, nats.Context(ctx)) {
+		if n.parameters.Consumer != nil && info.Name == n.parameters.Consumer.Name {
+			continue
+		}
+
+		if !consumerIsStale(info, olderThan) {
+			continue
+		}
+
+		if err := n.jsctx.DeleteConsumer(n.parameters.Stream.Name, info.Name, nats.Context(ctx)); err != nil {
+			if errors.Is(err, nats.ErrConsumerNotFound) {
+				continue
+			}
+
+			return deleted, errors.Wrap(ErrNatsJetstream, "consumer.Name="+info.Name+": "+err.Error())
+		}
+
+		deleted = append(deleted, info.Name)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return deleted, errors.Wrap(ErrNatsJetstream, err.Error())
+	}
+
+	return deleted, nil
+}
+
+// consumerIsStale returns true when the consumer has no interest and its last activity is older than olderThan.
+func consumerIsStale(info *nats.ConsumerInfo, olderThan time.Duration) bool {
+	if info.PushBound || info.NumWaiting > 0 {
+		return false
+	}
+
+	lastActive := info.Created
+
+	for _, t := range []*time.Time{info.Delivered.Last, info.AckFloor.Last} {
+		if t != nil && t.After(lastActive) {
+			lastActive = *t
+		}
+	}
+
+	return time.Since(lastActive) > olderThan
+}
+
+// fullSubject returns the subject suffix prepended with the configured PublisherSubjectPrefix.
+func (n *NatsJetstream) fullSubject(subjectSuffix string) string {
+	return strings.Join(
+		[]string{
+			n.parameters.PublisherSubjectPrefix,
+			subjectSuffix,
+		}, ".")
 }
 
 func injectOtelTraceContext(ctx context.Context, msg *nats.Msg) {
@@ -309,11 +713,19 @@ func injectOtelTraceContext(ctx context.Context, msg *nats.Msg) {
 }
 
 // Subscribe to all configured SubscribeSubjects
+//
+// With a SubscribeWaitTimeout, it waits for the streams storing the subjects to be created first.
+// The returned channel is closed once the stream is closed or drained, subscribers
+// ranging over it return then.
 func (n *NatsJetstream) Subscribe(ctx context.Context) (MsgCh, error) {
 	if n.jsctx == nil {
 		return nil, errors.Wrap(ErrNatsJetstreamAddConsumer, "Jetstream context is not setup")
 	}
 
+	if n.isClosed() {
+		return nil, ErrNatsClosed
+	}
+
 	// Subscribe as a pull based subscriber
 	if n.parameters.Consumer != nil && n.parameters.Consumer.Pull {
 		if err := n.subscribeAsPull(ctx); err != nil {
@@ -321,8 +733,17 @@ func (n *NatsJetstream) Subscribe(ctx context.Context) (MsgCh, error) {
 		}
 	}
 
+	waitCtx, cancel := n.subscribeWait(ctx)
+	defer cancel()
+
 	// regular Async subscription
 	for _, subject := range n.parameters.SubscribeSubjects {
+		if waitCtx != nil {
+			if _, err := n.WaitForStream(waitCtx, subject); err != nil {
+				return nil, err
+			}
+		}
+
 		subscription, err := n.jsctx.Subscribe(subject, n.subscriptionCallback, nats.Durable(n.parameters.AppName))
 		if err != nil {
 			return nil, errors.Wrap(ErrSubscription, err.Error()+": "+subject)
@@ -360,11 +781,35 @@ func (n *NatsJetstream) subscribeAsPull(_ context.Context) error {
 
 // PullMsg pulls up to the batch count of messages from each pull-based subscription to
 // subjects on the stream.
-func (n *NatsJetstream) PullMsg(_ context.Context, batch int) ([]Message, error) {
+//
+// While consumption is paused, PullMsg blocks until it is resumed or the context is done.
+// In the CooperativeFetch mode it also blocks while this instance holds its share of messages.
+// Once the stream is closed ErrNatsClosed is returned, ErrNatsDraining when it was drained.
+//
+// Fetches wait for messages until the context deadline, or the consumer FetchMaxWait when the
+// context has none, and nats.ErrTimeout is returned when no messages arrived. The context error
+// is returned when it's cancelled or its deadline passes.
+func (n *NatsJetstream) PullMsg(ctx context.Context, batch int) ([]Message, error) {
 	if n.jsctx == nil {
 		return nil, errors.Wrap(ErrNatsJetstreamAddConsumer, "Jetstream context is not setup")
 	}
 
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := n.waitResumed(ctx); err != nil {
+		return nil, n.closedErr(err)
+	}
+
+	if n.isDraining() {
+		return nil, ErrNatsDraining
+	}
+
+	if n.isClosed() {
+		return nil, ErrNatsClosed
+	}
+
 	var hasPullSubscription bool
 	var msgs []Message
 
@@ -375,11 +820,40 @@ func (n *NatsJetstream) PullMsg(_ context.Context, batch int) ([]Message, error)
 
 		hasPullSubscription = true
 
-		subMsgs, err := subscription.Fetch(batch)
+		fetch := batch
+		if n.cooperative() {
+			var err error
+			if fetch, err = n.cooperativeBatch(ctx, batch); err != nil {
+				return nil, err
+			}
+		}
+
+		subMsgs, err := n.fetch(ctx, subscription, fetch)
 		if err != nil {
+			if n.isDraining() || n.isClosed() {
+				return nil, n.closedErr(err)
+			}
+
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
 			return nil, errors.Wrap(err, ErrNatsMsgPull.Error())
 		}
-		msgs = append(msgs, msgIfFromNats(subMsgs...)...)
+		for _, m := range subMsgs {
+			if n.skipExpired(m) || n.skipFiltered(m, n.consumerFilter) {
+				continue
+			}
+
+			nm := n.newMsg(m)
+			if n.cooperative() {
+				n.holdCooperative(nm)
+			}
+
+			go n.watchAckDeadline(nm)
+
+			msgs = append(msgs, nm)
+		}
 	}
 
 	if !hasPullSubscription {
@@ -389,15 +863,146 @@ func (n *NatsJetstream) PullMsg(_ context.Context, batch int) ([]Message, error)
 	return msgs, nil
 }
 
+// fetch fetches up to batch messages from the subscription, waiting until the context deadline or
+// the FetchMaxWait when it has none. nats.ErrTimeout is returned once the FetchMaxWait passed.
+func (n *NatsJetstream) fetch(ctx context.Context, subscription *nats.Subscription, batch int) ([]*nats.Msg, error) {
+	if _, ok := ctx.Deadline(); ok {
+		return subscription.Fetch(batch, nats.Context(ctx))
+	}
+
+	fetchCtx, cancel := context.WithTimeout(ctx, n.fetchMaxWait())
+	defer cancel()
+
+	msgs, err := subscription.Fetch(batch, nats.Context(fetchCtx))
+	if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+		return msgs, nats.ErrTimeout
+	}
+
+	return msgs, err
+}
+
+func (n *NatsJetstream) fetchMaxWait() time.Duration {
+	if n.parameters == nil || n.parameters.Consumer == nil || n.parameters.Consumer.FetchMaxWait == 0 {
+		return DefaultFetchMaxWait
+	}
+
+	return n.parameters.Consumer.FetchMaxWait
+}
+
 func (n *NatsJetstream) subscriptionCallback(msg *nats.Msg) {
+	defer recoverCallback(msg)
+
+	if !n.enterCallback() {
+		_ = msg.Nak()
+		return
+	}
+
+	defer n.callbacks.Done()
+
+	n.handOut(msg, n.subscriberCh, nil, n.consumerFilter, nil)
+}
+
+// handOut sends the message on the channel for a subscriber to read, the message is Nak'ed
+// when no subscriber read it within the SubscriptionCallbackTimeout, or once the stream is
+// drained or the done channel is closed. Expired messages and those not matching the filter are skipped.
+// processed is called once the message was acked or terminated, skipped messages included.
+func (n *NatsJetstream) handOut(msg *nats.Msg, ch MsgCh, done <-chan struct{}, filter MessageFilter, processed func()) {
+	if n.ConsumptionPaused() {
+		_ = msg.NakWithDelay(n.nakDelay())
+		return
+	}
+
+	if n.skipExpired(msg) || n.skipFiltered(msg, filter) {
+		if processed != nil {
+			processed()
+		}
+
+		return
+	}
+
+	nm := n.newMsg(msg)
+	nm.processed = processed
+
 	select {
-	case <-time.After(subscriptionCallbackTimeout):
-		_ = msg.NakWithDelay(nakDelay)
-	case n.subscriberCh <- &natsMsg{msg: msg}:
+	case <-n.getClock().After(n.subscriptionCallbackTimeout()):
+		_ = msg.NakWithDelay(n.nakDelay())
+	case <-n.drainCh:
+		_ = msg.Nak()
+	case <-done:
+		_ = msg.Nak()
+	case ch <- nm:
+		go n.watchAckDeadline(nm)
 	}
 }
 
+// SetClock sets the Clock used for timeouts, this is meant for tests.
+func (n *NatsJetstream) SetClock(clock Clock) {
+	n.clock = clock
+}
+
+// SetNakDelay sets how long the redelivery of a message not read by a subscriber is delayed.
+func (n *NatsJetstream) SetNakDelay(d time.Duration) error {
+	if err := validateNakDelay(d); err != nil {
+		return err
+	}
+
+	n.ensureParameters().NakDelay = d
+
+	return nil
+}
+
+// SetSubscriptionCallbackTimeout sets how long a message waits for a subscriber to read it before it is Nak'ed.
+func (n *NatsJetstream) SetSubscriptionCallbackTimeout(d time.Duration) error {
+	if err := validateSubscriptionCallbackTimeout(d); err != nil {
+		return err
+	}
+
+	n.ensureParameters().SubscriptionCallbackTimeout = d
+
+	return nil
+}
+
+func (n *NatsJetstream) ensureParameters() *NatsOptions {
+	if n.parameters == nil {
+		n.parameters = &NatsOptions{}
+	}
+
+	return n.parameters
+}
+
+func (n *NatsJetstream) getClock() Clock {
+	if n.clock == nil {
+		return realClock{}
+	}
+
+	return n.clock
+}
+
+func (n *NatsJetstream) nakDelay() time.Duration {
+	if n.parameters == nil || n.parameters.NakDelay == 0 {
+		return nakDelay
+	}
+
+	return n.parameters.NakDelay
+}
+
+func (n *NatsJetstream) subscriptionCallbackTimeout() time.Duration {
+	if n.parameters == nil || n.parameters.SubscriptionCallbackTimeout == 0 {
+		return subscriptionCallbackTimeout
+	}
+
+	return n.parameters.SubscriptionCallbackTimeout
+}
+
+// ackSync returns true when messages are to be acked with double-ack semantics.
+func (n *NatsJetstream) ackSync() bool {
+	return n.parameters != nil && n.parameters.Consumer != nil && n.parameters.Consumer.AckSync
+}
+
 // Close drains any subscriptions and closes the NATS Jetstream connection.
+//
+// The channel returned by Subscribe is closed, messages not read by a subscriber yet are
+// Nak'ed for redelivery and PullMsg returns ErrNatsClosed.
 func (n *NatsJetstream) Close() error {
 	var errs error
 
@@ -407,6 +1012,8 @@ func (n *NatsJetstream) Close() error {
 		}
 	}
 
+	n.closeSubscriberCh()
+
 	if n.conn != nil {
 		n.conn.Close()
 	}
@@ -0,0 +1,129 @@
+package events
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+)
+
+// ackWatchdog tracks a message handed to a subscriber until it is acked, nak'ed or terminated.
+type ackWatchdog struct {
+	done     chan struct{}
+	doneOnce sync.Once
+	progress chan struct{}
+}
+
+func newAckWatchdog() *ackWatchdog {
+	return &ackWatchdog{
+		done:     make(chan struct{}),
+		progress: make(chan struct{}, 1),
+	}
+}
+
+// resolve stops the watchdog, the message won't be redelivered.
+func (w *ackWatchdog) resolve() {
+	if w == nil {
+		return
+	}
+
+	w.doneOnce.Do(func() { close(w.done) })
+}
+
+// inProgress restarts the watchdog, the server resets the AckWait when a message is marked in progress.
+func (w *ackWatchdog) inProgress() {
+	if w == nil {
+		return
+	}
+
+	select {
+	case w.progress <- struct{}{}:
+	default:
+	}
+}
+
+// AckDeadlineWarnings returns the number of messages which were not acked, nak'ed or terminated
+// within the consumer AckDeadlineWarning of their AckWait.
+func (n *NatsJetstream) AckDeadlineWarnings() uint64 {
+	return atomic.LoadUint64(&n.ackDeadlineWarnings)
+}
+
+// SetLogger sets the logger used to report messages nearing their AckWait.
+func (n *NatsJetstream) SetLogger(logger *zap.Logger) {
+	n.ensureParameters().Logger = logger
+}
+
+// ackWatchdogAfter returns how long a message may stay unresolved before a warning is logged,
+// zero when the watchdog is disabled.
+func (n *NatsJetstream) ackWatchdogAfter() time.Duration {
+	if n.parameters == nil || n.parameters.Consumer == nil || n.parameters.Consumer.AckDeadlineWarning == 0 {
+		return 0
+	}
+
+	return n.parameters.Consumer.AckWait - n.parameters.Consumer.AckDeadlineWarning
+}
+
+func (n *NatsJetstream) logger() *zap.Logger {
+	if n.parameters == nil || n.parameters.Logger == nil {
+		return zap.L()
+	}
+
+	return n.parameters.Logger
+}
+
+// newMsg wraps a NATS message for subscribers, starting its ack watchdog when enabled.
+func (n *NatsJetstream) newMsg(msg *nats.Msg) *natsMsg {
+	nm := &natsMsg{msg: msg, ackSync: n.ackSync(), reasons: n.reasonStore()}
+	nm.withFailureReason()
+
+	if n.ackWatchdogAfter() > 0 {
+		nm.watchdog = newAckWatchdog()
+	}
+
+	return nm
+}
+
+// watchAckDeadline warns when the message isn't resolved before it nears its AckWait.
+func (n *NatsJetstream) watchAckDeadline(nm *natsMsg) {
+	if nm.watchdog == nil {
+		return
+	}
+
+	after := n.ackWatchdogAfter()
+	clock := n.getClock()
+	started := clock.Now()
+
+	for {
+		select {
+		case <-nm.watchdog.done:
+			return
+		case <-nm.watchdog.progress:
+			started = clock.Now()
+			continue
+		case <-clock.After(after):
+		}
+
+		atomic.AddUint64(&n.ackDeadlineWarnings, 1)
+
+		fields := []zap.Field{
+			zap.String("subject", nm.msg.Subject),
+			zap.Duration("elapsed", clock.Now().Sub(started)),
+			zap.Duration("ack_wait", n.parameters.Consumer.AckWait),
+		}
+
+		if md, err := nm.msg.Metadata(); err == nil {
+			fields = append(fields,
+				zap.String("stream", md.Stream),
+				zap.String("consumer", md.Consumer),
+				zap.Uint64("stream_sequence", md.Sequence.Stream),
+				zap.Uint64("num_delivered", md.NumDelivered),
+			)
+		}
+
+		n.logger().Warn("message nearing ack deadline, it will be redelivered unless resolved", fields...)
+
+		return
+	}
+}
@@ -0,0 +1,91 @@
+//nolint:all
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	natsTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+func TestAckDeadlineWatchdog(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName: "TestAckDeadlineWatchdog",
+		Stream: &NatsStreamOptions{
+			Name:      "test_stream",
+			Subjects:  []string{"pre.test"},
+			Retention: "workQueue",
+		},
+		Consumer: &NatsConsumerOptions{
+			Name:               "test_consumer",
+			Pull:               true,
+			SubscribeSubjects:  []string{"pre.test"},
+			FilterSubject:      "pre.test",
+			AckWait:            time.Minute,
+			AckDeadlineWarning: 10 * time.Second,
+		},
+		PublisherSubjectPrefix: "pre",
+	}
+	require.NoError(t, njs.parameters.Consumer.validate())
+	require.NoError(t, njs.addStream())
+	require.NoError(t, njs.addConsumer())
+
+	core, logs := observer.New(zapcore.WarnLevel)
+	njs.SetLogger(zap.New(core))
+
+	clock := &fakeClock{now: time.Now(), fire: make(chan time.Time), requested: make(chan time.Duration, 1)}
+	njs.SetClock(clock)
+
+	_, err := njs.Subscribe(context.TODO())
+	require.NoError(t, err)
+
+	require.NoError(t, njs.Publish(context.TODO(), "test", []byte("slow")))
+	require.NoError(t, njs.Publish(context.TODO(), "test", []byte("in progress")))
+
+	// the first message is left unresolved until it nears the AckWait.
+	msgs, err := njs.PullMsg(context.TODO(), 1)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+
+	require.Equal(t, 50*time.Second, <-clock.requested)
+	clock.fire <- clock.now.Add(50 * time.Second)
+
+	require.Eventually(t, func() bool { return njs.AckDeadlineWarnings() == 1 }, time.Second, 10*time.Millisecond)
+
+	entries := logs.All()
+	require.Len(t, entries, 1)
+
+	fields := entries[0].ContextMap()
+	assert.Equal(t, "pre.test", fields["subject"])
+	assert.Equal(t, uint64(1), fields["stream_sequence"])
+	assert.Equal(t, "test_consumer", fields["consumer"])
+
+	require.NoError(t, msgs[0].Ack())
+
+	// marking the second message in progress restarts the watchdog, acking it stops it.
+	msgs, err = njs.PullMsg(context.TODO(), 1)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+
+	require.Equal(t, 50*time.Second, <-clock.requested)
+	require.NoError(t, msgs[0].InProgress())
+	require.Equal(t, 50*time.Second, <-clock.requested)
+	require.NoError(t, msgs[0].Ack())
+
+	assert.Equal(t, uint64(1), njs.AckDeadlineWarnings())
+	assert.Len(t, logs.All(), 1)
+}
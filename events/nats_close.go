@@ -0,0 +1,72 @@
+package events
+
+import (
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// ErrNatsClosed is returned when pulling messages once the NATS Jetstream was closed or drained.
+var ErrNatsClosed = errors.New("NATS Jetstream is closed")
+
+// enterCallback registers a subscription callback about to hand a message to subscribers,
+// it returns false once the subscriber channel is being closed.
+func (n *NatsJetstream) enterCallback() bool {
+	n.callbacksMu.Lock()
+	defer n.callbacksMu.Unlock()
+
+	if n.subscriberChClosed {
+		return false
+	}
+
+	n.callbacks.Add(1)
+
+	return true
+}
+
+// closeSubscriberCh stops handing out messages and closes the subscriber channel, and those
+// returned by SubscribeSubject, once no subscription callback is sending on them, subscribers
+// ranging over them then return.
+func (n *NatsJetstream) closeSubscriberCh() {
+	atomic.StoreInt32(&n.closed, 1)
+
+	n.callbacksMu.Lock()
+	alreadyClosed := n.subscriberChClosed
+	n.subscriberChClosed = true
+	subjectSubscriptions := n.subjectSubscriptions
+	n.subjectSubscriptions = nil
+	n.callbacksMu.Unlock()
+
+	if alreadyClosed {
+		return
+	}
+
+	// callbacks waiting on a subscriber Nak their message and return.
+	n.closeDrainCh()
+
+	n.callbacks.Wait()
+
+	if n.subscriberCh != nil {
+		close(n.subscriberCh)
+	}
+
+	for _, s := range subjectSubscriptions {
+		s.stop()
+	}
+}
+
+func (n *NatsJetstream) isClosed() bool {
+	return atomic.LoadInt32(&n.closed) == 1
+}
+
+// closedErr returns the error reported once the stream is drained or closed, err otherwise.
+func (n *NatsJetstream) closedErr(err error) error {
+	switch {
+	case n.isDraining():
+		return ErrNatsDraining
+	case n.isClosed():
+		return ErrNatsClosed
+	default:
+		return err
+	}
+}
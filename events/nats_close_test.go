@@ -0,0 +1,154 @@
+//nolint:all
+package events
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	natsTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+func TestCloseClosesSubscriberCh(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+
+	njs.parameters = &NatsOptions{
+		AppName: "TestCloseClosesSubscriberCh",
+		Stream: &NatsStreamOptions{
+			Name:      "test_stream",
+			Subjects:  []string{"pre.test"},
+			Retention: "limits",
+		},
+		SubscribeSubjects:      []string{"pre.test"},
+		PublisherSubjectPrefix: "pre",
+	}
+	require.NoError(t, njs.addStream())
+
+	msgCh, err := njs.Subscribe(context.TODO())
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, njs.Publish(context.TODO(), "test", []byte("1")))
+	}
+
+	received := make(chan struct{})
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for msg := range msgCh {
+			_ = msg.Ack()
+
+			select {
+			case <-received:
+			default:
+				close(received)
+			}
+		}
+	}()
+
+	<-received
+
+	require.NoError(t, njs.Close())
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("subscriber still blocked on the channel after Close")
+	}
+
+	// closing again is a no-op
+	_ = njs.Close()
+
+	_, err = njs.Subscribe(context.TODO())
+	assert.ErrorIs(t, err, ErrNatsClosed)
+}
+
+func TestPullMsgAfterClose(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+
+	njs.parameters = &NatsOptions{
+		AppName: "TestPullMsgAfterClose",
+		Stream: &NatsStreamOptions{
+			Name:      "test_stream",
+			Subjects:  []string{"pre.test"},
+			Retention: "limits",
+		},
+		Consumer: &NatsConsumerOptions{
+			Name:              "test_consumer",
+			Pull:              true,
+			SubscribeSubjects: []string{"pre.test"},
+			FilterSubject:     "pre.test",
+		},
+		PublisherSubjectPrefix: "pre",
+	}
+	require.NoError(t, njs.addStream())
+	require.NoError(t, njs.addConsumer())
+
+	_, err := njs.Subscribe(context.TODO())
+	require.NoError(t, err)
+
+	require.NoError(t, njs.Close())
+
+	_, err = njs.PullMsg(context.TODO(), 1)
+	assert.ErrorIs(t, err, ErrNatsClosed)
+}
+
+func TestCloseInterleavedWithCallbacks(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	require.NoError(t, njs.SetSubscriptionCallbackTimeout(time.Minute))
+
+	var wg sync.WaitGroup
+
+	// callbacks keep handing out messages while the stream is closed and the
+	// subscriber reads, none of them may send on the closed channel.
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			njs.subscriptionCallback(&nats.Msg{Subject: "test"})
+		}()
+	}
+
+	readerDone := make(chan struct{})
+
+	go func() {
+		defer close(readerDone)
+
+		for range njs.subscriberCh {
+		}
+	}()
+
+	require.NoError(t, njs.Close())
+
+	wg.Wait()
+
+	// callbacks after close return right away
+	njs.subscriptionCallback(&nats.Msg{Subject: "test"})
+
+	select {
+	case <-readerDone:
+	case <-time.After(time.Second):
+		t.Fatal("subscriber still blocked on the channel after Close")
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/nats-io/nats.go"
 	"github.com/pkg/errors"
+	"go.uber.org/zap"
 	"golang.org/x/exp/slices"
 )
 
@@ -21,13 +22,30 @@ const (
 	// subscription callback timeout
 	subscriptionCallbackTimeout = 5 * time.Second
 
+	// subscription callback timeout upper bound
+	maxSubscriptionCallbackTimeout = 5 * time.Minute
+
 	// Nak message with delay
 	nakDelay = 5 * time.Minute
 
+	// Nak delay upper bound
+	maxNakDelay = 24 * time.Hour
+
 	// consumer defaults
 	consumerAckWait       = 5 * time.Minute
 	consumerMaxAckPending = 100
 	consumerDeliverPolicy = nats.DeliverAllPolicy
+
+	// consumer ack policies
+	consumerAckPolicyExplicit = "explicit"
+	consumerAckPolicyAll      = "all"
+
+	// consumer deliver policies
+	consumerDeliverAll             = "all"
+	consumerDeliverNew             = "new"
+	consumerDeliverLast            = "last"
+	consumerDeliverByStartSequence = "byStartSequence"
+	consumerDeliverByStartTime     = "byStartTime"
 )
 
 // NatsOptions holds the configuration parameters to setup NATS Jetstream.
@@ -67,8 +85,44 @@ type NatsOptions struct {
 	// Setting Stream parameters will cause a NATS stream to be added.
 	Stream *NatsStreamOptions `mapstructure:"stream"`
 
+	// StreamTemplate defines the streams created for each resource type with EnsureStreamFor.
+	StreamTemplate *NatsStreamTemplate `mapstructure:"stream_template"`
+
 	// KVReplicationFactor sets the number of copies in a NATS clustered environment
 	KVReplicationFactor int `mapstructure:"kv_replication"`
+
+	// NakDelay is how long the redelivery of a message is delayed when no subscriber
+	// read it within the SubscriptionCallbackTimeout, defaults to 5 minutes.
+	NakDelay time.Duration `mapstructure:"nak_delay"`
+
+	// SubscriptionCallbackTimeout is how long a message received on a push based subscription
+	// waits for a subscriber to read it from the MsgCh before it is Nak'ed, defaults to 5 seconds.
+	SubscriptionCallbackTimeout time.Duration `mapstructure:"subscription_callback_timeout"`
+
+	// ValidatePublishSubjects verifies the subject of published messages is stored by the configured stream,
+	// or any stream when none is configured, publishes to other subjects return ErrPublishSubjectNotInStream.
+	// Each subject is looked up once.
+	ValidatePublishSubjects bool `mapstructure:"validate_publish_subjects"`
+
+	// SubscribeWaitTimeout is how long subscribing waits for the streams storing the subjects to be
+	// created, e.g. by their producer, instead of failing right away. The streams are looked up with
+	// an exponential backoff, ErrStreamNotReady is returned once the timeout elapsed. Subscribing
+	// doesn't wait when not set.
+	SubscribeWaitTimeout time.Duration `mapstructure:"subscribe_wait_timeout"`
+
+	// PreflightTimeout is how long Open may spend checking JetStream is available and the credentials
+	// are permitted to publish under the PublisherSubjectPrefix and to bind to the Consumer, so they
+	// fail at startup with ErrPreflightJetStream, ErrPreflightPublish or ErrPreflightConsumer instead of
+	// at the first publish. The checks need the server to be reachable and are skipped when not set.
+	PreflightTimeout time.Duration `mapstructure:"preflight_timeout"`
+
+	// DevLogBroker has NewStream return a LogBroker writing the published messages to stderr instead of
+	// connecting to NATS, for local development. The published messages matching the SubscribeSubjects
+	// are handed to the subscribers.
+	DevLogBroker bool `mapstructure:"dev_log_broker"`
+
+	// Logger reports messages nearing their AckWait, defaults to the global zap logger.
+	Logger *zap.Logger `mapstructure:"-"`
 }
 
 // NatsConsumerOptions is the parameters for the NATS consumer configuration.
@@ -88,6 +142,59 @@ type NatsConsumerOptions struct {
 
 	MaxAckPending int `mapstructure:"max_ack_pending"`
 
+	// CooperativeFetch shares the MaxAckPending of a pull consumer fairly between the instances fetching
+	// from it, each instance holds at most MaxAckPending divided by the number of instances unresolved
+	// messages and PullMsg fetches smaller batches or blocks once it holds its share.
+	// The instances are counted with the InstanceCounter set with SetInstanceCounter, without one
+	// the instance holds up to MaxAckPending messages.
+	CooperativeFetch bool `mapstructure:"cooperative_fetch"`
+
+	// InstanceCountRefresh is how often the instance count is refreshed, defaults to DefaultInstanceCountRefresh.
+	InstanceCountRefresh time.Duration `mapstructure:"instance_count_refresh"`
+
+	// FetchMaxWait is how long PullMsg waits for messages when the context passed has no deadline,
+	// defaults to DefaultFetchMaxWait.
+	FetchMaxWait time.Duration `mapstructure:"fetch_max_wait"`
+
+	// AckPolicy is either "explicit" (the default) where each message is acked individually,
+	// or "all" where acking a message acknowledges all messages before it.
+	//
+	// The "all" policy reduces the number of acks sent, but is only safe when messages are
+	// processed in order, e.g. a single subscriber with MaxAckPending set to 1, otherwise
+	// an ack for a later message will acknowledge earlier messages still being processed.
+	AckPolicy string `mapstructure:"ack_policy"`
+
+	// DeliverPolicy is where a new consumer starts in the stream, one of "all" (the default) to catch
+	// up on all the messages, "new" for the messages published once it was created, "last" for the
+	// last message, "byStartSequence" from the StartSequence or "byStartTime" from the StartTime.
+	//
+	// The server doesn't allow changing the DeliverPolicy and start of an existing consumer.
+	DeliverPolicy string `mapstructure:"deliver_policy"`
+
+	// StartSequence is the stream sequence a consumer with the "byStartSequence" DeliverPolicy starts from.
+	StartSequence uint64 `mapstructure:"start_sequence"`
+
+	// StartTime is the time a consumer with the "byStartTime" DeliverPolicy starts from.
+	StartTime time.Time `mapstructure:"start_time"`
+
+	// AckSync enables double-ack semantics, acking a message waits for the server
+	// to confirm the ack was received. This guarantees the message won't be redelivered once
+	// Ack() returns without error at the cost of a round trip to the server for each ack.
+	AckSync bool `mapstructure:"ack_sync"`
+
+	// AckDeadlineWarning enables the ack watchdog, a warning is logged when a message handed to a
+	// subscriber is not acked, nak'ed or terminated this long before its AckWait expires, after which
+	// the server redelivers the message and it is likely to be processed twice.
+	//
+	// Marking the message InProgress restarts the watchdog. When not set, no warning is logged.
+	AckDeadlineWarning time.Duration `mapstructure:"ack_deadline_warning"`
+
+	// InactiveThreshold has the server delete the consumer once it had no subscribers
+	// for this long, this keeps abandoned durables from holding on to the stream retention.
+	//
+	// When not set, the consumer is kept until deleted.
+	InactiveThreshold time.Duration `mapstructure:"inactive_threshold"`
+
 	// Setting the FilterSubject turns this consumer into a push based consumer,
 	// With no filter subject, the consumer is a pull based consumer.
 	//
@@ -95,8 +202,21 @@ type NatsConsumerOptions struct {
 	// and should be unique between consumers on the stream.
 	FilterSubject string `mapstructure:"filter_subject"`
 
+	// FilterSubjects filters the consumer on several subjects, it can't be set along with the FilterSubject.
+	// Consumers filtered on more than one subject require nats-server 2.10 or later.
+	//
+	// When neither are set on a pull consumer, the filter is derived from the SubscribeSubjects.
+	// The SubscribeSubjects of pull consumers must be delivered by the filter, with a FilterSubject
+	// they must be equal to it, with FilterSubjects each has to match one of them.
+	FilterSubjects []string `mapstructure:"filter_subjects"`
+
 	// Subscribe to these subjects through this consumer.
 	SubscribeSubjects []string `mapstructure:"subscribe_subjects"`
+
+	// HeaderFilters skips the messages not matching the headers, each header has to be set to one
+	// of its values, or to any value when it has none. Skipped messages are acked without being
+	// handed out, see NatsJetstream.FilteredMessages.
+	HeaderFilters map[string][]string `mapstructure:"header_filters"`
 }
 
 // NatsStreamOptions are parameters to setup a NATS stream.
@@ -122,6 +242,47 @@ type NatsStreamOptions struct {
 	//
 	// https://docs.nats.io/using-nats/developer/develop_jetstream/model_deep_dive#stream-limits-retention-and-policy
 	Retention string `mapstructure:"retention"`
+
+	// MaxAge is how long messages are kept on the stream, unlimited when not set. Dedicated
+	// streams for short-lived events, see WithTTL, drop them once they're stale.
+	MaxAge time.Duration `mapstructure:"max_age"`
+
+	// SubjectTransform rewrites the subject of the messages stored on the stream, this requires nats-server 2.10 or later.
+	//
+	// https://docs.nats.io/nats-concepts/subject_mapping
+	SubjectTransform *NatsSubjectTransform `mapstructure:"subject_transform"`
+}
+
+func (c *NatsConsumerOptions) natsDeliverPolicy() nats.DeliverPolicy {
+	switch c.DeliverPolicy {
+	case consumerDeliverNew:
+		return nats.DeliverNewPolicy
+	case consumerDeliverLast:
+		return nats.DeliverLastPolicy
+	case consumerDeliverByStartSequence:
+		return nats.DeliverByStartSequencePolicy
+	case consumerDeliverByStartTime:
+		return nats.DeliverByStartTimePolicy
+	default:
+		return consumerDeliverPolicy
+	}
+}
+
+// natsStartTime returns the StartTime of the consumer, nil unless it is set.
+func (c *NatsConsumerOptions) natsStartTime() *time.Time {
+	if c.StartTime.IsZero() {
+		return nil
+	}
+
+	return &c.StartTime
+}
+
+func (c *NatsConsumerOptions) natsAckPolicy() nats.AckPolicy {
+	if c.AckPolicy == consumerAckPolicyAll {
+		return nats.AckAllPolicy
+	}
+
+	return consumerAckPolicy
 }
 
 func (o *NatsOptions) validate() error {
@@ -141,6 +302,12 @@ func (o *NatsOptions) validate() error {
 		}
 	}
 
+	if o.StreamTemplate != nil {
+		if err := o.StreamTemplate.validate(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -165,6 +332,42 @@ func (o *NatsOptions) validatePrereqs() error {
 		o.ConnectTimeout = connectTimeout
 	}
 
+	if o.NakDelay == 0 {
+		o.NakDelay = nakDelay
+	}
+
+	if err := validateNakDelay(o.NakDelay); err != nil {
+		return err
+	}
+
+	if o.SubscribeWaitTimeout < 0 {
+		return errors.Wrap(ErrNatsConfig, "SubscribeWaitTimeout must not be negative")
+	}
+
+	if o.PreflightTimeout < 0 {
+		return errors.Wrap(ErrNatsConfig, "PreflightTimeout must not be negative")
+	}
+
+	if o.SubscriptionCallbackTimeout == 0 {
+		o.SubscriptionCallbackTimeout = subscriptionCallbackTimeout
+	}
+
+	return validateSubscriptionCallbackTimeout(o.SubscriptionCallbackTimeout)
+}
+
+func validateNakDelay(d time.Duration) error {
+	if d < 0 || d > maxNakDelay {
+		return errors.Wrap(ErrNatsConfig, "NakDelay must be between 0 and "+maxNakDelay.String())
+	}
+
+	return nil
+}
+
+func validateSubscriptionCallbackTimeout(d time.Duration) error {
+	if d <= 0 || d > maxSubscriptionCallbackTimeout {
+		return errors.Wrap(ErrNatsConfig, "SubscriptionCallbackTimeout must be greater than 0 and at most "+maxSubscriptionCallbackTimeout.String())
+	}
+
 	return nil
 }
 
@@ -185,6 +388,14 @@ func (s *NatsStreamOptions) validate() error {
 		return errors.Wrap(ErrNatsConfig, "stream parameters require one or more Subjects to associate with the stream")
 	}
 
+	if s.MaxAge < 0 {
+		return errors.Wrap(ErrNatsConfig, "stream parameters MaxAge must not be negative")
+	}
+
+	if s.SubjectTransform != nil {
+		return s.SubjectTransform.validate(s.Subjects)
+	}
+
 	return nil
 }
 
@@ -201,5 +412,66 @@ func (c *NatsConsumerOptions) validate() error {
 		c.MaxAckPending = consumerMaxAckPending
 	}
 
+	if c.AckDeadlineWarning < 0 || c.AckDeadlineWarning >= c.AckWait {
+		return errors.Wrap(ErrNatsConfig, "consumer parameters require an AckDeadlineWarning shorter than the AckWait")
+	}
+
+	if c.CooperativeFetch && !c.Pull {
+		return errors.Wrap(ErrNatsConfig, "consumer parameters require Pull for CooperativeFetch")
+	}
+
+	if c.InstanceCountRefresh < 0 {
+		return errors.Wrap(ErrNatsConfig, "consumer parameters require a positive InstanceCountRefresh")
+	}
+
+	if c.FetchMaxWait < 0 {
+		return errors.Wrap(ErrNatsConfig, "consumer parameters require a positive FetchMaxWait")
+	}
+
+	if c.AckPolicy == "" {
+		c.AckPolicy = consumerAckPolicyExplicit
+	}
+
+	if !slices.Contains([]string{consumerAckPolicyExplicit, consumerAckPolicyAll}, c.AckPolicy) {
+		return errors.Wrap(ErrNatsConfig, "consumer parameters require a valid AckPolicy")
+	}
+
+	if _, ok := c.HeaderFilters[""]; ok {
+		return errors.Wrap(ErrNatsConfig, "consumer parameters require named HeaderFilters")
+	}
+
+	if err := c.validateDeliverPolicy(); err != nil {
+		return err
+	}
+
+	return c.validateFilterSubjects()
+}
+
+// validateDeliverPolicy verifies the StartSequence and StartTime are set along with their DeliverPolicy only.
+func (c *NatsConsumerOptions) validateDeliverPolicy() error {
+	if c.DeliverPolicy == "" {
+		c.DeliverPolicy = consumerDeliverAll
+	}
+
+	policies := []string{
+		consumerDeliverAll,
+		consumerDeliverNew,
+		consumerDeliverLast,
+		consumerDeliverByStartSequence,
+		consumerDeliverByStartTime,
+	}
+
+	if !slices.Contains(policies, c.DeliverPolicy) {
+		return errors.Wrap(ErrNatsConfig, "consumer parameters require a valid DeliverPolicy")
+	}
+
+	if (c.DeliverPolicy == consumerDeliverByStartSequence) != (c.StartSequence > 0) {
+		return errors.Wrap(ErrNatsConfig, "consumer parameters require a StartSequence with the byStartSequence DeliverPolicy only")
+	}
+
+	if (c.DeliverPolicy == consumerDeliverByStartTime) != !c.StartTime.IsZero() {
+		return errors.Wrap(ErrNatsConfig, "consumer parameters require a StartTime with the byStartTime DeliverPolicy only")
+	}
+
 	return nil
 }
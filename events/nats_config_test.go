@@ -16,6 +16,10 @@ func TestNatsOptions_ValidatePrereqs(t *testing.T) {
 		StreamPass     string
 		CredsFile      string
 		ConnectTimeout time.Duration
+		NakDelay       time.Duration
+		CallbackTO     time.Duration
+		SubscribeWait  time.Duration
+		Preflight      time.Duration
 	}
 
 	tests := []struct {
@@ -52,7 +56,37 @@ func TestNatsOptions_ValidatePrereqs(t *testing.T) {
 			"Default connect timeout is set",
 			fields{AppName: "foo", URL: "nats://nats:4222", StreamUser: "foo", StreamPass: "bar", ConnectTimeout: 200 * time.Millisecond},
 			"",
-			&NatsOptions{AppName: "foo", URL: "nats://nats:4222", StreamUser: "foo", StreamPass: "bar", ConnectTimeout: 200 * time.Millisecond},
+			&NatsOptions{AppName: "foo", URL: "nats://nats:4222", StreamUser: "foo", StreamPass: "bar", ConnectTimeout: 200 * time.Millisecond, NakDelay: nakDelay, SubscriptionCallbackTimeout: subscriptionCallbackTimeout},
+		},
+		{
+			"Nak delay and callback timeout are kept",
+			fields{AppName: "foo", URL: "nats://nats:4222", StreamUser: "foo", StreamPass: "bar", NakDelay: time.Second, CallbackTO: time.Millisecond},
+			"",
+			&NatsOptions{AppName: "foo", URL: "nats://nats:4222", StreamUser: "foo", StreamPass: "bar", ConnectTimeout: connectTimeout, NakDelay: time.Second, SubscriptionCallbackTimeout: time.Millisecond},
+		},
+		{
+			"Nak delay out of bounds",
+			fields{AppName: "foo", URL: "nats://nats:4222", StreamUser: "foo", StreamPass: "bar", NakDelay: 48 * time.Hour},
+			"NakDelay must be",
+			nil,
+		},
+		{
+			"Callback timeout out of bounds",
+			fields{AppName: "foo", URL: "nats://nats:4222", StreamUser: "foo", StreamPass: "bar", CallbackTO: -time.Second},
+			"SubscriptionCallbackTimeout must be",
+			nil,
+		},
+		{
+			"Negative subscribe wait timeout",
+			fields{AppName: "foo", URL: "nats://nats:4222", StreamUser: "foo", StreamPass: "bar", SubscribeWait: -time.Second},
+			"SubscribeWaitTimeout must not be negative",
+			nil,
+		},
+		{
+			"Negative preflight timeout",
+			fields{AppName: "foo", URL: "nats://nats:4222", StreamUser: "foo", StreamPass: "bar", Preflight: -time.Second},
+			"PreflightTimeout must not be negative",
+			nil,
 		},
 	}
 
@@ -65,6 +99,11 @@ func TestNatsOptions_ValidatePrereqs(t *testing.T) {
 				StreamPass:     tt.fields.StreamPass,
 				CredsFile:      tt.fields.CredsFile,
 				ConnectTimeout: tt.fields.ConnectTimeout,
+
+				NakDelay:                    tt.fields.NakDelay,
+				SubscriptionCallbackTimeout: tt.fields.CallbackTO,
+				SubscribeWaitTimeout:        tt.fields.SubscribeWait,
+				PreflightTimeout:            tt.fields.Preflight,
 			}
 
 			err := o.validatePrereqs()
@@ -87,6 +126,8 @@ func TestNatsStreamOptions_Validate(t *testing.T) {
 		Acknowledgements bool
 		DuplicateWindow  time.Duration
 		Retention        string
+		MaxAge           time.Duration
+		SubjectTransform *NatsSubjectTransform
 	}
 
 	tests := []struct {
@@ -119,6 +160,54 @@ func TestNatsStreamOptions_Validate(t *testing.T) {
 			"",
 			&NatsStreamOptions{Name: "hollow", Subjects: []string{"foo.bar"}, Retention: "limits"},
 		},
+		{
+			"Subject transform",
+			fields{Name: "hollow", Subjects: []string{"legacy.>"}, SubjectTransform: &NatsSubjectTransform{
+				Source:      "legacy.*.servers.>",
+				Destination: "com.hollow.sh.servers.{{wildcard(1)}}.>",
+			}},
+			"",
+			&NatsStreamOptions{Name: "hollow", Subjects: []string{"legacy.>"}, Retention: "limits", SubjectTransform: &NatsSubjectTransform{
+				Source:      "legacy.*.servers.>",
+				Destination: "com.hollow.sh.servers.{{wildcard(1)}}.>",
+			}},
+		},
+		{
+			"Subject transform Destination required",
+			fields{Name: "hollow", Subjects: []string{"legacy.>"}, SubjectTransform: &NatsSubjectTransform{Source: "legacy.>"}},
+			"requires a Source and a Destination",
+			nil,
+		},
+		{
+			"Subject transform Source not covered by the stream",
+			fields{Name: "hollow", Subjects: []string{"legacy.>"}, SubjectTransform: &NatsSubjectTransform{Source: "other.>", Destination: "new.>"}},
+			"isn't covered by the stream Subjects",
+			nil,
+		},
+		{
+			"Subject transform referencing a missing wildcard",
+			fields{Name: "hollow", Subjects: []string{"legacy.>"}, SubjectTransform: &NatsSubjectTransform{Source: "legacy.*", Destination: "new.$2"}},
+			"references wildcard 2, the Source has 1",
+			nil,
+		},
+		{
+			"Subject transform dropping the full wildcard",
+			fields{Name: "hollow", Subjects: []string{"legacy.>"}, SubjectTransform: &NatsSubjectTransform{Source: "legacy.>", Destination: "new.servers"}},
+			"must end with >",
+			nil,
+		},
+		{
+			"Subject transform with an empty token",
+			fields{Name: "hollow", Subjects: []string{"legacy.>"}, SubjectTransform: &NatsSubjectTransform{Source: "legacy.>", Destination: "new..>"}},
+			"has an empty token",
+			nil,
+		},
+		{
+			"Negative MaxAge",
+			fields{Name: "hollow", Subjects: []string{"presence.>"}, MaxAge: -time.Minute},
+			"MaxAge must not be negative",
+			nil,
+		},
 	}
 
 	for _, tt := range tests {
@@ -129,6 +218,8 @@ func TestNatsStreamOptions_Validate(t *testing.T) {
 				Acknowledgements: tt.fields.Acknowledgements,
 				DuplicateWindow:  tt.fields.DuplicateWindow,
 				Retention:        tt.fields.Retention,
+				MaxAge:           tt.fields.MaxAge,
+				SubjectTransform: tt.fields.SubjectTransform,
 			}
 
 			err := s.validate()
@@ -153,6 +244,15 @@ func TestNatsConsumerOptions_Validate(t *testing.T) {
 		MaxAckPending     int
 		FilterSubject     string
 		SubscribeSubjects []string
+		AckPolicy         string
+
+		AckDeadlineWarning time.Duration
+		FilterSubjects     []string
+		CooperativeFetch   bool
+		HeaderFilters      map[string][]string
+		DeliverPolicy      string
+		StartSequence      uint64
+		StartTime          time.Time
 	}
 
 	tests := []struct {
@@ -175,12 +275,155 @@ func TestNatsConsumerOptions_Validate(t *testing.T) {
 				Name:          "foo",
 				AckWait:       consumerAckWait,
 				MaxAckPending: consumerMaxAckPending,
+				AckPolicy:     consumerAckPolicyExplicit,
+				DeliverPolicy: consumerDeliverAll,
 			},
 		},
+		{
+			"Invalid ack policy",
+			"require a valid AckPolicy",
+			&fields{Name: "foo", AckPolicy: "none"},
+			nil,
+		},
+		{
+			"Filter subject derived from pull subscribe subject",
+			"",
+			&fields{Name: "foo", Pull: true, SubscribeSubjects: []string{"pre.test"}},
+			&NatsConsumerOptions{
+				Name:              "foo",
+				Pull:              true,
+				SubscribeSubjects: []string{"pre.test"},
+				FilterSubject:     "pre.test",
+				AckWait:           consumerAckWait,
+				MaxAckPending:     consumerMaxAckPending,
+				AckPolicy:         consumerAckPolicyExplicit,
+				DeliverPolicy:     consumerDeliverAll,
+			},
+		},
+		{
+			"Filter subjects derived from pull subscribe subjects",
+			"",
+			&fields{Name: "foo", Pull: true, SubscribeSubjects: []string{"pre.a", "pre.b"}},
+			&NatsConsumerOptions{
+				Name:              "foo",
+				Pull:              true,
+				SubscribeSubjects: []string{"pre.a", "pre.b"},
+				FilterSubjects:    []string{"pre.a", "pre.b"},
+				AckWait:           consumerAckWait,
+				MaxAckPending:     consumerMaxAckPending,
+				AckPolicy:         consumerAckPolicyExplicit,
+				DeliverPolicy:     consumerDeliverAll,
+			},
+		},
+		{
+			"Single filter subjects set as the filter subject",
+			"",
+			&fields{Name: "foo", FilterSubjects: []string{"pre.>"}},
+			&NatsConsumerOptions{
+				Name:          "foo",
+				FilterSubject: "pre.>",
+				AckWait:       consumerAckWait,
+				MaxAckPending: consumerMaxAckPending,
+				AckPolicy:     consumerAckPolicyExplicit,
+				DeliverPolicy: consumerDeliverAll,
+			},
+		},
+		{
+			"Filter subject and filter subjects",
+			"either a FilterSubject or FilterSubjects",
+			&fields{Name: "foo", FilterSubject: "pre.a", FilterSubjects: []string{"pre.b"}},
+			nil,
+		},
+		{
+			"Pull subscribe subject not matching the filter subject",
+			"doesn't match the consumer FilterSubject",
+			&fields{Name: "foo", Pull: true, FilterSubject: "pre.>", SubscribeSubjects: []string{"pre.test"}},
+			nil,
+		},
+		{
+			"Pull subscribe subject not covered by the filter subjects",
+			"isn't covered by the consumer FilterSubjects",
+			&fields{Name: "foo", Pull: true, FilterSubjects: []string{"pre.a.*", "pre.b"}, SubscribeSubjects: []string{"pre.a.>"}},
+			nil,
+		},
+		{
+			"Ack deadline warning past AckWait",
+			"AckDeadlineWarning shorter than the AckWait",
+			&fields{Name: "foo", AckDeadlineWarning: consumerAckWait},
+			nil,
+		},
+		{
+			"Cooperative fetch without Pull",
+			"require Pull for CooperativeFetch",
+			&fields{Name: "foo", CooperativeFetch: true},
+			nil,
+		},
+		{
+			"Unnamed header filter",
+			"require named HeaderFilters",
+			&fields{Name: "foo", HeaderFilters: map[string][]string{"": {"server"}}},
+			nil,
+		},
+		{
+			"Invalid deliver policy",
+			"require a valid DeliverPolicy",
+			&fields{Name: "foo", DeliverPolicy: "first"},
+			nil,
+		},
+		{
+			"Start sequence set",
+			"",
+			&fields{Name: "foo", DeliverPolicy: consumerDeliverByStartSequence, StartSequence: 42},
+			&NatsConsumerOptions{
+				Name:          "foo",
+				AckWait:       consumerAckWait,
+				MaxAckPending: consumerMaxAckPending,
+				AckPolicy:     consumerAckPolicyExplicit,
+				DeliverPolicy: consumerDeliverByStartSequence,
+				StartSequence: 42,
+			},
+		},
+		{
+			"Start sequence missing",
+			"require a StartSequence with the byStartSequence DeliverPolicy only",
+			&fields{Name: "foo", DeliverPolicy: consumerDeliverByStartSequence},
+			nil,
+		},
+		{
+			"Start sequence without its deliver policy",
+			"require a StartSequence with the byStartSequence DeliverPolicy only",
+			&fields{Name: "foo", StartSequence: 42},
+			nil,
+		},
+		{
+			"Start time missing",
+			"require a StartTime with the byStartTime DeliverPolicy only",
+			&fields{Name: "foo", DeliverPolicy: consumerDeliverByStartTime},
+			nil,
+		},
+		{
+			"Start time without its deliver policy",
+			"require a StartTime with the byStartTime DeliverPolicy only",
+			&fields{Name: "foo", DeliverPolicy: consumerDeliverNew, StartTime: time.Now()},
+			nil,
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			c := &NatsConsumerOptions{Name: tt.fields.Name}
+			c := &NatsConsumerOptions{
+				Name:               tt.fields.Name,
+				Pull:               tt.fields.Pull,
+				AckPolicy:          tt.fields.AckPolicy,
+				AckDeadlineWarning: tt.fields.AckDeadlineWarning,
+				CooperativeFetch:   tt.fields.CooperativeFetch,
+				FilterSubject:      tt.fields.FilterSubject,
+				FilterSubjects:     tt.fields.FilterSubjects,
+				SubscribeSubjects:  tt.fields.SubscribeSubjects,
+				HeaderFilters:      tt.fields.HeaderFilters,
+				DeliverPolicy:      tt.fields.DeliverPolicy,
+				StartSequence:      tt.fields.StartSequence,
+				StartTime:          tt.fields.StartTime,
+			}
 
 			err := c.validate()
 			if tt.errorContains != "" {
@@ -194,3 +437,26 @@ func TestNatsConsumerOptions_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestSubjectIsSubset(t *testing.T) {
+	tests := []struct {
+		subject string
+		filter  string
+		want    bool
+	}{
+		{"pre.test", "pre.test", true},
+		{"pre.test", "pre.other", false},
+		{"pre.test", "pre.*", true},
+		{"pre.test.more", "pre.*", false},
+		{"pre.test.more", "pre.>", true},
+		{"pre", "pre.>", false},
+		{"pre.*", "pre.>", true},
+		{"pre.>", "pre.*", false},
+		{"pre.*.a", "pre.*.*", true},
+		{"pre.a", "pre.a.b", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, subjectIsSubset(tt.subject, tt.filter), tt.subject+" in "+tt.filter)
+	}
+}
@@ -0,0 +1,192 @@
+package events
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// DefaultInstanceCountRefresh is how often the instance count is refreshed in the cooperative fetch mode.
+const DefaultInstanceCountRefresh = 30 * time.Second
+
+// InstanceCounter returns the number of instances fetching from the consumer, see registry.InstanceCounter.
+type InstanceCounter interface {
+	InstanceCount(ctx context.Context) (int, error)
+}
+
+// InstanceCounterFunc is a func implementing InstanceCounter.
+type InstanceCounterFunc func(ctx context.Context) (int, error)
+
+// InstanceCount calls the func.
+func (f InstanceCounterFunc) InstanceCount(ctx context.Context) (int, error) {
+	return f(ctx)
+}
+
+// cooperativeFetch limits the messages held by this instance to its share of the consumer MaxAckPending.
+type cooperativeFetch struct {
+	mu        sync.Mutex
+	counter   InstanceCounter
+	instances int
+	refreshed time.Time
+	inFlight  int
+	// released is closed when a message is resolved
+	released chan struct{}
+}
+
+// SetInstanceCounter sets how the number of instances sharing the consumer is discovered
+// in the cooperative fetch mode, see NatsConsumerOptions.CooperativeFetch.
+func (n *NatsJetstream) SetInstanceCounter(counter InstanceCounter) {
+	n.coop.mu.Lock()
+	defer n.coop.mu.Unlock()
+
+	n.coop.counter = counter
+	n.coop.refreshed = time.Time{}
+}
+
+// CooperativeShare returns the maximum number of messages this instance holds in the cooperative
+// fetch mode and the number it currently holds, to be exported as metrics.
+func (n *NatsJetstream) CooperativeShare() (maxInFlight, inFlight int) {
+	n.coop.mu.Lock()
+	defer n.coop.mu.Unlock()
+
+	return n.cooperativeMaxInFlight(), n.coop.inFlight
+}
+
+func (n *NatsJetstream) cooperative() bool {
+	return n.parameters != nil && n.parameters.Consumer != nil && n.parameters.Consumer.CooperativeFetch
+}
+
+// cooperativeBatch returns the number of messages this instance may fetch, up to batch. It blocks
+// while the instance holds its whole share until a message is resolved or the context is done.
+func (n *NatsJetstream) cooperativeBatch(ctx context.Context, batch int) (int, error) {
+	n.refreshInstanceCount(ctx)
+
+	for {
+		n.coop.mu.Lock()
+		available := n.cooperativeMaxInFlight() - n.coop.inFlight
+		released := n.coop.releasedCh()
+		n.coop.mu.Unlock()
+
+		if available > 0 {
+			if available < batch {
+				return available, nil
+			}
+
+			return batch, nil
+		}
+
+		select {
+		case <-released:
+		case <-n.drainCh:
+			return 0, n.closedErr(ErrNatsDraining)
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		}
+	}
+}
+
+// cooperativeMaxInFlight returns the share of the consumer MaxAckPending of this instance,
+// the caller holds the lock.
+func (n *NatsJetstream) cooperativeMaxInFlight() int {
+	maxAckPending := consumerMaxAckPending
+	if n.parameters != nil && n.parameters.Consumer != nil && n.parameters.Consumer.MaxAckPending > 0 {
+		maxAckPending = n.parameters.Consumer.MaxAckPending
+	}
+
+	instances := n.coop.instances
+	if instances < 1 {
+		instances = 1
+	}
+
+	// round up so the shares add up to at least the MaxAckPending
+	share := (maxAckPending + instances - 1) / instances
+	if share < 1 {
+		share = 1
+	}
+
+	return share
+}
+
+// refreshInstanceCount updates the instance count once it is older than the refresh interval,
+// the last count is kept when it can't be refreshed.
+func (n *NatsJetstream) refreshInstanceCount(ctx context.Context) {
+	n.coop.mu.Lock()
+	counter := n.coop.counter
+	stale := time.Since(n.coop.refreshed) >= n.instanceCountRefresh()
+	n.coop.mu.Unlock()
+
+	if counter == nil || !stale {
+		return
+	}
+
+	count, err := counter.InstanceCount(ctx)
+	if err != nil {
+		n.logger().Sugar().Warnw("unable to refresh the instance count", "error", err)
+		return
+	}
+
+	n.coop.mu.Lock()
+	n.coop.instances = count
+	n.coop.refreshed = time.Now()
+	n.coop.mu.Unlock()
+}
+
+func (n *NatsJetstream) instanceCountRefresh() time.Duration {
+	if n.parameters == nil || n.parameters.Consumer == nil || n.parameters.Consumer.InstanceCountRefresh == 0 {
+		return DefaultInstanceCountRefresh
+	}
+
+	return n.parameters.Consumer.InstanceCountRefresh
+}
+
+// holdCooperative counts the message against the instance share until it is resolved,
+// or its AckWait passes after which the server redelivers it anyway.
+func (n *NatsJetstream) holdCooperative(nm *natsMsg) {
+	n.coop.mu.Lock()
+	n.coop.inFlight++
+	n.coop.mu.Unlock()
+
+	var once sync.Once
+
+	release := func() {
+		once.Do(func() {
+			n.coop.mu.Lock()
+			n.coop.inFlight--
+			n.coop.signalReleased()
+			n.coop.mu.Unlock()
+		})
+	}
+
+	ackWait := consumerAckWait
+	if n.parameters.Consumer.AckWait > 0 {
+		ackWait = n.parameters.Consumer.AckWait
+	}
+
+	timer := time.AfterFunc(ackWait, release)
+
+	nm.coopRelease = func() {
+		timer.Stop()
+		release()
+	}
+
+	nm.coopProgress = func() {
+		timer.Reset(ackWait)
+	}
+}
+
+// releasedCh returns the channel signaled when a message is resolved, the caller holds the lock.
+func (c *cooperativeFetch) releasedCh() chan struct{} {
+	if c.released == nil {
+		c.released = make(chan struct{})
+	}
+
+	return c.released
+}
+
+// signalReleased wakes up the fetches waiting for a message to be resolved, the caller holds the lock.
+func (c *cooperativeFetch) signalReleased() {
+	if c.released != nil {
+		close(c.released)
+		c.released = nil
+	}
+}
@@ -0,0 +1,114 @@
+//nolint:all
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	natsTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+func TestPullMsgCooperativeFetch(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName: "TestPullMsgCooperativeFetch",
+		Stream: &NatsStreamOptions{
+			Name:      "test_stream",
+			Subjects:  []string{"pre.test"},
+			Retention: "limits",
+		},
+		Consumer: &NatsConsumerOptions{
+			Name:              "test_consumer",
+			Pull:              true,
+			SubscribeSubjects: []string{"pre.test"},
+			FilterSubject:     "pre.test",
+			AckWait:           time.Minute,
+			MaxAckPending:     5,
+			CooperativeFetch:  true,
+		},
+		PublisherSubjectPrefix: "pre",
+	}
+	require.NoError(t, njs.addStream())
+	require.NoError(t, njs.addConsumer())
+
+	// 3 instances share the consumer, each holds up to 2 messages
+	njs.SetInstanceCounter(InstanceCounterFunc(func(context.Context) (int, error) {
+		return 3, nil
+	}))
+
+	_, err := njs.Subscribe(context.TODO())
+	require.NoError(t, err)
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, njs.Publish(context.TODO(), "test", []byte("1")))
+	}
+
+	msgs, err := njs.PullMsg(context.TODO(), 10)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+
+	maxInFlight, inFlight := njs.CooperativeShare()
+	assert.Equal(t, 2, maxInFlight)
+	assert.Equal(t, 2, inFlight)
+
+	// blocks while the share is held
+	ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err = njs.PullMsg(ctx, 10)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// resolving a message frees room for one more, resolving twice doesn't count twice
+	require.NoError(t, msgs[0].Ack())
+	require.Error(t, msgs[0].Ack())
+
+	more, err := njs.PullMsg(context.TODO(), 10)
+	require.NoError(t, err)
+	require.Len(t, more, 1)
+
+	_, inFlight = njs.CooperativeShare()
+	assert.Equal(t, 2, inFlight)
+
+	// a blocked fetch resumes once a message is resolved
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		_ = msgs[1].Nak()
+	}()
+
+	more, err = njs.PullMsg(context.TODO(), 10)
+	require.NoError(t, err)
+	require.Len(t, more, 1)
+}
+
+func TestCooperativeMaxInFlight(t *testing.T) {
+	testcases := []struct {
+		name          string
+		maxAckPending int
+		instances     int
+		expected      int
+	}{
+		{"no instance count", 10, 0, 10},
+		{"even share", 10, 2, 5},
+		{"rounded up", 10, 3, 4},
+		{"more instances than messages", 2, 5, 1},
+	}
+
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			njs := &NatsJetstream{parameters: &NatsOptions{Consumer: &NatsConsumerOptions{MaxAckPending: tc.maxAckPending}}}
+			njs.coop.instances = tc.instances
+
+			assert.Equal(t, tc.expected, njs.cooperativeMaxInFlight())
+		})
+	}
+}
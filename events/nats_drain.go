@@ -0,0 +1,92 @@
+package events
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// interval at which drained subscriptions are checked for completion.
+const drainPollInterval = 50 * time.Millisecond
+
+// ErrNatsDraining is returned when pulling messages while the NATS Jetstream is being drained.
+var ErrNatsDraining = errors.New("NATS Jetstream is draining")
+
+// Drain stops fetching new messages and drains the subscriptions, messages received until the
+// context is done are still handed to subscribers. Any message left after that is Nak'ed to
+// be redelivered right away, the channel returned by Subscribe and the connection are closed.
+func (n *NatsJetstream) Drain(ctx context.Context) error {
+	if !atomic.CompareAndSwapInt32(&n.draining, 0, 1) {
+		return errors.Wrap(ErrNatsDraining, "drain already started")
+	}
+
+	for _, subscription := range n.subscriptions {
+		// the subscription may already be closed.
+		_ = subscription.Drain()
+	}
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+waitDrained:
+	for !n.subscriptionsDrained() {
+		select {
+		case <-ctx.Done():
+			break waitDrained
+		case <-ticker.C:
+		}
+	}
+
+	// messages waiting on a subscriber are Nak'ed and the subscriber channel is closed.
+	n.closeSubscriberCh()
+
+	for _, subscription := range n.subscriptions {
+		if subscription.IsValid() {
+			_ = subscription.Unsubscribe()
+		}
+	}
+
+	if n.conn != nil {
+		n.conn.Close()
+	}
+
+	return nil
+}
+
+// ShutdownHook returns a function draining the stream within the shutdown grace period,
+// to be registered with the rootcmd shutdown hooks:
+//
+//	root.OnShutdown("events", events.ShutdownHook(stream))
+//
+// Streams that can't be drained are closed.
+func ShutdownHook(stream Stream) func(ctx context.Context) error {
+	return func(ctx context.Context) error {
+		if d, ok := stream.(interface{ Drain(context.Context) error }); ok {
+			return d.Drain(ctx)
+		}
+
+		return stream.Close()
+	}
+}
+
+func (n *NatsJetstream) subscriptionsDrained() bool {
+	for _, subscription := range n.subscriptions {
+		if subscription.IsValid() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (n *NatsJetstream) isDraining() bool {
+	return atomic.LoadInt32(&n.draining) == 1
+}
+
+func (n *NatsJetstream) closeDrainCh() {
+	n.drainOnce.Do(func() {
+		close(n.drainCh)
+	})
+}
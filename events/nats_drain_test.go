@@ -0,0 +1,99 @@
+//nolint:all
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	natsTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+func TestDrain(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	njs.subscriberCh = make(MsgCh)
+
+	njs.parameters = &NatsOptions{
+		AppName: "TestDrain",
+		Stream: &NatsStreamOptions{
+			Name: "test_stream",
+			Subjects: []string{
+				"pre.test",
+			},
+			Retention: "limits",
+		},
+		SubscribeSubjects: []string{
+			"pre.test",
+		},
+		PublisherSubjectPrefix: "pre",
+	}
+	require.NoError(t, njs.addStream())
+
+	// watch the acks sent for the messages.
+	watchConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	defer watchConn.Close()
+
+	acks, err := watchConn.SubscribeSync("$JS.ACK.test_stream.>")
+	require.NoError(t, err)
+	require.NoError(t, watchConn.Flush())
+
+	msgCh, err := njs.Subscribe(context.TODO())
+	require.NoError(t, err)
+
+	require.NoError(t, njs.Publish(context.TODO(), "test", []byte("1")))
+	require.NoError(t, njs.Publish(context.TODO(), "test", []byte("2")))
+
+	// the first message is processed before the shutdown.
+	msg := <-msgCh
+	require.NoError(t, msg.Ack())
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 200*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	require.NoError(t, ShutdownHook(njs)(ctx))
+	assert.Less(t, time.Since(start), subscriptionCallbackTimeout)
+
+	// nobody read the second message, it was Nak'ed.
+	var got []string
+
+	for {
+		ack, err := acks.NextMsg(500 * time.Millisecond)
+		if err != nil {
+			break
+		}
+
+		got = append(got, string(ack.Data))
+	}
+
+	assert.Equal(t, []string{"+ACK", "-NAK"}, got)
+	assert.True(t, jsConn.IsClosed())
+
+	_, err = njs.PullMsg(context.TODO(), 1)
+	assert.ErrorIs(t, err, ErrNatsDraining)
+
+	assert.ErrorIs(t, njs.Drain(context.TODO()), ErrNatsDraining)
+}
+
+type closeOnlyStream struct {
+	Stream
+	closed bool
+}
+
+func (s *closeOnlyStream) Close() error {
+	s.closed = true
+	return nil
+}
+
+func TestShutdownHookClosesStream(t *testing.T) {
+	s := &closeOnlyStream{}
+	require.NoError(t, ShutdownHook(s)(context.TODO()))
+	assert.True(t, s.closed)
+}
@@ -0,0 +1,183 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+)
+
+const (
+	// JetStream API subject to create or update a consumer.
+	jsAPIConsumerCreateT = "$JS.API.CONSUMER.CREATE.%s.%s"
+
+	// timeout for JetStream API requests made while setting up the stream.
+	jsAPISetupTimeout = 5 * time.Second
+)
+
+// filterSubjects returns the subjects the consumer is filtered on.
+func (c *NatsConsumerOptions) filterSubjects() []string {
+	if c.FilterSubject != "" {
+		return []string{c.FilterSubject}
+	}
+
+	return c.FilterSubjects
+}
+
+// validateFilterSubjects derives the filter of pull consumers from their SubscribeSubjects when none is set,
+// and ensures the SubscribeSubjects are delivered by the consumer filter.
+func (c *NatsConsumerOptions) validateFilterSubjects() error {
+	if c.FilterSubject != "" && len(c.FilterSubjects) > 0 {
+		return errors.Wrap(ErrNatsConfig, "consumer parameters accept either a FilterSubject or FilterSubjects, not both")
+	}
+
+	if c.Pull && len(c.filterSubjects()) == 0 {
+		c.FilterSubjects = c.SubscribeSubjects
+	}
+
+	// a single filter subject is set as the FilterSubject, which older servers support.
+	if len(c.FilterSubjects) == 1 {
+		c.FilterSubject, c.FilterSubjects = c.FilterSubjects[0], nil
+	}
+
+	if !c.Pull {
+		return nil
+	}
+
+	for _, subject := range c.SubscribeSubjects {
+		if c.FilterSubject != "" {
+			// the client only binds pull subscriptions on the exact FilterSubject.
+			if subject != c.FilterSubject {
+				return errors.Wrap(ErrNatsConfig, fmt.Sprintf(
+					"pull SubscribeSubjects %q doesn't match the consumer FilterSubject %q, set FilterSubjects to consume several subjects",
+					subject, c.FilterSubject,
+				))
+			}
+
+			continue
+		}
+
+		if !subjectCoveredBy(subject, c.FilterSubjects) {
+			return errors.Wrap(ErrNatsConfig, fmt.Sprintf(
+				"pull SubscribeSubjects %q isn't covered by the consumer FilterSubjects %q, no messages would be delivered",
+				subject, c.FilterSubjects,
+			))
+		}
+	}
+
+	return nil
+}
+
+// subjectCoveredBy returns true when all the subjects matching subject match one of the filters.
+func subjectCoveredBy(subject string, filters []string) bool {
+	for _, filter := range filters {
+		if subjectIsSubset(subject, filter) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// subjectIsSubset returns true when every subject matching subject also matches filter,
+// both may hold the * and > wildcards.
+func subjectIsSubset(subject, filter string) bool {
+	st := strings.Split(subject, ".")
+	ft := strings.Split(filter, ".")
+
+	for i, f := range ft {
+		if f == ">" {
+			return len(st) > i
+		}
+
+		if i >= len(st) {
+			return false
+		}
+
+		switch {
+		case f == "*":
+			if st[i] == ">" {
+				return false
+			}
+		case f != st[i]:
+			return false
+		}
+	}
+
+	return len(st) == len(ft)
+}
+
+type jsAPIConsumerCreateRequest struct {
+	Stream string                    `json:"stream_name"`
+	Config multiFilterConsumerConfig `json:"config"`
+}
+
+// multiFilterConsumerConfig adds the filter subjects the NATS client doesn't know about to the consumer config.
+type multiFilterConsumerConfig struct {
+	nats.ConsumerConfig
+	FilterSubjects []string `json:"filter_subjects,omitempty"`
+}
+
+type jsAPIConsumerCreateResponse struct {
+	jsAPIResponse
+	Config *multiFilterConsumerConfig `json:"config,omitempty"`
+}
+
+// addMultiFilterConsumer creates or updates a consumer filtered on several subjects,
+// this requires nats-server 2.10 or later.
+func (n *NatsJetstream) addMultiFilterConsumer(cfg *nats.ConsumerConfig) error {
+	if version := n.conn.ConnectedServerVersion(); !serverVersionAtLeast(version, 2, 10) {
+		return errors.Wrap(
+			ErrNatsJetstreamAddConsumer,
+			"consumer FilterSubjects require nats-server 2.10 or later, connected server version: "+version,
+		)
+	}
+
+	req := jsAPIConsumerCreateRequest{
+		Stream: n.parameters.Stream.Name,
+		Config: multiFilterConsumerConfig{
+			ConsumerConfig: *cfg,
+			FilterSubjects: n.parameters.Consumer.FilterSubjects,
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), jsAPISetupTimeout)
+	defer cancel()
+
+	var resp jsAPIConsumerCreateResponse
+
+	subject := fmt.Sprintf(jsAPIConsumerCreateT, n.parameters.Stream.Name, cfg.Durable)
+	if err := n.jsAPIRequest(ctx, subject, req, &resp); err != nil {
+		return errors.Wrap(err, ErrNatsJetstreamAddConsumer.Error()+" consumer.Name="+cfg.Durable)
+	}
+
+	if resp.Config == nil || len(resp.Config.FilterSubjects) != len(req.Config.FilterSubjects) {
+		return errors.Wrap(ErrNatsJetstreamAddConsumer, "consumer FilterSubjects were not applied by the server")
+	}
+
+	return nil
+}
+
+// serverVersionAtLeast returns true when the server version is at least major.minor.
+func serverVersionAtLeast(version string, major, minor int) bool {
+	parts := strings.SplitN(strings.TrimPrefix(version, "v"), ".", 3)
+	if len(parts) < 2 {
+		return false
+	}
+
+	gotMajor, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return false
+	}
+
+	gotMinor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return false
+	}
+
+	return gotMajor > major || (gotMajor == major && gotMinor >= minor)
+}
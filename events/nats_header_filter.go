@@ -0,0 +1,106 @@
+package events
+
+import (
+	"sync/atomic"
+
+	"github.com/nats-io/nats.go"
+)
+
+// MessageFilter returns true for the messages to hand out to subscribers, the others are acked and
+// skipped before being handed out. Filters run for each message and are expected to be cheap,
+// e.g. matching headers rather than decoding the payload.
+type MessageFilter func(msg Message) bool
+
+// HeaderFilter returns a MessageFilter matching the messages with the header set to one of the
+// values, or set to any value when none are given.
+func HeaderFilter(header string, values ...string) MessageFilter {
+	return func(msg Message) bool {
+		nm, err := AsNatsMsg(msg)
+		if err != nil {
+			return false
+		}
+
+		return headerMatches(nm.Header, header, values)
+	}
+}
+
+// AllFilters returns a MessageFilter matching the messages matched by all the filters.
+func AllFilters(filters ...MessageFilter) MessageFilter {
+	return func(msg Message) bool {
+		for _, filter := range filters {
+			if !filter(msg) {
+				return false
+			}
+		}
+
+		return true
+	}
+}
+
+func headerMatches(h nats.Header, header string, values []string) bool {
+	got := h.Values(header)
+	if len(values) == 0 {
+		return len(got) > 0
+	}
+
+	for _, v := range got {
+		for _, want := range values {
+			if v == want {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// SetMessageFilter sets the filter messages of the consumer are to match, along with the
+// consumer HeaderFilters, to be handed out by Subscribe and PullMsg. It is to be set before subscribing.
+func (n *NatsJetstream) SetMessageFilter(filter MessageFilter) {
+	n.filter = filter
+}
+
+// FilteredMessages returns the number of messages skipped because they didn't match the filters.
+func (n *NatsJetstream) FilteredMessages() uint64 {
+	return atomic.LoadUint64(&n.filteredMessages)
+}
+
+// consumerFilter matches the messages of the configured consumer with its HeaderFilters and the MessageFilter.
+func (n *NatsJetstream) consumerFilter(msg Message) bool {
+	if n.parameters != nil && n.parameters.Consumer != nil && len(n.parameters.Consumer.HeaderFilters) > 0 {
+		nm, err := AsNatsMsg(msg)
+		if err != nil {
+			return false
+		}
+
+		for header, values := range n.parameters.Consumer.HeaderFilters {
+			if !headerMatches(nm.Header, header, values) {
+				return false
+			}
+		}
+	}
+
+	return n.filter == nil || n.filter(msg)
+}
+
+// skipFiltered acks the message and returns true when it doesn't match the filter.
+func (n *NatsJetstream) skipFiltered(msg *nats.Msg, filter MessageFilter) bool {
+	if filter == nil || filter(&natsMsg{msg: msg}) {
+		return false
+	}
+
+	atomic.AddUint64(&n.filteredMessages, 1)
+
+	n.ackSkipped(msg)
+
+	return true
+}
+
+// ackSkipped acks a message skipped without being handed out.
+func (n *NatsJetstream) ackSkipped(msg *nats.Msg) {
+	if n.ackSync() {
+		_ = msg.AckSync()
+	} else {
+		_ = msg.Ack()
+	}
+}
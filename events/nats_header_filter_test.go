@@ -0,0 +1,96 @@
+//nolint:all
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	natsTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+func TestHeaderFilters(t *testing.T) {
+	srv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, srv)
+
+	nc, js := natsTest.JetStreamContext(t, srv)
+	njs := NewJetstreamFromConn(nc)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName: "TestHeaderFilters",
+		Stream: &NatsStreamOptions{
+			Name:      "resources",
+			Subjects:  []string{"pre.>"},
+			Retention: "limits",
+		},
+		Consumer: &NatsConsumerOptions{
+			Name:              "resources_consumer",
+			Pull:              true,
+			SubscribeSubjects: []string{"pre.resources"},
+			FilterSubject:     "pre.resources",
+			HeaderFilters:     map[string][]string{"Resource-Type": {"server", "switch"}},
+		},
+		PublisherSubjectPrefix: "pre",
+	}
+	require.NoError(t, njs.addStream())
+	require.NoError(t, njs.addConsumer())
+
+	// only messages from the lab are handed out
+	njs.SetMessageFilter(HeaderFilter("Site", "lab"))
+
+	_, err := njs.Subscribe(context.TODO())
+	require.NoError(t, err)
+
+	publish := func(data string, headers map[string]string) {
+		msg := nats.NewMsg("pre.resources")
+		msg.Data = []byte(data)
+
+		for k, v := range headers {
+			msg.Header.Set(k, v)
+		}
+
+		_, err := js.PublishMsg(msg)
+		require.NoError(t, err)
+	}
+
+	publish("server", map[string]string{"Resource-Type": "server", "Site": "lab"})
+	publish("rack", map[string]string{"Resource-Type": "rack", "Site": "lab"})
+	publish("switch", map[string]string{"Resource-Type": "switch", "Site": "lab"})
+	publish("remote server", map[string]string{"Resource-Type": "server", "Site": "remote"})
+	publish("no headers", nil)
+
+	msgs, err := njs.PullMsg(context.TODO(), 5)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+
+	assert.Equal(t, []byte("server"), msgs[0].Data())
+	assert.Equal(t, []byte("switch"), msgs[1].Data())
+	assert.Equal(t, uint64(3), njs.FilteredMessages())
+
+	// the skipped messages were acked
+	require.Eventually(t, func() bool {
+		consumer, err := js.ConsumerInfo("resources", "resources_consumer")
+		return err == nil && consumer.NumAckPending == 2
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestHeaderFilter(t *testing.T) {
+	msg := nats.NewMsg("test")
+	msg.Header.Add("Resource-Type", "rack")
+	msg.Header.Add("Resource-Type", "server")
+
+	m := &natsMsg{msg: msg}
+
+	assert.True(t, HeaderFilter("Resource-Type")(m))
+	assert.True(t, HeaderFilter("Resource-Type", "server")(m))
+	assert.False(t, HeaderFilter("Resource-Type", "switch")(m))
+	assert.False(t, HeaderFilter("Site")(m))
+
+	assert.True(t, AllFilters()(m))
+	assert.False(t, AllFilters(HeaderFilter("Resource-Type"), HeaderFilter("Site"))(m))
+}
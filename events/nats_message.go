@@ -14,6 +14,10 @@ import (
 
 // AsNatsMsg exposes the underlying nats.Msg to a sophisticated consumer.
 func AsNatsMsg(m Message) (*nats.Msg, error) {
+	if im, ok := m.(*idempotentMsg); ok {
+		m = im.Message
+	}
+
 	nm, ok := m.(*natsMsg)
 	if !ok {
 		return nil, errors.New("Message is not a NATS message type")
@@ -23,29 +27,111 @@ func AsNatsMsg(m Message) (*nats.Msg, error) {
 
 // MustNatsMsg will panic if the type assertion fails
 func MustNatsMsg(m Message) *nats.Msg {
+	if im, ok := m.(*idempotentMsg); ok {
+		m = im.Message
+	}
+
 	nm := m.(*natsMsg)
 	return nm.msg
 }
 
 type natsMsg struct {
-	msg *nats.Msg
+	msg      *nats.Msg
+	ackSync  bool
+	watchdog *ackWatchdog
+
+	// set in the cooperative fetch mode to release the message from the instance share
+	coopRelease  func()
+	coopProgress func()
+
+	// set by replays to move their cursor once the message is acked or terminated
+	processed func()
+
+	// keeps the reasons given to NakWithReason and TermWithReason
+	reasons ReasonStore
 }
 
 func (nm *natsMsg) Ack() error {
-	return nm.msg.Ack()
+	nm.resolve()
+
+	var err error
+	if nm.ackSync {
+		err = nm.msg.AckSync()
+	} else {
+		err = nm.msg.Ack()
+	}
+
+	nm.markProcessed(err)
+
+	if err == nil {
+		nm.clearReason()
+	}
+
+	return err
 }
 func (nm *natsMsg) Nak() error {
+	nm.resolve()
 	return nm.msg.Nak()
 }
 
+func (nm *natsMsg) AckWithContext(ctx context.Context) error {
+	nm.resolve()
+
+	ctx, cancel := ackContext(ctx)
+	defer cancel()
+
+	err := ackError(nm.msg.AckSync(nats.Context(ctx)))
+
+	nm.markProcessed(err)
+
+	if err == nil {
+		nm.clearReason()
+	}
+
+	return err
+}
+
+func (nm *natsMsg) NakWithContext(ctx context.Context) error {
+	nm.resolve()
+
+	ctx, cancel := ackContext(ctx)
+	defer cancel()
+
+	return ackError(nm.msg.Nak(nats.Context(ctx)))
+}
+
 func (nm *natsMsg) Term() error {
-	return nm.msg.Term()
+	nm.resolve()
+
+	err := nm.msg.Term()
+	nm.markProcessed(err)
+
+	return err
 }
 
 func (nm *natsMsg) InProgress() error {
+	nm.watchdog.inProgress()
+	if nm.coopProgress != nil {
+		nm.coopProgress()
+	}
 	return nm.msg.InProgress()
 }
 
+// resolve stops tracking the message once it is acked, nak'ed or terminated.
+func (nm *natsMsg) resolve() {
+	nm.watchdog.resolve()
+	if nm.coopRelease != nil {
+		nm.coopRelease()
+	}
+}
+
+// markProcessed reports the message was acked or terminated.
+func (nm *natsMsg) markProcessed(err error) {
+	if err == nil && nm.processed != nil {
+		nm.processed()
+	}
+}
+
 func (nm *natsMsg) Subject() string {
 	return nm.msg.Subject
 }
@@ -54,6 +140,10 @@ func (nm *natsMsg) Data() []byte {
 	return nm.msg.Data
 }
 
+func (nm *natsMsg) IsTombstone() bool {
+	return isTombstone(nm.msg.Header)
+}
+
 func (nm *natsMsg) ExtractOtelTraceContext(ctx context.Context) context.Context {
 	if nm == nil || nm.msg.Header == nil {
 		return ctx
@@ -62,10 +152,29 @@ func (nm *natsMsg) ExtractOtelTraceContext(ctx context.Context) context.Context
 	return otel.GetTextMapPropagator().Extract(ctx, propagation.HeaderCarrier(nm.msg.Header))
 }
 
-func msgIfFromNats(natsMsgs ...*nats.Msg) []Message {
-	msgs := make([]Message, 0, len(natsMsgs))
-	for _, m := range natsMsgs {
-		msgs = append(msgs, &natsMsg{msg: m})
+func (nm *natsMsg) Metadata() (MessageMetadata, error) {
+	md, err := nm.msg.Metadata()
+	if err != nil {
+		return MessageMetadata{}, err
 	}
-	return msgs
+
+	return MessageMetadata{
+		StreamSequence:   md.Sequence.Stream,
+		ConsumerSequence: md.Sequence.Consumer,
+		NumDelivered:     md.NumDelivered,
+		NumPending:       md.NumPending,
+		Stream:           md.Stream,
+		Consumer:         md.Consumer,
+		Timestamp:        md.Timestamp,
+	}, nil
+}
+
+func (nm *natsMsg) DecodeInto(v interface{}) error {
+	var contentType, contentEncoding string
+	if nm.msg.Header != nil {
+		contentType = nm.msg.Header.Get(HeaderContentType)
+		contentEncoding = nm.msg.Header.Get(HeaderContentEncoding)
+	}
+
+	return DecodePayload(nm.msg.Data, contentType, contentEncoding, v)
 }
@@ -4,6 +4,7 @@ package events
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/nats-io/nats.go"
 	"github.com/stretchr/testify/require"
@@ -19,6 +20,14 @@ func (_ *bogusMsg) Nak() error {
 	return nil
 }
 
+func (_ *bogusMsg) AckWithContext(_ context.Context) error {
+	return nil
+}
+
+func (_ *bogusMsg) NakWithContext(_ context.Context) error {
+	return nil
+}
+
 func (_ *bogusMsg) InProgress() error {
 	return nil
 }
@@ -39,6 +48,26 @@ func (_ *bogusMsg) ExtractOtelTraceContext(ctx context.Context) context.Context
 	return ctx
 }
 
+func (_ *bogusMsg) Metadata() (MessageMetadata, error) {
+	return MessageMetadata{}, nil
+}
+
+func (_ *bogusMsg) DecodeInto(v interface{}) error {
+	return nil
+}
+
+func (_ *bogusMsg) IsTombstone() bool {
+	return false
+}
+
+func (_ *bogusMsg) NakWithReason(_ string, _ time.Duration) error {
+	return nil
+}
+
+func (_ *bogusMsg) TermWithReason(_ string) error {
+	return nil
+}
+
 func TestConversions(t *testing.T) {
 	nm := &natsMsg{
 		msg: nats.NewMsg("some.subject"),
@@ -0,0 +1,170 @@
+package events
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// MultiStreamOptions holds the configuration of a MultiStreamBroker, the NatsOptions configure the
+// connection, the subscriptions and the consumer. The NatsOptions Stream must not be set.
+//
+//	events.MultiStreamOptions{
+//		NatsOptions: events.NatsOptions{
+//			// ...
+//			Consumer: &events.NatsConsumerOptions{Name: "worker", Pull: true},
+//		},
+//		Streams: []events.NatsStreamOptions{
+//			{Name: "jobs", Subjects: []string{"com.hollow.sh.jobs.>"}, Retention: "workQueue"},
+//			{Name: "events", Subjects: []string{"com.hollow.sh.events.>"}, Retention: "limits"},
+//		},
+//		ConsumerStream: "jobs",
+//	}
+type MultiStreamOptions struct {
+	NatsOptions `mapstructure:",squash"`
+
+	// Streams are created when the broker is opened, the subjects of different streams must not overlap.
+	Streams []NatsStreamOptions `mapstructure:"streams"`
+
+	// ConsumerStream is the name of the stream the Consumer is added to, defaults to the first of the Streams.
+	ConsumerStream string `mapstructure:"consumer_stream"`
+}
+
+func (o *MultiStreamOptions) validate() error {
+	if o.Stream != nil {
+		return errors.Wrap(ErrNatsConfig, "the streams of a multi stream broker are set with Streams, not Stream")
+	}
+
+	if len(o.Streams) == 0 {
+		return errors.Wrap(ErrNatsConfig, "multi stream broker requires one or more Streams")
+	}
+
+	names := map[string]bool{}
+
+	for i := range o.Streams {
+		stream := &o.Streams[i]
+
+		if err := stream.validate(); err != nil {
+			return err
+		}
+
+		if names[stream.Name] {
+			return errors.Wrap(ErrNatsConfig, "duplicate stream name: "+stream.Name)
+		}
+
+		names[stream.Name] = true
+
+		for _, other := range o.Streams[:i] {
+			for _, subject := range stream.Subjects {
+				for _, otherSubject := range other.Subjects {
+					if subjectsOverlap(subject, otherSubject) {
+						return errors.Wrap(ErrNatsConfig, "subject "+subject+" of stream "+stream.Name+
+							" overlaps subject "+otherSubject+" of stream "+other.Name)
+					}
+				}
+			}
+		}
+	}
+
+	if o.ConsumerStream == "" {
+		o.ConsumerStream = o.Streams[0].Name
+	}
+
+	if !names[o.ConsumerStream] {
+		return errors.Wrap(ErrNatsConfig, "ConsumerStream isn't one of the Streams: "+o.ConsumerStream)
+	}
+
+	return o.NatsOptions.validate()
+}
+
+// MultiStreamBroker manages several streams under one NATS connection, e.g. to keep work queue and
+// limits subjects apart since a stream has a single retention policy. It is used as a single Stream,
+// publishes are routed to the stream storing their subject and publishing to a subject none of the
+// streams store returns ErrPublishSubjectNotInStream. The consumer is added to the ConsumerStream,
+// the SubscribeSubjects may be stored by any of the streams.
+type MultiStreamBroker struct {
+	*NatsJetstream
+}
+
+// NewMultiStreamBroker validates the MultiStreamOptions and returns a MultiStreamBroker,
+// the streams are created by Open.
+func NewMultiStreamBroker(params StreamParameters, opts ...BrokerOption) (*MultiStreamBroker, error) {
+	options, valid := params.(MultiStreamOptions)
+	if !valid {
+		return nil, errors.Wrap(
+			ErrNatsConfig,
+			"expected parameters of type MultiStreamOptions{}, got: "+reflect.TypeOf(params).String(),
+		)
+	}
+
+	streams := make([]NatsStreamOptions, len(options.Streams))
+	copy(streams, options.Streams)
+	options.Streams = streams
+
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+
+	parameters := options.NatsOptions
+
+	// the consumer and pull subscriptions are bound to the consumer stream
+	for i := range streams {
+		if streams[i].Name == options.ConsumerStream {
+			parameters.Stream = &streams[i]
+		}
+	}
+
+	n := &NatsJetstream{parameters: &parameters, drainCh: make(chan struct{}), streams: streams}
+
+	for _, opt := range opts {
+		opt(n)
+	}
+
+	n.applyExactlyOnce()
+
+	return &MultiStreamBroker{NatsJetstream: n}, nil
+}
+
+// StreamFor returns the name of the stream the messages published to the subject suffix are stored in,
+// ErrPublishSubjectNotInStream is returned when none of the streams store the subject.
+func (m *MultiStreamBroker) StreamFor(subjectSuffix string) (string, error) {
+	subject := m.fullSubject(subjectSuffix)
+
+	stream := m.streamFor(subject)
+	if stream == "" {
+		return "", errors.Wrap(ErrPublishSubjectNotInStream, subject+" isn't covered by the streams of the broker")
+	}
+
+	return stream, nil
+}
+
+// streamFor returns the name of the stream of a MultiStreamBroker storing the subject, empty when
+// none of them does or the broker manages a single stream.
+func (n *NatsJetstream) streamFor(subject string) string {
+	for _, stream := range n.streams {
+		if subjectCoveredBy(subject, stream.Subjects) {
+			return stream.Name
+		}
+	}
+
+	return ""
+}
+
+// subjectsOverlap returns true when a subject matches both subjects, either may hold the * and > wildcards.
+func subjectsOverlap(a, b string) bool {
+	at := strings.Split(a, ".")
+	bt := strings.Split(b, ".")
+
+	for i := 0; i < len(at) && i < len(bt); i++ {
+		if at[i] == ">" || bt[i] == ">" {
+			return true
+		}
+
+		if at[i] != "*" && bt[i] != "*" && at[i] != bt[i] {
+			return false
+		}
+	}
+
+	return len(at) == len(bt)
+}
@@ -0,0 +1,165 @@
+//nolint:all
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	natsTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+func TestSubjectsOverlap(t *testing.T) {
+	testcases := []struct {
+		a, b string
+		want bool
+	}{
+		{"facility.jobs.>", "facility.events.>", false},
+		{"facility.>", "facility.events.ams1", true},
+		{"facility.*.ams1", "facility.events.*", true},
+		{"facility.*", "facility.events.ams1", false},
+		{"facility.jobs", "facility.jobs", true},
+		{"facility.jobs", "facility.jobs.ams1", false},
+	}
+
+	for _, tt := range testcases {
+		assert.Equal(t, tt.want, subjectsOverlap(tt.a, tt.b), tt.a+" "+tt.b)
+		assert.Equal(t, tt.want, subjectsOverlap(tt.b, tt.a), tt.b+" "+tt.a)
+	}
+}
+
+func TestNewMultiStreamBrokerValidation(t *testing.T) {
+	base := NatsOptions{AppName: "test", URL: "nats://nats:4222", StreamUser: "foo", StreamPass: "bar"}
+
+	testcases := []struct {
+		name          string
+		options       MultiStreamOptions
+		errorContains string
+	}{
+		{
+			"no streams",
+			MultiStreamOptions{NatsOptions: base},
+			"one or more Streams",
+		},
+		{
+			"stream set",
+			MultiStreamOptions{
+				NatsOptions: func() NatsOptions { o := base; o.Stream = &NatsStreamOptions{Name: "a"}; return o }(),
+				Streams:     []NatsStreamOptions{{Name: "jobs", Subjects: []string{"facility.jobs.>"}}},
+			},
+			"not Stream",
+		},
+		{
+			"duplicate names",
+			MultiStreamOptions{NatsOptions: base, Streams: []NatsStreamOptions{
+				{Name: "jobs", Subjects: []string{"facility.jobs.>"}},
+				{Name: "jobs", Subjects: []string{"facility.events.>"}},
+			}},
+			"duplicate stream name",
+		},
+		{
+			"overlapping subjects",
+			MultiStreamOptions{NatsOptions: base, Streams: []NatsStreamOptions{
+				{Name: "jobs", Subjects: []string{"facility.jobs.>"}},
+				{Name: "all", Subjects: []string{"facility.>"}},
+			}},
+			"overlaps subject facility.jobs.> of stream jobs",
+		},
+		{
+			"unknown consumer stream",
+			MultiStreamOptions{
+				NatsOptions:    base,
+				Streams:        []NatsStreamOptions{{Name: "jobs", Subjects: []string{"facility.jobs.>"}}},
+				ConsumerStream: "events",
+			},
+			"ConsumerStream isn't one of the Streams",
+		},
+	}
+
+	for _, tt := range testcases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewMultiStreamBroker(tt.options)
+			assert.ErrorIs(t, err, ErrNatsConfig)
+			assert.ErrorContains(t, err, tt.errorContains)
+		})
+	}
+
+	_, err := NewMultiStreamBroker(base)
+	assert.ErrorIs(t, err, ErrNatsConfig)
+}
+
+func TestMultiStreamBroker(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	broker, err := NewMultiStreamBroker(MultiStreamOptions{
+		NatsOptions: NatsOptions{
+			URL:                    jsSrv.ClientURL(),
+			AppName:                "test",
+			StreamUser:             "foo",
+			StreamPass:             "bar",
+			ConnectTimeout:         time.Second,
+			PublisherSubjectPrefix: "facility",
+			Consumer: &NatsConsumerOptions{
+				Name:              "worker",
+				Pull:              true,
+				SubscribeSubjects: []string{"facility.jobs.>"},
+			},
+		},
+		Streams: []NatsStreamOptions{
+			{Name: "events", Subjects: []string{"facility.events.>"}, Retention: "limits"},
+			{Name: "jobs", Subjects: []string{"facility.jobs.>"}, Retention: "workQueue"},
+		},
+		ConsumerStream: "jobs",
+	})
+	require.NoError(t, err)
+
+	var stream Stream = broker
+
+	require.NoError(t, stream.Open())
+	defer stream.Close()
+
+	name, err := broker.StreamFor("jobs.ams1")
+	require.NoError(t, err)
+	assert.Equal(t, "jobs", name)
+
+	require.NoError(t, stream.Publish(context.Background(), "jobs.ams1", []byte("job")))
+	require.NoError(t, stream.Publish(context.Background(), "events.ams1", []byte("event")))
+
+	_, err = broker.PublishWithOptions(context.Background(), "events.dfw1", []byte("event"))
+	require.NoError(t, err)
+
+	err = stream.Publish(context.Background(), "other.ams1", []byte("other"))
+	assert.ErrorIs(t, err, ErrPublishSubjectNotInStream)
+
+	_, err = broker.StreamFor("other.ams1")
+	assert.ErrorIs(t, err, ErrPublishSubjectNotInStream)
+
+	js := AsNatsJetStreamContext(broker.NatsJetstream)
+
+	for stream, msgs := range map[string]uint64{"jobs": 1, "events": 2} {
+		info, err := js.StreamInfo(stream)
+		require.NoError(t, err)
+		assert.Equal(t, msgs, info.State.Msgs, stream)
+	}
+
+	info, err := js.StreamInfo("jobs")
+	require.NoError(t, err)
+	assert.Equal(t, nats.WorkQueuePolicy, info.Config.Retention)
+
+	_, err = stream.Subscribe(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	msgs, err := stream.PullMsg(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, "facility.jobs.ams1", msgs[0].Subject())
+	require.NoError(t, msgs[0].Ack())
+}
@@ -0,0 +1,68 @@
+package events
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// PauseConsumption stops handing out messages, the connection, subscriptions and consumer are kept.
+// PullMsg blocks until consumption is resumed, and messages delivered to push based subscriptions
+// are Nak'ed to be redelivered after the NakDelay.
+func (n *NatsJetstream) PauseConsumption() error {
+	if n.jsctx == nil {
+		return errors.Wrap(ErrNatsJetstream, "Jetstream context is not setup")
+	}
+
+	n.pauseMu.Lock()
+	defer n.pauseMu.Unlock()
+
+	if n.resumeCh == nil {
+		n.resumeCh = make(chan struct{})
+	}
+
+	return nil
+}
+
+// ResumeConsumption resumes handing out messages after PauseConsumption.
+func (n *NatsJetstream) ResumeConsumption() error {
+	n.pauseMu.Lock()
+	defer n.pauseMu.Unlock()
+
+	if n.resumeCh != nil {
+		close(n.resumeCh)
+		n.resumeCh = nil
+	}
+
+	return nil
+}
+
+// ConsumptionPaused returns true while consumption is paused.
+func (n *NatsJetstream) ConsumptionPaused() bool {
+	return n.pausedCh() != nil
+}
+
+// pausedCh returns the channel closed once consumption is resumed, nil when it isn't paused.
+func (n *NatsJetstream) pausedCh() chan struct{} {
+	n.pauseMu.Lock()
+	defer n.pauseMu.Unlock()
+
+	return n.resumeCh
+}
+
+// waitResumed blocks while consumption is paused, until it is resumed, drained or the context is done.
+func (n *NatsJetstream) waitResumed(ctx context.Context) error {
+	resumed := n.pausedCh()
+	if resumed == nil {
+		return nil
+	}
+
+	select {
+	case <-resumed:
+		return nil
+	case <-n.drainCh:
+		return ErrNatsDraining
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
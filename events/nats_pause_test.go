@@ -0,0 +1,112 @@
+//nolint:all
+package events
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	natsTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+func TestPauseAndResumeConsumption(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName: "TestPauseAndResumeConsumption",
+		Stream: &NatsStreamOptions{
+			Name:      "test_stream",
+			Subjects:  []string{"pre.test"},
+			Retention: "workQueue",
+		},
+		Consumer: &NatsConsumerOptions{
+			Name:              "test_consumer",
+			Pull:              true,
+			SubscribeSubjects: []string{"pre.test"},
+			FilterSubject:     "pre.test",
+		},
+		PublisherSubjectPrefix: "pre",
+	}
+	require.NoError(t, njs.addStream())
+	require.NoError(t, njs.addConsumer())
+
+	var stream Stream = njs
+
+	pauser, ok := stream.(ConsumptionPauser)
+	require.True(t, ok)
+
+	_, err := njs.Subscribe(context.TODO())
+	require.NoError(t, err)
+
+	require.NoError(t, njs.Publish(context.TODO(), "test", []byte("paused")))
+
+	require.NoError(t, pauser.PauseConsumption())
+	assert.True(t, njs.ConsumptionPaused())
+
+	ctx, cancel := context.WithTimeout(context.TODO(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err = njs.PullMsg(ctx, 1)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// the consumer is kept while paused.
+	_, err = njs.jsctx.ConsumerInfo("test_stream", "test_consumer")
+	require.NoError(t, err)
+
+	pulled := make(chan []Message)
+
+	go func() {
+		msgs, err := njs.PullMsg(context.TODO(), 1)
+		assert.NoError(t, err)
+		pulled <- msgs
+	}()
+
+	select {
+	case <-pulled:
+		t.Fatal("message pulled while paused")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	require.NoError(t, pauser.ResumeConsumption())
+	assert.False(t, njs.ConsumptionPaused())
+
+	msgs := <-pulled
+	require.Len(t, msgs, 1)
+	assert.Equal(t, []byte("paused"), msgs[0].Data())
+}
+
+func TestSubscriptionCallbackNakWhilePaused(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	njs.subscriberCh = make(MsgCh)
+	require.NoError(t, njs.SetNakDelay(42*time.Second))
+	require.NoError(t, njs.PauseConsumption())
+
+	acks, err := jsConn.SubscribeSync("test.ack")
+	require.NoError(t, err)
+
+	sub, err := jsConn.SubscribeSync("test")
+	require.NoError(t, err)
+
+	// the callback returns right away, nobody reads the message.
+	njs.subscriptionCallback(&nats.Msg{Subject: "test", Reply: "test.ack", Sub: sub})
+
+	ack, err := acks.NextMsg(time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf(`-NAK {"delay": %d}`, 42*time.Second), string(ack.Data))
+}
@@ -0,0 +1,142 @@
+package events
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+)
+
+// the subject published to when checking the publish permission, under the PublisherSubjectPrefix
+const preflightSubjectSuffix = "preflight"
+
+var (
+	// ErrPreflightJetStream is returned by Open when JetStream isn't available to the account of the credentials.
+	ErrPreflightJetStream = errors.New("preflight: JetStream not available")
+
+	// ErrPreflightPublish is returned by Open when the credentials aren't allowed to publish under the PublisherSubjectPrefix.
+	ErrPreflightPublish = errors.New("preflight: publish not permitted")
+
+	// ErrPreflightConsumer is returned by Open when the credentials aren't allowed to bind to the configured consumer.
+	ErrPreflightConsumer = errors.New("preflight: consumer bind not permitted")
+)
+
+// preflight checks the connection is able to do what the NatsOptions configure, so misconfigured
+// credentials fail Open instead of the first publish or subscription. It checks JetStream is
+// available to the account, publishing under the PublisherSubjectPrefix is permitted, and binding
+// to the consumer is permitted. The checks are bounded by the PreflightTimeout and skipped when it
+// isn't set.
+func (n *NatsJetstream) preflight() error {
+	if n.parameters.PreflightTimeout <= 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), n.parameters.PreflightTimeout)
+	defer cancel()
+
+	if err := n.preflightJetStream(ctx); err != nil {
+		return err
+	}
+
+	if err := n.preflightPublish(ctx); err != nil {
+		return err
+	}
+
+	return n.preflightConsumer(ctx)
+}
+
+func (n *NatsJetstream) preflightJetStream(ctx context.Context) error {
+	js, err := n.conn.JetStream()
+	if err != nil {
+		return errors.Wrap(ErrPreflightJetStream, err.Error())
+	}
+
+	// the account info request hangs until the timeout when it isn't permitted, check it first
+	if err := n.preflightPublishPermitted(ctx, nats.NewMsg("$JS.API.INFO")); err != nil {
+		return errors.Wrap(ErrPreflightJetStream, err.Error())
+	}
+
+	if _, err := js.AccountInfo(nats.Context(ctx)); err != nil {
+		return errors.Wrap(ErrPreflightJetStream, err.Error())
+	}
+
+	return nil
+}
+
+// preflightPublish publishes a message the stream rejects, expecting a last sequence it can't
+// have, so the permission is checked without storing anything. Core NATS subscribers of the
+// subject receive it though.
+func (n *NatsJetstream) preflightPublish(ctx context.Context) error {
+	if n.parameters.PublisherSubjectPrefix == "" {
+		return nil
+	}
+
+	msg := nats.NewMsg(n.fullSubject(preflightSubjectSuffix))
+	msg.Header.Set(nats.ExpectedLastSeqHdr, strconv.FormatUint(math.MaxUint64, 10))
+
+	if err := n.preflightPublishPermitted(ctx, msg); err != nil {
+		return errors.Wrap(ErrPreflightPublish, err.Error())
+	}
+
+	return nil
+}
+
+// preflightConsumer checks the consumer info may be requested and replies received on an inbox,
+// as binding a subscription to the consumer does.
+func (n *NatsJetstream) preflightConsumer(ctx context.Context) error {
+	if n.parameters.Consumer == nil || n.parameters.Stream == nil {
+		return nil
+	}
+
+	info := nats.NewMsg("$JS.API.CONSUMER.INFO." + n.parameters.Stream.Name + "." + n.parameters.Consumer.Name)
+	if err := n.preflightPublishPermitted(ctx, info); err != nil {
+		return errors.Wrap(ErrPreflightConsumer, err.Error())
+	}
+
+	sub, err := n.conn.SubscribeSync(nats.NewInbox())
+	if err != nil {
+		return errors.Wrap(ErrPreflightConsumer, err.Error())
+	}
+
+	defer sub.Unsubscribe() //nolint:errcheck // only used to check the permission
+
+	if err := n.preflightPermitted(ctx, "Subscription", sub.Subject); err != nil {
+		return errors.Wrap(ErrPreflightConsumer, err.Error())
+	}
+
+	return nil
+}
+
+// preflightPublishPermitted publishes the message and returns the permissions violation the server
+// reported for it, if any. JetStream API requests published without a reply subject aren't answered.
+func (n *NatsJetstream) preflightPublishPermitted(ctx context.Context, msg *nats.Msg) error {
+	if err := n.conn.PublishMsg(msg); err != nil {
+		return err
+	}
+
+	return n.preflightPermitted(ctx, "Publish", msg.Subject)
+}
+
+// preflightPermitted flushes the connection and returns the permissions violation the server
+// reported for the operation on the subject, if any.
+func (n *NatsJetstream) preflightPermitted(ctx context.Context, op, subject string) error {
+	// the server reports the violations before answering the flush
+	if err := n.conn.FlushWithContext(ctx); err != nil {
+		return err
+	}
+
+	lastErr := n.conn.LastError()
+	if lastErr == nil {
+		return nil
+	}
+
+	violation := strings.ToLower(nats.PERMISSIONS_ERR + " for " + op + " to \"" + subject + "\"")
+	if strings.Contains(strings.ToLower(lastErr.Error()), violation) {
+		return lastErr
+	}
+
+	return nil
+}
@@ -0,0 +1,93 @@
+//nolint:all
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats-server/v2/server"
+	srvtest "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	natsTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+// startPermissionsServer starts a JetStream server which users are only permitted to publish to the given subjects
+func startPermissionsServer(t *testing.T, publish map[string][]string) *server.Server {
+	t.Helper()
+
+	opts := srvtest.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+
+	for user, subjects := range publish {
+		opts.Users = append(opts.Users, &server.User{
+			Username: user,
+			Password: "pass",
+			Permissions: &server.Permissions{
+				Publish:   &server.SubjectPermission{Allow: subjects},
+				Subscribe: &server.SubjectPermission{Allow: []string{"_INBOX.>"}},
+			},
+		})
+	}
+
+	return srvtest.RunServer(&opts)
+}
+
+func TestOpenPreflight(t *testing.T) {
+	jsSrv := startPermissionsServer(t, map[string][]string{
+		"admin":     {">"},
+		"no-js":     {"facility.>"},
+		"reader":    {"$JS.API.INFO", "$JS.API.CONSUMER.INFO.>"},
+		"publisher": {"$JS.API.INFO", "facility.>"},
+	})
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	open := func(user string) error {
+		njs, err := NewNatsBroker(NatsOptions{
+			URL:                    jsSrv.ClientURL(),
+			AppName:                "test",
+			StreamUser:             user,
+			StreamPass:             "pass",
+			ConnectTimeout:         time.Second,
+			PublisherSubjectPrefix: "facility",
+			Stream:                 &NatsStreamOptions{Name: "test_stream", Subjects: []string{"facility.>"}, Retention: "limits"},
+			Consumer:               &NatsConsumerOptions{Name: "test_consumer", Pull: true},
+			PreflightTimeout:       2 * time.Second,
+		})
+		require.NoError(t, err)
+
+		err = njs.Open()
+		if err == nil {
+			njs.Close()
+		}
+
+		return err
+	}
+
+	assert.ErrorIs(t, open("no-js"), ErrPreflightJetStream)
+	assert.ErrorIs(t, open("reader"), ErrPreflightPublish)
+
+	err := open("publisher")
+	assert.ErrorIs(t, err, ErrPreflightConsumer)
+	assert.ErrorContains(t, err, "$JS.API.CONSUMER.INFO.test_stream.test_consumer")
+
+	// the stream is created by the first open, the second one probes it
+	require.NoError(t, open("admin"))
+	require.NoError(t, open("admin"))
+
+	// the publish permission probe isn't stored
+	conn, err := nats.Connect(jsSrv.ClientURL(), nats.UserInfo("admin", "pass"))
+	require.NoError(t, err)
+	defer conn.Close()
+
+	js, err := conn.JetStream()
+	require.NoError(t, err)
+
+	info, err := js.StreamInfo("test_stream")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), info.State.Msgs)
+}
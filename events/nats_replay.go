@@ -0,0 +1,260 @@
+package events
+
+import (
+	"context"
+	"encoding/base64"
+	"strconv"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+// ErrCursorStore is returned when the cursor of a replay consumer couldn't be loaded or saved.
+var ErrCursorStore = errors.New("error in replay cursor store")
+
+// CursorStore records the last stream sequence processed by the replay consumers of an app on a subject.
+type CursorStore interface {
+	// LoadCursor returns the last processed stream sequence, zero when none was saved.
+	LoadCursor(ctx context.Context, app, subject string) (uint64, error)
+
+	// SaveCursor records the last processed stream sequence.
+	SaveCursor(ctx context.Context, app, subject string, seq uint64) error
+}
+
+// kvCursorStore keeps the cursors in a JetStream KV bucket.
+type kvCursorStore struct {
+	kv nats.KeyValue
+}
+
+// NewKVCursorStore returns a CursorStore keeping the cursors in the KV bucket, so a replay
+// resumes where the previous instance of the app stopped.
+func NewKVCursorStore(kv nats.KeyValue) CursorStore {
+	return &kvCursorStore{kv: kv}
+}
+
+func (s *kvCursorStore) LoadCursor(_ context.Context, app, subject string) (uint64, error) {
+	entry, err := s.kv.Get(cursorKey(app, subject))
+	if err != nil {
+		if errors.Is(err, nats.ErrKeyNotFound) {
+			return 0, nil
+		}
+
+		return 0, errors.Wrap(ErrCursorStore, err.Error())
+	}
+
+	seq, err := strconv.ParseUint(string(entry.Value()), 10, 64)
+	if err != nil {
+		return 0, errors.Wrap(ErrCursorStore, err.Error())
+	}
+
+	return seq, nil
+}
+
+func (s *kvCursorStore) SaveCursor(_ context.Context, app, subject string, seq uint64) error {
+	if _, err := s.kv.Put(cursorKey(app, subject), []byte(strconv.FormatUint(seq, 10))); err != nil {
+		return errors.Wrap(ErrCursorStore, err.Error())
+	}
+
+	return nil
+}
+
+// cursorKey encodes the app and subject, subject wildcards aren't valid in KV keys.
+func cursorKey(app, subject string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(app)) + "." + base64.RawURLEncoding.EncodeToString([]byte(subject))
+}
+
+// ReplayOptions configures the consumer created by Replay.
+type ReplayOptions struct {
+	// Cursors records the last processed stream sequence, the replay resumes after it.
+	// The replay starts over from the StartSequence each time when unset.
+	Cursors CursorStore
+
+	// StartSequence is the stream sequence the replay starts from when no cursor was saved,
+	// all the messages are replayed when zero.
+	StartSequence uint64
+
+	// Filter skips the messages it doesn't match, they are acked without being handed out.
+	Filter MessageFilter
+
+	// Logger reports the cursors which couldn't be saved, defaults to the global zap logger.
+	Logger *zap.Logger
+}
+
+// replayCursor tracks the processed messages of a replay. The cursor only moves past the messages
+// delivered before once they were all processed, so none is skipped when resuming.
+type replayCursor struct {
+	app     string
+	subject string
+	store   CursorStore
+	logger  *zap.Logger
+
+	mu        sync.Mutex
+	pending   map[uint64]struct{}
+	processed uint64
+	saved     uint64
+}
+
+// delivered tracks a message handed out to a subscriber.
+func (c *replayCursor) delivered(seq uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if seq > c.saved {
+		c.pending[seq] = struct{}{}
+	}
+}
+
+// done records the message was acked or terminated, and saves the cursor when it moved.
+func (c *replayCursor) done(seq uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.pending, seq)
+
+	if seq > c.processed {
+		c.processed = seq
+	}
+
+	cursor := c.processed
+
+	for pending := range c.pending {
+		if pending <= cursor {
+			cursor = pending - 1
+		}
+	}
+
+	if cursor <= c.saved {
+		return
+	}
+
+	// saved under the lock so the cursor never moves back
+	if err := c.store.SaveCursor(context.Background(), c.app, c.subject, cursor); err != nil {
+		c.logger.Warn("replay cursor not saved", zap.String("subject", c.subject), zap.Uint64("sequence", cursor), zap.Error(err))
+		return
+	}
+
+	c.saved = cursor
+}
+
+// Replay creates an ephemeral consumer replaying the messages on the subject and returns a channel
+// handing them out. With a CursorStore the last stream sequence processed, acked or terminated,
+// is saved per app and subject and the next replay resumes after it.
+//
+// The replay lasts until the context is done or the stream is closed, the channel is closed then
+// and the server removes the consumer.
+func (n *NatsJetstream) Replay(ctx context.Context, subject string, opts ReplayOptions) (MsgCh, error) {
+	if n.jsctx == nil {
+		return nil, errors.Wrap(ErrNatsJetstreamAddConsumer, "Jetstream context is not setup")
+	}
+
+	if n.isClosed() {
+		return nil, ErrNatsClosed
+	}
+
+	if subject == "" {
+		return nil, errors.Wrap(ErrSubscription, "subject is required")
+	}
+
+	stream, err := n.waitSubjectStream(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Logger == nil {
+		opts.Logger = zap.L()
+	}
+
+	var cursor *replayCursor
+
+	start := opts.StartSequence
+
+	if opts.Cursors != nil {
+		var appName string
+		if n.parameters != nil {
+			appName = n.parameters.AppName
+		}
+
+		saved, err := opts.Cursors.LoadCursor(ctx, appName, subject)
+		if err != nil {
+			return nil, err
+		}
+
+		if saved > 0 {
+			start = saved + 1
+		}
+
+		cursor = &replayCursor{
+			app:     appName,
+			subject: subject,
+			store:   opts.Cursors,
+			logger:  opts.Logger,
+			pending: map[uint64]struct{}{},
+			saved:   saved,
+		}
+	}
+
+	s := &subjectSubscription{
+		ch:   make(MsgCh),
+		done: make(chan struct{}),
+	}
+
+	callback := func(msg *nats.Msg) {
+		defer recoverCallback(msg)
+
+		if !s.enter() {
+			_ = msg.Nak()
+			return
+		}
+
+		defer s.callbacks.Done()
+
+		var processed func()
+
+		if cursor != nil {
+			if md, err := msg.Metadata(); err == nil {
+				seq := md.Sequence.Stream
+				cursor.delivered(seq)
+				processed = func() { cursor.done(seq) }
+			}
+		}
+
+		n.handOut(msg, s.ch, s.done, opts.Filter, processed)
+	}
+
+	subOpts := []nats.SubOpt{nats.BindStream(stream), nats.ManualAck(), nats.AckExplicit(), nats.MaxDeliver(consumerMaxDeliver)}
+
+	if start > 0 {
+		subOpts = append(subOpts, nats.StartSequence(start))
+	} else {
+		subOpts = append(subOpts, nats.DeliverAll())
+	}
+
+	s.sub, err = n.jsctx.Subscribe(subject, callback, subOpts...)
+	if err != nil {
+		return nil, errors.Wrap(ErrSubscription, err.Error()+": "+subject)
+	}
+
+	n.callbacksMu.Lock()
+	if n.subscriberChClosed {
+		n.callbacksMu.Unlock()
+		s.stop()
+
+		return nil, ErrNatsClosed
+	}
+
+	n.subjectSubscriptions = append(n.subjectSubscriptions, s)
+	n.callbacksMu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			n.removeSubjectSubscription(s)
+			s.stop()
+		case <-s.done:
+		}
+	}()
+
+	return s.ch, nil
+}
@@ -0,0 +1,128 @@
+//nolint:all
+package events
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	natsTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+func TestReplayResumesFromCursor(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, js := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName:                "replayer",
+		PublisherSubjectPrefix: "audit",
+		Stream: &NatsStreamOptions{
+			Name:      "audit_stream",
+			Subjects:  []string{"audit.>"},
+			Retention: "limits",
+		},
+	}
+	require.NoError(t, njs.addStream())
+
+	kv, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: "cursors"})
+	require.NoError(t, err)
+
+	cursors := NewKVCursorStore(kv)
+
+	for i := 1; i <= 5; i++ {
+		require.NoError(t, njs.Publish(context.TODO(), "servers", []byte(fmt.Sprint(i))))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	ch, err := njs.Replay(ctx, "audit.servers", ReplayOptions{Cursors: cursors})
+	require.NoError(t, err)
+
+	require.NoError(t, readMsg(t, ch).Ack())
+	require.NoError(t, readMsg(t, ch).Term())
+
+	// the cursor doesn't move past the third message until it is processed
+	third := readMsg(t, ch)
+	assert.Equal(t, []byte("3"), third.Data())
+	require.NoError(t, readMsg(t, ch).Ack())
+
+	seq, err := cursors.LoadCursor(context.TODO(), "replayer", "audit.servers")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), seq)
+
+	cancel()
+
+	// messages handed out while stopping aren't processed
+	for range ch {
+	}
+
+	// the next replay resumes after the cursor
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+
+	ch, err = njs.Replay(ctx, "audit.servers", ReplayOptions{Cursors: cursors})
+	require.NoError(t, err)
+
+	for _, want := range []string{"3", "4", "5"} {
+		msg := readMsg(t, ch)
+		assert.Equal(t, []byte(want), msg.Data())
+		require.NoError(t, msg.Ack())
+	}
+
+	seq, err = cursors.LoadCursor(context.TODO(), "replayer", "audit.servers")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(5), seq)
+
+	// cursors are kept per subject
+	seq, err = cursors.LoadCursor(context.TODO(), "replayer", "audit.>")
+	require.NoError(t, err)
+	assert.Equal(t, uint64(0), seq)
+}
+
+func TestReplayWithoutCursor(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName:                "replayer",
+		PublisherSubjectPrefix: "audit",
+		Stream: &NatsStreamOptions{
+			Name:      "audit_stream",
+			Subjects:  []string{"audit.>"},
+			Retention: "limits",
+		},
+	}
+	require.NoError(t, njs.addStream())
+
+	for i := 1; i <= 3; i++ {
+		require.NoError(t, njs.Publish(context.TODO(), "servers", []byte(fmt.Sprint(i))))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	ch, err := njs.Replay(ctx, "audit.servers", ReplayOptions{StartSequence: 2})
+	require.NoError(t, err)
+
+	for _, want := range []string{"2", "3"} {
+		msg := readMsg(t, ch)
+		assert.Equal(t, []byte(want), msg.Data())
+		require.NoError(t, msg.Ack())
+	}
+
+	_, err = njs.Replay(ctx, "", ReplayOptions{})
+	assert.ErrorIs(t, err, ErrSubscription)
+}
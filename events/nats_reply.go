@@ -0,0 +1,124 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+)
+
+const (
+	// CorrelationIDHeader is the message header carrying the ID used to match a reply to its request.
+	CorrelationIDHeader = "Correlation-Id"
+
+	// ReplySubjectHeader is the message header carrying the subject a reply is to be published on.
+	//
+	// JetStream overwrites the reply subject of a published message with its own
+	// publish acknowledgement inbox, the reply subject is carried in a header instead.
+	ReplySubjectHeader = "Reply-Subject"
+)
+
+var (
+	// ErrNatsReply is returned when a reply could not be sent or received.
+	ErrNatsReply = errors.New("error in NATS request/reply")
+
+	// ErrNoReplySubject is returned when replying to a message that was not published awaiting a reply.
+	ErrNoReplySubject = errors.New("message has no reply subject")
+)
+
+// PublishAndAwaitReply publishes a message onto the NATS Jetstream and waits up to the timeout
+// for a reply published with ReplyTo. The message is given a unique reply subject and correlation ID
+// which are set as the ReplySubjectHeader and CorrelationIDHeader headers. The data is scrubbed
+// by the payload scrubbers, see SetPayloadScrubbers.
+//
+// NOTE: The subject passed here will be prepended with any configured PublisherSubjectPrefix.
+func (n *NatsJetstream) PublishAndAwaitReply(ctx context.Context, subjectSuffix string, data []byte, timeout time.Duration) ([]byte, error) {
+	if n.jsctx == nil {
+		return nil, errors.Wrap(ErrNatsJetstream, "Jetstream context is not setup")
+	}
+
+	if n.conn == nil {
+		return nil, errors.Wrap(ErrNatsConn, "NATS connection is not established")
+	}
+
+	subject := n.fullSubject(subjectSuffix)
+	if err := n.validatePublishSubject(subject); err != nil {
+		return nil, err
+	}
+
+	data, err := n.scrubbers.scrub(subject, data)
+	if err != nil {
+		return nil, err
+	}
+
+	inbox := n.conn.NewRespInbox()
+	correlationID := uuid.NewString()
+
+	// subscribe before publishing to ensure the reply is not missed.
+	sub, err := n.conn.SubscribeSync(inbox)
+	if err != nil {
+		return nil, errors.Wrap(ErrNatsReply, err.Error())
+	}
+
+	defer sub.Unsubscribe() //nolint:errcheck // the subscription is only used for this request
+
+	msg := nats.NewMsg(subject)
+	msg.Data = data
+
+	injectOtelTraceContext(ctx, msg)
+
+	msg.Header.Set(ReplySubjectHeader, inbox)
+	msg.Header.Set(CorrelationIDHeader, correlationID)
+
+	if _, err := n.jsctx.PublishMsg(msg, nats.Context(ctx)); err != nil {
+		return nil, err
+	}
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	for {
+		reply, err := sub.NextMsgWithContext(waitCtx)
+		if err != nil {
+			return nil, errors.Wrap(ErrNatsReply, err.Error())
+		}
+
+		// discard any reply that isn't for this request.
+		if reply.Header.Get(CorrelationIDHeader) == correlationID {
+			return reply.Data, nil
+		}
+	}
+}
+
+// ReplyTo publishes the data as a reply to a message published with PublishAndAwaitReply, the data
+// is scrubbed by the payload scrubbers, see SetPayloadScrubbers.
+func (n *NatsJetstream) ReplyTo(msg Message, data []byte) error {
+	if n.conn == nil {
+		return errors.Wrap(ErrNatsConn, "NATS connection is not established")
+	}
+
+	nm, err := AsNatsMsg(msg)
+	if err != nil {
+		return errors.Wrap(ErrNatsReply, err.Error())
+	}
+
+	if nm.Header == nil || nm.Header.Get(ReplySubjectHeader) == "" {
+		return ErrNoReplySubject
+	}
+
+	reply := nats.NewMsg(nm.Header.Get(ReplySubjectHeader))
+
+	if reply.Data, err = n.scrubbers.scrub(reply.Subject, data); err != nil {
+		return err
+	}
+
+	reply.Header.Set(CorrelationIDHeader, nm.Header.Get(CorrelationIDHeader))
+
+	if err := n.conn.PublishMsg(reply); err != nil {
+		return errors.Wrap(ErrNatsReply, err.Error())
+	}
+
+	return nil
+}
@@ -0,0 +1,153 @@
+//nolint:all
+package events
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+
+	natsTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+func TestPublishAndAwaitReply(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName: "TestPublishAndAwaitReply",
+		Stream: &NatsStreamOptions{
+			Name: "test_stream",
+			Subjects: []string{
+				"pre.command",
+			},
+			Retention: "workQueue",
+		},
+		Consumer: &NatsConsumerOptions{
+			Name: "test_consumer",
+			Pull: true,
+			SubscribeSubjects: []string{
+				"pre.command",
+			},
+			FilterSubject: "pre.command",
+		},
+		PublisherSubjectPrefix: "pre",
+	}
+	require.NoError(t, njs.addStream())
+	require.NoError(t, njs.addConsumer())
+
+	_, err := njs.Subscribe(context.TODO())
+	require.NoError(t, err)
+
+	// the responder answers the command with a result.
+	go func() {
+		msgs, err := njs.PullMsg(context.TODO(), 1)
+		if err != nil || len(msgs) != 1 {
+			return
+		}
+
+		_ = msgs[0].Ack()
+		_ = njs.ReplyTo(msgs[0], append([]byte("result: "), msgs[0].Data()...))
+	}()
+
+	reply, err := njs.PublishAndAwaitReply(context.TODO(), "command", []byte("do it"), 5*time.Second)
+	require.NoError(t, err)
+	require.Equal(t, []byte("result: do it"), reply)
+
+	// nobody answers this time.
+	_, err = njs.PublishAndAwaitReply(context.TODO(), "command", []byte("do it again"), 100*time.Millisecond)
+	require.ErrorIs(t, err, ErrNatsReply)
+}
+
+func TestReplyToWithoutReplySubject(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	err := njs.ReplyTo(&natsMsg{msg: nats.NewMsg("pre.command")}, []byte("result"))
+	require.ErrorIs(t, err, ErrNoReplySubject)
+
+	err = njs.ReplyTo(&bogusMsg{}, []byte("result"))
+	require.ErrorIs(t, err, ErrNatsReply)
+}
+
+func TestPublishAndAwaitReplyScrubbed(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName: "TestPublishAndAwaitReplyScrubbed",
+		Stream: &NatsStreamOptions{
+			Name:      "test_stream",
+			Subjects:  []string{"pre.command"},
+			Retention: "workQueue",
+		},
+		Consumer: &NatsConsumerOptions{
+			Name:              "test_consumer",
+			Pull:              true,
+			SubscribeSubjects: []string{"pre.command"},
+			FilterSubject:     "pre.command",
+		},
+		PublisherSubjectPrefix: "pre",
+	}
+	require.NoError(t, njs.addStream())
+	require.NoError(t, njs.addConsumer())
+
+	_, err := njs.Subscribe(context.TODO())
+	require.NoError(t, err)
+
+	js, err := NewJSONPathScrubber("password")
+	require.NoError(t, err)
+
+	njs.SetPayloadScrubbers(js)
+
+	requests := make(chan []byte, 1)
+
+	go func() {
+		msgs, err := njs.PullMsg(context.TODO(), 1)
+		if err != nil || len(msgs) != 1 {
+			return
+		}
+
+		_ = msgs[0].Ack()
+
+		requests <- msgs[0].Data()
+
+		_ = njs.ReplyTo(msgs[0], []byte(`{"password":"reply-secret","status":"done"}`))
+	}()
+
+	reply, err := njs.PublishAndAwaitReply(context.TODO(), "command", []byte(`{"password":"request-secret"}`), 5*time.Second)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"password":"[REDACTED]","status":"done"}`, string(reply))
+
+	require.JSONEq(t, `{"password":"[REDACTED]"}`, string(<-requests))
+	require.Equal(t, PayloadScrubStats{Payloads: 2, Redactions: 2}, njs.PayloadScrubStats())
+
+	// requests and replies failing to be scrubbed aren't published
+	njs.SetPayloadScrubbers(PayloadScrubberFunc(func(string, []byte) ([]byte, int, error) {
+		return nil, 0, errors.New("boom")
+	}))
+
+	_, err = njs.PublishAndAwaitReply(context.TODO(), "command", []byte(`{"password":"request-secret"}`), 100*time.Millisecond)
+	require.ErrorIs(t, err, ErrPayloadScrub)
+
+	msg := nats.NewMsg("pre.command")
+	msg.Header.Set(ReplySubjectHeader, "_INBOX.test")
+
+	err = njs.ReplyTo(&natsMsg{msg: msg}, []byte(`{"password":"reply-secret"}`))
+	require.ErrorIs(t, err, ErrPayloadScrub)
+}
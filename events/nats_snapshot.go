@@ -0,0 +1,295 @@
+package events
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+)
+
+const (
+	// JetStream API subjects to snapshot and restore streams.
+	jsAPIStreamSnapshotT = "$JS.API.STREAM.SNAPSHOT.%s"
+	jsAPIStreamRestoreT  = "$JS.API.STREAM.RESTORE.%s"
+
+	// size of the chunks sent to the server on restore.
+	restoreChunkSize = 128 * 1024
+)
+
+var (
+	// ErrNatsSnapshot is returned when a stream snapshot fails.
+	ErrNatsSnapshot = errors.New("error taking NATS Jetstream stream snapshot")
+
+	// ErrNatsRestore is returned when a stream restore fails.
+	ErrNatsRestore = errors.New("error restoring NATS Jetstream stream snapshot")
+)
+
+// SnapshotProgressFunc is invoked with the total number of bytes transferred so far.
+type SnapshotProgressFunc func(bytes uint64)
+
+// SnapshotOption configures a stream snapshot or restore.
+type SnapshotOption func(*snapshotOptions)
+
+type snapshotOptions struct {
+	progress    SnapshotProgressFunc
+	noConsumers bool
+	checkMsgs   bool
+}
+
+// WithSnapshotProgress sets a function invoked as snapshot data is transferred.
+func WithSnapshotProgress(fn SnapshotProgressFunc) SnapshotOption {
+	return func(o *snapshotOptions) {
+		o.progress = fn
+	}
+}
+
+// WithSnapshotNoConsumers excludes the stream consumers from the snapshot.
+func WithSnapshotNoConsumers() SnapshotOption {
+	return func(o *snapshotOptions) {
+		o.noConsumers = true
+	}
+}
+
+// WithSnapshotCheckMsgs has the server verify all message checksums before taking the snapshot.
+func WithSnapshotCheckMsgs() SnapshotOption {
+	return func(o *snapshotOptions) {
+		o.checkMsgs = true
+	}
+}
+
+// snapshotHeader precedes the snapshot data written by SnapshotStream,
+// it holds what's required to restore the stream.
+type snapshotHeader struct {
+	Config nats.StreamConfig `json:"config"`
+	State  nats.StreamState  `json:"state"`
+}
+
+type jsAPIError struct {
+	Code        int    `json:"code"`
+	Description string `json:"description"`
+}
+
+type jsAPIResponse struct {
+	Error *jsAPIError `json:"error,omitempty"`
+}
+
+func (r *jsAPIResponse) err() error {
+	if r.Error == nil {
+		return nil
+	}
+
+	return fmt.Errorf("%s (%d)", r.Error.Description, r.Error.Code) //nolint:goerr113 // wrapped by callers
+}
+
+type jsAPIStreamSnapshotRequest struct {
+	DeliverSubject string `json:"deliver_subject"`
+	NoConsumers    bool   `json:"no_consumers,omitempty"`
+	CheckMsgs      bool   `json:"jsck,omitempty"`
+}
+
+type jsAPIStreamSnapshotResponse struct {
+	jsAPIResponse
+	Config *nats.StreamConfig `json:"config,omitempty"`
+	State  *nats.StreamState  `json:"state,omitempty"`
+}
+
+type jsAPIStreamRestoreRequest struct {
+	Config nats.StreamConfig `json:"config"`
+	State  nats.StreamState  `json:"state"`
+}
+
+type jsAPIStreamRestoreResponse struct {
+	jsAPIResponse
+	DeliverSubject string `json:"deliver_subject"`
+}
+
+// SnapshotStream writes a snapshot of the configured stream to w, along with the stream
+// configuration required to restore it with RestoreStream.
+func (n *NatsJetstream) SnapshotStream(ctx context.Context, w io.Writer, opts ...SnapshotOption) error {
+	if n.conn == nil {
+		return errors.Wrap(ErrNatsConn, "NATS connection is not established")
+	}
+
+	if n.parameters == nil || n.parameters.Stream == nil {
+		return errors.Wrap(ErrNatsConfig, "stream parameters required")
+	}
+
+	var so snapshotOptions
+	for _, opt := range opts {
+		opt(&so)
+	}
+
+	inbox := nats.NewInbox()
+
+	// subscribe before requesting the snapshot to ensure no chunks are missed.
+	sub, err := n.conn.SubscribeSync(inbox)
+	if err != nil {
+		return errors.Wrap(ErrNatsSnapshot, err.Error())
+	}
+
+	defer sub.Unsubscribe() //nolint:errcheck // the subscription is only used for this snapshot
+
+	var resp jsAPIStreamSnapshotResponse
+
+	req := jsAPIStreamSnapshotRequest{
+		DeliverSubject: inbox,
+		NoConsumers:    so.noConsumers,
+		CheckMsgs:      so.checkMsgs,
+	}
+
+	if err := n.jsAPIRequest(ctx, fmt.Sprintf(jsAPIStreamSnapshotT, n.parameters.Stream.Name), req, &resp); err != nil {
+		return errors.Wrap(ErrNatsSnapshot, err.Error())
+	}
+
+	if resp.Config == nil || resp.State == nil {
+		return errors.Wrap(ErrNatsSnapshot, "stream configuration missing in response")
+	}
+
+	header, err := json.Marshal(snapshotHeader{Config: *resp.Config, State: *resp.State})
+	if err != nil {
+		return errors.Wrap(ErrNatsSnapshot, err.Error())
+	}
+
+	if _, err := w.Write(append(header, '\n')); err != nil {
+		return errors.Wrap(ErrNatsSnapshot, err.Error())
+	}
+
+	var transferred uint64
+
+	for {
+		chunk, err := sub.NextMsgWithContext(ctx)
+		if err != nil {
+			return errors.Wrap(ErrNatsSnapshot, err.Error())
+		}
+
+		// an empty chunk marks the end of the snapshot.
+		if len(chunk.Data) == 0 {
+			return nil
+		}
+
+		if _, err := w.Write(chunk.Data); err != nil {
+			return errors.Wrap(ErrNatsSnapshot, err.Error())
+		}
+
+		transferred += uint64(len(chunk.Data))
+		if so.progress != nil {
+			so.progress(transferred)
+		}
+
+		// ack the chunk for flow control.
+		if chunk.Reply != "" {
+			if err := chunk.Respond(nil); err != nil {
+				return errors.Wrap(ErrNatsSnapshot, err.Error())
+			}
+		}
+	}
+}
+
+// RestoreStream restores a stream from a snapshot written by SnapshotStream. The stream
+// is restored with the configuration it had when the snapshot was taken and must not exist.
+func (n *NatsJetstream) RestoreStream(ctx context.Context, r io.Reader, opts ...SnapshotOption) error {
+	if n.conn == nil {
+		return errors.Wrap(ErrNatsConn, "NATS connection is not established")
+	}
+
+	var so snapshotOptions
+	for _, opt := range opts {
+		opt(&so)
+	}
+
+	br := bufio.NewReader(r)
+
+	line, err := br.ReadBytes('\n')
+	if err != nil {
+		return errors.Wrap(ErrNatsRestore, "reading snapshot header: "+err.Error())
+	}
+
+	var header snapshotHeader
+	if err := json.Unmarshal(line, &header); err != nil {
+		return errors.Wrap(ErrNatsRestore, "invalid snapshot header: "+err.Error())
+	}
+
+	var resp jsAPIStreamRestoreResponse
+
+	req := jsAPIStreamRestoreRequest(header)
+
+	if err := n.jsAPIRequest(ctx, fmt.Sprintf(jsAPIStreamRestoreT, header.Config.Name), req, &resp); err != nil {
+		return errors.Wrap(ErrNatsRestore, err.Error())
+	}
+
+	var transferred uint64
+
+	chunk := make([]byte, restoreChunkSize)
+
+	for {
+		read, err := io.ReadFull(br, chunk)
+		if read > 0 {
+			reply, rerr := n.conn.RequestWithContext(ctx, resp.DeliverSubject, chunk[:read])
+			if rerr != nil {
+				return errors.Wrap(ErrNatsRestore, rerr.Error())
+			}
+
+			// the server replies with an error message if the chunk couldn't be stored.
+			if len(reply.Data) > 0 {
+				return errors.Wrap(ErrNatsRestore, string(reply.Data))
+			}
+
+			transferred += uint64(read)
+			if so.progress != nil {
+				so.progress(transferred)
+			}
+		}
+
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+
+		if err != nil {
+			return errors.Wrap(ErrNatsRestore, err.Error())
+		}
+	}
+
+	// an empty chunk marks the end of the snapshot, the server replies once the stream was restored.
+	reply, err := n.conn.RequestWithContext(ctx, resp.DeliverSubject, nil)
+	if err != nil {
+		return errors.Wrap(ErrNatsRestore, err.Error())
+	}
+
+	var result jsAPIResponse
+	if err := json.Unmarshal(reply.Data, &result); err != nil {
+		return errors.Wrap(ErrNatsRestore, err.Error())
+	}
+
+	if err := result.err(); err != nil {
+		return errors.Wrap(ErrNatsRestore, err.Error())
+	}
+
+	return nil
+}
+
+// jsAPIRequest sends a request to the JetStream API and decodes its response.
+func (n *NatsJetstream) jsAPIRequest(ctx context.Context, subject string, req, resp interface{}) error {
+	body, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+
+	msg, err := n.conn.RequestWithContext(ctx, subject, body)
+	if err != nil {
+		return err
+	}
+
+	if err := json.Unmarshal(msg.Data, resp); err != nil {
+		return err
+	}
+
+	if r, ok := resp.(interface{ err() error }); ok {
+		return r.err()
+	}
+
+	return nil
+}
@@ -0,0 +1,76 @@
+//nolint:all
+package events
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	natsTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+func TestSnapshotAndRestoreStream(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, jsCtx := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName: "TestSnapshotAndRestoreStream",
+		Stream: &NatsStreamOptions{
+			Name: "test_stream",
+			Subjects: []string{
+				"pre.>",
+			},
+			Retention: "limits",
+		},
+		PublisherSubjectPrefix: "pre",
+	}
+	require.NoError(t, njs.addStream())
+
+	for i := 0; i < 10; i++ {
+		require.NoError(t, njs.Publish(context.TODO(), "things", []byte("some data")))
+	}
+
+	var (
+		buf      bytes.Buffer
+		progress uint64
+	)
+
+	err := njs.SnapshotStream(context.TODO(), &buf, WithSnapshotProgress(func(b uint64) { progress = b }))
+	require.NoError(t, err)
+	require.NotZero(t, progress)
+
+	// the stream exists, it can't be restored over.
+	err = njs.RestoreStream(context.TODO(), bytes.NewReader(buf.Bytes()))
+	require.ErrorIs(t, err, ErrNatsRestore)
+
+	require.NoError(t, jsCtx.DeleteStream("test_stream"))
+
+	progress = 0
+
+	err = njs.RestoreStream(context.TODO(), &buf, WithSnapshotProgress(func(b uint64) { progress = b }))
+	require.NoError(t, err)
+	require.NotZero(t, progress)
+
+	info, err := jsCtx.StreamInfo("test_stream")
+	require.NoError(t, err)
+	require.Equal(t, uint64(10), info.State.Msgs)
+	require.Equal(t, []string{"pre.>"}, info.Config.Subjects)
+}
+
+func TestRestoreStreamInvalidSnapshot(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	err := njs.RestoreStream(context.TODO(), bytes.NewReader([]byte("not a snapshot")))
+	require.ErrorIs(t, err, ErrNatsRestore)
+}
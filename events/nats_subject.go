@@ -0,0 +1,53 @@
+package events
+
+import (
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+)
+
+// ErrPublishSubjectNotInStream is returned when publishing to a subject not covered by the stream
+// and NatsOptions.ValidatePublishSubjects is set.
+var ErrPublishSubjectNotInStream = errors.New("publish subject is not covered by the NATS Jetstream stream")
+
+// validatePublishSubject verifies the subject is stored by the configured stream, or any stream
+// when none is configured. Subjects which pass are cached and not looked up again, failures are not
+// cached so subjects added to the stream later are accepted.
+//
+// The subjects published by a MultiStreamBroker are always verified to be covered by one of its streams.
+func (n *NatsJetstream) validatePublishSubject(subject string) error {
+	if len(n.streams) > 0 {
+		if n.streamFor(subject) == "" {
+			return errors.Wrap(ErrPublishSubjectNotInStream, subject+" isn't covered by the streams of the broker")
+		}
+
+		return nil
+	}
+
+	if !n.parameters.ValidatePublishSubjects {
+		return nil
+	}
+
+	if _, ok := n.validSubjects.Load(subject); ok {
+		return nil
+	}
+
+	name, err := n.jsctx.StreamNameBySubject(subject)
+	if err != nil {
+		if errors.Is(err, nats.ErrNoMatchingStream) {
+			return errors.Wrap(ErrPublishSubjectNotInStream, subject)
+		}
+
+		return errors.Wrap(ErrNatsJetstream, err.Error())
+	}
+
+	if n.parameters.Stream != nil && n.parameters.Stream.Name != "" && name != n.parameters.Stream.Name {
+		return errors.Wrap(
+			ErrPublishSubjectNotInStream,
+			subject+" is stored by stream "+name+", not "+n.parameters.Stream.Name,
+		)
+	}
+
+	n.validSubjects.Store(subject, struct{}{})
+
+	return nil
+}
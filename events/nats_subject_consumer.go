@@ -0,0 +1,261 @@
+package events
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+)
+
+// consumer names can't hold the subject token separator and wildcards.
+var consumerNameReplacer = strings.NewReplacer(".", "_", "*", "any", ">", "all", " ", "_")
+
+// SubjectConsumerOptions configures the durable consumer created by SubscribeSubject.
+type SubjectConsumerOptions struct {
+	// Name is the durable consumer name, defaults to the AppName and the subject with
+	// its dots and wildcards replaced, e.g. myapp-facility_ams1 for facility.ams1.
+	Name string
+
+	// QueueGroup shares the messages between the subscribers in the group.
+	QueueGroup string
+
+	// AckWait defaults to the 5 minutes consumer default.
+	AckWait time.Duration
+
+	// MaxAckPending defaults to the 100 messages consumer default.
+	MaxAckPending int
+
+	// InactiveThreshold removes the consumer once it had no subscriber for this long,
+	// the consumer is kept when zero.
+	InactiveThreshold time.Duration
+
+	// Filter skips the messages it doesn't match, they are acked without being handed out.
+	Filter MessageFilter
+}
+
+func (o *SubjectConsumerOptions) consumerName(appName, subject string) string {
+	if o.Name != "" {
+		return o.Name
+	}
+
+	name := consumerNameReplacer.Replace(subject)
+	if appName == "" {
+		return name
+	}
+
+	return appName + "-" + name
+}
+
+// subjectSubscription is a subscription made with SubscribeSubject, handing out its
+// messages on a dedicated channel.
+type subjectSubscription struct {
+	sub  *nats.Subscription
+	ch   MsgCh
+	done chan struct{}
+
+	// callbacks tracks the subscription callbacks sending on the ch, it is closed once they returned.
+	mu        sync.Mutex
+	callbacks sync.WaitGroup
+	stopped   bool
+}
+
+// enter registers a callback about to hand a message out, it returns false once the subscription is stopped.
+func (s *subjectSubscription) enter() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return false
+	}
+
+	s.callbacks.Add(1)
+
+	return true
+}
+
+// stop unsubscribes and closes the channel once no callback is sending on it, messages
+// waiting on a subscriber are Nak'ed. The consumer is kept.
+func (s *subjectSubscription) stop() {
+	s.mu.Lock()
+	alreadyStopped := s.stopped
+	s.stopped = true
+	s.mu.Unlock()
+
+	if alreadyStopped {
+		return
+	}
+
+	close(s.done)
+
+	if s.sub.IsValid() {
+		_ = s.sub.Unsubscribe()
+	}
+
+	s.callbacks.Wait()
+
+	close(s.ch)
+}
+
+// SubscribeSubject creates a durable push consumer filtered on the subject, or binds to it when
+// it exists, and returns a channel handing out its messages. This lets consumers be added at
+// runtime, e.g. a processor for each facility, without being configured in the NatsOptions.
+//
+// The consumer is created on the configured stream, or the stream storing the subject when none
+// is configured, waiting for it to be created with a SubscribeWaitTimeout. An existing consumer
+// must filter on the same subject.
+//
+// The subscription lasts until the context is done or the stream is closed, the channel is closed
+// then. The consumer itself is kept, unless an InactiveThreshold is set.
+func (n *NatsJetstream) SubscribeSubject(ctx context.Context, subject string, opts SubjectConsumerOptions) (MsgCh, error) {
+	if n.jsctx == nil {
+		return nil, errors.Wrap(ErrNatsJetstreamAddConsumer, "Jetstream context is not setup")
+	}
+
+	if n.isClosed() {
+		return nil, ErrNatsClosed
+	}
+
+	if subject == "" {
+		return nil, errors.Wrap(ErrSubscription, "subject is required")
+	}
+
+	stream, err := n.waitSubjectStream(ctx, subject)
+	if err != nil {
+		return nil, err
+	}
+
+	var appName string
+	if n.parameters != nil {
+		appName = n.parameters.AppName
+	}
+
+	name := opts.consumerName(appName, subject)
+
+	if err := n.ensureSubjectConsumer(stream, name, subject, &opts); err != nil {
+		return nil, err
+	}
+
+	s := &subjectSubscription{
+		ch:   make(MsgCh),
+		done: make(chan struct{}),
+	}
+
+	callback := func(msg *nats.Msg) {
+		defer recoverCallback(msg)
+
+		if !s.enter() {
+			_ = msg.Nak()
+			return
+		}
+
+		defer s.callbacks.Done()
+
+		n.handOut(msg, s.ch, s.done, opts.Filter, nil)
+	}
+
+	subOpts := []nats.SubOpt{nats.Bind(stream, name), nats.ManualAck()}
+
+	if opts.QueueGroup != "" {
+		s.sub, err = n.jsctx.QueueSubscribe(subject, opts.QueueGroup, callback, subOpts...)
+	} else {
+		s.sub, err = n.jsctx.Subscribe(subject, callback, subOpts...)
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(ErrSubscription, err.Error()+": "+subject)
+	}
+
+	n.callbacksMu.Lock()
+	if n.subscriberChClosed {
+		n.callbacksMu.Unlock()
+		s.stop()
+
+		return nil, ErrNatsClosed
+	}
+
+	n.subjectSubscriptions = append(n.subjectSubscriptions, s)
+	n.callbacksMu.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			n.removeSubjectSubscription(s)
+			s.stop()
+		case <-s.done:
+		}
+	}()
+
+	return s.ch, nil
+}
+
+// subjectStream returns the configured stream, or the stream storing the subject when none is configured.
+func (n *NatsJetstream) subjectStream(subject string) (string, error) {
+	if n.parameters != nil && n.parameters.Stream != nil && n.parameters.Stream.Name != "" {
+		return n.parameters.Stream.Name, nil
+	}
+
+	stream, err := n.jsctx.StreamNameBySubject(subject)
+	if err != nil {
+		return "", errors.Wrap(ErrSubscription, err.Error()+": "+subject)
+	}
+
+	return stream, nil
+}
+
+// ensureSubjectConsumer creates the durable consumer filtered on the subject unless it exists.
+func (n *NatsJetstream) ensureSubjectConsumer(stream, name, subject string, opts *SubjectConsumerOptions) error {
+	info, err := n.jsctx.ConsumerInfo(stream, name)
+	if err == nil {
+		if info.Config.FilterSubject != subject {
+			return errors.Wrap(ErrSubscription, "consumer "+name+" filters on "+info.Config.FilterSubject+", not "+subject)
+		}
+
+		return nil
+	}
+
+	if !errors.Is(err, nats.ErrConsumerNotFound) {
+		return errors.Wrap(err, ErrNatsJetstreamAddConsumer.Error()+" consumer.Name="+name)
+	}
+
+	cfg := &nats.ConsumerConfig{
+		Durable:           name,
+		DeliverSubject:    nats.NewInbox(),
+		DeliverGroup:      opts.QueueGroup,
+		FilterSubject:     subject,
+		MaxDeliver:        consumerMaxDeliver,
+		AckPolicy:         consumerAckPolicy,
+		AckWait:           consumerAckWait,
+		MaxAckPending:     consumerMaxAckPending,
+		DeliverPolicy:     nats.DeliverAllPolicy,
+		InactiveThreshold: opts.InactiveThreshold,
+	}
+
+	if opts.AckWait > 0 {
+		cfg.AckWait = opts.AckWait
+	}
+
+	if opts.MaxAckPending > 0 {
+		cfg.MaxAckPending = opts.MaxAckPending
+	}
+
+	if _, err := n.jsctx.AddConsumer(stream, cfg); err != nil {
+		return errors.Wrap(err, ErrNatsJetstreamAddConsumer.Error())
+	}
+
+	return nil
+}
+
+func (n *NatsJetstream) removeSubjectSubscription(s *subjectSubscription) {
+	n.callbacksMu.Lock()
+	defer n.callbacksMu.Unlock()
+
+	for i, sub := range n.subjectSubscriptions {
+		if sub == s {
+			n.subjectSubscriptions = append(n.subjectSubscriptions[:i], n.subjectSubscriptions[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,114 @@
+//nolint:all
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	natsTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+func readMsg(t *testing.T, ch MsgCh) Message {
+	t.Helper()
+
+	select {
+	case msg, ok := <-ch:
+		require.True(t, ok, "channel closed")
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no message received")
+	}
+
+	return nil
+}
+
+func requireClosed(t *testing.T, ch MsgCh) {
+	t.Helper()
+
+	select {
+	case _, ok := <-ch:
+		require.False(t, ok, "unexpected message")
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed")
+	}
+}
+
+func TestSubscribeSubject(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName:                "test",
+		PublisherSubjectPrefix: "facility",
+		Stream: &NatsStreamOptions{
+			Name:      "test_stream",
+			Subjects:  []string{"facility.>"},
+			Retention: "limits",
+		},
+	}
+	require.NoError(t, njs.addStream())
+
+	// the stream storing the subject is looked up when none is configured
+	njs.parameters.Stream = nil
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	ams1, err := njs.SubscribeSubject(ctx, "facility.ams1", SubjectConsumerOptions{})
+	require.NoError(t, err)
+
+	dfw1, err := njs.SubscribeSubject(context.Background(), "facility.dfw1", SubjectConsumerOptions{MaxAckPending: 10})
+	require.NoError(t, err)
+
+	require.NoError(t, njs.Publish(context.TODO(), "ams1", []byte("ams1")))
+	require.NoError(t, njs.Publish(context.TODO(), "dfw1", []byte("dfw1")))
+
+	msg := readMsg(t, ams1)
+	assert.Equal(t, "facility.ams1", msg.Subject())
+	require.NoError(t, msg.Ack())
+
+	msg = readMsg(t, dfw1)
+	assert.Equal(t, "facility.dfw1", msg.Subject())
+	require.NoError(t, msg.Ack())
+
+	info, err := njs.jsctx.ConsumerInfo("test_stream", "test-facility_dfw1")
+	require.NoError(t, err)
+	assert.Equal(t, "facility.dfw1", info.Config.FilterSubject)
+	assert.Equal(t, 10, info.Config.MaxAckPending)
+
+	// the subscription ends with the context, the consumer is kept
+	cancel()
+	requireClosed(t, ams1)
+
+	_, err = njs.jsctx.ConsumerInfo("test_stream", "test-facility_ams1")
+	require.NoError(t, err)
+
+	// an existing consumer is bound again
+	require.NoError(t, njs.Publish(context.TODO(), "ams1", []byte("ams1")))
+
+	ams1, err = njs.SubscribeSubject(context.Background(), "facility.ams1", SubjectConsumerOptions{})
+	require.NoError(t, err)
+
+	msg = readMsg(t, ams1)
+	assert.Equal(t, []byte("ams1"), msg.Data())
+	require.NoError(t, msg.Ack())
+
+	_, err = njs.SubscribeSubject(context.Background(), "facility.sjc1", SubjectConsumerOptions{Name: "test-facility_ams1"})
+	assert.ErrorIs(t, err, ErrSubscription)
+
+	// closing the stream closes the channels
+	require.NoError(t, njs.Close())
+	requireClosed(t, ams1)
+	requireClosed(t, dfw1)
+
+	_, err = njs.SubscribeSubject(context.Background(), "facility.ams1", SubjectConsumerOptions{})
+	assert.ErrorIs(t, err, ErrNatsClosed)
+}
@@ -0,0 +1,183 @@
+package events
+
+import (
+	"strings"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+	"golang.org/x/exp/slices"
+)
+
+// StreamTemplateResource is the placeholder replaced with the resource type in stream template names and subjects.
+const StreamTemplateResource = "{resource}"
+
+// NatsStreamTemplate defines the streams created for each resource type with EnsureStreamFor,
+// the StreamTemplateResource placeholder in the name and subjects is replaced with the resource type.
+//
+//	StreamTemplate: &events.NatsStreamTemplate{
+//		Name:     "hollow-{resource}",
+//		Subjects: []string{"com.hollow.sh.{resource}.>"},
+//		MaxAge:   7 * 24 * time.Hour,
+//	}
+type NatsStreamTemplate struct {
+	// Name of the streams, it must hold the StreamTemplateResource placeholder.
+	Name string `mapstructure:"name"`
+
+	// Subjects stored by the streams, each must hold the StreamTemplateResource placeholder.
+	Subjects []string `mapstructure:"subjects"`
+
+	// Retention is the message eviction criteria, one of workQueue, limits (the default) or interest.
+	Retention string `mapstructure:"retention"`
+
+	// DuplicateWindow, messages containing the same message ID will be
+	// deduplicated in this time window.
+	DuplicateWindow time.Duration `mapstructure:"duplicate_window"`
+
+	// MaxAge, MaxMsgs and MaxBytes limit the messages kept in the streams, unlimited when not set.
+	MaxAge   time.Duration `mapstructure:"max_age"`
+	MaxMsgs  int64         `mapstructure:"max_msgs"`
+	MaxBytes int64         `mapstructure:"max_bytes"`
+
+	// Replicas is the number of copies of the streams in a NATS cluster, defaults to 1.
+	Replicas int `mapstructure:"replicas"`
+}
+
+func (t *NatsStreamTemplate) validate() error {
+	if t.Retention == "" {
+		t.Retention = "limits"
+	}
+
+	if !slices.Contains([]string{"workQueue", "limits", "interest"}, t.Retention) {
+		return errors.Wrap(ErrNatsConfig, "stream template requires a valid Retention")
+	}
+
+	if !strings.Contains(t.Name, StreamTemplateResource) {
+		return errors.Wrap(ErrNatsConfig, "stream template Name requires the "+StreamTemplateResource+" placeholder")
+	}
+
+	if len(t.Subjects) == 0 {
+		return errors.Wrap(ErrNatsConfig, "stream template requires one or more Subjects")
+	}
+
+	for _, subject := range t.Subjects {
+		if !strings.Contains(subject, StreamTemplateResource) {
+			return errors.Wrap(ErrNatsConfig, "stream template subject requires the "+StreamTemplateResource+" placeholder: "+subject)
+		}
+	}
+
+	if t.DuplicateWindow < 0 || t.MaxAge < 0 || t.MaxMsgs < 0 || t.MaxBytes < 0 || t.Replicas < 0 {
+		return errors.Wrap(ErrNatsConfig, "stream template limits must not be negative")
+	}
+
+	return nil
+}
+
+// streamConfig returns the configuration of the stream of the resource type.
+func (t *NatsStreamTemplate) streamConfig(resourceType string) (*nats.StreamConfig, error) {
+	if resourceType == "" || strings.ContainsAny(resourceType, ".*> \t\r\n") {
+		return nil, errors.Wrap(ErrNatsConfig, "invalid resource type for stream template: "+resourceType)
+	}
+
+	retention, err := natsRetention(t.Retention)
+	if err != nil {
+		return nil, err
+	}
+
+	subjects := make([]string, len(t.Subjects))
+	for i, subject := range t.Subjects {
+		subjects[i] = strings.ReplaceAll(subject, StreamTemplateResource, resourceType)
+	}
+
+	cfg := &nats.StreamConfig{
+		Name:       strings.ReplaceAll(t.Name, StreamTemplateResource, resourceType),
+		Subjects:   subjects,
+		Retention:  retention,
+		Duplicates: t.DuplicateWindow,
+		MaxAge:     t.MaxAge,
+		MaxMsgs:    -1,
+		MaxBytes:   -1,
+		Replicas:   t.Replicas,
+	}
+
+	if t.MaxMsgs > 0 {
+		cfg.MaxMsgs = t.MaxMsgs
+	}
+
+	if t.MaxBytes > 0 {
+		cfg.MaxBytes = t.MaxBytes
+	}
+
+	if cfg.Replicas == 0 {
+		cfg.Replicas = 1
+	}
+
+	return cfg, nil
+}
+
+// EnsureStreamFor creates the stream of the resource type from the configured StreamTemplate,
+// or updates it when its configuration differs from the template. It returns the stream name.
+//
+// Streams can't change their retention policy, such an update is rejected by the server.
+func (n *NatsJetstream) EnsureStreamFor(resourceType string) (string, error) {
+	if n.jsctx == nil {
+		return "", errors.Wrap(ErrNatsJetstreamAddStream, "Jetstream context is not setup")
+	}
+
+	if n.parameters == nil || n.parameters.StreamTemplate == nil {
+		return "", errors.Wrap(ErrNatsConfig, "no StreamTemplate defined")
+	}
+
+	cfg, err := n.parameters.StreamTemplate.streamConfig(resourceType)
+	if err != nil {
+		return "", err
+	}
+
+	info, err := n.jsctx.StreamInfo(cfg.Name)
+
+	switch {
+	case errors.Is(err, nats.ErrStreamNotFound):
+		if _, err := n.jsctx.AddStream(cfg); err != nil {
+			return "", errors.Wrap(ErrNatsJetstreamAddStream, err.Error())
+		}
+
+		return cfg.Name, nil
+	case err != nil:
+		return "", errors.Wrap(ErrNatsJetstream, err.Error())
+	}
+
+	if streamConfigMatches(&info.Config, cfg) {
+		return cfg.Name, nil
+	}
+
+	if _, err := n.jsctx.UpdateStream(cfg); err != nil {
+		return "", errors.Wrap(ErrNatsJetstreamAddStream, "updating stream "+cfg.Name+": "+err.Error())
+	}
+
+	return cfg.Name, nil
+}
+
+// streamConfigMatches returns true when the stream configuration holds the templated settings.
+func streamConfigMatches(current, expected *nats.StreamConfig) bool {
+	return slices.Equal(current.Subjects, expected.Subjects) &&
+		current.Retention == expected.Retention &&
+		current.Duplicates == expected.Duplicates &&
+		current.MaxAge == expected.MaxAge &&
+		current.MaxMsgs == expected.MaxMsgs &&
+		current.MaxBytes == expected.MaxBytes &&
+		current.Replicas == expected.Replicas
+}
+
+// natsRetention returns the NATS retention policy of the configured name.
+func natsRetention(name string) (nats.RetentionPolicy, error) {
+	switch name {
+	case "workQueue":
+		return nats.WorkQueuePolicy, nil
+	case "limits":
+		return nats.LimitsPolicy, nil
+	case "interest":
+		return nats.InterestPolicy, nil
+	default:
+		return 0, errors.Wrap(ErrNatsConfig, "unknown retention policy defined: "+name)
+	}
+}
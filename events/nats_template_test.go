@@ -0,0 +1,95 @@
+//nolint:all
+package events
+
+import (
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	natsTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+func TestEnsureStreamFor(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	_, err := njs.EnsureStreamFor("servers")
+	assert.ErrorIs(t, err, ErrNatsConfig)
+
+	njs.parameters = &NatsOptions{
+		StreamTemplate: &NatsStreamTemplate{
+			Name:     "hollow-{resource}",
+			Subjects: []string{"com.hollow.sh.{resource}.>"},
+			MaxAge:   time.Hour,
+		},
+	}
+	require.NoError(t, njs.parameters.StreamTemplate.validate())
+
+	for _, resourceType := range []string{"servers", "firmwares"} {
+		name, err := njs.EnsureStreamFor(resourceType)
+		require.NoError(t, err)
+		assert.Equal(t, "hollow-"+resourceType, name)
+
+		info, err := njs.jsctx.StreamInfo(name)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"com.hollow.sh." + resourceType + ".>"}, info.Config.Subjects)
+		assert.Equal(t, nats.LimitsPolicy, info.Config.Retention)
+		assert.Equal(t, time.Hour, info.Config.MaxAge)
+	}
+
+	// unchanged streams are left as is
+	_, err = njs.EnsureStreamFor("servers")
+	require.NoError(t, err)
+
+	// streams are updated to the template
+	njs.parameters.StreamTemplate.MaxAge = 2 * time.Hour
+	njs.parameters.StreamTemplate.MaxMsgs = 100
+
+	_, err = njs.EnsureStreamFor("servers")
+	require.NoError(t, err)
+
+	info, err := njs.jsctx.StreamInfo("hollow-servers")
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Hour, info.Config.MaxAge)
+	assert.Equal(t, int64(100), info.Config.MaxMsgs)
+
+	_, err = njs.EnsureStreamFor("servers.*")
+	assert.ErrorIs(t, err, ErrNatsConfig)
+}
+
+func TestNatsStreamTemplate_Validate(t *testing.T) {
+	tests := []struct {
+		name          string
+		template      NatsStreamTemplate
+		errorContains string
+	}{
+		{"valid", NatsStreamTemplate{Name: "{resource}", Subjects: []string{"{resource}.>"}}, ""},
+		{"no name placeholder", NatsStreamTemplate{Name: "hollow", Subjects: []string{"{resource}.>"}}, "Name requires the {resource} placeholder"},
+		{"no subjects", NatsStreamTemplate{Name: "{resource}"}, "requires one or more Subjects"},
+		{"no subject placeholder", NatsStreamTemplate{Name: "{resource}", Subjects: []string{"servers.>"}}, "subject requires the {resource} placeholder"},
+		{"invalid retention", NatsStreamTemplate{Name: "{resource}", Subjects: []string{"{resource}.>"}, Retention: "forever"}, "valid Retention"},
+		{"negative limit", NatsStreamTemplate{Name: "{resource}", Subjects: []string{"{resource}.>"}, MaxMsgs: -1}, "must not be negative"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.template.validate()
+			if tt.errorContains == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, "limits", tt.template.Retention)
+
+				return
+			}
+
+			assert.ErrorIs(t, err, ErrNatsConfig)
+			assert.ErrorContains(t, err, tt.errorContains)
+		})
+	}
+}
@@ -3,6 +3,7 @@ package events
 
 import (
 	"context"
+	"fmt"
 	"testing"
 	"time"
 
@@ -90,11 +91,77 @@ func TestPublishAndSubscribe(t *testing.T) {
 	require.Equal(t, 1, len(msgs))
 	require.Equal(t, payload, msgs[0].Data())
 
+	md, err := msgs[0].Metadata()
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), md.StreamSequence)
+	require.Equal(t, uint64(1), md.ConsumerSequence)
+	require.Equal(t, uint64(1), md.NumDelivered)
+	require.Equal(t, "test_stream", md.Stream)
+	require.Equal(t, "test_consumer", md.Consumer)
+	require.False(t, md.Timestamp.IsZero())
+
 	msgs, err = njs.PullMsg(context.TODO(), 1)
 	require.Error(t, err)
 	require.ErrorIs(t, err, nats.ErrTimeout)
 }
 
+func TestPullMsgContext(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName: "TestPullMsgContext",
+		Stream: &NatsStreamOptions{
+			Name:      "test_stream",
+			Subjects:  []string{"pre.test"},
+			Retention: "workQueue",
+		},
+		Consumer: &NatsConsumerOptions{
+			Name:              "test_consumer",
+			Pull:              true,
+			SubscribeSubjects: []string{"pre.test"},
+			FilterSubject:     "pre.test",
+			FetchMaxWait:      100 * time.Millisecond,
+		},
+	}
+	require.NoError(t, njs.addStream())
+	require.NoError(t, njs.addConsumer())
+
+	_, err := njs.Subscribe(context.TODO())
+	require.NoError(t, err)
+
+	// without a deadline the fetch waits for the FetchMaxWait
+	start := time.Now()
+	_, err = njs.PullMsg(context.Background(), 1)
+	require.ErrorIs(t, err, nats.ErrTimeout)
+	assert.Less(t, time.Since(start), time.Second)
+
+	// a cancelled context ends the fetch
+	njs.parameters.Consumer.FetchMaxWait = time.Minute
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start = time.Now()
+	_, err = njs.PullMsg(ctx, 1)
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, time.Since(start), time.Second)
+
+	_, err = njs.PullMsg(ctx, 1)
+	require.ErrorIs(t, err, context.Canceled)
+
+	// the context deadline takes precedence over the FetchMaxWait
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = njs.PullMsg(ctx, 1)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
 func Test_addConsumer(t *testing.T) {
 	jsSrv := natsTest.StartJetStreamServer(t)
 	defer natsTest.ShutdownJetStream(t, jsSrv)
@@ -148,12 +215,256 @@ func Test_addConsumer(t *testing.T) {
 
 	// update config
 	consumerCfg.MaxAckPending = 30
+	consumerCfg.InactiveThreshold = time.Hour
 	require.NoError(t, njs.addConsumer())
 
 	consumerInfo, err = njs.jsctx.ConsumerInfo("test_stream", consumerCfg.Name)
 	require.NoError(t, err)
 
 	assert.Equal(t, consumerCfg.MaxAckPending, consumerInfo.Config.MaxAckPending)
+	assert.Equal(t, consumerCfg.InactiveThreshold, consumerInfo.Config.InactiveThreshold)
+}
+
+func Test_addConsumerDeliverPolicy(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	consumerCfg := &NatsConsumerOptions{
+		Name:              "test_consumer",
+		Pull:              true,
+		SubscribeSubjects: []string{"pre.test"},
+		MaxAckPending:     10,
+		AckWait:           time.Minute,
+		DeliverPolicy:     consumerDeliverByStartSequence,
+		StartSequence:     2,
+	}
+
+	njs.parameters = &NatsOptions{
+		AppName: "Test_addConsumerDeliverPolicy",
+		Stream: &NatsStreamOptions{
+			Name:      "test_stream",
+			Subjects:  []string{"pre.test"},
+			Retention: "limits",
+		},
+		Consumer:               consumerCfg,
+		PublisherSubjectPrefix: "pre",
+	}
+
+	require.NoError(t, njs.addStream())
+
+	for _, payload := range []string{"first", "second", "third"} {
+		require.NoError(t, njs.Publish(context.Background(), "test", []byte(payload)))
+	}
+
+	require.NoError(t, njs.addConsumer())
+
+	consumerInfo, err := njs.jsctx.ConsumerInfo("test_stream", consumerCfg.Name)
+	require.NoError(t, err)
+
+	assert.Equal(t, nats.DeliverByStartSequencePolicy, consumerInfo.Config.DeliverPolicy)
+	assert.Equal(t, uint64(2), consumerInfo.Config.OptStartSeq)
+	assert.True(t, njs.consumerConfigIsEqual(consumerInfo))
+
+	_, err = njs.Subscribe(context.Background())
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	msgs, err := njs.PullMsg(ctx, 1)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, []byte("second"), msgs[0].Data())
+}
+
+func TestPublishWithOptions(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName: "TestPublishWithOptions",
+		Stream: &NatsStreamOptions{
+			Name: "test_stream",
+			Subjects: []string{
+				"pre.test",
+			},
+			Retention:       "limits",
+			DuplicateWindow: time.Minute,
+		},
+		Consumer: &NatsConsumerOptions{
+			Name: "test_consumer",
+			Pull: true,
+			SubscribeSubjects: []string{
+				"pre.test",
+			},
+			FilterSubject: "pre.test",
+			AckPolicy:     consumerAckPolicyAll,
+			AckSync:       true,
+		},
+		PublisherSubjectPrefix: "pre",
+	}
+	require.NoError(t, njs.addStream())
+	require.NoError(t, njs.addConsumer())
+
+	consumerInfo, err := njs.jsctx.ConsumerInfo("test_stream", "test_consumer")
+	require.NoError(t, err)
+	assert.Equal(t, nats.AckAllPolicy, consumerInfo.Config.AckPolicy)
+
+	seq, err := njs.PublishWithOptions(context.TODO(), "test", []byte("1"), WithMsgID("1"), WithExpectLastSequence(0))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), seq)
+
+	// a retried publish is deduplicated
+	seq, err = njs.PublishWithOptions(context.TODO(), "test", []byte("1"), WithMsgID("1"))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), seq)
+
+	// a publish expecting an outdated sequence is rejected
+	_, err = njs.PublishWithOptions(context.TODO(), "test", []byte("2"), WithMsgID("2"), WithExpectLastSequence(0))
+	require.Error(t, err)
+
+	seq, err = njs.PublishWithOptions(context.TODO(), "test", []byte("2"), WithMsgID("2"), WithExpectLastSequencePerSubject(1))
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), seq)
+
+	_, err = njs.Subscribe(context.TODO())
+	require.NoError(t, err)
+
+	msgs, err := njs.PullMsg(context.TODO(), 2)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+
+	// acking the last message acks all messages before it
+	require.NoError(t, msgs[1].Ack())
+
+	floor, err := njs.AckFloor()
+	require.NoError(t, err)
+	assert.Equal(t, uint64(2), floor)
+}
+
+func TestCleanupStaleConsumers(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, jsCtx := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName: "TestCleanupStaleConsumers",
+		Stream: &NatsStreamOptions{
+			Name: "test_stream",
+			Subjects: []string{
+				"pre.>",
+			},
+			Retention: "limits",
+		},
+		Consumer: &NatsConsumerOptions{
+			Name:          "test_consumer",
+			FilterSubject: "pre.test",
+		},
+		PublisherSubjectPrefix: "pre",
+	}
+	require.NoError(t, njs.addStream())
+	require.NoError(t, njs.parameters.Consumer.validate())
+	require.NoError(t, njs.addConsumer())
+
+	for _, name := range []string{"stale", "bound"} {
+		_, err := jsCtx.AddConsumer("test_stream", &nats.ConsumerConfig{
+			Durable:        name,
+			DeliverSubject: "deliver." + name,
+			AckPolicy:      nats.AckExplicitPolicy,
+		})
+		require.NoError(t, err)
+	}
+
+	// the bound consumer has a subscriber.
+	sub, err := jsCtx.SubscribeSync("", nats.Bind("test_stream", "bound"))
+	require.NoError(t, err)
+	defer sub.Unsubscribe()
+
+	// nothing is older than an hour.
+	deleted, err := njs.CleanupStaleConsumers(context.TODO(), time.Hour)
+	require.NoError(t, err)
+	assert.Empty(t, deleted)
+
+	time.Sleep(50 * time.Millisecond)
+
+	deleted, err = njs.CleanupStaleConsumers(context.TODO(), 10*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"stale"}, deleted)
+
+	var remaining []string
+	for name := range jsCtx.ConsumerNames("test_stream") {
+		remaining = append(remaining, name)
+	}
+
+	assert.ElementsMatch(t, []string{"test_consumer", "bound"}, remaining)
+}
+
+type fakeClock struct {
+	now       time.Time
+	fire      chan time.Time
+	requested chan time.Duration
+}
+
+func (c *fakeClock) Now() time.Time {
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+	c.requested <- d
+	return c.fire
+}
+
+func TestSubscriptionCallbackNakOnTimeout(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	clock := &fakeClock{now: time.Now(), fire: make(chan time.Time), requested: make(chan time.Duration, 1)}
+	njs.SetClock(clock)
+	njs.subscriberCh = make(MsgCh)
+
+	require.ErrorIs(t, njs.SetNakDelay(-time.Second), ErrNatsConfig)
+	require.ErrorIs(t, njs.SetSubscriptionCallbackTimeout(0), ErrNatsConfig)
+	require.NoError(t, njs.SetNakDelay(42*time.Second))
+	require.NoError(t, njs.SetSubscriptionCallbackTimeout(time.Minute))
+
+	// the nak is sent to the message reply subject.
+	acks, err := jsConn.SubscribeSync("test.ack")
+	require.NoError(t, err)
+
+	sub, err := jsConn.SubscribeSync("test")
+	require.NoError(t, err)
+
+	done := make(chan struct{})
+
+	go func() {
+		njs.subscriptionCallback(&nats.Msg{Subject: "test", Reply: "test.ack", Sub: sub})
+		close(done)
+	}()
+
+	require.Equal(t, time.Minute, <-clock.requested)
+
+	// nobody reads the message before the timeout fires.
+	clock.fire <- clock.now.Add(time.Minute)
+	<-done
+
+	ack, err := acks.NextMsg(time.Second)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf(`-NAK {"delay": %d}`, 42*time.Second), string(ack.Data))
 }
 
 func TestInjectOtelTraceContext(t *testing.T) {
@@ -194,10 +505,137 @@ func TestExtractOtelTraceContext(t *testing.T) {
 	traceParent := msg.Header.Get("Traceparent")
 
 	// wrap natsMsg to pass to extract method
-	nm := &natsMsg{msg}
+	nm := &natsMsg{msg: msg}
 
 	ctxWithTrace := nm.ExtractOtelTraceContext(context.Background())
 	got := trace.SpanFromContext(ctxWithTrace).SpanContext().TraceID().String()
 
 	assert.Contains(t, traceParent, got)
 }
+
+func Test_addConsumerFilterSubjects(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName: "Test_addConsumerFilterSubjects",
+		Stream: &NatsStreamOptions{
+			Name:      "test_stream",
+			Subjects:  []string{"pre.>"},
+			Retention: "limits",
+		},
+		Consumer: &NatsConsumerOptions{
+			Name:              "test_consumer",
+			Pull:              true,
+			SubscribeSubjects: []string{"pre.a", "pre.b"},
+		},
+	}
+	require.NoError(t, njs.parameters.Consumer.validate())
+	require.NoError(t, njs.addStream())
+
+	err := njs.addConsumer()
+	if serverVersionAtLeast(jsConn.ConnectedServerVersion(), 2, 10) {
+		require.NoError(t, err)
+		return
+	}
+
+	// the servers before 2.10 ignore the filter subjects, the consumer isn't added.
+	require.ErrorIs(t, err, ErrNatsJetstreamAddConsumer)
+	assert.ErrorContains(t, err, "nats-server 2.10")
+
+	_, err = njs.jsctx.ConsumerInfo("test_stream", "test_consumer")
+	assert.ErrorIs(t, err, nats.ErrConsumerNotFound)
+}
+
+func Test_addStreamSubjectTransform(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName: "Test_addStreamSubjectTransform",
+		Stream: &NatsStreamOptions{
+			Name:      "test_stream",
+			Subjects:  []string{"legacy.>", "com.hollow.sh.>"},
+			Retention: "limits",
+			SubjectTransform: &NatsSubjectTransform{
+				Source:      "legacy.*.servers.>",
+				Destination: "com.hollow.sh.servers.{{wildcard(1)}}.>",
+			},
+		},
+	}
+	require.NoError(t, njs.parameters.Stream.validate())
+
+	err := njs.addStream()
+	if serverVersionAtLeast(jsConn.ConnectedServerVersion(), 2, 10) {
+		require.NoError(t, err)
+
+		_, err = njs.jsctx.Publish("legacy.ams1.servers.create", []byte("{}"))
+		require.NoError(t, err)
+
+		msg, err := njs.jsctx.GetLastMsg("test_stream", "com.hollow.sh.servers.ams1.create")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("{}"), msg.Data)
+
+		// the transform is applied to the existing stream once changed
+		njs.parameters.Stream.SubjectTransform.Destination = "com.hollow.sh.$1.servers.>"
+		require.NoError(t, njs.addStream())
+
+		return
+	}
+
+	// the servers before 2.10 ignore the subject transform, the stream isn't added.
+	require.ErrorIs(t, err, ErrNatsJetstreamAddStream)
+	assert.ErrorContains(t, err, "nats-server 2.10")
+
+	_, err = njs.jsctx.StreamInfo("test_stream")
+	assert.ErrorIs(t, err, nats.ErrStreamNotFound)
+}
+
+func TestPublishValidatesSubject(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName: "TestPublishValidatesSubject",
+		Stream: &NatsStreamOptions{
+			Name:      "test_stream",
+			Subjects:  []string{"pre.test.>"},
+			Retention: "limits",
+		},
+		PublisherSubjectPrefix:  "pre",
+		ValidatePublishSubjects: true,
+	}
+	require.NoError(t, njs.addStream())
+
+	_, err := njs.jsctx.AddStream(&nats.StreamConfig{Name: "other_stream", Subjects: []string{"pre.other"}})
+	require.NoError(t, err)
+
+	require.NoError(t, njs.Publish(context.TODO(), "test.foo", []byte("1")))
+
+	_, ok := njs.validSubjects.Load("pre.test.foo")
+	assert.True(t, ok, "valid subject should be cached")
+
+	err = njs.Publish(context.TODO(), "unknown", []byte("1"))
+	assert.ErrorIs(t, err, ErrPublishSubjectNotInStream)
+
+	// stored by another stream
+	_, err = njs.PublishWithOptions(context.TODO(), "other", []byte("1"))
+	assert.ErrorIs(t, err, ErrPublishSubjectNotInStream)
+
+	// any stream is accepted when none is configured
+	njs.parameters.Stream = nil
+	_, err = njs.PublishWithOptions(context.TODO(), "other", []byte("1"))
+	assert.NoError(t, err)
+}
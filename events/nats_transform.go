@@ -0,0 +1,157 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+)
+
+const (
+	// JetStream API subjects to create, update and get a stream.
+	jsAPIStreamCreateT = "$JS.API.STREAM.CREATE.%s"
+	jsAPIStreamUpdateT = "$JS.API.STREAM.UPDATE.%s"
+	jsAPIStreamInfoT   = "$JS.API.STREAM.INFO.%s"
+)
+
+// transformWildcardRef matches the references to the source wildcards in a transform destination,
+// either as $1 or {{wildcard(1)}}.
+var transformWildcardRef = regexp.MustCompile(`\$(\d+)|\{\{\s*[wW]ildcard\s*\(\s*(\d+)\s*\)\s*\}\}`)
+
+// NatsSubjectTransform rewrites the subject of the messages stored on the stream, e.g. to
+// ingest legacy subjects into a new hierarchy. This requires nats-server 2.10 or later.
+//
+//	SubjectTransform: &events.NatsSubjectTransform{
+//		Source:      "legacy.*.servers.>",
+//		Destination: "com.hollow.sh.servers.{{wildcard(1)}}.>",
+//	}
+//
+// https://docs.nats.io/nats-concepts/subject_mapping
+type NatsSubjectTransform struct {
+	// Source is the subject transformed, it must be covered by the stream Subjects.
+	Source string `mapstructure:"source" json:"src"`
+
+	// Destination is the subject messages are stored with, the * wildcards of the
+	// Source are referenced with $1 or {{wildcard(1)}}.
+	Destination string `mapstructure:"destination" json:"dest"`
+}
+
+func (t *NatsSubjectTransform) validate(streamSubjects []string) error {
+	if t.Source == "" || t.Destination == "" {
+		return errors.Wrap(ErrNatsConfig, "stream subject transform requires a Source and a Destination")
+	}
+
+	if err := validateSubjectTokens(t.Source); err != nil {
+		return errors.Wrap(ErrNatsConfig, "stream subject transform Source: "+err.Error())
+	}
+
+	if !subjectCoveredBy(t.Source, streamSubjects) {
+		return errors.Wrap(ErrNatsConfig, fmt.Sprintf(
+			"stream subject transform Source %q isn't covered by the stream Subjects %q", t.Source, streamSubjects,
+		))
+	}
+
+	if err := validateSubjectTokens(transformWildcardRef.ReplaceAllString(t.Destination, "x")); err != nil {
+		return errors.Wrap(ErrNatsConfig, "stream subject transform Destination: "+err.Error())
+	}
+
+	source := strings.Split(t.Source, ".")
+	wildcards := 0
+
+	for _, token := range source {
+		if token == "*" {
+			wildcards++
+		}
+	}
+
+	for _, ref := range transformWildcardRef.FindAllStringSubmatch(t.Destination, -1) {
+		idx := ref[1]
+		if idx == "" {
+			idx = ref[2]
+		}
+
+		if n, _ := strconv.Atoi(idx); n < 1 || n > wildcards {
+			return errors.Wrap(ErrNatsConfig, fmt.Sprintf(
+				"stream subject transform Destination references wildcard %s, the Source has %d", idx, wildcards,
+			))
+		}
+	}
+
+	// the tokens matched by a trailing > are carried over with a trailing > in the destination.
+	if strings.HasSuffix(t.Destination, ">") != (source[len(source)-1] == ">") {
+		return errors.Wrap(ErrNatsConfig, "stream subject transform Destination must end with > when, and only when, the Source does")
+	}
+
+	return nil
+}
+
+// validateSubjectTokens ensures the subject has no empty token and only holds > as its last token.
+func validateSubjectTokens(subject string) error {
+	tokens := strings.Split(subject, ".")
+
+	for i, token := range tokens {
+		switch {
+		case token == "":
+			return errors.New("subject " + subject + " has an empty token") //nolint:goerr113 // wrapped by callers
+		case token == ">" && i != len(tokens)-1:
+			return errors.New("subject " + subject + " has > before its last token") //nolint:goerr113 // wrapped by callers
+		}
+	}
+
+	return nil
+}
+
+// transformedStreamConfig adds the subject transform the NATS client doesn't know about to the stream config.
+type transformedStreamConfig struct {
+	nats.StreamConfig
+	SubjectTransform *NatsSubjectTransform `json:"subject_transform,omitempty"`
+}
+
+type jsAPIStreamResponse struct {
+	jsAPIResponse
+	Config *transformedStreamConfig `json:"config,omitempty"`
+}
+
+// addTransformedStream creates the stream with its subject transform, or updates the existing stream
+// when its transform differs. This requires nats-server 2.10 or later.
+func (n *NatsJetstream) addTransformedStream(cfg *nats.StreamConfig, transform *NatsSubjectTransform, exists bool) error {
+	if version := n.conn.ConnectedServerVersion(); !serverVersionAtLeast(version, 2, 10) {
+		return errors.Wrap(
+			ErrNatsJetstreamAddStream,
+			"stream SubjectTransform requires nats-server 2.10 or later, connected server version: "+version,
+		)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), jsAPISetupTimeout)
+	defer cancel()
+
+	subject := fmt.Sprintf(jsAPIStreamCreateT, cfg.Name)
+
+	if exists {
+		var info jsAPIStreamResponse
+		if err := n.jsAPIRequest(ctx, fmt.Sprintf(jsAPIStreamInfoT, cfg.Name), struct{}{}, &info); err != nil {
+			return errors.Wrap(ErrNatsJetstreamAddStream, err.Error())
+		}
+
+		if info.Config != nil && info.Config.SubjectTransform != nil && *info.Config.SubjectTransform == *transform {
+			return nil
+		}
+
+		subject = fmt.Sprintf(jsAPIStreamUpdateT, cfg.Name)
+	}
+
+	var resp jsAPIStreamResponse
+	if err := n.jsAPIRequest(ctx, subject, transformedStreamConfig{StreamConfig: *cfg, SubjectTransform: transform}, &resp); err != nil {
+		return errors.Wrap(ErrNatsJetstreamAddStream, err.Error())
+	}
+
+	if resp.Config == nil || resp.Config.SubjectTransform == nil {
+		return errors.Wrap(ErrNatsJetstreamAddStream, "stream SubjectTransform was not applied by the server")
+	}
+
+	return nil
+}
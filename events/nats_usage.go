@@ -0,0 +1,47 @@
+package events
+
+import (
+	"context"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+)
+
+// Usage returns the message, byte and consumer counts of the configured stream, along with
+// the JetStream account usage and limits.
+func (n *NatsJetstream) Usage(ctx context.Context) (StreamUsage, error) {
+	if n.jsctx == nil {
+		return StreamUsage{}, errors.Wrap(ErrNatsJetstream, "Jetstream context is not setup")
+	}
+
+	if n.parameters == nil || n.parameters.Stream == nil {
+		return StreamUsage{}, errors.Wrap(ErrNatsConfig, "stream parameters required")
+	}
+
+	info, err := n.jsctx.StreamInfo(n.parameters.Stream.Name, nats.Context(ctx))
+	if err != nil {
+		return StreamUsage{}, errors.Wrap(ErrNatsJetstream, err.Error())
+	}
+
+	account, err := n.jsctx.AccountInfo(nats.Context(ctx))
+	if err != nil {
+		return StreamUsage{}, errors.Wrap(ErrNatsJetstream, err.Error())
+	}
+
+	return StreamUsage{
+		Stream:    info.Config.Name,
+		Messages:  info.State.Msgs,
+		Bytes:     info.State.Bytes,
+		Consumers: info.State.Consumers,
+		Account: AccountUsage{
+			Memory:       account.Memory,
+			Store:        account.Store,
+			Streams:      account.Streams,
+			Consumers:    account.Consumers,
+			MaxMemory:    account.Limits.MaxMemory,
+			MaxStore:     account.Limits.MaxStore,
+			MaxStreams:   account.Limits.MaxStreams,
+			MaxConsumers: account.Limits.MaxConsumers,
+		},
+	}, nil
+}
@@ -0,0 +1,71 @@
+//nolint:all
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	natsTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+func TestUsage(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName: "TestUsage",
+		Stream: &NatsStreamOptions{
+			Name: "test_stream",
+			Subjects: []string{
+				"pre.test",
+			},
+			Retention: "limits",
+		},
+		Consumer: &NatsConsumerOptions{
+			Name:          "test_consumer",
+			FilterSubject: "pre.test",
+		},
+		PublisherSubjectPrefix: "pre",
+	}
+	require.NoError(t, njs.addStream())
+	require.NoError(t, njs.parameters.Consumer.validate())
+	require.NoError(t, njs.addConsumer())
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, njs.Publish(context.TODO(), "test", []byte("some data")))
+	}
+
+	usage, err := njs.Usage(context.TODO())
+	require.NoError(t, err)
+
+	assert.Equal(t, "test_stream", usage.Stream)
+	assert.Equal(t, uint64(3), usage.Messages)
+	assert.NotZero(t, usage.Bytes)
+	assert.Equal(t, 1, usage.Consumers)
+	assert.Equal(t, 1, usage.Account.Streams)
+	assert.Equal(t, 1, usage.Account.Consumers)
+	assert.Equal(t, -1, usage.Account.MaxStreams)
+
+	ctx, cancel := context.WithCancel(context.TODO())
+
+	var reports []StreamUsage
+
+	ReportUsage(ctx, njs, 10*time.Millisecond, func(u StreamUsage, err error) {
+		require.NoError(t, err)
+
+		reports = append(reports, u)
+		if len(reports) == 2 {
+			cancel()
+		}
+	})
+
+	assert.Len(t, reports, 2)
+}
@@ -0,0 +1,96 @@
+package events
+
+import (
+	"context"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// first wait between lookups of a stream not created yet, doubled after each lookup
+	streamWaitBackoff = 100 * time.Millisecond
+
+	// upper bound of the wait between lookups
+	maxStreamWaitBackoff = 5 * time.Second
+)
+
+// ErrStreamNotReady is returned when the stream storing a subject wasn't created before the wait ended.
+var ErrStreamNotReady = errors.New("stream not ready")
+
+// WaitForStream waits for a stream storing the subject to be created and returns its name, it looks
+// the stream up with an exponential backoff until the context is done. This lets consumers start
+// before the producer creating the stream, whatever the deployment order.
+func (n *NatsJetstream) WaitForStream(ctx context.Context, subject string) (string, error) {
+	if n.jsctx == nil {
+		return "", errors.Wrap(ErrNatsJetstream, "Jetstream context is not setup")
+	}
+
+	backoff := streamWaitBackoff
+	logged := false
+
+	for {
+		stream, err := n.jsctx.StreamNameBySubject(subject, nats.Context(ctx))
+		if err == nil {
+			return stream, nil
+		}
+
+		if !streamMissing(err) || ctx.Err() != nil {
+			return "", errors.Wrap(ErrStreamNotReady, err.Error()+": "+subject)
+		}
+
+		if !logged {
+			n.logger().Info("waiting for the stream storing the subject to be created", zap.String("subject", subject))
+
+			logged = true
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", errors.Wrap(ErrStreamNotReady, ctx.Err().Error()+": "+subject)
+		case <-n.getClock().After(backoff):
+		}
+
+		if backoff *= 2; backoff > maxStreamWaitBackoff {
+			backoff = maxStreamWaitBackoff
+		}
+	}
+}
+
+// streamMissing returns true for the lookup errors of streams not created yet, or of a JetStream
+// not available yet, these are retried while waiting for the stream.
+func streamMissing(err error) bool {
+	return errors.Is(err, nats.ErrNoMatchingStream) ||
+		errors.Is(err, nats.ErrStreamNotFound) ||
+		errors.Is(err, nats.ErrNoResponders) ||
+		errors.Is(err, nats.ErrJetStreamNotEnabled)
+}
+
+// subscribeWait returns the context bounding the wait for the streams of the subjects subscribed
+// to, nil when the SubscribeWaitTimeout isn't set and subscribing doesn't wait.
+func (n *NatsJetstream) subscribeWait(ctx context.Context) (context.Context, context.CancelFunc) {
+	if n.parameters == nil || n.parameters.SubscribeWaitTimeout <= 0 {
+		return nil, func() {}
+	}
+
+	return context.WithTimeout(ctx, n.parameters.SubscribeWaitTimeout)
+}
+
+// waitSubjectStream returns the stream subscriptions to the subject are created on like subjectStream,
+// waiting for the stream storing the subject to be created when the SubscribeWaitTimeout is set.
+func (n *NatsJetstream) waitSubjectStream(ctx context.Context, subject string) (string, error) {
+	if n.parameters != nil && n.parameters.Stream != nil && n.parameters.Stream.Name != "" {
+		return n.parameters.Stream.Name, nil
+	}
+
+	waitCtx, cancel := n.subscribeWait(ctx)
+	defer cancel()
+
+	if waitCtx == nil {
+		return n.subjectStream(subject)
+	}
+
+	return n.WaitForStream(waitCtx, subject)
+}
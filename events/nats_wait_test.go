@@ -0,0 +1,92 @@
+//nolint:all
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	natsTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+func TestSubscribeWaitsForStream(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, jsCtx := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName:                "test",
+		PublisherSubjectPrefix: "facility",
+		SubscribeSubjects:      []string{"facility.ams1"},
+		SubscribeWaitTimeout:   5 * time.Second,
+	}
+
+	// the producer creates the stream once the consumer started
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+
+		_, err := jsCtx.AddStream(&nats.StreamConfig{Name: "test_stream", Subjects: []string{"facility.>"}})
+		assert.NoError(t, err)
+	}()
+
+	ch, err := njs.Subscribe(context.Background())
+	require.NoError(t, err)
+
+	dfw1, err := njs.SubscribeSubject(context.Background(), "facility.dfw1", SubjectConsumerOptions{})
+	require.NoError(t, err)
+
+	require.NoError(t, njs.Publish(context.TODO(), "ams1", []byte("ams1")))
+	require.NoError(t, njs.Publish(context.TODO(), "dfw1", []byte("dfw1")))
+
+	msg := readMsg(t, ch)
+	assert.Equal(t, "facility.ams1", msg.Subject())
+	require.NoError(t, msg.Ack())
+
+	msg = readMsg(t, dfw1)
+	assert.Equal(t, "facility.dfw1", msg.Subject())
+	require.NoError(t, msg.Ack())
+}
+
+func TestSubscribeWaitTimeout(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName:              "test",
+		SubscribeSubjects:    []string{"facility.ams1"},
+		SubscribeWaitTimeout: 200 * time.Millisecond,
+	}
+
+	start := time.Now()
+
+	_, err := njs.Subscribe(context.Background())
+	assert.ErrorIs(t, err, ErrStreamNotReady)
+	assert.GreaterOrEqual(t, time.Since(start), 200*time.Millisecond)
+
+	_, err = njs.Replay(context.Background(), "facility.ams1", ReplayOptions{})
+	assert.ErrorIs(t, err, ErrStreamNotReady)
+
+	// the context passed bounds the wait too
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = njs.WaitForStream(ctx, "facility.ams1")
+	assert.ErrorIs(t, err, ErrStreamNotReady)
+
+	// subscribing fails right away without the timeout
+	njs.parameters.SubscribeWaitTimeout = 0
+
+	_, err = njs.SubscribeSubject(context.Background(), "facility.ams1", SubjectConsumerOptions{})
+	assert.ErrorIs(t, err, ErrSubscription)
+}
@@ -0,0 +1,110 @@
+//nolint:wsl
+package kv
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+var (
+	// ErrCounterValue is returned when the counter key holds something other than a counter value.
+	ErrCounterValue = errors.New("invalid counter value")
+
+	// ErrCounterContention is returned when the counter couldn't be updated before the context was done,
+	// as other writers kept updating it.
+	ErrCounterContention = errors.New("counter update conflicted with other writers")
+)
+
+// the wait before retrying a conflicting counter update is picked at random below this
+const maxCounterRetryWait = 10 * time.Millisecond
+
+// DistributedCounter is a monotonic counter shared through a NATS KV key. Updates are
+// made against the last revision of the key and retried on conflict, so concurrent
+// increments from any number of processes each get a distinct value.
+type DistributedCounter struct {
+	bucket nats.KeyValue
+	key    string
+}
+
+// Counter returns the DistributedCounter stored at the key of the bucket, a counter
+// which was never incremented has the value 0.
+func Counter(bucket nats.KeyValue, key string) *DistributedCounter {
+	return &DistributedCounter{bucket: bucket, key: key}
+}
+
+// Get returns the current value of the counter.
+func (c *DistributedCounter) Get(ctx context.Context) (uint64, error) {
+	value, _, err := c.load(ctx)
+	return value, err
+}
+
+// Incr increments the counter and returns its new value, no other caller gets the same value
+// until the counter is reset. Conflicting updates are retried until the context is done.
+func (c *DistributedCounter) Incr(ctx context.Context) (uint64, error) {
+	for {
+		value, revision, err := c.load(ctx)
+		if err != nil {
+			return 0, err
+		}
+
+		next := strconv.FormatUint(value+1, 10)
+
+		if revision == 0 {
+			_, err = c.bucket.Create(c.key, []byte(next))
+		} else {
+			_, err = c.bucket.Update(c.key, []byte(next), revision)
+		}
+
+		switch {
+		case err == nil:
+			return value + 1, nil
+		case !errors.Is(err, nats.ErrKeyExists):
+			return 0, err
+		}
+
+		// another writer updated the counter since it was read
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("%w: %s", ErrCounterContention, ctx.Err())
+		case <-time.After(time.Duration(rand.Int63n(int64(maxCounterRetryWait)))): //nolint:gosec // jitter only
+		}
+	}
+}
+
+// Reset sets the counter back to 0, values returned by Incr before the reset are handed out again.
+func (c *DistributedCounter) Reset(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	_, err := c.bucket.PutString(c.key, "0")
+	return err
+}
+
+// load returns the value of the counter and the revision it was read at, 0 when the key doesn't exist.
+func (c *DistributedCounter) load(ctx context.Context) (uint64, uint64, error) {
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	entry, err := c.bucket.Get(c.key)
+	if err != nil {
+		if errors.Is(err, nats.ErrKeyNotFound) {
+			return 0, 0, nil
+		}
+		return 0, 0, err
+	}
+
+	value, err := strconv.ParseUint(string(entry.Value()), 10, 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("%w: key %s: %s", ErrCounterValue, c.key, err)
+	}
+
+	return value, entry.Revision(), nil
+}
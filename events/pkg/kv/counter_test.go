@@ -0,0 +1,142 @@
+//nolint:all
+package kv
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.hollow.sh/toolbox/events"
+	kvTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+func TestCounter(t *testing.T) {
+	srv := kvTest.StartJetStreamServer(t)
+	defer kvTest.ShutdownJetStream(t, srv)
+	nc, _ := kvTest.JetStreamContext(t, srv)
+
+	evJS := events.NewJetstreamFromConn(nc)
+	defer evJS.Close()
+
+	bucket, err := CreateOrBindKVBucket(evJS, "counters")
+	require.NoError(t, err)
+
+	ctx := context.TODO()
+	c := Counter(bucket, "batches")
+
+	v, err := c.Get(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), v)
+
+	for i := uint64(1); i <= 3; i++ {
+		v, err = c.Incr(ctx)
+		require.NoError(t, err)
+		require.Equal(t, i, v)
+	}
+
+	v, err = c.Get(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(3), v)
+
+	require.NoError(t, c.Reset(ctx))
+
+	v, err = c.Incr(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), v)
+
+	// a deleted key starts over
+	require.NoError(t, bucket.Delete("batches"))
+
+	v, err = c.Incr(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), v)
+
+	_, err = bucket.PutString("batches", "not a number")
+	require.NoError(t, err)
+
+	_, err = c.Incr(ctx)
+	require.ErrorIs(t, err, ErrCounterValue)
+}
+
+func TestCounterContention(t *testing.T) {
+	srv := kvTest.StartJetStreamServer(t)
+	defer kvTest.ShutdownJetStream(t, srv)
+	nc, _ := kvTest.JetStreamContext(t, srv)
+
+	evJS := events.NewJetstreamFromConn(nc)
+	defer evJS.Close()
+
+	bucket, err := CreateOrBindKVBucket(evJS, "counters")
+	require.NoError(t, err)
+
+	const (
+		writers = 8
+		incrs   = 25
+	)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		values = map[uint64]int{}
+	)
+
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			// each writer uses its own handle, as separate processes would.
+			c := Counter(bucket, "batches")
+
+			for i := 0; i < incrs; i++ {
+				v, err := c.Incr(ctx)
+				if !assert.NoError(t, err) {
+					return
+				}
+
+				mu.Lock()
+				values[v]++
+				mu.Unlock()
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	// every increment got a distinct value, without gaps.
+	require.Len(t, values, writers*incrs)
+
+	for v := uint64(1); v <= writers*incrs; v++ {
+		require.Equal(t, 1, values[v], "value %d", v)
+	}
+
+	v, err := Counter(bucket, "batches").Get(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(writers*incrs), v)
+}
+
+func TestCounterContextDone(t *testing.T) {
+	srv := kvTest.StartJetStreamServer(t)
+	defer kvTest.ShutdownJetStream(t, srv)
+	nc, _ := kvTest.JetStreamContext(t, srv)
+
+	evJS := events.NewJetstreamFromConn(nc)
+	defer evJS.Close()
+
+	bucket, err := CreateOrBindKVBucket(evJS, "counters")
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err = Counter(bucket, "batches").Incr(ctx)
+	require.ErrorIs(t, err, context.Canceled)
+}
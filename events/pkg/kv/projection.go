@@ -0,0 +1,28 @@
+package kv
+
+import (
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+
+	"go.hollow.sh/toolbox/events"
+)
+
+// ResourceKey returns the key a resource is stored at in a KV backed projection, the
+// resource type and ID separated by a dot.
+func ResourceKey(resourceType events.ResourceType, resourceID uuid.UUID) string {
+	return string(resourceType) + "." + resourceID.String()
+}
+
+// ApplyTombstone removes the resource of the tombstone from the projection bucket, see
+// events.ParseTombstone. The key is purged so the bucket compacts its history, watchers
+// of the bucket still get notified of the deletion. Resources not in the bucket are ignored.
+func ApplyTombstone(bucket nats.KeyValue, t events.Tombstone) error {
+	err := bucket.Purge(ResourceKey(t.ResourceType, t.ResourceID))
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return nil
+	}
+
+	return err
+}
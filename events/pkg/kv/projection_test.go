@@ -0,0 +1,48 @@
+//nolint:all
+package kv
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/require"
+
+	"go.hollow.sh/toolbox/events"
+	kvTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+func TestApplyTombstone(t *testing.T) {
+	srv := kvTest.StartJetStreamServer(t)
+	defer kvTest.ShutdownJetStream(t, srv)
+	nc, _ := kvTest.JetStreamContext(t, srv)
+
+	evJS := events.NewJetstreamFromConn(nc)
+	defer evJS.Close()
+
+	bucket, err := CreateOrBindKVBucket(evJS, "projection", WithStorageType(nats.MemoryStorage))
+	require.NoError(t, err)
+
+	ts := events.Tombstone{ResourceType: "servers", ResourceID: uuid.New()}
+	key := ResourceKey(ts.ResourceType, ts.ResourceID)
+	require.Equal(t, "servers."+ts.ResourceID.String(), key)
+
+	_, err = bucket.Put(key, []byte("v1"))
+	require.NoError(t, err)
+	_, err = bucket.Put(key, []byte("v2"))
+	require.NoError(t, err)
+
+	require.NoError(t, ApplyTombstone(bucket, ts))
+
+	_, err = bucket.Get(key)
+	require.ErrorIs(t, err, nats.ErrKeyNotFound)
+
+	// the history is compacted to the purge marker
+	history, err := bucket.History(key)
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	require.Equal(t, nats.KeyValuePurge, history[0].Operation())
+
+	// resources missing from the projection are ignored
+	require.NoError(t, ApplyTombstone(bucket, events.Tombstone{ResourceType: "servers", ResourceID: uuid.New()}))
+}
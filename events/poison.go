@@ -0,0 +1,394 @@
+package events
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"sync/atomic"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+	"go.uber.org/zap"
+)
+
+const (
+	// DefaultMaxHandlerFailures is the number of times a message may fail to be handled before
+	// a HandlerGuard terminates it, when no MaxFailures is set.
+	DefaultMaxHandlerFailures = 3
+
+	// DeadLetterReasonHeader holds why a message was routed to the dead letter subject.
+	DeadLetterReasonHeader = "Hollow-Dead-Letter-Reason"
+
+	// DeadLetterSubjectHeader holds the subject a message was consumed from before it was routed
+	// to the dead letter subject.
+	DeadLetterSubjectHeader = "Hollow-Dead-Letter-Subject"
+
+	// number of times a KV failure count update is retried when it raced with another consumer.
+	kvFailureUpdateAttempts = 5
+)
+
+var (
+	// ErrHandlerPanic is reported when a message handler panicked.
+	ErrHandlerPanic = errors.New("message handler panicked")
+
+	// ErrFailureTracker is returned when the failures of a message couldn't be tracked.
+	ErrFailureTracker = errors.New("error tracking message failures")
+
+	// ErrDeadLetter is returned when a message couldn't be routed to the dead letter subject.
+	ErrDeadLetter = errors.New("error routing message to dead letter subject")
+)
+
+// FailureTracker counts the failures to handle each message, by message ID.
+type FailureTracker interface {
+	// Fail records a failure to handle the message and returns its number of failures.
+	Fail(ctx context.Context, id string) (int, error)
+
+	// Clear forgets the failures of the message, once it was handled or terminated.
+	Clear(ctx context.Context, id string) error
+}
+
+// memoryFailureTracker counts failures within the process, redeliveries to other instances start over.
+type memoryFailureTracker struct {
+	mu       sync.Mutex
+	failures map[string]int
+}
+
+// NewMemoryFailureTracker returns a FailureTracker counting failures in memory, the failures
+// of messages redelivered to other consumer instances are counted apart.
+func NewMemoryFailureTracker() FailureTracker {
+	return &memoryFailureTracker{failures: map[string]int{}}
+}
+
+func (t *memoryFailureTracker) Fail(_ context.Context, id string) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.failures[id]++
+
+	return t.failures[id], nil
+}
+
+func (t *memoryFailureTracker) Clear(_ context.Context, id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.failures, id)
+
+	return nil
+}
+
+// kvFailureTracker counts failures in a JetStream KV bucket shared by the consumer instances.
+type kvFailureTracker struct {
+	kv nats.KeyValue
+}
+
+// NewKVFailureTracker returns a FailureTracker counting failures in the KV bucket, so the failures
+// of a message are counted across the consumer instances it is redelivered to. The bucket is
+// expected to have a TTL to drop the counts of messages which were never cleared.
+func NewKVFailureTracker(kv nats.KeyValue) FailureTracker {
+	return &kvFailureTracker{kv: kv}
+}
+
+func (t *kvFailureTracker) Fail(_ context.Context, id string) (int, error) {
+	key := failureKey(id)
+
+	for attempt := 0; attempt < kvFailureUpdateAttempts; attempt++ {
+		entry, err := t.kv.Get(key)
+
+		switch {
+		case errors.Is(err, nats.ErrKeyNotFound):
+			_, err = t.kv.Create(key, []byte("1"))
+			if err == nil {
+				return 1, nil
+			}
+		case err != nil:
+			return 0, errors.Wrap(ErrFailureTracker, err.Error())
+		default:
+			count, convErr := strconv.Atoi(string(entry.Value()))
+			if convErr != nil {
+				count = 0
+			}
+
+			count++
+
+			_, err = t.kv.Update(key, []byte(strconv.Itoa(count)), entry.Revision())
+			if err == nil {
+				return count, nil
+			}
+		}
+
+		// another consumer updated the count first, it is read again.
+		if !errors.Is(err, nats.ErrKeyExists) {
+			return 0, errors.Wrap(ErrFailureTracker, err.Error())
+		}
+	}
+
+	return 0, errors.Wrap(ErrFailureTracker, "too many concurrent updates of message "+id)
+}
+
+func (t *kvFailureTracker) Clear(_ context.Context, id string) error {
+	if err := t.kv.Delete(failureKey(id)); err != nil && !errors.Is(err, nats.ErrKeyNotFound) {
+		return errors.Wrap(ErrFailureTracker, err.Error())
+	}
+
+	return nil
+}
+
+// failureKey encodes the message ID into a valid KV key.
+func failureKey(id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id))
+}
+
+// DeadLetterFunc routes a message which kept failing to be handled to a dead letter destination,
+// cause is the last failure. The message is terminated once it returns without an error.
+type DeadLetterFunc func(ctx context.Context, msg Message, cause error) error
+
+// DeadLetter returns a DeadLetterFunc publishing the messages on the subject, with their headers,
+// the DeadLetterReasonHeader and the DeadLetterSubjectHeader.
+//
+// NOTE: The subject passed here will be prepended with any configured PublisherSubjectPrefix.
+func (n *NatsJetstream) DeadLetter(subjectSuffix string) DeadLetterFunc {
+	return func(ctx context.Context, msg Message, cause error) error {
+		if n.jsctx == nil {
+			return errors.Wrap(ErrDeadLetter, "Jetstream context is not setup")
+		}
+
+		subject := n.fullSubject(subjectSuffix)
+		if err := n.validatePublishSubject(subject); err != nil {
+			return err
+		}
+
+		out := nats.NewMsg(subject)
+		out.Data = msg.Data()
+
+		if nm, err := AsNatsMsg(msg); err == nil {
+			for key, values := range nm.Header {
+				out.Header[key] = append([]string(nil), values...)
+			}
+		}
+
+		out.Header.Set(DeadLetterSubjectHeader, msg.Subject())
+		out.Header.Set(DeadLetterReasonHeader, cause.Error())
+
+		options := []nats.PubOpt{nats.Context(ctx)}
+
+		// redeliveries of a message which couldn't be terminated are stored once.
+		if id := messageID(msg); id != "" {
+			out.Header.Del(nats.MsgIdHdr)
+			options = append(options, nats.MsgId("dead-letter."+id))
+		}
+
+		if _, err := n.jsctx.PublishMsg(out, options...); err != nil {
+			return errors.Wrap(ErrDeadLetter, err.Error()+": "+subject)
+		}
+
+		return nil
+	}
+}
+
+// HandlerGuardOptions configures a HandlerGuard.
+type HandlerGuardOptions struct {
+	// MaxFailures is the number of times a message may fail before it is terminated,
+	// defaults to DefaultMaxHandlerFailures.
+	MaxFailures int
+
+	// Tracker counts the failures of each message, defaults to NewMemoryFailureTracker.
+	// Use NewKVFailureTracker to count them across consumer instances.
+	Tracker FailureTracker
+
+	// DeadLetter routes the terminated messages, e.g. NatsJetstream.DeadLetter.
+	// Messages are terminated without being routed anywhere when unset.
+	DeadLetter DeadLetterFunc
+
+	// ReasonSerializer turns the failures into the reason the messages are Nak'ed and terminated
+	// with, see NakWithReason. Defaults to DefaultReasonSerializer.
+	ReasonSerializer ReasonSerializer
+
+	// Logger reports the panics and the terminated messages, defaults to the global zap logger.
+	Logger *zap.Logger
+}
+
+// HandlerGuardStats are the counts reported by a HandlerGuard, to be exported as metrics.
+type HandlerGuardStats struct {
+	// Panics is the number of times the handler panicked.
+	Panics uint64
+
+	// Terminated is the number of poison messages terminated after MaxFailures.
+	Terminated uint64
+
+	// DeadLettered is the number of terminated messages routed with the DeadLetter func.
+	DeadLettered uint64
+}
+
+// HandlerGuard isolates a MessageHandler from the consumer: a panicking handler no longer kills
+// the goroutine consuming the messages. The message is Nak'ed for redelivery instead, and once it
+// failed MaxFailures times it is considered a poison message, routed to the DeadLetter func and
+// terminated so it isn't redelivered again.
+//
+// Failures are counted by message ID, the nats.MsgIdHdr of the message or its stream sequence.
+//
+//	guard, err := events.NewHandlerGuard(handler, events.HandlerGuardOptions{
+//		DeadLetter: stream.DeadLetter("servers.dlq"),
+//	})
+//
+//	dispatcher, err := events.NewKeyedDispatcher(8, guard.Handle)
+type HandlerGuard struct {
+	handler MessageHandler
+	opts    HandlerGuardOptions
+
+	panics       uint64
+	terminated   uint64
+	deadLettered uint64
+}
+
+// NewHandlerGuard returns a HandlerGuard wrapping the handler.
+func NewHandlerGuard(handler MessageHandler, opts HandlerGuardOptions) (*HandlerGuard, error) {
+	if handler == nil {
+		return nil, errors.Wrap(ErrNatsConfig, "handler guard requires a message handler")
+	}
+
+	if opts.MaxFailures < 0 {
+		return nil, errors.Wrap(ErrNatsConfig, "handler guard MaxFailures must not be negative")
+	}
+
+	if opts.MaxFailures == 0 {
+		opts.MaxFailures = DefaultMaxHandlerFailures
+	}
+
+	if opts.Tracker == nil {
+		opts.Tracker = NewMemoryFailureTracker()
+	}
+
+	if opts.ReasonSerializer == nil {
+		opts.ReasonSerializer = DefaultReasonSerializer
+	}
+
+	if opts.Logger == nil {
+		opts.Logger = zap.L()
+	}
+
+	return &HandlerGuard{handler: handler, opts: opts}, nil
+}
+
+// Handle hands the message to the handler, recovering it from panics. It satisfies MessageHandler.
+func (g *HandlerGuard) Handle(ctx context.Context, msg Message) {
+	id := messageID(msg)
+
+	if err := g.handle(ctx, msg); err != nil {
+		g.fail(ctx, msg, id, err)
+		return
+	}
+
+	// only redelivered messages may have failed before.
+	if md, err := msg.Metadata(); id != "" && (err != nil || md.NumDelivered > 1) {
+		if err := g.opts.Tracker.Clear(ctx, id); err != nil {
+			g.opts.Logger.Warn("message failures not cleared", zap.String("id", id), zap.Error(err))
+		}
+	}
+}
+
+// Stats returns the counts of panics and poison messages.
+func (g *HandlerGuard) Stats() HandlerGuardStats {
+	return HandlerGuardStats{
+		Panics:       atomic.LoadUint64(&g.panics),
+		Terminated:   atomic.LoadUint64(&g.terminated),
+		DeadLettered: atomic.LoadUint64(&g.deadLettered),
+	}
+}
+
+func (g *HandlerGuard) handle(ctx context.Context, msg Message) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			atomic.AddUint64(&g.panics, 1)
+
+			err = errors.Wrap(ErrHandlerPanic, fmt.Sprint(r))
+
+			g.opts.Logger.Error("message handler panicked",
+				zap.String("subject", msg.Subject()),
+				zap.Any("panic", r),
+				zap.ByteString("stack", debug.Stack()),
+			)
+		}
+	}()
+
+	g.handler(ctx, msg)
+
+	return nil
+}
+
+// fail records the failure, the message is Nak'ed for redelivery until it failed MaxFailures times.
+func (g *HandlerGuard) fail(ctx context.Context, msg Message, id string, cause error) {
+	reason := g.opts.ReasonSerializer(cause)
+
+	if id == "" {
+		_ = msg.NakWithReason(reason, 0)
+		return
+	}
+
+	failures, err := g.opts.Tracker.Fail(ctx, id)
+	if err != nil {
+		g.opts.Logger.Warn("message failure not tracked", zap.String("id", id), zap.Error(err))
+	}
+
+	if err != nil || failures < g.opts.MaxFailures {
+		_ = msg.NakWithReason(reason, 0)
+		return
+	}
+
+	if g.opts.DeadLetter != nil {
+		if err := g.opts.DeadLetter(ctx, msg, cause); err != nil {
+			// kept for redelivery rather than lost, the next failure routes it again.
+			g.opts.Logger.Error("poison message not routed to the dead letter subject", zap.String("id", id), zap.Error(err))
+
+			_ = msg.NakWithReason(reason, 0)
+
+			return
+		}
+
+		atomic.AddUint64(&g.deadLettered, 1)
+	}
+
+	atomic.AddUint64(&g.terminated, 1)
+
+	g.opts.Logger.Warn("poison message terminated",
+		zap.String("id", id),
+		zap.String("subject", msg.Subject()),
+		zap.Int("failures", failures),
+		zap.Error(cause),
+	)
+
+	_ = msg.TermWithReason(reason)
+
+	if err := g.opts.Tracker.Clear(ctx, id); err != nil {
+		g.opts.Logger.Warn("message failures not cleared", zap.String("id", id), zap.Error(err))
+	}
+}
+
+// messageID returns the ID failures of the message are counted by, the nats.MsgIdHdr of the message
+// or its stream sequence, empty when it has neither.
+func messageID(msg Message) string {
+	if nm, err := AsNatsMsg(msg); err == nil && nm.Header != nil {
+		if id := nm.Header.Get(nats.MsgIdHdr); id != "" {
+			return id
+		}
+	}
+
+	if md, err := msg.Metadata(); err == nil && md.Stream != "" {
+		return fmt.Sprintf("%s.%d", md.Stream, md.StreamSequence)
+	}
+
+	return ""
+}
+
+// recoverCallback keeps a panic in a subscription callback from crashing the process, the message is Nak'ed.
+func recoverCallback(msg *nats.Msg) {
+	if r := recover(); r != nil {
+		log.Printf("subscription callback for subject=%s panicked: %v\n%s", msg.Subject, r, debug.Stack())
+
+		_ = msg.Nak()
+	}
+}
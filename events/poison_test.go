@@ -0,0 +1,158 @@
+//nolint:all
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	natsTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+func TestHandlerGuardPoisonMessage(t *testing.T) {
+	srv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, srv)
+
+	nc, js := natsTest.JetStreamContext(t, srv)
+	njs := NewJetstreamFromConn(nc)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName: "TestHandlerGuard",
+		Stream: &NatsStreamOptions{
+			Name:      "poison",
+			Subjects:  []string{"pre.>"},
+			Retention: "limits",
+		},
+		Consumer: &NatsConsumerOptions{
+			Name:              "poison_consumer",
+			Pull:              true,
+			SubscribeSubjects: []string{"pre.servers"},
+			FilterSubject:     "pre.servers",
+		},
+		PublisherSubjectPrefix: "pre",
+	}
+	require.NoError(t, njs.addStream())
+	require.NoError(t, njs.addConsumer())
+
+	_, err := njs.Subscribe(context.TODO())
+	require.NoError(t, err)
+
+	var handled []string
+
+	guard, err := NewHandlerGuard(func(ctx context.Context, msg Message) {
+		if string(msg.Data()) == "poison" {
+			panic("can't handle poison")
+		}
+
+		handled = append(handled, string(msg.Data()))
+		_ = msg.Ack()
+	}, HandlerGuardOptions{
+		MaxFailures: 2,
+		DeadLetter:  njs.DeadLetter("dlq"),
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, njs.Publish(context.TODO(), "servers", []byte("poison")))
+	require.NoError(t, njs.Publish(context.TODO(), "servers", []byte("healthy")))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// the poison message is redelivered without a delay, it may be fetched again before "healthy"
+	for guard.Stats().Terminated == 0 || len(handled) == 0 {
+		fetchCtx, fetchCancel := context.WithTimeout(ctx, time.Second)
+		msgs, err := njs.PullMsg(fetchCtx, 1)
+		fetchCancel()
+
+		if ctx.Err() != nil {
+			t.Fatalf("poison message wasn't terminated or healthy wasn't handled: %+v %v", guard.Stats(), handled)
+		}
+
+		if err != nil {
+			continue
+		}
+
+		for _, msg := range msgs {
+			guard.Handle(ctx, msg)
+		}
+	}
+
+	assert.Equal(t, HandlerGuardStats{Panics: 2, Terminated: 1, DeadLettered: 1}, guard.Stats())
+	assert.Equal(t, []string{"healthy"}, handled)
+
+	dlq, err := js.GetLastMsg("poison", "pre.dlq")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("poison"), dlq.Data)
+	assert.Equal(t, "pre.servers", dlq.Header.Get(DeadLetterSubjectHeader))
+	assert.Contains(t, dlq.Header.Get(DeadLetterReasonHeader), "can't handle poison")
+	assert.Contains(t, dlq.Header.Get(FailureReasonHeader), "can't handle poison", "the reason of the previous failure should be kept")
+
+	// the poison message isn't redelivered once terminated
+	info, err := js.ConsumerInfo("poison", "poison_consumer")
+	require.NoError(t, err)
+	assert.Equal(t, 0, info.NumAckPending)
+	assert.Equal(t, 0, info.NumRedelivered)
+	assert.Equal(t, uint64(0), info.NumPending)
+}
+
+func TestHandlerGuardUntrackedMessage(t *testing.T) {
+	guard, err := NewHandlerGuard(func(ctx context.Context, msg Message) {
+		panic("boom")
+	}, HandlerGuardOptions{})
+	require.NoError(t, err)
+
+	// messages without an ID can't be tracked, they are only recovered
+	msg := &natsMsg{msg: nats.NewMsg("test")}
+	assert.NotPanics(t, func() { guard.Handle(context.Background(), msg) })
+	assert.Equal(t, HandlerGuardStats{Panics: 1}, guard.Stats())
+
+	_, err = NewHandlerGuard(nil, HandlerGuardOptions{})
+	assert.ErrorIs(t, err, ErrNatsConfig)
+
+	_, err = NewHandlerGuard(guard.Handle, HandlerGuardOptions{MaxFailures: -1})
+	assert.ErrorIs(t, err, ErrNatsConfig)
+}
+
+func TestFailureTrackers(t *testing.T) {
+	srv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, srv)
+
+	_, js := natsTest.JetStreamContext(t, srv)
+
+	kv, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: "failures", TTL: time.Hour})
+	require.NoError(t, err)
+
+	trackers := map[string]FailureTracker{
+		"memory": NewMemoryFailureTracker(),
+		"kv":     NewKVFailureTracker(kv),
+	}
+
+	for name, tracker := range trackers {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			// IDs aren't restricted to the characters allowed in KV keys
+			for _, want := range []int{1, 2, 3} {
+				got, err := tracker.Fail(ctx, "stream.1 with spaces")
+				require.NoError(t, err)
+				assert.Equal(t, want, got)
+			}
+
+			got, err := tracker.Fail(ctx, "stream.2")
+			require.NoError(t, err)
+			assert.Equal(t, 1, got)
+
+			require.NoError(t, tracker.Clear(ctx, "stream.1 with spaces"))
+			require.NoError(t, tracker.Clear(ctx, "never failed"))
+
+			got, err = tracker.Fail(ctx, "stream.1 with spaces")
+			require.NoError(t, err)
+			assert.Equal(t, 1, got)
+		})
+	}
+}
@@ -0,0 +1,247 @@
+package events
+
+import (
+	"context"
+	"encoding/base64"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+)
+
+const (
+	// FailureReasonHeader holds why the previous delivery of a redelivered message was Nak'ed or
+	// terminated, as given to NakWithReason or TermWithReason.
+	FailureReasonHeader = "Hollow-Failure-Reason"
+
+	// number of reasons kept by the default in-memory store, above which arbitrary ones are dropped.
+	memoryReasonStoreSize = 10000
+)
+
+// ErrReasonStore is returned when the failure reason of a message couldn't be saved or loaded.
+var ErrReasonStore = errors.New("error in failure reason store")
+
+// ReasonStore keeps the failure reasons of the Nak'ed and terminated messages, by message ID,
+// so they outlive the delivery that failed.
+type ReasonStore interface {
+	// Save records the reason of the latest failure of the message.
+	Save(ctx context.Context, id, reason string) error
+
+	// Load returns the reason of the latest failure of the message, empty when none was saved.
+	Load(ctx context.Context, id string) (string, error)
+
+	// Clear forgets the reason, once the message was acked.
+	Clear(ctx context.Context, id string) error
+}
+
+// ReasonSerializer turns the error a message failed with into the reason it is Nak'ed or terminated
+// with, e.g. to add an error code or strip sensitive details.
+type ReasonSerializer func(err error) string
+
+// DefaultReasonSerializer uses the message of the error as the reason.
+func DefaultReasonSerializer(err error) string {
+	return err.Error()
+}
+
+// memoryReasonStore keeps the reasons within the process, redeliveries to other instances don't see them.
+type memoryReasonStore struct {
+	mu      sync.Mutex
+	reasons map[string]string
+}
+
+// NewMemoryReasonStore returns a ReasonStore keeping the reasons in memory, this is the default.
+func NewMemoryReasonStore() ReasonStore {
+	return &memoryReasonStore{reasons: map[string]string{}}
+}
+
+func (s *memoryReasonStore) Save(_ context.Context, id, reason string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.reasons[id]; !ok && len(s.reasons) >= memoryReasonStoreSize {
+		// drop an arbitrary reason, map iteration order is random
+		for k := range s.reasons {
+			delete(s.reasons, k)
+			break
+		}
+	}
+
+	s.reasons[id] = reason
+
+	return nil
+}
+
+func (s *memoryReasonStore) Load(_ context.Context, id string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.reasons[id], nil
+}
+
+func (s *memoryReasonStore) Clear(_ context.Context, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.reasons, id)
+
+	return nil
+}
+
+// kvReasonStore keeps the reasons in a JetStream KV bucket.
+type kvReasonStore struct {
+	kv nats.KeyValue
+}
+
+// NewKVReasonStore returns a ReasonStore keeping the reasons in the KV bucket, shared by the consumer
+// instances and kept for the dead letter consumers once the message was terminated. The bucket is
+// expected to have a TTL to drop the reasons of terminated messages.
+func NewKVReasonStore(kv nats.KeyValue) ReasonStore {
+	return &kvReasonStore{kv: kv}
+}
+
+func (s *kvReasonStore) Save(_ context.Context, id, reason string) error {
+	if _, err := s.kv.Put(reasonKey(id), []byte(reason)); err != nil {
+		return errors.Wrap(ErrReasonStore, err.Error())
+	}
+
+	return nil
+}
+
+func (s *kvReasonStore) Load(_ context.Context, id string) (string, error) {
+	entry, err := s.kv.Get(reasonKey(id))
+	if err != nil {
+		if errors.Is(err, nats.ErrKeyNotFound) {
+			return "", nil
+		}
+
+		return "", errors.Wrap(ErrReasonStore, err.Error())
+	}
+
+	return string(entry.Value()), nil
+}
+
+func (s *kvReasonStore) Clear(_ context.Context, id string) error {
+	if err := s.kv.Delete(reasonKey(id)); err != nil && !errors.Is(err, nats.ErrKeyNotFound) {
+		return errors.Wrap(ErrReasonStore, err.Error())
+	}
+
+	return nil
+}
+
+// reasonKey encodes the message ID into a valid KV key.
+func reasonKey(id string) string {
+	return "reason." + base64.RawURLEncoding.EncodeToString([]byte(id))
+}
+
+// SetReasonStore sets where the failure reasons of the messages are kept, NewMemoryReasonStore by default.
+func (n *NatsJetstream) SetReasonStore(store ReasonStore) {
+	n.reasonsMu.Lock()
+	defer n.reasonsMu.Unlock()
+
+	n.reasons = store
+}
+
+func (n *NatsJetstream) reasonStore() ReasonStore {
+	n.reasonsMu.Lock()
+	defer n.reasonsMu.Unlock()
+
+	if n.reasons == nil {
+		n.reasons = NewMemoryReasonStore()
+	}
+
+	return n.reasons
+}
+
+// FailureReason returns why the previous delivery of the message failed, empty when it wasn't
+// redelivered or its previous delivery was Nak'ed without a reason.
+func FailureReason(msg Message) string {
+	if nm, err := AsNatsMsg(msg); err == nil && nm.Header != nil {
+		return nm.Header.Get(FailureReasonHeader)
+	}
+
+	return ""
+}
+
+// withFailureReason sets the FailureReasonHeader of a redelivered message to the reason of its previous failure.
+func (nm *natsMsg) withFailureReason() {
+	if nm.reasons == nil {
+		return
+	}
+
+	md, err := nm.msg.Metadata()
+	if err != nil || md.NumDelivered < 2 {
+		return
+	}
+
+	id := messageID(nm)
+	if id == "" {
+		return
+	}
+
+	reason, err := nm.reasons.Load(context.Background(), id)
+	if err != nil || reason == "" {
+		return
+	}
+
+	if nm.msg.Header == nil {
+		nm.msg.Header = nats.Header{}
+	}
+
+	nm.msg.Header.Set(FailureReasonHeader, reason)
+}
+
+// saveReason records the reason the message failed with before it is Nak'ed or terminated,
+// the message is resolved anyway when it can't be saved.
+func (nm *natsMsg) saveReason(reason string) error {
+	if nm.reasons == nil {
+		return nil
+	}
+
+	id := messageID(nm)
+	if id == "" {
+		return nil
+	}
+
+	return nm.reasons.Save(context.Background(), id, reason)
+}
+
+// clearReason forgets the reason of a message that was eventually acked.
+func (nm *natsMsg) clearReason() {
+	if nm.reasons == nil || nm.msg.Header.Get(FailureReasonHeader) == "" {
+		return
+	}
+
+	if id := messageID(nm); id != "" {
+		_ = nm.reasons.Clear(context.Background(), id)
+	}
+}
+
+func (nm *natsMsg) NakWithReason(reason string, delay time.Duration) error {
+	saveErr := nm.saveReason(reason)
+
+	nm.resolve()
+
+	var err error
+	if delay > 0 {
+		err = nm.msg.NakWithDelay(delay)
+	} else {
+		err = nm.msg.Nak()
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return saveErr
+}
+
+func (nm *natsMsg) TermWithReason(reason string) error {
+	saveErr := nm.saveReason(reason)
+
+	if err := nm.Term(); err != nil {
+		return err
+	}
+
+	return saveErr
+}
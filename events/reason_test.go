@@ -0,0 +1,128 @@
+//nolint:all
+package events
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	natsTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+func TestNakWithReason(t *testing.T) {
+	srv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, srv)
+
+	nc, js := natsTest.JetStreamContext(t, srv)
+	njs := NewJetstreamFromConn(nc)
+	defer njs.Close()
+
+	kv, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: "reasons", TTL: time.Hour})
+	require.NoError(t, err)
+
+	store := NewKVReasonStore(kv)
+	njs.SetReasonStore(store)
+
+	njs.parameters = &NatsOptions{
+		AppName: "TestNakWithReason",
+		Stream: &NatsStreamOptions{
+			Name:      "reasons",
+			Subjects:  []string{"pre.>"},
+			Retention: "limits",
+		},
+		Consumer: &NatsConsumerOptions{
+			Name:              "reasons_consumer",
+			Pull:              true,
+			SubscribeSubjects: []string{"pre.servers"},
+			FilterSubject:     "pre.servers",
+		},
+		PublisherSubjectPrefix: "pre",
+	}
+	require.NoError(t, njs.addStream())
+	require.NoError(t, njs.addConsumer())
+
+	_, err = njs.Subscribe(context.TODO())
+	require.NoError(t, err)
+
+	require.NoError(t, njs.Publish(context.TODO(), "servers", []byte("nak")))
+
+	fetch := func() Message {
+		t.Helper()
+
+		msgs, err := njs.PullMsg(context.Background(), 1)
+		require.NoError(t, err)
+		require.Len(t, msgs, 1)
+
+		return msgs[0]
+	}
+
+	msg := fetch()
+	assert.Empty(t, FailureReason(msg))
+	require.NoError(t, msg.NakWithReason("database unavailable", 0))
+
+	// the reason is set on the redelivery
+	msg = fetch()
+	assert.Equal(t, []byte("nak"), msg.Data())
+	assert.Equal(t, "database unavailable", FailureReason(msg))
+
+	id := messageID(msg)
+	require.NoError(t, msg.Ack())
+
+	reason, err := store.Load(context.Background(), id)
+	require.NoError(t, err)
+	assert.Empty(t, reason, "the reason should be cleared once the message is acked")
+
+	// the reason of terminated messages is kept for the dead letter consumers
+	require.NoError(t, njs.Publish(context.TODO(), "servers", []byte("term")))
+
+	msg = fetch()
+	assert.Equal(t, []byte("term"), msg.Data())
+	require.NoError(t, msg.TermWithReason("invalid payload"))
+
+	reason, err = store.Load(context.Background(), messageID(msg))
+	require.NoError(t, err)
+	assert.Equal(t, "invalid payload", reason)
+}
+
+func TestReasonStores(t *testing.T) {
+	srv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, srv)
+
+	_, js := natsTest.JetStreamContext(t, srv)
+
+	kv, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: "reasons", TTL: time.Hour})
+	require.NoError(t, err)
+
+	stores := map[string]ReasonStore{
+		"memory": NewMemoryReasonStore(),
+		"kv":     NewKVReasonStore(kv),
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+
+			reason, err := store.Load(ctx, "stream.1 with spaces")
+			require.NoError(t, err)
+			assert.Empty(t, reason)
+
+			require.NoError(t, store.Save(ctx, "stream.1 with spaces", "first"))
+			require.NoError(t, store.Save(ctx, "stream.1 with spaces", "second"))
+
+			reason, err = store.Load(ctx, "stream.1 with spaces")
+			require.NoError(t, err)
+			assert.Equal(t, "second", reason)
+
+			require.NoError(t, store.Clear(ctx, "stream.1 with spaces"))
+			require.NoError(t, store.Clear(ctx, "stream.1 with spaces"))
+
+			reason, err = store.Load(ctx, "stream.1 with spaces")
+			require.NoError(t, err)
+			assert.Empty(t, reason)
+		})
+	}
+}
@@ -8,8 +8,10 @@
 package registry
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"strings"
 	"time"
 
 	"github.com/nats-io/nats.go"
@@ -110,3 +112,32 @@ func LastContact(id ControllerID) (time.Time, error) {
 	}
 	return ar.LastActive, nil
 }
+
+// CountControllers returns the number of active controllers of the app
+func CountControllers(ctx context.Context, app string) (int, error) {
+	if registry == nil {
+		return 0, ErrRegistryUninitialized
+	}
+	keys, err := registry.Keys(nats.Context(ctx))
+	if err != nil {
+		if errors.Is(err, nats.ErrNoKeysFound) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	var count int
+	for _, key := range keys {
+		if strings.HasPrefix(key, app+"/") {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// InstanceCounter returns an events.InstanceCounter counting the active controllers of the app,
+// for the cooperative fetch mode of pull consumers shared by the instances of the app.
+func InstanceCounter(app string) events.InstanceCounter {
+	return events.InstanceCounterFunc(func(ctx context.Context) (int, error) {
+		return CountControllers(ctx, app)
+	})
+}
@@ -2,6 +2,7 @@
 package registry
 
 import (
+	"context"
 	"testing"
 
 	"github.com/nats-io/nats.go"
@@ -47,6 +48,12 @@ func TestAppLifecycle(t *testing.T) {
 	require.NoError(t, err)
 	err = ControllerCheckin(id)
 	require.NoError(t, err)
+	count, err := CountControllers(context.TODO(), "testApp")
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	count, err = InstanceCounter("otherApp").InstanceCount(context.TODO())
+	require.NoError(t, err)
+	require.Equal(t, 0, count)
 	_, err = LastContact(id)
 	require.NoError(t, err)
 	err = DeregisterController(id)
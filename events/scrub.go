@@ -0,0 +1,240 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/pkg/errors"
+)
+
+// ErrPayloadScrub is returned when publishing a payload a PayloadScrubber failed to scrub, the
+// payload isn't published so the data it was to redact doesn't leak.
+var ErrPayloadScrub = errors.New("error scrubbing payload")
+
+// RedactedValue replaces the data redacted by the built-in scrubbers.
+const RedactedValue = "[REDACTED]"
+
+// PayloadScrubber redacts sensitive data, e.g. secrets, from the payloads before they are published.
+// Scrub returns the payload to publish and the number of values it redacted.
+type PayloadScrubber interface {
+	Scrub(subject string, data []byte) ([]byte, int, error)
+}
+
+// PayloadScrubberFunc is a function implementing PayloadScrubber.
+type PayloadScrubberFunc func(subject string, data []byte) ([]byte, int, error)
+
+// Scrub calls f.
+func (f PayloadScrubberFunc) Scrub(subject string, data []byte) ([]byte, int, error) {
+	return f(subject, data)
+}
+
+// PayloadScrubStats holds the metrics of the payload scrubbers, to be exported to the metrics system.
+type PayloadScrubStats struct {
+	// Payloads counts the published payloads with at least one redaction
+	Payloads uint64
+	// Redactions counts the values redacted
+	Redactions uint64
+}
+
+// payloadScrubbers is the chain of scrubbers applied to the published payloads.
+type payloadScrubbers struct {
+	scrubbers []PayloadScrubber
+
+	payloads   uint64
+	redactions uint64
+}
+
+func (ps *payloadScrubbers) set(scrubbers []PayloadScrubber) {
+	ps.scrubbers = scrubbers
+}
+
+// scrub runs the scrubbers in order, each one scrubbing the payload returned by the previous one.
+func (ps *payloadScrubbers) scrub(subject string, data []byte) ([]byte, error) {
+	var redactions int
+
+	for _, scrubber := range ps.scrubbers {
+		scrubbed, n, err := scrubber.Scrub(subject, data)
+		if err != nil {
+			return nil, errors.Wrap(ErrPayloadScrub, err.Error())
+		}
+
+		data = scrubbed
+		redactions += n
+	}
+
+	if redactions > 0 {
+		atomic.AddUint64(&ps.payloads, 1)
+		atomic.AddUint64(&ps.redactions, uint64(redactions))
+	}
+
+	return data, nil
+}
+
+func (ps *payloadScrubbers) stats() PayloadScrubStats {
+	return PayloadScrubStats{
+		Payloads:   atomic.LoadUint64(&ps.payloads),
+		Redactions: atomic.LoadUint64(&ps.redactions),
+	}
+}
+
+// SetPayloadScrubbers sets the scrubbers applied in order to the payloads before they are published,
+// including the requests and replies of PublishAndAwaitReply and ReplyTo, it is to be set before publishing. Publishing fails with ErrPayloadScrub when a scrubber fails.
+//
+// Payloads published with a content encoding by PublishWithOptions are opaque and aren't scrubbed,
+// PublishEncoded scrubs them before applying the encoding.
+func (n *NatsJetstream) SetPayloadScrubbers(scrubbers ...PayloadScrubber) {
+	n.scrubbers.set(scrubbers)
+}
+
+// PayloadScrubStats returns the metrics of the payload scrubbers.
+func (n *NatsJetstream) PayloadScrubStats() PayloadScrubStats {
+	return n.scrubbers.stats()
+}
+
+// RegexScrubber replaces the matches of its patterns in the payloads with RedactedValue, e.g. to
+// redact tokens whatever the encoding of the payload. Patterns matching JSON quotes or delimiters
+// would leave JSON payloads invalid.
+type RegexScrubber struct {
+	patterns []*regexp.Regexp
+}
+
+// NewRegexScrubber returns a RegexScrubber redacting the matches of the patterns.
+func NewRegexScrubber(patterns ...string) (*RegexScrubber, error) {
+	rs := &RegexScrubber{}
+
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, errors.Wrap(ErrPayloadScrub, err.Error())
+		}
+
+		rs.patterns = append(rs.patterns, re)
+	}
+
+	return rs, nil
+}
+
+// Scrub replaces the matches of the patterns with RedactedValue.
+func (rs *RegexScrubber) Scrub(_ string, data []byte) ([]byte, int, error) {
+	var redactions int
+
+	for _, re := range rs.patterns {
+		matches := len(re.FindAllIndex(data, -1))
+		if matches == 0 {
+			continue
+		}
+
+		data = re.ReplaceAllLiteral(data, []byte(RedactedValue))
+		redactions += matches
+	}
+
+	return data, redactions, nil
+}
+
+// JSONPathScrubber replaces the values at its paths in JSON payloads with RedactedValue, other payloads
+// are left as is. Paths are the keys of the nested objects separated by dots, e.g. credentials.password,
+// where * matches any key or array element, e.g. users.*.token.
+//
+// The objects of the payloads with redactions are published with their keys sorted.
+type JSONPathScrubber struct {
+	paths [][]string
+}
+
+// NewJSONPathScrubber returns a JSONPathScrubber redacting the values at the paths.
+func NewJSONPathScrubber(paths ...string) (*JSONPathScrubber, error) {
+	js := &JSONPathScrubber{}
+
+	for _, path := range paths {
+		segments := strings.Split(path, ".")
+
+		for _, s := range segments {
+			if s == "" {
+				return nil, errors.Wrap(ErrPayloadScrub, "invalid JSON path "+strconv.Quote(path))
+			}
+		}
+
+		js.paths = append(js.paths, segments)
+	}
+
+	return js, nil
+}
+
+// Scrub replaces the values at the paths with RedactedValue.
+func (js *JSONPathScrubber) Scrub(_ string, data []byte) ([]byte, int, error) {
+	if !json.Valid(data) {
+		return data, 0, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, 0, err
+	}
+
+	var redactions int
+
+	for _, path := range js.paths {
+		redactions += redactJSONPath(doc, path)
+	}
+
+	if redactions == 0 {
+		return data, 0, nil
+	}
+
+	var buf bytes.Buffer
+
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(doc); err != nil {
+		return nil, 0, err
+	}
+
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), redactions, nil
+}
+
+// redactJSONPath replaces the values at the path in the decoded document, returning how many it replaced.
+func redactJSONPath(doc interface{}, path []string) int {
+	var redactions int
+
+	switch v := doc.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			if path[0] != "*" && path[0] != key {
+				continue
+			}
+
+			if len(path) == 1 {
+				v[key] = RedactedValue
+				redactions++
+
+				continue
+			}
+
+			redactions += redactJSONPath(child, path[1:])
+		}
+	case []interface{}:
+		for i, child := range v {
+			if path[0] != "*" && path[0] != strconv.Itoa(i) {
+				continue
+			}
+
+			if len(path) == 1 {
+				v[i] = RedactedValue
+				redactions++
+
+				continue
+			}
+
+			redactions += redactJSONPath(child, path[1:])
+		}
+	}
+
+	return redactions
+}
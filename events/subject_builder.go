@@ -0,0 +1,92 @@
+package events
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ErrInvalidSubject is returned when a subject, or one of its tokens, isn't a valid NATS subject.
+var ErrInvalidSubject = errors.New("invalid subject")
+
+// SubjectBuilder builds a subject from its tokens, validating them once built. Builders are
+// values, appending tokens returns a new builder and leaves the original untouched so a common
+// root can be shared.
+//
+//	servers := events.NewSubjectBuilder("servers")
+//	subject, err := servers.With(siteID, "create").Build()
+//
+// The subjects built are suffixes, the PublisherSubjectPrefix is prepended when publishing.
+type SubjectBuilder struct {
+	tokens []string
+}
+
+// NewSubjectBuilder returns a SubjectBuilder starting with the tokens.
+func NewSubjectBuilder(tokens ...string) SubjectBuilder {
+	return SubjectBuilder{tokens: append([]string(nil), tokens...)}
+}
+
+// With returns a builder with the tokens appended.
+func (b SubjectBuilder) With(tokens ...string) SubjectBuilder {
+	all := make([]string, 0, len(b.tokens)+len(tokens))
+	all = append(all, b.tokens...)
+	all = append(all, tokens...)
+
+	return SubjectBuilder{tokens: all}
+}
+
+// All returns a builder with the > wildcard appended, matching all the subjects under the subject built so far.
+func (b SubjectBuilder) All() SubjectBuilder {
+	return b.With(">")
+}
+
+// String returns the subject without validating it.
+func (b SubjectBuilder) String() string {
+	return strings.Join(b.tokens, ".")
+}
+
+// Build returns the subject, an error wrapping ErrInvalidSubject is returned when a token is
+// empty or holds a dot, whitespace, or a wildcard which isn't the whole token.
+func (b SubjectBuilder) Build() (string, error) {
+	subject := b.String()
+
+	if len(b.tokens) == 0 {
+		return "", errors.Wrap(ErrInvalidSubject, "subject is empty")
+	}
+
+	if err := checkSubjectTokens(subject, b.tokens); err != nil {
+		return "", err
+	}
+
+	return subject, nil
+}
+
+// ValidateSubject returns an error wrapping ErrInvalidSubject when the subject isn't valid,
+// the * wildcard may stand for any token and the > wildcard for the last one.
+func ValidateSubject(subject string) error {
+	if subject == "" {
+		return errors.Wrap(ErrInvalidSubject, "subject is empty")
+	}
+
+	return checkSubjectTokens(subject, strings.Split(subject, "."))
+}
+
+func checkSubjectTokens(subject string, tokens []string) error {
+	for i, token := range tokens {
+		switch {
+		case token == "":
+			return errors.Wrap(ErrInvalidSubject, subject+": empty token")
+		case token == ">" && i != len(tokens)-1:
+			return errors.Wrap(ErrInvalidSubject, subject+": > wildcard isn't the last token")
+		case token != "*" && token != ">" && strings.ContainsAny(token, ".*> \t\r\n"):
+			return errors.Wrap(ErrInvalidSubject, subject+": invalid token "+token)
+		}
+	}
+
+	return nil
+}
+
+// SubjectMatches returns true when the subject matches the pattern, which may hold the * and > wildcards.
+func SubjectMatches(subject, pattern string) bool {
+	return subjectIsSubset(subject, pattern)
+}
@@ -0,0 +1,42 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubjectBuilder(t *testing.T) {
+	servers := NewSubjectBuilder("servers")
+
+	subject, err := servers.With("ams1", "create").Build()
+	require.NoError(t, err)
+	assert.Equal(t, "servers.ams1.create", subject)
+
+	// the root is left untouched
+	assert.Equal(t, "servers", servers.String())
+	assert.Equal(t, "servers.>", servers.All().String())
+
+	for _, invalid := range []SubjectBuilder{
+		NewSubjectBuilder(),
+		servers.With(""),
+		servers.With("ams1.dc2"),
+		servers.With("ams 1"),
+		servers.With("ams*"),
+		servers.All().With("create"),
+	} {
+		_, err := invalid.Build()
+		assert.ErrorIs(t, err, ErrInvalidSubject, invalid.String())
+	}
+
+	_, err = servers.With("*", "create").Build()
+	assert.NoError(t, err)
+}
+
+func TestSubjectMatches(t *testing.T) {
+	assert.True(t, SubjectMatches("servers.ams1.create", "servers.*.create"))
+	assert.True(t, SubjectMatches("servers.ams1.create", "servers.>"))
+	assert.False(t, SubjectMatches("servers.ams1", "servers.*.create"))
+	assert.False(t, SubjectMatches("servers", "servers.>"))
+}
@@ -0,0 +1,113 @@
+package events
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/nats-io/nats.go"
+	"github.com/pkg/errors"
+)
+
+const (
+	// TombstoneHeader is set to "true" on tombstone messages, announcing the deletion of a resource.
+	TombstoneHeader = "Hollow-Tombstone"
+
+	// ResourceTypeHeader holds the type of the resource a tombstone was published for.
+	ResourceTypeHeader = "Hollow-Resource-Type"
+
+	// ResourceIDHeader holds the ID of the resource a tombstone was published for.
+	ResourceIDHeader = "Hollow-Resource-ID"
+
+	tombstoneHeaderValue = "true"
+)
+
+var (
+	// ErrInvalidTombstone is returned when publishing a tombstone without a resource type or ID,
+	// or when the headers of a tombstone message can't be parsed.
+	ErrInvalidTombstone = errors.New("invalid tombstone")
+
+	// ErrNotTombstone is returned when parsing a message which isn't a tombstone.
+	ErrNotTombstone = errors.New("message is not a tombstone")
+)
+
+// Tombstone announces the deletion of a resource, consumers building materialized views
+// remove the resource from them when receiving it.
+//
+// Tombstones are published with an empty payload, the TombstoneHeader and the resource
+// type and ID headers. They are keyed with the resource ID, see WithMessageKey, so they are
+// processed after the other messages for the resource by a KeyedDispatcher.
+type Tombstone struct {
+	ResourceType ResourceType
+	ResourceID   uuid.UUID
+}
+
+func (t Tombstone) validate() error {
+	if t.ResourceType == "" {
+		return errors.Wrap(ErrInvalidTombstone, "resource type is required")
+	}
+
+	if t.ResourceID == uuid.Nil {
+		return errors.Wrap(ErrInvalidTombstone, "resource ID is required")
+	}
+
+	return nil
+}
+
+// setHeaders sets the tombstone headers on the message headers.
+func (t Tombstone) setHeaders(h nats.Header) {
+	h.Set(TombstoneHeader, tombstoneHeaderValue)
+	h.Set(ResourceTypeHeader, string(t.ResourceType))
+	h.Set(ResourceIDHeader, t.ResourceID.String())
+}
+
+// withTombstone publishes the message as the tombstone.
+func withTombstone(t Tombstone) PublishOption {
+	return func(o *publishOptions) {
+		o.tombstone = &t
+	}
+}
+
+// PublishTombstone publishes a tombstone for the resource with PublishWithOptions, returning
+// the stream sequence it was stored at.
+//
+// NOTE: The subject passed here will be prepended with any configured PublisherSubjectPrefix.
+func (n *NatsJetstream) PublishTombstone(ctx context.Context, subjectSuffix string, t Tombstone, opts ...PublishOption) (uint64, error) {
+	if err := t.validate(); err != nil {
+		return 0, err
+	}
+
+	// the options passed take precedence over the default message key
+	opts = append([]PublishOption{WithMessageKey(t.ResourceID.String())}, opts...)
+	opts = append(opts, withTombstone(t))
+
+	return n.PublishWithOptions(ctx, subjectSuffix, nil, opts...)
+}
+
+// ParseTombstone returns the tombstone held by the message, ErrNotTombstone is returned
+// for other messages.
+func ParseTombstone(msg Message) (Tombstone, error) {
+	nm, err := AsNatsMsg(msg)
+	if err != nil || !isTombstone(nm.Header) {
+		return Tombstone{}, ErrNotTombstone
+	}
+
+	id, err := uuid.Parse(nm.Header.Get(ResourceIDHeader))
+	if err != nil {
+		return Tombstone{}, errors.Wrap(ErrInvalidTombstone, err.Error())
+	}
+
+	t := Tombstone{
+		ResourceType: ResourceType(nm.Header.Get(ResourceTypeHeader)),
+		ResourceID:   id,
+	}
+
+	if err := t.validate(); err != nil {
+		return Tombstone{}, err
+	}
+
+	return t, nil
+}
+
+func isTombstone(h nats.Header) bool {
+	return h != nil && h.Get(TombstoneHeader) == tombstoneHeaderValue
+}
@@ -0,0 +1,72 @@
+//nolint:all
+package events
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	natsTest "go.hollow.sh/toolbox/events/internal/test"
+)
+
+func TestPublishTombstone(t *testing.T) {
+	jsSrv := natsTest.StartJetStreamServer(t)
+	defer natsTest.ShutdownJetStream(t, jsSrv)
+
+	jsConn, _ := natsTest.JetStreamContext(t, jsSrv)
+	njs := NewJetstreamFromConn(jsConn)
+	defer njs.Close()
+
+	njs.parameters = &NatsOptions{
+		AppName: "TestPublishTombstone",
+		Stream: &NatsStreamOptions{
+			Name:      "test_stream",
+			Subjects:  []string{"pre.servers.>"},
+			Retention: "workQueue",
+		},
+		Consumer: &NatsConsumerOptions{
+			Name:              "test_consumer",
+			Pull:              true,
+			SubscribeSubjects: []string{"pre.servers.>"},
+			FilterSubject:     "pre.servers.>",
+		},
+		PublisherSubjectPrefix: "pre",
+	}
+	require.NoError(t, njs.addStream())
+	require.NoError(t, njs.addConsumer())
+
+	_, err := njs.Subscribe(context.TODO())
+	require.NoError(t, err)
+
+	id := uuid.New()
+
+	_, err = njs.PublishTombstone(context.TODO(), "servers.delete", Tombstone{ResourceType: "servers"})
+	require.ErrorIs(t, err, ErrInvalidTombstone)
+
+	require.NoError(t, njs.Publish(context.TODO(), "servers.update", []byte(`{}`)))
+
+	_, err = njs.PublishTombstone(context.TODO(), "servers.delete", Tombstone{ResourceType: "servers", ResourceID: id})
+	require.NoError(t, err)
+
+	msgs, err := njs.PullMsg(context.TODO(), 2)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+
+	assert.False(t, msgs[0].IsTombstone())
+	_, err = ParseTombstone(msgs[0])
+	assert.ErrorIs(t, err, ErrNotTombstone)
+
+	assert.True(t, msgs[1].IsTombstone())
+	assert.Empty(t, msgs[1].Data())
+	assert.Equal(t, id.String(), KeyFromHeader(MessageKeyHeader)(msgs[1]))
+
+	ts, err := ParseTombstone(msgs[1])
+	require.NoError(t, err)
+	assert.Equal(t, Tombstone{ResourceType: "servers", ResourceID: id}, ts)
+
+	_, err = ParseTombstone(&bogusMsg{})
+	assert.ErrorIs(t, err, ErrNotTombstone)
+}
@@ -0,0 +1,67 @@
+package events
+
+import (
+	"context"
+	"time"
+)
+
+// StreamUsage holds the resources used by a stream and the limits of the account it belongs to.
+type StreamUsage struct {
+	// Stream is the name of the stream.
+	Stream string
+
+	// Messages is the number of messages stored in the stream.
+	Messages uint64
+
+	// Bytes is the size of the messages stored in the stream.
+	Bytes uint64
+
+	// Consumers is the number of consumers on the stream.
+	Consumers int
+
+	// Account holds the resources used by the account and its limits.
+	Account AccountUsage
+}
+
+// AccountUsage holds the resources used by a stream broker account and its limits.
+//
+// A limit of -1 means unlimited.
+type AccountUsage struct {
+	Memory    uint64
+	Store     uint64
+	Streams   int
+	Consumers int
+
+	MaxMemory    int64
+	MaxStore     int64
+	MaxStreams   int
+	MaxConsumers int
+}
+
+// UsageReporter is implemented by stream brokers able to report their usage.
+type UsageReporter interface {
+	Usage(ctx context.Context) (StreamUsage, error)
+}
+
+// ReportUsage calls the report function with the usage of the stream broker at every interval,
+// until the context is done. Errors getting the usage are passed on to the report function.
+//
+// The report function is expected to forward the usage to the metrics system, e.g. as gauges.
+func ReportUsage(ctx context.Context, reporter UsageReporter, interval time.Duration, report func(StreamUsage, error)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		report(reporter.Usage(ctx))
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			// the ticker may fire along with the context being done.
+			if ctx.Err() != nil {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,262 @@
+package ginauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// APIKeyHeader is the header API keys are read from
+	APIKeyHeader = "X-API-Key"
+
+	// APIKeyAuthScheme is the Authorization header scheme API keys may be sent with, e.g. "ApiKey <key>"
+	APIKeyAuthScheme = "ApiKey"
+)
+
+var (
+	// ErrInvalidAPIKeyConfig is the error returned when API keys can't be loaded
+	ErrInvalidAPIKeyConfig = errors.New("invalid api key config")
+)
+
+// APIKey describes an API key accepted by the APIKeyMiddleware. Only the hash of
+// the key is kept, see HashAPIKey.
+type APIKey struct {
+	// ID identifies the key, it is used as the subject when none is set
+	ID string `json:"id" yaml:"id" mapstructure:"id"`
+	// Hash is the hex encoded SHA-256 hash of the key
+	Hash    string `json:"hash" yaml:"hash" mapstructure:"hash"`
+	Subject string `json:"subject,omitempty" yaml:"subject" mapstructure:"subject"`
+	// Scopes are the roles granted to requests made with the key
+	Scopes []string `json:"scopes,omitempty" yaml:"scopes" mapstructure:"scopes"`
+	// ExpiresAt is when the key stops being accepted, the key doesn't expire when unset
+	ExpiresAt time.Time `json:"expires_at,omitempty" yaml:"expires_at" mapstructure:"expires_at"`
+}
+
+// APIKeySource loads the API keys accepted by an APIKeyMiddleware
+type APIKeySource interface {
+	LoadAPIKeys(ctx context.Context) ([]APIKey, error)
+}
+
+// StaticAPIKeys is an APIKeySource for keys given in the configuration
+type StaticAPIKeys []APIKey
+
+// LoadAPIKeys returns the configured keys
+func (s StaticAPIKeys) LoadAPIKeys(_ context.Context) ([]APIKey, error) {
+	return s, nil
+}
+
+// FileAPIKeys is an APIKeySource reading a JSON list of keys from a file
+type FileAPIKeys string
+
+// LoadAPIKeys reads the keys from the file
+func (f FileAPIKeys) LoadAPIKeys(_ context.Context) ([]APIKey, error) {
+	b, err := os.ReadFile(string(f))
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []APIKey
+	if err := json.Unmarshal(b, &keys); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// KVAPIKeys is an APIKeySource reading keys from a NATS KV bucket, each
+// entry holds a JSON encoded APIKey.
+type KVAPIKeys struct {
+	KV nats.KeyValue
+}
+
+// LoadAPIKeys reads all the keys in the bucket
+func (k KVAPIKeys) LoadAPIKeys(ctx context.Context) ([]APIKey, error) {
+	names, err := k.KV.Keys(nats.Context(ctx))
+	if err != nil {
+		if errors.Is(err, nats.ErrNoKeysFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	keys := make([]APIKey, 0, len(names))
+
+	for _, name := range names {
+		entry, err := k.KV.Get(name)
+		if err != nil {
+			if errors.Is(err, nats.ErrKeyNotFound) {
+				continue
+			}
+
+			return nil, err
+		}
+
+		var key APIKey
+		if err := json.Unmarshal(entry.Value(), &key); err != nil {
+			return nil, fmt.Errorf("key %s: %w", name, err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+// HashAPIKey returns the hash of an API key as expected in APIKey.Hash
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// APIKeyMiddleware authenticates requests with API keys, for integrations which can't use OIDC.
+// It implements GenericAuthMiddleware so it can be stacked in a MultiTokenMiddleware.
+type APIKeyMiddleware struct {
+	source APIKeySource
+
+	mu   sync.RWMutex
+	keys []apiKeyEntry
+}
+
+type apiKeyEntry struct {
+	APIKey
+	hash []byte
+}
+
+// NewAPIKeyMiddleware returns an APIKeyMiddleware accepting the keys loaded from the source
+func NewAPIKeyMiddleware(ctx context.Context, source APIKeySource) (*APIKeyMiddleware, error) {
+	if source == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidAPIKeyConfig, "The key source can't be nil")
+	}
+
+	akm := &APIKeyMiddleware{source: source}
+
+	if err := akm.Reload(ctx); err != nil {
+		return nil, err
+	}
+
+	return akm, nil
+}
+
+// Reload loads the keys from the source again, replacing the accepted keys
+func (akm *APIKeyMiddleware) Reload(ctx context.Context) error {
+	keys, err := akm.source.LoadAPIKeys(ctx)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidAPIKeyConfig, err)
+	}
+
+	entries := make([]apiKeyEntry, 0, len(keys))
+
+	for _, k := range keys {
+		hash, err := hex.DecodeString(k.Hash)
+		if err != nil || len(hash) != sha256.Size {
+			return fmt.Errorf("%w: key %s has an invalid hash", ErrInvalidAPIKeyConfig, k.ID)
+		}
+
+		entries = append(entries, apiKeyEntry{APIKey: k, hash: hash})
+	}
+
+	akm.mu.Lock()
+	akm.keys = entries
+	akm.mu.Unlock()
+
+	return nil
+}
+
+// SetMetadata ensures metadata is set in the gin Context
+func (akm *APIKeyMiddleware) SetMetadata(c *gin.Context, cm ClaimMetadata) {
+	if cm.Subject != "" {
+		c.Set(contextKeySubject, cm.Subject)
+	}
+
+	if cm.User != "" {
+		c.Set(contextKeyUser, cm.User)
+	}
+
+	c.Set(contextKeyRoles, cm.Roles)
+
+	MarkAuthenticated(c)
+}
+
+// VerifyTokenWithScopes verifies the API key from the gin Context grants any of the given scopes
+func (akm *APIKeyMiddleware) VerifyTokenWithScopes(c *gin.Context, scopes []string) (ClaimMetadata, error) {
+	presented := apiKeyFromRequest(c)
+	if presented == "" {
+		return ClaimMetadata{}, NewAuthenticationError("missing api key")
+	}
+
+	sum := sha256.Sum256([]byte(presented))
+
+	var (
+		key   APIKey
+		found bool
+	)
+
+	akm.mu.RLock()
+
+	// compare against every key so the time taken doesn't tell which key matched
+	for _, k := range akm.keys {
+		if subtle.ConstantTimeCompare(sum[:], k.hash) == 1 {
+			key, found = k.APIKey, true
+		}
+	}
+
+	akm.mu.RUnlock()
+
+	if !found {
+		return ClaimMetadata{}, NewAuthenticationError("invalid api key")
+	}
+
+	if !key.ExpiresAt.IsZero() && time.Now().After(key.ExpiresAt) {
+		return ClaimMetadata{}, NewAuthenticationError("api key expired")
+	}
+
+	if !hasAnyRole(key.Scopes, scopes) {
+		return ClaimMetadata{}, NewAuthorizationError("not authorized, missing required scope")
+	}
+
+	subject := key.Subject
+	if subject == "" {
+		subject = key.ID
+	}
+
+	return ClaimMetadata{Subject: subject, User: subject, Roles: key.Scopes}, nil
+}
+
+// AuthRequired provides a middleware that ensures a request has authentication
+func (akm *APIKeyMiddleware) AuthRequired(scopes []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cm, err := akm.VerifyTokenWithScopes(c, scopes)
+		if err != nil {
+			AbortBecauseOfError(c, err)
+			return
+		}
+
+		akm.SetMetadata(c, cm)
+	}
+}
+
+func apiKeyFromRequest(c *gin.Context) string {
+	if key := c.GetHeader(APIKeyHeader); key != "" {
+		return key
+	}
+
+	scheme, key, found := strings.Cut(c.GetHeader("Authorization"), " ")
+	if found && strings.EqualFold(scheme, APIKeyAuthScheme) {
+		return key
+	}
+
+	return ""
+}
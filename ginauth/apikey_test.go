@@ -0,0 +1,179 @@
+package ginauth_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	srvtest "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.hollow.sh/toolbox/ginauth"
+)
+
+func TestAPIKeyMiddleware(t *testing.T) {
+	keys := ginauth.StaticAPIKeys{
+		{
+			ID:     "reader",
+			Hash:   ginauth.HashAPIKey("reader-secret"),
+			Scopes: []string{"read"},
+		},
+		{
+			ID:        "expired",
+			Hash:      ginauth.HashAPIKey("expired-secret"),
+			Subject:   "integration",
+			Scopes:    []string{"read"},
+			ExpiresAt: time.Now().Add(-time.Minute),
+		},
+	}
+
+	akm, err := ginauth.NewAPIKeyMiddleware(context.TODO(), keys)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name         string
+		header       string
+		value        string
+		scopes       []string
+		responseCode int
+	}{
+		{"valid key", ginauth.APIKeyHeader, "reader-secret", []string{"read"}, http.StatusOK},
+		{"valid key in authorization header", "Authorization", "ApiKey reader-secret", []string{"read"}, http.StatusOK},
+		{"missing key", "", "", []string{"read"}, http.StatusUnauthorized},
+		{"unknown key", ginauth.APIKeyHeader, "other-secret", []string{"read"}, http.StatusUnauthorized},
+		{"expired key", ginauth.APIKeyHeader, "expired-secret", []string{"read"}, http.StatusUnauthorized},
+		{"missing scope", ginauth.APIKeyHeader, "reader-secret", []string{"write"}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/", akm.AuthRequired(tt.scopes), func(c *gin.Context) {
+				c.JSON(http.StatusOK, c.GetString("jwt.subject"))
+			})
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "http://test/", nil)
+
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.responseCode, w.Code)
+
+			if tt.responseCode == http.StatusOK {
+				assert.Contains(t, w.Body.String(), "reader")
+			}
+		})
+	}
+}
+
+func TestAPIKeyMiddlewareInvalidHash(t *testing.T) {
+	_, err := ginauth.NewAPIKeyMiddleware(context.TODO(), ginauth.StaticAPIKeys{{ID: "bad", Hash: "not-a-hash"}})
+	assert.ErrorIs(t, err, ginauth.ErrInvalidAPIKeyConfig)
+
+	_, err = ginauth.NewAPIKeyMiddleware(context.TODO(), nil)
+	assert.ErrorIs(t, err, ginauth.ErrInvalidAPIKeyConfig)
+}
+
+func TestAPIKeyMiddlewareSources(t *testing.T) {
+	key := ginauth.APIKey{
+		ID:     "writer",
+		Hash:   ginauth.HashAPIKey("writer-secret"),
+		Scopes: []string{"write"},
+	}
+
+	body, err := json.Marshal([]ginauth.APIKey{key})
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "keys.json")
+	require.NoError(t, os.WriteFile(path, body, 0o600))
+
+	opts := srvtest.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+
+	srv := srvtest.RunServer(&opts)
+	defer srv.Shutdown()
+
+	nc, err := nats.Connect(srv.ClientURL())
+	require.NoError(t, err)
+
+	defer nc.Close()
+
+	js, err := nc.JetStream()
+	require.NoError(t, err)
+
+	kv, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: "apikeys"})
+	require.NoError(t, err)
+
+	kvSource := ginauth.KVAPIKeys{KV: kv}
+
+	// an empty bucket holds no keys
+	akm, err := ginauth.NewAPIKeyMiddleware(context.TODO(), kvSource)
+	require.NoError(t, err)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "http://test/", nil)
+	c.Request.Header.Set(ginauth.APIKeyHeader, "writer-secret")
+
+	_, err = akm.VerifyTokenWithScopes(c, []string{"write"})
+	assert.ErrorIs(t, err, ginauth.ErrAuthentication)
+
+	body, err = json.Marshal(key)
+	require.NoError(t, err)
+
+	_, err = kv.Put("writer", body)
+	require.NoError(t, err)
+
+	require.NoError(t, akm.Reload(context.TODO()))
+
+	for _, source := range []ginauth.APIKeySource{ginauth.FileAPIKeys(path), kvSource} {
+		akm, err := ginauth.NewAPIKeyMiddleware(context.TODO(), source)
+		require.NoError(t, err)
+
+		cm, err := akm.VerifyTokenWithScopes(c, []string{"write"})
+		require.NoError(t, err)
+		assert.Equal(t, ginauth.ClaimMetadata{Subject: "writer", User: "writer", Roles: []string{"write"}}, cm)
+	}
+}
+
+func TestAPIKeyMiddlewareInMultiTokenMiddleware(t *testing.T) {
+	akm, err := ginauth.NewAPIKeyMiddleware(context.TODO(), ginauth.StaticAPIKeys{
+		{ID: "reader", Hash: ginauth.HashAPIKey("reader-secret"), Scopes: []string{"read"}},
+	})
+	require.NoError(t, err)
+
+	mtm, err := ginauth.NewMultiTokenMiddleware()
+	require.NoError(t, err)
+	require.NoError(t, mtm.Add(&stubVerifier{}))
+	require.NoError(t, mtm.Add(akm))
+
+	r := gin.New()
+	r.GET("/", mtm.AuthRequired([]string{"read"}), func(c *gin.Context) {
+		c.JSON(http.StatusOK, "ok")
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://test/", nil)
+	req.Header.Set(ginauth.APIKeyHeader, "reader-secret")
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "http://test/", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
@@ -0,0 +1,120 @@
+package ginauth
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// ErrInvalidBypassRule is the error returned when a bypass rule is invalid
+var ErrInvalidBypassRule = errors.New("invalid auth bypass rule")
+
+// BypassRule describes requests which skip authentication, such as health checks
+type BypassRule struct {
+	// Path is the request path matched exactly, or as a prefix when Prefix is set
+	Path string
+	// Prefix matches the Path and any path below it, "/debug" matches "/debug/pprof" but not "/debugger"
+	Prefix bool
+	// Methods the rule applies to, any method when empty
+	Methods []string
+}
+
+// BypassPath returns a rule matching the exact path for the given methods, or any method when none are given
+func BypassPath(p string, methods ...string) BypassRule {
+	return BypassRule{Path: p, Methods: methods}
+}
+
+// BypassPrefix returns a rule matching the path and the paths below it for the given methods, or any method when none are given
+func BypassPrefix(prefix string, methods ...string) BypassRule {
+	return BypassRule{Path: prefix, Prefix: true, Methods: methods}
+}
+
+func (r BypassRule) matches(method, reqPath string) bool {
+	if len(r.Methods) > 0 {
+		found := false
+
+		for _, m := range r.Methods {
+			if strings.EqualFold(m, method) {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if !r.Prefix {
+		return reqPath == r.Path
+	}
+
+	prefix := strings.TrimSuffix(r.Path, "/")
+
+	return reqPath == prefix || strings.HasPrefix(reqPath, prefix+"/")
+}
+
+// BypassList holds the rules of requests skipping authentication. Every bypass is logged
+// at debug level, the rules are logged when the list is created.
+type BypassList struct {
+	rules  []BypassRule
+	logger *zap.Logger
+}
+
+// NewBypassList returns a BypassList with the given rules, a nil logger disables logging
+func NewBypassList(logger *zap.Logger, rules ...BypassRule) (*BypassList, error) {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	for _, r := range rules {
+		if !isCleanAbsPath(r.Path) {
+			return nil, fmt.Errorf("%w: path %q must be absolute and clean", ErrInvalidBypassRule, r.Path)
+		}
+
+		logger.Info("auth bypassed for requests",
+			zap.String("path", r.Path),
+			zap.Bool("prefix", r.Prefix),
+			zap.Strings("methods", r.Methods),
+		)
+	}
+
+	return &BypassList{rules: rules, logger: logger}, nil
+}
+
+// Bypass returns true when the request matches a rule and skips authentication
+func (b *BypassList) Bypass(c *gin.Context) bool {
+	if b == nil || len(b.rules) == 0 {
+		return false
+	}
+
+	// the path is cleaned so "/healthz/../admin" doesn't match a "/healthz" prefix
+	reqPath := path.Clean("/" + c.Request.URL.Path)
+
+	for _, r := range b.rules {
+		if r.matches(c.Request.Method, reqPath) {
+			b.logger.Debug("auth bypassed",
+				zap.String("method", c.Request.Method),
+				zap.String("path", c.Request.URL.Path),
+				zap.String("rule", r.Path),
+			)
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// isCleanAbsPath returns true for absolute paths without empty, "." or ".." segments, a trailing slash is allowed
+func isCleanAbsPath(p string) bool {
+	if !strings.HasPrefix(p, "/") {
+		return false
+	}
+
+	return p == "/" || path.Clean(p) == strings.TrimSuffix(p, "/")
+}
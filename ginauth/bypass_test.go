@@ -0,0 +1,81 @@
+package ginauth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.hollow.sh/toolbox/ginauth"
+)
+
+func TestMultiTokenMiddlewareBypassList(t *testing.T) {
+	core, logs := observer.New(zapcore.DebugLevel)
+
+	bypass, err := ginauth.NewBypassList(zap.New(core),
+		ginauth.BypassPath("/healthz"),
+		ginauth.BypassPrefix("/metrics", http.MethodGet),
+	)
+	require.NoError(t, err)
+
+	// the rules are logged
+	assert.Equal(t, 2, logs.Len())
+
+	mtm, err := ginauth.NewMultiTokenMiddleware()
+	require.NoError(t, err)
+	require.NoError(t, mtm.Add(&stubVerifier{}))
+	mtm.SetBypassList(bypass)
+
+	r := gin.New()
+	r.Use(mtm.AuthRequired([]string{"read"}))
+	r.Any("/*path", func(c *gin.Context) {
+		c.JSON(http.StatusOK, "ok")
+	})
+
+	tests := []struct {
+		method       string
+		path         string
+		responseCode int
+	}{
+		{http.MethodGet, "/healthz", http.StatusOK},
+		{http.MethodPost, "/healthz", http.StatusOK},
+		{http.MethodGet, "/healthz/more", http.StatusUnauthorized},
+		{http.MethodGet, "/metrics", http.StatusOK},
+		{http.MethodGet, "/metrics/process", http.StatusOK},
+		{http.MethodPost, "/metrics", http.StatusUnauthorized},
+		{http.MethodGet, "/metricsfoo", http.StatusUnauthorized},
+		{http.MethodGet, "/metrics/../admin", http.StatusUnauthorized},
+		{http.MethodGet, "/admin", http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.method+" "+tt.path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(tt.method, "http://test/", nil)
+			req.URL.Path = tt.path
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.responseCode, w.Code)
+		})
+	}
+
+	bypassed := logs.FilterMessage("auth bypassed").Len()
+	assert.Equal(t, 4, bypassed)
+}
+
+func TestNewBypassListInvalidPath(t *testing.T) {
+	for _, p := range []string{"healthz", "/a/../b", "//healthz", ""} {
+		_, err := ginauth.NewBypassList(nil, ginauth.BypassPath(p))
+		assert.ErrorIs(t, err, ginauth.ErrInvalidBypassRule, p)
+	}
+
+	_, err := ginauth.NewBypassList(nil, ginauth.BypassPrefix("/debug/"), ginauth.BypassPath("/"))
+	assert.NoError(t, err)
+}
@@ -0,0 +1,180 @@
+package ginauth
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DefaultCSRFCookieName is the CSRF cookie name used when none is configured
+	DefaultCSRFCookieName = "hollow_csrf"
+
+	// DefaultCSRFHeaderName is the header the CSRF token is read from when none is configured
+	DefaultCSRFHeaderName = "X-CSRF-Token"
+
+	contextKeyCSRFToken = "ginauth.csrf_token"
+
+	// csrfTokenLength is the number of random bytes of a CSRF token
+	csrfTokenLength = 32
+)
+
+var (
+	// ErrInvalidCSRFConfig is the error returned when the CSRF configuration is invalid
+	ErrInvalidCSRFConfig = errors.New("invalid CSRF config")
+
+	// ErrInvalidCSRFToken is the error returned when the CSRF token of a request is missing or
+	// doesn't match its CSRF cookie
+	ErrInvalidCSRFToken = errors.New("invalid CSRF token")
+)
+
+// CSRFConfig provides the configuration for the double-submit CSRF protection
+type CSRFConfig struct {
+	// CookieName is the name of the CSRF cookie. Defaults to DefaultCSRFCookieName.
+	CookieName string
+	// HeaderName is the header the CSRF token is sent back in. Defaults to DefaultCSRFHeaderName.
+	HeaderName string
+	Path       string
+	Domain     string
+	Secure     bool
+	// SameSite is the SameSite attribute of the CSRF cookie. Defaults to http.SameSiteStrictMode.
+	SameSite http.SameSite
+	// SessionCookieName is the name of the session cookie, requests with it are never exempt.
+	// Defaults to DefaultSessionCookieName.
+	SessionCookieName string
+}
+
+// CSRFMiddleware protects cookie based sessions, see SessionMiddleware, against cross-site request
+// forgery with the double-submit pattern: a random token is set in a cookie readable by the page
+// scripts, and requests with unsafe methods must send it back in the CSRF header. Other sites can
+// make the browser send the cookie but can't read it to set the header.
+//
+// Requests with a bearer token or an X-API-Key header and without a session cookie are exempt,
+// browsers don't attach these credentials on their own and other sites can't set them without a
+// CORS preflight. Other Authorization schemes, such as Basic credentials cached by the browser,
+// aren't exempt.
+type CSRFMiddleware struct {
+	config CSRFConfig
+}
+
+// NewCSRFMiddleware returns a CSRFMiddleware with the given configuration
+func NewCSRFMiddleware(cfg CSRFConfig) (*CSRFMiddleware, error) {
+	if cfg.CookieName == "" {
+		cfg.CookieName = DefaultCSRFCookieName
+	}
+
+	if cfg.HeaderName == "" {
+		cfg.HeaderName = DefaultCSRFHeaderName
+	}
+
+	if cfg.Path == "" {
+		cfg.Path = "/"
+	}
+
+	if cfg.SessionCookieName == "" {
+		cfg.SessionCookieName = DefaultSessionCookieName
+	}
+
+	if cfg.SameSite == 0 || cfg.SameSite == http.SameSiteDefaultMode {
+		cfg.SameSite = http.SameSiteStrictMode
+	}
+
+	if cfg.SameSite == http.SameSiteNoneMode && !cfg.Secure {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidCSRFConfig, "SameSite None cookies must be Secure")
+	}
+
+	return &CSRFMiddleware{config: cfg}, nil
+}
+
+// Protect provides a middleware which issues the CSRF cookie to requests without one and rejects
+// the requests with unsafe methods, e.g. POST or DELETE, whose CSRF header doesn't match their
+// cookie with a 403 and the CodeInvalidCSRFToken code. It is to be used before the auth middlewares.
+func (cm *CSRFMiddleware) Protect() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if cm.exempt(c) {
+			return
+		}
+
+		cookie, err := c.Cookie(cm.config.CookieName)
+		if err != nil || cookie == "" {
+			cookie, err = cm.IssueToken(c)
+			if err != nil {
+				AbortBecauseOfError(c, &AuthError{HTTPErrorCode: http.StatusInternalServerError, err: err})
+				return
+			}
+		}
+
+		c.Set(contextKeyCSRFToken, cookie)
+
+		if csrfSafeMethod(c.Request.Method) {
+			return
+		}
+
+		header := c.GetHeader(cm.config.HeaderName)
+		if header == "" || subtle.ConstantTimeCompare([]byte(header), []byte(cookie)) != 1 {
+			AbortBecauseOfError(c, NewAuthorizationErrorFrom(ErrInvalidCSRFToken))
+			return
+		}
+	}
+}
+
+// IssueToken sets a new CSRF cookie on the response and returns its token, e.g. to rotate it
+// once a session is issued
+func (cm *CSRFMiddleware) IssueToken(c *gin.Context) (string, error) {
+	raw := make([]byte, csrfTokenLength)
+	if _, err := io.ReadFull(rand.Reader, raw); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrInvalidCSRFToken, err)
+	}
+
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	// the cookie is read by the page scripts to send the token back in the header
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     cm.config.CookieName,
+		Value:    token,
+		Path:     cm.config.Path,
+		Domain:   cm.config.Domain,
+		Secure:   cm.config.Secure,
+		HttpOnly: false,
+		SameSite: cm.config.SameSite,
+	})
+
+	c.Set(contextKeyCSRFToken, token)
+
+	return token, nil
+}
+
+// CSRFToken returns the CSRF token of the request set by the CSRFMiddleware, e.g. to render it in
+// a form or a meta tag, empty when the request is exempt or wasn't handled by the middleware
+func CSRFToken(c *gin.Context) string {
+	return c.GetString(contextKeyCSRFToken)
+}
+
+// exempt returns true when the request carries credentials browsers don't send on their own, a
+// bearer token or an API key, and no session cookie which would authenticate it first
+func (cm *CSRFMiddleware) exempt(c *gin.Context) bool {
+	if _, err := c.Cookie(cm.config.SessionCookieName); err == nil {
+		return false
+	}
+
+	scheme, _, _ := strings.Cut(c.GetHeader("Authorization"), " ")
+
+	return strings.EqualFold(scheme, "bearer") || c.GetHeader(APIKeyHeader) != ""
+}
+
+// csrfSafeMethod returns true for the methods which must not change state
+func csrfSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	default:
+		return false
+	}
+}
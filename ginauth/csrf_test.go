@@ -0,0 +1,93 @@
+package ginauth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.hollow.sh/toolbox/ginauth"
+)
+
+func TestCSRFMiddleware(t *testing.T) {
+	_, err := ginauth.NewCSRFMiddleware(ginauth.CSRFConfig{SameSite: http.SameSiteNoneMode})
+	assert.ErrorIs(t, err, ginauth.ErrInvalidCSRFConfig)
+
+	cm, err := ginauth.NewCSRFMiddleware(ginauth.CSRFConfig{Secure: true})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.Use(cm.Protect())
+	r.GET("/form", func(c *gin.Context) {
+		c.String(http.StatusOK, ginauth.CSRFToken(c))
+	})
+	r.POST("/servers", func(c *gin.Context) {
+		c.Status(http.StatusCreated)
+	})
+
+	// safe requests are issued the cookie
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/form", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	cookies := w.Result().Cookies()
+	require.Len(t, cookies, 1)
+
+	cookie := cookies[0]
+	assert.Equal(t, ginauth.DefaultCSRFCookieName, cookie.Name)
+	assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+	assert.True(t, cookie.Secure)
+	assert.False(t, cookie.HttpOnly)
+	assert.Equal(t, cookie.Value, w.Body.String())
+
+	session := &http.Cookie{Name: ginauth.DefaultSessionCookieName, Value: "session"}
+
+	testCases := []struct {
+		name    string
+		headers map[string]string
+		cookie  bool
+		session bool
+		code    int
+	}{
+		{"matching token", map[string]string{ginauth.DefaultCSRFHeaderName: cookie.Value}, true, false, http.StatusCreated},
+		{"missing token", nil, true, false, http.StatusForbidden},
+		{"mismatched token", map[string]string{ginauth.DefaultCSRFHeaderName: "forged"}, true, false, http.StatusForbidden},
+		{"missing cookie", map[string]string{ginauth.DefaultCSRFHeaderName: cookie.Value}, false, false, http.StatusForbidden},
+		{"bearer token", map[string]string{"Authorization": "bearer token"}, true, false, http.StatusCreated},
+		{"api key", map[string]string{ginauth.APIKeyHeader: "key"}, false, false, http.StatusCreated},
+		{"basic credentials", map[string]string{"Authorization": "Basic dXNlcjpwYXNz"}, true, false, http.StatusForbidden},
+		{"session cookie with basic credentials", map[string]string{"Authorization": "Basic dXNlcjpwYXNz"}, true, true, http.StatusForbidden},
+		{"session cookie with bearer token", map[string]string{"Authorization": "bearer token"}, true, true, http.StatusForbidden},
+		{"session cookie with api key", map[string]string{ginauth.APIKeyHeader: "key"}, true, true, http.StatusForbidden},
+		{"session cookie with matching token", map[string]string{"Authorization": "Basic dXNlcjpwYXNz", ginauth.DefaultCSRFHeaderName: cookie.Value}, true, true, http.StatusCreated},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/servers", nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			if tt.cookie {
+				req.AddCookie(cookie)
+			}
+
+			if tt.session {
+				req.AddCookie(session)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.code, w.Code)
+
+			if tt.code == http.StatusForbidden {
+				assert.Contains(t, w.Body.String(), string(ginauth.CodeInvalidCSRFToken))
+			}
+		})
+	}
+}
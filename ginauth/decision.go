@@ -0,0 +1,312 @@
+package ginauth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+const (
+	// DecisionHeader is the header decision assertions are passed in when none is configured
+	DecisionHeader = "X-Hollow-Auth-Decision"
+
+	// DefaultDecisionTTL is how long a decision assertion is valid for when no TTL is configured
+	DefaultDecisionTTL = 30 * time.Second
+
+	contextKeyDecision = "ginauth.decision"
+
+	decisionIDLength = 16
+)
+
+var (
+	// ErrInvalidDecisionConfig is the error returned when the decision configuration is invalid
+	ErrInvalidDecisionConfig = errors.New("invalid decision config")
+
+	// ErrInvalidDecision is the error returned when a decision assertion can't be issued or verified
+	ErrInvalidDecision = errors.New("invalid auth decision")
+)
+
+// DecisionConfig provides the configuration for issuing decision assertions
+type DecisionConfig struct {
+	// SigningKey signs the assertions, it must be a private or symmetric key with a KeyID
+	// and an Algorithm. The verifiers are given the matching public key.
+	SigningKey jose.JSONWebKey
+	// Issuer identifies the service issuing the assertions, e.g. the edge gateway
+	Issuer string
+	// Audience identifies the services the assertions are issued for
+	Audience string
+	// Header is the request header the assertion is set in. Defaults to DecisionHeader.
+	Header string
+	// TTL is how long an assertion is valid for. Defaults to DefaultDecisionTTL.
+	TTL time.Duration
+}
+
+// decisionClaims are the claims of a decision assertion in addition to the registered ones
+type decisionClaims struct {
+	User  string   `json:"usr,omitempty"`
+	Roles []string `json:"roles,omitempty"`
+	// OriginalTokenID is the jti of the token verified at the edge
+	OriginalTokenID string `json:"orig_jti,omitempty"`
+}
+
+// DecisionMiddleware wraps a GenericAuthMiddleware so that, once a token was successfully
+// verified, a short-lived signed decision assertion holding the ClaimMetadata is set in a
+// request header. Requests forwarded to internal services carry the assertion, which a
+// DecisionVerifier accepts without verifying the original token again.
+type DecisionMiddleware struct {
+	verifier GenericAuthMiddleware
+	config   DecisionConfig
+	signer   jose.Signer
+}
+
+// NewDecisionMiddleware returns a DecisionMiddleware issuing assertions for tokens verified by the given middleware
+func NewDecisionMiddleware(verifier GenericAuthMiddleware, cfg DecisionConfig) (*DecisionMiddleware, error) {
+	if verifier == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMiddlewareReference, "The middleware reference can't be nil")
+	}
+
+	if cfg.SigningKey.Key == nil || cfg.SigningKey.IsPublic() {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDecisionConfig, "a private signing key is required")
+	}
+
+	if cfg.SigningKey.KeyID == "" || cfg.SigningKey.Algorithm == "" {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDecisionConfig, "the signing key requires a key ID and an algorithm")
+	}
+
+	if cfg.Issuer == "" || cfg.Audience == "" {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDecisionConfig, "an issuer and an audience are required")
+	}
+
+	if cfg.Header == "" {
+		cfg.Header = DecisionHeader
+	}
+
+	if cfg.TTL == 0 {
+		cfg.TTL = DefaultDecisionTTL
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.SignatureAlgorithm(cfg.SigningKey.Algorithm), Key: cfg.SigningKey},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDecisionConfig, err)
+	}
+
+	return &DecisionMiddleware{
+		verifier: verifier,
+		config:   cfg,
+		signer:   signer,
+	}, nil
+}
+
+// SetMetadata ensures metadata is set in the gin Context
+func (dm *DecisionMiddleware) SetMetadata(c *gin.Context, cm ClaimMetadata) {
+	dm.verifier.SetMetadata(c, cm)
+}
+
+// VerifyTokenWithScopes verifies the token from the gin Context with the wrapped middleware and
+// sets a decision assertion in the request on success. Any assertion sent by the client is
+// removed first, so only the ones issued here are forwarded.
+func (dm *DecisionMiddleware) VerifyTokenWithScopes(c *gin.Context, scopes []string) (ClaimMetadata, error) {
+	c.Request.Header.Del(dm.config.Header)
+
+	cm, err := dm.verifier.VerifyTokenWithScopes(c, scopes)
+	if err != nil {
+		return ClaimMetadata{}, err
+	}
+
+	if err := dm.IssueDecision(c, cm); err != nil {
+		return ClaimMetadata{}, err
+	}
+
+	return cm, nil
+}
+
+// AuthRequired provides a middleware that ensures a request has authentication
+func (dm *DecisionMiddleware) AuthRequired(scopes []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cm, err := dm.VerifyTokenWithScopes(c, scopes)
+		if err != nil {
+			AbortBecauseOfError(c, err)
+			return
+		}
+
+		dm.SetMetadata(c, cm)
+	}
+}
+
+// IssueDecision signs a decision assertion holding the given ClaimMetadata and sets it in the request
+// header, it is also kept in the gin Context for requests made to other services, see Decision.
+func (dm *DecisionMiddleware) IssueDecision(c *gin.Context, cm ClaimMetadata) error {
+	id := make([]byte, decisionIDLength)
+	if _, err := rand.Read(id); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidDecision, err)
+	}
+
+	now := time.Now()
+
+	assertion, err := jwt.Signed(dm.signer).
+		Claims(jwt.Claims{
+			ID:        hex.EncodeToString(id),
+			Issuer:    dm.config.Issuer,
+			Subject:   cm.Subject,
+			Audience:  jwt.Audience{dm.config.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			Expiry:    jwt.NewNumericDate(now.Add(dm.config.TTL)),
+		}).
+		Claims(decisionClaims{
+			User:            cm.User,
+			Roles:           cm.Roles,
+			OriginalTokenID: cm.TokenID,
+		}).
+		CompactSerialize()
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidDecision, err)
+	}
+
+	c.Request.Header.Set(dm.config.Header, assertion)
+	c.Set(contextKeyDecision, assertion)
+
+	return nil
+}
+
+// Decision returns the decision assertion issued for the request, to be set in the
+// DecisionHeader of requests made to other services. It is empty when none was issued.
+func Decision(c *gin.Context) string {
+	return c.GetString(contextKeyDecision)
+}
+
+// DecisionVerifierConfig provides the configuration for verifying decision assertions
+type DecisionVerifierConfig struct {
+	// KeySet holds the keys the assertions may be signed with, shared with the issuers
+	KeySet jose.JSONWebKeySet
+	// Issuer is the expected issuer of the assertions
+	Issuer string
+	// Audience is the expected audience of the assertions
+	Audience string
+	// Header is the request header the assertion is read from. Defaults to DecisionHeader.
+	Header string
+	// ClockSkew is the leeway allowed when validating the assertion time claims. Defaults to jwt.DefaultLeeway if unspecified.
+	ClockSkew time.Duration
+}
+
+// DecisionVerifier is a lightweight middleware for internal services, accepting the decision
+// assertions issued by a DecisionMiddleware in place of the original token. It implements
+// GenericAuthMiddleware so it can be stacked in a MultiTokenMiddleware.
+type DecisionVerifier struct {
+	config DecisionVerifierConfig
+}
+
+// NewDecisionVerifier returns a DecisionVerifier accepting the assertions signed with the keys of the configured key set
+func NewDecisionVerifier(cfg DecisionVerifierConfig) (*DecisionVerifier, error) {
+	if len(cfg.KeySet.Keys) == 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDecisionConfig, "a key set is required")
+	}
+
+	if cfg.Issuer == "" || cfg.Audience == "" {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDecisionConfig, "an issuer and an audience are required")
+	}
+
+	if cfg.Header == "" {
+		cfg.Header = DecisionHeader
+	}
+
+	if cfg.ClockSkew == 0 {
+		cfg.ClockSkew = jwt.DefaultLeeway
+	}
+
+	return &DecisionVerifier{config: cfg}, nil
+}
+
+// SetMetadata ensures metadata is set in the gin Context
+func (dv *DecisionVerifier) SetMetadata(c *gin.Context, cm ClaimMetadata) {
+	if cm.Subject != "" {
+		c.Set(contextKeySubject, cm.Subject)
+	}
+
+	if cm.User != "" {
+		c.Set(contextKeyUser, cm.User)
+	}
+
+	c.Set(contextKeyRoles, cm.Roles)
+
+	MarkAuthenticated(c)
+}
+
+// VerifyTokenWithScopes verifies the decision assertion from the gin Context grants any of the given scopes
+func (dv *DecisionVerifier) VerifyTokenWithScopes(c *gin.Context, scopes []string) (ClaimMetadata, error) {
+	raw := c.GetHeader(dv.config.Header)
+	if raw == "" {
+		return ClaimMetadata{}, NewAuthenticationError("missing auth decision")
+	}
+
+	tok, err := jwt.ParseSigned(raw)
+	if err != nil {
+		return ClaimMetadata{}, NewAuthenticationErrorFrom(ErrInvalidDecision)
+	}
+
+	hdr := tok.Headers[0]
+
+	keys := dv.config.KeySet.Key(hdr.KeyID)
+	if hdr.KeyID == "" || len(keys) == 0 {
+		return ClaimMetadata{}, NewInvalidSigningKeyError()
+	}
+
+	key := keys[0]
+
+	// the algorithm is taken from the key rather than trusted from the assertion
+	if key.Algorithm != "" && key.Algorithm != hdr.Algorithm {
+		return ClaimMetadata{}, NewInvalidSigningKeyError()
+	}
+
+	var (
+		cl jwt.Claims
+		dc decisionClaims
+	)
+
+	// symmetric keys have no public part
+	verifyKey := key.Key
+	if pub := key.Public(); pub.Key != nil {
+		verifyKey = pub.Key
+	}
+
+	if err := tok.Claims(verifyKey, &cl, &dc); err != nil {
+		return ClaimMetadata{}, NewAuthenticationErrorFrom(ErrInvalidDecision)
+	}
+
+	err = cl.ValidateWithLeeway(jwt.Expected{
+		Issuer:   dv.config.Issuer,
+		Audience: jwt.Audience{dv.config.Audience},
+		Time:     time.Now(),
+	}, dv.config.ClockSkew)
+	if err != nil {
+		return ClaimMetadata{}, NewTokenValidationError(fmt.Errorf("%w: %s", ErrInvalidDecision, err))
+	}
+
+	if !hasAnyRole(dc.Roles, scopes) {
+		return ClaimMetadata{}, NewAuthorizationError("not authorized, missing required scope")
+	}
+
+	return ClaimMetadata{Subject: cl.Subject, User: dc.User, Roles: dc.Roles, TokenID: dc.OriginalTokenID}, nil
+}
+
+// AuthRequired provides a middleware that ensures a request has authentication
+func (dv *DecisionVerifier) AuthRequired(scopes []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cm, err := dv.VerifyTokenWithScopes(c, scopes)
+		if err != nil {
+			AbortBecauseOfError(c, err)
+			return
+		}
+
+		dv.SetMetadata(c, cm)
+	}
+}
@@ -0,0 +1,178 @@
+package ginauth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+
+	"go.hollow.sh/toolbox/ginauth"
+)
+
+func decisionTestKey(t *testing.T, kid string) jose.JSONWebKey {
+	t.Helper()
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	return jose.JSONWebKey{Key: priv, KeyID: kid, Algorithm: string(jose.RS256), Use: "sig"}
+}
+
+// issueDecision runs the request through a DecisionMiddleware and returns the assertion it forwards
+func issueDecision(t *testing.T, dm *ginauth.DecisionMiddleware, spoofed string) (int, string) {
+	t.Helper()
+
+	var forwarded string
+
+	r := gin.New()
+	r.GET("/", dm.AuthRequired([]string{"read"}), func(c *gin.Context) {
+		forwarded = c.Request.Header.Get(ginauth.DecisionHeader)
+		assert.Equal(t, forwarded, ginauth.Decision(c))
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://test/", nil)
+	req.Header.Set("Authorization", "bearer good")
+
+	if spoofed != "" {
+		req.Header.Set("Authorization", "bearer bad")
+		req.Header.Set(ginauth.DecisionHeader, spoofed)
+	}
+
+	r.ServeHTTP(w, req)
+
+	return w.Code, forwarded
+}
+
+func TestDecisionMiddleware(t *testing.T) {
+	signingKey := decisionTestKey(t, "edge-1")
+	otherKey := decisionTestKey(t, "edge-1")
+
+	sv := &stubVerifier{cm: ginauth.ClaimMetadata{Subject: "foo", User: "foo@example.com", Roles: []string{"read"}, TokenID: "original-jti"}}
+
+	cfg := ginauth.DecisionConfig{
+		SigningKey: signingKey,
+		Issuer:     "edge",
+		Audience:   "internal",
+	}
+
+	dm, err := ginauth.NewDecisionMiddleware(sv, cfg)
+	require.NoError(t, err)
+
+	code, assertion := issueDecision(t, dm, "")
+	require.Equal(t, http.StatusOK, code)
+	require.NotEmpty(t, assertion)
+
+	otherCfg := cfg
+	otherCfg.SigningKey = otherKey
+
+	otherDM, err := ginauth.NewDecisionMiddleware(sv, otherCfg)
+	require.NoError(t, err)
+
+	_, forged := issueDecision(t, otherDM, "")
+
+	expiredCfg := cfg
+	expiredCfg.TTL = -time.Hour
+
+	expiredDM, err := ginauth.NewDecisionMiddleware(sv, expiredCfg)
+	require.NoError(t, err)
+
+	_, expired := issueDecision(t, expiredDM, "")
+
+	// assertions sent by clients aren't forwarded
+	code, spoofed := issueDecision(t, dm, assertion)
+	assert.Equal(t, http.StatusUnauthorized, code)
+	assert.Empty(t, spoofed)
+
+	dv, err := ginauth.NewDecisionVerifier(ginauth.DecisionVerifierConfig{
+		KeySet:   jose.JSONWebKeySet{Keys: []jose.JSONWebKey{signingKey.Public()}},
+		Issuer:   "edge",
+		Audience: "internal",
+	})
+	require.NoError(t, err)
+
+	wrongAudience, err := ginauth.NewDecisionVerifier(ginauth.DecisionVerifierConfig{
+		KeySet:   jose.JSONWebKeySet{Keys: []jose.JSONWebKey{signingKey.Public()}},
+		Issuer:   "edge",
+		Audience: "other",
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name      string
+		verifier  *ginauth.DecisionVerifier
+		assertion string
+		scopes    []string
+		wantCode  int
+	}{
+		{"valid", dv, assertion, []string{"read"}, http.StatusOK},
+		{"missing scope", dv, assertion, []string{"write"}, http.StatusForbidden},
+		{"missing assertion", dv, "", []string{"read"}, http.StatusUnauthorized},
+		{"malformed assertion", dv, "not-a-jwt", []string{"read"}, http.StatusUnauthorized},
+		{"signed with another key", dv, forged, []string{"read"}, http.StatusUnauthorized},
+		{"expired", dv, expired, []string{"read"}, http.StatusUnauthorized},
+		{"wrong audience", wrongAudience, assertion, []string{"read"}, http.StatusUnauthorized},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var cm ginauth.ClaimMetadata
+
+			r := gin.New()
+			r.GET("/", func(c *gin.Context) {
+				var err error
+
+				cm, err = tt.verifier.VerifyTokenWithScopes(c, tt.scopes)
+				if err != nil {
+					ginauth.AbortBecauseOfError(c, err)
+					return
+				}
+
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "http://test/", nil)
+
+			if tt.assertion != "" {
+				req.Header.Set(ginauth.DecisionHeader, tt.assertion)
+			}
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+
+			if tt.wantCode == http.StatusOK {
+				assert.Equal(t, sv.cm, cm)
+			}
+		})
+	}
+}
+
+func TestNewDecisionMiddlewareConfig(t *testing.T) {
+	key := decisionTestKey(t, "edge-1")
+
+	testCases := []struct {
+		name string
+		cfg  ginauth.DecisionConfig
+	}{
+		{"public key", ginauth.DecisionConfig{SigningKey: key.Public(), Issuer: "edge", Audience: "internal"}},
+		{"missing key id", ginauth.DecisionConfig{SigningKey: jose.JSONWebKey{Key: key.Key, Algorithm: key.Algorithm}, Issuer: "edge", Audience: "internal"}},
+		{"missing audience", ginauth.DecisionConfig{SigningKey: key, Issuer: "edge"}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ginauth.NewDecisionMiddleware(&stubVerifier{}, tt.cfg)
+			assert.ErrorIs(t, err, ginauth.ErrInvalidDecisionConfig)
+		})
+	}
+}
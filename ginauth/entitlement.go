@@ -0,0 +1,103 @@
+package ginauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// ErrEntitlementCheck is the error returned when an entitlement couldn't be checked
+	ErrEntitlementCheck = errors.New("entitlement check failed")
+
+	// ErrInvalidEntitlementChecker is the error returned when the entitlement checker is invalid
+	ErrInvalidEntitlementChecker = errors.New("invalid entitlement checker")
+)
+
+// EntitlementRequest holds what an EntitlementChecker is given to decide on an entitlement
+type EntitlementRequest struct {
+	// Name is the entitlement being checked, e.g. "servers.create"
+	Name    string
+	Subject string
+	User    string
+	Roles   []string
+	// Method and Path are those of the request being authorized
+	Method string
+	Path   string
+	// Resource holds hints about the resource being accessed, by default the route parameters
+	Resource map[string]string
+}
+
+// EntitlementDecision holds the result of an entitlement check
+type EntitlementDecision struct {
+	Allowed bool
+	Message string
+}
+
+// EntitlementChecker decides whether a requestor holds an entitlement, such as
+// a quota on the number of resources it may create. It goes beyond scopes which
+// only tell whether a kind of operation is permitted.
+type EntitlementChecker interface {
+	CheckEntitlement(context.Context, EntitlementRequest) (EntitlementDecision, error)
+}
+
+// EntitlementMiddleware checks entitlements for requests that were already authenticated.
+// The subject, user and roles are taken from the metadata set in the gin Context by the
+// auth middleware, so its handler needs to come after the AuthRequired one.
+type EntitlementMiddleware struct {
+	checker EntitlementChecker
+}
+
+// NewEntitlementMiddleware returns an EntitlementMiddleware using the given checker
+func NewEntitlementMiddleware(checker EntitlementChecker) (*EntitlementMiddleware, error) {
+	if checker == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidEntitlementChecker, "The checker reference can't be nil")
+	}
+
+	return &EntitlementMiddleware{checker: checker}, nil
+}
+
+// RequireEntitlement provides a middleware that ensures the requestor holds the named entitlement
+func (em *EntitlementMiddleware) RequireEntitlement(name string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		decision, err := em.checker.CheckEntitlement(c.Request.Context(), NewEntitlementRequest(c, name))
+		if err != nil {
+			AbortBecauseOfError(c, &AuthError{HTTPErrorCode: http.StatusServiceUnavailable, err: fmt.Errorf("%w: %s", ErrEntitlementCheck, err)})
+			return
+		}
+
+		if !decision.Allowed {
+			msg := decision.Message
+			if msg == "" {
+				msg = fmt.Sprintf("missing entitlement %s", name)
+			}
+
+			AbortBecauseOfError(c, NewAuthorizationError(msg))
+		}
+	}
+}
+
+// NewEntitlementRequest builds an EntitlementRequest for the named entitlement from the gin Context
+func NewEntitlementRequest(c *gin.Context, name string) EntitlementRequest {
+	req := EntitlementRequest{
+		Name:    name,
+		Subject: c.GetString(contextKeySubject),
+		User:    c.GetString(contextKeyUser),
+		Roles:   c.GetStringSlice(contextKeyRoles),
+		Method:  c.Request.Method,
+		Path:    c.FullPath(),
+	}
+
+	if len(c.Params) > 0 {
+		req.Resource = make(map[string]string, len(c.Params))
+
+		for _, p := range c.Params {
+			req.Resource[p.Key] = p.Value
+		}
+	}
+
+	return req
+}
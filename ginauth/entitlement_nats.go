@@ -0,0 +1,76 @@
+package ginauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+// NatsEntitlementChecker is an EntitlementChecker which sends an EntitlementRequestV1
+// as a NATS request and expects an EntitlementResponseV1 as reply.
+type NatsEntitlementChecker struct {
+	conn    *nats.Conn
+	subject string
+	timeout time.Duration
+}
+
+// NewNatsEntitlementChecker returns a NatsEntitlementChecker sending requests on the given subject
+func NewNatsEntitlementChecker(conn *nats.Conn, subject string, timeout time.Duration) (*NatsEntitlementChecker, error) {
+	if conn == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidEntitlementChecker, "The NATS connection can't be nil")
+	}
+
+	if subject == "" {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidEntitlementChecker, "The request subject can't be empty")
+	}
+
+	return &NatsEntitlementChecker{
+		conn:    conn,
+		subject: subject,
+		timeout: timeout,
+	}, nil
+}
+
+// CheckEntitlement requests an entitlement decision over NATS
+func (nc *NatsEntitlementChecker) CheckEntitlement(ctx context.Context, req EntitlementRequest) (EntitlementDecision, error) {
+	body, err := json.Marshal(&EntitlementRequestV1{
+		AuthMeta: AuthMeta{
+			Version: AuthRequestVersion1,
+		},
+		Entitlement: req.Name,
+		Subject:     req.Subject,
+		User:        req.User,
+		Roles:       req.Roles,
+		Method:      req.Method,
+		Path:        req.Path,
+		Resource:    req.Resource,
+	})
+	if err != nil {
+		return EntitlementDecision{}, err
+	}
+
+	if nc.timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, nc.timeout)
+		defer cancel()
+	}
+
+	msg, err := nc.conn.RequestWithContext(ctx, nc.subject, body)
+	if err != nil {
+		return EntitlementDecision{}, err
+	}
+
+	resp := EntitlementResponseV1{}
+	if err := json.Unmarshal(msg.Data, &resp); err != nil {
+		return EntitlementDecision{}, err
+	}
+
+	return EntitlementDecision{
+		Allowed: resp.Allowed,
+		Message: resp.Message,
+	}, nil
+}
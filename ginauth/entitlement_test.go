@@ -0,0 +1,174 @@
+package ginauth_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	srvtest "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.hollow.sh/toolbox/ginauth"
+)
+
+type stubEntitlementChecker struct {
+	last     ginauth.EntitlementRequest
+	decision ginauth.EntitlementDecision
+	err      error
+}
+
+func (sc *stubEntitlementChecker) CheckEntitlement(_ context.Context, req ginauth.EntitlementRequest) (ginauth.EntitlementDecision, error) {
+	sc.last = req
+	return sc.decision, sc.err
+}
+
+func newEntitlementTestRouter(t *testing.T, checker ginauth.EntitlementChecker) *gin.Engine {
+	t.Helper()
+
+	em, err := ginauth.NewEntitlementMiddleware(checker)
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.POST("/tenants/:tenant/servers",
+		func(c *gin.Context) {
+			c.Set("jwt.subject", "foo")
+			c.Set("jwt.roles", []string{"create:servers"})
+		},
+		em.RequireEntitlement("servers.create"),
+		func(c *gin.Context) {
+			c.JSON(http.StatusOK, "ok")
+		},
+	)
+
+	return r
+}
+
+func TestRequireEntitlement(t *testing.T) {
+	tests := []struct {
+		name         string
+		decision     ginauth.EntitlementDecision
+		err          error
+		responseCode int
+		message      string
+	}{
+		{
+			"allowed",
+			ginauth.EntitlementDecision{Allowed: true},
+			nil,
+			http.StatusOK,
+			"ok",
+		},
+		{
+			"denied",
+			ginauth.EntitlementDecision{Message: "server quota exceeded"},
+			nil,
+			http.StatusForbidden,
+			"server quota exceeded",
+		},
+		{
+			"denied without message",
+			ginauth.EntitlementDecision{},
+			nil,
+			http.StatusForbidden,
+			"missing entitlement servers.create",
+		},
+		{
+			"checker failure",
+			ginauth.EntitlementDecision{Allowed: true},
+			errors.New("boom"),
+			http.StatusServiceUnavailable,
+			"entitlement check failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checker := &stubEntitlementChecker{decision: tt.decision, err: tt.err}
+			r := newEntitlementTestRouter(t, checker)
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("POST", "http://test/tenants/acme/servers", nil)
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.responseCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.message)
+
+			assert.Equal(t, ginauth.EntitlementRequest{
+				Name:     "servers.create",
+				Subject:  "foo",
+				Roles:    []string{"create:servers"},
+				Method:   "POST",
+				Path:     "/tenants/:tenant/servers",
+				Resource: map[string]string{"tenant": "acme"},
+			}, checker.last)
+		})
+	}
+}
+
+func TestNewEntitlementMiddlewareNilChecker(t *testing.T) {
+	_, err := ginauth.NewEntitlementMiddleware(nil)
+	assert.ErrorIs(t, err, ginauth.ErrInvalidEntitlementChecker)
+}
+
+func TestNatsEntitlementChecker(t *testing.T) {
+	opts := srvtest.DefaultTestOptions
+	opts.Port = -1
+
+	srv := srvtest.RunServer(&opts)
+	defer srv.Shutdown()
+
+	nc, err := nats.Connect(srv.ClientURL())
+	require.NoError(t, err)
+
+	defer nc.Close()
+
+	// the responder allows a single server per tenant.
+	_, err = nc.Subscribe("entitlements", func(msg *nats.Msg) {
+		req := ginauth.EntitlementRequestV1{}
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
+			return
+		}
+
+		resp := ginauth.EntitlementResponseV1{
+			AuthMeta: ginauth.AuthMeta{Version: ginauth.AuthRequestVersion1},
+			Allowed:  req.Entitlement == "servers.create" && req.Resource["tenant"] == "acme",
+		}
+
+		if !resp.Allowed {
+			resp.Message = "server quota exceeded"
+		}
+
+		body, _ := json.Marshal(resp)
+		_ = msg.Respond(body)
+	})
+	require.NoError(t, err)
+
+	checker, err := ginauth.NewNatsEntitlementChecker(nc, "entitlements", time.Second)
+	require.NoError(t, err)
+
+	r := newEntitlementTestRouter(t, checker)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "http://test/tenants/acme/servers", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "http://test/tenants/other/servers", nil))
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), "server quota exceeded")
+
+	// nobody answers on this subject.
+	checker, err = ginauth.NewNatsEntitlementChecker(nc, "nobody", 100*time.Millisecond)
+	require.NoError(t, err)
+
+	w = httptest.NewRecorder()
+	newEntitlementTestRouter(t, checker).ServeHTTP(w, httptest.NewRequest("POST", "http://test/tenants/acme/servers", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+}
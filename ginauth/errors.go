@@ -61,6 +61,17 @@ func NewAuthorizationError(msg string) *AuthError {
 	}
 }
 
+// NewAuthorizationErrorFrom returns an authorization error which is due to
+// not being able to determine what the requestor can do (e.g. authorization error).
+// The error is based on another one (it wraps it).
+func NewAuthorizationErrorFrom(err error) *AuthError {
+	return &AuthError{
+		HTTPErrorCode: http.StatusForbidden,
+		// nolint:goerr113
+		err: err,
+	}
+}
+
 // Error ensures AuthenticationError implements the error interface
 func (ae *AuthError) Error() string {
 	return ae.err.Error()
@@ -1,6 +1,9 @@
 package ginauth
 
 import (
+	"encoding/json"
+	"time"
+
 	"github.com/gin-gonic/gin"
 )
 
@@ -10,6 +13,16 @@ type ClaimMetadata struct {
 	Subject string
 	User    string
 	Roles   []string
+	// TokenID is the ID (jti) of the verified token, when it has one
+	TokenID string
+	// Tenant is the tenant or organization the token was issued for, when the middleware reads one
+	Tenant string
+	// ExpiresAt is when the verified token expires, zero when it has no expiry
+	ExpiresAt time.Time
+	// Claims are all the claims of the verified token as raw JSON, when the middleware reads them.
+	// They may be shared by the requests with the same token and must not be modified. They aren't
+	// kept in the session cookies of the SessionMiddleware.
+	Claims map[string]json.RawMessage `json:"-"`
 }
 
 // GenericAuthMiddleware defines middleware that verifies a token coming from a gin.Context.
@@ -18,3 +31,15 @@ type GenericAuthMiddleware interface {
 	VerifyTokenWithScopes(*gin.Context, []string) (ClaimMetadata, error)
 	SetMetadata(*gin.Context, ClaimMetadata)
 }
+
+// RoleVerifier is implemented by middleware checking the roles of a token against the required
+// scopes with their own strategy, e.g. requiring all the scopes rather than any of them.
+type RoleVerifier interface {
+	VerifyRoles(roles, scopes []string) error
+}
+
+// RevocationChecker is implemented by middleware able to tell whether a token, by its ID, was revoked
+// since it was verified, e.g. to end the sessions issued for it.
+type RevocationChecker interface {
+	CheckRevoked(c *gin.Context, tokenID string) error
+}
@@ -0,0 +1,63 @@
+package ginauth
+
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+)
+
+// RequestIDHeader is the header of the request ID logged by AuthFailureLogger
+const RequestIDHeader = "X-Request-Id"
+
+// AuthFailure is the Meta of the gin errors recorded for the requests the auth middlewares rejected
+type AuthFailure struct {
+	// Status is the HTTP status of the response
+	Status int
+	// Code is the ErrorCodeOf the error
+	Code ErrorCode
+}
+
+// AuthErrors returns the gin errors recorded for the request by the auth middlewares
+func AuthErrors(c *gin.Context) []*gin.Error {
+	var errs []*gin.Error
+
+	for _, e := range c.Errors {
+		if _, ok := e.Meta.(AuthFailure); ok {
+			errs = append(errs, e)
+		}
+	}
+
+	return errs
+}
+
+// AuthFailureLogger returns a middleware logging a structured record at warn level for each auth
+// failure of the request, with the request metadata. It is to be used before the auth middlewares.
+func AuthFailureLogger(logger *zap.Logger) gin.HandlerFunc {
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	return func(c *gin.Context) {
+		start := time.Now()
+
+		c.Next()
+
+		for _, e := range AuthErrors(c) {
+			failure := e.Meta.(AuthFailure) //nolint:forcetypeassert // checked by AuthErrors
+
+			logger.Warn("request rejected by auth middleware",
+				zap.String("method", c.Request.Method),
+				zap.String("path", c.Request.URL.Path),
+				zap.String("route", c.FullPath()),
+				zap.String("client_ip", c.ClientIP()),
+				zap.String("user_agent", c.Request.UserAgent()),
+				zap.String("request_id", c.GetHeader(RequestIDHeader)),
+				zap.Int("status", failure.Status),
+				zap.String("code", string(failure.Code)),
+				zap.String("reason", e.Error()),
+				zap.Duration("latency", time.Since(start)),
+			)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package ginauth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.hollow.sh/toolbox/ginauth"
+)
+
+func TestAuthFailureLogger(t *testing.T) {
+	core, logs := observer.New(zap.WarnLevel)
+
+	var recorded []*gin.Error
+
+	r := gin.New()
+	r.Use(ginauth.AuthFailureLogger(zap.New(core)))
+	r.Use(func(c *gin.Context) {
+		c.Next()
+		recorded = ginauth.AuthErrors(c)
+	})
+
+	r.GET("/things/:id", func(c *gin.Context) {
+		ginauth.AbortBecauseOfError(c, ginauth.NewAuthorizationError("missing scope read"))
+	})
+	r.GET("/ok", func(c *gin.Context) {
+		_ = c.Error(assert.AnError)
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/things/1", nil)
+	req.Header.Set(ginauth.RequestIDHeader, "req-1")
+	req.Header.Set("User-Agent", "test-agent")
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	require.Len(t, recorded, 1)
+	assert.Equal(t, ginauth.AuthFailure{Status: http.StatusForbidden, Code: ginauth.CodeForbidden}, recorded[0].Meta)
+	assert.ErrorContains(t, recorded[0], "missing scope read")
+
+	require.Equal(t, 1, logs.Len())
+
+	fields := logs.All()[0].ContextMap()
+	assert.Equal(t, "GET", fields["method"])
+	assert.Equal(t, "/things/1", fields["path"])
+	assert.Equal(t, "/things/:id", fields["route"])
+	assert.Equal(t, "req-1", fields["request_id"])
+	assert.Equal(t, "test-agent", fields["user_agent"])
+	assert.Equal(t, int64(http.StatusForbidden), fields["status"])
+	assert.Equal(t, string(ginauth.CodeForbidden), fields["code"])
+	assert.Equal(t, "missing scope read", fields["reason"])
+
+	// errors not recorded by the auth middlewares aren't logged
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ok", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, recorded)
+	assert.Equal(t, 1, logs.Len())
+}
@@ -0,0 +1,225 @@
+package ginauth
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/gin-gonic/gin"
+)
+
+const contextKeyMessageCatalog = "ginauth.message_catalog"
+
+// ErrInvalidMessageCatalog is the error returned when the templates of a TemplateCatalog are invalid
+var ErrInvalidMessageCatalog = errors.New("invalid message catalog")
+
+// ErrorCode is the machine readable code of an auth error, returned in the "code" field of the
+// error responses. Codes are stable, the human readable messages may be customized with a MessageCatalog.
+type ErrorCode string
+
+const (
+	// CodeUnauthenticated is the code of the requests whose requestor couldn't be authenticated
+	CodeUnauthenticated ErrorCode = "unauthenticated"
+	// CodeInvalidToken is the code of the requests with a token failing validation, e.g. expired
+	CodeInvalidToken ErrorCode = "invalid_token"
+	// CodeInvalidSigningKey is the code of the requests with a token signed by an unknown key
+	CodeInvalidSigningKey ErrorCode = "invalid_signing_key"
+	// CodeForbidden is the code of the requests whose requestor isn't authorized
+	CodeForbidden ErrorCode = "forbidden"
+	// CodeUnavailable is the code of the requests which couldn't be authorized because a
+	// dependency, e.g. a remote authorization endpoint, is unavailable
+	CodeUnavailable ErrorCode = "unavailable"
+	// CodeMisconfigured is the code of the requests which couldn't be authorized because the auth
+	// middlewares are misconfigured, e.g. registered in the wrong order
+	CodeMisconfigured ErrorCode = "misconfigured"
+	// CodeInvalidCSRFToken is the code of the requests rejected by the CSRFMiddleware
+	CodeInvalidCSRFToken ErrorCode = "invalid_csrf_token"
+)
+
+// MessageCatalog returns the human readable message of auth errors, e.g. branded or localized.
+type MessageCatalog interface {
+	// Message returns the message of the error, ok is false to keep the default message
+	Message(c *gin.Context, code ErrorCode, err error) (msg string, ok bool)
+}
+
+// MessageCatalogFunc is a function implementing MessageCatalog
+type MessageCatalogFunc func(c *gin.Context, code ErrorCode, err error) (string, bool)
+
+// Message calls the function
+func (f MessageCatalogFunc) Message(c *gin.Context, code ErrorCode, err error) (string, bool) {
+	return f(c, code, err)
+}
+
+// ErrorCodeOf returns the ErrorCode of an error returned by the middlewares
+func ErrorCodeOf(err error) ErrorCode {
+	var validationErr *TokenValidationError
+	if errors.As(err, &validationErr) {
+		return CodeInvalidToken
+	}
+
+	var authErr *AuthError
+	if !errors.As(err, &authErr) {
+		return CodeUnauthenticated
+	}
+
+	switch {
+	case errors.Is(authErr.err, ErrInvalidSigningKey):
+		return CodeInvalidSigningKey
+	case errors.Is(authErr.err, ErrMiddlewareOrder):
+		return CodeMisconfigured
+	case errors.Is(authErr.err, ErrInvalidCSRFToken):
+		return CodeInvalidCSRFToken
+	case authErr.HTTPErrorCode == http.StatusForbidden:
+		return CodeForbidden
+	case authErr.HTTPErrorCode >= http.StatusInternalServerError:
+		return CodeUnavailable
+	default:
+		return CodeUnauthenticated
+	}
+}
+
+// UseMessageCatalog returns a middleware setting the catalog used by AbortBecauseOfError for the
+// requests it handles, it is to be used before the auth middlewares.
+func UseMessageCatalog(catalog MessageCatalog) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(contextKeyMessageCatalog, catalog)
+	}
+}
+
+// contextMessageCatalog returns the catalog set by UseMessageCatalog, nil when none is set
+func contextMessageCatalog(c *gin.Context) MessageCatalog {
+	if v, ok := c.Get(contextKeyMessageCatalog); ok {
+		if catalog, ok := v.(MessageCatalog); ok {
+			return catalog
+		}
+	}
+
+	return nil
+}
+
+// TemplateData is what the templates of a TemplateCatalog are executed with
+type TemplateData struct {
+	Code   ErrorCode
+	Status int
+	// Error is the default message of the error
+	Error string
+}
+
+// TemplateCatalog is a MessageCatalog of text templates by language and error code, the language
+// is negotiated with the Accept-Language header of the request
+type TemplateCatalog struct {
+	templates       map[string]map[ErrorCode]*template.Template
+	defaultLanguage string
+}
+
+// NewTemplateCatalog parses the templates, keyed by language tag, e.g. "en" or "fr-CA", and error code.
+// The templates of the default language are used for requests accepting none of the languages,
+// errors without a template keep their default message.
+func NewTemplateCatalog(templates map[string]map[ErrorCode]string, defaultLanguage string) (*TemplateCatalog, error) {
+	tc := &TemplateCatalog{
+		templates:       make(map[string]map[ErrorCode]*template.Template, len(templates)),
+		defaultLanguage: strings.ToLower(defaultLanguage),
+	}
+
+	for lang, codes := range templates {
+		lang = strings.ToLower(lang)
+		tc.templates[lang] = make(map[ErrorCode]*template.Template, len(codes))
+
+		for code, text := range codes {
+			tmpl, err := template.New(lang + "/" + string(code)).Parse(text)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %s", ErrInvalidMessageCatalog, err)
+			}
+
+			tc.templates[lang][code] = tmpl
+		}
+	}
+
+	if _, ok := tc.templates[tc.defaultLanguage]; defaultLanguage != "" && !ok {
+		return nil, fmt.Errorf("%w: no templates for the default language %s", ErrInvalidMessageCatalog, defaultLanguage)
+	}
+
+	return tc, nil
+}
+
+// Message executes the template of the code in the language negotiated for the request
+func (tc *TemplateCatalog) Message(c *gin.Context, code ErrorCode, err error) (string, bool) {
+	tmpl := tc.template(c.GetHeader("Accept-Language"), code)
+	if tmpl == nil {
+		return "", false
+	}
+
+	status := http.StatusUnauthorized
+
+	var authErr *AuthError
+	if errors.As(err, &authErr) {
+		status = authErr.HTTPErrorCode
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, TemplateData{Code: code, Status: status, Error: err.Error()}); err != nil {
+		return "", false
+	}
+
+	return buf.String(), true
+}
+
+func (tc *TemplateCatalog) template(acceptLanguage string, code ErrorCode) *template.Template {
+	for _, lang := range append(acceptedLanguages(acceptLanguage), tc.defaultLanguage) {
+		if tmpl, ok := tc.templates[lang][code]; ok {
+			return tmpl
+		}
+
+		// fall back from a regional variant to the language
+		if base, _, found := strings.Cut(lang, "-"); found {
+			if tmpl, ok := tc.templates[base][code]; ok {
+				return tmpl
+			}
+		}
+	}
+
+	return nil
+}
+
+// acceptedLanguages returns the lower cased language tags of the Accept-Language header, by preference
+func acceptedLanguages(header string) []string {
+	type accepted struct {
+		tag     string
+		quality float64
+	}
+
+	var langs []accepted
+
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" || tag == "*" {
+			continue
+		}
+
+		quality := 1.0
+
+		if params = strings.TrimSpace(params); strings.HasPrefix(params, "q=") {
+			if v, err := strconv.ParseFloat(strings.TrimPrefix(params, "q="), 64); err == nil {
+				quality = v
+			}
+		}
+
+		if quality > 0 {
+			langs = append(langs, accepted{tag: strings.ToLower(tag), quality: quality})
+		}
+	}
+
+	sort.SliceStable(langs, func(i, j int) bool { return langs[i].quality > langs[j].quality })
+
+	tags := make([]string, len(langs))
+	for i, l := range langs {
+		tags[i] = l.tag
+	}
+
+	return tags
+}
@@ -0,0 +1,119 @@
+package ginauth_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.hollow.sh/toolbox/ginauth"
+)
+
+func TestErrorCodeOf(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want ginauth.ErrorCode
+	}{
+		{"token validation", ginauth.NewTokenValidationError(errors.New("expired")), ginauth.CodeInvalidToken},
+		{"signing key", ginauth.NewInvalidSigningKeyError(), ginauth.CodeInvalidSigningKey},
+		{"authentication", ginauth.NewAuthenticationError("no token"), ginauth.CodeUnauthenticated},
+		{"authorization", ginauth.NewAuthorizationError("missing scope"), ginauth.CodeForbidden},
+		{"wrapped authorization", fmt.Errorf("wrapped: %w", ginauth.NewAuthorizationError("missing scope")), ginauth.CodeForbidden},
+		{"middleware order", ginauth.NewMiddlewareOrderError(), ginauth.CodeMisconfigured},
+		{"csrf token", ginauth.NewAuthorizationErrorFrom(ginauth.ErrInvalidCSRFToken), ginauth.CodeInvalidCSRFToken},
+		{"other error", errors.New("boom"), ginauth.CodeUnauthenticated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, ginauth.ErrorCodeOf(tt.err))
+		})
+	}
+}
+
+func TestTemplateCatalog(t *testing.T) {
+	catalog, err := ginauth.NewTemplateCatalog(map[string]map[ginauth.ErrorCode]string{
+		"en": {
+			ginauth.CodeForbidden:    "Sorry, you can't do that ({{.Error}})",
+			ginauth.CodeInvalidToken: "Please sign in again",
+		},
+		"fr": {
+			ginauth.CodeForbidden: "Désolé, action interdite",
+		},
+		"fr-CA": {
+			ginauth.CodeForbidden: "Désolé, action interdite au Canada",
+		},
+	}, "en")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		err            error
+		wantStatus     int
+		wantCode       ginauth.ErrorCode
+		wantMessage    string
+	}{
+		{"default language", "", ginauth.NewAuthorizationError("missing scope"), http.StatusForbidden, ginauth.CodeForbidden, "Sorry, you can't do that (missing scope)"},
+		{"negotiated language", "de, fr;q=0.8, en;q=0.5", ginauth.NewAuthorizationError("missing scope"), http.StatusForbidden, ginauth.CodeForbidden, "Désolé, action interdite"},
+		{"regional variant", "fr-CA", ginauth.NewAuthorizationError("missing scope"), http.StatusForbidden, ginauth.CodeForbidden, "Désolé, action interdite au Canada"},
+		{"language of a regional variant", "fr-BE", ginauth.NewAuthorizationError("missing scope"), http.StatusForbidden, ginauth.CodeForbidden, "Désolé, action interdite"},
+		{"code missing in the language", "fr", ginauth.NewTokenValidationError(errors.New("expired")), http.StatusUnauthorized, ginauth.CodeInvalidToken, "Please sign in again"},
+		{"code missing in the catalog", "en", ginauth.NewAuthenticationError("no token"), http.StatusUnauthorized, ginauth.CodeUnauthenticated, "no token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/", ginauth.UseMessageCatalog(catalog), func(c *gin.Context) {
+				ginauth.AbortBecauseOfError(c, tt.err)
+			})
+
+			req := httptest.NewRequest("GET", "http://test/", nil)
+			req.Header.Set("Accept-Language", tt.acceptLanguage)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			var body map[string]string
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			assert.Equal(t, string(tt.wantCode), body["code"])
+			assert.Equal(t, tt.wantMessage, body["message"])
+		})
+	}
+}
+
+func TestAbortWithMessageCatalog(t *testing.T) {
+	contextCatalog := ginauth.MessageCatalogFunc(func(c *gin.Context, code ginauth.ErrorCode, err error) (string, bool) {
+		return "from the context", true
+	})
+
+	catalog := ginauth.MessageCatalogFunc(func(c *gin.Context, code ginauth.ErrorCode, err error) (string, bool) {
+		return "from the middleware", true
+	})
+
+	r := gin.New()
+	r.GET("/", ginauth.UseMessageCatalog(contextCatalog), func(c *gin.Context) {
+		ginauth.AbortWithMessageCatalog(c, ginauth.NewAuthenticationError("no token"), catalog)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "http://test/", nil))
+
+	assert.JSONEq(t, `{"code":"unauthenticated","message":"from the middleware"}`, w.Body.String())
+
+	_, err := ginauth.NewTemplateCatalog(map[string]map[ginauth.ErrorCode]string{"en": {ginauth.CodeForbidden: "{{.Error"}}, "en")
+	assert.ErrorIs(t, err, ginauth.ErrInvalidMessageCatalog)
+
+	_, err = ginauth.NewTemplateCatalog(map[string]map[ginauth.ErrorCode]string{"en": {}}, "fr")
+	assert.ErrorIs(t, err, ginauth.ErrInvalidMessageCatalog)
+}
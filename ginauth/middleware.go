@@ -7,19 +7,50 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-// AbortBecauseOfError aborts a gin context based on a given error
+// AbortBecauseOfError aborts a gin context based on a given error, with the message of the
+// catalog set by UseMessageCatalog when there is one
 func AbortBecauseOfError(c *gin.Context, err error) {
+	AbortWithMessageCatalog(c, err, nil)
+}
+
+// AbortWithMessageCatalog aborts a gin context based on a given error, the response holds the
+// ErrorCodeOf the error and its message from the catalog. The catalog set by UseMessageCatalog
+// is used when catalog is nil, and the default message when neither has one.
+//
+// The error is also recorded in the gin context errors with an AuthFailure as its Meta, so the
+// access logs hold the failure reason, see AuthFailureLogger.
+func AbortWithMessageCatalog(c *gin.Context, err error, catalog MessageCatalog) {
 	var authErr *AuthError
 
 	var validationErr *TokenValidationError
 
+	// If we can't cast it, unauthorize anyway
+	status := http.StatusUnauthorized
+	body := gin.H{"message": err.Error()}
+
 	switch {
 	case errors.As(err, &validationErr):
-		c.AbortWithStatusJSON(validationErr.HTTPErrorCode, gin.H{"message": "invalid auth token", "error": validationErr.Error()})
+		status = validationErr.HTTPErrorCode
+		body = gin.H{"message": "invalid auth token", "error": validationErr.Error()}
 	case errors.As(err, &authErr):
-		c.AbortWithStatusJSON(authErr.HTTPErrorCode, gin.H{"message": authErr.Error()})
-	default:
-		// If we can't cast it, unauthorize anyway
-		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"message": err.Error()})
+		status = authErr.HTTPErrorCode
+		body = gin.H{"message": authErr.Error()}
+	}
+
+	code := ErrorCodeOf(err)
+	body["code"] = code
+
+	if catalog == nil {
+		catalog = contextMessageCatalog(c)
+	}
+
+	if catalog != nil {
+		if msg, ok := catalog.Message(c, code, err); ok {
+			body["message"] = msg
+		}
 	}
+
+	_ = c.Error(err).SetMeta(AuthFailure{Status: status, Code: code})
+
+	c.AbortWithStatusJSON(status, body)
 }
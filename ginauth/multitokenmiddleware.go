@@ -3,11 +3,14 @@ package ginauth
 import (
 	"errors"
 	"fmt"
+	"io"
 	"sync"
 
 	"github.com/gin-gonic/gin"
 )
 
+const contextKeyVerifierResults = "ginauth.verifier_results"
+
 // MultiTokenMiddleware Allows for concurrently verifying a token
 // using different middleware implementations. This relies on implementing
 // the GenericAuthMiddleware interface.
@@ -16,6 +19,8 @@ import (
 // only one object that implements the interface.
 type MultiTokenMiddleware struct {
 	verifiers []GenericAuthMiddleware
+	bypass    *BypassList
+	catalog   MessageCatalog
 }
 
 // NewMultiTokenMiddleware builds a MultiTokenMiddleware object from multiple AuthConfigs.
@@ -38,63 +43,189 @@ func (mtm *MultiTokenMiddleware) Add(middleware GenericAuthMiddleware) error {
 	return nil
 }
 
+// SetBypassList sets the requests which skip authentication, such as health checks
+func (mtm *MultiTokenMiddleware) SetBypassList(b *BypassList) {
+	mtm.bypass = b
+}
+
+// SetMessageCatalog sets the catalog of the messages of the errors returned by AuthRequired
+func (mtm *MultiTokenMiddleware) SetMessageCatalog(catalog MessageCatalog) {
+	mtm.catalog = catalog
+}
+
+// Close closes the verifiers implementing io.Closer, e.g. to stop their background work, and
+// returns the first error
+func (mtm *MultiTokenMiddleware) Close() error {
+	var firstErr error
+
+	for _, verifier := range mtm.verifiers {
+		closer, ok := verifier.(io.Closer)
+		if !ok {
+			continue
+		}
+
+		if err := closer.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return firstErr
+}
+
+// TokenMatcher is implemented by verifiers able to tell cheaply, without validating its signature,
+// that they don't handle the token of the request, e.g. as it was issued by another issuer.
+// MatchToken returns nil when the verifier should verify the token, otherwise the error it would
+// fail with, which is the result of the verifier in a MultiTokenMiddleware.
+type TokenMatcher interface {
+	MatchToken(*gin.Context) error
+}
+
+// VerifierResult holds the outcome of a single verifier of a MultiTokenMiddleware
+type VerifierResult struct {
+	// Index is the position of the verifier in the order it was added
+	Index    int
+	Verifier GenericAuthMiddleware
+	Metadata ClaimMetadata
+	Err      error
+}
+
+// VerifyAll concurrently verifies the token and scopes from the gin Context with every
+// verifier, the results are returned in the order the verifiers were added.
+//
+// The verifiers implementing TokenMatcher are matched first, in order, and only the matching
+// ones verify the token. The verification doesn't spawn any goroutine when a single verifier
+// matches the token.
+func (mtm *MultiTokenMiddleware) VerifyAll(c *gin.Context, scopes []string) []VerifierResult {
+	results := make([]VerifierResult, len(mtm.verifiers))
+	matched := make([]int, 0, len(mtm.verifiers))
+
+	for i, verifier := range mtm.verifiers {
+		results[i] = VerifierResult{Index: i, Verifier: verifier}
+
+		if matcher, ok := verifier.(TokenMatcher); ok {
+			if err := matcher.MatchToken(c); err != nil {
+				results[i].Err = err
+				continue
+			}
+		}
+
+		matched = append(matched, i)
+	}
+
+	verify := func(i int) {
+		results[i].Metadata, results[i].Err = mtm.verifiers[i].VerifyTokenWithScopes(c, scopes)
+	}
+
+	if len(matched) == 1 {
+		verify(matched[0])
+
+		return results
+	}
+
+	var wg sync.WaitGroup
+
+	wg.Add(len(matched))
+
+	for _, i := range matched {
+		go func(i int) {
+			defer wg.Done()
+
+			verify(i)
+		}(i)
+	}
+
+	wg.Wait()
+
+	return results
+}
+
 // AuthRequired is similar to the `AuthRequired` function from the Middleware type
 // in the sense that it'll evaluate the scopes and the token coming from the context.
 // However, this will concurrently evaluate them with the middlewares configured in this
-// struct
+// struct.
+//
+// The first verifier, in the order they were added, that succeeded sets the metadata. When
+// all of them failed, the most relevant error is surfaced. The results of all the verifiers
+// are available to later handlers through VerifierResults.
 func (mtm *MultiTokenMiddleware) AuthRequired(scopes []string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		var wg sync.WaitGroup
-
-		res := make(chan error, len(mtm.verifiers))
-
-		wg.Add(len(mtm.verifiers))
+		if mtm.bypass.Bypass(c) {
+			return
+		}
 
-		for _, verifier := range mtm.verifiers {
-			go func(v GenericAuthMiddleware, c *gin.Context, r chan<- error) {
-				defer wg.Done()
+		results := mtm.VerifyAll(c, scopes)
 
-				cm, err := v.VerifyTokenWithScopes(c, scopes)
+		c.Set(contextKeyVerifierResults, results)
 
-				if err != nil {
-					v.SetMetadata(c, cm)
-				}
+		winner, ok := selectVerifierResult(results)
+		if !ok {
+			AbortWithMessageCatalog(c, errors.New("no verifiers configured"), mtm.catalog) //nolint:goerr113
+			return
+		}
 
-				r <- err
-			}(verifier, c, res)
+		if winner.Err != nil {
+			AbortWithMessageCatalog(c, winner.Err, mtm.catalog)
+			return
 		}
 
-		wg.Wait()
-		close(res)
+		winner.Verifier.SetMetadata(c, winner.Metadata)
+	}
+}
 
-		var surfacingErr error
+// VerifierResults returns the results of the verifiers of a MultiTokenMiddleware set in the gin Context
+func VerifierResults(c *gin.Context) []VerifierResult {
+	v, ok := c.Get(contextKeyVerifierResults)
+	if !ok {
+		return nil
+	}
 
-		for err := range res {
-			if err == nil {
-				// NOTE(jaosorior): This takes the first non-error as a success.
-				// It would be quite strange if we would get multiple successes.
-				return
-			}
+	results, _ := v.([]VerifierResult)
 
-			// initialize surfacingErr.
-			if surfacingErr == nil {
-				surfacingErr = err
-				continue
-			}
+	return results
+}
 
-			// If we previously had an error related to having an invalid signing key
-			// we overwrite the error to be surfaced. We care more about other types of
-			// errors, such as not having the appropriate scope
-			// Also, if we previously had an error with the remote endpoint, we override the error.
-			// This might be a very general error and more specific ones are preferred
-			// for surfacing.
-			if errors.Is(surfacingErr, ErrMiddlewareRemote) || errors.Is(surfacingErr, ErrInvalidSigningKey) {
-				surfacingErr = err
-			}
+// selectVerifierResult picks the first success, or the first of the most relevant errors
+// when no verifier succeeded.
+func selectVerifierResult(results []VerifierResult) (VerifierResult, bool) {
+	var (
+		selected VerifierResult
+		found    bool
+	)
+
+	for _, r := range results {
+		if r.Err == nil {
+			return r, true
 		}
 
-		if surfacingErr != nil {
-			AbortBecauseOfError(c, surfacingErr)
+		if !found || errorRelevance(r.Err) > errorRelevance(selected.Err) {
+			selected, found = r, true
 		}
 	}
+
+	return selected, found
+}
+
+// errorRelevance ranks errors to surface, errors with the remote endpoint or an invalid
+// signing key are very general and only tell the verifier doesn't handle the token, others
+// such as not having the appropriate scope are more specific.
+func errorRelevance(err error) int {
+	if isAuthErrorCause(err, ErrMiddlewareRemote) || isAuthErrorCause(err, ErrInvalidSigningKey) {
+		return 0
+	}
+
+	return 1
+}
+
+// isAuthErrorCause checks the error chain and the error wrapped by an AuthError for the target.
+func isAuthErrorCause(err, target error) bool {
+	if errors.Is(err, target) {
+		return true
+	}
+
+	var authErr *AuthError
+	if errors.As(err, &authErr) {
+		return errors.Is(authErr.err, target)
+	}
+
+	return false
 }
@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -58,7 +59,7 @@ func TestMultitokenMiddlewareValidatesTokens(t *testing.T) {
 			ginjwt.TestPrivRSAKey2ID,
 			jwt.Claims{
 				Subject:   "test-user",
-				Issuer:    "ginjwt.test.issuer",
+				Issuer:    "ginjwt.test.issuer2",
 				NotBefore: jwt.NewNumericDate(time.Now().Add(-2 * time.Hour)),
 				Audience:  jwt.Audience{"ginjwt.test", "another.test.service"},
 			},
@@ -280,3 +281,156 @@ func TestMultitokenInvalidAuthHeader(t *testing.T) {
 		})
 	}
 }
+
+type fixedVerifier struct {
+	cm          ginauth.ClaimMetadata
+	err         error
+	metadataSet bool
+}
+
+func (fv *fixedVerifier) VerifyTokenWithScopes(_ *gin.Context, _ []string) (ginauth.ClaimMetadata, error) {
+	return fv.cm, fv.err
+}
+
+func (fv *fixedVerifier) SetMetadata(c *gin.Context, cm ginauth.ClaimMetadata) {
+	fv.metadataSet = true
+
+	c.Set("jwt.subject", cm.Subject)
+}
+
+func TestMultitokenMiddlewareResultAggregation(t *testing.T) {
+	testCases := []struct {
+		testName     string
+		verifiers    []*fixedVerifier
+		responseCode int
+		responseBody string
+		winner       int
+	}{
+		{
+			"first success wins",
+			[]*fixedVerifier{
+				{err: ginauth.NewInvalidSigningKeyError()},
+				{cm: ginauth.ClaimMetadata{Subject: "second"}},
+				{cm: ginauth.ClaimMetadata{Subject: "third"}},
+			},
+			http.StatusOK,
+			"second",
+			1,
+		},
+		{
+			"specific error surfaced over invalid signing key",
+			[]*fixedVerifier{
+				{err: ginauth.NewInvalidSigningKeyError()},
+				{err: ginauth.NewAuthorizationError("missing required scope")},
+				{err: ginauth.NewInvalidSigningKeyError()},
+			},
+			http.StatusForbidden,
+			"missing required scope",
+			-1,
+		},
+		{
+			"first error surfaced when equally relevant",
+			[]*fixedVerifier{
+				{err: ginauth.NewAuthenticationError("first")},
+				{err: ginauth.NewAuthenticationError("second")},
+			},
+			http.StatusUnauthorized,
+			"first",
+			-1,
+		},
+		{
+			"no verifiers",
+			nil,
+			http.StatusUnauthorized,
+			"no verifiers configured",
+			-1,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.testName, func(t *testing.T) {
+			mtm, err := ginauth.NewMultiTokenMiddleware()
+			require.NoError(t, err)
+
+			for _, v := range tt.verifiers {
+				require.NoError(t, mtm.Add(v))
+			}
+
+			var results []ginauth.VerifierResult
+
+			r := gin.New()
+			r.Use(func(c *gin.Context) {
+				c.Next()
+
+				results = ginauth.VerifierResults(c)
+			})
+			r.GET("/", mtm.AuthRequired([]string{"read"}), func(c *gin.Context) {
+				c.JSON(http.StatusOK, c.GetString("jwt.subject"))
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", "http://test/", nil))
+
+			assert.Equal(t, tt.responseCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.responseBody)
+
+			require.Len(t, results, len(tt.verifiers))
+
+			for i, v := range tt.verifiers {
+				assert.Equal(t, i, results[i].Index)
+				assert.Equal(t, v.err, results[i].Err)
+				assert.Equal(t, i == tt.winner, v.metadataSet)
+			}
+		})
+	}
+}
+
+type matchingVerifier struct {
+	fixedVerifier
+	matchErr error
+	verified int32
+}
+
+func (mv *matchingVerifier) MatchToken(_ *gin.Context) error {
+	return mv.matchErr
+}
+
+func (mv *matchingVerifier) VerifyTokenWithScopes(c *gin.Context, scopes []string) (ginauth.ClaimMetadata, error) {
+	atomic.AddInt32(&mv.verified, 1)
+
+	return mv.fixedVerifier.VerifyTokenWithScopes(c, scopes)
+}
+
+func TestMultitokenMiddlewareTokenMatcher(t *testing.T) {
+	skipped := &matchingVerifier{
+		fixedVerifier: fixedVerifier{cm: ginauth.ClaimMetadata{Subject: "skipped"}},
+		matchErr:      ginauth.NewInvalidSigningKeyError(),
+	}
+	matched := &matchingVerifier{fixedVerifier: fixedVerifier{cm: ginauth.ClaimMetadata{Subject: "matched"}}}
+	plain := &fixedVerifier{err: ginauth.NewAuthenticationError("plain")}
+
+	mtm, err := ginauth.NewMultiTokenMiddleware()
+	require.NoError(t, err)
+
+	require.NoError(t, mtm.Add(skipped))
+	require.NoError(t, mtm.Add(plain))
+	require.NoError(t, mtm.Add(matched))
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "http://test/", nil)
+
+	results := mtm.VerifyAll(c, []string{"read"})
+	require.Len(t, results, 3)
+
+	// the verifiers not matching the token report their match error without verifying it
+	assert.Equal(t, int32(0), atomic.LoadInt32(&skipped.verified))
+	assert.Equal(t, skipped.matchErr, results[0].Err)
+	assert.Equal(t, plain.err, results[1].Err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&matched.verified))
+	assert.NoError(t, results[2].Err)
+	assert.Equal(t, "matched", results[2].Metadata.Subject)
+
+	for i, r := range results {
+		assert.Equal(t, i, r.Index)
+	}
+}
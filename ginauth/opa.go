@@ -0,0 +1,263 @@
+package ginauth
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// ErrInvalidOPAConfig is the error returned when the OPA middleware configuration is invalid
+	ErrInvalidOPAConfig = errors.New("invalid OPA config")
+
+	// ErrOPAQuery is the error returned when the policy couldn't be evaluated
+	ErrOPAQuery = errors.New("OPA policy query failed")
+)
+
+// OPAInput is the input document the policy is evaluated with
+type OPAInput struct {
+	Subject string   `json:"subject"`
+	User    string   `json:"user,omitempty"`
+	Roles   []string `json:"roles"`
+	Tenant  string   `json:"tenant,omitempty"`
+	// Scopes are the scopes the route requires
+	Scopes []string `json:"scopes"`
+	Method string   `json:"method"`
+	// Path is the matched gin route, e.g. /servers/:id, RequestPath the path of the request
+	Path        string            `json:"path"`
+	RequestPath string            `json:"request_path"`
+	Params      map[string]string `json:"params,omitempty"`
+}
+
+// OPADecision is the result of a policy evaluation
+type OPADecision struct {
+	Allow bool `json:"allow"`
+	// Reason is returned to the client when the request is denied
+	Reason string `json:"reason,omitempty"`
+	// ID identifies the decision in the OPA decision logs, when the evaluator returns one
+	ID string `json:"-"`
+}
+
+// PolicyEvaluator evaluates the authorization policy for an input. OPAClient queries an OPA
+// server, embedded Rego, e.g. with the OPA SDK, is used by implementing this interface.
+type PolicyEvaluator interface {
+	Evaluate(ctx context.Context, input OPAInput) (OPADecision, error)
+}
+
+// OPADecisionLogEntry holds a decision of the OPAMiddleware, Err is set when the policy couldn't be evaluated
+type OPADecisionLogEntry struct {
+	Input    OPAInput
+	Decision OPADecision
+	Err      error
+	Duration time.Duration
+}
+
+// OPADecisionLogFunc is called with each decision of the OPAMiddleware, e.g. to audit them
+type OPADecisionLogFunc func(ctx context.Context, entry OPADecisionLogEntry)
+
+// OPAConfig provides the configuration of the OPAMiddleware
+type OPAConfig struct {
+	// Evaluator evaluates the policy, e.g. an OPAClient
+	Evaluator PolicyEvaluator
+	// DecisionLog is called with each decision, no decisions are logged when unset
+	DecisionLog OPADecisionLogFunc
+}
+
+// OPAMiddleware delegates the authorization of requests to an Open Policy Agent policy. The
+// token is verified by the wrapped GenericAuthMiddleware, without checking scopes, then the
+// policy decides given the metadata of the token, the request and the scopes of the route.
+// Requests are denied when the policy can't be evaluated.
+type OPAMiddleware struct {
+	verifier GenericAuthMiddleware
+	config   OPAConfig
+}
+
+// NewOPAMiddleware returns an OPAMiddleware authorizing the requests authenticated by the given middleware
+func NewOPAMiddleware(verifier GenericAuthMiddleware, cfg OPAConfig) (*OPAMiddleware, error) {
+	if verifier == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMiddlewareReference, "The middleware reference can't be nil")
+	}
+
+	if cfg.Evaluator == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidOPAConfig, "a policy evaluator is required")
+	}
+
+	return &OPAMiddleware{verifier: verifier, config: cfg}, nil
+}
+
+// SetMetadata ensures metadata is set in the gin Context
+func (om *OPAMiddleware) SetMetadata(c *gin.Context, cm ClaimMetadata) {
+	om.verifier.SetMetadata(c, cm)
+}
+
+// VerifyTokenWithScopes verifies the token from the gin Context with the wrapped middleware, then
+// evaluates the policy for the request and the scopes.
+func (om *OPAMiddleware) VerifyTokenWithScopes(c *gin.Context, scopes []string) (ClaimMetadata, error) {
+	cm, err := om.verifier.VerifyTokenWithScopes(c, nil)
+	if err != nil {
+		return ClaimMetadata{}, err
+	}
+
+	input := NewOPAInput(c, cm, scopes)
+
+	start := time.Now()
+
+	decision, err := om.config.Evaluator.Evaluate(c.Request.Context(), input)
+
+	if om.config.DecisionLog != nil {
+		om.config.DecisionLog(c.Request.Context(), OPADecisionLogEntry{
+			Input:    input,
+			Decision: decision,
+			Err:      err,
+			Duration: time.Since(start),
+		})
+	}
+
+	if err != nil {
+		return ClaimMetadata{}, &AuthError{HTTPErrorCode: http.StatusServiceUnavailable, err: fmt.Errorf("%w: %s", ErrOPAQuery, err)}
+	}
+
+	if !decision.Allow {
+		msg := decision.Reason
+		if msg == "" {
+			msg = "not authorized by policy"
+		}
+
+		return ClaimMetadata{}, NewAuthorizationError(msg)
+	}
+
+	return cm, nil
+}
+
+// AuthRequired provides a middleware that ensures a request has authentication and is authorized by the policy
+func (om *OPAMiddleware) AuthRequired(scopes []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cm, err := om.VerifyTokenWithScopes(c, scopes)
+		if err != nil {
+			AbortBecauseOfError(c, err)
+			return
+		}
+
+		om.SetMetadata(c, cm)
+	}
+}
+
+// NewOPAInput builds the policy input of the request from the gin Context and the token metadata
+func NewOPAInput(c *gin.Context, cm ClaimMetadata, scopes []string) OPAInput {
+	input := OPAInput{
+		Subject:     cm.Subject,
+		User:        cm.User,
+		Roles:       cm.Roles,
+		Tenant:      cm.Tenant,
+		Scopes:      scopes,
+		Method:      c.Request.Method,
+		Path:        c.FullPath(),
+		RequestPath: c.Request.URL.Path,
+	}
+
+	if input.Roles == nil {
+		input.Roles = []string{}
+	}
+
+	if input.Scopes == nil {
+		input.Scopes = []string{}
+	}
+
+	if len(c.Params) > 0 {
+		input.Params = make(map[string]string, len(c.Params))
+
+		for _, p := range c.Params {
+			input.Params[p.Key] = p.Value
+		}
+	}
+
+	return input
+}
+
+// OPAClient evaluates a policy with the Data API of an OPA server
+type OPAClient struct {
+	url    string
+	client *http.Client
+}
+
+// NewOPAClient returns an OPAClient querying the policy document at the url, e.g.
+// http://localhost:8181/v1/data/hollow/authz. The document is either a boolean or an
+// object with the allow and reason fields, an undefined document denies the request.
+func NewOPAClient(url string, timeout time.Duration) *OPAClient {
+	return &OPAClient{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+type opaQuery struct {
+	Input OPAInput `json:"input"`
+}
+
+type opaResponse struct {
+	Result     json.RawMessage `json:"result"`
+	DecisionID string          `json:"decision_id"`
+}
+
+// Evaluate queries the policy document with the input
+func (oc *OPAClient) Evaluate(ctx context.Context, input OPAInput) (OPADecision, error) {
+	body, err := json.Marshal(opaQuery{Input: input})
+	if err != nil {
+		return OPADecision{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, oc.url, bytes.NewReader(body))
+	if err != nil {
+		return OPADecision{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := oc.client.Do(req)
+	if err != nil {
+		return OPADecision{}, err
+	}
+
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return OPADecision{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return OPADecision{}, fmt.Errorf("%s: %s", resp.Status, respBody) //nolint:goerr113
+	}
+
+	var result opaResponse
+	if err := json.Unmarshal(respBody, &result); err != nil {
+		return OPADecision{}, err
+	}
+
+	decision := OPADecision{ID: result.DecisionID}
+
+	// an undefined document denies the request
+	if len(result.Result) == 0 {
+		return decision, nil
+	}
+
+	if err := json.Unmarshal(result.Result, &decision.Allow); err == nil {
+		return decision, nil
+	}
+
+	if err := json.Unmarshal(result.Result, &decision); err != nil {
+		return OPADecision{}, fmt.Errorf("unexpected policy result %s", result.Result) //nolint:goerr113
+	}
+
+	decision.ID = result.DecisionID
+
+	return decision, nil
+}
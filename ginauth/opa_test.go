@@ -0,0 +1,166 @@
+package ginauth_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.hollow.sh/toolbox/ginauth"
+)
+
+// newOPAServer serves the policy document, allowing admins and readers of their own servers
+func newOPAServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var q struct {
+			Input ginauth.OPAInput `json:"input"`
+		}
+
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&q))
+
+		in := q.Input
+
+		switch {
+		case in.RequestPath == "/undefined":
+			_, _ = w.Write([]byte(`{}`))
+		case in.RequestPath == "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		case in.RequestPath == "/bool":
+			_, _ = w.Write([]byte(`{"result": true, "decision_id": "d1"}`))
+		case len(in.Roles) > 0 && in.Roles[0] == "admin":
+			_, _ = w.Write([]byte(`{"result": {"allow": true}, "decision_id": "d2"}`))
+		case in.Method == http.MethodGet && in.Params["id"] == in.Subject && len(in.Scopes) == 1 && in.Scopes[0] == "read":
+			_, _ = w.Write([]byte(`{"result": {"allow": true}, "decision_id": "d3"}`))
+		default:
+			_, _ = w.Write([]byte(`{"result": {"allow": false, "reason": "only your own servers"}, "decision_id": "d4"}`))
+		}
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func TestOPAMiddleware(t *testing.T) {
+	srv := newOPAServer(t)
+
+	testCases := []struct {
+		name         string
+		verifier     *fixedVerifier
+		path         string
+		responseCode int
+		responseBody string
+		decisionID   string
+	}{
+		{
+			"admin",
+			&fixedVerifier{cm: ginauth.ClaimMetadata{Subject: "alice", Roles: []string{"admin"}}},
+			"/servers/bob",
+			http.StatusOK,
+			"alice",
+			"d2",
+		},
+		{
+			"owner",
+			&fixedVerifier{cm: ginauth.ClaimMetadata{Subject: "bob"}},
+			"/servers/bob",
+			http.StatusOK,
+			"bob",
+			"d3",
+		},
+		{
+			"denied with the policy reason",
+			&fixedVerifier{cm: ginauth.ClaimMetadata{Subject: "bob"}},
+			"/servers/alice",
+			http.StatusForbidden,
+			"only your own servers",
+			"d4",
+		},
+		{
+			"boolean document",
+			&fixedVerifier{cm: ginauth.ClaimMetadata{Subject: "bob"}},
+			"/bool",
+			http.StatusOK,
+			"bob",
+			"d1",
+		},
+		{
+			"undefined document",
+			&fixedVerifier{cm: ginauth.ClaimMetadata{Subject: "bob"}},
+			"/undefined",
+			http.StatusForbidden,
+			"not authorized by policy",
+			"",
+		},
+		{
+			"policy unavailable",
+			&fixedVerifier{cm: ginauth.ClaimMetadata{Subject: "bob"}},
+			"/broken",
+			http.StatusServiceUnavailable,
+			"OPA policy query failed",
+			"",
+		},
+		{
+			"unauthenticated",
+			&fixedVerifier{err: ginauth.NewAuthenticationError("invalid token")},
+			"/servers/bob",
+			http.StatusUnauthorized,
+			"invalid token",
+			"",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var logged []ginauth.OPADecisionLogEntry
+
+			om, err := ginauth.NewOPAMiddleware(tt.verifier, ginauth.OPAConfig{
+				Evaluator: ginauth.NewOPAClient(srv.URL, time.Second),
+				DecisionLog: func(_ context.Context, e ginauth.OPADecisionLogEntry) {
+					logged = append(logged, e)
+				},
+			})
+			require.NoError(t, err)
+
+			handler := func(c *gin.Context) {
+				c.JSON(http.StatusOK, c.GetString("jwt.subject"))
+			}
+
+			r := gin.New()
+			r.GET("/servers/:id", om.AuthRequired([]string{"read"}), handler)
+			r.GET("/:path", om.AuthRequired([]string{"read"}), handler)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", "http://test"+tt.path, nil))
+
+			assert.Equal(t, tt.responseCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.responseBody)
+
+			if tt.verifier.err != nil {
+				assert.Empty(t, logged)
+				return
+			}
+
+			require.Len(t, logged, 1)
+			assert.Equal(t, tt.decisionID, logged[0].Decision.ID)
+			assert.Equal(t, tt.path, logged[0].Input.RequestPath)
+			assert.Equal(t, []string{"read"}, logged[0].Input.Scopes)
+		})
+	}
+}
+
+func TestNewOPAMiddlewareErrors(t *testing.T) {
+	_, err := ginauth.NewOPAMiddleware(nil, ginauth.OPAConfig{Evaluator: ginauth.NewOPAClient("http://opa", time.Second)})
+	assert.ErrorIs(t, err, ginauth.ErrInvalidMiddlewareReference)
+
+	_, err = ginauth.NewOPAMiddleware(&fixedVerifier{}, ginauth.OPAConfig{})
+	assert.ErrorIs(t, err, ginauth.ErrInvalidOPAConfig)
+}
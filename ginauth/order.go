@@ -0,0 +1,34 @@
+package ginauth
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const contextKeyAuthenticated = "ginauth.authenticated"
+
+// ErrMiddlewareOrder is the error returned when the scopes of a request are checked before any auth
+// middleware authenticated it, e.g. RequiredScopes registered without AuthRequired
+var ErrMiddlewareOrder = errors.New("scopes checked on a request no auth middleware authenticated, check the middlewares order")
+
+// MarkAuthenticated records in the gin Context that the request was authenticated, auth middlewares
+// call it once the requestor is verified so the scope middlewares can tell they ran before
+func MarkAuthenticated(c *gin.Context) {
+	c.Set(contextKeyAuthenticated, true)
+}
+
+// Authenticated returns true once an auth middleware authenticated the request
+func Authenticated(c *gin.Context) bool {
+	return c.GetBool(contextKeyAuthenticated)
+}
+
+// NewMiddlewareOrderError returns the AuthError of requests whose scopes are checked before they
+// were authenticated, they are rejected with a 500 as the server is misconfigured
+func NewMiddlewareOrderError() *AuthError {
+	return &AuthError{
+		HTTPErrorCode: http.StatusInternalServerError,
+		err:           ErrMiddlewareOrder,
+	}
+}
@@ -0,0 +1,49 @@
+package ginauth_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.hollow.sh/toolbox/ginauth"
+)
+
+func TestMarkAuthenticated(t *testing.T) {
+	akm, err := ginauth.NewAPIKeyMiddleware(context.TODO(), ginauth.StaticAPIKeys{
+		{ID: "reader", Hash: ginauth.HashAPIKey("reader-secret"), Scopes: []string{"read"}},
+	})
+	require.NoError(t, err)
+
+	var authenticated bool
+
+	r := gin.New()
+	r.GET("/", func(c *gin.Context) {
+		assert.False(t, ginauth.Authenticated(c))
+	}, akm.AuthRequired([]string{"read"}), func(c *gin.Context) {
+		authenticated = ginauth.Authenticated(c)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(ginauth.APIKeyHeader, "reader-secret")
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, authenticated)
+}
+
+func TestMiddlewareOrderError(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	ginauth.AbortBecauseOfError(c, ginauth.NewMiddlewareOrderError())
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), `"code":"misconfigured"`)
+}
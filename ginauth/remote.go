@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -15,6 +17,11 @@ const (
 	// We might want to standardize these into exportable constants
 	contextKeySubject = "jwt.subject"
 	contextKeyUser    = "jwt.user"
+	contextKeyRoles   = "jwt.roles"
+
+	// prefix lengths kept when truncating forwarded client addresses
+	truncatedIPv4Bits = 24
+	truncatedIPv6Bits = 48
 )
 
 // NewAuthRequestV1FromScopes creates an AuthRequest structure from the given scopes
@@ -27,20 +34,67 @@ func NewAuthRequestV1FromScopes(scopes []string) *AuthRequestV1 {
 	}
 }
 
+// NewAuthRequestV2 creates an AuthRequestV2 structure from the given scopes and client information
+func NewAuthRequestV2(scopes []string, client *ClientInfoV2) *AuthRequestV2 {
+	return &AuthRequestV2{
+		AuthMeta: AuthMeta{
+			Version: AuthRequestVersion2,
+		},
+		Scopes: scopes,
+		Client: client,
+	}
+}
+
 // RemoteMiddleware defines middleware that relies on a remote endpoint
 // in order to get an authorization decision
 type RemoteMiddleware struct {
 	url     string
 	timeout time.Duration
+
+	forwardClientInfo bool
+	redaction         ClientInfoRedaction
+
+	stats *remoteStats
+	cache DecisionCache
+}
+
+// ClientInfoRedaction limits the client information forwarded to the remote endpoint
+type ClientInfoRedaction struct {
+	// TruncateIPs zeroes the host part of forwarded addresses, keeping the /24 of IPv4
+	// and the /48 of IPv6 addresses, which is enough for geo decisions
+	TruncateIPs bool
+	// OmitUserAgent doesn't forward the User-Agent
+	OmitUserAgent bool
+	// RoutePath forwards the matched gin route, e.g. /servers/:id, instead of the request path
+	RoutePath bool
+}
+
+// RemoteOption configures a RemoteMiddleware
+type RemoteOption func(*RemoteMiddleware)
+
+// WithClientInfo makes the RemoteMiddleware send AuthRequestV2 requests, forwarding the client IP,
+// X-Forwarded-For addresses, User-Agent, request method and path to the remote endpoint
+func WithClientInfo(redaction ClientInfoRedaction) RemoteOption {
+	return func(rm *RemoteMiddleware) {
+		rm.forwardClientInfo = true
+		rm.redaction = redaction
+	}
 }
 
 // NewRemoteMiddleware returns an instance of RemoteMiddleware
 // TODO(jaosorior) Pass in TLS parameters
-func NewRemoteMiddleware(url string, timeout time.Duration) *RemoteMiddleware {
-	return &RemoteMiddleware{
+func NewRemoteMiddleware(url string, timeout time.Duration, opts ...RemoteOption) *RemoteMiddleware {
+	rm := &RemoteMiddleware{
 		url:     url,
 		timeout: timeout,
+		stats:   newRemoteStats(),
+	}
+
+	for _, opt := range opts {
+		opt(rm)
 	}
+
+	return rm
 }
 
 // SetMetadata ensures metadata is set in the gin Context
@@ -52,6 +106,8 @@ func (rm *RemoteMiddleware) SetMetadata(c *gin.Context, cm ClaimMetadata) {
 	if cm.User != "" {
 		c.Set(contextKeyUser, cm.User)
 	}
+
+	MarkAuthenticated(c)
 }
 
 // VerifyTokenWithScopes verifies a given token (from the gin Context) against the given scope
@@ -61,13 +117,31 @@ func (rm *RemoteMiddleware) VerifyTokenWithScopes(c *gin.Context, scopes []strin
 		Timeout: rm.timeout,
 	}
 	origRequest := c.Request
-	areq := NewAuthRequestV1FromScopes(scopes)
+
+	var areq interface{} = NewAuthRequestV1FromScopes(scopes)
+	if rm.forwardClientInfo {
+		areq = NewAuthRequestV2(scopes, rm.clientInfo(c))
+	}
 
 	reqbody, merr := json.Marshal(areq)
 	if merr != nil {
 		return ClaimMetadata{}, fmt.Errorf("%w: %s", ErrMiddlewareRemote, merr)
 	}
 
+	var cacheKey string
+
+	if rm.cache != nil {
+		cacheKey = DecisionCacheKey(origRequest.Header.Get("Authorization"), reqbody)
+
+		if d, err := rm.cache.GetDecision(c.Request.Context(), cacheKey); err == nil && d != nil {
+			rm.stats.recordCache(true)
+
+			return d.claimMetadata()
+		}
+
+		rm.stats.recordCache(false)
+	}
+
 	// We forward the original request method that was done to the target service.
 	// That's part of what we're authorizing.
 	req, reqerr := http.NewRequestWithContext(c.Request.Context(), origRequest.Method, rm.url, bytes.NewBuffer(reqbody))
@@ -80,8 +154,12 @@ func (rm *RemoteMiddleware) VerifyTokenWithScopes(c *gin.Context, scopes []strin
 	// Forward authorization header
 	req.Header.Set("Authorization", origRequest.Header.Get("Authorization"))
 
+	start := time.Now()
+
 	resp, resperr := cli.Do(req)
 	if resperr != nil {
+		rm.stats.record(remoteStatusNone, time.Since(start), resperr)
+
 		return ClaimMetadata{}, fmt.Errorf("%w: %s", ErrMiddlewareRemote, resperr)
 	}
 
@@ -89,9 +167,13 @@ func (rm *RemoteMiddleware) VerifyTokenWithScopes(c *gin.Context, scopes []strin
 
 	body, readerr := io.ReadAll(resp.Body)
 	if readerr != nil {
+		rm.stats.record(remoteStatusNone, time.Since(start), readerr)
+
 		return ClaimMetadata{}, fmt.Errorf("%w: %s", ErrMiddlewareRemote, readerr)
 	}
 
+	rm.stats.record(resp.StatusCode, time.Since(start), nil)
+
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusForbidden && resp.StatusCode != http.StatusUnauthorized {
 		return ClaimMetadata{}, fmt.Errorf("%w: %s", ErrMiddlewareRemote, body)
 	}
@@ -103,26 +185,69 @@ func (rm *RemoteMiddleware) VerifyTokenWithScopes(c *gin.Context, scopes []strin
 		return ClaimMetadata{}, NewAuthenticationError(unmarshallerr.Error())
 	}
 
-	if !authResp.Authed {
-		return ClaimMetadata{}, NewAuthenticationError(authResp.Message)
+	decision := CachedDecision{
+		Authed:  authResp.Authed,
+		Message: authResp.Message,
 	}
 
 	// TODO(jaosorior): Should we fail the request if no appropriate
 	// response is provided?
-	if authResp.Details == nil {
-		// The request was approved but no metadata was given back
-		return ClaimMetadata{}, nil
+	if authResp.Details != nil {
+		decision.Subject = authResp.Details.Subject
+		decision.User = authResp.Details.User
 	}
 
-	cm := ClaimMetadata{
-		Subject: authResp.Details.Subject,
-		User:    authResp.Details.User,
+	if rm.cache != nil {
+		// a cache failure only costs a request to the remote endpoint next time
+		_ = rm.cache.SetDecision(c.Request.Context(), cacheKey, decision)
 	}
-	if authResp.Details.User == "" {
-		cm.User = authResp.Details.Subject
+
+	return decision.claimMetadata()
+}
+
+// clientInfo returns the information of the client making the request, redacted as configured
+func (rm *RemoteMiddleware) clientInfo(c *gin.Context) *ClientInfoV2 {
+	info := &ClientInfoV2{
+		IP:     rm.redactIP(c.ClientIP()),
+		Method: c.Request.Method,
+		Path:   c.Request.URL.Path,
+	}
+
+	for _, header := range c.Request.Header.Values("X-Forwarded-For") {
+		for _, addr := range strings.Split(header, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				info.ForwardedFor = append(info.ForwardedFor, rm.redactIP(addr))
+			}
+		}
+	}
+
+	if !rm.redaction.OmitUserAgent {
+		info.UserAgent = c.Request.UserAgent()
+	}
+
+	if rm.redaction.RoutePath {
+		info.Path = c.FullPath()
+	}
+
+	return info
+}
+
+func (rm *RemoteMiddleware) redactIP(addr string) string {
+	if !rm.redaction.TruncateIPs {
+		return addr
+	}
+
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		// not an address we know how to truncate, don't leak it
+		return ""
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(truncatedIPv4Bits, 8*net.IPv4len)).String()
 	}
 
-	return cm, nil
+	return ip.Mask(net.CIDRMask(truncatedIPv6Bits, 8*net.IPv6len)).String()
 }
 
 // AuthRequired provides a middleware that ensures a request has authentication
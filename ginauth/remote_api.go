@@ -3,6 +3,10 @@ package ginauth
 const (
 	// AuthRequestVersion1 defines version 1 of the AuthRequest message format
 	AuthRequestVersion1 = "v1"
+
+	// AuthRequestVersion2 defines version 2 of the AuthRequest message format,
+	// which adds the client information of the original request
+	AuthRequestVersion2 = "v2"
 )
 
 // AuthMeta holds metdata for an AuthRequest
@@ -17,6 +21,25 @@ type AuthRequestV1 struct {
 	Scopes   []string `json:"scopes"`
 }
 
+// AuthRequestV2 holds an auth request which asks a remote endpoint for an
+// authorization decision based on the given scopes and the client making the request
+type AuthRequestV2 struct {
+	AuthMeta `json:",inline"`
+	Scopes   []string      `json:"scopes"`
+	Client   *ClientInfoV2 `json:"client,omitempty"`
+}
+
+// ClientInfoV2 describes the client which made the original request
+type ClientInfoV2 struct {
+	// IP is the client IP as resolved by gin, taking the trusted proxies into account
+	IP string `json:"ip,omitempty"`
+	// ForwardedFor holds the addresses from the X-Forwarded-For header of the original request
+	ForwardedFor []string `json:"forwarded_for,omitempty"`
+	UserAgent    string   `json:"user_agent,omitempty"`
+	Method       string   `json:"method,omitempty"`
+	Path         string   `json:"path,omitempty"`
+}
+
 // AuthResponseV1 holds a simple auth response which denotes
 // the auth decision. Note that the decision will also be
 // reflected in the HTTP status code.
@@ -32,3 +55,23 @@ type SuccessAuthDetailsV1 struct {
 	Subject string `json:"subject"`
 	User    string `json:"user,omitempty"`
 }
+
+// EntitlementRequestV1 asks a remote endpoint whether the requestor holds
+// the named entitlement for the given resource
+type EntitlementRequestV1 struct {
+	AuthMeta    `json:",inline"`
+	Entitlement string            `json:"entitlement"`
+	Subject     string            `json:"subject"`
+	User        string            `json:"user,omitempty"`
+	Roles       []string          `json:"roles,omitempty"`
+	Method      string            `json:"method,omitempty"`
+	Path        string            `json:"path,omitempty"`
+	Resource    map[string]string `json:"resource,omitempty"`
+}
+
+// EntitlementResponseV1 holds the entitlement decision
+type EntitlementResponseV1 struct {
+	AuthMeta `json:",inline"`
+	Allowed  bool   `json:"allowed"`
+	Message  string `json:"message,omitempty"`
+}
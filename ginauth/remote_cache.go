@@ -0,0 +1,281 @@
+package ginauth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+)
+
+const (
+	// DefaultDecisionCacheTTL is how long decisions are cached when no TTL is set
+	DefaultDecisionCacheTTL = 10 * time.Second
+
+	// number of decisions cached locally above which the expired ones are dropped
+	localDecisionsSweepSize = 1024
+)
+
+// ErrInvalidDecisionCache is the error returned when the decision cache configuration is invalid
+var ErrInvalidDecisionCache = errors.New("invalid decision cache config")
+
+// CachedDecision is a decision of the remote endpoint reused for identical requests
+type CachedDecision struct {
+	Authed  bool   `json:"auth"`
+	Message string `json:"message,omitempty"`
+	Subject string `json:"subject,omitempty"`
+	User    string `json:"user,omitempty"`
+}
+
+// claimMetadata returns the result VerifyTokenWithScopes returns for the decision
+func (d CachedDecision) claimMetadata() (ClaimMetadata, error) {
+	if !d.Authed {
+		return ClaimMetadata{}, NewAuthenticationError(d.Message)
+	}
+
+	cm := ClaimMetadata{
+		Subject: d.Subject,
+		User:    d.User,
+	}
+	if cm.User == "" {
+		cm.User = d.Subject
+	}
+
+	return cm, nil
+}
+
+// DecisionCache stores the decisions of the remote endpoint, keyed with DecisionCacheKey.
+// Errors are ignored by the RemoteMiddleware which asks the remote endpoint instead.
+type DecisionCache interface {
+	// GetDecision returns the cached decision, nil when none is cached or it expired
+	GetDecision(ctx context.Context, key string) (*CachedDecision, error)
+	// SetDecision caches the decision
+	SetDecision(ctx context.Context, key string, decision CachedDecision) error
+}
+
+// WithDecisionCache makes the RemoteMiddleware reuse the decisions made for identical requests,
+// requests with the same token, scopes and forwarded client information. Denials are cached
+// too, failures to reach the remote endpoint aren't.
+func WithDecisionCache(cache DecisionCache) RemoteOption {
+	return func(rm *RemoteMiddleware) {
+		rm.cache = cache
+	}
+}
+
+// DecisionCacheTokenHash returns the hash the decisions for the token are keyed with
+func DecisionCacheTokenHash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// DecisionCacheKey returns the key of the decision for the Authorization header and the body of
+// the request sent to the remote endpoint. It is prefixed with the DecisionCacheTokenHash of the
+// token so the decisions for a token can be invalidated together.
+func DecisionCacheKey(authorization string, authRequest []byte) string {
+	token := authorization
+
+	if fields := strings.Fields(authorization); len(fields) == 2 { //nolint:gomnd // scheme and token
+		token = fields[1]
+	}
+
+	sum := sha256.Sum256(authRequest)
+
+	return DecisionCacheTokenHash(token) + "." + hex.EncodeToString(sum[:])
+}
+
+// NatsDecisionCacheConfig configures a NatsDecisionCache
+type NatsDecisionCacheConfig struct {
+	// KV is the bucket shared by the replicas, it should have a TTL so expired decisions are dropped
+	KV nats.KeyValue
+	// Conn publishes and receives the invalidations
+	Conn *nats.Conn
+	// InvalidationSubject is the subject invalidations are published on
+	InvalidationSubject string
+	// TTL is how long decisions are reused. Defaults to DefaultDecisionCacheTTL if unspecified.
+	TTL time.Duration
+}
+
+// NatsDecisionCache is a DecisionCache shared by a fleet of replicas through a NATS KV bucket,
+// decisions are also kept in memory for their TTL. Invalidations published on the
+// InvalidationSubject drop the decisions kept in memory by each replica.
+type NatsDecisionCache struct {
+	kv      nats.KeyValue
+	conn    *nats.Conn
+	subject string
+	ttl     time.Duration
+	sub     *nats.Subscription
+
+	mu    sync.Mutex
+	local map[string]natsCachedDecision
+}
+
+// natsCachedDecision is the decision stored in the bucket
+type natsCachedDecision struct {
+	CachedDecision
+	Expires time.Time `json:"expires"`
+}
+
+// NewNatsDecisionCache returns a NatsDecisionCache subscribed to the invalidations
+func NewNatsDecisionCache(cfg NatsDecisionCacheConfig) (*NatsDecisionCache, error) {
+	if cfg.KV == nil || cfg.Conn == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDecisionCache, "a KV bucket and a NATS connection are required")
+	}
+
+	if cfg.InvalidationSubject == "" {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDecisionCache, "the invalidation subject can't be empty")
+	}
+
+	if cfg.TTL == 0 {
+		cfg.TTL = DefaultDecisionCacheTTL
+	}
+
+	dc := &NatsDecisionCache{
+		kv:      cfg.KV,
+		conn:    cfg.Conn,
+		subject: cfg.InvalidationSubject,
+		ttl:     cfg.TTL,
+		local:   map[string]natsCachedDecision{},
+	}
+
+	sub, err := cfg.Conn.Subscribe(cfg.InvalidationSubject, func(msg *nats.Msg) {
+		dc.dropLocal(string(msg.Data))
+	})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidDecisionCache, err)
+	}
+
+	dc.sub = sub
+
+	return dc, nil
+}
+
+// GetDecision returns the decision kept in memory, or the one stored in the bucket
+func (dc *NatsDecisionCache) GetDecision(_ context.Context, key string) (*CachedDecision, error) {
+	now := time.Now()
+
+	dc.mu.Lock()
+	d, ok := dc.local[key]
+	dc.mu.Unlock()
+
+	if ok && now.Before(d.Expires) {
+		return &d.CachedDecision, nil
+	}
+
+	entry, err := dc.kv.Get(key)
+	if err != nil {
+		if errors.Is(err, nats.ErrKeyNotFound) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	if err := json.Unmarshal(entry.Value(), &d); err != nil {
+		return nil, err
+	}
+
+	if !now.Before(d.Expires) {
+		return nil, nil
+	}
+
+	dc.storeLocal(key, d)
+
+	return &d.CachedDecision, nil
+}
+
+// SetDecision keeps the decision in memory and stores it in the bucket for the TTL
+func (dc *NatsDecisionCache) SetDecision(_ context.Context, key string, decision CachedDecision) error {
+	d := natsCachedDecision{CachedDecision: decision, Expires: time.Now().Add(dc.ttl)}
+
+	dc.storeLocal(key, d)
+
+	b, err := json.Marshal(d)
+	if err != nil {
+		return err
+	}
+
+	_, err = dc.kv.Put(key, b)
+
+	return err
+}
+
+// Invalidate drops the decisions made for the token from the bucket and publishes the
+// invalidation to the replicas, all the decisions are dropped when the token is empty
+func (dc *NatsDecisionCache) Invalidate(ctx context.Context, token string) error {
+	var hash string
+	if token != "" {
+		hash = DecisionCacheTokenHash(token)
+	}
+
+	dc.dropLocal(hash)
+
+	pattern := ">"
+	if hash != "" {
+		pattern = hash + ".*"
+	}
+
+	watcher, err := dc.kv.Watch(pattern, nats.MetaOnly(), nats.IgnoreDeletes(), nats.Context(ctx))
+	if err != nil {
+		return err
+	}
+
+	defer watcher.Stop() //nolint:errcheck // the watcher is only used to list the keys
+
+	// a nil entry marks the end of the stored keys
+	for entry := range watcher.Updates() {
+		if entry == nil {
+			break
+		}
+
+		if err := dc.kv.Delete(entry.Key()); err != nil && !errors.Is(err, nats.ErrKeyNotFound) {
+			return err
+		}
+	}
+
+	return dc.conn.Publish(dc.subject, []byte(hash))
+}
+
+// Close stops receiving invalidations
+func (dc *NatsDecisionCache) Close() error {
+	return dc.sub.Unsubscribe()
+}
+
+func (dc *NatsDecisionCache) storeLocal(key string, d natsCachedDecision) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	if len(dc.local) >= localDecisionsSweepSize {
+		now := time.Now()
+
+		for k, cached := range dc.local {
+			if !now.Before(cached.Expires) {
+				delete(dc.local, k)
+			}
+		}
+	}
+
+	dc.local[key] = d
+}
+
+// dropLocal drops the decisions kept in memory for the token hash, all of them when it is empty
+func (dc *NatsDecisionCache) dropLocal(hash string) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	if hash == "" {
+		dc.local = map[string]natsCachedDecision{}
+		return
+	}
+
+	for key := range dc.local {
+		if strings.HasPrefix(key, hash+".") {
+			delete(dc.local, key)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package ginauth_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	srvtest "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.hollow.sh/toolbox/ginauth"
+)
+
+func TestRemoteMiddlewareDecisionCache(t *testing.T) {
+	var remoteRequests int32
+
+	// only the "admin" token is authorized
+	authServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&remoteRequests, 1)
+
+		resp := ginauth.AuthResponseV1{Message: "operation not permitted"}
+		status := http.StatusUnauthorized
+
+		if r.Header.Get("Authorization") == "Bearer admin" {
+			resp = ginauth.AuthResponseV1{Authed: true, Details: &ginauth.SuccessAuthDetailsV1{Subject: "admin"}}
+			status = http.StatusOK
+		}
+
+		w.WriteHeader(status)
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+	defer authServer.Close()
+
+	opts := srvtest.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+
+	srv := srvtest.RunServer(&opts)
+	defer srv.Shutdown()
+
+	nc, err := nats.Connect(srv.ClientURL())
+	require.NoError(t, err)
+
+	defer nc.Close()
+
+	js, err := nc.JetStream()
+	require.NoError(t, err)
+
+	kv, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: "decisions", TTL: time.Minute})
+	require.NoError(t, err)
+
+	// two replicas sharing the bucket
+	replicas := make([]*ginauth.RemoteMiddleware, 2)
+	caches := make([]*ginauth.NatsDecisionCache, 2)
+
+	for i := range replicas {
+		caches[i], err = ginauth.NewNatsDecisionCache(ginauth.NatsDecisionCacheConfig{
+			KV:                  kv,
+			Conn:                nc,
+			InvalidationSubject: "decisions.invalidate",
+		})
+		require.NoError(t, err)
+
+		defer caches[i].Close()
+
+		replicas[i] = ginauth.NewRemoteMiddleware(authServer.URL, time.Second, ginauth.WithDecisionCache(caches[i]))
+	}
+
+	verify := func(rm *ginauth.RemoteMiddleware, token string, scopes ...string) (ginauth.ClaimMetadata, error) {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "http://test/", nil)
+		c.Request.Header.Set("Authorization", "Bearer "+token)
+
+		return rm.VerifyTokenWithScopes(c, scopes)
+	}
+
+	cm, err := verify(replicas[0], "admin", "read")
+	require.NoError(t, err)
+	assert.Equal(t, "admin", cm.User)
+
+	// the other replica reuses the decision
+	cm, err = verify(replicas[1], "admin", "read")
+	require.NoError(t, err)
+	assert.Equal(t, "admin", cm.User)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&remoteRequests))
+
+	// other scopes are another decision
+	_, err = verify(replicas[1], "admin", "write")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&remoteRequests))
+
+	// denials are cached too
+	for _, rm := range replicas {
+		_, err = verify(rm, "guest", "read")
+		assert.ErrorContains(t, err, "operation not permitted")
+	}
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(&remoteRequests))
+	assert.Equal(t, uint64(2), replicas[1].Stats().CacheHits)
+	assert.Equal(t, uint64(1), replicas[1].Stats().CacheMisses)
+
+	// invalidating the token drops its decisions from the bucket and the other replicas
+	require.NoError(t, caches[0].Invalidate(context.Background(), "admin"))
+	require.NoError(t, nc.Flush())
+
+	require.Eventually(t, func() bool {
+		_, err := verify(replicas[1], "admin", "read")
+		return err == nil && atomic.LoadInt32(&remoteRequests) == 4
+	}, time.Second, 10*time.Millisecond)
+
+	// the decisions for other tokens are kept
+	_, err = verify(replicas[0], "guest", "read")
+	assert.Error(t, err)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&remoteRequests))
+
+	// all the decisions are dropped without a token
+	require.NoError(t, caches[1].Invalidate(context.Background(), ""))
+
+	_, err = verify(replicas[1], "guest", "read")
+	assert.Error(t, err)
+	assert.Equal(t, int32(5), atomic.LoadInt32(&remoteRequests))
+}
+
+func TestDecisionCacheKey(t *testing.T) {
+	key := ginauth.DecisionCacheKey("Bearer token", []byte(`{"scopes":["read"]}`))
+
+	assert.Equal(t, key, ginauth.DecisionCacheKey("bearer token", []byte(`{"scopes":["read"]}`)))
+	assert.NotEqual(t, key, ginauth.DecisionCacheKey("Bearer token", []byte(`{"scopes":["write"]}`)))
+	assert.Contains(t, key, ginauth.DecisionCacheTokenHash("token")+".")
+
+	_, err := ginauth.NewNatsDecisionCache(ginauth.NatsDecisionCacheConfig{})
+	assert.ErrorIs(t, err, ginauth.ErrInvalidDecisionCache)
+}
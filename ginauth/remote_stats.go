@@ -0,0 +1,229 @@
+package ginauth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultRemoteErrorBudget is the ratio of failed requests to the remote endpoint,
+	// within the DefaultRemoteErrorBudgetWindow, above which it is reported unhealthy
+	DefaultRemoteErrorBudget = 0.5
+
+	// DefaultRemoteErrorBudgetWindow is the number of latest requests the error budget applies to
+	DefaultRemoteErrorBudgetWindow = 100
+
+	// status recorded for requests which got no response
+	remoteStatusNone = 0
+)
+
+// ErrRemoteUnhealthy is the error returned by RemoteMiddleware.Healthy when the
+// remote endpoint failed more requests than the error budget allows
+var ErrRemoteUnhealthy = errors.New("remote auth endpoint unhealthy")
+
+// DefaultRemoteLatencyBuckets are the upper bounds of the remote request latency histogram
+var DefaultRemoteLatencyBuckets = []time.Duration{
+	5 * time.Millisecond,
+	10 * time.Millisecond,
+	25 * time.Millisecond,
+	50 * time.Millisecond,
+	100 * time.Millisecond,
+	250 * time.Millisecond,
+	500 * time.Millisecond,
+	time.Second,
+	2500 * time.Millisecond,
+	5 * time.Second,
+	10 * time.Second,
+}
+
+// RemoteStats holds the metrics of the requests made to the remote endpoint, to be
+// exported to the metrics system
+type RemoteStats struct {
+	// Requests counts the requests by response status code, requests which got no response are counted under 0
+	Requests map[int]uint64
+	// Timeouts counts the requests which timed out
+	Timeouts uint64
+	// LatencyBuckets are the upper bounds of the latency histogram
+	LatencyBuckets []time.Duration
+	// LatencyCounts holds the cumulative number of requests which took up to each bucket,
+	// the last entry counts all requests
+	LatencyCounts []uint64
+	// LatencySum is the total time spent on requests
+	LatencySum time.Duration
+	// Failures is the number of failed requests among the latest ErrorBudgetWindow requests
+	Failures int
+	// Window is the number of latest requests Failures applies to
+	Window int
+	// CacheHits counts the decisions reused from the DecisionCache
+	CacheHits uint64
+	// CacheMisses counts the decisions not found in the DecisionCache
+	CacheMisses uint64
+}
+
+// WithErrorBudget sets the ratio of failed requests among the latest window requests above
+// which the remote endpoint is reported unhealthy, defaults to DefaultRemoteErrorBudget of
+// the DefaultRemoteErrorBudgetWindow requests. Requests which got no response or a response
+// other than 200, 401 or 403 are failures.
+func WithErrorBudget(budget float64, window int) RemoteOption {
+	return func(rm *RemoteMiddleware) {
+		rm.stats.budget = budget
+		rm.stats.outcomes = make([]bool, window)
+	}
+}
+
+// remoteStats records the requests made to the remote endpoint
+type remoteStats struct {
+	mu sync.Mutex
+
+	requests   map[int]uint64
+	timeouts   uint64
+	latencies  []uint64
+	latencySum time.Duration
+
+	budget float64
+	// outcomes is a ring of the latest requests, true for failures
+	outcomes []bool
+	next     int
+	recorded int
+	failures int
+
+	cacheHits   uint64
+	cacheMisses uint64
+}
+
+func newRemoteStats() *remoteStats {
+	return &remoteStats{
+		requests:  map[int]uint64{},
+		latencies: make([]uint64, len(DefaultRemoteLatencyBuckets)+1),
+		budget:    DefaultRemoteErrorBudget,
+		outcomes:  make([]bool, DefaultRemoteErrorBudgetWindow),
+	}
+}
+
+// record records a request, status is remoteStatusNone when no response was received
+func (s *remoteStats) record(status int, latency time.Duration, err error) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests[status]++
+
+	if err != nil && isTimeout(err) {
+		s.timeouts++
+	}
+
+	for i, bound := range DefaultRemoteLatencyBuckets {
+		if latency <= bound {
+			s.latencies[i]++
+		}
+	}
+
+	s.latencies[len(DefaultRemoteLatencyBuckets)]++
+	s.latencySum += latency
+
+	if len(s.outcomes) == 0 {
+		return
+	}
+
+	failed := status != http.StatusOK && status != http.StatusUnauthorized && status != http.StatusForbidden
+
+	if s.recorded == len(s.outcomes) && s.outcomes[s.next] {
+		s.failures--
+	}
+
+	if failed {
+		s.failures++
+	}
+
+	s.outcomes[s.next] = failed
+	s.next = (s.next + 1) % len(s.outcomes)
+
+	if s.recorded < len(s.outcomes) {
+		s.recorded++
+	}
+}
+
+// recordCache records a DecisionCache lookup
+func (s *remoteStats) recordCache(hit bool) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if hit {
+		s.cacheHits++
+	} else {
+		s.cacheMisses++
+	}
+}
+
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// Stats returns the metrics of the requests made to the remote endpoint
+func (rm *RemoteMiddleware) Stats() RemoteStats {
+	s := rm.stats
+	if s == nil {
+		return RemoteStats{}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := RemoteStats{
+		Requests:       make(map[int]uint64, len(s.requests)),
+		Timeouts:       s.timeouts,
+		LatencyBuckets: DefaultRemoteLatencyBuckets,
+		LatencyCounts:  append([]uint64(nil), s.latencies...),
+		LatencySum:     s.latencySum,
+		Failures:       s.failures,
+		Window:         s.recorded,
+		CacheHits:      s.cacheHits,
+		CacheMisses:    s.cacheMisses,
+	}
+
+	for status, count := range s.requests {
+		stats.Requests[status] = count
+	}
+
+	return stats
+}
+
+// Healthy returns ErrRemoteUnhealthy when the remote endpoint failed more of the latest
+// requests than the error budget allows, it can be used as a health or readiness probe.
+func (rm *RemoteMiddleware) Healthy(_ context.Context) error {
+	s := rm.stats
+	if s == nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.recorded == 0 {
+		return nil
+	}
+
+	if ratio := float64(s.failures) / float64(s.recorded); ratio > s.budget {
+		return fmt.Errorf("%w: %d of the last %d requests failed", ErrRemoteUnhealthy, s.failures, s.recorded)
+	}
+
+	return nil
+}
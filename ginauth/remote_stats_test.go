@@ -0,0 +1,79 @@
+package ginauth_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"go.hollow.sh/toolbox/ginauth"
+)
+
+func TestRemoteMiddlewareStats(t *testing.T) {
+	var status int32 = http.StatusOK
+
+	authSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		code := int(atomic.LoadInt32(&status))
+		if code == http.StatusGatewayTimeout {
+			time.Sleep(100 * time.Millisecond)
+		}
+
+		w.WriteHeader(code)
+
+		_ = json.NewEncoder(w).Encode(ginauth.AuthResponseV1{
+			AuthMeta: ginauth.AuthMeta{Version: "v1"},
+			Authed:   code == http.StatusOK,
+			Details:  &ginauth.SuccessAuthDetailsV1{Subject: "foo"},
+		})
+	}))
+	defer authSrv.Close()
+
+	rm := ginauth.NewRemoteMiddleware(authSrv.URL, 50*time.Millisecond, ginauth.WithErrorBudget(0.25, 4))
+
+	call := func(code int) {
+		atomic.StoreInt32(&status, int32(code))
+
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "http://test/", nil)
+
+		_, _ = rm.VerifyTokenWithScopes(c, []string{"read"})
+	}
+
+	assert.NoError(t, rm.Healthy(context.TODO()))
+
+	call(http.StatusOK)
+	call(http.StatusForbidden)
+	call(http.StatusInternalServerError)
+
+	// 1 failure in 3 requests is past the budget
+	assert.ErrorIs(t, rm.Healthy(context.TODO()), ginauth.ErrRemoteUnhealthy)
+
+	call(http.StatusGatewayTimeout)
+
+	stats := rm.Stats()
+	assert.Equal(t, map[int]uint64{
+		http.StatusOK:                  1,
+		http.StatusForbidden:           1,
+		http.StatusInternalServerError: 1,
+		0:                              1,
+	}, stats.Requests)
+	assert.Equal(t, uint64(1), stats.Timeouts)
+	assert.Equal(t, 2, stats.Failures)
+	assert.Equal(t, 4, stats.Window)
+	assert.Equal(t, uint64(4), stats.LatencyCounts[len(stats.LatencyCounts)-1])
+	assert.Len(t, stats.LatencyCounts, len(stats.LatencyBuckets)+1)
+
+	// failures roll out of the window
+	for i := 0; i < 4; i++ {
+		call(http.StatusOK)
+	}
+
+	assert.NoError(t, rm.Healthy(context.TODO()))
+	assert.Equal(t, 0, rm.Stats().Failures)
+}
@@ -1,6 +1,7 @@
 package ginauth_test
 
 import (
+	"encoding/json"
 	"fmt"
 	"net"
 	"net/http"
@@ -10,6 +11,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
 	"go.hollow.sh/toolbox/ginauth"
 )
@@ -130,3 +132,90 @@ func TestRemoteMiddleware(t *testing.T) {
 		})
 	}
 }
+
+func TestRemoteMiddlewareClientInfo(t *testing.T) {
+	tests := []struct {
+		name      string
+		opts      []ginauth.RemoteOption
+		expectReq ginauth.AuthRequestV2
+	}{
+		{
+			"v1 without client info",
+			nil,
+			ginauth.AuthRequestV2{
+				AuthMeta: ginauth.AuthMeta{Version: ginauth.AuthRequestVersion1},
+				Scopes:   []string{"read"},
+			},
+		},
+		{
+			"client info",
+			[]ginauth.RemoteOption{ginauth.WithClientInfo(ginauth.ClientInfoRedaction{})},
+			ginauth.AuthRequestV2{
+				AuthMeta: ginauth.AuthMeta{Version: ginauth.AuthRequestVersion2},
+				Scopes:   []string{"read"},
+				Client: &ginauth.ClientInfoV2{
+					IP:           "2001:db8:1:2::1",
+					ForwardedFor: []string{"203.0.113.7", "2001:db8:1:2::1"},
+					UserAgent:    "test-agent",
+					Method:       http.MethodGet,
+					Path:         "/servers/1234",
+				},
+			},
+		},
+		{
+			"redacted client info",
+			[]ginauth.RemoteOption{ginauth.WithClientInfo(ginauth.ClientInfoRedaction{
+				TruncateIPs:   true,
+				OmitUserAgent: true,
+				RoutePath:     true,
+			})},
+			ginauth.AuthRequestV2{
+				AuthMeta: ginauth.AuthMeta{Version: ginauth.AuthRequestVersion2},
+				Scopes:   []string{"read"},
+				Client: &ginauth.ClientInfoV2{
+					IP:           "2001:db8:1::",
+					ForwardedFor: []string{"203.0.113.0", "2001:db8:1::"},
+					Method:       http.MethodGet,
+					Path:         "/servers/:id",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got ginauth.AuthRequestV2
+
+			authSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&got))
+
+				_ = json.NewEncoder(w).Encode(ginauth.AuthResponseV1{
+					AuthMeta: ginauth.AuthMeta{Version: "v1"},
+					Authed:   true,
+					Details:  &ginauth.SuccessAuthDetailsV1{Subject: "foo"},
+				})
+			}))
+			defer authSrv.Close()
+
+			rm := ginauth.NewRemoteMiddleware(authSrv.URL, time.Second, tt.opts...)
+
+			r := gin.New()
+			require.NoError(t, r.SetTrustedProxies([]string{"192.0.2.1"}))
+
+			r.GET("/servers/:id", rm.AuthRequired([]string{"read"}), func(c *gin.Context) {
+				c.JSON(http.StatusOK, "ok")
+			})
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "http://test/servers/1234", nil)
+			req.RemoteAddr = "192.0.2.1:1234"
+			req.Header.Set("X-Forwarded-For", "203.0.113.7, 2001:db8:1:2::1")
+			req.Header.Set("User-Agent", "test-agent")
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.expectReq, got)
+		})
+	}
+}
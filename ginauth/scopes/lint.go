@@ -0,0 +1,95 @@
+package scopes
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Route is a route registration and the scopes it requires, any of which grants access.
+type Route struct {
+	Method string
+	Path   string
+	Scopes []string
+}
+
+// Problem is a scope naming issue found by Lint.
+type Problem struct {
+	Method string
+	Path   string
+	// Scope is the offending scope, empty when the problem is with the route itself
+	Scope  string
+	Reason string
+}
+
+// String formats the problem for test failures.
+func (p Problem) String() string {
+	if p.Scope == "" {
+		return fmt.Sprintf("%s %s: %s", p.Method, p.Path, p.Reason)
+	}
+
+	return fmt.Sprintf("%s %s: scope %q: %s", p.Method, p.Path, p.Scope, p.Reason)
+}
+
+// methodActions are the actions scopes may grant for the routes of each method.
+var methodActions = map[string][]string{
+	http.MethodGet:    {ActionRead},
+	http.MethodHead:   {ActionRead},
+	http.MethodPost:   {ActionCreate, ActionWrite},
+	http.MethodPut:    {ActionUpdate, ActionWrite},
+	http.MethodPatch:  {ActionUpdate, ActionWrite},
+	http.MethodDelete: {ActionDelete, ActionWrite},
+}
+
+// Lint checks the scopes required by the routes follow the naming conventions, services
+// run it in their tests against their route registrations:
+//
+//	assert.Empty(t, scopes.Lint(
+//		scopes.Route{Method: http.MethodGet, Path: "/servers", Scopes: ginjwt.ReadScopes("server")},
+//		scopes.Route{Method: http.MethodPost, Path: "/servers", Scopes: ginjwt.CreateScopes("server")},
+//	))
+//
+// Routes must require at least one scope, every scope must follow the grammar, and the action of
+// the scopes must match the route method: read for GET and HEAD, create for POST, update for PUT
+// and PATCH and delete for DELETE, write being accepted for all but GET and HEAD.
+// Routes with other methods are only checked against the grammar.
+func Lint(routes ...Route) []Problem {
+	var problems []Problem
+
+	for _, r := range routes {
+		method := strings.ToUpper(r.Method)
+
+		if len(r.Scopes) == 0 {
+			problems = append(problems, Problem{Method: method, Path: r.Path, Reason: "no scopes required"})
+			continue
+		}
+
+		for _, scope := range r.Scopes {
+			if reason := lintScope(method, scope); reason != "" {
+				problems = append(problems, Problem{Method: method, Path: r.Path, Scope: scope, Reason: reason})
+			}
+		}
+	}
+
+	return problems
+}
+
+func lintScope(method, scope string) string {
+	s, err := Parse(scope)
+	if err != nil {
+		return err.Error()
+	}
+
+	actions, ok := methodActions[method]
+	if !ok {
+		return ""
+	}
+
+	for _, action := range actions {
+		if s.Action == action {
+			return ""
+		}
+	}
+
+	return fmt.Sprintf("action %q doesn't match the method, expected one of %s", s.Action, strings.Join(actions, ", "))
+}
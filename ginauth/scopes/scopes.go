@@ -0,0 +1,140 @@
+// Package scopes defines the canonical grammar of the scopes required by hollow services.
+//
+// A scope is an action, optionally followed by the resource it applies to and a qualifier
+// narrowing it further, separated by colons:
+//
+//	action[:resource[:qualifier]]
+//
+// e.g. read, read:server or update:server:bmc. A scope without a resource grants the action
+// on all the resources. Each part is lowercase, starts with a letter (the qualifier may start
+// with a digit), and may hold digits and single dashes, underscores or dots between them.
+package scopes
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Separator separates the parts of a scope.
+const Separator = ":"
+
+// The actions of the scopes returned by the ginjwt CreateScopes, ReadScopes, UpdateScopes and DeleteScopes helpers.
+const (
+	ActionCreate = "create"
+	ActionRead   = "read"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+	// ActionWrite grants the create, update and delete actions
+	ActionWrite = "write"
+)
+
+const maxParts = 3
+
+// ErrInvalidScope is the error returned when a scope doesn't follow the grammar.
+var ErrInvalidScope = errors.New("invalid scope")
+
+var (
+	namePattern      = regexp.MustCompile(`^[a-z][a-z0-9]*([-_.][a-z0-9]+)*$`)
+	qualifierPattern = regexp.MustCompile(`^[a-z0-9]+([-_.][a-z0-9]+)*$`)
+)
+
+// Scope is a parsed scope.
+type Scope struct {
+	Action    string
+	Resource  string
+	Qualifier string
+}
+
+// Parse parses the scope, an error wrapping ErrInvalidScope is returned when it doesn't follow the grammar.
+func Parse(scope string) (Scope, error) {
+	parts := strings.Split(scope, Separator)
+	if len(parts) > maxParts {
+		return Scope{}, fmt.Errorf("%w: %q has more than %d parts", ErrInvalidScope, scope, maxParts)
+	}
+
+	for _, part := range parts[1:] {
+		if part == "" {
+			return Scope{}, fmt.Errorf("%w: %q has an empty part", ErrInvalidScope, scope)
+		}
+	}
+
+	s := Scope{Action: parts[0]}
+
+	if len(parts) > 1 {
+		s.Resource = parts[1]
+	}
+
+	if len(parts) == maxParts {
+		s.Qualifier = parts[maxParts-1]
+	}
+
+	if err := s.Validate(); err != nil {
+		return Scope{}, err
+	}
+
+	return s, nil
+}
+
+// MustParse parses the scope, panicking when it doesn't follow the grammar.
+func MustParse(scope string) Scope {
+	s, err := Parse(scope)
+	if err != nil {
+		panic(err)
+	}
+
+	return s
+}
+
+// Validate ensures the scope follows the grammar.
+func (s Scope) Validate() error {
+	if !namePattern.MatchString(s.Action) {
+		return fmt.Errorf("%w: %q: invalid action %q", ErrInvalidScope, s.String(), s.Action)
+	}
+
+	if s.Resource == "" && s.Qualifier != "" {
+		return fmt.Errorf("%w: %q: qualifier without a resource", ErrInvalidScope, s.String())
+	}
+
+	if s.Resource != "" && !namePattern.MatchString(s.Resource) {
+		return fmt.Errorf("%w: %q: invalid resource %q", ErrInvalidScope, s.String(), s.Resource)
+	}
+
+	if s.Qualifier != "" && !qualifierPattern.MatchString(s.Qualifier) {
+		return fmt.Errorf("%w: %q: invalid qualifier %q", ErrInvalidScope, s.String(), s.Qualifier)
+	}
+
+	return nil
+}
+
+// String formats the scope.
+func (s Scope) String() string {
+	parts := []string{s.Action}
+
+	if s.Resource != "" || s.Qualifier != "" {
+		parts = append(parts, s.Resource)
+	}
+
+	if s.Qualifier != "" {
+		parts = append(parts, s.Qualifier)
+	}
+
+	return strings.Join(parts, Separator)
+}
+
+// Format returns the scope for the action on the resource, all the resources when it's empty.
+func Format(action, resource string) string {
+	return Scope{Action: action, Resource: resource}.String()
+}
+
+// Validate ensures all the scopes follow the grammar, returning the error of the first one which doesn't.
+func Validate(scopes ...string) error {
+	for _, scope := range scopes {
+		if _, err := Parse(scope); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package scopes_test
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.hollow.sh/toolbox/ginauth/scopes"
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+func TestParse(t *testing.T) {
+	testCases := []struct {
+		scope   string
+		want    scopes.Scope
+		wantErr string
+	}{
+		{"read", scopes.Scope{Action: "read"}, ""},
+		{"read:server", scopes.Scope{Action: "read", Resource: "server"}, ""},
+		{"update:server-component:bmc", scopes.Scope{Action: "update", Resource: "server-component", Qualifier: "bmc"}, ""},
+		{"read:server:0f6d3c1e-1d0a-4c3e", scopes.Scope{Action: "read", Resource: "server", Qualifier: "0f6d3c1e-1d0a-4c3e"}, ""},
+		{"", scopes.Scope{}, "invalid action"},
+		{"Read:server", scopes.Scope{}, "invalid action"},
+		{"read:", scopes.Scope{}, "empty part"},
+		{"read:servers--all", scopes.Scope{}, "invalid resource"},
+		{"read:server:", scopes.Scope{}, "empty part"},
+		{"read:server:BMC", scopes.Scope{}, "invalid qualifier"},
+		{"read:server:bmc:extra", scopes.Scope{}, "more than 3 parts"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.scope, func(t *testing.T) {
+			s, err := scopes.Parse(tt.scope)
+			if tt.wantErr != "" {
+				assert.ErrorIs(t, err, scopes.ErrInvalidScope)
+				assert.ErrorContains(t, err, tt.wantErr)
+
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, s)
+			assert.Equal(t, tt.scope, s.String())
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	assert.Equal(t, "read", scopes.Format(scopes.ActionRead, ""))
+	assert.Equal(t, "delete:server", scopes.Format(scopes.ActionDelete, "server"))
+	assert.Equal(t, "update:server:bmc", scopes.Scope{Action: scopes.ActionUpdate, Resource: "server", Qualifier: "bmc"}.String())
+
+	assert.NoError(t, scopes.Validate(ginjwt.CreateScopes("server", "server-component")...))
+	assert.ErrorIs(t, scopes.Validate("read", "read:Server"), scopes.ErrInvalidScope)
+}
+
+func TestLint(t *testing.T) {
+	problems := scopes.Lint(
+		scopes.Route{Method: http.MethodGet, Path: "/servers", Scopes: ginjwt.ReadScopes("server")},
+		scopes.Route{Method: http.MethodPost, Path: "/servers", Scopes: ginjwt.CreateScopes("server")},
+		scopes.Route{Method: http.MethodPut, Path: "/servers/:id", Scopes: ginjwt.UpdateScopes("server")},
+		scopes.Route{Method: http.MethodDelete, Path: "/servers/:id", Scopes: ginjwt.DeleteScopes("server")},
+		scopes.Route{Method: "get", Path: "/firmwares", Scopes: []string{"write", "read:Firmware"}},
+		scopes.Route{Method: http.MethodPatch, Path: "/firmwares/:id"},
+		scopes.Route{Method: http.MethodOptions, Path: "/firmwares", Scopes: []string{"cors:firmware"}},
+	)
+
+	require.Len(t, problems, 3)
+
+	assert.Equal(t, scopes.Problem{
+		Method: http.MethodGet,
+		Path:   "/firmwares",
+		Scope:  "write",
+		Reason: `action "write" doesn't match the method, expected one of read`,
+	}, problems[0])
+	assert.Equal(t, "read:Firmware", problems[1].Scope)
+	assert.Contains(t, problems[1].Reason, "invalid resource")
+	assert.Equal(t, `PATCH /firmwares/:id: no scopes required`, problems[2].String())
+}
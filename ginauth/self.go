@@ -0,0 +1,47 @@
+package ginauth
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SubjectMatchFunc returns true when the authenticated subject is the one named by the URL parameter value
+type SubjectMatchFunc func(subject, param string) bool
+
+// ExactSubjectMatch is the SubjectMatchFunc matching subjects equal to the URL parameter value
+func ExactSubjectMatch(subject, param string) bool {
+	return subject == param
+}
+
+// RequireSelfOrScope provides a middleware that only lets through requests whose authenticated
+// subject matches the named URL parameter exactly, or holding any of the admin scopes. e.g.
+//
+//	router.GET("/users/:id", authMW.AuthRequired(nil), ginauth.RequireSelfOrScope("id", "users:admin"))
+//
+// The subject and roles are taken from the metadata set in the gin Context by the auth middleware,
+// so its handler needs to come after the AuthRequired one.
+func RequireSelfOrScope(param string, adminScopes ...string) gin.HandlerFunc {
+	return RequireSelfOrScopeFunc(param, ExactSubjectMatch, adminScopes...)
+}
+
+// RequireSelfOrScopeFunc is RequireSelfOrScope comparing the subject to the URL parameter with the given function
+func RequireSelfOrScopeFunc(param string, match SubjectMatchFunc, adminScopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subject := c.GetString(contextKeySubject)
+		if subject == "" {
+			AbortBecauseOfError(c, NewAuthenticationError("missing authenticated subject"))
+			return
+		}
+
+		if value := c.Param(param); value != "" && match(subject, value) {
+			return
+		}
+
+		if len(adminScopes) > 0 && hasAnyRole(c.GetStringSlice(contextKeyRoles), adminScopes) {
+			return
+		}
+
+		AbortBecauseOfError(c, NewAuthorizationError(fmt.Sprintf("not authorized, subject doesn't match %s", param)))
+	}
+}
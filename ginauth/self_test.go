@@ -0,0 +1,58 @@
+package ginauth_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+
+	"go.hollow.sh/toolbox/ginauth"
+)
+
+func TestRequireSelfOrScope(t *testing.T) {
+	tests := []struct {
+		name         string
+		subject      string
+		roles        []string
+		path         string
+		match        ginauth.SubjectMatchFunc
+		responseCode int
+	}{
+		{"own resource", "user-1", nil, "/users/user-1", nil, http.StatusOK},
+		{"other resource", "user-1", []string{"read"}, "/users/user-2", nil, http.StatusForbidden},
+		{"other resource with admin scope", "user-1", []string{"users:admin"}, "/users/user-2", nil, http.StatusOK},
+		{"missing subject", "", []string{"users:admin"}, "/users/user-2", nil, http.StatusUnauthorized},
+		{"custom comparison", "User-1", nil, "/users/user-1", strings.EqualFold, http.StatusOK},
+		{"custom comparison mismatch", "User-1", nil, "/users/user-2", strings.EqualFold, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setMetadata := func(c *gin.Context) {
+				if tt.subject != "" {
+					c.Set("jwt.subject", tt.subject)
+				}
+
+				c.Set("jwt.roles", tt.roles)
+			}
+
+			requireSelf := ginauth.RequireSelfOrScope("id", "users:admin")
+			if tt.match != nil {
+				requireSelf = ginauth.RequireSelfOrScopeFunc("id", tt.match, "users:admin")
+			}
+
+			r := gin.New()
+			r.GET("/users/:id", setMetadata, requireSelf, func(c *gin.Context) {
+				c.JSON(http.StatusOK, "ok")
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", "http://test"+tt.path, nil))
+
+			assert.Equal(t, tt.responseCode, w.Code)
+		})
+	}
+}
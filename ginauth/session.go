@@ -0,0 +1,298 @@
+package ginauth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DefaultSessionCookieName is the cookie name used when none is configured
+	DefaultSessionCookieName = "hollow_session"
+
+	// DefaultSessionTTL is the session lifetime used when none is configured
+	DefaultSessionTTL = 15 * time.Minute
+)
+
+var (
+	// ErrInvalidSessionConfig is the error returned when the session configuration is invalid
+	ErrInvalidSessionConfig = errors.New("invalid session config")
+
+	// ErrInvalidSession is the error returned when a session cookie can't be decoded or has expired
+	ErrInvalidSession = errors.New("invalid session")
+)
+
+// SessionConfig provides the configuration for session cookies
+type SessionConfig struct {
+	// Key is used to encrypt and authenticate the session cookie. It must be
+	// 16, 24 or 32 bytes long to select AES-128, AES-192 or AES-256.
+	Key []byte
+	// CookieName is the name of the session cookie. Defaults to DefaultSessionCookieName.
+	CookieName string
+	// TTL is how long an issued session is valid for, it can't be negative. Defaults to DefaultSessionTTL.
+	TTL      time.Duration
+	Path     string
+	Domain   string
+	Secure   bool
+	SameSite http.SameSite
+}
+
+// SessionMiddleware wraps a GenericAuthMiddleware so that, once a token was
+// successfully verified, a short-lived session cookie holding the ClaimMetadata,
+// without the raw Claims of the token, is issued. Subsequent requests are accepted
+// with either the session cookie or a token the wrapped middleware accepts. Sessions
+// end when their token is revoked if the wrapped middleware implements RevocationChecker.
+type SessionMiddleware struct {
+	verifier GenericAuthMiddleware
+	config   SessionConfig
+	aead     cipher.AEAD
+	bypass   *BypassList
+	catalog  MessageCatalog
+}
+
+type sessionPayload struct {
+	Claims    ClaimMetadata `json:"claims"`
+	ExpiresAt time.Time     `json:"expires_at"`
+}
+
+// NewSessionMiddleware returns a SessionMiddleware issuing sessions for tokens verified by the given middleware
+func NewSessionMiddleware(verifier GenericAuthMiddleware, cfg SessionConfig) (*SessionMiddleware, error) {
+	if verifier == nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidMiddlewareReference, "The middleware reference can't be nil")
+	}
+
+	if cfg.CookieName == "" {
+		cfg.CookieName = DefaultSessionCookieName
+	}
+
+	if cfg.TTL < 0 {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSessionConfig, "the TTL can't be negative")
+	}
+
+	if cfg.TTL == 0 {
+		cfg.TTL = DefaultSessionTTL
+	}
+
+	if cfg.Path == "" {
+		cfg.Path = "/"
+	}
+
+	block, err := aes.NewCipher(cfg.Key)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSessionConfig, err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidSessionConfig, err)
+	}
+
+	return &SessionMiddleware{
+		verifier: verifier,
+		config:   cfg,
+		aead:     aead,
+	}, nil
+}
+
+// SetBypassList sets the requests which skip authentication, such as health checks
+func (sm *SessionMiddleware) SetBypassList(b *BypassList) {
+	sm.bypass = b
+}
+
+// SetMessageCatalog sets the catalog of the messages of the errors returned by AuthRequired
+func (sm *SessionMiddleware) SetMessageCatalog(catalog MessageCatalog) {
+	sm.catalog = catalog
+}
+
+// SetMetadata ensures metadata is set in the gin Context
+func (sm *SessionMiddleware) SetMetadata(c *gin.Context, cm ClaimMetadata) {
+	sm.verifier.SetMetadata(c, cm)
+}
+
+// VerifyTokenWithScopes verifies the session cookie from the gin Context against the given scopes.
+// The scopes are checked with the strategy of the wrapped middleware when it implements RoleVerifier,
+// otherwise any of them is enough. When the request has a bearer token, which may be for another
+// subject than the session, or there is no valid session, the token is verified with the wrapped
+// middleware and a new session cookie is issued on success.
+func (sm *SessionMiddleware) VerifyTokenWithScopes(c *gin.Context, scopes []string) (ClaimMetadata, error) {
+	if !hasBearerToken(c) {
+		if cm, err := sm.verifySession(c); err == nil && sm.verifyRoles(cm.Roles, scopes) == nil {
+			if err := sm.checkRevoked(c, cm.TokenID); err != nil {
+				return ClaimMetadata{}, err
+			}
+
+			return cm, nil
+		}
+	}
+
+	cm, err := sm.verifier.VerifyTokenWithScopes(c, scopes)
+	if err != nil {
+		return ClaimMetadata{}, err
+	}
+
+	if err := sm.IssueSession(c, cm); err != nil {
+		return ClaimMetadata{}, err
+	}
+
+	return cm, nil
+}
+
+// AuthRequired provides a middleware that ensures a request has authentication
+func (sm *SessionMiddleware) AuthRequired(scopes []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if sm.bypass.Bypass(c) {
+			return
+		}
+
+		cm, err := sm.VerifyTokenWithScopes(c, scopes)
+		if err != nil {
+			AbortWithMessageCatalog(c, err, sm.catalog)
+			return
+		}
+
+		sm.SetMetadata(c, cm)
+	}
+}
+
+// IssueSession sets a session cookie holding the given ClaimMetadata on the response, the session
+// expires after the TTL or when the token expires if earlier. No session is issued for expired tokens.
+func (sm *SessionMiddleware) IssueSession(c *gin.Context, cm ClaimMetadata) error {
+	expiresAt := time.Now().Add(sm.config.TTL)
+	if !cm.ExpiresAt.IsZero() && cm.ExpiresAt.Before(expiresAt) {
+		expiresAt = cm.ExpiresAt
+	}
+
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+
+	payload, err := json.Marshal(sessionPayload{
+		Claims:    cm,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSession, err)
+	}
+
+	nonce := make([]byte, sm.aead.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidSession, err)
+	}
+
+	sealed := sm.aead.Seal(nonce, nonce, payload, []byte(sm.config.CookieName))
+
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     sm.config.CookieName,
+		Value:    base64.RawURLEncoding.EncodeToString(sealed),
+		Path:     sm.config.Path,
+		Domain:   sm.config.Domain,
+		MaxAge:   int(math.Ceil(ttl.Seconds())),
+		Secure:   sm.config.Secure,
+		HttpOnly: true,
+		SameSite: sm.config.SameSite,
+	})
+
+	return nil
+}
+
+// ClearSession expires the session cookie on the response
+func (sm *SessionMiddleware) ClearSession(c *gin.Context) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     sm.config.CookieName,
+		Value:    "",
+		Path:     sm.config.Path,
+		Domain:   sm.config.Domain,
+		MaxAge:   -1,
+		Secure:   sm.config.Secure,
+		HttpOnly: true,
+		SameSite: sm.config.SameSite,
+	})
+}
+
+func (sm *SessionMiddleware) verifySession(c *gin.Context) (ClaimMetadata, error) {
+	cookie, err := c.Cookie(sm.config.CookieName)
+	if err != nil {
+		return ClaimMetadata{}, NewAuthenticationErrorFrom(ErrInvalidSession)
+	}
+
+	sealed, err := base64.RawURLEncoding.DecodeString(cookie)
+	if err != nil || len(sealed) < sm.aead.NonceSize() {
+		return ClaimMetadata{}, NewAuthenticationErrorFrom(ErrInvalidSession)
+	}
+
+	nonce, ciphertext := sealed[:sm.aead.NonceSize()], sealed[sm.aead.NonceSize():]
+
+	payload, err := sm.aead.Open(nil, nonce, ciphertext, []byte(sm.config.CookieName))
+	if err != nil {
+		return ClaimMetadata{}, NewAuthenticationErrorFrom(ErrInvalidSession)
+	}
+
+	var sp sessionPayload
+	if err := json.Unmarshal(payload, &sp); err != nil {
+		return ClaimMetadata{}, NewAuthenticationErrorFrom(ErrInvalidSession)
+	}
+
+	if time.Now().After(sp.ExpiresAt) {
+		return ClaimMetadata{}, NewAuthenticationErrorFrom(ErrInvalidSession)
+	}
+
+	return sp.Claims, nil
+}
+
+// checkRevoked checks the token of a session wasn't revoked when the wrapped middleware can tell
+func (sm *SessionMiddleware) checkRevoked(c *gin.Context, tokenID string) error {
+	if rc, ok := sm.verifier.(RevocationChecker); ok {
+		return rc.CheckRevoked(c, tokenID)
+	}
+
+	return nil
+}
+
+// verifyRoles checks the roles of a session against the scopes like the wrapped middleware does
+func (sm *SessionMiddleware) verifyRoles(roles, scopes []string) error {
+	if rv, ok := sm.verifier.(RoleVerifier); ok {
+		return rv.VerifyRoles(roles, scopes)
+	}
+
+	if !hasAnyRole(roles, scopes) {
+		return NewAuthorizationError("not authorized, missing required scope")
+	}
+
+	return nil
+}
+
+// hasBearerToken returns true when the request has a bearer token in the Authorization header
+func hasBearerToken(c *gin.Context) bool {
+	scheme, _, _ := strings.Cut(c.GetHeader("Authorization"), " ")
+
+	return strings.EqualFold(scheme, "bearer")
+}
+
+// hasAnyRole returns true when no scopes are needed or any of them is in the roles
+func hasAnyRole(roles, scopes []string) bool {
+	if len(scopes) == 0 {
+		return true
+	}
+
+	for _, s := range scopes {
+		for _, r := range roles {
+			if s == r {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,407 @@
+package ginauth_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.hollow.sh/toolbox/ginauth"
+)
+
+type stubVerifier struct {
+	calls int
+	cm    ginauth.ClaimMetadata
+}
+
+func (sv *stubVerifier) VerifyTokenWithScopes(c *gin.Context, _ []string) (ginauth.ClaimMetadata, error) {
+	sv.calls++
+
+	if c.Request.Header.Get("Authorization") != "bearer good" {
+		return ginauth.ClaimMetadata{}, ginauth.NewAuthenticationError("bad token")
+	}
+
+	return sv.cm, nil
+}
+
+func (sv *stubVerifier) SetMetadata(c *gin.Context, cm ginauth.ClaimMetadata) {
+	c.Set("jwt.subject", cm.Subject)
+}
+
+func TestSessionMiddleware(t *testing.T) {
+	sv := &stubVerifier{cm: ginauth.ClaimMetadata{Subject: "foo", User: "foo", Roles: []string{"read"}}}
+
+	sm, err := ginauth.NewSessionMiddleware(sv, ginauth.SessionConfig{
+		Key: []byte("0123456789abcdef0123456789abcdef"),
+		TTL: time.Minute,
+	})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.GET("/read", sm.AuthRequired([]string{"read"}), func(c *gin.Context) {
+		c.JSON(http.StatusOK, c.GetString("jwt.subject"))
+	})
+	r.GET("/write", sm.AuthRequired([]string{"write"}), func(c *gin.Context) {
+		c.JSON(http.StatusOK, "ok")
+	})
+
+	// authenticate with a bearer token, a session cookie is issued
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://test/read", nil)
+	req.Header.Set("Authorization", "bearer good")
+	r.ServeHTTP(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, w.Result().Cookies(), 1)
+
+	cookie := w.Result().Cookies()[0]
+	assert.Equal(t, ginauth.DefaultSessionCookieName, cookie.Name)
+	assert.True(t, cookie.HttpOnly)
+	assert.Equal(t, 1, sv.calls)
+
+	// the session cookie alone is enough
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "http://test/read", nil)
+	req.AddCookie(cookie)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "foo")
+	assert.Equal(t, 1, sv.calls)
+
+	// the session doesn't grant scopes it doesn't hold
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "http://test/write", nil)
+	req.AddCookie(cookie)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, 2, sv.calls)
+
+	// a tampered cookie is rejected
+	tamperedValue := []byte(cookie.Value)
+	if tamperedValue[20] == 'A' {
+		tamperedValue[20] = 'B'
+	} else {
+		tamperedValue[20] = 'A'
+	}
+
+	tampered := *cookie
+	tampered.Value = string(tamperedValue)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "http://test/read", nil)
+	req.AddCookie(&tampered)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestSessionMiddlewareExpiry(t *testing.T) {
+	sv := &stubVerifier{cm: ginauth.ClaimMetadata{Subject: "foo"}}
+
+	sm, err := ginauth.NewSessionMiddleware(sv, ginauth.SessionConfig{
+		Key: []byte("0123456789abcdef"),
+		TTL: time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.GET("/", sm.AuthRequired(nil), func(c *gin.Context) {
+		c.JSON(http.StatusOK, "ok")
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://test/", nil)
+	req.Header.Set("Authorization", "bearer good")
+	r.ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	cookie := w.Result().Cookies()[0]
+
+	time.Sleep(5 * time.Millisecond)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "http://test/", nil)
+	req.AddCookie(cookie)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// allRolesVerifier requires all the scopes, like a ginjwt middleware with the "all" strategy
+type allRolesVerifier struct {
+	stubVerifier
+}
+
+func (av *allRolesVerifier) VerifyRoles(roles, scopes []string) error {
+	for _, s := range scopes {
+		found := false
+
+		for _, r := range roles {
+			if r == s {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return ginauth.NewAuthorizationError("not authorized, missing required scope")
+		}
+	}
+
+	return nil
+}
+
+func (av *allRolesVerifier) VerifyTokenWithScopes(c *gin.Context, scopes []string) (ginauth.ClaimMetadata, error) {
+	cm, err := av.stubVerifier.VerifyTokenWithScopes(c, scopes)
+	if err != nil {
+		return cm, err
+	}
+
+	return cm, av.VerifyRoles(cm.Roles, scopes)
+}
+
+func TestSessionMiddlewareRoleStrategy(t *testing.T) {
+	av := &allRolesVerifier{stubVerifier{cm: ginauth.ClaimMetadata{Subject: "foo", Roles: []string{"read"}}}}
+
+	sm, err := ginauth.NewSessionMiddleware(av, ginauth.SessionConfig{
+		Key: []byte("0123456789abcdef"),
+		TTL: time.Minute,
+	})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.GET("/read", sm.AuthRequired([]string{"read"}), func(c *gin.Context) {
+		c.JSON(http.StatusOK, "ok")
+	})
+	r.GET("/readwrite", sm.AuthRequired([]string{"read", "write"}), func(c *gin.Context) {
+		c.JSON(http.StatusOK, "ok")
+	})
+
+	cookie := firstCookie(t, r, "/read")
+	assert.Equal(t, 1, av.calls)
+
+	// the session holds read only while the wrapped middleware requires all the scopes
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://test/readwrite", nil)
+	req.AddCookie(cookie)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, 2, av.calls)
+}
+
+// firstCookie authenticates a request to the path with a good token and returns the session cookie
+func firstCookie(t *testing.T, r http.Handler, path string) *http.Cookie {
+	t.Helper()
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://test"+path, nil)
+	req.Header.Set("Authorization", "bearer good")
+	r.ServeHTTP(w, req)
+
+	require.Len(t, w.Result().Cookies(), 1)
+
+	return w.Result().Cookies()[0]
+}
+
+func TestSessionMiddlewareTokenExpiry(t *testing.T) {
+	sv := &stubVerifier{cm: ginauth.ClaimMetadata{Subject: "foo", ExpiresAt: time.Now().Add(time.Second)}}
+
+	sm, err := ginauth.NewSessionMiddleware(sv, ginauth.SessionConfig{
+		Key: []byte("0123456789abcdef"),
+		TTL: time.Hour,
+	})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.GET("/", sm.AuthRequired(nil), func(c *gin.Context) {
+		c.JSON(http.StatusOK, "ok")
+	})
+
+	cookie := firstCookie(t, r, "/")
+
+	// the session doesn't outlive the token
+	assert.LessOrEqual(t, cookie.MaxAge, 1)
+
+	time.Sleep(1100 * time.Millisecond)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://test/", nil)
+	req.AddCookie(cookie)
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// expired tokens, e.g. accepted within the clock skew, don't get a session
+	sv.cm.ExpiresAt = time.Now().Add(-time.Second)
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "http://test/", nil)
+	req.Header.Set("Authorization", "bearer good")
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Result().Cookies())
+}
+
+func TestSessionMiddlewareBypassAndMessages(t *testing.T) {
+	sm, err := ginauth.NewSessionMiddleware(&stubVerifier{}, ginauth.SessionConfig{Key: []byte("0123456789abcdef")})
+	require.NoError(t, err)
+
+	bypass, err := ginauth.NewBypassList(nil, ginauth.BypassPath("/healthz"))
+	require.NoError(t, err)
+
+	sm.SetBypassList(bypass)
+	sm.SetMessageCatalog(ginauth.MessageCatalogFunc(func(_ *gin.Context, code ginauth.ErrorCode, _ error) (string, bool) {
+		return "please sign in", true
+	}))
+
+	r := gin.New()
+	r.GET("/healthz", sm.AuthRequired(nil), func(c *gin.Context) {
+		c.JSON(http.StatusOK, "ok")
+	})
+	r.GET("/", sm.AuthRequired(nil), func(c *gin.Context) {
+		c.JSON(http.StatusOK, "ok")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "http://test/healthz", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "http://test/", nil))
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "please sign in")
+}
+
+func TestSessionMiddlewareOmitsClaims(t *testing.T) {
+	groups, err := json.Marshal(strings.Repeat("group,", 1000))
+	require.NoError(t, err)
+
+	sv := &stubVerifier{cm: ginauth.ClaimMetadata{
+		Subject: "foo",
+		Claims:  map[string]json.RawMessage{"groups": groups},
+	}}
+
+	sm, err := ginauth.NewSessionMiddleware(sv, ginauth.SessionConfig{Key: []byte("0123456789abcdef")})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.GET("/", sm.AuthRequired(nil), func(c *gin.Context) {
+		c.JSON(http.StatusOK, "ok")
+	})
+
+	// the claims would exceed the size browsers accept for a cookie
+	cookie := firstCookie(t, r, "/")
+	assert.Less(t, len(cookie.Value), 512)
+}
+
+// revocableVerifier accepts the bearer tokens it holds and implements ginauth.RevocationChecker
+type revocableVerifier struct {
+	tokens  map[string]ginauth.ClaimMetadata
+	revoked map[string]bool
+}
+
+func (rv *revocableVerifier) VerifyTokenWithScopes(c *gin.Context, _ []string) (ginauth.ClaimMetadata, error) {
+	cm, ok := rv.tokens[strings.TrimPrefix(c.Request.Header.Get("Authorization"), "bearer ")]
+	if !ok || rv.revoked[cm.TokenID] {
+		return ginauth.ClaimMetadata{}, ginauth.NewAuthenticationError("bad token")
+	}
+
+	return cm, nil
+}
+
+func (rv *revocableVerifier) SetMetadata(c *gin.Context, cm ginauth.ClaimMetadata) {
+	c.Set("jwt.subject", cm.Subject)
+}
+
+func (rv *revocableVerifier) CheckRevoked(_ *gin.Context, tokenID string) error {
+	if rv.revoked[tokenID] {
+		return ginauth.NewAuthenticationError("token revoked")
+	}
+
+	return nil
+}
+
+func TestSessionMiddlewareBearerToken(t *testing.T) {
+	rv := &revocableVerifier{
+		tokens: map[string]ginauth.ClaimMetadata{
+			"alice": {Subject: "alice", TokenID: "alice-jti"},
+			"bob":   {Subject: "bob", TokenID: "bob-jti"},
+		},
+		revoked: map[string]bool{},
+	}
+
+	sm, err := ginauth.NewSessionMiddleware(rv, ginauth.SessionConfig{Key: []byte("0123456789abcdef")})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.GET("/", sm.AuthRequired(nil), func(c *gin.Context) {
+		c.JSON(http.StatusOK, c.GetString("jwt.subject"))
+	})
+
+	request := func(cookie *http.Cookie, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://test/", nil)
+
+		if cookie != nil {
+			req.AddCookie(cookie)
+		}
+
+		if token != "" {
+			req.Header.Set("Authorization", "bearer "+token)
+		}
+
+		r.ServeHTTP(w, req)
+
+		return w
+	}
+
+	w := request(nil, "alice")
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Len(t, w.Result().Cookies(), 1)
+
+	cookie := w.Result().Cookies()[0]
+
+	// the bearer token is used rather than the session of another subject
+	w = request(cookie, "bob")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "bob")
+	require.Len(t, w.Result().Cookies(), 1)
+
+	// and a bad one isn't made up for by the session
+	w = request(cookie, "mallory")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = request(cookie, "")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "alice")
+
+	// the session ends with its token
+	rv.revoked["alice-jti"] = true
+
+	w = request(cookie, "")
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Body.String(), "token revoked")
+}
+
+func TestSessionMiddlewareConfig(t *testing.T) {
+	_, err := ginauth.NewSessionMiddleware(&stubVerifier{}, ginauth.SessionConfig{Key: []byte("short")})
+	assert.ErrorIs(t, err, ginauth.ErrInvalidSessionConfig)
+
+	_, err = ginauth.NewSessionMiddleware(&stubVerifier{}, ginauth.SessionConfig{Key: []byte("0123456789abcdef"), TTL: -time.Minute})
+	assert.ErrorIs(t, err, ginauth.ErrInvalidSessionConfig)
+
+	_, err = ginauth.NewSessionMiddleware(nil, ginauth.SessionConfig{Key: []byte("0123456789abcdef")})
+	assert.ErrorIs(t, err, ginauth.ErrInvalidMiddlewareReference)
+}
@@ -0,0 +1,122 @@
+package ginjwt
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"strings"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+// ErrInvalidSigningAlgorithm is the error returned when the token isn't signed with an allowed
+// algorithm, or with an algorithm the key it references can't verify
+var ErrInvalidSigningAlgorithm = errors.New("invalid JWT signing algorithm")
+
+// DefaultAllowedAlgorithms are the signature algorithms of the tokens accepted by the middleware,
+// AuthConfig.AllowedAlgorithms restricts them further.
+//
+// Only asymmetric algorithms are allowed: tokens are verified with the public keys of the JWKS,
+// accepting HS256 and other HMAC algorithms would let anyone holding the public key, which is
+// public, sign tokens with it (algorithm confusion). Unsigned tokens, alg=none, are never accepted.
+var DefaultAllowedAlgorithms = []jose.SignatureAlgorithm{
+	jose.RS256, jose.RS384, jose.RS512,
+	jose.PS256, jose.PS384, jose.PS512,
+	jose.ES256, jose.ES384, jose.ES512,
+	jose.EdDSA,
+}
+
+// ecdsaCurves are the curves of the keys verifying each ECDSA algorithm, per RFC 7518 section 3.4
+var ecdsaCurves = map[string]string{
+	string(jose.ES256): "P-256",
+	string(jose.ES384): "P-384",
+	string(jose.ES512): "P-521",
+}
+
+// allowedAlgorithms returns the algorithms of the AllowedAlgorithms, DefaultAllowedAlgorithms when
+// unset. Only the DefaultAllowedAlgorithms may be allowed, HMAC algorithms and none never are.
+func allowedAlgorithms(algs []string) ([]string, error) {
+	if len(algs) == 0 {
+		algs = make([]string, len(DefaultAllowedAlgorithms))
+
+		for i, alg := range DefaultAllowedAlgorithms {
+			algs[i] = string(alg)
+		}
+
+		return algs, nil
+	}
+
+	for _, alg := range algs {
+		if !isDefaultAllowedAlgorithm(alg) {
+			return nil, fmt.Errorf("%w: signing algorithm %q can't be allowed", ErrInvalidAuthConfig, alg)
+		}
+	}
+
+	return append([]string(nil), algs...), nil
+}
+
+func isDefaultAllowedAlgorithm(alg string) bool {
+	for _, allowed := range DefaultAllowedAlgorithms {
+		if alg == string(allowed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// verifyTokenAlgorithm rejects unsigned tokens and tokens signed with an algorithm not allowed,
+// it is checked before looking the key up so such tokens can't trigger JWKS refreshes
+func verifyTokenAlgorithm(alg string, allowed []string) error {
+	if alg == "" || strings.EqualFold(alg, "none") {
+		return fmt.Errorf("%w: unsigned tokens aren't accepted", ErrInvalidSigningAlgorithm)
+	}
+
+	if containsString(allowed, alg) {
+		return nil
+	}
+
+	return fmt.Errorf("%w: %s isn't allowed", ErrInvalidSigningAlgorithm, alg)
+}
+
+// verifyKeyAlgorithm rejects tokens signed with an algorithm the key isn't meant for, either
+// because the key sets another alg or use, or because the key type or curve can't verify such signatures
+func verifyKeyAlgorithm(alg string, key *jose.JSONWebKey) error {
+	if key.Algorithm != "" && key.Algorithm != alg {
+		return fmt.Errorf("%w: key %s is for %s, token is signed with %s", ErrInvalidSigningAlgorithm, key.KeyID, key.Algorithm, alg)
+	}
+
+	if key.Use != "" && key.Use != "sig" {
+		return fmt.Errorf("%w: key %s is for %s, not signatures", ErrInvalidSigningAlgorithm, key.KeyID, key.Use)
+	}
+
+	var family string
+
+	switch k := key.Public().Key.(type) {
+	case *rsa.PublicKey:
+		if strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS") {
+			return nil
+		}
+
+		family = "RSA"
+	case *ecdsa.PublicKey:
+		curve, ok := ecdsaCurves[alg]
+		if ok && curve == k.Curve.Params().Name {
+			return nil
+		}
+
+		family = "EC " + k.Curve.Params().Name
+	case ed25519.PublicKey:
+		if alg == string(jose.EdDSA) {
+			return nil
+		}
+
+		family = "OKP"
+	default:
+		family = fmt.Sprintf("%T", k)
+	}
+
+	return fmt.Errorf("%w: %s key %s can't verify %s signatures", ErrInvalidSigningAlgorithm, family, key.KeyID, alg)
+}
@@ -0,0 +1,294 @@
+package ginjwt_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"go.hollow.sh/toolbox/ginauth"
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+func TestVerifyTokenRejectsUnsafeAlgorithms(t *testing.T) {
+	claims := jwt.Claims{
+		Subject:   "test-user",
+		Issuer:    "ginjwt.test.issuer",
+		Audience:  jwt.Audience{"ginjwt.test"},
+		NotBefore: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+	}
+
+	// the RSA public key as a verifier confused into HMAC would use it as the secret
+	pubDER, err := x509.MarshalPKIXPublicKey(&ginjwt.TestPrivRSAKey1.PublicKey)
+	require.NoError(t, err)
+
+	pubPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER})
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	sign := func(alg jose.SignatureAlgorithm, kid string, key interface{}) string {
+		return ginjwt.TestHelperGetToken(ginjwt.TestHelperMustMakeSigner(alg, kid, key), claims, "scope", "read")
+	}
+
+	testCases := []struct {
+		testName string
+		token    string
+		wantErr  bool
+	}{
+		{"RS256 accepted", sign(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1), false},
+		{"PS256 accepted", sign(jose.PS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1), false},
+		{"alg none", unsignedToken(t, "none", claims), true},
+		{"alg None", unsignedToken(t, "None", claims), true},
+		{"alg empty", unsignedToken(t, "", claims), true},
+		{"HS256 with the RSA public key PEM", sign(jose.HS256, ginjwt.TestPrivRSAKey1ID, pubPEM), true},
+		{"HS256 with the RSA public key DER", sign(jose.HS256, ginjwt.TestPrivRSAKey1ID, pubDER), true},
+		{"HS512 with the RSA modulus", sign(jose.HS512, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1.PublicKey.N.Bytes()), true},
+		{"ES256 for an RSA key", sign(jose.ES256, ginjwt.TestPrivRSAKey1ID, ecKey), true},
+	}
+
+	authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:  true,
+		Audience: "ginjwt.test",
+		Issuer:   "ginjwt.test.issuer",
+		JWKS:     ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+	})
+	require.NoError(t, err)
+
+	for _, tt := range testCases {
+		t.Run(tt.testName, func(t *testing.T) {
+			_, err := authMW.VerifyToken(tokenContext(tt.token))
+			if !tt.wantErr {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorContains(t, err, ginjwt.ErrInvalidSigningAlgorithm.Error())
+			assert.ErrorIs(t, err, ginauth.ErrAuthentication)
+		})
+	}
+}
+
+func TestVerifyTokenKeyAlgorithm(t *testing.T) {
+	jwks := ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID)
+	jwks.Keys[0].Algorithm = string(jose.RS512)
+
+	authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:  true,
+		Audience: "ginjwt.test",
+		Issuer:   "ginjwt.test.issuer",
+		JWKS:     jwks,
+	})
+	require.NoError(t, err)
+
+	claims := jwt.Claims{
+		Subject:  "test-user",
+		Issuer:   "ginjwt.test.issuer",
+		Audience: jwt.Audience{"ginjwt.test"},
+	}
+
+	// the key is only meant to verify RS512 signatures
+	rs256 := ginjwt.TestHelperGetToken(ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1), claims, "scope", "read")
+	_, err = authMW.VerifyToken(tokenContext(rs256))
+	assert.ErrorContains(t, err, ginjwt.ErrInvalidSigningAlgorithm.Error())
+
+	rs512 := ginjwt.TestHelperGetToken(ginjwt.TestHelperMustMakeSigner(jose.RS512, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1), claims, "scope", "read")
+	_, err = authMW.VerifyToken(tokenContext(rs512))
+	assert.NoError(t, err)
+}
+
+func TestVerifyTokenECAndEdDSA(t *testing.T) {
+	claims := jwt.Claims{
+		Subject:   "test-user",
+		Issuer:    "ginjwt.test.issuer",
+		Audience:  jwt.Audience{"ginjwt.test"},
+		NotBefore: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+	}
+
+	sign := func(alg jose.SignatureAlgorithm, kid string, key interface{}) string {
+		return ginjwt.TestHelperGetToken(ginjwt.TestHelperMustMakeSigner(alg, kid, key), claims, "scope", "read")
+	}
+
+	keyIDs := []string{ginjwt.TestPrivECKey1ID, ginjwt.TestPrivECKey2ID, ginjwt.TestPrivEdKey1ID}
+
+	otherP256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		testName string
+		token    string
+		wantErr  bool
+	}{
+		{"ES256", sign(jose.ES256, ginjwt.TestPrivECKey1ID, ginjwt.TestPrivECKey1), false},
+		{"ES384", sign(jose.ES384, ginjwt.TestPrivECKey2ID, ginjwt.TestPrivECKey2), false},
+		{"EdDSA", sign(jose.EdDSA, ginjwt.TestPrivEdKey1ID, ginjwt.TestPrivEdKey1), false},
+		{"ES256 for a P-384 key", sign(jose.ES256, ginjwt.TestPrivECKey2ID, otherP256), true},
+		{"ES384 for a P-256 key", sign(jose.ES384, ginjwt.TestPrivECKey1ID, ginjwt.TestPrivECKey2), true},
+		{"EdDSA for an EC key", sign(jose.EdDSA, ginjwt.TestPrivECKey1ID, ginjwt.TestPrivEdKey1), true},
+		{"ES256 for an Ed25519 key", sign(jose.ES256, ginjwt.TestPrivEdKey1ID, ginjwt.TestPrivECKey1), true},
+	}
+
+	configs := map[string]ginjwt.AuthConfig{
+		"static JWKS": {JWKS: ginjwt.TestHelperJoseJWKSProvider(keyIDs...)},
+		"JWKS URI":    {JWKSURI: ginjwt.TestHelperJWKSProvider(keyIDs...)},
+	}
+
+	for name, cfg := range configs {
+		cfg.Enabled = true
+		cfg.Audience = "ginjwt.test"
+		cfg.Issuer = "ginjwt.test.issuer"
+
+		authMW, err := ginjwt.NewAuthMiddleware(cfg)
+		require.NoError(t, err)
+
+		for _, tt := range testCases {
+			t.Run(name+"/"+tt.testName, func(t *testing.T) {
+				_, err := authMW.VerifyToken(tokenContext(tt.token))
+				if !tt.wantErr {
+					assert.NoError(t, err)
+					return
+				}
+
+				assert.ErrorContains(t, err, ginjwt.ErrInvalidSigningAlgorithm.Error())
+			})
+		}
+	}
+}
+
+func TestVerifyTokenKeyUse(t *testing.T) {
+	jwks := ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivECKey1ID)
+	jwks.Keys[0].Use = "enc"
+
+	authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:  true,
+		Audience: "ginjwt.test",
+		Issuer:   "ginjwt.test.issuer",
+		JWKS:     jwks,
+	})
+	require.NoError(t, err)
+
+	claims := jwt.Claims{
+		Subject:  "test-user",
+		Issuer:   "ginjwt.test.issuer",
+		Audience: jwt.Audience{"ginjwt.test"},
+	}
+
+	// the key is only meant to encrypt
+	token := ginjwt.TestHelperGetToken(ginjwt.TestHelperMustMakeSigner(jose.ES256, ginjwt.TestPrivECKey1ID, ginjwt.TestPrivECKey1), claims, "scope", "read")
+	_, err = authMW.VerifyToken(tokenContext(token))
+	assert.ErrorContains(t, err, ginjwt.ErrInvalidSigningAlgorithm.Error())
+}
+
+func TestAllowedAlgorithms(t *testing.T) {
+	claims := jwt.Claims{
+		Subject:  "test-user",
+		Issuer:   "ginjwt.test.issuer",
+		Audience: jwt.Audience{"ginjwt.test"},
+	}
+
+	sign := func(alg jose.SignatureAlgorithm, kid string, key interface{}) string {
+		return ginjwt.TestHelperGetToken(ginjwt.TestHelperMustMakeSigner(alg, kid, key), claims, "scope", "read")
+	}
+
+	jwks := ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivECKey1ID)
+
+	authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:           true,
+		Audience:          "ginjwt.test",
+		Issuer:            "ginjwt.test.issuer",
+		JWKS:              jwks,
+		AllowedAlgorithms: []string{"ES256", "PS256"},
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		testName string
+		token    string
+		wantErr  bool
+	}{
+		{"ES256 allowed", sign(jose.ES256, ginjwt.TestPrivECKey1ID, ginjwt.TestPrivECKey1), false},
+		{"PS256 allowed", sign(jose.PS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1), false},
+		{"RS256 supported by the key but not allowed", sign(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1), true},
+		{"alg none", unsignedToken(t, "none", claims), true},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.testName, func(t *testing.T) {
+			_, err := authMW.VerifyToken(tokenContext(tt.token))
+			if !tt.wantErr {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorContains(t, err, ginjwt.ErrInvalidSigningAlgorithm.Error())
+		})
+	}
+
+	// only the asymmetric algorithms may be allowed
+	for _, alg := range []string{"HS256", "none", "", "RS1"} {
+		_, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+			Enabled:           true,
+			Audience:          "ginjwt.test",
+			Issuer:            "ginjwt.test.issuer",
+			JWKS:              jwks,
+			AllowedAlgorithms: []string{"RS256", alg},
+		})
+		assert.ErrorIs(t, err, ginjwt.ErrInvalidAuthConfig, alg)
+	}
+}
+
+func TestUnsafeAlgorithmsDontRefreshJWKS(t *testing.T) {
+	srv, _, fetches := newRotatingJWKSServer(t, ginjwt.TestPrivRSAKey1ID)
+
+	authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:       true,
+		Audience:      "ginjwt.test",
+		Issuer:        "ginjwt.test.issuer",
+		JWKSURI:       srv.URL,
+		JWKSLazyFetch: true,
+	})
+	require.NoError(t, err)
+
+	// unsigned tokens are rejected without fetching the JWKS
+	for i := 0; i < 3; i++ {
+		_, err := authMW.VerifyToken(tokenContext(unsignedToken(t, "none", jwt.Claims{Subject: fmt.Sprint(i)})))
+		assert.ErrorContains(t, err, ginjwt.ErrInvalidSigningAlgorithm.Error())
+	}
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(fetches))
+}
+
+// unsignedToken returns a token with an empty signature and the alg header set to alg
+func unsignedToken(t *testing.T, alg string, claims jwt.Claims) string {
+	t.Helper()
+
+	header, err := json.Marshal(map[string]string{"alg": alg, "kid": ginjwt.TestPrivRSAKey1ID, "typ": "JWT"})
+	require.NoError(t, err)
+
+	payload, err := json.Marshal(claims)
+	require.NoError(t, err)
+
+	return base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+}
+
+func tokenContext(token string) *gin.Context {
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "http://test/", nil)
+	c.Request.Header.Set("Authorization", "bearer "+token)
+
+	return c
+}
@@ -0,0 +1,96 @@
+package ginjwt_test
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+// tokenClaims returns the claims of the raw token as raw JSON, as read by the middleware
+func tokenClaims(t *testing.T, rawToken string) map[string]json.RawMessage {
+	t.Helper()
+
+	parts := strings.Split(rawToken, ".")
+	require.Len(t, parts, 3)
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+
+	claims := map[string]json.RawMessage{}
+	require.NoError(t, json.Unmarshal(payload, &claims))
+
+	return claims
+}
+
+func TestGetClaims(t *testing.T) {
+	cfg := ginjwt.AuthConfig{
+		Enabled:    true,
+		Audience:   "ginjwt.test",
+		Issuer:     "ginjwt.test.issuer",
+		JWKS:       ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+		RolesClaim: "userPerms.scope",
+	}
+
+	mw, err := ginjwt.NewAuthMiddleware(cfg)
+	require.NoError(t, err)
+
+	mtm, err := ginjwt.NewMultiTokenMiddlewareFromConfigs(cfg)
+	require.NoError(t, err)
+
+	handler := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"roles": ginjwt.GetRoles(c), "claims": ginjwt.GetClaims(c)})
+	}
+
+	r := gin.New()
+	r.GET("/single", mw.AuthRequired(), mw.RequiredScopes([]string{"read"}), handler)
+	r.GET("/multi", mtm.AuthRequired([]string{"read"}), handler)
+	r.GET("/anonymous", handler)
+
+	signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+	token := ginjwt.TestHelperGetToken(signer, jwt.Claims{
+		Subject:   "test-user",
+		Issuer:    "ginjwt.test.issuer",
+		NotBefore: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+		Audience:  jwt.Audience{"ginjwt.test"},
+	}, "userPerms", map[string]interface{}{"scope": []string{"read", "write"}, "org": "acme"})
+
+	for _, path := range []string{"/single", "/multi"} {
+		t.Run(path, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.Header.Set("Authorization", "bearer "+token)
+			r.ServeHTTP(w, req)
+
+			require.Equal(t, http.StatusOK, w.Code)
+
+			var got struct {
+				Roles  []string               `json:"roles"`
+				Claims map[string]interface{} `json:"claims"`
+			}
+
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &got))
+
+			assert.Equal(t, []string{"read", "write"}, got.Roles)
+			assert.Equal(t, "test-user", got.Claims["sub"])
+			assert.Equal(t, []interface{}{"ginjwt.test"}, got.Claims["aud"])
+			assert.Equal(t, "acme", got.Claims["userPerms"].(map[string]interface{})["org"])
+		})
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/anonymous", nil))
+
+	assert.JSONEq(t, `{"roles":null,"claims":null}`, w.Body.String())
+}
@@ -0,0 +1,231 @@
+package ginjwt
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"go.hollow.sh/toolbox/ginauth"
+)
+
+// DefaultDecisionCacheSize is the number of decisions cached when no size is configured
+const DefaultDecisionCacheSize = 10000
+
+// DecisionCacheStats holds the metrics of the decision cache, to be exported to the metrics system
+type DecisionCacheStats struct {
+	// Hits counts the requests authorized with a cached decision
+	Hits uint64
+	// Misses counts the requests whose token was verified as no decision was cached
+	Misses uint64
+	// Evictions counts the unexpired decisions dropped to make room for new ones
+	Evictions uint64
+	// Size is the number of decisions currently cached
+	Size int
+}
+
+// decisionCache memoizes the outcome of verifying a token and evaluating the scopes of a route.
+// Decisions are keyed by the token, which identifies the subject, the route and the scopes, so
+// repeated requests by a subject to a route skip the signature verification and claims parsing.
+type decisionCache struct {
+	ttl  time.Duration
+	size int
+
+	hits      uint64
+	misses    uint64
+	evictions uint64
+
+	mu      sync.Mutex
+	entries map[string]decisionCacheEntry
+}
+
+type decisionCacheEntry struct {
+	cm ginauth.ClaimMetadata
+	// err is the authorization error of tokens lacking the scopes, nil when authorized
+	err       error
+	expiresAt time.Time
+}
+
+func newDecisionCache(ttl time.Duration, size int) *decisionCache {
+	if size <= 0 {
+		size = DefaultDecisionCacheSize
+	}
+
+	return &decisionCache{
+		ttl:     ttl,
+		size:    size,
+		entries: map[string]decisionCacheEntry{},
+	}
+}
+
+// decisionCacheKey returns the key of the decision for the token on the route with the scopes,
+// the scopes are sorted as their order doesn't change the decision
+func decisionCacheKey(rawToken, route string, scopes []string) string {
+	sorted := append([]string{}, scopes...)
+	sort.Strings(sorted)
+
+	sum := sha256.Sum256([]byte(rawToken))
+
+	return hex.EncodeToString(sum[:]) + "\x00" + route + "\x00" + strings.Join(sorted, " ")
+}
+
+func (dc *decisionCache) get(key string) (decisionCacheEntry, bool) {
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	e, ok := dc.entries[key]
+	if ok && time.Now().Before(e.expiresAt) {
+		atomic.AddUint64(&dc.hits, 1)
+		return e, true
+	}
+
+	if ok {
+		delete(dc.entries, key)
+	}
+
+	atomic.AddUint64(&dc.misses, 1)
+
+	return decisionCacheEntry{}, false
+}
+
+// set caches the decision for the TTL, or until the token expires when it expires earlier
+func (dc *decisionCache) set(key string, cm ginauth.ClaimMetadata, err error, tokenExpiry time.Time) {
+	now := time.Now()
+
+	expiresAt := now.Add(dc.ttl)
+	if !tokenExpiry.IsZero() && tokenExpiry.Before(expiresAt) {
+		expiresAt = tokenExpiry
+	}
+
+	if !now.Before(expiresAt) {
+		return
+	}
+
+	dc.mu.Lock()
+	defer dc.mu.Unlock()
+
+	if _, ok := dc.entries[key]; !ok && len(dc.entries) >= dc.size {
+		for k, e := range dc.entries {
+			if !now.Before(e.expiresAt) {
+				delete(dc.entries, k)
+			}
+		}
+
+		// drop arbitrary decisions when none expired, map iteration order is random
+		for k := range dc.entries {
+			if len(dc.entries) < dc.size {
+				break
+			}
+
+			delete(dc.entries, k)
+			atomic.AddUint64(&dc.evictions, 1)
+		}
+	}
+
+	dc.entries[key] = decisionCacheEntry{cm: cm, err: err, expiresAt: expiresAt}
+}
+
+func (dc *decisionCache) stats() DecisionCacheStats {
+	dc.mu.Lock()
+	size := len(dc.entries)
+	dc.mu.Unlock()
+
+	return DecisionCacheStats{
+		Hits:      atomic.LoadUint64(&dc.hits),
+		Misses:    atomic.LoadUint64(&dc.misses),
+		Evictions: atomic.LoadUint64(&dc.evictions),
+		Size:      size,
+	}
+}
+
+// DecisionCacheStats returns the metrics of the decision cache, all zero when DecisionCacheTTL isn't set.
+func (m *Middleware) DecisionCacheStats() DecisionCacheStats {
+	if m.decisions == nil {
+		return DecisionCacheStats{}
+	}
+
+	return m.decisions.stats()
+}
+
+// authorize verifies the token of the request and, when checkScopes is true, the scopes, reusing the
+// cached decision of the route when the decision cache is enabled. The error of tokens lacking the
+// scopes is returned as scopesErr, along with the metadata of the token.
+func (m *Middleware) authorize(c *gin.Context, scopes []string, checkScopes bool) (cm ginauth.ClaimMetadata, scopesErr, err error) {
+	rawToken, err := m.tokenFromRequest(c)
+	if err != nil {
+		return ginauth.ClaimMetadata{}, nil, err
+	}
+
+	if m.decisions == nil {
+		cm, _, err = m.verifyToken(c, rawToken)
+		if err != nil {
+			return ginauth.ClaimMetadata{}, nil, err
+		}
+
+		if checkScopes {
+			scopesErr = m.VerifyRoles(cm.Roles, scopes)
+		}
+
+		return cm, scopesErr, nil
+	}
+
+	if !checkScopes {
+		scopes = nil
+	}
+
+	key := decisionCacheKey(rawToken, c.FullPath(), scopes)
+
+	if e, ok := m.decisions.get(key); ok {
+		// tokens revoked after their decision was cached are denied
+		if err := m.CheckRevoked(c, e.cm.TokenID); err != nil {
+			return ginauth.ClaimMetadata{}, nil, err
+		}
+
+		return e.cm, e.err, nil
+	}
+
+	cm, expiry, err := m.verifyToken(c, rawToken)
+	if err != nil {
+		// failed verifications aren't cached, the token may become valid, e.g. once the JWKS is refreshed
+		return ginauth.ClaimMetadata{}, nil, err
+	}
+
+	if checkScopes {
+		scopesErr = m.VerifyRoles(cm.Roles, scopes)
+	}
+
+	m.decisions.set(key, cm, scopesErr, expiry)
+
+	return cm, scopesErr, nil
+}
+
+// tokenExpiry returns the exp claim of the token, zero when it has none
+func tokenExpiry(claims map[string]json.RawMessage) time.Time {
+	raw, ok := claims["exp"]
+	if !ok {
+		return time.Time{}
+	}
+
+	var exp jwt.NumericDate
+	if err := json.Unmarshal(raw, &exp); err != nil {
+		return time.Time{}
+	}
+
+	return exp.Time()
+}
+
+// earliest returns the earliest non-zero time
+func earliest(a, b time.Time) time.Time {
+	if a.IsZero() || (!b.IsZero() && b.Before(a)) {
+		return b
+	}
+
+	return a
+}
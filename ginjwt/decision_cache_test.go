@@ -0,0 +1,254 @@
+package ginjwt_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+// newDecisionCacheMiddleware returns a middleware with the decision cache, the tokens are verified on
+// the cache misses
+func newDecisionCacheMiddleware(t *testing.T, ttl time.Duration, size int) *ginjwt.Middleware {
+	t.Helper()
+
+	mw, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:           true,
+		Audience:          "ginjwt.test",
+		Issuer:            "ginjwt.test.issuer",
+		JWKS:              ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+		DecisionCacheTTL:  ttl,
+		DecisionCacheSize: size,
+	})
+	require.NoError(t, err)
+
+	return mw
+}
+
+func decisionCacheToken(subject string, expiry time.Time, scopes ...string) string {
+	signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+
+	return ginjwt.TestHelperGetToken(signer, jwt.Claims{
+		Subject:   subject,
+		Issuer:    "ginjwt.test.issuer",
+		NotBefore: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+		Expiry:    jwt.NewNumericDate(expiry),
+		Audience:  jwt.Audience{"ginjwt.test"},
+	}, "scope", scopes)
+}
+
+// verifiedTokens returns the number of tokens verified by the middleware, the decision cache misses
+func verifiedTokens(mw *ginjwt.Middleware) uint64 {
+	return mw.DecisionCacheStats().Misses
+}
+
+func TestDecisionCacheAuthRequired(t *testing.T) {
+	mw := newDecisionCacheMiddleware(t, time.Minute, 0)
+
+	r := gin.New()
+	handler := func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"subject": ginjwt.GetSubject(c)})
+	}
+
+	r.GET("/things/:id", mw.AuthRequired(), handler)
+	r.GET("/other", mw.AuthRequired(), handler)
+
+	token := decisionCacheToken("user-1", time.Now().Add(time.Hour), "read")
+
+	do := func(path, token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "bearer "+token)
+		r.ServeHTTP(w, req)
+
+		return w
+	}
+
+	for _, path := range []string{"/things/1", "/things/1", "/things/2"} {
+		w := do(path, token)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.JSONEq(t, `{"subject":"user-1"}`, w.Body.String())
+	}
+
+	assert.Equal(t, uint64(1), verifiedTokens(mw), "requests to the same route should reuse the decision")
+
+	assert.Equal(t, http.StatusOK, do("/other", token).Code)
+	assert.Equal(t, uint64(2), verifiedTokens(mw), "another route should verify the token")
+
+	assert.Equal(t, http.StatusOK, do("/things/1", decisionCacheToken("user-2", time.Now().Add(time.Hour), "read")).Code)
+	assert.Equal(t, uint64(3), verifiedTokens(mw), "another token should be verified")
+
+	assert.Equal(t, http.StatusUnauthorized, do("/things/1", "not-a-token").Code)
+	assert.Equal(t, http.StatusUnauthorized, do("/things/1", "not-a-token").Code)
+
+	assert.Equal(t, ginjwt.DecisionCacheStats{Hits: 2, Misses: 5, Size: 3}, mw.DecisionCacheStats(), "failed verifications shouldn't be cached")
+}
+
+func TestDecisionCacheScopes(t *testing.T) {
+	mw := newDecisionCacheMiddleware(t, time.Minute, 0)
+
+	token := decisionCacheToken("user-1", time.Now().Add(time.Hour), "read", "write")
+
+	verify := func(scopes ...string) error {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "http://test/", nil)
+		c.Request.Header.Set("Authorization", "bearer "+token)
+
+		cm, err := mw.VerifyTokenWithScopes(c, scopes)
+		if err == nil {
+			assert.Equal(t, "user-1", cm.Subject)
+		}
+
+		assert.Equal(t, "user-1", ginjwt.GetSubject(c), "context should be populated from the cached decision")
+
+		return err
+	}
+
+	require.NoError(t, verify("read", "write"))
+	require.NoError(t, verify("write", "read"), "scopes order shouldn't change the decision")
+
+	require.Error(t, verify("admin"))
+	require.Error(t, verify("admin"), "denials should be cached")
+
+	assert.Equal(t, ginjwt.DecisionCacheStats{Hits: 2, Misses: 2, Size: 2}, mw.DecisionCacheStats())
+}
+
+func TestDecisionCacheTokenExpiry(t *testing.T) {
+	mw := newDecisionCacheMiddleware(t, time.Hour, 0)
+
+	r := gin.New()
+	r.GET("/", mw.AuthRequired(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	// the leeway accepts the token past its expiry, the decision must not outlive it though
+	token := decisionCacheToken("user-1", time.Now().Add(time.Second), "read")
+
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", fmt.Sprintf("bearer %s", token))
+		r.ServeHTTP(w, req)
+
+		require.Equal(t, http.StatusOK, w.Code)
+
+		time.Sleep(1100 * time.Millisecond)
+	}
+
+	assert.Equal(t, uint64(2), verifiedTokens(mw), "the decision should expire with the token")
+}
+
+func TestDecisionCacheEvictions(t *testing.T) {
+	mw := newDecisionCacheMiddleware(t, time.Minute, 2)
+
+	for i := 0; i < 3; i++ {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "http://test/", nil)
+		c.Request.Header.Set("Authorization", "bearer "+decisionCacheToken(fmt.Sprintf("user-%d", i), time.Now().Add(time.Hour), "read"))
+
+		_, err := mw.VerifyTokenWithScopes(c, []string{"read"})
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, ginjwt.DecisionCacheStats{Misses: 3, Evictions: 1, Size: 2}, mw.DecisionCacheStats())
+}
+
+func TestDecisionCacheDisabled(t *testing.T) {
+	var verified int32
+
+	mw, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:  true,
+		Audience: "ginjwt.test",
+		Issuer:   "ginjwt.test.issuer",
+		JWKS:     ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+		ClaimValidators: []ginjwt.ClaimValidatorFunc{
+			func(c *gin.Context, claims map[string]any) error {
+				atomic.AddInt32(&verified, 1)
+				return nil
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	token := decisionCacheToken("user-1", time.Now().Add(time.Hour), "read")
+
+	for i := 0; i < 2; i++ {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest(http.MethodGet, "http://test/", nil)
+		c.Request.Header.Set("Authorization", "bearer "+token)
+
+		_, err := mw.VerifyTokenWithScopes(c, []string{"read"})
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(&verified))
+	assert.Equal(t, ginjwt.DecisionCacheStats{}, mw.DecisionCacheStats())
+}
+
+func TestDecisionCacheRequestDependentChecks(t *testing.T) {
+	// the org claim must match the org of the URL
+	orgValidator := func(c *gin.Context, claims map[string]any) error {
+		if claims["org"] != c.Param("org") {
+			return fmt.Errorf("token isn't for org %s", c.Param("org"))
+		}
+
+		return nil
+	}
+
+	cfg := ginjwt.AuthConfig{
+		Enabled:         true,
+		Audience:        "ginjwt.test",
+		Issuer:          "ginjwt.test.issuer",
+		JWKS:            ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+		ClaimValidators: []ginjwt.ClaimValidatorFunc{orgValidator},
+	}
+
+	// cached decisions of the route would skip the checks for the other orgs
+	cached := cfg
+	cached.DecisionCacheTTL = time.Minute
+
+	_, err := ginjwt.NewAuthMiddleware(cached)
+	assert.ErrorIs(t, err, ginjwt.ErrInvalidAuthConfig)
+
+	_, err = ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:          true,
+		Audience:         "ginjwt.test",
+		Issuer:           "ginjwt.test.issuer",
+		JWKS:             ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+		SubjectResolver:  &countingResolver{},
+		DecisionCacheTTL: time.Minute,
+	})
+	assert.ErrorIs(t, err, ginjwt.ErrInvalidAuthConfig)
+
+	mw, err := ginjwt.NewAuthMiddleware(cfg)
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.GET("/orgs/:org", mw.AuthRequired(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+	token := ginjwt.TestHelperGetToken(signer, jwt.Claims{
+		Subject:   "user-1",
+		Issuer:    "ginjwt.test.issuer",
+		NotBefore: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+		Expiry:    jwt.NewNumericDate(time.Now().Add(time.Hour)),
+		Audience:  jwt.Audience{"ginjwt.test"},
+	}, "org", "acme")
+
+	for path, want := range map[string]int{"/orgs/acme": http.StatusOK, "/orgs/globex": http.StatusForbidden} {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "bearer "+token)
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, want, w.Code, path)
+	}
+}
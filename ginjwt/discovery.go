@@ -0,0 +1,141 @@
+package ginjwt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// OIDCDiscoveryPath is where an OpenID provider publishes its metadata, relative to its issuer
+const OIDCDiscoveryPath = "/.well-known/openid-configuration"
+
+// OIDCProviderMetadata holds the OpenID provider metadata used by the middleware, see
+// https://openid.net/specs/openid-connect-discovery-1_0.html#ProviderMetadata
+type OIDCProviderMetadata struct {
+	Issuer                           string   `json:"issuer"`
+	JWKSURI                          string   `json:"jwks_uri"`
+	AuthorizationEndpoint            string   `json:"authorization_endpoint,omitempty"`
+	TokenEndpoint                    string   `json:"token_endpoint,omitempty"`
+	UserinfoEndpoint                 string   `json:"userinfo_endpoint,omitempty"`
+	IntrospectionEndpoint            string   `json:"introspection_endpoint,omitempty"`
+	ScopesSupported                  []string `json:"scopes_supported,omitempty"`
+	IDTokenSigningAlgValuesSupported []string `json:"id_token_signing_alg_values_supported,omitempty"`
+}
+
+// DiscoverOIDCProvider fetches the metadata of the OpenID provider of the issuer. The issuer of the
+// metadata must be the one it was fetched for, and its JWKS URI an http(s) URL.
+func DiscoverOIDCProvider(ctx context.Context, issuer string) (OIDCProviderMetadata, error) {
+	return DiscoverOIDCProviderWithClient(ctx, http.DefaultClient, issuer)
+}
+
+// DiscoverOIDCProviderWithClient is DiscoverOIDCProvider fetching the metadata with the client.
+func DiscoverOIDCProviderWithClient(ctx context.Context, client *http.Client, issuer string) (OIDCProviderMetadata, error) {
+	if !isHTTPURL(issuer) {
+		return OIDCProviderMetadata{}, fmt.Errorf("%w: issuer %s isn't an http(s) URL", ErrOIDCDiscovery, issuer)
+	}
+
+	discoveryURL := strings.TrimSuffix(issuer, "/") + OIDCDiscoveryPath
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, discoveryURL, nil)
+	if err != nil {
+		return OIDCProviderMetadata{}, fmt.Errorf("%w: %s", ErrOIDCDiscovery, err)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return OIDCProviderMetadata{}, fmt.Errorf("%w: %s", ErrOIDCDiscovery, err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return OIDCProviderMetadata{}, fmt.Errorf("%w: %s returned %s", ErrOIDCDiscovery, discoveryURL, resp.Status)
+	}
+
+	var md OIDCProviderMetadata
+	if err := json.NewDecoder(resp.Body).Decode(&md); err != nil {
+		return OIDCProviderMetadata{}, fmt.Errorf("%w: %s", ErrOIDCDiscovery, err)
+	}
+
+	// the metadata of another issuer would have tokens verified with its keys
+	if strings.TrimSuffix(md.Issuer, "/") != strings.TrimSuffix(issuer, "/") {
+		return OIDCProviderMetadata{}, fmt.Errorf("%w: metadata of %s is for issuer %q", ErrOIDCDiscovery, issuer, md.Issuer)
+	}
+
+	if !isHTTPURL(md.JWKSURI) {
+		return OIDCProviderMetadata{}, fmt.Errorf("%w: metadata of %s has no valid jwks_uri", ErrOIDCDiscovery, issuer)
+	}
+
+	return md, nil
+}
+
+// ProviderMetadata returns the metadata of the OpenID provider discovered with JWKSDiscovery, false
+// until it was fetched.
+func (m *Middleware) ProviderMetadata() (OIDCProviderMetadata, bool) {
+	m.discoveryMu.Lock()
+	defer m.discoveryMu.Unlock()
+
+	if m.discovered == nil {
+		return OIDCProviderMetadata{}, false
+	}
+
+	return *m.discovered, true
+}
+
+// jwksURI returns the JWKSURI, or the JWKS URI discovered from the issuer with JWKSDiscovery.
+// The discovery is retried by the next JWKS fetch when it failed.
+func (m *Middleware) jwksURI(ctx context.Context) (string, error) {
+	if m.config.JWKSURI != "" || !m.discovery {
+		return m.config.JWKSURI, nil
+	}
+
+	m.discoveryMu.Lock()
+	defer m.discoveryMu.Unlock()
+
+	if m.discovered != nil {
+		return m.discovered.JWKSURI, nil
+	}
+
+	md, err := DiscoverOIDCProviderWithClient(ctx, m.config.HTTPClient, m.config.Issuer)
+	if err != nil {
+		return "", err
+	}
+
+	m.logger.Info("discovered OpenID provider", zap.String("issuer", m.config.Issuer), zap.String("jwks_uri", md.JWKSURI))
+
+	m.discovered = &md
+
+	return md.JWKSURI, nil
+}
+
+// jwksSource returns the log field of where the JWKS is fetched from: the KeySetProvider, the
+// JWKS URI, once discovered, or else the issuer it is discovered from.
+func (m *Middleware) jwksSource() zap.Field {
+	if m.config.KeySetProvider != nil {
+		return zap.String("provider", fmt.Sprintf("%T", m.config.KeySetProvider))
+	}
+
+	if m.config.JWKSURI != "" || !m.discovery {
+		return zap.String("uri", m.config.JWKSURI)
+	}
+
+	m.discoveryMu.Lock()
+	defer m.discoveryMu.Unlock()
+
+	if m.discovered != nil {
+		return zap.String("uri", m.discovered.JWKSURI)
+	}
+
+	return zap.String("issuer", m.config.Issuer)
+}
+
+func isHTTPURL(s string) bool {
+	u, err := url.Parse(s)
+
+	return err == nil && (u.Scheme == "https" || u.Scheme == "http") && u.Host != ""
+}
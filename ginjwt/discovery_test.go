@@ -0,0 +1,187 @@
+package ginjwt_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+// newOIDCProvider serves the openid-configuration of its URL as issuer, with the issuer
+// overridden when not empty, and the JWKS holding the key id
+func newOIDCProvider(t *testing.T, issuer, kid string) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var discoveries int32
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+
+	t.Cleanup(srv.Close)
+
+	if issuer == "" {
+		issuer = srv.URL
+	}
+
+	mux.HandleFunc(ginjwt.OIDCDiscoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&discoveries, 1)
+
+		_ = json.NewEncoder(w).Encode(ginjwt.OIDCProviderMetadata{
+			Issuer:        issuer,
+			JWKSURI:       srv.URL + "/keys",
+			TokenEndpoint: srv.URL + "/token",
+		})
+	})
+
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(ginjwt.TestHelperJoseJWKSProvider(kid))
+	})
+
+	return srv, &discoveries
+}
+
+func discoveryTestContext(issuer string) *gin.Context {
+	signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+
+	return tokenContext(ginjwt.TestHelperGetToken(signer, jwt.Claims{
+		Subject:  "test-user",
+		Issuer:   issuer,
+		Audience: jwt.Audience{"ginjwt.test"},
+	}, "scope", "read"))
+}
+
+func TestOIDCDiscovery(t *testing.T) {
+	srv, discoveries := newOIDCProvider(t, "", ginjwt.TestPrivRSAKey1ID)
+
+	authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:       true,
+		Audience:      "ginjwt.test",
+		Issuer:        srv.URL,
+		JWKSDiscovery: true,
+	})
+	require.NoError(t, err)
+
+	md, ok := authMW.ProviderMetadata()
+	require.True(t, ok)
+	assert.Equal(t, srv.URL+"/keys", md.JWKSURI)
+	assert.Equal(t, srv.URL+"/token", md.TokenEndpoint)
+
+	_, err = authMW.VerifyToken(discoveryTestContext(srv.URL))
+	assert.NoError(t, err)
+
+	// the provider is discovered once
+	assert.Equal(t, int32(1), atomic.LoadInt32(discoveries))
+}
+
+func TestOIDCDiscoveryLazyFetch(t *testing.T) {
+	srv, discoveries := newOIDCProvider(t, "", ginjwt.TestPrivRSAKey1ID)
+
+	authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:       true,
+		Audience:      "ginjwt.test",
+		Issuer:        srv.URL + "/",
+		JWKSDiscovery: true,
+		JWKSLazyFetch: true,
+	})
+	require.NoError(t, err)
+
+	_, ok := authMW.ProviderMetadata()
+	assert.False(t, ok)
+	assert.Equal(t, int32(0), atomic.LoadInt32(discoveries))
+
+	_, err = authMW.VerifyToken(discoveryTestContext(srv.URL + "/"))
+	assert.NoError(t, err)
+
+	_, ok = authMW.ProviderMetadata()
+	assert.True(t, ok)
+}
+
+func TestOIDCDiscoveryErrors(t *testing.T) {
+	// the metadata of another issuer is rejected
+	srv, _ := newOIDCProvider(t, "https://evil.example.com", ginjwt.TestPrivRSAKey1ID)
+
+	_, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:       true,
+		Audience:      "ginjwt.test",
+		Issuer:        srv.URL,
+		JWKSDiscovery: true,
+	})
+	assert.ErrorIs(t, err, ginjwt.ErrOIDCDiscovery)
+
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+
+	_, err = ginjwt.DiscoverOIDCProvider(context.Background(), notFound.URL)
+	assert.ErrorIs(t, err, ginjwt.ErrOIDCDiscovery)
+
+	// issuers which aren't URLs can't be discovered
+	_, err = ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:       true,
+		Audience:      "ginjwt.test",
+		Issuer:        "ginjwt.test.issuer",
+		JWKSDiscovery: true,
+	})
+	assert.ErrorIs(t, err, ginjwt.ErrInvalidAuthConfig)
+
+	// the discovery doesn't replace a JWKS source
+	_, err = ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:       true,
+		Audience:      "ginjwt.test",
+		Issuer:        notFound.URL,
+		JWKSURI:       notFound.URL + "/keys",
+		JWKSDiscovery: true,
+	})
+	assert.ErrorIs(t, err, ginjwt.ErrInvalidAuthConfig)
+
+	// a degraded start keeps discovering in the background
+	authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:            true,
+		Audience:           "ginjwt.test",
+		Issuer:             notFound.URL,
+		JWKSDiscovery:      true,
+		JWKSStartDegraded:  true,
+		JWKSStartupBackoff: time.Millisecond,
+	})
+	require.NoError(t, err)
+	assert.NoError(t, authMW.Close())
+}
+
+func TestOIDCDiscoveryOptIn(t *testing.T) {
+	srv, discoveries := newOIDCProvider(t, "", ginjwt.TestPrivRSAKey1ID)
+
+	// a config without JWKS source is rejected, the issuer isn't discovered
+	_, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:  true,
+		Audience: "ginjwt.test",
+		Issuer:   srv.URL,
+	})
+	assert.ErrorIs(t, err, ginjwt.ErrInvalidAuthConfig)
+	assert.ErrorContains(t, err, "either JWKSURI, JWKS, KeySetProvider or JWKSDiscovery must be provided")
+	assert.Equal(t, int32(0), atomic.LoadInt32(discoveries))
+
+	authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:  true,
+		Audience: "ginjwt.test",
+		Issuer:   srv.URL,
+		JWKSURI:  srv.URL + "/keys",
+	})
+	require.NoError(t, err)
+
+	_, err = authMW.VerifyToken(discoveryTestContext(srv.URL))
+	assert.NoError(t, err)
+
+	_, ok := authMW.ProviderMetadata()
+	assert.False(t, ok)
+	assert.Equal(t, int32(0), atomic.LoadInt32(discoveries))
+}
@@ -25,4 +25,34 @@ var (
 
 	// ErrJWKSConfigConflict is an error when both JWKSURI and JWKS are set
 	ErrJWKSConfigConflict = errors.New("JWKS and JWKSURI can't both be set at the same time")
+
+	// ErrClaimValidation is the error returned when a ClaimValidatorFunc rejects the token claims
+	ErrClaimValidation = errors.New("token claims rejected")
+
+	// ErrInvalidHostedDomain is the error returned when the hd claim of a Google token isn't one of the HostedDomains
+	ErrInvalidHostedDomain = errors.New("invalid JWT hosted domain")
+
+	// ErrSubjectResolution is the error returned when the token subject couldn't be mapped to an identity
+	ErrSubjectResolution = errors.New("unable to resolve token subject")
+
+	// ErrMissingTenant is the error returned by RequireTenant when the token has no tenant claim
+	ErrMissingTenant = errors.New("missing JWT tenant claim")
+
+	// ErrKeyFetchTimeout is the error returned when the JWKS fetch outlived the request or the JWKSRemoteTimeout
+	ErrKeyFetchTimeout = errors.New("timed out fetching the JWKS")
+
+	// ErrTokenNotFound is returned by a TokenExtractor when the request has no token in its source
+	ErrTokenNotFound = errors.New("token not found")
+
+	// ErrOIDCDiscovery is the error returned when the OpenID provider metadata of the issuer couldn't be discovered
+	ErrOIDCDiscovery = errors.New("unable to discover the OpenID provider")
+
+	// ErrIntrospection is the error returned when the token couldn't be introspected
+	ErrIntrospection = errors.New("unable to introspect the token")
+
+	// ErrTokenRevoked is the error returned when the ID of the token is in the RevocationStore
+	ErrTokenRevoked = errors.New("JWT has been revoked")
+
+	// ErrRevocationCheck is the error returned when the RevocationStore couldn't be checked
+	ErrRevocationCheck = errors.New("unable to check the JWT revocation")
 )
@@ -0,0 +1,183 @@
+package ginjwt
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"go.hollow.sh/toolbox/ginauth"
+)
+
+// TokenExtractor reads the raw token of a request from one source, such as a header or a cookie.
+// It returns ErrTokenNotFound when the request doesn't hold a token in its source so the next
+// extractor of the TokenExtractors is tried, other errors reject the request.
+type TokenExtractor interface {
+	ExtractToken(c *gin.Context) (string, error)
+}
+
+// TokenExtractorFunc is a function satisfying the TokenExtractor interface
+type TokenExtractorFunc func(c *gin.Context) (string, error)
+
+// ExtractToken calls f(c)
+func (f TokenExtractorFunc) ExtractToken(c *gin.Context) (string, error) {
+	return f(c)
+}
+
+// BearerHeaderExtractor reads the token from a header in the "Bearer token" format, e.g. the
+// Authorization header. Headers in another format are rejected.
+func BearerHeaderExtractor(header string) TokenExtractor {
+	return TokenExtractorFunc(func(c *gin.Context) (string, error) {
+		value := c.Request.Header.Get(header)
+		if value == "" {
+			return "", ErrTokenNotFound
+		}
+
+		scheme, rawToken, found := strings.Cut(value, " ")
+
+		if !(found && strings.EqualFold(scheme, "bearer")) {
+			return "", ginauth.NewAuthenticationError("invalid authorization header, expected format: \"Bearer token\"")
+		}
+
+		return rawToken, nil
+	})
+}
+
+// HeaderExtractor reads the token from a header holding only the token, e.g. X-Auth-Token.
+func HeaderExtractor(header string) TokenExtractor {
+	return TokenExtractorFunc(func(c *gin.Context) (string, error) {
+		if rawToken := strings.TrimSpace(c.Request.Header.Get(header)); rawToken != "" {
+			return rawToken, nil
+		}
+
+		return "", ErrTokenNotFound
+	})
+}
+
+// CookieExtractor reads the token from a cookie, for browser clients.
+func CookieExtractor(name string) TokenExtractor {
+	return TokenExtractorFunc(func(c *gin.Context) (string, error) {
+		if rawToken, err := c.Cookie(name); err == nil && rawToken != "" {
+			return rawToken, nil
+		}
+
+		return "", ErrTokenNotFound
+	})
+}
+
+// QueryExtractor reads the token from a query parameter, e.g. access_token for clients that can't
+// set headers. Query parameters end up in access logs, prefer the other sources when possible.
+func QueryExtractor(param string) TokenExtractor {
+	return TokenExtractorFunc(func(c *gin.Context) (string, error) {
+		if rawToken := c.Query(param); rawToken != "" {
+			return rawToken, nil
+		}
+
+		return "", ErrTokenNotFound
+	})
+}
+
+// WebSocketSubprotocolExtractor reads the token from the subprotocols of websocket upgrade requests,
+// following the WebSocketTokenSubprotocol, see WebSocketSubprotocol. Upgrade requests without a token are rejected.
+func WebSocketSubprotocolExtractor() TokenExtractor {
+	return TokenExtractorFunc(func(c *gin.Context) (string, error) {
+		if !isWebSocketUpgrade(c) {
+			return "", ErrTokenNotFound
+		}
+
+		return tokenFromWebSocketProtocols(c)
+	})
+}
+
+// ParseTokenExtractors returns the extractors of the token sources, in order. The sources are:
+//
+//   - bearer:<header>, the token in a "Bearer token" header, bearer alone is the Authorization header
+//   - header:<header>, a header holding only the token
+//   - cookie:<name>, a cookie
+//   - query:<param>, a query parameter
+//   - websocket, the subprotocols of websocket upgrade requests
+func ParseTokenExtractors(sources []string) ([]TokenExtractor, error) {
+	var extractors []TokenExtractor
+
+	for _, source := range sources {
+		kind, name, _ := strings.Cut(source, ":")
+
+		kind = strings.ToLower(strings.TrimSpace(kind))
+		name = strings.TrimSpace(name)
+
+		if name == "" && kind != "bearer" && kind != "websocket" {
+			return nil, fmt.Errorf("%w: token source %q has no name", ErrInvalidAuthConfig, source)
+		}
+
+		switch kind {
+		case "bearer":
+			if name == "" {
+				name = "Authorization"
+			}
+
+			extractors = append(extractors, BearerHeaderExtractor(name))
+		case "header":
+			extractors = append(extractors, HeaderExtractor(name))
+		case "cookie":
+			extractors = append(extractors, CookieExtractor(name))
+		case "query":
+			extractors = append(extractors, QueryExtractor(name))
+		case "websocket":
+			extractors = append(extractors, WebSocketSubprotocolExtractor())
+		default:
+			return nil, fmt.Errorf("%w: unknown token source %q", ErrInvalidAuthConfig, source)
+		}
+	}
+
+	return extractors, nil
+}
+
+// defaultTokenExtractors read the Authorization header, the header grpc-gateway forwards it in,
+// and when enabled the subprotocols of websocket upgrade requests.
+func defaultTokenExtractors(cfg AuthConfig) []TokenExtractor {
+	extractors := []TokenExtractor{
+		BearerHeaderExtractor("Authorization"),
+		BearerHeaderExtractor(GRPCGatewayAuthorizationHeader),
+	}
+
+	if cfg.WebSocketSubprotocolToken {
+		extractors = append(extractors, WebSocketSubprotocolExtractor())
+	}
+
+	return extractors
+}
+
+// tokenFromRequest returns the raw token of the request, read by the first of the TokenExtractors
+// finding one.
+func (m *Middleware) tokenFromRequest(c *gin.Context) (string, error) {
+	return extractToken(c, m.extractors, len(m.config.TokenExtractors) > 0)
+}
+
+// extractToken returns the token found by the first of the extractors finding one, custom is set
+// when the extractors were configured rather than the default ones.
+func extractToken(c *gin.Context, extractors []TokenExtractor, custom bool) (string, error) {
+	for _, extractor := range extractors {
+		rawToken, err := extractor.ExtractToken(c)
+		if errors.Is(err, ErrTokenNotFound) {
+			continue
+		}
+
+		if err != nil {
+			var authErr *ginauth.AuthError
+			if !errors.As(err, &authErr) {
+				err = ginauth.NewAuthenticationErrorFrom(err)
+			}
+
+			return "", err
+		}
+
+		return rawToken, nil
+	}
+
+	if custom {
+		return "", ginauth.NewAuthenticationError("missing auth token")
+	}
+
+	return "", ginauth.NewAuthenticationError("missing authorization header, expected format: \"Bearer token\"")
+}
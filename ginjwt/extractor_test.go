@@ -0,0 +1,126 @@
+package ginjwt_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+func TestTokenExtractors(t *testing.T) {
+	signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+	rawToken := ginjwt.TestHelperGetToken(signer, jwt.Claims{
+		Subject:  "test-user",
+		Issuer:   "ginjwt.test.issuer",
+		Audience: jwt.Audience{"ginjwt.test"},
+	}, "scope", "read")
+
+	extractors, err := ginjwt.ParseTokenExtractors([]string{"bearer", "header:X-Auth-Token", "cookie:session", "query:access_token"})
+	require.NoError(t, err)
+
+	authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:         true,
+		Audience:        "ginjwt.test",
+		Issuer:          "ginjwt.test.issuer",
+		JWKS:            ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+		TokenExtractors: extractors,
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name     string
+		setup    func(r *http.Request)
+		wantCode int
+		wantBody string
+	}{
+		{
+			"authorization header",
+			func(r *http.Request) { r.Header.Set("Authorization", "Bearer "+rawToken) },
+			http.StatusOK,
+			"test-user",
+		},
+		{
+			"custom header",
+			func(r *http.Request) { r.Header.Set("X-Auth-Token", rawToken) },
+			http.StatusOK,
+			"test-user",
+		},
+		{
+			"cookie",
+			func(r *http.Request) { r.AddCookie(&http.Cookie{Name: "session", Value: rawToken}) },
+			http.StatusOK,
+			"test-user",
+		},
+		{
+			"query parameter",
+			func(r *http.Request) { r.URL.RawQuery = "access_token=" + rawToken },
+			http.StatusOK,
+			"test-user",
+		},
+		{
+			"first source wins",
+			func(r *http.Request) {
+				r.Header.Set("Authorization", "Bearer invalid")
+				r.AddCookie(&http.Cookie{Name: "session", Value: rawToken})
+			},
+			http.StatusUnauthorized,
+			"unable to parse auth token",
+		},
+		{
+			"malformed authorization header",
+			func(r *http.Request) {
+				r.Header.Set("Authorization", "Basic Zm9vOmJhcg==")
+				r.AddCookie(&http.Cookie{Name: "session", Value: rawToken})
+			},
+			http.StatusUnauthorized,
+			"invalid authorization header",
+		},
+		{
+			"no token",
+			func(r *http.Request) {},
+			http.StatusUnauthorized,
+			"missing auth token",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			r := gin.New()
+			r.GET("/", authMW.AuthRequired(), func(c *gin.Context) {
+				c.JSON(http.StatusOK, ginjwt.GetSubject(c))
+			})
+
+			req := httptest.NewRequest("GET", "http://test/", nil)
+			tt.setup(req)
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.wantBody)
+		})
+	}
+}
+
+func TestParseTokenExtractorsErrors(t *testing.T) {
+	for _, sources := range [][]string{{"cookie"}, {"query:"}, {"body:token"}} {
+		_, err := ginjwt.ParseTokenExtractors(sources)
+		assert.ErrorIs(t, err, ginjwt.ErrInvalidAuthConfig, sources)
+	}
+
+	_, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:         true,
+		Audience:        "ginjwt.test",
+		Issuer:          "ginjwt.test.issuer",
+		JWKS:            ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+		TokenExtractors: []ginjwt.TokenExtractor{nil},
+	})
+	assert.ErrorIs(t, err, ginjwt.ErrInvalidAuthConfig)
+}
@@ -1,11 +1,14 @@
 package ginjwt
 
 import (
+	"fmt"
+	"net/http"
 	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"gopkg.in/square/go-jose.v2/jwt"
 )
 
 // OIDCConfig provides the configuration for the oidc provider auth configuration
@@ -14,15 +17,36 @@ type OIDCConfig struct {
 	Audience               string                 `yaml:"audience"`
 	Issuer                 string                 `yaml:"issuer"`
 	JWKSURI                string                 `yaml:"jwsuri"`
+	JWKSDiscovery          bool                   `yaml:"jwksdiscovery"`
 	JWKSRemoteTimeout      time.Duration          `yaml:"jwksremotetimeout"`
 	RoleValidationStrategy RoleValidationStrategy `yaml:"rolevalidationstrategy"`
 	Claims                 Claims                 `yaml:"claims"`
+	Audiences              []string               `yaml:"audiences"`
+	ClockSkew              time.Duration          `yaml:"clockskew"`
+	Leeway                 time.Duration          `yaml:"leeway"`
+	DisabledMode           DisabledMode           `yaml:"disabledmode"`
+	JWKSStartupRetries     int                    `yaml:"jwksstartupretries"`
+	JWKSStartupBackoff     time.Duration          `yaml:"jwksstartupbackoff"`
+	JWKSStartDegraded      bool                   `yaml:"jwksstartdegraded"`
+	JWKSLazyFetch          bool                   `yaml:"jwkslazyfetch"`
+	JWKSRefreshInterval    time.Duration          `yaml:"jwksrefreshinterval"`
+	JWKSRefreshJitter      time.Duration          `yaml:"jwksrefreshjitter"`
+	ProviderPreset         ProviderPreset         `yaml:"providerpreset"`
+	HostedDomains          []string               `yaml:"hosteddomains"`
+	AcceptAuthorizedParty  bool                   `yaml:"acceptauthorizedparty"`
+	AudienceScopes         map[string][]string    `yaml:"audiencescopes"`
+	RetiredKeyGracePeriod  time.Duration          `yaml:"retiredkeygraceperiod"`
+	AllowedAlgorithms      []string               `yaml:"allowedalgorithms"`
+	TokenSources           []string               `yaml:"tokensources"`
+	HTTPProxy              string                 `yaml:"httpproxy"`
+	HTTPCAFile             string                 `yaml:"httpcafile"`
 }
 
 // Claims defines the roles and username claims for the given oidc provider
 type Claims struct {
 	Roles    string `yaml:"roles"`
 	Username string `yaml:"username"`
+	Tenant   string `yaml:"tenant"`
 }
 
 // RegisterViperOIDCFlags ensures that the given Viper and cobra.Command instances
@@ -30,18 +54,61 @@ type Claims struct {
 //
 // - oidc: Enables/disables OIDC authentication.
 //
-// - oidc-aud: Specifies the expected audience for the JWT token.
+// - oidc-aud: Specifies the expected audience for the JWT token (can be more than one value,
+// a token for any of them is accepted).
 //
 // - oidc-issuer: Specifies the expected issuer for the JWT token (can be more than one value).
 //
 // - oidc-jwksuri: Specifies the JSON Web Key Set (JWKS) URI (can be more than one value).
 //
+// - oidc-jwks-discovery: Discovers the JWKS URI from the openid-configuration of the http(s) issuers
+// instead of setting oidc-jwksuri.
+//
 // - oidc-roles-claim: Specifies the roles to be accepted for the JWT claim.
 //
 // - oidc-username-claim: Specifies a username to use for the JWT claim.
 //
+// - oidc-tenant-claim: Specifies the JWT claim holding the tenant or organization.
+//
 // - oidc-jwks-remotetimeout: Specifies a timeout for the JWKS URI.
 //
+// - oidc-role-strategy: Specifies the role validation strategy (any or all). The previous
+// oidc-role-validation-strategy name is accepted as an alias.
+//
+// - oidc-clock-skew: Specifies the leeway allowed when validating the JWT time claims, the ClockSkew
+// of the AuthConfig, also named Leeway.
+//
+// - oidc-disabled-mode: Specifies the behavior when OIDC is disabled (disabled-allow,
+// disabled-warn or disabled-deny).
+//
+// - oidc-jwks-startup-retries: Specifies how many times fetching the JWKS is retried at startup.
+//
+// - oidc-jwks-startup-backoff: Specifies the wait before the first JWKS fetch retry, doubling with each retry.
+//
+// - oidc-jwks-start-degraded: Starts even when the JWKS couldn't be fetched, retrying in the background.
+//
+// - oidc-jwks-refresh-interval: Specifies how often the JWKS is refreshed in the background.
+//
+// - oidc-jwks-refresh-jitter: Specifies the random wait added to each background JWKS refresh interval.
+//
+// - oidc-retired-key-grace-period: Specifies how long tokens signed with keys removed from the JWKS are still accepted.
+//
+// - oidc-allowed-algorithm: Specifies a JOSE algorithm the JWT may be signed with (can be repeated).
+//
+// - oidc-token-source: Specifies a source the JWT is read from, tried in order (can be repeated),
+// see ParseTokenExtractors.
+//
+// - oidc-http-proxy: Specifies the proxy the JWKS and OpenID provider metadata are fetched through.
+//
+// - oidc-http-ca-file: Specifies a PEM file of CA certificates trusted when fetching the JWKS and
+// OpenID provider metadata, in addition to the system ones.
+//
+// - oidc-provider-preset: Specifies the identity provider preset (azuread, google, auth0 or keycloak).
+//
+// - oidc-hosted-domain: Specifies the Google Workspace domains accepted with the google preset (can be repeated).
+//
+// - oidc-accept-authorized-party: Accepts the azp claim as the audience, e.g. with the keycloak preset.
+//
 // A call to this would normally look as follows:
 //
 //	ginjwt.RegisterViperOIDCFlags(viper.GetViper(), serveCmd)
@@ -51,20 +118,75 @@ type Claims struct {
 func RegisterViperOIDCFlags(v *viper.Viper, cmd *cobra.Command) {
 	cmd.Flags().Bool("oidc", true, "use oidc auth")
 	BindFlagFromViperInst(v, "oidc.enabled", cmd.Flags().Lookup("oidc"))
-	cmd.Flags().String("oidc-aud", "", "expected audience on OIDC JWT")
-	BindFlagFromViperInst(v, "oidc.audience", cmd.Flags().Lookup("oidc-aud"))
+	cmd.Flags().StringSlice("oidc-aud", []string{}, "expected audience on OIDC JWT (can be repeated)")
+	BindFlagFromViperInst(v, "oidc.audiences", cmd.Flags().Lookup("oidc-aud"))
 	cmd.Flags().StringSlice("oidc-issuer", []string{}, "expected issuer of OIDC JWT")
 	BindFlagFromViperInst(v, "oidc.issuer", cmd.Flags().Lookup("oidc-issuer"))
 	cmd.Flags().StringSlice("oidc-jwksuri", []string{}, "URI for JWKS listing for JWTs")
 	BindFlagFromViperInst(v, "oidc.jwksuri", cmd.Flags().Lookup("oidc-jwksuri"))
+	cmd.Flags().Bool("oidc-jwks-discovery", false, "discover the JWKS URI from the openid-configuration of the issuers instead of setting oidc-jwksuri")
+	BindFlagFromViperInst(v, "oidc.jwksdiscovery", cmd.Flags().Lookup("oidc-jwks-discovery"))
 	cmd.Flags().String("oidc-roles-claim", "claim", "field containing the permissions of an OIDC JWT")
 	BindFlagFromViperInst(v, "oidc.claims.roles", cmd.Flags().Lookup("oidc-roles-claim"))
 	cmd.Flags().String("oidc-username-claim", "", "additional fields to output in logs from the JWT token, ex (email)")
 	BindFlagFromViperInst(v, "oidc.claims.username", cmd.Flags().Lookup("oidc-username-claim"))
+	cmd.Flags().String("oidc-tenant-claim", "", "field containing the tenant or organization of an OIDC JWT")
+	BindFlagFromViperInst(v, "oidc.claims.tenant", cmd.Flags().Lookup("oidc-tenant-claim"))
 	cmd.Flags().Duration("oidc-jwks-remote-timeout", 1*time.Minute, "timeout for remote JWKS fetching")
 	BindFlagFromViperInst(v, "oidc.jwksremotetimeout", cmd.Flags().Lookup("oidc-jwks-remote-timeout"))
-	cmd.Flags().String("oidc-role-validation-strategy", string(RoleValidationStrategyAny), "validation strategy for roles (any or all)")
-	BindFlagFromViperInst(v, "oidc.rolevalidationstrategy", cmd.Flags().Lookup("oidc-role-validation-strategy"))
+	cmd.Flags().String("oidc-role-strategy", string(RoleValidationStrategyAny), "validation strategy for roles (any or all)")
+	BindFlagFromViperInst(v, "oidc.rolevalidationstrategy", cmd.Flags().Lookup("oidc-role-strategy"))
+
+	cmd.Flags().Duration("oidc-clock-skew", jwt.DefaultLeeway, "allowed clock skew when validating OIDC JWT times")
+	BindFlagFromViperInst(v, "oidc.clockskew", cmd.Flags().Lookup("oidc-clock-skew"))
+	cmd.Flags().String("oidc-disabled-mode", string(DisabledModeAllow), "behavior when oidc is disabled (disabled-allow, disabled-warn or disabled-deny)")
+	BindFlagFromViperInst(v, "oidc.disabledmode", cmd.Flags().Lookup("oidc-disabled-mode"))
+	cmd.Flags().Int("oidc-jwks-startup-retries", 0, "number of retries when fetching the JWKS fails at startup")
+	BindFlagFromViperInst(v, "oidc.jwksstartupretries", cmd.Flags().Lookup("oidc-jwks-startup-retries"))
+	cmd.Flags().Duration("oidc-jwks-startup-backoff", DefaultJWKSStartupBackoff, "wait before the first JWKS fetch retry, doubling with each retry")
+	BindFlagFromViperInst(v, "oidc.jwksstartupbackoff", cmd.Flags().Lookup("oidc-jwks-startup-backoff"))
+	cmd.Flags().Bool("oidc-jwks-start-degraded", false, "start even when the JWKS couldn't be fetched, retrying in the background")
+	BindFlagFromViperInst(v, "oidc.jwksstartdegraded", cmd.Flags().Lookup("oidc-jwks-start-degraded"))
+	cmd.Flags().Bool("oidc-jwks-lazy-fetch", false, "fetch the JWKS when verifying the first token instead of at startup")
+	BindFlagFromViperInst(v, "oidc.jwkslazyfetch", cmd.Flags().Lookup("oidc-jwks-lazy-fetch"))
+	cmd.Flags().Duration("oidc-jwks-refresh-interval", 0, "how often the JWKS is refreshed in the background, only for unknown keys when unset")
+	BindFlagFromViperInst(v, "oidc.jwksrefreshinterval", cmd.Flags().Lookup("oidc-jwks-refresh-interval"))
+	cmd.Flags().Duration("oidc-jwks-refresh-jitter", 0, "random wait added to each background JWKS refresh interval")
+	BindFlagFromViperInst(v, "oidc.jwksrefreshjitter", cmd.Flags().Lookup("oidc-jwks-refresh-jitter"))
+	cmd.Flags().Duration("oidc-retired-key-grace-period", 0, "how long tokens signed with keys removed from the JWKS are still accepted")
+	BindFlagFromViperInst(v, "oidc.retiredkeygraceperiod", cmd.Flags().Lookup("oidc-retired-key-grace-period"))
+	cmd.Flags().StringSlice("oidc-allowed-algorithm", []string{}, "JOSE algorithm the JWT may be signed with, all the asymmetric ones when unset (can be repeated)")
+	BindFlagFromViperInst(v, "oidc.allowedalgorithms", cmd.Flags().Lookup("oidc-allowed-algorithm"))
+	cmd.Flags().StringSlice("oidc-token-source", []string{}, "source the JWT is read from, e.g. bearer, cookie:session or query:access_token, the Authorization header when unset (can be repeated)")
+	BindFlagFromViperInst(v, "oidc.tokensources", cmd.Flags().Lookup("oidc-token-source"))
+	cmd.Flags().String("oidc-http-proxy", "", "proxy the JWKS and OpenID provider metadata are fetched through, from the environment when unset")
+	BindFlagFromViperInst(v, "oidc.httpproxy", cmd.Flags().Lookup("oidc-http-proxy"))
+	cmd.Flags().String("oidc-http-ca-file", "", "PEM file of CA certificates trusted when fetching the JWKS and OpenID provider metadata")
+	BindFlagFromViperInst(v, "oidc.httpcafile", cmd.Flags().Lookup("oidc-http-ca-file"))
+	cmd.Flags().String("oidc-provider-preset", "", "identity provider preset (azuread, google, auth0 or keycloak)")
+	BindFlagFromViperInst(v, "oidc.providerpreset", cmd.Flags().Lookup("oidc-provider-preset"))
+	cmd.Flags().StringSlice("oidc-hosted-domain", []string{}, "Google Workspace domain accepted with the google preset (can be repeated)")
+	BindFlagFromViperInst(v, "oidc.hosteddomains", cmd.Flags().Lookup("oidc-hosted-domain"))
+	cmd.Flags().Bool("oidc-accept-authorized-party", false, "accept the azp claim as the audience, e.g. for keycloak access tokens")
+	BindFlagFromViperInst(v, "oidc.acceptauthorizedparty", cmd.Flags().Lookup("oidc-accept-authorized-party"))
+
+	normalizeFlagAliases(cmd.Flags(), map[string]string{
+		"oidc-role-validation-strategy": "oidc-role-strategy",
+	})
+}
+
+// normalizeFlagAliases makes the given flag set accept the alias names for flags,
+// any existing normalization function is kept.
+func normalizeFlagAliases(fs *pflag.FlagSet, aliases map[string]string) {
+	prev := fs.GetNormalizeFunc()
+
+	fs.SetNormalizeFunc(func(f *pflag.FlagSet, name string) pflag.NormalizedName {
+		if target, ok := aliases[name]; ok {
+			name = target
+		}
+
+		return prev(f, name)
+	})
 }
 
 // GetAuthConfigFromFlags builds an AuthConfig object from flags provided by
@@ -77,9 +199,9 @@ func RegisterViperOIDCFlags(v *viper.Viper, cmd *cobra.Command) {
 //
 // Note that when using this function configuration
 func GetAuthConfigFromFlags(v *viper.Viper) (AuthConfig, error) {
-	var authConfigs []OIDCConfig
-	if err := v.UnmarshalKey("oidc", &authConfigs); err != nil {
-		return AuthConfig{}, ErrInvalidAuthConfig
+	authConfigs, err := oidcConfigsFromViper(v)
+	if err != nil {
+		return AuthConfig{}, err
 	}
 
 	if len(authConfigs) == 0 {
@@ -89,26 +211,56 @@ func GetAuthConfigFromFlags(v *viper.Viper) (AuthConfig, error) {
 	config := authConfigs[0]
 
 	if !config.Enabled {
-		return AuthConfig{}, nil
+		return AuthConfig{DisabledMode: config.DisabledMode}, nil
 	}
 
 	if config.Issuer == "" {
 		return AuthConfig{}, ErrMissingIssuerFlag
 	}
 
-	if config.JWKSURI == "" {
+	if config.JWKSURI == "" && !config.JWKSDiscovery {
 		return AuthConfig{}, ErrMissingJWKURIFlag
 	}
 
+	extractors, err := ParseTokenExtractors(config.TokenSources)
+	if err != nil {
+		return AuthConfig{}, err
+	}
+
+	httpClient, err := config.httpClient()
+	if err != nil {
+		return AuthConfig{}, err
+	}
+
 	return AuthConfig{
 		Enabled:                config.Enabled,
 		Audience:               config.Audience,
 		Issuer:                 config.Issuer,
 		JWKSURI:                config.JWKSURI,
+		JWKSDiscovery:          config.JWKSDiscovery,
 		JWKSRemoteTimeout:      config.JWKSRemoteTimeout,
 		RoleValidationStrategy: config.RoleValidationStrategy,
 		RolesClaim:             config.Claims.Roles,
 		UsernameClaim:          config.Claims.Username,
+		TenantClaim:            config.Claims.Tenant,
+		Audiences:              config.Audiences,
+		ClockSkew:              config.ClockSkew,
+		Leeway:                 config.Leeway,
+		DisabledMode:           config.DisabledMode,
+		JWKSStartupRetries:     config.JWKSStartupRetries,
+		JWKSStartupBackoff:     config.JWKSStartupBackoff,
+		JWKSStartDegraded:      config.JWKSStartDegraded,
+		JWKSLazyFetch:          config.JWKSLazyFetch,
+		JWKSRefreshInterval:    config.JWKSRefreshInterval,
+		JWKSRefreshJitter:      config.JWKSRefreshJitter,
+		ProviderPreset:         config.ProviderPreset,
+		HostedDomains:          config.HostedDomains,
+		AcceptAuthorizedParty:  config.AcceptAuthorizedParty,
+		AudienceScopes:         config.AudienceScopes,
+		RetiredKeyGracePeriod:  config.RetiredKeyGracePeriod,
+		AllowedAlgorithms:      config.AllowedAlgorithms,
+		TokenExtractors:        extractors,
+		HTTPClient:             httpClient,
 	}, nil
 }
 
@@ -123,9 +275,9 @@ func GetAuthConfigFromFlags(v *viper.Viper) (AuthConfig, error) {
 // Note that this function will retrieve as many AuthConfigs as the number
 // of issuers and JWK URIs given (which must match)
 func GetAuthConfigsFromFlags(v *viper.Viper) ([]AuthConfig, error) {
-	var authConfigs []OIDCConfig
-	if err := v.UnmarshalKey("oidc", &authConfigs); err != nil {
-		return []AuthConfig{}, ErrInvalidAuthConfig
+	authConfigs, err := oidcConfigsFromViper(v)
+	if err != nil {
+		return []AuthConfig{}, err
 	}
 
 	if len(authConfigs) == 0 {
@@ -140,20 +292,50 @@ func GetAuthConfigsFromFlags(v *viper.Viper) ([]AuthConfig, error) {
 				return []AuthConfig{}, ErrMissingIssuerFlag
 			}
 
-			if c.JWKSURI == "" {
+			if c.JWKSURI == "" && !c.JWKSDiscovery {
 				return []AuthConfig{}, ErrMissingJWKURIFlag
 			}
 
+			extractors, err := ParseTokenExtractors(c.TokenSources)
+			if err != nil {
+				return []AuthConfig{}, err
+			}
+
+			httpClient, err := c.httpClient()
+			if err != nil {
+				return []AuthConfig{}, err
+			}
+
 			authcfgs = append(authcfgs,
 				AuthConfig{
 					Enabled:                c.Enabled,
 					Audience:               c.Audience,
 					Issuer:                 c.Issuer,
 					JWKSURI:                c.JWKSURI,
+					JWKSDiscovery:          c.JWKSDiscovery,
 					JWKSRemoteTimeout:      c.JWKSRemoteTimeout,
 					RoleValidationStrategy: c.RoleValidationStrategy,
 					RolesClaim:             c.Claims.Roles,
 					UsernameClaim:          c.Claims.Username,
+					TenantClaim:            c.Claims.Tenant,
+					Audiences:              c.Audiences,
+					ClockSkew:              c.ClockSkew,
+					Leeway:                 c.Leeway,
+					DisabledMode:           c.DisabledMode,
+					JWKSStartupRetries:     c.JWKSStartupRetries,
+					JWKSStartupBackoff:     c.JWKSStartupBackoff,
+					JWKSStartDegraded:      c.JWKSStartDegraded,
+					JWKSLazyFetch:          c.JWKSLazyFetch,
+					JWKSRefreshInterval:    c.JWKSRefreshInterval,
+					JWKSRefreshJitter:      c.JWKSRefreshJitter,
+					ProviderPreset:         c.ProviderPreset,
+					HostedDomains:          c.HostedDomains,
+					AcceptAuthorizedParty:  c.AcceptAuthorizedParty,
+					AudienceScopes:         c.AudienceScopes,
+					RetiredKeyGracePeriod:  c.RetiredKeyGracePeriod,
+					AllowedAlgorithms:      c.AllowedAlgorithms,
+					TokenExtractors:        extractors,
+					HTTPClient:             httpClient,
 				},
 			)
 		}
@@ -162,6 +344,91 @@ func GetAuthConfigsFromFlags(v *viper.Viper) ([]AuthConfig, error) {
 	return authcfgs, nil
 }
 
+// oidcConfigsFromViper returns the oidc configurations set in the viper instance. When
+// the oidc configuration was only provided through command line flags, one configuration
+// is returned for each issuer and JWKS URI pair.
+func oidcConfigsFromViper(v *viper.Viper) ([]OIDCConfig, error) {
+	if v.Get("oidc") == nil {
+		return oidcConfigsFromFlags(v)
+	}
+
+	var authConfigs []OIDCConfig
+	if err := v.UnmarshalKey("oidc", &authConfigs); err != nil {
+		return nil, ErrInvalidAuthConfig
+	}
+
+	return authConfigs, nil
+}
+
+func oidcConfigsFromFlags(v *viper.Viper) ([]OIDCConfig, error) {
+	issuers := v.GetStringSlice("oidc.issuer")
+	jwksURIs := v.GetStringSlice("oidc.jwksuri")
+
+	// without JWKS URIs they are all discovered from the issuers with oidc-jwks-discovery
+	if len(jwksURIs) > 0 && len(issuers) != len(jwksURIs) {
+		return nil, fmt.Errorf("%w: the number of issuers and JWKS URIs must match", ErrInvalidAuthConfig)
+	}
+
+	base := OIDCConfig{
+		Enabled:                v.GetBool("oidc.enabled"),
+		Audience:               v.GetString("oidc.audience"),
+		Audiences:              v.GetStringSlice("oidc.audiences"),
+		JWKSDiscovery:          v.GetBool("oidc.jwksdiscovery"),
+		JWKSRemoteTimeout:      v.GetDuration("oidc.jwksremotetimeout"),
+		RoleValidationStrategy: RoleValidationStrategy(v.GetString("oidc.rolevalidationstrategy")),
+		ClockSkew:              v.GetDuration("oidc.clockskew"),
+		DisabledMode:           DisabledMode(v.GetString("oidc.disabledmode")),
+		JWKSStartupRetries:     v.GetInt("oidc.jwksstartupretries"),
+		JWKSStartupBackoff:     v.GetDuration("oidc.jwksstartupbackoff"),
+		JWKSStartDegraded:      v.GetBool("oidc.jwksstartdegraded"),
+		JWKSLazyFetch:          v.GetBool("oidc.jwkslazyfetch"),
+		JWKSRefreshInterval:    v.GetDuration("oidc.jwksrefreshinterval"),
+		JWKSRefreshJitter:      v.GetDuration("oidc.jwksrefreshjitter"),
+		ProviderPreset:         ProviderPreset(v.GetString("oidc.providerpreset")),
+		HostedDomains:          v.GetStringSlice("oidc.hosteddomains"),
+		AcceptAuthorizedParty:  v.GetBool("oidc.acceptauthorizedparty"),
+		AudienceScopes:         v.GetStringMapStringSlice("oidc.audiencescopes"),
+		RetiredKeyGracePeriod:  v.GetDuration("oidc.retiredkeygraceperiod"),
+		AllowedAlgorithms:      v.GetStringSlice("oidc.allowedalgorithms"),
+		TokenSources:           v.GetStringSlice("oidc.tokensources"),
+		HTTPProxy:              v.GetString("oidc.httpproxy"),
+		HTTPCAFile:             v.GetString("oidc.httpcafile"),
+		Claims: Claims{
+			Roles:    v.GetString("oidc.claims.roles"),
+			Username: v.GetString("oidc.claims.username"),
+			Tenant:   v.GetString("oidc.claims.tenant"),
+		},
+	}
+
+	// a single configuration lets the missing issuer and JWKS URI be reported.
+	if len(issuers) == 0 {
+		return []OIDCConfig{base}, nil
+	}
+
+	authConfigs := make([]OIDCConfig, len(issuers))
+
+	for i := range issuers {
+		authConfigs[i] = base
+		authConfigs[i].Issuer = issuers[i]
+
+		if len(jwksURIs) > 0 {
+			authConfigs[i].JWKSURI = jwksURIs[i]
+		}
+	}
+
+	return authConfigs, nil
+}
+
+// httpClient returns the client going through the HTTPProxy and trusting the HTTPCAFile, nil
+// for the default client when neither is set.
+func (c OIDCConfig) httpClient() (*http.Client, error) {
+	if c.HTTPProxy == "" && c.HTTPCAFile == "" {
+		return nil, nil
+	}
+
+	return NewHTTPClient(HTTPClientConfig{ProxyURL: c.HTTPProxy, CAFile: c.HTTPCAFile})
+}
+
 // ViperBindFlag provides a wrapper around the viper bindings that handles error checks
 func ViperBindFlag(name string, flag *pflag.Flag) {
 	BindFlagFromViperInst(viper.GetViper(), name, flag)
@@ -69,6 +69,31 @@ func TestRegisterViperOIDCFlagsSingleProvider(t *testing.T) {
 			},
 			wantErr: true,
 		},
+		{
+			name: "Get AuthConfig fails due to missing JWK URI without discovery",
+			expectedAuthConfig: ginjwt.AuthConfig{
+				Enabled:       true,
+				Audience:      "beer",
+				Issuer:        "https://auth.example.com",
+				JWKSURI:       "",
+				RolesClaim:    "quite",
+				UsernameClaim: "tasty",
+			},
+			wantErr: true,
+		},
+		{
+			name: "Get AuthConfig with the JWK URI discovered from the issuer",
+			expectedAuthConfig: ginjwt.AuthConfig{
+				Enabled:                true,
+				Audience:               "beer",
+				Issuer:                 "https://auth.example.com",
+				JWKSURI:                "",
+				JWKSDiscovery:          true,
+				RolesClaim:             "quite",
+				UsernameClaim:          "tasty",
+				RoleValidationStrategy: ginjwt.RoleValidationStrategyAny,
+			},
+		},
 	}
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
@@ -81,6 +106,7 @@ func TestRegisterViperOIDCFlagsSingleProvider(t *testing.T) {
 			v.Set("oidc.audience", tc.expectedAuthConfig.Audience)
 			v.Set("oidc.issuer", tc.expectedAuthConfig.Issuer)
 			v.Set("oidc.jwksuri", tc.expectedAuthConfig.JWKSURI)
+			v.Set("oidc.jwksdiscovery", tc.expectedAuthConfig.JWKSDiscovery)
 			v.Set("oidc.claims.roles", tc.expectedAuthConfig.RolesClaim)
 			v.Set("oidc.claims.username", tc.expectedAuthConfig.UsernameClaim)
 			v.Set("oidc.jwksremotetimeout", tc.expectedAuthConfig.JWKSRemoteTimeout)
@@ -95,6 +121,7 @@ func TestRegisterViperOIDCFlagsSingleProvider(t *testing.T) {
 				assert.Equal(t, tc.expectedAuthConfig.Audience, gotAT.Audience)
 				assert.Equal(t, tc.expectedAuthConfig.Issuer, gotAT.Issuer)
 				assert.Equal(t, tc.expectedAuthConfig.JWKSURI, gotAT.JWKSURI)
+				assert.Equal(t, tc.expectedAuthConfig.JWKSDiscovery, gotAT.JWKSDiscovery)
 				assert.Equal(t, tc.expectedAuthConfig.JWKSRemoteTimeout, gotAT.JWKSRemoteTimeout)
 				assert.Equal(t, tc.expectedAuthConfig.RolesClaim, gotAT.RolesClaim)
 				assert.Equal(t, tc.expectedAuthConfig.UsernameClaim, gotAT.UsernameClaim)
@@ -478,3 +505,48 @@ func TestRegisterViperOIDCFlagsForMultipleConfigs(t *testing.T) {
 		})
 	}
 }
+
+func TestRegisterViperOIDCFlagsFromCommandLine(t *testing.T) {
+	v := viper.New()
+	cmd := &cobra.Command{}
+
+	ginjwt.RegisterViperOIDCFlags(v, cmd)
+
+	err := cmd.ParseFlags([]string{
+		"--oidc-aud", "tacos",
+		"--oidc-aud", "burritos",
+		"--oidc-issuer", "are",
+		"--oidc-jwksuri", "https://bit.ly/3HlVmWp",
+		"--oidc-clock-skew", "30s",
+		"--oidc-retired-key-grace-period", "1h",
+		"--oidc-jwks-refresh-interval", "15m",
+		"--oidc-jwks-refresh-jitter", "1m",
+		"--oidc-allowed-algorithm", "ES256",
+		"--oidc-allowed-algorithm", "EdDSA",
+		"--oidc-token-source", "cookie:session",
+		"--oidc-token-source", "bearer",
+		"--oidc-http-proxy", "http://egress-proxy:3128",
+		"--oidc-role-validation-strategy", "all",
+	})
+	assert.NoError(t, err)
+
+	gotAT, err := ginjwt.GetAuthConfigFromFlags(v)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"tacos", "burritos"}, gotAT.Audiences)
+	assert.Equal(t, 30*time.Second, gotAT.ClockSkew)
+	assert.Equal(t, time.Hour, gotAT.RetiredKeyGracePeriod)
+	assert.Equal(t, 15*time.Minute, gotAT.JWKSRefreshInterval)
+	assert.Equal(t, time.Minute, gotAT.JWKSRefreshJitter)
+	assert.Equal(t, []string{"ES256", "EdDSA"}, gotAT.AllowedAlgorithms)
+	assert.Len(t, gotAT.TokenExtractors, 2)
+	assert.NotNil(t, gotAT.HTTPClient)
+	assert.Equal(t, ginjwt.RoleValidationStrategyAll, gotAT.RoleValidationStrategy)
+
+	err = cmd.ParseFlags([]string{"--oidc-role-strategy", "any"})
+	assert.NoError(t, err)
+
+	gotAT, err = ginjwt.GetAuthConfigFromFlags(v)
+	assert.NoError(t, err)
+	assert.Equal(t, ginjwt.RoleValidationStrategyAny, gotAT.RoleValidationStrategy)
+}
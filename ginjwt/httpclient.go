@@ -0,0 +1,58 @@
+package ginjwt
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+)
+
+// HTTPClientConfig provides the settings of the client reaching the identity provider, see NewHTTPClient
+type HTTPClientConfig struct {
+	// ProxyURL is the proxy the requests go through, e.g. http://egress-proxy:3128. Defaults to the
+	// proxy of the HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables if unspecified.
+	ProxyURL string
+	// CAFile is a PEM file of CA certificates trusted in addition to the system ones, e.g. the private
+	// CA of the identity provider or of a TLS intercepting proxy.
+	CAFile string
+}
+
+// NewHTTPClient returns a client going through the proxy and trusting the CA of the config, to be set
+// as the AuthConfig HTTPClient.
+func NewHTTPClient(cfg HTTPClientConfig) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if cfg.ProxyURL != "" {
+		proxyURL, err := url.Parse(cfg.ProxyURL)
+		if err != nil || proxyURL.Host == "" {
+			return nil, fmt.Errorf("%w: invalid proxy URL %q", ErrInvalidAuthConfig, cfg.ProxyURL)
+		}
+
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if cfg.CAFile != "" {
+		pem, err := os.ReadFile(cfg.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidAuthConfig, err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: no certificates in CA file %s", ErrInvalidAuthConfig, cfg.CAFile)
+		}
+
+		transport.TLSClientConfig = &tls.Config{
+			MinVersion: tls.VersionTLS12,
+			RootCAs:    pool,
+		}
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
@@ -0,0 +1,121 @@
+package ginjwt_test
+
+import (
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+func jwksHandler(kid string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(ginjwt.TestHelperJoseJWKSProvider(kid))
+	}
+}
+
+func TestJWKSCustomHTTPClient(t *testing.T) {
+	srv := httptest.NewTLSServer(jwksHandler(ginjwt.TestPrivRSAKey1ID))
+	defer srv.Close()
+
+	cfg := ginjwt.AuthConfig{
+		Enabled:  true,
+		Audience: "ginjwt.test",
+		Issuer:   "ginjwt.test.issuer",
+		JWKSURI:  srv.URL,
+	}
+
+	// the default client doesn't trust the certificate of the test server
+	_, err := ginjwt.NewAuthMiddleware(cfg)
+	require.Error(t, err)
+
+	cfg.HTTPClient = srv.Client()
+
+	authMW, err := ginjwt.NewAuthMiddleware(cfg)
+	require.NoError(t, err)
+
+	signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+
+	_, err = authMW.VerifyToken(newJWKSTestContext(signer))
+	assert.NoError(t, err)
+}
+
+func TestNewHTTPClientCAFile(t *testing.T) {
+	srv := httptest.NewTLSServer(jwksHandler(ginjwt.TestPrivRSAKey1ID))
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0o600))
+
+	client, err := ginjwt.NewHTTPClient(ginjwt.HTTPClientConfig{CAFile: caFile})
+	require.NoError(t, err)
+
+	_, err = ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:    true,
+		Audience:   "ginjwt.test",
+		Issuer:     "ginjwt.test.issuer",
+		JWKSURI:    srv.URL,
+		HTTPClient: client,
+	})
+	assert.NoError(t, err)
+}
+
+func TestNewHTTPClientProxy(t *testing.T) {
+	var proxied int32
+
+	// the proxy serves the JWKS of the unreachable identity provider
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Host != "idp.invalid" {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		atomic.AddInt32(&proxied, 1)
+		jwksHandler(ginjwt.TestPrivRSAKey1ID)(w, r)
+	}))
+	defer proxy.Close()
+
+	client, err := ginjwt.NewHTTPClient(ginjwt.HTTPClientConfig{ProxyURL: proxy.URL})
+	require.NoError(t, err)
+
+	_, err = ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:    true,
+		Audience:   "ginjwt.test",
+		Issuer:     "ginjwt.test.issuer",
+		JWKSURI:    "http://idp.invalid/keys",
+		HTTPClient: client,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&proxied))
+}
+
+func TestNewHTTPClientInvalidConfig(t *testing.T) {
+	empty := filepath.Join(t.TempDir(), "empty.pem")
+	require.NoError(t, os.WriteFile(empty, nil, 0o600))
+
+	testCases := []struct {
+		testName string
+		cfg      ginjwt.HTTPClientConfig
+	}{
+		{"proxy without host", ginjwt.HTTPClientConfig{ProxyURL: "proxy"}},
+		{"missing CA file", ginjwt.HTTPClientConfig{CAFile: filepath.Join(t.TempDir(), "missing.pem")}},
+		{"CA file without certificates", ginjwt.HTTPClientConfig{CAFile: empty}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.testName, func(t *testing.T) {
+			_, err := ginjwt.NewHTTPClient(tt.cfg)
+			assert.ErrorIs(t, err, ginjwt.ErrInvalidAuthConfig)
+		})
+	}
+}
@@ -0,0 +1,362 @@
+package ginjwt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.uber.org/zap"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"go.hollow.sh/toolbox/ginauth"
+)
+
+const (
+	// DefaultIntrospectionTimeout bounds the introspection requests when IntrospectionConfig.Timeout is unspecified
+	DefaultIntrospectionTimeout = 10 * time.Second
+
+	// DefaultIntrospectionRolesClaim is the member of the introspection response the roles are read
+	// from when IntrospectionConfig.RolesClaim is unspecified
+	DefaultIntrospectionRolesClaim = "scope"
+
+	// maximum size of the introspection responses read
+	maxIntrospectionResponseSize = 1 << 20
+)
+
+// IntrospectionConfig provides the configuration of the IntrospectionMiddleware
+type IntrospectionConfig struct {
+	// Endpoint is the token introspection endpoint. When unspecified it is discovered from the
+	// OpenID provider metadata of the Issuer, which must then be an http(s) URL.
+	Endpoint string
+	// ClientID and ClientSecret authenticate the middleware to the introspection endpoint with HTTP basic auth.
+	ClientID     string
+	ClientSecret string
+	// Issuer is the issuer tokens are accepted from, the iss member of the response must match it when present.
+	Issuer string
+	// Audience and Audiences are the audiences tokens are accepted for, a response must have any of
+	// them when set. Tokens for any audience are accepted when unset.
+	Audience  string
+	Audiences []string
+	// RolesClaim is the member of the response holding the roles. Defaults to DefaultIntrospectionRolesClaim if unspecified.
+	RolesClaim string
+	// UsernameClaim is the member of the response holding the user. Defaults to the username member,
+	// then the subject, if unspecified.
+	UsernameClaim string
+	// TenantClaim is the member of the response holding the tenant, read with GetTenant. No tenant
+	// is read if unspecified.
+	TenantClaim string
+	// Role validation strategy for roles claim. Defaults to any if unspecified.
+	RoleValidationStrategy RoleValidationStrategy
+	// ClockSkew is the leeway allowed when validating the exp, nbf and iat members of the response.
+	// Defaults to jwt.DefaultLeeway if unspecified.
+	ClockSkew time.Duration
+	// Timeout bounds each introspection request. Defaults to DefaultIntrospectionTimeout if unspecified.
+	Timeout time.Duration
+	// HTTPClient sends the introspection requests and discovers the endpoint, see AuthConfig.HTTPClient.
+	// The Timeout applies unless the client has its own. Defaults to a client with the default transport if unspecified.
+	HTTPClient *http.Client
+	// TokenExtractors read the token of the requests, see AuthConfig.TokenExtractors. Defaults to the
+	// Authorization header and the grpc-gateway header if unspecified.
+	TokenExtractors []TokenExtractor
+	// CacheTTL caches the metadata of active tokens for this long, never past their expiry, so each
+	// request doesn't introspect the token. Tokens revoked within the TTL keep being accepted until
+	// it ends. Tokens are introspected on each request if unspecified.
+	CacheTTL time.Duration
+	// CacheSize is the number of tokens cached. Defaults to DefaultDecisionCacheSize if unspecified.
+	CacheSize int
+	// MessageCatalog customizes the messages of the errors the middleware responds with, the
+	// catalog set with ginauth.UseMessageCatalog is used when unset.
+	MessageCatalog ginauth.MessageCatalog
+	// Logger is used to report the endpoint discovery. Defaults to a no-op logger if unspecified.
+	Logger *zap.Logger
+}
+
+// IntrospectionMiddleware verifies opaque tokens, which can't be validated locally, by POSTing them
+// to the token introspection endpoint of the identity provider, see RFC 7662. The members of the
+// response are mapped into the ClaimMetadata as the claims of a JWT would be.
+type IntrospectionMiddleware struct {
+	config     IntrospectionConfig
+	audiences  []string
+	client     *http.Client
+	extractors []TokenExtractor
+	logger     *zap.Logger
+
+	// tokens caches the metadata of active tokens, nil unless CacheTTL is set
+	tokens *decisionCache
+
+	endpointMu sync.Mutex
+	endpoint   string
+}
+
+// NewIntrospectionMiddleware returns an IntrospectionMiddleware introspecting tokens with the configured endpoint
+func NewIntrospectionMiddleware(cfg IntrospectionConfig) (*IntrospectionMiddleware, error) {
+	if cfg.Endpoint != "" && !isHTTPURL(cfg.Endpoint) {
+		return nil, fmt.Errorf("%w: introspection endpoint %s isn't an http(s) URL", ErrInvalidAuthConfig, cfg.Endpoint)
+	}
+
+	if cfg.Endpoint == "" && !isHTTPURL(cfg.Issuer) {
+		return nil, fmt.Errorf("%w: an introspection endpoint or an http(s) issuer to discover it from is required", ErrInvalidAuthConfig)
+	}
+
+	if cfg.ClockSkew < 0 {
+		return nil, fmt.Errorf("%w: the clock skew can't be negative", ErrInvalidAuthConfig)
+	}
+
+	switch cfg.RoleValidationStrategy {
+	case "", RoleValidationStrategyAny, RoleValidationStrategyAll:
+	default:
+		return nil, fmt.Errorf("%w: unknown role validation strategy %q", ErrInvalidAuthConfig, cfg.RoleValidationStrategy)
+	}
+
+	if cfg.RolesClaim == "" {
+		cfg.RolesClaim = DefaultIntrospectionRolesClaim
+	}
+
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultIntrospectionTimeout
+	}
+
+	client := http.Client{}
+	if cfg.HTTPClient != nil {
+		client = *cfg.HTTPClient
+	}
+
+	if client.Timeout == 0 {
+		client.Timeout = cfg.Timeout
+	}
+
+	im := &IntrospectionMiddleware{
+		config:     cfg,
+		audiences:  (&AuthConfig{Audience: cfg.Audience, Audiences: cfg.Audiences}).audiences(),
+		client:     &client,
+		extractors: cfg.TokenExtractors,
+		logger:     cfg.Logger,
+		endpoint:   cfg.Endpoint,
+	}
+
+	if len(im.extractors) == 0 {
+		im.extractors = defaultTokenExtractors(AuthConfig{})
+	}
+
+	if im.logger == nil {
+		im.logger = zap.NewNop()
+	}
+
+	if cfg.CacheTTL > 0 {
+		im.tokens = newDecisionCache(cfg.CacheTTL, cfg.CacheSize)
+	}
+
+	return im, nil
+}
+
+// SetMetadata sets the needed metadata to the gin context which came from the token
+func (im *IntrospectionMiddleware) SetMetadata(c *gin.Context, cm ginauth.ClaimMetadata) {
+	setMetadata(c, cm)
+}
+
+// VerifyTokenWithScopes introspects the token of the request and validates its roles include the scopes.
+// This implements the GenericAuthMiddleware interface
+func (im *IntrospectionMiddleware) VerifyTokenWithScopes(c *gin.Context, scopes []string) (ginauth.ClaimMetadata, error) {
+	cm, err := im.VerifyToken(c)
+	if err != nil {
+		return ginauth.ClaimMetadata{}, err
+	}
+
+	setContext(c, cm)
+
+	if err := im.VerifyRoles(cm.Roles, scopes); err != nil {
+		return ginauth.ClaimMetadata{}, err
+	}
+
+	return cm, nil
+}
+
+// VerifyRoles checks the given roles against the required scopes using the configured role
+// validation strategy. This implements the ginauth.RoleVerifier interface
+func (im *IntrospectionMiddleware) VerifyRoles(roles, scopes []string) error {
+	return verifyRoles(im.config.RoleValidationStrategy, roles, scopes)
+}
+
+// VerifyToken introspects the token of the request without validating its roles
+func (im *IntrospectionMiddleware) VerifyToken(c *gin.Context) (ginauth.ClaimMetadata, error) {
+	rawToken, err := extractToken(c, im.extractors, len(im.config.TokenExtractors) > 0)
+	if err != nil {
+		return ginauth.ClaimMetadata{}, err
+	}
+
+	var key string
+
+	if im.tokens != nil {
+		key = decisionCacheKey(rawToken, "", nil)
+
+		if e, ok := im.tokens.get(key); ok {
+			return e.cm, nil
+		}
+	}
+
+	cm, expiry, err := im.introspect(c.Request.Context(), rawToken)
+	if err != nil {
+		return ginauth.ClaimMetadata{}, err
+	}
+
+	if im.tokens != nil {
+		im.tokens.set(key, cm, nil, expiry)
+	}
+
+	return cm, nil
+}
+
+// AuthRequired provides a middleware that ensures a request has an active token with the scopes
+func (im *IntrospectionMiddleware) AuthRequired(scopes []string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, err := im.VerifyTokenWithScopes(c, scopes); err != nil {
+			ginauth.AbortWithMessageCatalog(c, err, im.config.MessageCatalog)
+			return
+		}
+	}
+}
+
+// CacheStats returns the metrics of the token cache, all zero when CacheTTL isn't set.
+func (im *IntrospectionMiddleware) CacheStats() DecisionCacheStats {
+	if im.tokens == nil {
+		return DecisionCacheStats{}
+	}
+
+	return im.tokens.stats()
+}
+
+// introspect POSTs the token to the introspection endpoint, returning the metadata of the token
+// and when it expires, zero when the response has no exp.
+func (im *IntrospectionMiddleware) introspect(ctx context.Context, rawToken string) (ginauth.ClaimMetadata, time.Time, error) {
+	endpoint, err := im.introspectionEndpoint(ctx)
+	if err != nil {
+		return ginauth.ClaimMetadata{}, time.Time{}, ginauth.NewAuthenticationErrorFrom(fmt.Errorf("%w: %s", ErrIntrospection, err))
+	}
+
+	form := url.Values{"token": {rawToken}, "token_type_hint": {"access_token"}}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return ginauth.ClaimMetadata{}, time.Time{}, ginauth.NewAuthenticationErrorFrom(fmt.Errorf("%w: %s", ErrIntrospection, err))
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	if im.config.ClientID != "" {
+		// client credentials are form encoded before being used for basic auth, see RFC 6749 section 2.3.1
+		req.SetBasicAuth(url.QueryEscape(im.config.ClientID), url.QueryEscape(im.config.ClientSecret))
+	}
+
+	resp, err := im.client.Do(req)
+	if err != nil {
+		return ginauth.ClaimMetadata{}, time.Time{}, ginauth.NewAuthenticationErrorFrom(fmt.Errorf("%w: %s", ErrIntrospection, err))
+	}
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxIntrospectionResponseSize))
+	if err != nil {
+		return ginauth.ClaimMetadata{}, time.Time{}, ginauth.NewAuthenticationErrorFrom(fmt.Errorf("%w: %s", ErrIntrospection, err))
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return ginauth.ClaimMetadata{}, time.Time{}, ginauth.NewAuthenticationErrorFrom(fmt.Errorf("%w: %s returned %s", ErrIntrospection, endpoint, resp.Status))
+	}
+
+	return im.parseIntrospectionResponse(body)
+}
+
+// parseIntrospectionResponse validates the introspection response and maps its members into the ClaimMetadata
+func (im *IntrospectionMiddleware) parseIntrospectionResponse(body []byte) (ginauth.ClaimMetadata, time.Time, error) {
+	sc := map[string]json.RawMessage{}
+	if err := json.Unmarshal(body, &sc); err != nil {
+		return ginauth.ClaimMetadata{}, time.Time{}, ginauth.NewAuthenticationErrorFrom(fmt.Errorf("%w: %s", ErrIntrospection, err))
+	}
+
+	var active bool
+	if raw, ok := sc["active"]; !ok || json.Unmarshal(raw, &active) != nil || !active {
+		return ginauth.ClaimMetadata{}, time.Time{}, ginauth.NewAuthenticationError("auth token is not active")
+	}
+
+	cl := jwt.Claims{}
+	if err := json.Unmarshal(body, &cl); err != nil {
+		return ginauth.ClaimMetadata{}, time.Time{}, ginauth.NewAuthenticationErrorFrom(fmt.Errorf("%w: %s", ErrIntrospection, err))
+	}
+
+	// the iss and aud members are optional, they are only checked when the response has them
+	if im.config.Issuer != "" && cl.Issuer != "" && cl.Issuer != im.config.Issuer {
+		return ginauth.ClaimMetadata{}, time.Time{}, ginauth.NewTokenValidationError(jwt.ErrInvalidIssuer)
+	}
+
+	clockSkew := (&AuthConfig{ClockSkew: im.config.ClockSkew}).clockSkew()
+
+	if err := cl.ValidateWithLeeway(jwt.Expected{Time: time.Now()}, clockSkew); err != nil {
+		return ginauth.ClaimMetadata{}, time.Time{}, ginauth.NewTokenValidationError(err)
+	}
+
+	if len(im.audiences) > 0 && !hasAnyAudience(cl.Audience, im.audiences) {
+		return ginauth.ClaimMetadata{}, time.Time{}, ginauth.NewTokenValidationError(jwt.ErrInvalidAudience)
+	}
+
+	user := cl.Subject
+
+	usernameClaim := im.config.UsernameClaim
+	if usernameClaim == "" {
+		usernameClaim = "username"
+	}
+
+	if u, ok := parseStringClaim(lookupClaim(sc, usernameClaim)); ok && u != "" {
+		user = u
+	}
+
+	var tenant string
+	if im.config.TenantClaim != "" {
+		tenant, _ = parseStringClaim(lookupClaim(sc, im.config.TenantClaim))
+	}
+
+	cm := ginauth.ClaimMetadata{
+		Subject:   cl.Subject,
+		User:      user,
+		Roles:     parseRolesClaim(lookupClaim(sc, im.config.RolesClaim)),
+		TokenID:   cl.ID,
+		Tenant:    tenant,
+		ExpiresAt: tokenExpiry(sc),
+		Claims:    sc,
+	}
+
+	return cm, cm.ExpiresAt, nil
+}
+
+// introspectionEndpoint returns the Endpoint, or the introspection endpoint discovered from the issuer
+// when it isn't set. The discovery is retried by the next request when it failed.
+func (im *IntrospectionMiddleware) introspectionEndpoint(ctx context.Context) (string, error) {
+	im.endpointMu.Lock()
+	defer im.endpointMu.Unlock()
+
+	if im.endpoint != "" {
+		return im.endpoint, nil
+	}
+
+	md, err := DiscoverOIDCProviderWithClient(ctx, im.client, im.config.Issuer)
+	if err != nil {
+		return "", err
+	}
+
+	if !isHTTPURL(md.IntrospectionEndpoint) {
+		return "", fmt.Errorf("%w: metadata of %s has no valid introspection_endpoint", ErrOIDCDiscovery, im.config.Issuer)
+	}
+
+	im.logger.Info("discovered introspection endpoint", zap.String("issuer", im.config.Issuer), zap.String("introspection_endpoint", md.IntrospectionEndpoint))
+
+	im.endpoint = md.IntrospectionEndpoint
+
+	return im.endpoint, nil
+}
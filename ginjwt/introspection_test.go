@@ -0,0 +1,304 @@
+package ginjwt_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.hollow.sh/toolbox/ginauth"
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+// newIntrospectionServer serves the responses of the tokens to the client test-client, tokens
+// without a response are inactive
+func newIntrospectionServer(t *testing.T, responses map[string]map[string]interface{}) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var requests int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		// the credentials are form encoded, see RFC 6749 section 2.3.1
+		id, secret, _ := r.BasicAuth()
+		id, _ = url.QueryUnescape(id)
+		secret, _ = url.QueryUnescape(secret)
+
+		if id != "test-client" || secret != "s3cr3t%" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil || r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		resp, ok := responses[r.PostForm.Get("token")]
+		if !ok {
+			resp = map[string]interface{}{"active": false}
+		}
+
+		_ = json.NewEncoder(w).Encode(resp)
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv, &requests
+}
+
+func TestIntrospectionMiddleware(t *testing.T) {
+	exp := time.Now().Add(time.Hour).Unix()
+
+	srv, _ := newIntrospectionServer(t, map[string]map[string]interface{}{
+		"valid": {
+			"active": true, "scope": "read write", "sub": "user-id", "username": "alice",
+			"aud": "ginjwt.test", "iss": "ginjwt.test.issuer", "exp": exp, "jti": "token-id", "org": "tenant-a",
+		},
+		"no-optional-members": {"active": true, "scope": "read", "sub": "user-id"},
+		"expired":             {"active": true, "scope": "read", "sub": "user-id", "exp": time.Now().Add(-time.Hour).Unix()},
+		"other-audience":      {"active": true, "scope": "read", "sub": "user-id", "aud": []string{"other"}},
+		"other-issuer":        {"active": true, "scope": "read", "sub": "user-id", "iss": "other"},
+	})
+
+	testCases := []struct {
+		testName   string
+		cfg        ginjwt.IntrospectionConfig
+		token      string
+		scopes     []string
+		wantStatus int
+		wantCM     ginauth.ClaimMetadata
+	}{
+		{
+			testName:   "active token",
+			token:      "valid",
+			scopes:     []string{"read"},
+			wantStatus: http.StatusOK,
+			wantCM:     ginauth.ClaimMetadata{Subject: "user-id", User: "alice", Roles: []string{"read", "write"}, TokenID: "token-id", Tenant: "tenant-a"},
+		},
+		{
+			testName:   "response without iss, aud and exp",
+			token:      "no-optional-members",
+			wantStatus: http.StatusOK,
+			wantCM:     ginauth.ClaimMetadata{Subject: "user-id", User: "user-id", Roles: []string{"read"}},
+		},
+		{
+			testName:   "audience required",
+			cfg:        ginjwt.IntrospectionConfig{Audience: "ginjwt.test"},
+			token:      "no-optional-members",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:   "inactive token",
+			token:      "revoked",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:   "expired token",
+			token:      "expired",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:   "wrong audience",
+			cfg:        ginjwt.IntrospectionConfig{Audience: "ginjwt.test"},
+			token:      "other-audience",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:   "wrong issuer",
+			cfg:        ginjwt.IntrospectionConfig{Issuer: "ginjwt.test.issuer"},
+			token:      "other-issuer",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			testName:   "missing scope",
+			token:      "valid",
+			scopes:     []string{"admin"},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			testName:   "all scopes required",
+			cfg:        ginjwt.IntrospectionConfig{RoleValidationStrategy: ginjwt.RoleValidationStrategyAll},
+			token:      "valid",
+			scopes:     []string{"read", "admin"},
+			wantStatus: http.StatusForbidden,
+		},
+		{
+			testName:   "missing token",
+			wantStatus: http.StatusUnauthorized,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.testName, func(t *testing.T) {
+			cfg := tt.cfg
+			cfg.Endpoint = srv.URL
+			cfg.ClientID = "test-client"
+			cfg.ClientSecret = "s3cr3t%"
+			cfg.TenantClaim = "org"
+
+			im, err := ginjwt.NewIntrospectionMiddleware(cfg)
+			require.NoError(t, err)
+
+			var cm ginauth.ClaimMetadata
+
+			r := gin.New()
+			r.GET("/", im.AuthRequired(tt.scopes), func(c *gin.Context) {
+				cm = ginauth.ClaimMetadata{
+					Subject: ginjwt.GetSubject(c),
+					User:    ginjwt.GetUser(c),
+					Roles:   c.GetStringSlice("jwt.roles"),
+					Tenant:  ginjwt.GetTenant(c),
+				}
+
+				c.Status(http.StatusOK)
+			})
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, tt.wantCM.Subject, cm.Subject)
+			assert.Equal(t, tt.wantCM.User, cm.User)
+			assert.Equal(t, tt.wantCM.Roles, cm.Roles)
+			assert.Equal(t, tt.wantCM.Tenant, cm.Tenant)
+
+			got, err := im.VerifyToken(tokenContext(tt.token))
+			require.NoError(t, err)
+			assert.Equal(t, tt.wantCM.TokenID, got.TokenID)
+		})
+	}
+}
+
+func TestIntrospectionEndpointErrors(t *testing.T) {
+	srv, _ := newIntrospectionServer(t, nil)
+
+	im, err := ginjwt.NewIntrospectionMiddleware(ginjwt.IntrospectionConfig{
+		Endpoint:     srv.URL,
+		ClientID:     "test-client",
+		ClientSecret: "wrong",
+	})
+	require.NoError(t, err)
+
+	_, err = im.VerifyToken(tokenContext("valid"))
+	assert.ErrorContains(t, err, ginjwt.ErrIntrospection.Error())
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("not json"))
+	}))
+	defer failing.Close()
+
+	im, err = ginjwt.NewIntrospectionMiddleware(ginjwt.IntrospectionConfig{Endpoint: failing.URL})
+	require.NoError(t, err)
+
+	_, err = im.VerifyToken(tokenContext("valid"))
+	assert.ErrorContains(t, err, ginjwt.ErrIntrospection.Error())
+}
+
+func TestIntrospectionCache(t *testing.T) {
+	srv, requests := newIntrospectionServer(t, map[string]map[string]interface{}{
+		"valid": {"active": true, "scope": "read", "sub": "user-id", "exp": time.Now().Add(time.Hour).Unix()},
+	})
+
+	im, err := ginjwt.NewIntrospectionMiddleware(ginjwt.IntrospectionConfig{
+		Endpoint:     srv.URL,
+		ClientID:     "test-client",
+		ClientSecret: "s3cr3t%",
+		CacheTTL:     time.Minute,
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		cm, err := im.VerifyTokenWithScopes(tokenContext("valid"), []string{"read"})
+		require.NoError(t, err)
+		assert.Equal(t, "user-id", cm.Subject)
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+
+	// inactive tokens aren't cached
+	for i := 0; i < 2; i++ {
+		_, err := im.VerifyToken(tokenContext("revoked"))
+		assert.Error(t, err)
+	}
+
+	assert.Equal(t, int32(3), atomic.LoadInt32(requests))
+	assert.Equal(t, uint64(2), im.CacheStats().Hits)
+}
+
+func TestIntrospectionEndpointDiscovery(t *testing.T) {
+	introspection, _ := newIntrospectionServer(t, map[string]map[string]interface{}{
+		"valid": {"active": true, "scope": "read", "sub": "user-id"},
+	})
+
+	var discoveries int32
+
+	mux := http.NewServeMux()
+	provider := httptest.NewServer(mux)
+
+	defer provider.Close()
+
+	mux.HandleFunc(ginjwt.OIDCDiscoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&discoveries, 1)
+
+		_ = json.NewEncoder(w).Encode(ginjwt.OIDCProviderMetadata{
+			Issuer:                provider.URL,
+			JWKSURI:               provider.URL + "/keys",
+			IntrospectionEndpoint: introspection.URL,
+		})
+	})
+
+	im, err := ginjwt.NewIntrospectionMiddleware(ginjwt.IntrospectionConfig{
+		Issuer:       provider.URL,
+		ClientID:     "test-client",
+		ClientSecret: "s3cr3t%",
+	})
+	require.NoError(t, err)
+
+	for i := 0; i < 2; i++ {
+		cm, err := im.VerifyToken(tokenContext("valid"))
+		require.NoError(t, err)
+		assert.Equal(t, "user-id", cm.Subject)
+	}
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&discoveries))
+}
+
+func TestIntrospectionConfigValidation(t *testing.T) {
+	testCases := []struct {
+		testName string
+		cfg      ginjwt.IntrospectionConfig
+	}{
+		{"no endpoint nor issuer", ginjwt.IntrospectionConfig{}},
+		{"issuer isn't a URL", ginjwt.IntrospectionConfig{Issuer: "ginjwt.test.issuer"}},
+		{"endpoint isn't a URL", ginjwt.IntrospectionConfig{Endpoint: "introspect"}},
+		{"negative clock skew", ginjwt.IntrospectionConfig{Endpoint: "https://idp.test/introspect", ClockSkew: -time.Second}},
+		{"unknown role validation strategy", ginjwt.IntrospectionConfig{Endpoint: "https://idp.test/introspect", RoleValidationStrategy: "some"}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.testName, func(t *testing.T) {
+			_, err := ginjwt.NewIntrospectionMiddleware(tt.cfg)
+			assert.ErrorIs(t, err, ginjwt.ErrInvalidAuthConfig)
+		})
+	}
+}
@@ -0,0 +1,390 @@
+package ginjwt_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+// newRotatingJWKSServer serves the JWKS holding the current key id, the key is rotated with the returned func
+func newRotatingJWKSServer(t *testing.T, kid string) (*httptest.Server, func(kid string), *int32) {
+	t.Helper()
+
+	var (
+		mu       sync.Mutex
+		current  = kid
+		requests int32
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+
+		mu.Lock()
+		keySet := ginjwt.TestHelperJoseJWKSProvider(current)
+		mu.Unlock()
+
+		_ = json.NewEncoder(w).Encode(keySet)
+	}))
+
+	t.Cleanup(srv.Close)
+
+	rotate := func(kid string) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		current = kid
+	}
+
+	return srv, rotate, &requests
+}
+
+func newJWKSTestContext(signer jose.Signer) *gin.Context {
+	rawToken := ginjwt.TestHelperGetToken(signer, jwt.Claims{
+		Subject:   "test-user",
+		Issuer:    "ginjwt.test.issuer",
+		NotBefore: jwt.NewNumericDate(time.Now().Add(-2 * time.Hour)),
+		Audience:  jwt.Audience{"ginjwt.test"},
+	}, "scope", "read")
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "http://test/", nil)
+	c.Request.Header.Set("Authorization", fmt.Sprintf("bearer %s", rawToken))
+
+	return c
+}
+
+func TestVerifyTokenDuringJWKSRotation(t *testing.T) {
+	srv, rotate, requests := newRotatingJWKSServer(t, ginjwt.TestPrivRSAKey1ID)
+
+	authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:  true,
+		Audience: "ginjwt.test",
+		Issuer:   "ginjwt.test.issuer",
+		JWKSURI:  srv.URL,
+	})
+	require.NoError(t, err)
+
+	oldSigner := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+	newSigner := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey2ID, ginjwt.TestPrivRSAKey2)
+
+	// verifying with the cached key concurrently
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 10; j++ {
+				_, err := authMW.VerifyToken(newJWKSTestContext(oldSigner))
+				assert.NoError(t, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests), "cached key should not refresh the JWKS")
+
+	rotate(ginjwt.TestPrivRSAKey2ID)
+
+	// tokens signed with the rotated key all wait for a single refresh
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 10; j++ {
+				_, err := authMW.VerifyToken(newJWKSTestContext(newSigner))
+				assert.NoError(t, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	assert.Equal(t, int32(2), atomic.LoadInt32(requests))
+
+	// the old key is gone once rotated
+	_, err = authMW.VerifyToken(newJWKSTestContext(oldSigner))
+	assert.Error(t, err)
+}
+
+func TestVerifyTokenConcurrentWithRefresh(t *testing.T) {
+	srv, _, _ := newRotatingJWKSServer(t, ginjwt.TestPrivRSAKey1ID)
+
+	authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:  true,
+		Audience: "ginjwt.test",
+		Issuer:   "ginjwt.test.issuer",
+		JWKSURI:  srv.URL,
+	})
+	require.NoError(t, err)
+
+	signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+	unknown := ginjwt.TestHelperMustMakeSigner(jose.RS256, "unknown-kid", ginjwt.TestPrivRSAKey2)
+
+	var wg sync.WaitGroup
+
+	// tokens with an unknown key id refresh the cache while valid tokens are verified,
+	// run with -race to catch unguarded access to the cache
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 10; j++ {
+				_, err := authMW.VerifyToken(newJWKSTestContext(signer))
+				assert.NoError(t, err)
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 10; j++ {
+				_, err := authMW.VerifyToken(newJWKSTestContext(unknown))
+				assert.Error(t, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestJWKSLazyFetch(t *testing.T) {
+	srv, _, requests := newRotatingJWKSServer(t, ginjwt.TestPrivRSAKey1ID)
+
+	authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:       true,
+		Audience:      "ginjwt.test",
+		Issuer:        "ginjwt.test.issuer",
+		JWKSURI:       srv.URL,
+		JWKSLazyFetch: true,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(0), atomic.LoadInt32(requests))
+
+	signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+
+	_, err = authMW.VerifyToken(newJWKSTestContext(signer))
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+}
+
+func TestMultiTokenMiddlewareConcurrentJWKSPrefetch(t *testing.T) {
+	var arrived sync.WaitGroup
+
+	arrived.Add(2)
+
+	keySet := ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID)
+
+	// each JWKS is only served once both were requested, serial fetches time out
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived.Done()
+
+		done := make(chan struct{})
+		go func() { arrived.Wait(); close(done) }()
+
+		select {
+		case <-done:
+			_ = json.NewEncoder(w).Encode(keySet)
+		case <-time.After(2 * time.Second):
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+
+	cfgs := make([]ginjwt.AuthConfig, 2)
+
+	for i := range cfgs {
+		srv := httptest.NewServer(handler)
+		t.Cleanup(srv.Close)
+
+		cfgs[i] = ginjwt.AuthConfig{Enabled: true, Audience: "ginjwt.test", Issuer: "ginjwt.test.issuer", JWKSURI: srv.URL}
+	}
+
+	_, err := ginjwt.NewMultiTokenMiddlewareFromConfigs(cfgs...)
+	require.NoError(t, err)
+}
+
+func TestMultiTokenMiddlewareJWKSPrefetchTimeout(t *testing.T) {
+	healthy, _, _ := newRotatingJWKSServer(t, ginjwt.TestPrivRSAKey1ID)
+
+	// the broken identity provider never answers
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(broken.Close)
+
+	newConfigs := func(degraded bool) []ginjwt.AuthConfig {
+		return []ginjwt.AuthConfig{
+			{Enabled: true, Audience: "ginjwt.test", Issuer: "ginjwt.test.issuer", JWKSURI: healthy.URL},
+			{
+				Enabled:            true,
+				Audience:           "ginjwt.test",
+				Issuer:             "ginjwt.test.issuer",
+				JWKSURI:            broken.URL,
+				JWKSRemoteTimeout:  50 * time.Millisecond,
+				JWKSStartupRetries: 5,
+				JWKSStartDegraded:  degraded,
+				JWKSStartupBackoff: time.Hour,
+			},
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	_, err := ginjwt.NewMultiTokenMiddlewareFromConfigsContext(ctx, newConfigs(false)...)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+
+	mtm, err := ginjwt.NewMultiTokenMiddlewareFromConfigsContext(ctx, newConfigs(true)...)
+	require.NoError(t, err)
+
+	defer mtm.Close()
+	assert.Less(t, time.Since(start), time.Second)
+
+	// the healthy provider verifies tokens while the broken one is fetched in the background
+	signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+
+	results := mtm.VerifyAll(newJWKSTestContext(signer), []string{"read"})
+	require.Len(t, results, 2)
+	assert.NoError(t, results[0].Err)
+	assert.Error(t, results[1].Err)
+}
+
+func TestVerifyTokenJWKSFetchRequestDeadline(t *testing.T) {
+	// the hung identity provider never answers
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(hung.Close)
+
+	authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:       true,
+		Audience:      "ginjwt.test",
+		Issuer:        "ginjwt.test.issuer",
+		JWKSURI:       hung.URL,
+		JWKSLazyFetch: true,
+	})
+	require.NoError(t, err)
+
+	signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+
+	var wg sync.WaitGroup
+
+	// the second request waits for the refresh of the first one, until its own deadline
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			c := newJWKSTestContext(signer)
+			c.Request = c.Request.WithContext(ctx)
+
+			start := time.Now()
+
+			_, err := authMW.VerifyToken(c)
+			assert.ErrorContains(t, err, ginjwt.ErrKeyFetchTimeout.Error())
+			assert.Less(t, time.Since(start), time.Second)
+		}()
+	}
+
+	wg.Wait()
+}
+
+func BenchmarkVerifyTokenParallel(b *testing.B) {
+	authMW := newBenchmarkMiddleware(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	b.RunParallel(func(pb *testing.PB) {
+		c := newBenchmarkContext(b, []string{"testScope", "anotherScope", "more-scopes"})
+
+		for pb.Next() {
+			if _, err := authMW.VerifyToken(c); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func TestJWKSStartDegradedClose(t *testing.T) {
+	var fetches int32
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	t.Cleanup(srv.Close)
+
+	mux.HandleFunc(ginjwt.OIDCDiscoveryPath, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(ginjwt.OIDCProviderMetadata{Issuer: srv.URL, JWKSURI: srv.URL + "/keys"})
+	})
+
+	// the keys of the identity provider are unavailable
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+
+	core, logs := observer.New(zap.WarnLevel)
+
+	authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:            true,
+		Audience:           "ginjwt.test",
+		Issuer:             srv.URL,
+		JWKSDiscovery:      true,
+		JWKSStartDegraded:  true,
+		JWKSStartupBackoff: time.Millisecond,
+		Logger:             zap.New(core),
+	})
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(&fetches) > 2
+	}, time.Second, time.Millisecond)
+
+	require.NoError(t, authMW.Close())
+
+	// no more fetches once closed, a fetch canceled by Close may still reach the handler
+	time.Sleep(20 * time.Millisecond)
+
+	stopped := atomic.LoadInt32(&fetches)
+
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, stopped, atomic.LoadInt32(&fetches))
+
+	// the discovered URI is logged rather than the empty JWKSURI
+	warnings := logs.FilterMessage("starting without JWKS, tokens are rejected until it is fetched").All()
+	require.Len(t, warnings, 1)
+	assert.Equal(t, srv.URL+"/keys", warnings[0].ContextMap()["uri"])
+}
@@ -1,14 +1,18 @@
 package ginjwt
 
 import (
+	"crypto/x509"
 	"encoding/json"
 	"fmt"
 	"net/http"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/pkg/errors"
+	"go.uber.org/zap"
 	"golang.org/x/net/context"
 	"gopkg.in/square/go-jose.v2"
 	"gopkg.in/square/go-jose.v2/jwt"
@@ -17,10 +21,11 @@ import (
 )
 
 const (
-	contextKeySubject       = "jwt.subject"
-	contextKeyUser          = "jwt.user"
-	contextKeyRoles         = "jwt.roles"
-	expectedAuthHeaderParts = 2
+	contextKeySubject = "jwt.subject"
+	contextKeyUser    = "jwt.user"
+	contextKeyRoles   = "jwt.roles"
+	contextKeyTenant  = "jwt.tenant"
+	contextKeyClaims  = "jwt.claims"
 )
 
 // RoleValidationStrategy represents a validation strategy for roles.
@@ -33,10 +38,76 @@ const (
 	RoleValidationStrategyAll RoleValidationStrategy = "all"
 )
 
+// DisabledMode represents the behavior of the middleware when auth is disabled.
+type DisabledMode string
+
+const (
+	// DisabledModeAllow lets all requests through when auth is disabled.
+	DisabledModeAllow DisabledMode = "disabled-allow"
+	// DisabledModeWarn lets all requests through when auth is disabled, logging each of them.
+	DisabledModeWarn DisabledMode = "disabled-warn"
+	// DisabledModeDeny rejects all requests when auth is disabled.
+	DisabledModeDeny DisabledMode = "disabled-deny"
+)
+
+const (
+	// DefaultJWKSStartupBackoff is the wait before the first retry of a failed JWKS fetch at startup
+	DefaultJWKSStartupBackoff = time.Second
+
+	// maximum wait between retries of a failed JWKS fetch at startup
+	maxJWKSStartupBackoff = time.Minute
+
+	// DefaultJWKSRefreshTimeout bounds the JWKS fetches when JWKSRemoteTimeout is unspecified
+	DefaultJWKSRefreshTimeout = 10 * time.Second
+)
+
 // Middleware provides a gin compatible middleware that will authenticate JWT requests
 type Middleware struct {
 	config     AuthConfig
+	audiences  []string
+	issuers    []string
+	subjects   *subjectCache
+	nested     *Middleware
+	logger     *zap.Logger
+	jwksMu     sync.RWMutex
 	cachedJWKS jose.JSONWebKeySet
+	// retiredKeys are the keys removed from the JWKS within the RetiredKeyGracePeriod, by key ID
+	retiredKeys    map[string]*retiredKey
+	retiredKeyUses uint64
+	// refreshing serializes refreshes on cache misses, requests signed with a
+	// rotated key wait for a single refresh instead of each fetching the JWKS.
+	// It is a channel so waiting requests give up when their context is done.
+	refreshing chan struct{}
+	// x5cVerified caches the leaf certificates which chains were verified
+	x5cVerified sync.Map
+	// quirks are the settings of the ProviderPreset, nil when no preset is set
+	quirks *providerQuirks
+	// usernameClaims are read in order for the username, the subject is used when none is set
+	usernameClaims []string
+	// algorithms are the signing algorithms of the tokens accepted, see AllowedAlgorithms
+	algorithms []string
+	// extractors read the token of the requests, see TokenExtractors
+	extractors []TokenExtractor
+
+	// discovery is set when the JWKS URI is discovered from the issuer, see ProviderMetadata
+	discovery   bool
+	discoveryMu sync.Mutex
+	discovered  *OIDCProviderMetadata
+
+	// stopRefresher stops the background JWKS refreshes, nil unless JWKSRefreshInterval is set
+	stopRefresher context.CancelFunc
+	refresherDone chan struct{}
+	// stopRetries stops fetching the JWKS in the background, nil unless started degraded
+	stopRetries context.CancelFunc
+	retriesDone chan struct{}
+	closeOnce   sync.Once
+
+	// decisions caches the authorization decisions, nil unless DecisionCacheTTL is set
+	decisions *decisionCache
+	// misorderedRoutes are the routes reported running RequiredScopes before AuthRequired
+	misorderedRoutes sync.Map
+
+	disabledRequests uint64
 }
 
 // AuthConfig provides the configuration for the authentication service
@@ -45,6 +116,9 @@ type AuthConfig struct {
 	Audience string
 	Issuer   string
 	JWKSURI  string
+	// JWKSDiscovery discovers the JWKS URI from the openid-configuration of the Issuer, an http(s) URL,
+	// instead of setting the JWKSURI, JWKS or KeySetProvider. See ProviderMetadata.
+	JWKSDiscovery bool
 
 	// JWKS allows the user to specify the JWKS directly instead of through URI
 	JWKS              jose.JSONWebKeySet
@@ -54,10 +128,148 @@ type AuthConfig struct {
 	JWKSRemoteTimeout time.Duration
 	// Role validation strategy for roles claim. Defaults to any if unspecified.
 	RoleValidationStrategy RoleValidationStrategy
+	// Audiences are accepted in addition to Audience, a token is valid when it contains any of them.
+	Audiences []string
+	// ClockSkew is the leeway allowed when validating the token exp, nbf and iat claims, for hosts with
+	// drifting clocks. Defaults to jwt.DefaultLeeway if unspecified. The NestedTokenConfig uses it unless
+	// it sets its own.
+	ClockSkew time.Duration
+	// Leeway is an alias of ClockSkew, either may be set. Setting both to different values is invalid.
+	Leeway time.Duration
+	// TenantClaim is the claim holding the tenant or organization of the token, read with GetTenant.
+	// No tenant is read if unspecified.
+	TenantClaim string
+	// SubjectResolver maps the token subject to the identity set as the ClaimMetadata User.
+	SubjectResolver SubjectResolver
+	// SubjectCacheTTL is how long resolved subjects are cached. Defaults to DefaultSubjectCacheTTL if unspecified.
+	SubjectCacheTTL time.Duration
+	// DisabledMode is the behavior when auth isn't enabled. Defaults to DisabledModeAllow if unspecified.
+	DisabledMode DisabledMode
+	// Logger is used to report the disabled mode and JWKS fetch failures. Defaults to a no-op logger if unspecified.
+	Logger *zap.Logger
+	// JWKSStartupRetries is the number of times fetching the JWKS from JWKSURI is retried when
+	// creating the middleware, waiting JWKSStartupBackoff between attempts with the wait doubling each time.
+	JWKSStartupRetries int
+	// JWKSStartupBackoff is the wait before the first retry. Defaults to DefaultJWKSStartupBackoff if unspecified.
+	JWKSStartupBackoff time.Duration
+	// JWKSStartDegraded returns the middleware even when the JWKS couldn't be fetched at startup,
+	// it keeps being fetched in the background and tokens are rejected until it is.
+	JWKSStartDegraded bool
+	// JWKSLazyFetch skips fetching the JWKS from the JWKSURI or KeySetProvider when creating the
+	// middleware, it is fetched when verifying the first token instead. This keeps an unavailable
+	// identity provider from delaying the startup.
+	JWKSLazyFetch bool
+	// JWKSRefreshInterval refreshes the JWKS from the JWKSURI or KeySetProvider in the background,
+	// so rotated keys are fetched before the first token signed with them. The JWKS is only
+	// refreshed for unknown keys if unspecified. The refreshes stop with Close.
+	JWKSRefreshInterval time.Duration
+	// JWKSRefreshJitter adds a random wait up to this long to each JWKSRefreshInterval, so the
+	// instances of a service don't refresh all at once.
+	JWKSRefreshJitter time.Duration
+	// WebSocketSubprotocolToken accepts tokens passed in the subprotocols of websocket upgrade requests
+	// without an Authorization header, as browsers can't set headers on websockets. See WebSocketTokenSubprotocol.
+	WebSocketSubprotocolToken bool
+	// TokenExtractors read the token of the requests, the first one finding a token is used, e.g. to
+	// accept tokens in a cookie from browsers. See ParseTokenExtractors for the built-in sources.
+	// Defaults to the Authorization header, the grpc-gateway header and, with WebSocketSubprotocolToken,
+	// the websocket subprotocols if unspecified.
+	TokenExtractors []TokenExtractor
+	// NestedTokenClaim is the claim holding an inner token wrapped in the token, when set the
+	// inner token is verified with the NestedTokenConfig once the outer token is verified.
+	// The ClaimMetadata then holds the subject and user of the inner token and the roles of both.
+	NestedTokenClaim string
+	// NestedTokenConfig is the configuration inner tokens are verified with, it must be enabled.
+	NestedTokenConfig *AuthConfig
+	// BypassList holds the requests skipping authentication, such as health checks.
+	BypassList *ginauth.BypassList
+	// MessageCatalog customizes the messages of the errors the middleware responds with, the
+	// catalog set with ginauth.UseMessageCatalog is used when unset.
+	MessageCatalog ginauth.MessageCatalog
+	// ClaimValidators run in order once the token passed the standard validation, the
+	// request is rejected with a 403 Forbidden when any of them returns an error.
+	ClaimValidators []ClaimValidatorFunc
+	// X5CRoots, when set, requires keys to hold an x5c certificate chain verifying against
+	// these roots, keys without a chain or with an invalid or expired one are rejected.
+	X5CRoots *x509.CertPool
+	// ProviderPreset configures the claims, issuer and audience handling for the quirks of an
+	// identity provider. The RolesClaim and UsernameClaim take precedence over the preset ones.
+	ProviderPreset ProviderPreset
+	// KeySetProvider supplies the JWKS instead of the JWKSURI or JWKS, e.g. a NatsKVKeySetProvider.
+	// Providers implementing KeySetWatcher update the keys of the middleware as they change.
+	KeySetProvider KeySetProvider
+	// HostedDomains are the Google Workspace domains tokens are accepted for with ProviderPresetGoogle,
+	// the hd claim of the token must be one of them. Any domain is accepted when unset.
+	HostedDomains []string
+	// AcceptAuthorizedParty accepts the tokens which azp claim, rather than the aud claim, is one of
+	// the audiences, e.g. Keycloak access tokens only issued for the account audience. The azp claim
+	// names the client the token was issued to, so any token of that client is accepted.
+	AcceptAuthorizedParty bool
+	// AudienceScopes maps accepted audiences to the scopes implied by them, the scopes of each
+	// audience of the token are added to the roles read from the RolesClaim.
+	AudienceScopes map[string][]string
+	// DecisionCacheTTL enables caching the decisions of requests with the same token, which identifies
+	// the subject, to the same route with the same scopes, for high QPS APIs. Decisions are reused for
+	// the TTL, never past the token expiry, and tokens failing verification aren't cached. Cached
+	// decisions skip keys removed from the JWKS, but not the RevocationStore. Routes are matched by
+	// pattern, e.g. /orgs/:org, so it can't be set with ClaimValidators or a SubjectResolver which may
+	// depend on the request.
+	DecisionCacheTTL time.Duration
+	// DecisionCacheSize is the number of decisions cached. Defaults to DefaultDecisionCacheSize if unspecified.
+	DecisionCacheSize int
+	// RetiredKeyGracePeriod keeps accepting tokens signed with the keys removed from the JWKS for this
+	// long after the refresh which removed them, so tokens issued before an identity provider rotated
+	// its keys stay valid until they expire. See RetiredKeyStats. Keys are dropped at once if unspecified.
+	RetiredKeyGracePeriod time.Duration
+	// HTTPClient fetches the JWKS from the JWKSURI and the OpenID provider metadata, e.g. to reach the
+	// identity provider through an egress proxy or trust its private CA, see NewHTTPClient. The
+	// JWKSRemoteTimeout still bounds each fetch. Defaults to http.DefaultClient if unspecified.
+	HTTPClient *http.Client
+	// RevocationStore denies the tokens which ID, the jti claim, was revoked, e.g. a NatsKVRevocationStore.
+	// Tokens without an ID can't be revoked. The NestedTokenConfig uses it unless it sets its own.
+	RevocationStore RevocationStore
+	// RevocationFailOpen accepts the tokens when the RevocationStore can't be checked, they are
+	// rejected if unspecified.
+	RevocationFailOpen bool
+	// AllowedAlgorithms restricts the JOSE algorithms the tokens may be signed with, e.g. to the one
+	// the identity provider uses. It must be a subset of DefaultAllowedAlgorithms, which are allowed if unspecified.
+	AllowedAlgorithms []string
 }
 
 // NewAuthMiddleware will return an auth middleware configured with the jwt parameters passed in
 func NewAuthMiddleware(cfg AuthConfig) (*Middleware, error) {
+	mw, err := newAuthMiddleware(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := mw.prefetchJWKS(context.Background()); err != nil {
+		return nil, err
+	}
+
+	mw.startJWKSRefresher()
+
+	return mw, nil
+}
+
+// newAuthMiddleware returns the middleware without fetching its JWKS, see prefetchJWKS.
+func newAuthMiddleware(cfg AuthConfig) (*Middleware, error) {
+	quirks, err := cfg.ProviderPreset.quirks()
+	if err != nil {
+		return nil, err
+	}
+
+	var usernameClaims []string
+
+	if quirks != nil {
+		if cfg.RolesClaim == "" {
+			cfg.RolesClaim = quirks.rolesClaim
+		}
+
+		if cfg.UsernameClaim == "" {
+			usernameClaims = quirks.usernameClaims
+		}
+	}
+
 	if cfg.RolesClaim == "" {
 		cfg.RolesClaim = "scope"
 	}
@@ -66,15 +278,85 @@ func NewAuthMiddleware(cfg AuthConfig) (*Middleware, error) {
 		cfg.UsernameClaim = "sub"
 	}
 
+	if cfg.DisabledMode == "" {
+		cfg.DisabledMode = DisabledModeAllow
+	}
+
+	if cfg.Logger == nil {
+		cfg.Logger = zap.NewNop()
+	}
+
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+
+	if len(usernameClaims) == 0 {
+		usernameClaims = []string{cfg.UsernameClaim}
+	}
+
+	algorithms, err := allowedAlgorithms(cfg.AllowedAlgorithms)
+	if err != nil {
+		return nil, err
+	}
+
+	extractors := cfg.TokenExtractors
+	if len(extractors) == 0 {
+		extractors = defaultTokenExtractors(cfg)
+	}
+
+	for _, extractor := range extractors {
+		if extractor == nil {
+			return nil, fmt.Errorf("%w: nil token extractor", ErrInvalidAuthConfig)
+		}
+	}
+
 	mw := &Middleware{
-		config: cfg,
+		config:         cfg,
+		audiences:      quirks.acceptedAudiences(cfg.audiences()),
+		issuers:        quirks.acceptedIssuers(cfg.Issuer),
+		quirks:         quirks,
+		usernameClaims: usernameClaims,
+		algorithms:     algorithms,
+		extractors:     extractors,
+		logger:         cfg.Logger,
+		refreshing:     make(chan struct{}, 1),
+	}
+
+	if cfg.SubjectResolver != nil {
+		mw.subjects = newSubjectCache(cfg.SubjectResolver, cfg.SubjectCacheTTL)
+	}
+
+	if cfg.DecisionCacheTTL > 0 {
+		if len(cfg.ClaimValidators) > 0 || cfg.SubjectResolver != nil {
+			return nil, fmt.Errorf("%w: DecisionCacheTTL can't be set with ClaimValidators or a SubjectResolver", ErrInvalidAuthConfig)
+		}
+
+		mw.decisions = newDecisionCache(cfg.DecisionCacheTTL, cfg.DecisionCacheSize)
+	}
+
+	if cfg.NestedTokenClaim != "" {
+		nested, err := newNestedMiddleware(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		mw.nested = nested
 	}
 
 	if !cfg.Enabled {
+		switch cfg.DisabledMode {
+		case DisabledModeAllow, DisabledModeWarn:
+			mw.logger.Warn("auth is disabled, requests are not authenticated", zap.String("mode", string(cfg.DisabledMode)))
+		case DisabledModeDeny:
+			mw.logger.Warn("auth is disabled, requests are rejected", zap.String("mode", string(cfg.DisabledMode)))
+		default:
+			return nil, fmt.Errorf("%w: unknown disabled mode %s", ErrInvalidAuthConfig, cfg.DisabledMode)
+		}
+
 		return mw, nil
 	}
 
-	if cfg.Audience == "" {
+	if len(mw.audiences) == 0 {
 		return nil, errors.Wrap(ErrInvalidAudience, "empty value")
 	}
 
@@ -82,29 +364,74 @@ func NewAuthMiddleware(cfg AuthConfig) (*Middleware, error) {
 		return nil, errors.Wrap(ErrInvalidIssuer, "empty value")
 	}
 
-	uriProvided := (cfg.JWKSURI != "")
-	jwksProvided := len(cfg.JWKS.Keys) > 0
+	if cfg.ClockSkew < 0 || cfg.Leeway < 0 {
+		return nil, fmt.Errorf("%w: ClockSkew and Leeway must not be negative", ErrInvalidAuthConfig)
+	}
+
+	if cfg.ClockSkew != 0 && cfg.Leeway != 0 && cfg.ClockSkew != cfg.Leeway {
+		return nil, fmt.Errorf("%w: ClockSkew and Leeway are aliases, set only one", ErrInvalidAuthConfig)
+	}
 
-	// Either they were both provided, or neither was provided
-	if uriProvided == jwksProvided {
-		return nil, fmt.Errorf("%w: either JWKSURI or JWKS must be provided", ErrInvalidAuthConfig)
+	if cfg.JWKSRefreshInterval < 0 || cfg.JWKSRefreshJitter < 0 {
+		return nil, fmt.Errorf("%w: JWKSRefreshInterval and JWKSRefreshJitter must not be negative", ErrInvalidAuthConfig)
+	}
+
+	for aud := range cfg.AudienceScopes {
+		if !containsString(cfg.audiences(), aud) {
+			return nil, fmt.Errorf("%w: AudienceScopes audience %s isn't an accepted audience", ErrInvalidAuthConfig, aud)
+		}
+	}
+
+	provided := 0
+
+	for _, ok := range []bool{cfg.JWKSURI != "", len(cfg.JWKS.Keys) > 0, cfg.KeySetProvider != nil, cfg.JWKSDiscovery} {
+		if ok {
+			provided++
+		}
+	}
+
+	// Only one of them must be provided
+	if provided != 1 {
+		return nil, fmt.Errorf("%w: either JWKSURI, JWKS, KeySetProvider or JWKSDiscovery must be provided", ErrInvalidAuthConfig)
+	}
+
+	if cfg.JWKSDiscovery {
+		if !isHTTPURL(cfg.Issuer) {
+			return nil, fmt.Errorf("%w: JWKSDiscovery requires an http(s) issuer, got %s", ErrInvalidAuthConfig, cfg.Issuer)
+		}
+
+		mw.discovery = true
+	}
+
+	if w, ok := cfg.KeySetProvider.(KeySetWatcher); ok {
+		w.OnKeySetChange(mw.setJWKS)
 	}
 
 	// Only refresh JWKSURI if static one isn't provided
 	if len(cfg.JWKS.Keys) > 0 {
 		mw.cachedJWKS = cfg.JWKS
-	} else {
-		// Fetch JWKS from URI
-		if err := mw.refreshJWKS(); err != nil {
-			return nil, err
-		}
 	}
 
 	return mw, nil
 }
 
+// prefetchJWKS fetches the JWKS from the URI or the provider, unless it is static or fetched lazily.
+// Retries stop once the context is done.
+func (m *Middleware) prefetchJWKS(ctx context.Context) error {
+	if !m.config.Enabled || len(m.config.JWKS.Keys) > 0 || m.config.JWKSLazyFetch {
+		return nil
+	}
+
+	return m.fetchJWKSAtStartup(ctx)
+}
+
 // SetMetadata sets the needed metadata to the gin context which came from the token
 func (m *Middleware) SetMetadata(c *gin.Context, cm ginauth.ClaimMetadata) {
+	setMetadata(c, cm)
+}
+
+// setMetadata sets the metadata which came from the token to the gin context
+func setMetadata(c *gin.Context, cm ginauth.ClaimMetadata) {
 	if cm.Subject != "" {
 		c.Set(contextKeySubject, cm.Subject)
 	}
@@ -112,22 +439,50 @@ func (m *Middleware) SetMetadata(c *gin.Context, cm ginauth.ClaimMetadata) {
 	if cm.User != "" {
 		c.Set(contextKeyUser, cm.User)
 	}
+
+	if cm.Tenant != "" {
+		c.Set(contextKeyTenant, cm.Tenant)
+	}
+
+	if cm.Roles != nil {
+		c.Set(contextKeyRoles, cm.Roles)
+	}
+
+	if cm.Claims != nil {
+		c.Set(contextKeyClaims, cm.Claims)
+	}
+
+	ginauth.MarkAuthenticated(c)
+}
+
+// setContext sets the metadata of the authenticated request to the gin context
+func (m *Middleware) setContext(c *gin.Context, cm ginauth.ClaimMetadata) {
+	setContext(c, cm)
+}
+
+// setContext sets the metadata of the authenticated request, roles included, to the gin context
+func setContext(c *gin.Context, cm ginauth.ClaimMetadata) {
+	c.Set(contextKeySubject, cm.Subject)
+	c.Set(contextKeyUser, cm.User)
+	c.Set(contextKeyRoles, cm.Roles)
+	c.Set(contextKeyTenant, cm.Tenant)
+	c.Set(contextKeyClaims, cm.Claims)
+
+	ginauth.MarkAuthenticated(c)
 }
 
 // VerifyTokenWithScopes satisfies the goauth.GenericAuthMiddleware interface and exists only for
 // backwards compatibility with that interface.
 func (m *Middleware) VerifyTokenWithScopes(c *gin.Context, scopes []string) (ginauth.ClaimMetadata, error) {
-	cm, err := m.VerifyToken(c)
+	cm, scopesErr, err := m.authorize(c, scopes, true)
 	if err != nil {
 		return ginauth.ClaimMetadata{}, err
 	}
 
-	c.Set(contextKeySubject, cm.Subject)
-	c.Set(contextKeyUser, cm.User)
-	c.Set(contextKeyRoles, cm.Roles)
+	m.setContext(c, cm)
 
-	if err := m.VerifyScopes(c, scopes); err != nil {
-		return ginauth.ClaimMetadata{}, err
+	if scopesErr != nil {
+		return ginauth.ClaimMetadata{}, scopesErr
 	}
 
 	return cm, nil
@@ -136,114 +491,304 @@ func (m *Middleware) VerifyTokenWithScopes(c *gin.Context, scopes []string) (gin
 // VerifyToken verifies a JWT token gotten from the gin.Context object. This does not validate roles claims/scopes.
 // This implements the GenericMiddleware interface
 func (m *Middleware) VerifyToken(c *gin.Context) (ginauth.ClaimMetadata, error) {
-	authHeader := c.Request.Header.Get("Authorization")
-
-	if authHeader == "" {
-		return ginauth.ClaimMetadata{}, ginauth.NewAuthenticationError("missing authorization header, expected format: \"Bearer token\"")
+	rawToken, err := m.tokenFromRequest(c)
+	if err != nil {
+		return ginauth.ClaimMetadata{}, err
 	}
 
-	authHeaderParts := strings.SplitN(authHeader, " ", expectedAuthHeaderParts)
+	cm, _, err := m.verifyToken(c, rawToken)
 
-	if !(len(authHeaderParts) == expectedAuthHeaderParts && strings.ToLower(authHeaderParts[0]) == "bearer") {
-		return ginauth.ClaimMetadata{}, ginauth.NewAuthenticationError("invalid authorization header, expected format: \"Bearer token\"")
+	return cm, err
+}
+
+// verifyToken verifies the raw token, and the token nested in it, returning its metadata and
+// when it expires, zero when it doesn't.
+func (m *Middleware) verifyToken(c *gin.Context, rawToken string) (ginauth.ClaimMetadata, time.Time, error) {
+	cm, claims, err := m.verifyRawToken(c, rawToken)
+	if err != nil {
+		return ginauth.ClaimMetadata{}, time.Time{}, err
+	}
+
+	if m.nested != nil {
+		return m.verifyNestedToken(c, cm, claims)
 	}
 
-	rawToken := authHeaderParts[1]
+	return cm, tokenExpiry(claims), nil
+}
 
-	tok, err := jwt.ParseSigned(rawToken)
-	if err != nil {
-		return ginauth.ClaimMetadata{}, ginauth.NewAuthenticationError("unable to parse auth token")
+// verifyRawToken verifies a JWT token, returning its metadata and custom claims.
+func (m *Middleware) verifyRawToken(c *gin.Context, rawToken string) (ginauth.ClaimMetadata, map[string]json.RawMessage, error) {
+	pt := parseToken(c, rawToken)
+	if pt.err != nil {
+		return ginauth.ClaimMetadata{}, nil, ginauth.NewAuthenticationError("unable to parse auth token")
 	}
 
+	tok := pt.tok
+
 	if tok.Headers[0].KeyID == "" {
-		return ginauth.ClaimMetadata{}, ginauth.NewAuthenticationError("unable to parse auth token header")
+		return ginauth.ClaimMetadata{}, nil, ginauth.NewAuthenticationError("unable to parse auth token header")
+	}
+
+	if err := verifyTokenAlgorithm(tok.Headers[0].Algorithm, m.algorithms); err != nil {
+		return ginauth.ClaimMetadata{}, nil, ginauth.NewAuthenticationErrorFrom(err)
+	}
+
+	key, err := m.getJWKS(c.Request.Context(), tok.Headers[0].KeyID)
+	if err != nil {
+		return ginauth.ClaimMetadata{}, nil, ginauth.NewAuthenticationErrorFrom(err)
 	}
 
-	key := m.getJWKS(tok.Headers[0].KeyID)
 	if key == nil {
-		return ginauth.ClaimMetadata{}, ginauth.NewInvalidSigningKeyError()
+		return ginauth.ClaimMetadata{}, nil, ginauth.NewInvalidSigningKeyError()
+	}
+
+	if err := verifyKeyAlgorithm(tok.Headers[0].Algorithm, key); err != nil {
+		return ginauth.ClaimMetadata{}, nil, ginauth.NewAuthenticationErrorFrom(err)
 	}
 
 	cl := jwt.Claims{}
-	sc := map[string]interface{}{}
+
+	// Custom claims are kept as raw JSON so only the roles and username
+	// claims we actually care about get decoded.
+	sc := map[string]json.RawMessage{}
 
 	if err := tok.Claims(key, &cl, &sc); err != nil {
-		return ginauth.ClaimMetadata{}, ginauth.NewAuthenticationError("unable to validate auth token")
+		return ginauth.ClaimMetadata{}, nil, ginauth.NewAuthenticationError("unable to validate auth token")
+	}
+
+	if !containsString(m.issuers, cl.Issuer) {
+		return ginauth.ClaimMetadata{}, nil, ginauth.NewTokenValidationError(jwt.ErrInvalidIssuer)
 	}
 
-	err = cl.Validate(jwt.Expected{
-		Issuer:   m.config.Issuer,
-		Audience: jwt.Audience{m.config.Audience},
-		Time:     time.Now(),
-	})
+	err = cl.ValidateWithLeeway(jwt.Expected{
+		Time: time.Now(),
+	}, m.config.clockSkew())
 	if err != nil {
-		return ginauth.ClaimMetadata{}, ginauth.NewTokenValidationError(err)
+		return ginauth.ClaimMetadata{}, nil, ginauth.NewTokenValidationError(err)
+	}
+
+	if !hasAnyAudience(cl.Audience, m.audiences) && !m.authorizedPartyAudience(sc) {
+		return ginauth.ClaimMetadata{}, nil, ginauth.NewTokenValidationError(jwt.ErrInvalidAudience)
+	}
+
+	if err := m.verifyHostedDomain(sc); err != nil {
+		return ginauth.ClaimMetadata{}, nil, err
 	}
 
-	var roles []string
-	switch r := sc[m.config.RolesClaim].(type) {
-	case string:
-		roles = strings.Split(r, " ")
-	case []interface{}:
-		for _, i := range r {
-			roles = append(roles, i.(string))
+	roles := parseRolesClaim(lookupClaim(sc, m.config.RolesClaim))
+
+	if implied := m.impliedScopes(cl.Audience); len(implied) > 0 {
+		roles = mergeRoles(roles, implied)
+	}
+
+	user := cl.Subject
+
+	for _, claim := range m.usernameClaims {
+		if u, ok := parseStringClaim(lookupClaim(sc, claim)); ok && u != "" {
+			user = u
+			break
 		}
 	}
 
-	var user string
-	switch u := sc[m.config.UsernameClaim].(type) {
-	case string:
-		user = u
-	default:
-		user = cl.Subject
+	if m.subjects != nil {
+		user, err = m.subjects.resolve(c.Request.Context(), cl.Subject, sc)
+		if err != nil {
+			return ginauth.ClaimMetadata{}, nil, ginauth.NewAuthenticationErrorFrom(fmt.Errorf("%w: %s", ErrSubjectResolution, err))
+		}
+	}
+
+	if err := m.CheckRevoked(c, cl.ID); err != nil {
+		return ginauth.ClaimMetadata{}, nil, err
+	}
+
+	if err := m.validateClaims(c, sc); err != nil {
+		return ginauth.ClaimMetadata{}, nil, err
 	}
 
-	return ginauth.ClaimMetadata{Subject: cl.Subject, User: user, Roles: roles}, nil
+	var tenant string
+	if m.config.TenantClaim != "" {
+		tenant, _ = parseStringClaim(lookupClaim(sc, m.config.TenantClaim))
+	}
+
+	cm := ginauth.ClaimMetadata{
+		Subject:   cl.Subject,
+		User:      user,
+		Roles:     roles,
+		TokenID:   cl.ID,
+		Tenant:    tenant,
+		ExpiresAt: cl.Expiry.Time(),
+		Claims:    sc,
+	}
+
+	return cm, sc, nil
 }
 
 // AuthRequired provides a middleware that ensures a request has authentication.  In order to
 // validate scopes, you also need to call RequireScopes().
 func (m *Middleware) AuthRequired() gin.HandlerFunc {
 	return func(c *gin.Context) {
+		if m.config.BypassList.Bypass(c) {
+			return
+		}
+
 		if !m.config.Enabled {
+			m.handleDisabled(c)
 			return
 		}
 
-		cm, err := m.VerifyToken(c)
+		cm, _, err := m.authorize(c, nil, false)
 		if err != nil {
-			ginauth.AbortBecauseOfError(c, err)
+			ginauth.AbortWithMessageCatalog(c, err, m.config.MessageCatalog)
 			return
 		}
 
-		c.Set(contextKeySubject, cm.Subject)
-		c.Set(contextKeyUser, cm.User)
-		c.Set(contextKeyRoles, cm.Roles)
+		m.setContext(c, cm)
+	}
+}
+
+// AuthWithScopes provides a middleware that authenticates the request and validates the passed list
+// of scopes are included in its role claims, it is AuthRequired followed by RequiredScopes in a
+// single handler so they can't be registered in the wrong order.
+func (m *Middleware) AuthWithScopes(scopes []string) gin.HandlerFunc {
+	m.warnEmptyScopes(scopes)
+
+	return func(c *gin.Context) {
+		if m.config.BypassList.Bypass(c) {
+			return
+		}
+
+		if !m.config.Enabled {
+			m.handleDisabled(c)
+			return
+		}
+
+		if _, err := m.VerifyTokenWithScopes(c, scopes); err != nil {
+			ginauth.AbortWithMessageCatalog(c, err, m.config.MessageCatalog)
+			return
+		}
 	}
 }
 
 // RequiredScopes provides middleware that validates that the passed list of scopes
 // are included in the role claims by checking the values on context.
+//
+// It must be registered after AuthRequired, requests it handles that weren't authenticated are
+// rejected with a 500 and the misconfigured route is logged, see AuthWithScopes.
 func (m *Middleware) RequiredScopes(scopes []string) gin.HandlerFunc {
+	m.warnEmptyScopes(scopes)
+
 	return func(c *gin.Context) {
+		if m.config.BypassList.Bypass(c) {
+			return
+		}
+
 		if !m.config.Enabled {
+			m.handleDisabled(c)
+			return
+		}
+
+		if !ginauth.Authenticated(c) {
+			m.reportMiddlewareOrder(c)
+			ginauth.AbortWithMessageCatalog(c, ginauth.NewMiddlewareOrderError(), m.config.MessageCatalog)
+
 			return
 		}
 
 		if err := m.VerifyScopes(c, scopes); err != nil {
-			ginauth.AbortBecauseOfError(c, err)
+			ginauth.AbortWithMessageCatalog(c, err, m.config.MessageCatalog)
 			return
 		}
 	}
 }
 
+// RequireTenant provides a middleware rejecting with a 403 the requests which token has no tenant
+// in the TenantClaim. It must run after AuthRequired.
+func (m *Middleware) RequireTenant() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if m.config.BypassList.Bypass(c) {
+			return
+		}
+
+		if !m.config.Enabled {
+			m.handleDisabled(c)
+			return
+		}
+
+		if !ginauth.Authenticated(c) {
+			m.reportMiddlewareOrder(c)
+			ginauth.AbortWithMessageCatalog(c, ginauth.NewMiddlewareOrderError(), m.config.MessageCatalog)
+
+			return
+		}
+
+		if GetTenant(c) == "" {
+			ginauth.AbortWithMessageCatalog(c, ginauth.NewAuthorizationErrorFrom(ErrMissingTenant), m.config.MessageCatalog)
+			return
+		}
+	}
+}
+
+// warnEmptyScopes reports scope middlewares registered without scopes, they let any authenticated request through
+func (m *Middleware) warnEmptyScopes(scopes []string) {
+	if len(scopes) == 0 {
+		m.logger.Warn("scope middleware registered without scopes, any authenticated request is allowed")
+	}
+}
+
+// reportMiddlewareOrder logs the routes where RequiredScopes runs before AuthRequired, once per route
+func (m *Middleware) reportMiddlewareOrder(c *gin.Context) {
+	if _, reported := m.misorderedRoutes.LoadOrStore(c.FullPath(), struct{}{}); reported {
+		return
+	}
+
+	m.logger.Error("RequiredScopes ran on a request AuthRequired didn't authenticate, requests to the route are rejected",
+		zap.String("method", c.Request.Method),
+		zap.String("route", c.FullPath()),
+	)
+}
+
+// DisabledMode returns the behavior of the middleware when auth is disabled.
+func (m *Middleware) DisabledMode() DisabledMode {
+	return m.config.DisabledMode
+}
+
+// DisabledRequests returns the number of requests handled while auth was disabled.
+func (m *Middleware) DisabledRequests() uint64 {
+	return atomic.LoadUint64(&m.disabledRequests)
+}
+
+// handleDisabled applies the disabled mode to a request.
+func (m *Middleware) handleDisabled(c *gin.Context) {
+	atomic.AddUint64(&m.disabledRequests, 1)
+
+	switch m.config.DisabledMode {
+	case DisabledModeWarn:
+		m.logger.Warn("auth is disabled, request not authenticated",
+			zap.String("method", c.Request.Method),
+			zap.String("path", c.Request.URL.Path),
+		)
+	case DisabledModeDeny:
+		ginauth.AbortWithMessageCatalog(c, ginauth.NewAuthenticationError("auth is disabled"), m.config.MessageCatalog)
+	}
+}
+
 // VerifyScopes verifies role claims added to the gin.Context object.
 // This implements the GenericMiddleware interface
 func (m *Middleware) VerifyScopes(c *gin.Context, scopes []string) error {
-	roles := c.GetStringSlice("jwt.roles")
+	return m.VerifyRoles(c.GetStringSlice(contextKeyRoles), scopes)
+}
 
+// VerifyRoles checks the given roles against the required scopes using the
+// configured role validation strategy. This implements the ginauth.RoleVerifier interface
+func (m *Middleware) VerifyRoles(roles, scopes []string) error {
+	return verifyRoles(m.config.RoleValidationStrategy, roles, scopes)
+}
+
+// verifyRoles checks the given roles against the required scopes using the strategy.
+func verifyRoles(strategy RoleValidationStrategy, roles, scopes []string) error {
 	var rolesSatisfied bool
 
-	switch m.config.RoleValidationStrategy {
+	switch strategy {
 	case "", RoleValidationStrategyAny:
 		rolesSatisfied = hasAnyScope(roles, scopes)
 	case RoleValidationStrategyAll:
@@ -259,30 +804,42 @@ func (m *Middleware) VerifyScopes(c *gin.Context, scopes []string) error {
 	return nil
 }
 
-func (m *Middleware) refreshJWKS() error {
-	var ctx context.Context
-
+func (m *Middleware) refreshJWKSContext(ctx context.Context) error {
 	// When using JWKS directly, refresh should be a no-op
 	if len(m.config.JWKS.Keys) > 0 {
 		return nil
 	}
 
-	if m.config.JWKSRemoteTimeout != 0 {
-		var cancel context.CancelFunc
+	timeout := m.config.JWKSRemoteTimeout
+	if timeout == 0 {
+		timeout = DefaultJWKSRefreshTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	if m.config.KeySetProvider != nil {
+		jwks, err := m.config.KeySetProvider.KeySet(ctx)
+		if err != nil {
+			return err
+		}
+
+		m.setJWKS(jwks)
 
-		ctx, cancel = context.WithTimeout(context.Background(), m.config.JWKSRemoteTimeout)
+		return nil
+	}
 
-		defer cancel()
-	} else {
-		ctx = context.Background()
+	jwksURI, err := m.jwksURI(ctx)
+	if err != nil {
+		return err
 	}
 
-	req, reqerr := http.NewRequestWithContext(ctx, http.MethodGet, m.config.JWKSURI, nil)
+	req, reqerr := http.NewRequestWithContext(ctx, http.MethodGet, jwksURI, nil)
 	if reqerr != nil {
 		return reqerr
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := m.config.HTTPClient.Do(req)
 	if err != nil {
 		return err
 	}
@@ -293,24 +850,275 @@ func (m *Middleware) refreshJWKS() error {
 		return fmt.Errorf("%w: %s", ginauth.ErrMiddlewareRemote, resp.Body)
 	}
 
-	return json.NewDecoder(resp.Body).Decode(&m.cachedJWKS)
+	var jwks jose.JSONWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return err
+	}
+
+	m.setJWKS(jwks)
+
+	return nil
 }
 
-func (m *Middleware) getJWKS(kid string) *jose.JSONWebKey {
-	keys := m.cachedJWKS.Key(kid)
-	if len(keys) == 0 {
-		// couldn't find the signing key in our cache, refresh cache and search again
-		if err := m.refreshJWKS(); err != nil {
+// setJWKS replaces the cached JWKS.
+func (m *Middleware) setJWKS(jwks jose.JSONWebKeySet) {
+	m.jwksMu.Lock()
+	m.retireKeys(m.cachedJWKS, jwks)
+	m.cachedJWKS = jwks
+	m.jwksMu.Unlock()
+}
+
+// fetchJWKSAtStartup fetches the JWKS, retrying with backoff as configured until the context is
+// done. When the middleware may start degraded, a failed fetch keeps being retried in the background.
+func (m *Middleware) fetchJWKSAtStartup(ctx context.Context) error {
+	backoff := m.config.JWKSStartupBackoff
+	if backoff <= 0 {
+		backoff = DefaultJWKSStartupBackoff
+	}
+
+	var err error
+
+retries:
+	for attempt := 0; ; attempt++ {
+		if err = m.refreshJWKSContext(ctx); err == nil {
 			return nil
 		}
 
-		keys = m.cachedJWKS.Key(kid)
+		m.logger.Warn("unable to fetch JWKS", m.jwksSource(), zap.Int("attempt", attempt+1), zap.Error(err))
+
+		if attempt >= m.config.JWKSStartupRetries {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			break retries
+		case <-time.After(backoff):
+		}
+
+		backoff = nextJWKSBackoff(backoff)
+	}
+
+	if !m.config.JWKSStartDegraded {
+		return err
+	}
+
+	m.logger.Warn("starting without JWKS, tokens are rejected until it is fetched", m.jwksSource())
+
+	retryCtx, cancel := context.WithCancel(context.Background())
+
+	m.stopRetries = cancel
+	m.retriesDone = make(chan struct{})
+
+	go m.retryJWKSFetch(retryCtx, backoff)
+
+	return nil
+}
+
+// retryJWKSFetch fetches the JWKS of a middleware started degraded in the background, until it is
+// fetched or Close is called.
+func (m *Middleware) retryJWKSFetch(ctx context.Context, backoff time.Duration) {
+	defer close(m.retriesDone)
+
+	for {
+		timer := time.NewTimer(backoff)
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		if err := m.refreshJWKSContext(ctx); err == nil {
+			m.logger.Info("fetched JWKS", m.jwksSource())
+			return
+		}
+
+		backoff = nextJWKSBackoff(backoff)
+	}
+}
+
+func nextJWKSBackoff(backoff time.Duration) time.Duration {
+	backoff *= 2
+	if backoff > maxJWKSStartupBackoff {
+		return maxJWKSStartupBackoff
+	}
+
+	return backoff
+}
+
+// getJWKS returns the key signing the token, refreshing the JWKS when the cache doesn't hold it.
+// The refresh is bounded by the context, ErrKeyFetchTimeout is returned when it is done first.
+func (m *Middleware) getJWKS(ctx context.Context, kid string) (*jose.JSONWebKey, error) {
+	keys := m.cachedKeys(kid)
+	if len(keys) == 0 {
+		var err error
+
+		keys, err = m.refreshForKey(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+
 		if len(keys) == 0 {
-			return nil
+			return nil, nil
+		}
+	}
+
+	return m.selectKey(keys), nil
+}
+
+// refreshForKey refreshes the cache when it doesn't hold the signing key and searches again.
+// Concurrent callers are serialized and skip the refresh when another one already fetched the key.
+// Failed refreshes return no keys, except when the context is done which is reported as ErrKeyFetchTimeout.
+func (m *Middleware) refreshForKey(ctx context.Context, kid string) ([]jose.JSONWebKey, error) {
+	select {
+	case m.refreshing <- struct{}{}:
+	case <-ctx.Done():
+		return nil, fmt.Errorf("%w: %s", ErrKeyFetchTimeout, ctx.Err())
+	}
+
+	defer func() { <-m.refreshing }()
+
+	if keys := m.cachedKeys(kid); len(keys) > 0 {
+		return keys, nil
+	}
+
+	if err := m.refreshJWKSContext(ctx); err != nil {
+		if ctx.Err() != nil || errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w: %s", ErrKeyFetchTimeout, err)
 		}
+
+		return nil, nil
 	}
 
-	return &keys[0]
+	return m.cachedKeys(kid), nil
+}
+
+func (m *Middleware) cachedKeys(kid string) []jose.JSONWebKey {
+	m.jwksMu.RLock()
+	defer m.jwksMu.RUnlock()
+
+	if keys := m.cachedJWKS.Key(kid); len(keys) > 0 {
+		return keys
+	}
+
+	return m.retiredKeysFor(kid)
+}
+
+// audiences returns all the audiences a token may be issued for.
+func (c *AuthConfig) audiences() []string {
+	auds := make([]string, 0, len(c.Audiences)+1)
+
+	if c.Audience != "" {
+		auds = append(auds, c.Audience)
+	}
+
+	for _, aud := range c.Audiences {
+		if aud != "" {
+			auds = append(auds, aud)
+		}
+	}
+
+	return auds
+}
+
+// clockSkew returns the leeway allowed when validating token time claims, set by ClockSkew or Leeway.
+func (c *AuthConfig) clockSkew() time.Duration {
+	switch {
+	case c.ClockSkew != 0:
+		return c.ClockSkew
+	case c.Leeway != 0:
+		return c.Leeway
+	default:
+		return jwt.DefaultLeeway
+	}
+}
+
+func hasAnyAudience(have jwt.Audience, accepted []string) bool {
+	for _, aud := range accepted {
+		if have.Contains(aud) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// impliedScopes returns the scopes implied by the audiences of the token, see AuthConfig.AudienceScopes.
+func (m *Middleware) impliedScopes(auds jwt.Audience) []string {
+	var scopes []string
+
+	for _, aud := range auds {
+		scopes = append(scopes, m.config.AudienceScopes[aud]...)
+	}
+
+	return scopes
+}
+
+// parseRolesClaim decodes a roles claim which may either be a space separated
+// string or a list of strings. Nested lists are flattened and any other value in
+// the list, such as a number, null or an object, is skipped. Any other shape
+// yields no roles.
+func parseRolesClaim(raw json.RawMessage) []string {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	if s, ok := parseStringClaim(raw); ok {
+		return strings.Fields(s)
+	}
+
+	var items []interface{}
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil
+	}
+
+	return appendRoles(make([]string, 0, len(items)), items)
+}
+
+// appendRoles appends the non empty strings of the list to the roles, flattening nested lists.
+func appendRoles(roles []string, items []interface{}) []string {
+	for _, i := range items {
+		switch r := i.(type) {
+		case string:
+			if r != "" {
+				roles = append(roles, r)
+			}
+		case []interface{}:
+			roles = appendRoles(roles, r)
+		}
+	}
+
+	return roles
+}
+
+// decodeClaims decodes the claims of the token, see GetClaims.
+func decodeClaims(claims map[string]json.RawMessage) map[string]interface{} {
+	decoded := make(map[string]interface{}, len(claims))
+
+	for name, raw := range claims {
+		var v interface{}
+		if err := json.Unmarshal(raw, &v); err == nil {
+			decoded[name] = v
+		}
+	}
+
+	return decoded
+}
+
+// parseStringClaim decodes a claim holding a JSON string.
+func parseStringClaim(raw json.RawMessage) (string, bool) {
+	if len(raw) == 0 || raw[0] != '"' {
+		return "", false
+	}
+
+	var s string
+	if err := json.Unmarshal(raw, &s); err != nil {
+		return "", false
+	}
+
+	return s, true
 }
 
 func hasAllScopes(have, needed []string) bool {
@@ -367,3 +1175,29 @@ func GetSubject(c *gin.Context) string {
 func GetUser(c *gin.Context) string {
 	return c.GetString(contextKeyUser)
 }
+
+// GetTenant will return the tenant read from the TenantClaim of the JWT that is saved in the request. This requires that
+// authentication of the request has already occurred. If authentication failed or the token has no tenant an empty string
+// is returned.
+func GetTenant(c *gin.Context) string {
+	return c.GetString(contextKeyTenant)
+}
+
+// GetRoles will return the roles read from the RolesClaim of the JWT that is saved in the request, along with the scopes
+// implied by its audiences. This requires that authentication of the request has already occurred. If authentication
+// failed or the token has no roles nil is returned.
+func GetRoles(c *gin.Context) []string {
+	return c.GetStringSlice(contextKeyRoles)
+}
+
+// GetClaims will return all the claims of the JWT that is saved in the request, decoded from JSON on each call: numbers
+// are float64, objects are maps and lists are slices. This requires that authentication of the request has already
+// occurred. If authentication failed nil is returned.
+func GetClaims(c *gin.Context) map[string]interface{} {
+	raw, _ := c.Value(contextKeyClaims).(map[string]json.RawMessage)
+	if raw == nil {
+		return nil
+	}
+
+	return decodeClaims(raw)
+}
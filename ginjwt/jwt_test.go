@@ -3,16 +3,21 @@ package ginjwt_test
 import (
 	"bytes"
 	"crypto/rsa"
+	"encoding/json"
 	"fmt"
+	"math/rand"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
 	"gopkg.in/square/go-jose.v2"
 	"gopkg.in/square/go-jose.v2/jwt"
 
@@ -669,6 +674,8 @@ func TestVerifyTokenWithScopes(t *testing.T) {
 			}
 
 			assert.NoError(t, err)
+
+			tt.want.Claims = tokenClaims(t, rawToken)
 			assert.Equal(t, tt.want, got)
 		})
 	}
@@ -770,3 +777,782 @@ func TestAuthMiddlewareConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyTokenRolesClaimShapes(t *testing.T) {
+	testCases := []struct {
+		testName  string
+		rolesJSON interface{}
+		wantRoles []string
+	}{
+		{
+			"space separated string",
+			"read write",
+			[]string{"read", "write"},
+		},
+		{
+			"list of strings",
+			[]string{"read", "write"},
+			[]string{"read", "write"},
+		},
+		{
+			"list with non-string entries",
+			[]interface{}{"read", 1, "write"},
+			[]string{"read", "write"},
+		},
+		{
+			"unsupported type",
+			42,
+			nil,
+		},
+		{
+			"string with repeated spaces",
+			" read  write ",
+			[]string{"read", "write"},
+		},
+		{
+			"empty string",
+			"",
+			nil,
+		},
+		{
+			"nested lists",
+			[]interface{}{"read", []interface{}{"write", []interface{}{"admin", 3}}},
+			[]string{"read", "write", "admin"},
+		},
+		{
+			"list with nulls, objects and empty strings",
+			[]interface{}{nil, "read", map[string]interface{}{"role": "admin"}, "", true},
+			[]string{"read"},
+		},
+		{
+			"null",
+			nil,
+			nil,
+		},
+		{
+			"object",
+			map[string]interface{}{"roles": []string{"admin"}},
+			nil,
+		},
+	}
+
+	cfg := ginjwt.AuthConfig{
+		Enabled:  true,
+		Audience: "ginjwt.test",
+		Issuer:   "ginjwt.test.issuer",
+		JWKS:     ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+	}
+
+	authMW, err := ginjwt.NewAuthMiddleware(cfg)
+	require.NoError(t, err)
+
+	for _, tt := range testCases {
+		t.Run(tt.testName, func(t *testing.T) {
+			signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+			claims := jwt.Claims{
+				Subject:   "test-user",
+				Issuer:    "ginjwt.test.issuer",
+				NotBefore: jwt.NewNumericDate(time.Now().Add(-2 * time.Hour)),
+				Audience:  jwt.Audience{"ginjwt.test"},
+			}
+			rawToken := ginjwt.TestHelperGetToken(signer, claims, "scope", tt.rolesJSON)
+
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "http://test/", nil)
+			c.Request.Header.Set("Authorization", fmt.Sprintf("bearer %s", rawToken))
+
+			cm, err := authMW.VerifyToken(c)
+			require.NoError(t, err)
+
+			if tt.wantRoles == nil {
+				assert.Empty(t, cm.Roles)
+			} else {
+				assert.Equal(t, tt.wantRoles, cm.Roles)
+			}
+
+			assert.Equal(t, "test-user", cm.User)
+		})
+	}
+}
+
+func TestVerifyTokenAudiencesAndClockSkew(t *testing.T) {
+	testCases := []struct {
+		testName  string
+		audiences []string
+		clockSkew time.Duration
+		claims    jwt.Claims
+		wantErr   string
+	}{
+		{
+			"token for any accepted audience",
+			[]string{"ginjwt.other", "ginjwt.test"},
+			0,
+			jwt.Claims{
+				Audience:  jwt.Audience{"ginjwt.test"},
+				NotBefore: jwt.NewNumericDate(time.Now().Add(-2 * time.Hour)),
+			},
+			"",
+		},
+		{
+			"token for no accepted audience",
+			[]string{"ginjwt.other", "ginjwt.another"},
+			0,
+			jwt.Claims{
+				Audience:  jwt.Audience{"ginjwt.test"},
+				NotBefore: jwt.NewNumericDate(time.Now().Add(-2 * time.Hour)),
+			},
+			"invalid audience claim",
+		},
+		{
+			"expired token within clock skew",
+			[]string{"ginjwt.test"},
+			5 * time.Minute,
+			jwt.Claims{
+				Audience: jwt.Audience{"ginjwt.test"},
+				Expiry:   jwt.NewNumericDate(time.Now().Add(-2 * time.Minute)),
+			},
+			"",
+		},
+		{
+			"token not valid yet within clock skew",
+			[]string{"ginjwt.test"},
+			5 * time.Minute,
+			jwt.Claims{
+				Audience:  jwt.Audience{"ginjwt.test"},
+				NotBefore: jwt.NewNumericDate(time.Now().Add(2 * time.Minute)),
+				IssuedAt:  jwt.NewNumericDate(time.Now().Add(2 * time.Minute)),
+			},
+			"",
+		},
+		{
+			"token not valid yet outside clock skew",
+			[]string{"ginjwt.test"},
+			time.Second,
+			jwt.Claims{
+				Audience:  jwt.Audience{"ginjwt.test"},
+				NotBefore: jwt.NewNumericDate(time.Now().Add(2 * time.Minute)),
+			},
+			"token not valid yet",
+		},
+		{
+			"expired token outside clock skew",
+			[]string{"ginjwt.test"},
+			time.Second,
+			jwt.Claims{
+				Audience: jwt.Audience{"ginjwt.test"},
+				Expiry:   jwt.NewNumericDate(time.Now().Add(-2 * time.Minute)),
+			},
+			"token is expired",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.testName, func(t *testing.T) {
+			cfg := ginjwt.AuthConfig{
+				Enabled:   true,
+				Audiences: tt.audiences,
+				Issuer:    "ginjwt.test.issuer",
+				JWKS:      ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+				ClockSkew: tt.clockSkew,
+			}
+
+			authMW, err := ginjwt.NewAuthMiddleware(cfg)
+			require.NoError(t, err)
+
+			tt.claims.Subject = "test-user"
+			tt.claims.Issuer = "ginjwt.test.issuer"
+
+			signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+			rawToken := ginjwt.TestHelperGetToken(signer, tt.claims, "scope", "read")
+
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "http://test/", nil)
+			c.Request.Header.Set("Authorization", fmt.Sprintf("bearer %s", rawToken))
+
+			_, err = authMW.VerifyToken(c)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+
+	_, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:   true,
+		Audience:  "ginjwt.test",
+		Issuer:    "ginjwt.test.issuer",
+		JWKS:      ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+		ClockSkew: -time.Minute,
+	})
+	assert.ErrorIs(t, err, ginjwt.ErrInvalidAuthConfig)
+
+	_, err = ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:  true,
+		Audience: "ginjwt.test",
+		Issuer:   "ginjwt.test.issuer",
+		JWKS:     ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+		Leeway:   -time.Minute,
+	})
+	assert.ErrorIs(t, err, ginjwt.ErrInvalidAuthConfig)
+
+	// Leeway is an alias of ClockSkew, they can't conflict
+	_, err = ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:   true,
+		Audience:  "ginjwt.test",
+		Issuer:    "ginjwt.test.issuer",
+		JWKS:      ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+		ClockSkew: time.Minute,
+		Leeway:    5 * time.Minute,
+	})
+	assert.ErrorIs(t, err, ginjwt.ErrInvalidAuthConfig)
+}
+
+func TestVerifyTokenLeeway(t *testing.T) {
+	signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+	rawToken := ginjwt.TestHelperGetToken(signer, jwt.Claims{
+		Subject:   "test-user",
+		Issuer:    "ginjwt.test.issuer",
+		Audience:  jwt.Audience{"ginjwt.test"},
+		NotBefore: jwt.NewNumericDate(time.Now().Add(2 * time.Minute)),
+		Expiry:    jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}, "scope", "read")
+
+	testCases := []struct {
+		name              string
+		clockSkew, leeway time.Duration
+		wantErr           string
+	}{
+		{"default", 0, 0, "token not valid yet"},
+		{"leeway", 0, 5 * time.Minute, ""},
+		{"too short leeway", 0, time.Second, "token not valid yet"},
+		{"same clock skew and leeway", 5 * time.Minute, 5 * time.Minute, ""},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+				Enabled:   true,
+				Audience:  "ginjwt.test",
+				Issuer:    "ginjwt.test.issuer",
+				JWKS:      ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+				ClockSkew: tt.clockSkew,
+				Leeway:    tt.leeway,
+			})
+			require.NoError(t, err)
+
+			_, err = authMW.VerifyToken(tokenContext(rawToken))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestVerifyTokenAudienceScopes(t *testing.T) {
+	testCases := []struct {
+		testName  string
+		audiences jwt.Audience
+		scopes    string
+		want      []string
+	}{
+		{"implied scopes added", jwt.Audience{"internal.api"}, "write", []string{"write", "read"}},
+		{"implied scopes not duplicated", jwt.Audience{"internal.api"}, "read write", []string{"read", "write"}},
+		{"implied scopes of each audience", jwt.Audience{"internal.api", "ginjwt.test"}, "", []string{"read", "list"}},
+		{"audience without implied scopes", jwt.Audience{"ginjwt.other"}, "write", []string{"write"}},
+	}
+
+	authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:   true,
+		Audiences: []string{"internal.api", "ginjwt.test", "ginjwt.other"},
+		Issuer:    "ginjwt.test.issuer",
+		JWKS:      ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+		AudienceScopes: map[string][]string{
+			"internal.api": {"read"},
+			"ginjwt.test":  {"list", "read"},
+		},
+	})
+	require.NoError(t, err)
+
+	signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+
+	for _, tt := range testCases {
+		t.Run(tt.testName, func(t *testing.T) {
+			claims := jwt.Claims{
+				Subject:   "test-user",
+				Issuer:    "ginjwt.test.issuer",
+				Audience:  tt.audiences,
+				NotBefore: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+			}
+
+			rawToken := ginjwt.TestHelperGetToken(signer, claims, "scope", tt.scopes)
+
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "http://test/", nil)
+			c.Request.Header.Set("Authorization", fmt.Sprintf("bearer %s", rawToken))
+
+			cm, err := authMW.VerifyToken(c)
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, cm.Roles)
+		})
+	}
+}
+
+func TestAudienceScopesRequireAcceptedAudience(t *testing.T) {
+	_, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:        true,
+		Audience:       "ginjwt.test",
+		Issuer:         "ginjwt.test.issuer",
+		JWKS:           ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+		AudienceScopes: map[string][]string{"internal.api": {"read"}},
+	})
+	assert.ErrorIs(t, err, ginjwt.ErrInvalidAuthConfig)
+	assert.ErrorContains(t, err, "internal.api")
+}
+
+func newBenchmarkContext(b *testing.B, claimScopes []string) *gin.Context {
+	b.Helper()
+
+	signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+	claims := jwt.Claims{
+		Subject:   "test-user",
+		Issuer:    "ginjwt.test.issuer",
+		NotBefore: jwt.NewNumericDate(time.Now().Add(-2 * time.Hour)),
+		Audience:  jwt.Audience{"ginjwt.test", "another.test.service"},
+	}
+	rawToken := ginjwt.TestHelperGetToken(signer, claims, "scope", strings.Join(claimScopes, " "))
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "http://test/", nil)
+	c.Request.Header.Set("Authorization", fmt.Sprintf("bearer %s", rawToken))
+
+	return c
+}
+
+func newBenchmarkMiddleware(b *testing.B) *ginjwt.Middleware {
+	b.Helper()
+
+	cfg := ginjwt.AuthConfig{
+		Enabled:  true,
+		Audience: "ginjwt.test",
+		Issuer:   "ginjwt.test.issuer",
+		JWKS:     ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey2ID),
+	}
+
+	authMW, err := ginjwt.NewAuthMiddleware(cfg)
+	require.NoError(b, err)
+
+	return authMW
+}
+
+func BenchmarkVerifyToken(b *testing.B) {
+	authMW := newBenchmarkMiddleware(b)
+	c := newBenchmarkContext(b, []string{"testScope", "anotherScope", "more-scopes"})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := authMW.VerifyToken(c); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkVerifyTokenWithScopes(b *testing.B) {
+	authMW := newBenchmarkMiddleware(b)
+	c := newBenchmarkContext(b, []string{"testScope", "anotherScope", "more-scopes"})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if _, err := authMW.VerifyTokenWithScopes(c, []string{"more-scopes"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkVerifyScopes(b *testing.B) {
+	authMW := newBenchmarkMiddleware(b)
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Set("jwt.roles", []string{"read", "write", "read:servers", "write:servers", "more-scopes"})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := authMW.VerifyScopes(c, []string{"create:servers", "more-scopes"}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestMiddlewareMessageCatalog(t *testing.T) {
+	catalog := ginauth.MessageCatalogFunc(func(c *gin.Context, code ginauth.ErrorCode, err error) (string, bool) {
+		if code != ginauth.CodeInvalidToken {
+			return "", false
+		}
+
+		return "Your session expired, please sign in again", true
+	})
+
+	authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:        true,
+		Audience:       "ginjwt.test",
+		Issuer:         "ginjwt.test.issuer",
+		JWKS:           ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+		MessageCatalog: catalog,
+	})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.GET("/", authMW.AuthRequired(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, "ok")
+	})
+
+	signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+	expired := ginjwt.TestHelperGetToken(signer, jwt.Claims{
+		Subject:  "test-user",
+		Issuer:   "ginjwt.test.issuer",
+		Audience: jwt.Audience{"ginjwt.test"},
+		Expiry:   jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+	}, "scope", "read")
+
+	req := httptest.NewRequest("GET", "http://test/", nil)
+	req.Header.Set("Authorization", "bearer "+expired)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, "invalid_token", body["code"])
+	assert.Equal(t, "Your session expired, please sign in again", body["message"])
+	assert.Contains(t, body["error"], "expired")
+
+	// errors without a message in the catalog keep the default one
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "http://test/", nil))
+
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "unauthenticated", body["code"])
+	assert.NotEqual(t, "Your session expired, please sign in again", body["message"])
+}
+
+func TestMiddlewareDisabledModes(t *testing.T) {
+	testCases := []struct {
+		testName     string
+		mode         ginjwt.DisabledMode
+		responseCode int
+		logs         int
+	}{
+		{"default allows", "", http.StatusOK, 1},
+		{"allow", ginjwt.DisabledModeAllow, http.StatusOK, 1},
+		{"warn", ginjwt.DisabledModeWarn, http.StatusOK, 3},
+		{"deny", ginjwt.DisabledModeDeny, http.StatusUnauthorized, 1},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.testName, func(t *testing.T) {
+			core, logs := observer.New(zap.WarnLevel)
+
+			authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+				Enabled:      false,
+				DisabledMode: tt.mode,
+				Logger:       zap.New(core),
+			})
+			require.NoError(t, err)
+
+			r := gin.New()
+			r.GET("/", authMW.AuthRequired(), authMW.RequiredScopes([]string{"read"}), func(c *gin.Context) {
+				c.JSON(http.StatusOK, "ok")
+			})
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest("GET", "http://test/", nil))
+
+			assert.Equal(t, tt.responseCode, w.Code)
+			assert.Equal(t, tt.logs, logs.Len())
+			assert.NotZero(t, authMW.DisabledRequests())
+		})
+	}
+
+	_, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{DisabledMode: "disabled-maybe"})
+	assert.ErrorIs(t, err, ginjwt.ErrInvalidAuthConfig)
+}
+
+// newFlakyJWKSServer returns a JWKS server failing the first requests.
+func newFlakyJWKSServer(t *testing.T, failures int32) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var requests int32
+
+	keySet := ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(keySet)
+	}))
+
+	t.Cleanup(srv.Close)
+
+	return srv, &requests
+}
+
+func TestNewAuthMiddlewareJWKSStartupRetry(t *testing.T) {
+	testCases := []struct {
+		testName     string
+		failures     int32
+		retries      int
+		degraded     bool
+		wantErr      bool
+		wantRequests int32
+	}{
+		{"no retries", 1, 0, false, true, 1},
+		{"recovers within retries", 2, 3, false, false, 3},
+		{"retries exhausted", 5, 2, false, true, 3},
+		{"starts degraded", 5, 1, true, false, 2},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.testName, func(t *testing.T) {
+			srv, requests := newFlakyJWKSServer(t, tt.failures)
+
+			authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+				Enabled:            true,
+				Audience:           "ginjwt.test",
+				Issuer:             "ginjwt.test.issuer",
+				JWKSURI:            srv.URL,
+				JWKSStartupRetries: tt.retries,
+				JWKSStartupBackoff: time.Millisecond,
+				JWKSStartDegraded:  tt.degraded,
+			})
+			if tt.wantErr {
+				assert.ErrorIs(t, err, ginauth.ErrMiddlewareRemote)
+				assert.Equal(t, tt.wantRequests, atomic.LoadInt32(requests))
+
+				return
+			}
+
+			require.NoError(t, err)
+
+			defer authMW.Close()
+
+			if !tt.degraded {
+				assert.Equal(t, tt.wantRequests, atomic.LoadInt32(requests))
+			}
+
+			// the JWKS is eventually fetched in the background when starting degraded.
+			signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+			rawToken := ginjwt.TestHelperGetToken(signer, jwt.Claims{
+				Subject:   "test-user",
+				Issuer:    "ginjwt.test.issuer",
+				NotBefore: jwt.NewNumericDate(time.Now().Add(-2 * time.Hour)),
+				Audience:  jwt.Audience{"ginjwt.test"},
+			}, "scope", "read")
+
+			assert.Eventually(t, func() bool {
+				c, _ := gin.CreateTestContext(httptest.NewRecorder())
+				c.Request = httptest.NewRequest("GET", "http://test/", nil)
+				c.Request.Header.Set("Authorization", fmt.Sprintf("bearer %s", rawToken))
+
+				_, err := authMW.VerifyToken(c)
+
+				return err == nil
+			}, time.Second, 10*time.Millisecond)
+		})
+	}
+}
+
+func TestAuthRequiredBypassList(t *testing.T) {
+	bypass, err := ginauth.NewBypassList(nil, ginauth.BypassPath("/healthz", http.MethodGet))
+	require.NoError(t, err)
+
+	authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:    true,
+		Audience:   "ginjwt.test",
+		Issuer:     "ginjwt.test.issuer",
+		JWKS:       ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+		BypassList: bypass,
+	})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.Use(authMW.AuthRequired(), authMW.RequiredScopes([]string{"read"}))
+	r.GET("/healthz", func(c *gin.Context) { c.JSON(http.StatusOK, "ok") })
+	r.GET("/servers", func(c *gin.Context) { c.JSON(http.StatusOK, "ok") })
+
+	for path, code := range map[string]int{"/healthz": http.StatusOK, "/servers": http.StatusUnauthorized} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "http://test"+path, nil))
+
+		assert.Equal(t, code, w.Code, path)
+	}
+}
+
+// claimShapesMiddlewares returns middlewares reading the roles and username from the top level
+// claims and from claims nested in objects.
+func claimShapesMiddlewares(t testing.TB) []*ginjwt.Middleware {
+	t.Helper()
+
+	var mws []*ginjwt.Middleware
+
+	for _, claims := range [][2]string{{"scope", "name"}, {"realm_access.roles", "profile.name"}} {
+		mw, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+			Enabled:       true,
+			Audience:      "ginjwt.test",
+			Issuer:        "ginjwt.test.issuer",
+			JWKS:          ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+			RolesClaim:    claims[0],
+			UsernameClaim: claims[1],
+		})
+		require.NoError(t, err)
+
+		mws = append(mws, mw)
+	}
+
+	return mws
+}
+
+// checkClaimShapes verifies a token holding the given raw JSON roles and username claims, at the
+// top level and nested in objects, and checks the properties holding for any claim shape.
+func checkClaimShapes(t *testing.T, mws []*ginjwt.Middleware, signer jose.Signer, roles, username []byte) {
+	t.Helper()
+
+	cl := jwt.Claims{
+		Subject:   "test-user",
+		Issuer:    "ginjwt.test.issuer",
+		NotBefore: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+		Audience:  jwt.Audience{"ginjwt.test"},
+	}
+
+	rawToken, err := jwt.Signed(signer).Claims(cl).Claims(map[string]interface{}{
+		"scope":        json.RawMessage(roles),
+		"name":         json.RawMessage(username),
+		"realm_access": map[string]interface{}{"roles": json.RawMessage(roles)},
+		"profile":      map[string]interface{}{"name": json.RawMessage(username)},
+	}).CompactSerialize()
+	require.NoError(t, err)
+
+	for _, mw := range mws {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "http://test/", nil)
+		c.Request.Header.Set("Authorization", "bearer "+rawToken)
+
+		var cm ginauth.ClaimMetadata
+
+		require.NotPanics(t, func() {
+			cm, err = mw.VerifyToken(c)
+		})
+
+		// the shape of the claims never makes a valid token invalid
+		require.NoError(t, err)
+		assert.Equal(t, "test-user", cm.Subject)
+		assert.NotEmpty(t, cm.User, "the username falls back to the subject")
+
+		for _, r := range cm.Roles {
+			assert.NotEmpty(t, r, "roles are never empty")
+		}
+
+		var rolesString string
+		if json.Unmarshal(roles, &rolesString) == nil {
+			assert.ElementsMatch(t, strings.Fields(rolesString), cm.Roles)
+		}
+
+		var user string
+		if json.Unmarshal(username, &user) == nil && user != "" {
+			assert.Equal(t, user, cm.User)
+		}
+	}
+}
+
+// randomClaimValue returns a random JSON value up to the given depth.
+func randomClaimValue(rnd *rand.Rand, depth int) interface{} {
+	const kinds = 7
+
+	kind := rnd.Intn(kinds)
+	if depth <= 0 {
+		kind %= 5
+	}
+
+	switch kind {
+	case 0:
+		return nil
+	case 1:
+		return rnd.Intn(2) == 0
+	case 2:
+		return rnd.NormFloat64() * 1e6
+	case 3:
+		return strings.Repeat(" ", rnd.Intn(2)) + fmt.Sprintf("role-%d", rnd.Intn(100)) + strings.Repeat(" ", rnd.Intn(3))
+	case 4:
+		return ""
+	case 5:
+		items := make([]interface{}, rnd.Intn(4))
+		for i := range items {
+			items[i] = randomClaimValue(rnd, depth-1)
+		}
+
+		return items
+	default:
+		obj := map[string]interface{}{}
+		for i := rnd.Intn(3); i > 0; i-- {
+			obj[fmt.Sprintf("k%d", i)] = randomClaimValue(rnd, depth-1)
+		}
+
+		return obj
+	}
+}
+
+func TestVerifyTokenClaimShapesProperties(t *testing.T) {
+	const iterations = 200
+
+	mws := claimShapesMiddlewares(t)
+	signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+	rnd := rand.New(rand.NewSource(1)) //nolint:gosec // reproducible claim shapes
+
+	for i := 0; i < iterations; i++ {
+		roles, err := json.Marshal(randomClaimValue(rnd, 3))
+		require.NoError(t, err)
+
+		username, err := json.Marshal(randomClaimValue(rnd, 1))
+		require.NoError(t, err)
+
+		checkClaimShapes(t, mws, signer, roles, username)
+	}
+}
+
+func FuzzVerifyTokenClaimShapes(f *testing.F) {
+	for _, seed := range [][2]string{
+		{`"read write"`, `"user"`},
+		{`["read",["write",[1,2.5]]]`, `42`},
+		{`[null,{"a":"b"},"",true]`, `null`},
+		{`{"roles":["admin"]}`, `["user"]`},
+		{`"  "`, `""`},
+		{`[[[[[[["deep"]]]]]]]`, `{"name":"user"}`},
+	} {
+		f.Add([]byte(seed[0]), []byte(seed[1]))
+	}
+
+	mws := claimShapesMiddlewares(f)
+	signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+
+	f.Fuzz(func(t *testing.T, roles, username []byte) {
+		if !json.Valid(roles) || !json.Valid(username) {
+			t.Skip()
+		}
+
+		checkClaimShapes(t, mws, signer, roles, username)
+	})
+}
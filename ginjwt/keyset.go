@@ -0,0 +1,21 @@
+package ginjwt
+
+import (
+	"context"
+
+	"gopkg.in/square/go-jose.v2"
+)
+
+// KeySetProvider supplies the JWKS tokens are verified with, for issuers not publishing
+// their keys at a JWKS URI. KeySet is called at startup and whenever a token is signed
+// by a key missing from the cached JWKS.
+type KeySetProvider interface {
+	KeySet(ctx context.Context) (jose.JSONWebKeySet, error)
+}
+
+// KeySetWatcher is implemented by KeySetProviders notified of key changes, the middleware
+// registers to replace its cached JWKS as soon as the keys change.
+type KeySetWatcher interface {
+	// OnKeySetChange registers fn to be called with the new JWKS each time the keys change.
+	OnKeySetChange(fn func(jose.JSONWebKeySet))
+}
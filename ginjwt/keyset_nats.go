@@ -0,0 +1,199 @@
+package ginjwt
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// ErrKeySetProvider is returned when a KeySetProvider can't supply the keys.
+var ErrKeySetProvider = errors.New("unable to provide JWKS")
+
+// NatsKVKeySetProvider is a KeySetProvider and KeySetWatcher reading JWKS documents from
+// the keys of a NATS KV bucket. The key set is the union of the keys of all the documents,
+// so keys are rotated in by putting a document and revoked by deleting it.
+//
+// Documents which can't be decoded are logged and ignored, the keys previously read
+// from them are kept.
+type NatsKVKeySetProvider struct {
+	watcher nats.KeyWatcher
+	logger  *zap.Logger
+
+	mu        sync.RWMutex
+	documents map[string]jose.JSONWebKeySet
+	listeners []func(jose.JSONWebKeySet)
+	ready     chan struct{}
+}
+
+// NatsKVKeySetOption configures a NatsKVKeySetProvider.
+type NatsKVKeySetOption func(*natsKVKeySetOptions)
+
+type natsKVKeySetOptions struct {
+	keys   string
+	logger *zap.Logger
+}
+
+// WithKeySetKeys sets the keys of the bucket holding the JWKS documents, wildcards are
+// accepted. Defaults to all the keys of the bucket.
+func WithKeySetKeys(keys string) NatsKVKeySetOption {
+	return func(o *natsKVKeySetOptions) {
+		o.keys = keys
+	}
+}
+
+// WithKeySetLogger sets the logger reporting documents which can't be decoded, defaults to a no-op logger.
+func WithKeySetLogger(logger *zap.Logger) NatsKVKeySetOption {
+	return func(o *natsKVKeySetOptions) {
+		o.logger = logger
+	}
+}
+
+// NewNatsKVKeySetProvider returns a NatsKVKeySetProvider watching the bucket until Stop is called.
+func NewNatsKVKeySetProvider(bucket nats.KeyValue, opts ...NatsKVKeySetOption) (*NatsKVKeySetProvider, error) {
+	if bucket == nil {
+		return nil, fmt.Errorf("%w: the NATS KV bucket can't be nil", ErrInvalidAuthConfig)
+	}
+
+	o := natsKVKeySetOptions{
+		keys:   ">",
+		logger: zap.NewNop(),
+	}
+
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	watcher, err := bucket.Watch(o.keys)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrKeySetProvider, err)
+	}
+
+	p := &NatsKVKeySetProvider{
+		watcher:   watcher,
+		logger:    o.logger,
+		documents: map[string]jose.JSONWebKeySet{},
+		ready:     make(chan struct{}),
+	}
+
+	go p.watch()
+
+	return p, nil
+}
+
+// KeySet returns the keys of all the documents, waiting for the documents in the bucket
+// to be read first.
+func (p *NatsKVKeySetProvider) KeySet(ctx context.Context) (jose.JSONWebKeySet, error) {
+	select {
+	case <-p.ready:
+	case <-ctx.Done():
+		return jose.JSONWebKeySet{}, fmt.Errorf("%w: %s", ErrKeySetProvider, ctx.Err())
+	}
+
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.keySet(), nil
+}
+
+// OnKeySetChange registers fn to be called with the new key set each time a document changes.
+func (p *NatsKVKeySetProvider) OnKeySetChange(fn func(jose.JSONWebKeySet)) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.listeners = append(p.listeners, fn)
+}
+
+// Stop stops watching the bucket, the key set isn't updated anymore.
+func (p *NatsKVKeySetProvider) Stop() error {
+	return p.watcher.Stop()
+}
+
+func (p *NatsKVKeySetProvider) watch() {
+	initial := true
+
+	for entry := range p.watcher.Updates() {
+		// a nil entry marks the end of the values initially in the bucket
+		if entry == nil {
+			if initial {
+				initial = false
+
+				close(p.ready)
+			}
+
+			continue
+		}
+
+		if !p.apply(entry) || initial {
+			continue
+		}
+
+		p.notify()
+	}
+
+	// stopped before the initial values were read, KeySet returns the documents read so far
+	if initial {
+		close(p.ready)
+	}
+}
+
+// apply updates the documents with the entry, returning whether they changed.
+func (p *NatsKVKeySetProvider) apply(entry nats.KeyValueEntry) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if entry.Operation() != nats.KeyValuePut {
+		if _, ok := p.documents[entry.Key()]; !ok {
+			return false
+		}
+
+		delete(p.documents, entry.Key())
+
+		return true
+	}
+
+	var jwks jose.JSONWebKeySet
+	if err := json.Unmarshal(entry.Value(), &jwks); err != nil {
+		p.logger.Warn("ignoring invalid JWKS document", zap.String("key", entry.Key()), zap.Uint64("revision", entry.Revision()), zap.Error(err))
+		return false
+	}
+
+	p.documents[entry.Key()] = jwks
+
+	return true
+}
+
+func (p *NatsKVKeySetProvider) notify() {
+	p.mu.RLock()
+	jwks := p.keySet()
+	listeners := p.listeners
+	p.mu.RUnlock()
+
+	for _, fn := range listeners {
+		fn(jwks)
+	}
+}
+
+// keySet merges the documents, in the order of their keys.
+func (p *NatsKVKeySetProvider) keySet() jose.JSONWebKeySet {
+	names := make([]string, 0, len(p.documents))
+	for name := range p.documents {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var jwks jose.JSONWebKeySet
+
+	for _, name := range names {
+		jwks.Keys = append(jwks.Keys, p.documents[name].Keys...)
+	}
+
+	return jwks
+}
@@ -0,0 +1,119 @@
+package ginjwt_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	srvtest "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+func putKeySet(t *testing.T, bucket nats.KeyValue, key string, keyIDs ...string) {
+	t.Helper()
+
+	doc, err := json.Marshal(ginjwt.TestHelperJoseJWKSProvider(keyIDs...))
+	require.NoError(t, err)
+
+	_, err = bucket.Put(key, doc)
+	require.NoError(t, err)
+}
+
+func verifyWithKey(mw *ginjwt.Middleware, key interface{}, keyID string) error {
+	signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, keyID, key)
+	rawToken := ginjwt.TestHelperGetToken(signer, jwt.Claims{
+		Subject:   "test-user",
+		Issuer:    "ginjwt.test.issuer",
+		NotBefore: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+		Audience:  jwt.Audience{"ginjwt.test"},
+	}, "scope", "read")
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "http://test/", nil)
+	c.Request.Header.Set("Authorization", fmt.Sprintf("bearer %s", rawToken))
+
+	_, err := mw.VerifyToken(c)
+
+	return err
+}
+
+func TestNatsKVKeySetProvider(t *testing.T) {
+	opts := srvtest.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+
+	srv := srvtest.RunServer(&opts)
+	defer srv.Shutdown()
+
+	nc, err := nats.Connect(srv.ClientURL())
+	require.NoError(t, err)
+
+	defer nc.Close()
+
+	js, err := nc.JetStream()
+	require.NoError(t, err)
+
+	bucket, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: "jwks"})
+	require.NoError(t, err)
+
+	putKeySet(t, bucket, "issuer.one", ginjwt.TestPrivRSAKey1ID)
+	_, err = bucket.Put("other", []byte("not a key set"))
+	require.NoError(t, err)
+
+	provider, err := ginjwt.NewNatsKVKeySetProvider(bucket, ginjwt.WithKeySetKeys("issuer.>"))
+	require.NoError(t, err)
+
+	defer provider.Stop()
+
+	cfg := ginjwt.AuthConfig{
+		Enabled:        true,
+		Audience:       "ginjwt.test",
+		Issuer:         "ginjwt.test.issuer",
+		KeySetProvider: provider,
+	}
+
+	_, err = ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:        true,
+		Audience:       "ginjwt.test",
+		Issuer:         "ginjwt.test.issuer",
+		JWKS:           ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+		KeySetProvider: provider,
+	})
+	require.ErrorIs(t, err, ginjwt.ErrInvalidAuthConfig)
+
+	mw, err := ginjwt.NewAuthMiddleware(cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, verifyWithKey(mw, ginjwt.TestPrivRSAKey1, ginjwt.TestPrivRSAKey1ID))
+	require.Error(t, verifyWithKey(mw, ginjwt.TestPrivRSAKey2, ginjwt.TestPrivRSAKey2ID))
+
+	// rotating in a key
+	putKeySet(t, bucket, "issuer.two", ginjwt.TestPrivRSAKey2ID)
+
+	require.Eventually(t, func() bool {
+		return verifyWithKey(mw, ginjwt.TestPrivRSAKey2, ginjwt.TestPrivRSAKey2ID) == nil
+	}, time.Second, 10*time.Millisecond)
+
+	// invalid documents keep the previous keys
+	_, err = bucket.Put("issuer.two", []byte("{"))
+	require.NoError(t, err)
+
+	// revoking a key
+	require.NoError(t, bucket.Delete("issuer.one"))
+
+	require.Eventually(t, func() bool {
+		return verifyWithKey(mw, ginjwt.TestPrivRSAKey1, ginjwt.TestPrivRSAKey1ID) != nil
+	}, time.Second, 10*time.Millisecond)
+
+	assert.NoError(t, verifyWithKey(mw, ginjwt.TestPrivRSAKey2, ginjwt.TestPrivRSAKey2ID))
+}
@@ -0,0 +1,74 @@
+package ginjwt
+
+import (
+	"github.com/gin-gonic/gin"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"go.hollow.sh/toolbox/ginauth"
+)
+
+const contextKeyParsedToken = "jwt.parsed_token"
+
+// parsedToken is a token parsed once per request, shared by the middlewares verifying it
+type parsedToken struct {
+	raw string
+	tok *jwt.JSONWebToken
+	err error
+
+	// issuer is read without verifying the signature, it is only used to match middlewares
+	issuer string
+}
+
+// parseToken parses the raw token, or returns it from the gin Context when another middleware
+// already parsed it. Only the first token parsed in a request is kept, e.g. not the nested ones.
+func parseToken(c *gin.Context, rawToken string) *parsedToken {
+	if v, ok := c.Get(contextKeyParsedToken); ok {
+		if pt, ok := v.(*parsedToken); ok && pt.raw == rawToken {
+			return pt
+		}
+	}
+
+	pt := &parsedToken{raw: rawToken}
+
+	pt.tok, pt.err = jwt.ParseSigned(rawToken)
+	if pt.err == nil {
+		cl := jwt.Claims{}
+		if err := pt.tok.UnsafeClaimsWithoutVerification(&cl); err == nil {
+			pt.issuer = cl.Issuer
+		}
+	}
+
+	if _, ok := c.Get(contextKeyParsedToken); !ok {
+		c.Set(contextKeyParsedToken, pt)
+	}
+
+	return pt
+}
+
+// MatchToken satisfies the ginauth.TokenMatcher interface, it returns an error when the issuer of
+// the token isn't one of the middleware, so that a MultiTokenMiddleware doesn't validate its
+// signature nor refresh the JWKS for it. The error is the one the verification would fail with:
+// an invalid signing key when the key isn't cached, an invalid issuer otherwise.
+//
+// Tokens which can't be parsed are matched, their verification reports why.
+func (m *Middleware) MatchToken(c *gin.Context) error {
+	if !m.config.Enabled {
+		return nil
+	}
+
+	rawToken, err := m.tokenFromRequest(c)
+	if err != nil {
+		return nil
+	}
+
+	pt := parseToken(c, rawToken)
+	if pt.err != nil || pt.tok.Headers[0].KeyID == "" || containsString(m.issuers, pt.issuer) {
+		return nil
+	}
+
+	if len(m.cachedKeys(pt.tok.Headers[0].KeyID)) == 0 {
+		return ginauth.NewInvalidSigningKeyError()
+	}
+
+	return ginauth.NewTokenValidationError(jwt.ErrInvalidIssuer)
+}
@@ -0,0 +1,97 @@
+package ginjwt_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"go.hollow.sh/toolbox/ginauth"
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+func TestMultiTokenMiddlewareMatchesIssuer(t *testing.T) {
+	srv1, _, requests1 := newRotatingJWKSServer(t, ginjwt.TestPrivRSAKey1ID)
+	srv2, _, requests2 := newRotatingJWKSServer(t, ginjwt.TestPrivRSAKey2ID)
+
+	mtm, err := ginjwt.NewMultiTokenMiddlewareFromConfigs(
+		ginjwt.AuthConfig{Enabled: true, Audience: "ginjwt.test", Issuer: "ginjwt.test.issuer", JWKSURI: srv1.URL},
+		ginjwt.AuthConfig{Enabled: true, Audience: "ginjwt.test", Issuer: "ginjwt.test.issuer2", JWKSURI: srv2.URL},
+	)
+	require.NoError(t, err)
+
+	signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey2ID, ginjwt.TestPrivRSAKey2)
+
+	r := gin.New()
+	r.GET("/", mtm.AuthRequired([]string{"read"}), func(c *gin.Context) {
+		c.JSON(http.StatusOK, ginjwt.GetSubject(c))
+	})
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "http://test/", nil)
+	req.Header.Set("Authorization", "bearer "+ginjwt.TestHelperGetToken(signer, jwt.Claims{
+		Subject:  "test-user",
+		Issuer:   "ginjwt.test.issuer2",
+		Audience: jwt.Audience{"ginjwt.test"},
+	}, "scope", "read"))
+
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "test-user")
+
+	// the JWKS of the other issuer isn't refreshed for the unknown key
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests1))
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests2))
+}
+
+func TestMatchToken(t *testing.T) {
+	authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:  true,
+		Audience: "ginjwt.test",
+		Issuer:   "ginjwt.test.issuer",
+		JWKS:     ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+	})
+	require.NoError(t, err)
+
+	signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+	unknownSigner := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey2ID, ginjwt.TestPrivRSAKey2)
+
+	testCases := []struct {
+		name    string
+		signer  jose.Signer
+		issuer  string
+		wantErr string
+	}{
+		{"matching issuer", signer, "ginjwt.test.issuer", ""},
+		{"unknown key of another issuer", unknownSigner, "ginjwt.test.issuer2", ginauth.ErrInvalidSigningKey.Error()},
+		{"known key of another issuer", signer, "ginjwt.test.issuer2", jwt.ErrInvalidIssuer.Error()},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tokenContext(ginjwt.TestHelperGetToken(tt.signer, jwt.Claims{
+				Subject:  "test-user",
+				Issuer:   tt.issuer,
+				Audience: jwt.Audience{"ginjwt.test"},
+			}, "scope", "read"))
+
+			err := authMW.MatchToken(c)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+				return
+			}
+
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+
+	// tokens which can't be parsed are left to the verification
+	assert.NoError(t, authMW.MatchToken(tokenContext("not.a.token")))
+}
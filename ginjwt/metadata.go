@@ -0,0 +1,81 @@
+package ginjwt
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetadataPath is the conventional path MetadataHandler is served on
+const MetadataPath = "/.well-known/hollow-auth"
+
+// resourcePlaceholder stands for the resource name in the scope conventions
+const resourcePlaceholder = "<resource>"
+
+// Metadata summarizes what the auth middlewares of a service expect from tokens. It holds no secrets.
+type Metadata struct {
+	// Issuers lists the token issuers accepted by the service
+	Issuers []IssuerMetadata `json:"issuers"`
+	// ScopeConventions maps each action to the scopes granting it, "<resource>" stands for the resource name
+	ScopeConventions map[string][]string `json:"scope_conventions"`
+}
+
+// IssuerMetadata summarizes the tokens accepted from an issuer
+type IssuerMetadata struct {
+	Enabled                bool                   `json:"enabled"`
+	DisabledMode           DisabledMode           `json:"disabled_mode,omitempty"`
+	Issuer                 string                 `json:"issuer,omitempty"`
+	Audiences              []string               `json:"audiences,omitempty"`
+	JWKSURI                string                 `json:"jwks_uri,omitempty"`
+	RolesClaim             string                 `json:"roles_claim,omitempty"`
+	UsernameClaim          string                 `json:"username_claim,omitempty"`
+	TenantClaim            string                 `json:"tenant_claim,omitempty"`
+	RoleValidationStrategy RoleValidationStrategy `json:"role_validation_strategy,omitempty"`
+}
+
+// Metadata returns the summary of the tokens accepted by the middleware
+func (m *Middleware) Metadata() IssuerMetadata {
+	if !m.config.Enabled {
+		return IssuerMetadata{DisabledMode: m.config.DisabledMode}
+	}
+
+	strategy := m.config.RoleValidationStrategy
+	if strategy == "" {
+		strategy = RoleValidationStrategyAny
+	}
+
+	return IssuerMetadata{
+		Enabled:                true,
+		Issuer:                 m.config.Issuer,
+		Audiences:              m.audiences,
+		JWKSURI:                m.config.JWKSURI,
+		RolesClaim:             m.config.RolesClaim,
+		UsernameClaim:          m.config.UsernameClaim,
+		TenantClaim:            m.config.TenantClaim,
+		RoleValidationStrategy: strategy,
+	}
+}
+
+// MetadataHandler returns a gin handler serving the Metadata of the given middlewares as JSON,
+// letting clients discover which issuers, audiences and scopes the service expects:
+//
+//	router.GET(ginjwt.MetadataPath, ginjwt.MetadataHandler(authMW))
+func MetadataHandler(mws ...*Middleware) gin.HandlerFunc {
+	md := Metadata{
+		Issuers: make([]IssuerMetadata, 0, len(mws)),
+		ScopeConventions: map[string][]string{
+			"create": CreateScopes(resourcePlaceholder),
+			"read":   ReadScopes(resourcePlaceholder),
+			"update": UpdateScopes(resourcePlaceholder),
+			"delete": DeleteScopes(resourcePlaceholder),
+		},
+	}
+
+	for _, mw := range mws {
+		md.Issuers = append(md.Issuers, mw.Metadata())
+	}
+
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, md)
+	}
+}
@@ -0,0 +1,59 @@
+package ginjwt_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+func TestMetadataHandler(t *testing.T) {
+	enabled, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:   true,
+		Audience:  "ginjwt.test",
+		Audiences: []string{"ginjwt.test.other"},
+		Issuer:    "ginjwt.test.issuer",
+		JWKS:      ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+	})
+	require.NoError(t, err)
+
+	disabled, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{DisabledMode: ginjwt.DisabledModeDeny})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.GET(ginjwt.MetadataPath, ginjwt.MetadataHandler(enabled, disabled))
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "http://test"+ginjwt.MetadataPath, nil))
+
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var md ginjwt.Metadata
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &md))
+
+	assert.Equal(t, []ginjwt.IssuerMetadata{
+		{
+			Enabled:                true,
+			Issuer:                 "ginjwt.test.issuer",
+			Audiences:              []string{"ginjwt.test", "ginjwt.test.other"},
+			RolesClaim:             "scope",
+			UsernameClaim:          "sub",
+			RoleValidationStrategy: ginjwt.RoleValidationStrategyAny,
+		},
+		{
+			DisabledMode: ginjwt.DisabledModeDeny,
+		},
+	}, md.Issuers)
+
+	assert.Equal(t, []string{"write", "create", "create:<resource>"}, md.ScopeConventions["create"])
+	assert.Equal(t, []string{"read", "read:<resource>"}, md.ScopeConventions["read"])
+
+	// keys are never exposed
+	assert.NotContains(t, w.Body.String(), `"keys"`)
+}
@@ -1,6 +1,9 @@
 package ginjwt
 
 import (
+	"context"
+	"sync"
+
 	"github.com/pkg/errors"
 
 	"go.hollow.sh/toolbox/ginauth"
@@ -8,17 +11,56 @@ import (
 
 // NewMultiTokenMiddlewareFromConfigs builds a MultiTokenMiddleware object from multiple AuthConfigs.
 func NewMultiTokenMiddlewareFromConfigs(cfgs ...AuthConfig) (*ginauth.MultiTokenMiddleware, error) {
+	return NewMultiTokenMiddlewareFromConfigsContext(context.Background(), cfgs...)
+}
+
+// NewMultiTokenMiddlewareFromConfigsContext builds a MultiTokenMiddleware object from multiple AuthConfigs,
+// fetching their JWKS concurrently. Fetches still failing once the context is done stop being retried:
+// configs with JWKSStartDegraded keep fetching their JWKS in the background, the others fail the
+// construction. Configs with JWKSLazyFetch don't fetch their JWKS at all until the first token.
+// The background refreshes of configs with a JWKSRefreshInterval stop with MultiTokenMiddleware.Close.
+func NewMultiTokenMiddlewareFromConfigsContext(ctx context.Context, cfgs ...AuthConfig) (*ginauth.MultiTokenMiddleware, error) {
 	if len(cfgs) == 0 {
 		return nil, errors.Wrap(ErrInvalidAuthConfig, "configuration empty")
 	}
 
+	middlewares := make([]*Middleware, len(cfgs))
+
+	for i, cfg := range cfgs {
+		middleware, err := newAuthMiddleware(cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		middlewares[i] = middleware
+	}
+
+	var wg sync.WaitGroup
+
+	errs := make([]error, len(middlewares))
+
+	for i, middleware := range middlewares {
+		wg.Add(1)
+
+		go func(i int, m *Middleware) {
+			defer wg.Done()
+
+			errs[i] = m.prefetchJWKS(ctx)
+		}(i, middleware)
+	}
+
+	wg.Wait()
+
 	mtm := &ginauth.MultiTokenMiddleware{}
 
-	for _, cfg := range cfgs {
-		middleware, err := NewAuthMiddleware(cfg)
+	for _, err := range errs {
 		if err != nil {
 			return nil, err
 		}
+	}
+
+	for _, middleware := range middlewares {
+		middleware.startJWKSRefresher()
 
 		if err := mtm.Add(middleware); err != nil {
 			return nil, err
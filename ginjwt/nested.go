@@ -0,0 +1,94 @@
+package ginjwt
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"go.hollow.sh/toolbox/ginauth"
+)
+
+// newNestedMiddleware returns the middleware verifying the tokens wrapped in the NestedTokenClaim.
+func newNestedMiddleware(cfg AuthConfig) (*Middleware, error) {
+	if cfg.NestedTokenConfig == nil || !cfg.NestedTokenConfig.Enabled {
+		return nil, fmt.Errorf("%w: an enabled NestedTokenConfig is required with the NestedTokenClaim", ErrInvalidAuthConfig)
+	}
+
+	if cfg.NestedTokenConfig.NestedTokenClaim != "" {
+		return nil, fmt.Errorf("%w: nested tokens can't wrap other tokens", ErrInvalidAuthConfig)
+	}
+
+	nestedCfg := *cfg.NestedTokenConfig
+	if nestedCfg.Logger == nil {
+		nestedCfg.Logger = cfg.Logger
+	}
+
+	if nestedCfg.HTTPClient == nil {
+		nestedCfg.HTTPClient = cfg.HTTPClient
+	}
+
+	if nestedCfg.RevocationStore == nil {
+		nestedCfg.RevocationStore = cfg.RevocationStore
+		nestedCfg.RevocationFailOpen = cfg.RevocationFailOpen
+	}
+
+	// both tokens are validated against the clock of the same host
+	if nestedCfg.ClockSkew == 0 && nestedCfg.Leeway == 0 {
+		nestedCfg.ClockSkew = cfg.ClockSkew
+		nestedCfg.Leeway = cfg.Leeway
+	}
+
+	return NewAuthMiddleware(nestedCfg)
+}
+
+// verifyNestedToken verifies the token wrapped in the verified outer token claims and merges their
+// metadata, it also returns when the earliest of both tokens expires. The claims are the ones of the
+// inner token, like the subject and user.
+func (m *Middleware) verifyNestedToken(c *gin.Context, outer ginauth.ClaimMetadata, claims map[string]json.RawMessage) (ginauth.ClaimMetadata, time.Time, error) {
+	rawToken, ok := parseStringClaim(claims[m.config.NestedTokenClaim])
+	if !ok || rawToken == "" {
+		return ginauth.ClaimMetadata{}, time.Time{}, ginauth.NewAuthenticationError("missing nested token claim " + m.config.NestedTokenClaim)
+	}
+
+	inner, innerClaims, err := m.nested.verifyRawToken(c, rawToken)
+	if err != nil {
+		return ginauth.ClaimMetadata{}, time.Time{}, err
+	}
+
+	tenant := inner.Tenant
+	if tenant == "" {
+		tenant = outer.Tenant
+	}
+
+	expiry := earliest(tokenExpiry(claims), tokenExpiry(innerClaims))
+
+	return ginauth.ClaimMetadata{
+		Subject:   inner.Subject,
+		User:      inner.User,
+		Roles:     mergeRoles(outer.Roles, inner.Roles),
+		TokenID:   outer.TokenID,
+		Tenant:    tenant,
+		ExpiresAt: expiry,
+		Claims:    inner.Claims,
+	}, expiry, nil
+}
+
+// mergeRoles returns the roles of both lists, without duplicates.
+func mergeRoles(a, b []string) []string {
+	seen := make(map[string]struct{}, len(a)+len(b))
+	roles := make([]string, 0, len(a)+len(b))
+
+	for _, r := range append(append([]string{}, a...), b...) {
+		if _, ok := seen[r]; ok {
+			continue
+		}
+
+		seen[r] = struct{}{}
+
+		roles = append(roles, r)
+	}
+
+	return roles
+}
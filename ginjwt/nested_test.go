@@ -0,0 +1,205 @@
+package ginjwt_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"go.hollow.sh/toolbox/ginauth"
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+func TestVerifyTokenNestedToken(t *testing.T) {
+	outerSigner := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+	innerSigner := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey2ID, ginjwt.TestPrivRSAKey2)
+
+	innerClaims := jwt.Claims{
+		Subject:   "inner-user",
+		Issuer:    "vendor.inner.issuer",
+		NotBefore: jwt.NewNumericDate(time.Now().Add(-2 * time.Hour)),
+		Audience:  jwt.Audience{"vendor.api"},
+	}
+
+	outerToken := func(claims map[string]interface{}) string {
+		raw, err := jwt.Signed(outerSigner).Claims(jwt.Claims{
+			Subject:   "outer-client",
+			Issuer:    "ginjwt.test.issuer",
+			NotBefore: jwt.NewNumericDate(time.Now().Add(-2 * time.Hour)),
+			Audience:  jwt.Audience{"ginjwt.test"},
+		}).Claims(claims).CompactSerialize()
+		require.NoError(t, err)
+
+		return raw
+	}
+
+	innerToken := ginjwt.TestHelperGetToken(innerSigner, innerClaims, "roles", []string{"write", "admin"})
+
+	authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:          true,
+		Audience:         "ginjwt.test",
+		Issuer:           "ginjwt.test.issuer",
+		JWKS:             ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+		NestedTokenClaim: "access_token",
+		NestedTokenConfig: &ginjwt.AuthConfig{
+			Enabled:    true,
+			Audience:   "vendor.api",
+			Issuer:     "vendor.inner.issuer",
+			JWKS:       ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey2ID),
+			RolesClaim: "roles",
+		},
+	})
+	require.NoError(t, err)
+
+	testCases := []struct {
+		testName string
+		token    string
+		want     ginauth.ClaimMetadata
+		wantErr  string
+	}{
+		{
+			"nested token",
+			outerToken(map[string]interface{}{
+				"scope":        "read write",
+				"access_token": innerToken,
+			}),
+			ginauth.ClaimMetadata{
+				Subject: "inner-user",
+				User:    "inner-user",
+				Roles:   []string{"read", "write", "admin"},
+				// the claims of the inner token, whose identity is reported
+				Claims: tokenClaims(t, innerToken),
+			},
+			"",
+		},
+		{
+			"missing nested token",
+			outerToken(map[string]interface{}{"scope": "read"}),
+			ginauth.ClaimMetadata{},
+			"missing nested token claim access_token",
+		},
+		{
+			"nested token signed with the outer key",
+			outerToken(map[string]interface{}{
+				"access_token": ginjwt.TestHelperGetToken(outerSigner, innerClaims, "roles", []string{"admin"}),
+			}),
+			ginauth.ClaimMetadata{},
+			"invalid token signing key",
+		},
+		{
+			"nested token from another issuer",
+			outerToken(map[string]interface{}{
+				"access_token": ginjwt.TestHelperGetToken(innerSigner, jwt.Claims{
+					Subject:   "inner-user",
+					Issuer:    "ginjwt.test.issuer",
+					NotBefore: jwt.NewNumericDate(time.Now().Add(-2 * time.Hour)),
+					Audience:  jwt.Audience{"vendor.api"},
+				}, "roles", []string{"admin"}),
+			}),
+			ginauth.ClaimMetadata{},
+			"invalid issuer",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.testName, func(t *testing.T) {
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "http://test/", nil)
+			c.Request.Header.Set("Authorization", fmt.Sprintf("bearer %s", tt.token))
+
+			cm, err := authMW.VerifyToken(c)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, cm)
+		})
+	}
+}
+
+func TestNestedTokenConfigRequired(t *testing.T) {
+	_, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:          true,
+		Audience:         "ginjwt.test",
+		Issuer:           "ginjwt.test.issuer",
+		JWKS:             ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+		NestedTokenClaim: "access_token",
+	})
+	assert.ErrorIs(t, err, ginjwt.ErrInvalidAuthConfig)
+}
+
+func TestNestedTokenLeeway(t *testing.T) {
+	outerSigner := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+	innerSigner := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey2ID, ginjwt.TestPrivRSAKey2)
+
+	// the inner token was issued by a host with a clock ahead
+	innerToken := ginjwt.TestHelperGetToken(innerSigner, jwt.Claims{
+		Subject:   "inner-user",
+		Issuer:    "vendor.inner.issuer",
+		NotBefore: jwt.NewNumericDate(time.Now().Add(2 * time.Minute)),
+		Audience:  jwt.Audience{"vendor.api"},
+	}, "roles", []string{"admin"})
+
+	outerToken, err := jwt.Signed(outerSigner).Claims(jwt.Claims{
+		Subject:   "outer-client",
+		Issuer:    "ginjwt.test.issuer",
+		NotBefore: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+		Audience:  jwt.Audience{"ginjwt.test"},
+	}).Claims(map[string]interface{}{"access_token": innerToken}).CompactSerialize()
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name                     string
+		clockSkew, leeway        time.Duration
+		nestedSkew, nestedLeeway time.Duration
+		wantErr                  string
+	}{
+		{"default leeway", 0, 0, 0, 0, "token not valid yet"},
+		{"clock skew inherited", 5 * time.Minute, 0, 0, 0, ""},
+		{"leeway inherited", 0, 5 * time.Minute, 0, 0, ""},
+		{"nested leeway", 0, 0, 0, 5 * time.Minute, ""},
+		{"nested clock skew", 0, 0, 5 * time.Minute, 0, ""},
+		{"nested leeway overrides", 0, 5 * time.Minute, 0, time.Second, "token not valid yet"},
+		{"nested clock skew overrides", 5 * time.Minute, 0, time.Second, 0, "token not valid yet"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+				Enabled:          true,
+				Audience:         "ginjwt.test",
+				Issuer:           "ginjwt.test.issuer",
+				JWKS:             ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+				ClockSkew:        tt.clockSkew,
+				Leeway:           tt.leeway,
+				NestedTokenClaim: "access_token",
+				NestedTokenConfig: &ginjwt.AuthConfig{
+					Enabled:   true,
+					Audience:  "vendor.api",
+					Issuer:    "vendor.inner.issuer",
+					JWKS:      ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey2ID),
+					ClockSkew: tt.nestedSkew,
+					Leeway:    tt.nestedLeeway,
+				},
+			})
+			require.NoError(t, err)
+
+			cm, err := authMW.VerifyToken(tokenContext(outerToken))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, "inner-user", cm.Subject)
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package ginjwt
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"go.hollow.sh/toolbox/ginauth/scopes"
+)
+
+// OpenAPIVersion is the OpenAPI version of the documents generated by OpenAPISecurity, security
+// requirements only hold scopes for the oauth2 and openIdConnect schemes before 3.1.
+const OpenAPIVersion = "3.1.0"
+
+// DefaultOpenAPISchemeName is the name of the security scheme when OpenAPIOptions SchemeName is unspecified
+const DefaultOpenAPISchemeName = "hollowAuth"
+
+// OpenAPIOptions configures the document generated by OpenAPISecurity
+type OpenAPIOptions struct {
+	// SchemeName is the key of the security scheme. Defaults to DefaultOpenAPISchemeName if unspecified.
+	SchemeName string
+	// Description describes the security scheme, the accepted audiences are listed if unspecified.
+	Description string
+}
+
+// OpenAPIDocument is an OpenAPI 3 document fragment holding the security scheme of the middleware
+// and the security requirements of the routes, it is merged into the API document of the service.
+type OpenAPIDocument struct {
+	OpenAPI    string                                 `json:"openapi"`
+	Components OpenAPIComponents                      `json:"components"`
+	Paths      map[string]map[string]OpenAPIOperation `json:"paths"`
+}
+
+// OpenAPIComponents holds the security schemes of an OpenAPIDocument
+type OpenAPIComponents struct {
+	SecuritySchemes map[string]OpenAPISecurityScheme `json:"securitySchemes"`
+}
+
+// OpenAPISecurityScheme is an OpenAPI security scheme object
+type OpenAPISecurityScheme struct {
+	Type             string `json:"type"`
+	Description      string `json:"description,omitempty"`
+	Scheme           string `json:"scheme,omitempty"`
+	BearerFormat     string `json:"bearerFormat,omitempty"`
+	OpenIDConnectURL string `json:"openIdConnectUrl,omitempty"`
+}
+
+// OpenAPIOperation holds the security requirements of an operation, any of which grants access.
+// An empty list means the operation requires no authentication.
+type OpenAPIOperation struct {
+	Security []map[string][]string `json:"security"`
+}
+
+// OpenAPISecurity returns the OpenAPI security scheme of the middleware and the security
+// requirements of the routes, keeping the API docs in sync with the middleware configuration:
+//
+//	doc := authMW.OpenAPISecurity(ginjwt.OpenAPIOptions{}, registry.Routes()...)
+//	out, err := json.MarshalIndent(doc, "", "  ")
+//
+// The scheme is openIdConnect, discovered from the issuer, when the Issuer is a URL and a bearer
+// JWT otherwise. With the any RoleValidationStrategy each scope of a route is a requirement of its
+// own, with the all strategy a route has a single requirement listing all its scopes. The gin path
+// parameters are converted to the OpenAPI templates, e.g. /servers/:id is /servers/{id}.
+// Routes require no authentication when the middleware is disabled, unless its DisabledMode denies
+// all requests.
+func (m *Middleware) OpenAPISecurity(opts OpenAPIOptions, routes ...scopes.Route) OpenAPIDocument {
+	name := opts.SchemeName
+	if name == "" {
+		name = DefaultOpenAPISchemeName
+	}
+
+	doc := OpenAPIDocument{
+		OpenAPI: OpenAPIVersion,
+		Components: OpenAPIComponents{
+			SecuritySchemes: map[string]OpenAPISecurityScheme{name: m.openAPISecurityScheme(opts.Description)},
+		},
+		Paths: map[string]map[string]OpenAPIOperation{},
+	}
+
+	for _, r := range routes {
+		path := openAPIPath(r.Path)
+
+		if doc.Paths[path] == nil {
+			doc.Paths[path] = map[string]OpenAPIOperation{}
+		}
+
+		doc.Paths[path][strings.ToLower(r.Method)] = OpenAPIOperation{Security: m.openAPIRequirements(name, r.Scopes)}
+	}
+
+	return doc
+}
+
+func (m *Middleware) openAPISecurityScheme(description string) OpenAPISecurityScheme {
+	if description == "" && len(m.audiences) > 0 {
+		description = "JWT issued for the audiences: " + strings.Join(m.audiences, ", ")
+	}
+
+	if isHTTPURL(m.config.Issuer) {
+		return OpenAPISecurityScheme{
+			Type:             "openIdConnect",
+			Description:      description,
+			OpenIDConnectURL: strings.TrimSuffix(m.config.Issuer, "/") + OIDCDiscoveryPath,
+		}
+	}
+
+	return OpenAPISecurityScheme{
+		Type:         "http",
+		Description:  description,
+		Scheme:       "bearer",
+		BearerFormat: "JWT",
+	}
+}
+
+func (m *Middleware) openAPIRequirements(name string, required []string) []map[string][]string {
+	requirements := []map[string][]string{}
+
+	switch {
+	case !m.config.Enabled && m.config.DisabledMode != DisabledModeDeny:
+		// no authentication required
+	case len(required) == 0:
+		requirements = append(requirements, map[string][]string{name: {}})
+	case m.config.RoleValidationStrategy == RoleValidationStrategyAll:
+		requirements = append(requirements, map[string][]string{name: required})
+	default:
+		for _, scope := range required {
+			requirements = append(requirements, map[string][]string{name: {scope}})
+		}
+	}
+
+	return requirements
+}
+
+// openAPIPath converts the gin path parameters to OpenAPI path templates
+func openAPIPath(path string) string {
+	segments := strings.Split(path, "/")
+
+	for i, s := range segments {
+		if strings.HasPrefix(s, ":") || strings.HasPrefix(s, "*") {
+			segments[i] = "{" + s[1:] + "}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// ScopeRegistry records the scopes required by the routes as they are registered, for
+// OpenAPISecurity and scopes.Lint. The path is the full path of the route, including the
+// base path of its router group.
+//
+//	router.GET("/servers/:id", authMW.AuthRequired(),
+//		authMW.RequiredScopes(registry.Scopes(http.MethodGet, "/servers/:id", ginjwt.ReadScopes("server"))))
+type ScopeRegistry struct {
+	mu     sync.Mutex
+	routes map[string]scopes.Route
+}
+
+// Scopes records the scopes required by the route and returns them, a route registered again
+// replaces the scopes recorded before.
+func (r *ScopeRegistry) Scopes(method, path string, required []string) []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.routes == nil {
+		r.routes = map[string]scopes.Route{}
+	}
+
+	method = strings.ToUpper(method)
+
+	r.routes[method+" "+path] = scopes.Route{Method: method, Path: path, Scopes: required}
+
+	return required
+}
+
+// Routes returns the routes recorded, sorted by path and method
+func (r *ScopeRegistry) Routes() []scopes.Route {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	routes := make([]scopes.Route, 0, len(r.routes))
+	for _, route := range r.routes {
+		routes = append(routes, route)
+	}
+
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].Path != routes[j].Path {
+			return routes[i].Path < routes[j].Path
+		}
+
+		return routes[i].Method < routes[j].Method
+	})
+
+	return routes
+}
@@ -0,0 +1,83 @@
+package ginjwt_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.hollow.sh/toolbox/ginauth/scopes"
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+func TestOpenAPISecurity(t *testing.T) {
+	registry := &ginjwt.ScopeRegistry{}
+
+	assert.Equal(t, []string{"read", "read:server"}, registry.Scopes(http.MethodGet, "/servers/:id", ginjwt.ReadScopes("server")))
+	registry.Scopes(http.MethodDelete, "/servers/:id", []string{"delete:server"})
+	registry.Scopes(http.MethodGet, "/files/*path", nil)
+
+	assert.Equal(t, []scopes.Route{
+		{Method: http.MethodGet, Path: "/files/*path"},
+		{Method: http.MethodDelete, Path: "/servers/:id", Scopes: []string{"delete:server"}},
+		{Method: http.MethodGet, Path: "/servers/:id", Scopes: []string{"read", "read:server"}},
+	}, registry.Routes())
+
+	mw, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:  true,
+		Audience: "ginjwt.test",
+		Issuer:   "https://issuer.ginjwt.test/",
+		JWKS:     ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+	})
+	require.NoError(t, err)
+
+	out, err := json.Marshal(mw.OpenAPISecurity(ginjwt.OpenAPIOptions{SchemeName: "oidc"}, registry.Routes()...))
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{
+		"openapi": "3.1.0",
+		"components": {"securitySchemes": {"oidc": {
+			"type": "openIdConnect",
+			"description": "JWT issued for the audiences: ginjwt.test",
+			"openIdConnectUrl": "https://issuer.ginjwt.test/.well-known/openid-configuration"
+		}}},
+		"paths": {
+			"/files/{path}": {"get": {"security": [{"oidc": []}]}},
+			"/servers/{id}": {
+				"delete": {"security": [{"oidc": ["delete:server"]}]},
+				"get": {"security": [{"oidc": ["read"]}, {"oidc": ["read:server"]}]}
+			}
+		}
+	}`, string(out))
+
+	all, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:                true,
+		Audience:               "ginjwt.test",
+		Issuer:                 "ginjwt.test.issuer",
+		JWKS:                   ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+		RoleValidationStrategy: ginjwt.RoleValidationStrategyAll,
+	})
+	require.NoError(t, err)
+
+	doc := all.OpenAPISecurity(ginjwt.OpenAPIOptions{Description: "service tokens"}, registry.Routes()...)
+
+	assert.Equal(t, ginjwt.OpenAPISecurityScheme{
+		Type:         "http",
+		Description:  "service tokens",
+		Scheme:       "bearer",
+		BearerFormat: "JWT",
+	}, doc.Components.SecuritySchemes[ginjwt.DefaultOpenAPISchemeName])
+	assert.Equal(t, []map[string][]string{
+		{ginjwt.DefaultOpenAPISchemeName: {"read", "read:server"}},
+	}, doc.Paths["/servers/{id}"]["get"].Security)
+
+	disabled, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{})
+	require.NoError(t, err)
+
+	out, err = json.Marshal(disabled.OpenAPISecurity(ginjwt.OpenAPIOptions{}, registry.Routes()...).Paths["/servers/{id}"]["get"])
+	require.NoError(t, err)
+
+	assert.JSONEq(t, `{"security": []}`, string(out))
+}
@@ -0,0 +1,100 @@
+package ginjwt_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+func newOrderTestMiddleware(t *testing.T) (*ginjwt.Middleware, *observer.ObservedLogs) {
+	t.Helper()
+
+	core, logs := observer.New(zap.WarnLevel)
+
+	mw, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:  true,
+		Audience: "ginjwt.test",
+		Issuer:   "ginjwt.test.issuer",
+		JWKS:     ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+		Logger:   zap.New(core),
+	})
+	require.NoError(t, err)
+
+	return mw, logs
+}
+
+func TestRequiredScopesWithoutAuthRequired(t *testing.T) {
+	mw, logs := newOrderTestMiddleware(t)
+
+	r := gin.New()
+	r.GET("/things/:id", mw.RequiredScopes([]string{"read"}), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	for _, path := range []string{"/things/1", "/things/2"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+
+		assert.Equal(t, http.StatusInternalServerError, w.Code)
+		assert.Contains(t, w.Body.String(), `"code":"misconfigured"`)
+	}
+
+	require.Equal(t, 1, logs.Len(), "the route should be reported once")
+	assert.Equal(t, "/things/:id", logs.All()[0].ContextMap()["route"])
+}
+
+func TestRequiredScopesWithoutScopes(t *testing.T) {
+	mw, logs := newOrderTestMiddleware(t)
+
+	mw.RequiredScopes(nil)
+	mw.AuthWithScopes([]string{})
+
+	assert.Equal(t, 2, logs.FilterMessageSnippet("without scopes").Len())
+}
+
+func TestAuthWithScopes(t *testing.T) {
+	mw, _ := newOrderTestMiddleware(t)
+
+	r := gin.New()
+	r.GET("/", mw.AuthWithScopes([]string{"read"}), func(c *gin.Context) {
+		c.JSON(http.StatusOK, ginjwt.GetSubject(c))
+	})
+
+	tests := []struct {
+		name         string
+		scope        string
+		token        bool
+		responseCode int
+	}{
+		{"authorized", "read", true, http.StatusOK},
+		{"missing scope", "write", true, http.StatusForbidden},
+		{"missing token", "", false, http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.token {
+				req.Header.Set("Authorization", "bearer "+decisionCacheToken("user-1", time.Now().Add(time.Hour), tt.scope))
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.responseCode, w.Code)
+
+			if tt.responseCode == http.StatusOK {
+				assert.Equal(t, `"user-1"`, w.Body.String())
+			}
+		})
+	}
+}
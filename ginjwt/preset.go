@@ -0,0 +1,221 @@
+package ginjwt
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"go.hollow.sh/toolbox/ginauth"
+)
+
+// ProviderPreset configures the claims, issuer and audience handling for the quirks of an identity provider.
+type ProviderPreset string
+
+const (
+	// ProviderPresetAzureAD reads the roles claim and falls back to the appid and azp claims for the
+	// username of app tokens. Tokens from both the v1 (sts.windows.net) and v2 (login.microsoftonline.com)
+	// issuers of the tenant are accepted, as are audiences with and without the api:// prefix.
+	ProviderPresetAzureAD ProviderPreset = "azuread"
+	// ProviderPresetGoogle reads the email claim for the username and accepts both forms of the Google
+	// issuer. When HostedDomains is set, the hd claim of the token must be one of them.
+	ProviderPresetGoogle ProviderPreset = "google"
+	// ProviderPresetAuth0 reads the permissions claim for the roles.
+	ProviderPresetAuth0 ProviderPreset = "auth0"
+	// ProviderPresetKeycloak reads the realm_access.roles claim for the roles and preferred_username for
+	// the username. Keycloak access tokens are often only issued for the account audience, set
+	// AcceptAuthorizedParty to accept the azp claim as the audience.
+	ProviderPresetKeycloak ProviderPreset = "keycloak"
+)
+
+const (
+	azureADV1IssuerPrefix = "https://sts.windows.net/"
+	azureADV2IssuerPrefix = "https://login.microsoftonline.com/"
+	azureADV2IssuerSuffix = "/v2.0"
+	azureADAudiencePrefix = "api://"
+
+	googleIssuer     = "https://accounts.google.com"
+	googleIssuerHost = "accounts.google.com"
+)
+
+// providerQuirks holds the settings applied by a ProviderPreset. Issuers are
+// always accepted with and without a trailing slash.
+type providerQuirks struct {
+	rolesClaim     string
+	usernameClaims []string
+	// issuers returns the issuers accepted for the configured one
+	issuers func(issuer string) []string
+	// audiences returns the audiences accepted for the configured ones
+	audiences func(auds []string) []string
+	// hostedDomain requires the hd claim to be one of the HostedDomains
+	hostedDomain bool
+}
+
+var providerPresets = map[ProviderPreset]providerQuirks{
+	ProviderPresetAzureAD: {
+		rolesClaim:     "roles",
+		usernameClaims: []string{"preferred_username", "upn", "appid", "azp"},
+		issuers:        azureADIssuers,
+		audiences:      azureADAudiences,
+	},
+	ProviderPresetGoogle: {
+		usernameClaims: []string{"email"},
+		issuers: func(string) []string {
+			return []string{googleIssuer, googleIssuerHost}
+		},
+		hostedDomain: true,
+	},
+	ProviderPresetAuth0: {
+		rolesClaim: "permissions",
+	},
+	ProviderPresetKeycloak: {
+		rolesClaim:     "realm_access.roles",
+		usernameClaims: []string{"preferred_username"},
+	},
+}
+
+// quirks returns the settings of the preset, nil when no preset is set.
+func (p ProviderPreset) quirks() (*providerQuirks, error) {
+	if p == "" {
+		return nil, nil
+	}
+
+	q, ok := providerPresets[p]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown provider preset %s", ErrInvalidAuthConfig, p)
+	}
+
+	return &q, nil
+}
+
+// acceptedIssuers returns the issuers a token may be issued by.
+func (q *providerQuirks) acceptedIssuers(issuer string) []string {
+	issuers := []string{issuer}
+
+	if q == nil {
+		return issuers
+	}
+
+	if q.issuers != nil {
+		issuers = q.issuers(issuer)
+	}
+
+	var accepted []string
+
+	for _, iss := range issuers {
+		trimmed := strings.TrimSuffix(iss, "/")
+		accepted = append(accepted, trimmed, trimmed+"/")
+	}
+
+	return accepted
+}
+
+// acceptedAudiences returns the audiences a token may be issued for.
+func (q *providerQuirks) acceptedAudiences(auds []string) []string {
+	if q == nil || q.audiences == nil {
+		return auds
+	}
+
+	return q.audiences(auds)
+}
+
+// authorizedPartyAudience reports whether the azp claim of the token is one of the accepted
+// audiences, when AcceptAuthorizedParty is set.
+func (m *Middleware) authorizedPartyAudience(claims map[string]json.RawMessage) bool {
+	if !m.config.AcceptAuthorizedParty {
+		return false
+	}
+
+	azp, ok := parseStringClaim(claims["azp"])
+
+	return ok && containsString(m.audiences, azp)
+}
+
+// verifyHostedDomain ensures the hd claim of the token is one of the HostedDomains, when the preset requires it.
+func (m *Middleware) verifyHostedDomain(claims map[string]json.RawMessage) error {
+	if m.quirks == nil || !m.quirks.hostedDomain || len(m.config.HostedDomains) == 0 {
+		return nil
+	}
+
+	hd, _ := parseStringClaim(claims["hd"])
+	if !containsString(m.config.HostedDomains, hd) {
+		return ginauth.NewTokenValidationError(fmt.Errorf("%w: %q", ErrInvalidHostedDomain, hd))
+	}
+
+	return nil
+}
+
+// azureADIssuers returns the v1 and v2 issuers of the tenant of the given issuer.
+func azureADIssuers(issuer string) []string {
+	var tenant string
+
+	switch {
+	case strings.HasPrefix(issuer, azureADV1IssuerPrefix):
+		tenant = strings.TrimPrefix(issuer, azureADV1IssuerPrefix)
+	case strings.HasPrefix(issuer, azureADV2IssuerPrefix):
+		tenant = strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(issuer, azureADV2IssuerPrefix), "/"), azureADV2IssuerSuffix)
+	default:
+		return []string{issuer}
+	}
+
+	tenant = strings.Trim(tenant, "/")
+
+	return []string{
+		azureADV1IssuerPrefix + tenant + "/",
+		azureADV2IssuerPrefix + tenant + azureADV2IssuerSuffix,
+	}
+}
+
+// azureADAudiences accepts the audiences with and without the api:// prefix, v1 tokens
+// hold the application ID URI while v2 tokens hold the client ID.
+func azureADAudiences(auds []string) []string {
+	var accepted []string
+
+	for _, aud := range auds {
+		accepted = append(accepted, aud)
+
+		if strings.HasPrefix(aud, azureADAudiencePrefix) {
+			accepted = append(accepted, strings.TrimPrefix(aud, azureADAudiencePrefix))
+		} else {
+			accepted = append(accepted, azureADAudiencePrefix+aud)
+		}
+	}
+
+	return accepted
+}
+
+// lookupClaim returns the raw value of the named claim. When the token doesn't hold a claim with
+// that name, the name is read as a dotted path to a claim nested in objects, e.g. realm_access.roles.
+func lookupClaim(claims map[string]json.RawMessage, name string) json.RawMessage {
+	if raw, ok := claims[name]; ok || !strings.Contains(name, ".") {
+		return raw
+	}
+
+	parts := strings.Split(name, ".")
+
+	raw := claims[parts[0]]
+
+	for _, part := range parts[1:] {
+		if len(raw) == 0 {
+			return nil
+		}
+
+		var obj map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &obj); err != nil {
+			return nil
+		}
+
+		raw = obj[part]
+	}
+
+	return raw
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
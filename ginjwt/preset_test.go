@@ -0,0 +1,222 @@
+package ginjwt_test
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"go.hollow.sh/toolbox/ginauth"
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+func TestProviderPresets(t *testing.T) {
+	testCases := []struct {
+		testName      string
+		preset        ginjwt.ProviderPreset
+		issuer        string
+		audience      string
+		hostedDomains []string
+		tokenIssuer   string
+		tokenAudience string
+		claims        map[string]any
+		want          ginauth.ClaimMetadata
+		wantErr       string
+	}{
+		{
+			"azuread v2 token for v1 issuer",
+			ginjwt.ProviderPresetAzureAD,
+			"https://sts.windows.net/tenant-id/",
+			"api://my-app",
+			nil,
+			"https://login.microsoftonline.com/tenant-id/v2.0",
+			"my-app",
+			map[string]any{"roles": []string{"servers.read"}, "preferred_username": "user@example.com"},
+			ginauth.ClaimMetadata{Subject: "test-user", User: "user@example.com", Roles: []string{"servers.read"}},
+			"",
+		},
+		{
+			"azuread app token",
+			ginjwt.ProviderPresetAzureAD,
+			"https://login.microsoftonline.com/tenant-id/v2.0",
+			"my-app",
+			nil,
+			"https://sts.windows.net/tenant-id/",
+			"api://my-app",
+			map[string]any{"roles": []string{"servers.write"}, "appid": "client-id"},
+			ginauth.ClaimMetadata{Subject: "test-user", User: "client-id", Roles: []string{"servers.write"}},
+			"",
+		},
+		{
+			"azuread other tenant",
+			ginjwt.ProviderPresetAzureAD,
+			"https://sts.windows.net/tenant-id/",
+			"my-app",
+			nil,
+			"https://login.microsoftonline.com/other-tenant/v2.0",
+			"my-app",
+			nil,
+			ginauth.ClaimMetadata{},
+			"invalid issuer claim",
+		},
+		{
+			"google hosted domain",
+			ginjwt.ProviderPresetGoogle,
+			"https://accounts.google.com",
+			"client-id.apps.googleusercontent.com",
+			[]string{"example.com"},
+			"accounts.google.com",
+			"client-id.apps.googleusercontent.com",
+			map[string]any{"email": "user@example.com", "hd": "example.com"},
+			ginauth.ClaimMetadata{Subject: "test-user", User: "user@example.com"},
+			"",
+		},
+		{
+			"google other hosted domain",
+			ginjwt.ProviderPresetGoogle,
+			"https://accounts.google.com",
+			"client-id.apps.googleusercontent.com",
+			[]string{"example.com"},
+			"https://accounts.google.com",
+			"client-id.apps.googleusercontent.com",
+			map[string]any{"email": "user@gmail.com"},
+			ginauth.ClaimMetadata{},
+			"invalid JWT hosted domain",
+		},
+		{
+			"auth0 permissions and trailing slash issuer",
+			ginjwt.ProviderPresetAuth0,
+			"https://tenant.auth0.com",
+			"https://api.example.com",
+			nil,
+			"https://tenant.auth0.com/",
+			"https://api.example.com",
+			map[string]any{"permissions": []string{"read:servers"}},
+			ginauth.ClaimMetadata{Subject: "test-user", User: "test-user", Roles: []string{"read:servers"}},
+			"",
+		},
+		{
+			"keycloak realm roles",
+			ginjwt.ProviderPresetKeycloak,
+			"https://keycloak.example.com/realms/hollow",
+			"hollow-api",
+			nil,
+			"https://keycloak.example.com/realms/hollow",
+			"hollow-api",
+			map[string]any{
+				"preferred_username": "user",
+				"realm_access":       map[string]any{"roles": []string{"admin"}},
+			},
+			ginauth.ClaimMetadata{Subject: "test-user", User: "user", Roles: []string{"admin"}},
+			"",
+		},
+		{
+			"keycloak authorized party without opting in",
+			ginjwt.ProviderPresetKeycloak,
+			"https://keycloak.example.com/realms/hollow",
+			"hollow-api",
+			nil,
+			"https://keycloak.example.com/realms/hollow",
+			"account",
+			map[string]any{"azp": "hollow-api"},
+			ginauth.ClaimMetadata{},
+			"invalid audience claim",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.testName, func(t *testing.T) {
+			cfg := ginjwt.AuthConfig{
+				Enabled:        true,
+				Audience:       tt.audience,
+				Issuer:         tt.issuer,
+				JWKS:           ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+				ProviderPreset: tt.preset,
+				HostedDomains:  tt.hostedDomains,
+			}
+
+			authMW, err := ginjwt.NewAuthMiddleware(cfg)
+			require.NoError(t, err)
+
+			claims := jwt.Claims{
+				Subject:   "test-user",
+				Issuer:    tt.tokenIssuer,
+				NotBefore: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+				Audience:  jwt.Audience{tt.tokenAudience},
+			}
+
+			signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+			rawToken, err := jwt.Signed(signer).Claims(claims).Claims(tt.claims).CompactSerialize()
+			require.NoError(t, err)
+
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "http://test/", nil)
+			c.Request.Header.Set("Authorization", fmt.Sprintf("bearer %s", rawToken))
+
+			cm, err := authMW.VerifyToken(c)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+
+			tt.want.Claims = tokenClaims(t, rawToken)
+			assert.Equal(t, tt.want, cm)
+		})
+	}
+}
+
+func TestAcceptAuthorizedParty(t *testing.T) {
+	testCases := []struct {
+		testName string
+		accept   bool
+		azp      string
+		wantErr  string
+	}{
+		{"audience from the authorized party", true, "hollow-api", ""},
+		{"other authorized party", true, "other-client", "invalid audience claim"},
+		{"not accepted", false, "hollow-api", "invalid audience claim"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.testName, func(t *testing.T) {
+			authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+				Enabled:               true,
+				Audience:              "hollow-api",
+				Issuer:                "https://keycloak.example.com/realms/hollow",
+				JWKS:                  ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+				ProviderPreset:        ginjwt.ProviderPresetKeycloak,
+				AcceptAuthorizedParty: tt.accept,
+			})
+			require.NoError(t, err)
+
+			signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+			token := ginjwt.TestHelperGetToken(signer, jwt.Claims{
+				Subject:  "test-user",
+				Issuer:   "https://keycloak.example.com/realms/hollow",
+				Audience: jwt.Audience{"account"},
+				Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+			}, "azp", tt.azp)
+
+			_, err = authMW.VerifyToken(tokenContext(token))
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestProviderPresetUnknown(t *testing.T) {
+	_, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{ProviderPreset: "okta"})
+	assert.ErrorIs(t, err, ginjwt.ErrInvalidAuthConfig)
+}
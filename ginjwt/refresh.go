@@ -0,0 +1,89 @@
+package ginjwt
+
+import (
+	"context"
+	"math/rand"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// startJWKSRefresher refreshes the JWKS every JWKSRefreshInterval in the background until Close
+// is called, static JWKS and disabled middlewares aren't refreshed.
+func (m *Middleware) startJWKSRefresher() {
+	if !m.config.Enabled || m.config.JWKSRefreshInterval <= 0 || len(m.config.JWKS.Keys) > 0 {
+		return
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	m.stopRefresher = cancel
+	m.refresherDone = make(chan struct{})
+
+	go m.runJWKSRefresher(ctx)
+}
+
+func (m *Middleware) runJWKSRefresher(ctx context.Context) {
+	defer close(m.refresherDone)
+
+	for {
+		timer := time.NewTimer(m.jwksRefreshWait())
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		// serialized with the refreshes for unknown keys
+		select {
+		case <-ctx.Done():
+			return
+		case m.refreshing <- struct{}{}:
+		}
+
+		err := m.refreshJWKSContext(ctx)
+
+		<-m.refreshing
+
+		switch {
+		case ctx.Err() != nil:
+			return
+		case err != nil:
+			// the cached keys are kept until the next refresh
+			m.logger.Warn("unable to refresh JWKS", m.jwksSource(), zap.Error(err))
+		default:
+			m.logger.Debug("refreshed JWKS", m.jwksSource())
+		}
+	}
+}
+
+// jwksRefreshWait returns the JWKSRefreshInterval plus a random duration up to the JWKSRefreshJitter.
+func (m *Middleware) jwksRefreshWait() time.Duration {
+	wait := m.config.JWKSRefreshInterval
+
+	if m.config.JWKSRefreshJitter > 0 {
+		wait += time.Duration(rand.Int63n(int64(m.config.JWKSRefreshJitter))) //nolint:gosec // jitter only
+	}
+
+	return wait
+}
+
+// Close stops refreshing and fetching the JWKS in the background, it waits for an ongoing refresh
+// to be canceled.
+func (m *Middleware) Close() error {
+	m.closeOnce.Do(func() {
+		if m.stopRefresher != nil {
+			m.stopRefresher()
+			<-m.refresherDone
+		}
+
+		if m.stopRetries != nil {
+			m.stopRetries()
+			<-m.retriesDone
+		}
+	})
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package ginjwt_test
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+func TestJWKSBackgroundRefresh(t *testing.T) {
+	srv, rotate, requests := newRotatingJWKSServer(t, ginjwt.TestPrivRSAKey1ID)
+
+	authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:             true,
+		Audience:            "ginjwt.test",
+		Issuer:              "ginjwt.test.issuer",
+		JWKSURI:             srv.URL,
+		JWKSRefreshInterval: 10 * time.Millisecond,
+		JWKSRefreshJitter:   5 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	rotate(ginjwt.TestPrivRSAKey2ID)
+
+	// the rotated key is fetched without any token signed with it
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(requests) >= 3
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, authMW.Close())
+	require.NoError(t, authMW.Close())
+
+	fetched := atomic.LoadInt32(requests)
+
+	newSigner := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey2ID, ginjwt.TestPrivRSAKey2)
+
+	_, err = authMW.VerifyToken(newJWKSTestContext(newSigner))
+	assert.NoError(t, err)
+
+	// no refresh was needed for the rotated key, nor happened once closed
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, fetched, atomic.LoadInt32(requests))
+}
+
+func TestJWKSBackgroundRefreshDisabled(t *testing.T) {
+	srv, _, requests := newRotatingJWKSServer(t, ginjwt.TestPrivRSAKey1ID)
+
+	authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:  true,
+		Audience: "ginjwt.test",
+		Issuer:   "ginjwt.test.issuer",
+		JWKSURI:  srv.URL,
+	})
+	require.NoError(t, err)
+
+	// only fetched at startup
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+	assert.NoError(t, authMW.Close())
+
+	_, err = ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:             true,
+		Audience:            "ginjwt.test",
+		Issuer:              "ginjwt.test.issuer",
+		JWKSURI:             srv.URL,
+		JWKSRefreshInterval: -time.Second,
+	})
+	assert.ErrorIs(t, err, ginjwt.ErrInvalidAuthConfig)
+}
+
+func TestMultiTokenMiddlewareBackgroundRefresh(t *testing.T) {
+	srv1, _, requests1 := newRotatingJWKSServer(t, ginjwt.TestPrivRSAKey1ID)
+	srv2, _, requests2 := newRotatingJWKSServer(t, ginjwt.TestPrivRSAKey2ID)
+
+	mtm, err := ginjwt.NewMultiTokenMiddlewareFromConfigs(
+		ginjwt.AuthConfig{
+			Enabled:             true,
+			Audience:            "ginjwt.test",
+			Issuer:              "ginjwt.test.issuer",
+			JWKSURI:             srv1.URL,
+			JWKSRefreshInterval: 10 * time.Millisecond,
+		},
+		ginjwt.AuthConfig{
+			Enabled:  true,
+			Audience: "ginjwt.test",
+			Issuer:   "ginjwt.test.issuer2",
+			JWKSURI:  srv2.URL,
+		},
+	)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return atomic.LoadInt32(requests1) >= 3
+	}, time.Second, 5*time.Millisecond)
+
+	require.NoError(t, mtm.Close())
+
+	fetched := atomic.LoadInt32(requests1)
+
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, fetched, atomic.LoadInt32(requests1))
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests2))
+}
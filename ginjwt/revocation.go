@@ -0,0 +1,233 @@
+package ginjwt
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nats-io/nats.go"
+	"go.uber.org/zap"
+
+	"go.hollow.sh/toolbox/ginauth"
+)
+
+// RevocationStore holds the IDs of the revoked tokens, the jti claim, so compromised tokens are
+// rejected before they expire.
+type RevocationStore interface {
+	// IsRevoked returns true when the token with the ID was revoked.
+	IsRevoked(ctx context.Context, tokenID string) (bool, error)
+	// Revoke denies the token with the ID until it expires, it can be forgotten past expiresAt.
+	Revoke(ctx context.Context, tokenID string, expiresAt time.Time) error
+}
+
+// CheckRevoked rejects the token when its ID is in the RevocationStore, tokens without an ID can't be revoked.
+// This implements the ginauth.RevocationChecker interface
+func (m *Middleware) CheckRevoked(c *gin.Context, tokenID string) error {
+	if m.config.RevocationStore == nil || tokenID == "" {
+		return nil
+	}
+
+	revoked, err := m.config.RevocationStore.IsRevoked(c.Request.Context(), tokenID)
+	if err != nil {
+		if m.config.RevocationFailOpen {
+			m.logger.Warn("accepting token as the revocation store is unavailable", zap.String("jti", tokenID), zap.Error(err))
+			return nil
+		}
+
+		return ginauth.NewAuthenticationErrorFrom(fmt.Errorf("%w: %s", ErrRevocationCheck, err))
+	}
+
+	if revoked {
+		return ginauth.NewAuthenticationErrorFrom(ErrTokenRevoked)
+	}
+
+	return nil
+}
+
+// MemoryRevocationStore is a RevocationStore held in memory, for a single instance or tests.
+type MemoryRevocationStore struct {
+	mu      sync.RWMutex
+	revoked map[string]time.Time
+}
+
+// NewMemoryRevocationStore returns an empty MemoryRevocationStore.
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{revoked: map[string]time.Time{}}
+}
+
+// IsRevoked returns true when the token with the ID was revoked and hasn't expired yet.
+func (s *MemoryRevocationStore) IsRevoked(_ context.Context, tokenID string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return revokedAt(s.revoked, tokenID), nil
+}
+
+// Revoke denies the token with the ID until it expires, expired tokens are dropped.
+func (s *MemoryRevocationStore) Revoke(_ context.Context, tokenID string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	for id, exp := range s.revoked {
+		if !exp.IsZero() && now.After(exp) {
+			delete(s.revoked, id)
+		}
+	}
+
+	s.revoked[tokenID] = expiresAt
+
+	return nil
+}
+
+// revokedAt returns true when the token ID is denied, tokens revoked without an expiry are denied forever.
+func revokedAt(revoked map[string]time.Time, tokenID string) bool {
+	exp, ok := revoked[tokenID]
+
+	return ok && (exp.IsZero() || time.Now().Before(exp))
+}
+
+// NatsKVRevocationStore is a RevocationStore shared by the instances of a service through a NATS KV
+// bucket, e.g. created with kv.CreateOrBindKVBucket of the events package. The revoked tokens are
+// watched so checks don't query the bucket, the bucket TTL, set with kv.WithTTL, should be the
+// maximum lifetime of the tokens so revocations are purged once the tokens expired.
+type NatsKVRevocationStore struct {
+	bucket  nats.KeyValue
+	watcher nats.KeyWatcher
+	logger  *zap.Logger
+
+	mu      sync.RWMutex
+	revoked map[string]time.Time
+	ready   chan struct{}
+}
+
+// revocationKeyPrefix is the prefix of the keys of the revoked tokens in the bucket.
+const revocationKeyPrefix = "jti."
+
+// NewNatsKVRevocationStore returns a NatsKVRevocationStore watching the bucket until Stop is called.
+func NewNatsKVRevocationStore(bucket nats.KeyValue, logger *zap.Logger) (*NatsKVRevocationStore, error) {
+	if bucket == nil {
+		return nil, fmt.Errorf("%w: the NATS KV bucket can't be nil", ErrInvalidAuthConfig)
+	}
+
+	if logger == nil {
+		logger = zap.NewNop()
+	}
+
+	watcher, err := bucket.Watch(revocationKeyPrefix + ">")
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrRevocationCheck, err)
+	}
+
+	s := &NatsKVRevocationStore{
+		bucket:  bucket,
+		watcher: watcher,
+		logger:  logger,
+		revoked: map[string]time.Time{},
+		ready:   make(chan struct{}),
+	}
+
+	go s.watch()
+
+	return s, nil
+}
+
+// revocationKey encodes the token ID into a valid KV key.
+func revocationKey(tokenID string) string {
+	return revocationKeyPrefix + base64.RawURLEncoding.EncodeToString([]byte(tokenID))
+}
+
+// IsRevoked returns true when the token with the ID was revoked and hasn't expired yet, waiting for
+// the revocations in the bucket to be read first.
+func (s *NatsKVRevocationStore) IsRevoked(ctx context.Context, tokenID string) (bool, error) {
+	select {
+	case <-s.ready:
+	case <-ctx.Done():
+		return false, ctx.Err()
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return revokedAt(s.revoked, tokenID), nil
+}
+
+// Revoke puts the token ID in the bucket, it is denied by all the instances watching it.
+func (s *NatsKVRevocationStore) Revoke(_ context.Context, tokenID string, expiresAt time.Time) error {
+	var value []byte
+	if !expiresAt.IsZero() {
+		value = []byte(expiresAt.UTC().Format(time.RFC3339))
+	}
+
+	if _, err := s.bucket.Put(revocationKey(tokenID), value); err != nil {
+		return err
+	}
+
+	// denied right away, without waiting for the watcher
+	s.mu.Lock()
+	s.revoked[tokenID] = expiresAt
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Stop stops watching the bucket, revocations made by other instances aren't seen anymore.
+func (s *NatsKVRevocationStore) Stop() error {
+	return s.watcher.Stop()
+}
+
+func (s *NatsKVRevocationStore) watch() {
+	initial := true
+
+	for entry := range s.watcher.Updates() {
+		// a nil entry marks the end of the values initially in the bucket
+		if entry == nil {
+			if initial {
+				initial = false
+
+				close(s.ready)
+			}
+
+			continue
+		}
+
+		s.apply(entry)
+	}
+
+	// stopped before the initial values were read, IsRevoked checks the revocations read so far
+	if initial {
+		close(s.ready)
+	}
+}
+
+// apply updates the revoked tokens with the entry.
+func (s *NatsKVRevocationStore) apply(entry nats.KeyValueEntry) {
+	id, err := base64.RawURLEncoding.DecodeString(entry.Key()[len(revocationKeyPrefix):])
+	if err != nil {
+		s.logger.Warn("ignoring invalid revocation key", zap.String("key", entry.Key()), zap.Error(err))
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry.Operation() != nats.KeyValuePut {
+		delete(s.revoked, string(id))
+		return
+	}
+
+	var expiresAt time.Time
+
+	if len(entry.Value()) > 0 {
+		if expiresAt, err = time.Parse(time.RFC3339, string(entry.Value())); err != nil {
+			// denied until the bucket TTL purges it rather than accepted
+			s.logger.Warn("revoking token with an invalid expiry forever", zap.String("key", entry.Key()), zap.Error(err))
+		}
+	}
+
+	s.revoked[string(id)] = expiresAt
+}
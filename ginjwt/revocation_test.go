@@ -0,0 +1,210 @@
+package ginjwt_test
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	srvtest "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"go.hollow.sh/toolbox/ginauth"
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+func revocationTestContext(tokenID string) *gin.Context {
+	signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+
+	return tokenContext(ginjwt.TestHelperGetToken(signer, jwt.Claims{
+		ID:       tokenID,
+		Subject:  "test-user",
+		Issuer:   "ginjwt.test.issuer",
+		Audience: jwt.Audience{"ginjwt.test"},
+		Expiry:   jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}, "scope", "read"))
+}
+
+func revocationTestConfig(store ginjwt.RevocationStore) ginjwt.AuthConfig {
+	return ginjwt.AuthConfig{
+		Enabled:         true,
+		Audience:        "ginjwt.test",
+		Issuer:          "ginjwt.test.issuer",
+		JWKS:            ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+		RevocationStore: store,
+	}
+}
+
+type failingRevocationStore struct{}
+
+func (failingRevocationStore) IsRevoked(context.Context, string) (bool, error) {
+	return false, errors.New("store unavailable")
+}
+
+func (failingRevocationStore) Revoke(context.Context, string, time.Time) error {
+	return errors.New("store unavailable")
+}
+
+func TestRevokedTokens(t *testing.T) {
+	store := ginjwt.NewMemoryRevocationStore()
+
+	authMW, err := ginjwt.NewAuthMiddleware(revocationTestConfig(store))
+	require.NoError(t, err)
+
+	require.NoError(t, store.Revoke(context.Background(), "revoked-id", time.Now().Add(time.Hour)))
+	require.NoError(t, store.Revoke(context.Background(), "expired-revocation", time.Now().Add(-time.Second)))
+
+	_, err = authMW.VerifyToken(revocationTestContext("revoked-id"))
+	assert.ErrorContains(t, err, ginjwt.ErrTokenRevoked.Error())
+
+	_, err = authMW.VerifyToken(revocationTestContext("expired-revocation"))
+	assert.NoError(t, err)
+
+	_, err = authMW.VerifyToken(revocationTestContext("other-id"))
+	assert.NoError(t, err)
+
+	// tokens without an ID can't be revoked
+	_, err = authMW.VerifyToken(revocationTestContext(""))
+	assert.NoError(t, err)
+}
+
+func TestRevocationStoreUnavailable(t *testing.T) {
+	authMW, err := ginjwt.NewAuthMiddleware(revocationTestConfig(failingRevocationStore{}))
+	require.NoError(t, err)
+
+	_, err = authMW.VerifyToken(revocationTestContext("some-id"))
+	assert.ErrorContains(t, err, ginjwt.ErrRevocationCheck.Error())
+
+	cfg := revocationTestConfig(failingRevocationStore{})
+	cfg.RevocationFailOpen = true
+
+	authMW, err = ginjwt.NewAuthMiddleware(cfg)
+	require.NoError(t, err)
+
+	_, err = authMW.VerifyToken(revocationTestContext("some-id"))
+	assert.NoError(t, err)
+}
+
+func TestRevokedTokenCachedDecision(t *testing.T) {
+	store := ginjwt.NewMemoryRevocationStore()
+
+	cfg := revocationTestConfig(store)
+	cfg.DecisionCacheTTL = time.Minute
+
+	authMW, err := ginjwt.NewAuthMiddleware(cfg)
+	require.NoError(t, err)
+
+	c := revocationTestContext("cached-id")
+
+	_, err = authMW.VerifyTokenWithScopes(c, []string{"read"})
+	require.NoError(t, err)
+
+	require.NoError(t, store.Revoke(context.Background(), "cached-id", time.Now().Add(time.Hour)))
+
+	_, err = authMW.VerifyTokenWithScopes(c, []string{"read"})
+	assert.ErrorContains(t, err, ginjwt.ErrTokenRevoked.Error())
+	assert.Equal(t, uint64(1), authMW.DecisionCacheStats().Hits)
+}
+
+func TestNatsKVRevocationStore(t *testing.T) {
+	opts := srvtest.DefaultTestOptions
+	opts.Port = -1
+	opts.JetStream = true
+	opts.StoreDir = t.TempDir()
+
+	srv := srvtest.RunServer(&opts)
+	defer srv.Shutdown()
+
+	nc, err := nats.Connect(srv.ClientURL())
+	require.NoError(t, err)
+
+	defer nc.Close()
+
+	js, err := nc.JetStream()
+	require.NoError(t, err)
+
+	bucket, err := js.CreateKeyValue(&nats.KeyValueConfig{Bucket: "revocations"})
+	require.NoError(t, err)
+
+	// revoked before the store was created
+	_, err = bucket.Put("jti.b2xkLWlk", nil)
+	require.NoError(t, err)
+
+	_, err = ginjwt.NewNatsKVRevocationStore(nil, nil)
+	assert.ErrorIs(t, err, ginjwt.ErrInvalidAuthConfig)
+
+	store, err := ginjwt.NewNatsKVRevocationStore(bucket, nil)
+	require.NoError(t, err)
+
+	defer store.Stop()
+
+	other, err := ginjwt.NewNatsKVRevocationStore(bucket, nil)
+	require.NoError(t, err)
+
+	defer other.Stop()
+
+	authMW, err := ginjwt.NewAuthMiddleware(revocationTestConfig(store))
+	require.NoError(t, err)
+
+	_, err = authMW.VerifyToken(revocationTestContext("old-id"))
+	assert.ErrorContains(t, err, ginjwt.ErrTokenRevoked.Error())
+
+	_, err = authMW.VerifyToken(revocationTestContext("compromised/id"))
+	assert.NoError(t, err)
+
+	// revoked by another instance
+	require.NoError(t, other.Revoke(context.Background(), "compromised/id", time.Now().Add(time.Hour)))
+
+	require.Eventually(t, func() bool {
+		_, err := authMW.VerifyToken(revocationTestContext("compromised/id"))
+		return err != nil
+	}, time.Second, 10*time.Millisecond)
+
+	_, err = authMW.VerifyToken(revocationTestContext("compromised/id"))
+	assert.ErrorContains(t, err, ginjwt.ErrTokenRevoked.Error())
+
+	// revocations removed from the bucket are lifted
+	require.NoError(t, bucket.Delete("jti.b2xkLWlk"))
+
+	require.Eventually(t, func() bool {
+		revoked, err := store.IsRevoked(context.Background(), "old-id")
+		return err == nil && !revoked
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestRevokedTokenSession(t *testing.T) {
+	store := ginjwt.NewMemoryRevocationStore()
+
+	authMW, err := ginjwt.NewAuthMiddleware(revocationTestConfig(store))
+	require.NoError(t, err)
+
+	sm, err := ginauth.NewSessionMiddleware(authMW, ginauth.SessionConfig{Key: []byte("0123456789abcdef")})
+	require.NoError(t, err)
+
+	c := revocationTestContext("session-id")
+
+	_, err = sm.VerifyTokenWithScopes(c, []string{"read"})
+	require.NoError(t, err)
+
+	cookies := c.Writer.Header().Values("Set-Cookie")
+	require.Len(t, cookies, 1)
+
+	// the session alone is accepted until its token is revoked
+	session, _ := gin.CreateTestContext(httptest.NewRecorder())
+	session.Request = httptest.NewRequest("GET", "http://test/", nil)
+	session.Request.Header.Set("Cookie", cookies[0])
+
+	_, err = sm.VerifyTokenWithScopes(session, []string{"read"})
+	require.NoError(t, err)
+
+	require.NoError(t, store.Revoke(context.Background(), "session-id", time.Now().Add(time.Hour)))
+
+	_, err = sm.VerifyTokenWithScopes(session, []string{"read"})
+	assert.ErrorContains(t, err, ginjwt.ErrTokenRevoked.Error())
+}
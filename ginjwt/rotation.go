@@ -0,0 +1,106 @@
+package ginjwt
+
+import (
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// RetiredKeyStats holds the metrics of the keys retired from the JWKS, to be exported to the metrics system
+type RetiredKeyStats struct {
+	// Retired is the number of keys removed from the JWKS which are still accepted
+	Retired int
+	// Used counts the tokens whose signing key was only found among the retired keys
+	Used uint64
+}
+
+// retiredKey is a key removed from the JWKS, accepted until it expires
+type retiredKey struct {
+	key       jose.JSONWebKey
+	expiresAt time.Time
+	// used counts the tokens signed with the key since it was retired
+	used uint64
+}
+
+// retireKeys keeps the keys of the old JWKS missing from the new one for the RetiredKeyGracePeriod,
+// drops the retired keys back in the new JWKS and those past their grace period.
+// It is called with the jwksMu held.
+func (m *Middleware) retireKeys(old, updated jose.JSONWebKeySet) {
+	grace := m.config.RetiredKeyGracePeriod
+	if grace <= 0 {
+		return
+	}
+
+	if m.retiredKeys == nil {
+		m.retiredKeys = map[string]*retiredKey{}
+	}
+
+	now := time.Now()
+
+	for kid, rk := range m.retiredKeys {
+		switch {
+		case len(updated.Key(kid)) > 0:
+			delete(m.retiredKeys, kid)
+		case now.After(rk.expiresAt):
+			delete(m.retiredKeys, kid)
+
+			m.logger.Info("retired JWKS key grace period ended, tokens signed with it are rejected",
+				zap.String("kid", kid), zap.Uint64("used", atomic.LoadUint64(&rk.used)))
+		}
+	}
+
+	for _, key := range old.Keys {
+		// keys without an ID can't be told apart once retired
+		if key.KeyID == "" || len(updated.Key(key.KeyID)) > 0 {
+			continue
+		}
+
+		if _, ok := m.retiredKeys[key.KeyID]; ok {
+			continue
+		}
+
+		m.retiredKeys[key.KeyID] = &retiredKey{key: key, expiresAt: now.Add(grace)}
+
+		m.logger.Info("key removed from the JWKS, tokens signed with it are accepted for the grace period",
+			zap.String("kid", key.KeyID), zap.Duration("grace_period", grace))
+	}
+}
+
+// retiredKeysFor returns the retired key with the ID while it is in its grace period.
+// It is called with the jwksMu held.
+func (m *Middleware) retiredKeysFor(kid string) []jose.JSONWebKey {
+	rk, ok := m.retiredKeys[kid]
+	if !ok || time.Now().After(rk.expiresAt) {
+		return nil
+	}
+
+	if atomic.AddUint64(&rk.used, 1) == 1 {
+		m.logger.Warn("token signed with a retired JWKS key", zap.String("kid", kid), zap.Time("expires_at", rk.expiresAt))
+	}
+
+	atomic.AddUint64(&m.retiredKeyUses, 1)
+
+	return []jose.JSONWebKey{rk.key}
+}
+
+// RetiredKeyStats returns the metrics of the retired keys, all zero when RetiredKeyGracePeriod isn't set.
+func (m *Middleware) RetiredKeyStats() RetiredKeyStats {
+	m.jwksMu.RLock()
+	defer m.jwksMu.RUnlock()
+
+	now := time.Now()
+	retired := 0
+
+	for _, rk := range m.retiredKeys {
+		if !now.After(rk.expiresAt) {
+			retired++
+		}
+	}
+
+	return RetiredKeyStats{
+		Retired: retired,
+		Used:    atomic.LoadUint64(&m.retiredKeyUses),
+	}
+}
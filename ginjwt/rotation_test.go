@@ -0,0 +1,93 @@
+package ginjwt_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+// rotatingKeySet is a KeySetProvider whose keys are replaced by the tests
+type rotatingKeySet struct {
+	mu   sync.Mutex
+	jwks jose.JSONWebKeySet
+}
+
+func (r *rotatingKeySet) KeySet(_ context.Context) (jose.JSONWebKeySet, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.jwks, nil
+}
+
+func (r *rotatingKeySet) rotate(keyIDs ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.jwks = ginjwt.TestHelperJoseJWKSProvider(keyIDs...)
+}
+
+func TestRetiredKeyGracePeriod(t *testing.T) {
+	keys := &rotatingKeySet{}
+	keys.rotate(ginjwt.TestPrivRSAKey1ID)
+
+	mw, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:               true,
+		Audience:              "ginjwt.test",
+		Issuer:                "ginjwt.test.issuer",
+		KeySetProvider:        keys,
+		RetiredKeyGracePeriod: 500 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.GET("/", mw.AuthRequired(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	claims := jwt.Claims{
+		Subject:   "test-user",
+		Issuer:    "ginjwt.test.issuer",
+		NotBefore: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+		Audience:  jwt.Audience{"ginjwt.test"},
+	}
+
+	oldToken := ginjwt.TestHelperGetToken(
+		ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1), claims, "scope", "read")
+	newToken := ginjwt.TestHelperGetToken(
+		ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey2ID, ginjwt.TestPrivRSAKey2), claims, "scope", "read")
+
+	status := func(token string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "bearer "+token)
+		r.ServeHTTP(w, req)
+
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, status(oldToken))
+
+	// the first token signed with the new key refreshes the keys, retiring the old one
+	keys.rotate(ginjwt.TestPrivRSAKey2ID)
+
+	assert.Equal(t, http.StatusOK, status(newToken))
+	assert.Equal(t, http.StatusOK, status(oldToken))
+	assert.Equal(t, ginjwt.RetiredKeyStats{Retired: 1, Used: 1}, mw.RetiredKeyStats())
+
+	assert.Eventually(t, func() bool {
+		return status(oldToken) == http.StatusUnauthorized
+	}, 2*time.Second, 50*time.Millisecond)
+
+	assert.Equal(t, 0, mw.RetiredKeyStats().Retired)
+}
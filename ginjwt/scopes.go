@@ -1,42 +1,32 @@
 package ginjwt
 
-import "fmt"
+import "go.hollow.sh/toolbox/ginauth/scopes"
 
 // CreateScopes will return a list of scopes allowed for creating the items that are passed in
 func CreateScopes(items ...string) []string {
-	s := []string{"write", "create"}
-	for _, i := range items {
-		s = append(s, fmt.Sprintf("create:%s", i))
-	}
-
-	return s
+	return actionScopes(scopes.ActionCreate, []string{scopes.ActionWrite, scopes.ActionCreate}, items)
 }
 
 // ReadScopes will return a list of scopes allowed for creating the items that are passed in.
 func ReadScopes(items ...string) []string {
-	s := []string{"read"}
-	for _, i := range items {
-		s = append(s, fmt.Sprintf("read:%s", i))
-	}
-
-	return s
+	return actionScopes(scopes.ActionRead, []string{scopes.ActionRead}, items)
 }
 
 // UpdateScopes will return a list of scopes allowed for updating the items that are passed in.
 func UpdateScopes(items ...string) []string {
-	s := []string{"write", "update"}
-	for _, i := range items {
-		s = append(s, fmt.Sprintf("update:%s", i))
-	}
-
-	return s
+	return actionScopes(scopes.ActionUpdate, []string{scopes.ActionWrite, scopes.ActionUpdate}, items)
 }
 
 // DeleteScopes will return a list of scopes allowed for deleting the items that are passed in.
 func DeleteScopes(items ...string) []string {
-	s := []string{"write", "delete"}
+	return actionScopes(scopes.ActionDelete, []string{scopes.ActionWrite, scopes.ActionDelete}, items)
+}
+
+// actionScopes returns the broad scopes followed by the scopes for the action on each of the items.
+func actionScopes(action string, broad, items []string) []string {
+	s := broad
 	for _, i := range items {
-		s = append(s, fmt.Sprintf("delete:%s", i))
+		s = append(s, scopes.Format(action, i))
 	}
 
 	return s
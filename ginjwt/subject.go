@@ -0,0 +1,111 @@
+package ginjwt
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"golang.org/x/net/context"
+)
+
+// DefaultSubjectCacheTTL is how long a resolved subject is cached when no TTL is configured
+const DefaultSubjectCacheTTL = 5 * time.Minute
+
+// SubjectResolver maps the subject of a validated token to the canonical identity of the
+// user, e.g. when the IdP issues pairwise subjects that differ for every client.
+// The claims hold the raw JSON of all the token claims.
+type SubjectResolver interface {
+	ResolveSubject(ctx context.Context, subject string, claims map[string]json.RawMessage) (string, error)
+}
+
+// subjectCache caches the identities returned by a SubjectResolver. Once an entry expires
+// it keeps being served while it's refreshed in the background, so only the first request
+// for a subject waits on the resolver.
+type subjectCache struct {
+	resolver SubjectResolver
+	ttl      time.Duration
+
+	mu      sync.Mutex
+	entries map[string]*subjectCacheEntry
+}
+
+type subjectCacheEntry struct {
+	identity   string
+	expiresAt  time.Time
+	refreshing bool
+}
+
+func newSubjectCache(resolver SubjectResolver, ttl time.Duration) *subjectCache {
+	if ttl <= 0 {
+		ttl = DefaultSubjectCacheTTL
+	}
+
+	return &subjectCache{
+		resolver: resolver,
+		ttl:      ttl,
+		entries:  map[string]*subjectCacheEntry{},
+	}
+}
+
+func (sc *subjectCache) resolve(ctx context.Context, subject string, claims map[string]json.RawMessage) (string, error) {
+	sc.mu.Lock()
+
+	if e, ok := sc.entries[subject]; ok {
+		if time.Now().After(e.expiresAt) && !e.refreshing {
+			e.refreshing = true
+
+			go sc.refresh(subject, claims)
+		}
+
+		sc.mu.Unlock()
+
+		return e.identity, nil
+	}
+
+	sc.mu.Unlock()
+
+	identity, err := sc.resolver.ResolveSubject(ctx, subject, claims)
+	if err != nil {
+		return "", err
+	}
+
+	sc.store(subject, identity)
+
+	return identity, nil
+}
+
+// refresh resolves an expired entry again, on failure the stale identity is kept
+// and the refresh is retried on the next request.
+func (sc *subjectCache) refresh(subject string, claims map[string]json.RawMessage) {
+	identity, err := sc.resolver.ResolveSubject(context.Background(), subject, claims)
+	if err != nil {
+		sc.mu.Lock()
+		if e, ok := sc.entries[subject]; ok {
+			e.refreshing = false
+		}
+		sc.mu.Unlock()
+
+		return
+	}
+
+	sc.store(subject, identity)
+}
+
+func (sc *subjectCache) store(subject, identity string) {
+	now := time.Now()
+
+	sc.mu.Lock()
+	defer sc.mu.Unlock()
+
+	// drop the entries nobody asked for in a while so the cache doesn't grow unbounded.
+	for s, e := range sc.entries {
+		if !e.refreshing && now.Sub(e.expiresAt) > sc.ttl {
+			delete(sc.entries, s)
+		}
+	}
+
+	sc.entries[subject] = &subjectCacheEntry{
+		identity:  identity,
+		expiresAt: now.Add(sc.ttl),
+	}
+}
@@ -0,0 +1,130 @@
+package ginjwt_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/context"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"go.hollow.sh/toolbox/ginauth"
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+type countingResolver struct {
+	mu       sync.Mutex
+	calls    int
+	identity string
+	err      error
+}
+
+func (cr *countingResolver) ResolveSubject(_ context.Context, subject string, claims map[string]json.RawMessage) (string, error) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	cr.calls++
+
+	if cr.err != nil {
+		return "", cr.err
+	}
+
+	if string(claims["scope"]) != `"read"` {
+		return "", errors.New("claims not passed")
+	}
+
+	return fmt.Sprintf("%s:%s", cr.identity, subject), nil
+}
+
+func (cr *countingResolver) set(identity string) {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	cr.identity = identity
+}
+
+func (cr *countingResolver) callCount() int {
+	cr.mu.Lock()
+	defer cr.mu.Unlock()
+
+	return cr.calls
+}
+
+func newSubjectTestContext(t *testing.T) *gin.Context {
+	t.Helper()
+
+	signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+	rawToken := ginjwt.TestHelperGetToken(signer, jwt.Claims{
+		Subject:   "pairwise-sub",
+		Issuer:    "ginjwt.test.issuer",
+		NotBefore: jwt.NewNumericDate(time.Now().Add(-2 * time.Hour)),
+		Audience:  jwt.Audience{"ginjwt.test"},
+	}, "scope", "read")
+
+	c, _ := gin.CreateTestContext(httptest.NewRecorder())
+	c.Request = httptest.NewRequest("GET", "http://test/", nil)
+	c.Request.Header.Set("Authorization", fmt.Sprintf("bearer %s", rawToken))
+
+	return c
+}
+
+func TestVerifyTokenSubjectResolver(t *testing.T) {
+	resolver := &countingResolver{identity: "user-1"}
+
+	authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:         true,
+		Audience:        "ginjwt.test",
+		Issuer:          "ginjwt.test.issuer",
+		JWKS:            ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+		SubjectResolver: resolver,
+		SubjectCacheTTL: 50 * time.Millisecond,
+	})
+	require.NoError(t, err)
+
+	cm, err := authMW.VerifyToken(newSubjectTestContext(t))
+	require.NoError(t, err)
+	assert.Equal(t, "pairwise-sub", cm.Subject)
+	assert.Equal(t, "user-1:pairwise-sub", cm.User)
+
+	// cached
+	cm, err = authMW.VerifyToken(newSubjectTestContext(t))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1:pairwise-sub", cm.User)
+	assert.Equal(t, 1, resolver.callCount())
+
+	// once expired, the cached identity is served while it's refreshed
+	resolver.set("user-2")
+	time.Sleep(60 * time.Millisecond)
+
+	cm, err = authMW.VerifyToken(newSubjectTestContext(t))
+	require.NoError(t, err)
+	assert.Equal(t, "user-1:pairwise-sub", cm.User)
+
+	assert.Eventually(t, func() bool {
+		cm, err := authMW.VerifyToken(newSubjectTestContext(t))
+		return err == nil && cm.User == "user-2:pairwise-sub"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestVerifyTokenSubjectResolverError(t *testing.T) {
+	authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:         true,
+		Audience:        "ginjwt.test",
+		Issuer:          "ginjwt.test.issuer",
+		JWKS:            ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+		SubjectResolver: &countingResolver{err: errors.New("directory unavailable")},
+	})
+	require.NoError(t, err)
+
+	_, err = authMW.VerifyToken(newSubjectTestContext(t))
+	assert.ErrorIs(t, err, ginauth.ErrAuthentication)
+	assert.ErrorContains(t, err, ginjwt.ErrSubjectResolution.Error())
+}
@@ -0,0 +1,86 @@
+package ginjwt_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+func TestTenantClaim(t *testing.T) {
+	mw, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+		Enabled:     true,
+		Audience:    "ginjwt.test",
+		Issuer:      "ginjwt.test.issuer",
+		JWKS:        ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+		TenantClaim: "org.id",
+	})
+	require.NoError(t, err)
+
+	r := gin.New()
+	r.GET("/tenant", mw.AuthRequired(), mw.RequireTenant(), func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"tenant": ginjwt.GetTenant(c)})
+	})
+
+	signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+	claims := jwt.Claims{
+		Subject:   "test-user",
+		Issuer:    "ginjwt.test.issuer",
+		NotBefore: jwt.NewNumericDate(time.Now().Add(-time.Hour)),
+		Audience:  jwt.Audience{"ginjwt.test"},
+	}
+
+	testCases := []struct {
+		testName     string
+		token        string
+		responseCode int
+		responseBody string
+	}{
+		{
+			"tenant set from the nested claim",
+			ginjwt.TestHelperGetToken(signer, claims, "org", map[string]string{"id": "acme"}),
+			http.StatusOK,
+			`{"tenant":"acme"}`,
+		},
+		{
+			"token without tenant",
+			ginjwt.TestHelperGetToken(signer, claims, "scope", "read"),
+			http.StatusForbidden,
+			"missing JWT tenant claim",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.testName, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, "/tenant", nil)
+			req.Header.Set("Authorization", "bearer "+tt.token)
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.responseCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.responseBody)
+		})
+	}
+}
+
+func TestRequireTenantWithoutAuthRequired(t *testing.T) {
+	mw, _ := newOrderTestMiddleware(t)
+
+	r := gin.New()
+	r.GET("/tenant", mw.RequireTenant(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/tenant", nil))
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+}
@@ -4,6 +4,10 @@
 package ginjwt
 
 import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
 	"crypto/rand"
 	"crypto/rsa"
 	"fmt"
@@ -35,7 +39,19 @@ var (
 	TestPrivRSAKey4, _ = rsa.GenerateKey(rand.Reader, testKeySize)
 	// TestPrivRSAKey4ID is the ID of this signing key in tokens
 	TestPrivRSAKey4ID = "testKey4"
-	keyMap            sync.Map
+	// TestPrivECKey1 provides a P-256 EC key used to sign ES256 tokens
+	TestPrivECKey1, _ = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	// TestPrivECKey1ID is the ID of this signing key in tokens
+	TestPrivECKey1ID = "testECKey1"
+	// TestPrivECKey2 provides a P-384 EC key used to sign ES384 tokens
+	TestPrivECKey2, _ = ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	// TestPrivECKey2ID is the ID of this signing key in tokens
+	TestPrivECKey2ID = "testECKey2"
+	// TestPrivEdKey1 provides an Ed25519 key used to sign EdDSA tokens
+	_, TestPrivEdKey1, _ = ed25519.GenerateKey(rand.Reader)
+	// TestPrivEdKey1ID is the ID of this signing key in tokens
+	TestPrivEdKey1ID = "testEdKey1"
+	keyMap           sync.Map
 )
 
 func init() {
@@ -43,6 +59,9 @@ func init() {
 	keyMap.Store(TestPrivRSAKey2ID, TestPrivRSAKey2)
 	keyMap.Store(TestPrivRSAKey3ID, TestPrivRSAKey3)
 	keyMap.Store(TestPrivRSAKey4ID, TestPrivRSAKey4)
+	keyMap.Store(TestPrivECKey1ID, TestPrivECKey1)
+	keyMap.Store(TestPrivECKey2ID, TestPrivECKey2)
+	keyMap.Store(TestPrivEdKey1ID, TestPrivEdKey1)
 }
 
 // TestHelperMustMakeSigner will return a JWT signer from the given key
@@ -65,11 +84,11 @@ func TestHelperJoseJWKSProvider(keyIDs ...string) jose.JSONWebKeySet {
 			panic("Failed finding private key to create test JWKS provider. Fix the test.")
 		}
 
-		privKey := rawKey.(*rsa.PrivateKey)
+		privKey := rawKey.(crypto.Signer)
 
 		jwks[idx] = jose.JSONWebKey{
 			KeyID: keyID,
-			Key:   &privKey.PublicKey,
+			Key:   privKey.Public(),
 		}
 	}
 
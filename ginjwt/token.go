@@ -0,0 +1,94 @@
+package ginjwt
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"go.hollow.sh/toolbox/ginauth"
+)
+
+const (
+	// GRPCGatewayAuthorizationHeader is the header grpc-gateway forwards the authorization metadata in
+	GRPCGatewayAuthorizationHeader = "Grpc-Metadata-Authorization"
+
+	// WebSocketProtocolHeader is the header listing the subprotocols of a websocket upgrade request
+	WebSocketProtocolHeader = "Sec-WebSocket-Protocol"
+
+	// WebSocketTokenSubprotocol is the subprotocol marking the token in the websocket subprotocol list,
+	// the entry following it is the token, e.g. "Sec-WebSocket-Protocol: bearer, <token>"
+	WebSocketTokenSubprotocol = "bearer"
+
+	contextKeyWebSocketSubprotocol = "jwt.websocket_subprotocol"
+)
+
+// tokenFromWebSocketProtocols reads the token following the WebSocketTokenSubprotocol in the subprotocol list.
+// The subprotocol to accept is set in the response and the gin Context, see WebSocketSubprotocol.
+func tokenFromWebSocketProtocols(c *gin.Context) (string, error) {
+	var protocols []string
+
+	for _, h := range c.Request.Header.Values(WebSocketProtocolHeader) {
+		for _, p := range strings.Split(h, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				protocols = append(protocols, p)
+			}
+		}
+	}
+
+	var (
+		rawToken string
+		accepted string
+	)
+
+	for i := 0; i < len(protocols); i++ {
+		if protocols[i] == WebSocketTokenSubprotocol && rawToken == "" && i+1 < len(protocols) {
+			rawToken = protocols[i+1]
+			i++
+
+			continue
+		}
+
+		// the first application subprotocol is accepted
+		if accepted == "" {
+			accepted = protocols[i]
+		}
+	}
+
+	if rawToken == "" {
+		return "", ginauth.NewAuthenticationError("missing token, expected websocket subprotocols: \"" + WebSocketTokenSubprotocol + ", token\"")
+	}
+
+	// the token is never echoed, the marker subprotocol is accepted when there's no other one
+	if accepted == "" {
+		accepted = WebSocketTokenSubprotocol
+	}
+
+	c.Header(WebSocketProtocolHeader, accepted)
+	c.Set(contextKeyWebSocketSubprotocol, accepted)
+
+	return rawToken, nil
+}
+
+// WebSocketSubprotocol returns the subprotocol to accept for a websocket upgrade request
+// authenticated with a token passed in its subprotocols. It has to be passed on to the websocket
+// upgrader, which writes the handshake response itself, e.g. with gorilla/websocket:
+//
+//	upgrader.Upgrade(c.Writer, c.Request, http.Header{"Sec-WebSocket-Protocol": {ginjwt.WebSocketSubprotocol(c)}})
+func WebSocketSubprotocol(c *gin.Context) string {
+	return c.GetString(contextKeyWebSocketSubprotocol)
+}
+
+func isWebSocketUpgrade(c *gin.Context) bool {
+	return strings.EqualFold(c.Request.Header.Get("Upgrade"), "websocket") &&
+		headerContainsToken(c.Request.Header.Get("Connection"), "upgrade")
+}
+
+func headerContainsToken(value, token string) bool {
+	for _, v := range strings.Split(value, ",") {
+		if strings.EqualFold(strings.TrimSpace(v), token) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,136 @@
+package ginjwt_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+func TestAuthRequiredTokenSources(t *testing.T) {
+	signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+	rawToken := ginjwt.TestHelperGetToken(signer, jwt.Claims{
+		Subject:   "test-user",
+		Issuer:    "ginjwt.test.issuer",
+		NotBefore: jwt.NewNumericDate(time.Now().Add(-2 * time.Hour)),
+		Audience:  jwt.Audience{"ginjwt.test"},
+	}, "scope", "read")
+
+	upgrade := map[string]string{"Connection": "keep-alive, Upgrade", "Upgrade": "websocket"}
+
+	withHeaders := func(extra map[string]string, headers ...map[string]string) map[string]string {
+		all := map[string]string{}
+
+		for _, h := range append(headers, extra) {
+			for k, v := range h {
+				all[k] = v
+			}
+		}
+
+		return all
+	}
+
+	testCases := []struct {
+		testName         string
+		websocket        bool
+		headers          map[string]string
+		responseCode     int
+		acceptedProtocol string
+	}{
+		{
+			"grpc-gateway header",
+			false,
+			map[string]string{ginjwt.GRPCGatewayAuthorizationHeader: "Bearer " + rawToken},
+			http.StatusOK,
+			"",
+		},
+		{
+			"websocket subprotocol with application protocol",
+			true,
+			withHeaders(map[string]string{ginjwt.WebSocketProtocolHeader: "graphql-ws, bearer, " + rawToken}, upgrade),
+			http.StatusOK,
+			"graphql-ws",
+		},
+		{
+			"websocket subprotocol token only",
+			true,
+			withHeaders(map[string]string{ginjwt.WebSocketProtocolHeader: "bearer, " + rawToken}, upgrade),
+			http.StatusOK,
+			"bearer",
+		},
+		{
+			"websocket subprotocol missing token",
+			true,
+			withHeaders(map[string]string{ginjwt.WebSocketProtocolHeader: "graphql-ws, bearer"}, upgrade),
+			http.StatusUnauthorized,
+			"",
+		},
+		{
+			"websocket subprotocol token not enabled",
+			false,
+			withHeaders(map[string]string{ginjwt.WebSocketProtocolHeader: "bearer, " + rawToken}, upgrade),
+			http.StatusUnauthorized,
+			"",
+		},
+		{
+			"subprotocol token on a request which isn't an upgrade",
+			true,
+			map[string]string{ginjwt.WebSocketProtocolHeader: "bearer, " + rawToken},
+			http.StatusUnauthorized,
+			"",
+		},
+		{
+			"subprotocol token with an invalid token",
+			true,
+			withHeaders(map[string]string{ginjwt.WebSocketProtocolHeader: "bearer, not-a-token"}, upgrade),
+			http.StatusUnauthorized,
+			"",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.testName, func(t *testing.T) {
+			authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+				Enabled:                   true,
+				Audience:                  "ginjwt.test",
+				Issuer:                    "ginjwt.test.issuer",
+				JWKS:                      ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+				WebSocketSubprotocolToken: tt.websocket,
+			})
+			require.NoError(t, err)
+
+			var accepted string
+
+			r := gin.New()
+			r.GET("/", authMW.AuthRequired(), func(c *gin.Context) {
+				accepted = ginjwt.WebSocketSubprotocol(c)
+				c.JSON(http.StatusOK, c.GetString("jwt.subject"))
+			})
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "http://test/", nil)
+
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.responseCode, w.Code)
+
+			if tt.responseCode == http.StatusOK {
+				assert.Equal(t, tt.acceptedProtocol, accepted)
+				assert.Equal(t, tt.acceptedProtocol, w.Header().Get(ginjwt.WebSocketProtocolHeader))
+				assert.NotContains(t, w.Header().Get(ginjwt.WebSocketProtocolHeader), rawToken)
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package ginjwt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+
+	"go.hollow.sh/toolbox/ginauth"
+)
+
+// ClaimValidatorFunc performs custom checks on the claims of a verified token, such as the
+// org claim matching the tenant of the request URL. Returning an error rejects the request with
+// a 403 Forbidden, unless the error is a *ginauth.AuthError in which case it is returned as is.
+type ClaimValidatorFunc func(c *gin.Context, claims map[string]any) error
+
+// validateClaims runs the configured claim validators
+func (m *Middleware) validateClaims(c *gin.Context, raw map[string]json.RawMessage) error {
+	if len(m.config.ClaimValidators) == 0 {
+		return nil
+	}
+
+	claims := make(map[string]any, len(raw))
+
+	for name, value := range raw {
+		var v any
+		if err := json.Unmarshal(value, &v); err != nil {
+			return ginauth.NewAuthenticationError("unable to parse auth token claims")
+		}
+
+		claims[name] = v
+	}
+
+	for _, validate := range m.config.ClaimValidators {
+		if err := validate(c, claims); err != nil {
+			var authErr *ginauth.AuthError
+			if errors.As(err, &authErr) {
+				return err
+			}
+
+			return ginauth.NewAuthorizationErrorFrom(fmt.Errorf("%w: %s", ErrClaimValidation, err))
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package ginjwt_test
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"go.hollow.sh/toolbox/ginauth"
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+func tenantValidator(c *gin.Context, claims map[string]any) error {
+	if org, _ := claims["org"].(string); org != c.Param("tenant") {
+		return fmt.Errorf("org %q can't access tenant %q", org, c.Param("tenant"))
+	}
+
+	return nil
+}
+
+func TestClaimValidators(t *testing.T) {
+	testCases := []struct {
+		testName     string
+		validators   []ginjwt.ClaimValidatorFunc
+		path         string
+		responseCode int
+		responseBody string
+	}{
+		{
+			"no validators",
+			nil,
+			"/tenants/other",
+			http.StatusOK,
+			"ok",
+		},
+		{
+			"claim matches",
+			[]ginjwt.ClaimValidatorFunc{tenantValidator},
+			"/tenants/acme",
+			http.StatusOK,
+			"ok",
+		},
+		{
+			"claim mismatch",
+			[]ginjwt.ClaimValidatorFunc{tenantValidator},
+			"/tenants/other",
+			http.StatusForbidden,
+			`org \"acme\" can't access tenant \"other\"`,
+		},
+		{
+			"stops at first failure",
+			[]ginjwt.ClaimValidatorFunc{
+				func(*gin.Context, map[string]any) error { return errors.New("first") },
+				func(*gin.Context, map[string]any) error { panic("not reached") },
+			},
+			"/tenants/acme",
+			http.StatusForbidden,
+			"first",
+		},
+		{
+			"auth error kept",
+			[]ginjwt.ClaimValidatorFunc{
+				func(*gin.Context, map[string]any) error { return ginauth.NewAuthenticationError("session revoked") },
+			},
+			"/tenants/acme",
+			http.StatusUnauthorized,
+			"session revoked",
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.testName, func(t *testing.T) {
+			authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+				Enabled:         true,
+				Audience:        "ginjwt.test",
+				Issuer:          "ginjwt.test.issuer",
+				JWKS:            ginjwt.TestHelperJoseJWKSProvider(ginjwt.TestPrivRSAKey1ID),
+				ClaimValidators: tt.validators,
+			})
+			require.NoError(t, err)
+
+			r := gin.New()
+			r.GET("/tenants/:tenant", authMW.AuthRequired(), func(c *gin.Context) {
+				c.JSON(http.StatusOK, "ok")
+			})
+
+			signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+			rawToken := ginjwt.TestHelperGetToken(signer, jwt.Claims{
+				Subject:   "test-user",
+				Issuer:    "ginjwt.test.issuer",
+				NotBefore: jwt.NewNumericDate(time.Now().Add(-2 * time.Hour)),
+				Audience:  jwt.Audience{"ginjwt.test"},
+			}, "org", "acme")
+
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest("GET", "http://test"+tt.path, nil)
+			req.Header.Set("Authorization", fmt.Sprintf("bearer %s", rawToken))
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.responseCode, w.Code)
+			assert.Contains(t, w.Body.String(), tt.responseBody)
+		})
+	}
+}
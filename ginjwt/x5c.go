@@ -0,0 +1,106 @@
+package ginjwt
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"gopkg.in/square/go-jose.v2"
+)
+
+// ErrX5CChain is the error returned when the x5c certificate chain of a key doesn't verify
+var ErrX5CChain = errors.New("invalid x5c certificate chain")
+
+// x5cVerification caches when a verified chain stops being valid
+type x5cVerification struct {
+	validUntil time.Time
+}
+
+// selectKey returns the first of the keys passing the x5c chain validation, keys are
+// returned as is when no X5CRoots are configured.
+func (m *Middleware) selectKey(keys []jose.JSONWebKey) *jose.JSONWebKey {
+	if m.config.X5CRoots == nil {
+		return &keys[0]
+	}
+
+	for i := range keys {
+		err := m.verifyX5C(&keys[i], time.Now())
+		if err == nil {
+			return &keys[i]
+		}
+
+		m.logger.Warn("rejecting JWKS key", zap.String("kid", keys[i].KeyID), zap.Error(err))
+	}
+
+	return nil
+}
+
+// verifyX5C verifies the x5c chain of the key against the X5CRoots and that the
+// leaf certificate holds the key. Successful verifications are cached until the
+// first certificate of the chain expires.
+func (m *Middleware) verifyX5C(key *jose.JSONWebKey, now time.Time) error {
+	if len(key.Certificates) == 0 {
+		return fmt.Errorf("%w: key has no x5c certificates", ErrX5CChain)
+	}
+
+	leaf := key.Certificates[0]
+	fingerprint := sha256.Sum256(leaf.Raw)
+
+	if cached, ok := m.x5cVerified.Load(fingerprint); ok && now.Before(cached.(x5cVerification).validUntil) {
+		return nil
+	}
+
+	if err := leafHoldsKey(leaf, key.Key); err != nil {
+		return err
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range key.Certificates[1:] {
+		intermediates.AddCert(cert)
+	}
+
+	chains, err := leaf.Verify(x509.VerifyOptions{
+		Roots:         m.config.X5CRoots,
+		Intermediates: intermediates,
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrX5CChain, err)
+	}
+
+	validUntil := leaf.NotAfter
+
+	for _, cert := range chains[0] {
+		if cert.NotAfter.Before(validUntil) {
+			validUntil = cert.NotAfter
+		}
+	}
+
+	m.x5cVerified.Store(fingerprint, x5cVerification{validUntil: validUntil})
+
+	return nil
+}
+
+// leafHoldsKey checks the public key of the certificate is the key
+func leafHoldsKey(leaf *x509.Certificate, key interface{}) error {
+	certKey, err := x509.MarshalPKIXPublicKey(leaf.PublicKey)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrX5CChain, err)
+	}
+
+	jwkKey, err := x509.MarshalPKIXPublicKey(key)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrX5CChain, err)
+	}
+
+	if !bytes.Equal(certKey, jwkKey) {
+		return fmt.Errorf("%w: leaf certificate doesn't hold the key", ErrX5CChain)
+	}
+
+	return nil
+}
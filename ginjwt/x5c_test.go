@@ -0,0 +1,141 @@
+package ginjwt_test
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+
+	"go.hollow.sh/toolbox/ginauth"
+	"go.hollow.sh/toolbox/ginjwt"
+)
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "ginjwt test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{cert: cert, key: key}
+}
+
+func (ca *testCA) issue(t *testing.T, pub crypto.PublicKey, notAfter time.Time) *x509.Certificate {
+	t.Helper()
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "ginjwt test signer"},
+		NotBefore:    time.Now().Add(-2 * time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca.cert, pub, ca.key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert
+}
+
+func TestX5CChainValidation(t *testing.T) {
+	ca := newTestCA(t)
+	otherCA := newTestCA(t)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca.cert)
+
+	pub := &ginjwt.TestPrivRSAKey1.PublicKey
+
+	testCases := []struct {
+		testName string
+		certs    []*x509.Certificate
+		wantErr  bool
+	}{
+		{"valid chain", []*x509.Certificate{ca.issue(t, pub, time.Now().Add(time.Hour))}, false},
+		{"no chain", nil, true},
+		{"untrusted root", []*x509.Certificate{otherCA.issue(t, pub, time.Now().Add(time.Hour))}, true},
+		{"expired leaf", []*x509.Certificate{ca.issue(t, pub, time.Now().Add(-time.Hour))}, true},
+		{"leaf for another key", []*x509.Certificate{ca.issue(t, &ginjwt.TestPrivRSAKey2.PublicKey, time.Now().Add(time.Hour))}, true},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.testName, func(t *testing.T) {
+			authMW, err := ginjwt.NewAuthMiddleware(ginjwt.AuthConfig{
+				Enabled:  true,
+				Audience: "ginjwt.test",
+				Issuer:   "ginjwt.test.issuer",
+				JWKS: jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{
+					KeyID:        ginjwt.TestPrivRSAKey1ID,
+					Key:          pub,
+					Algorithm:    string(jose.RS256),
+					Use:          "sig",
+					Certificates: tt.certs,
+				}}},
+				X5CRoots: roots,
+			})
+			require.NoError(t, err)
+
+			signer := ginjwt.TestHelperMustMakeSigner(jose.RS256, ginjwt.TestPrivRSAKey1ID, ginjwt.TestPrivRSAKey1)
+			rawToken := ginjwt.TestHelperGetToken(signer, jwt.Claims{
+				Subject:   "test-user",
+				Issuer:    "ginjwt.test.issuer",
+				NotBefore: jwt.NewNumericDate(time.Now().Add(-2 * time.Hour)),
+				Audience:  jwt.Audience{"ginjwt.test"},
+			}, "scope", "read")
+
+			c, _ := gin.CreateTestContext(httptest.NewRecorder())
+			c.Request = httptest.NewRequest("GET", "http://test/", nil)
+			c.Request.Header.Set("Authorization", fmt.Sprintf("bearer %s", rawToken))
+
+			// verified twice to go through the cached verification
+			for i := 0; i < 2; i++ {
+				_, err = authMW.VerifyToken(c)
+
+				if tt.wantErr {
+					assert.ErrorIs(t, err, ginauth.ErrAuthentication)
+					assert.ErrorContains(t, err, ginauth.ErrInvalidSigningKey.Error())
+
+					continue
+				}
+
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
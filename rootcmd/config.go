@@ -0,0 +1,121 @@
+package rootcmd
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/spf13/viper"
+)
+
+// ErrInvalidConfig is returned when the configuration can't be bound or fails validation
+var ErrInvalidConfig = errors.New("invalid config")
+
+// ConfigViolation is a config value failing a validate struct tag
+type ConfigViolation struct {
+	// Key is the viper key of the value, e.g. db.uri
+	Key string
+	// Tag is the failed validation, e.g. required or min
+	Tag string
+	// Param is the parameter of the validation, e.g. 1 for min=1
+	Param string
+}
+
+// ConfigValidationError holds all the violations found validating a config
+type ConfigValidationError struct {
+	Violations []ConfigViolation
+}
+
+// Error lists the violations
+func (e *ConfigValidationError) Error() string {
+	msgs := make([]string, 0, len(e.Violations))
+
+	for _, v := range e.Violations {
+		msg := fmt.Sprintf("%s: failed %s validation", v.Key, v.Tag)
+		if v.Param != "" {
+			msg = fmt.Sprintf("%s: failed %s=%s validation", v.Key, v.Tag, v.Param)
+		}
+
+		msgs = append(msgs, msg)
+	}
+
+	return fmt.Sprintf("%s: %s", ErrInvalidConfig, strings.Join(msgs, ", "))
+}
+
+// Unwrap allows the error to be checked against ErrInvalidConfig
+func (e *ConfigValidationError) Unwrap() error {
+	return ErrInvalidConfig
+}
+
+// BindConfig unmarshals the viper config into a T and validates it with the go-playground/validator
+// `validate` struct tags. All violations are returned in a *ConfigValidationError, keyed by the
+// viper key of the value, which is the mapstructure tag of the fields or their lower cased name.
+//
+//	type Config struct {
+//		DB struct {
+//			URI string `mapstructure:"uri" validate:"required,uri"`
+//		} `mapstructure:"db"`
+//	}
+//
+//	cfg, err := rootcmd.BindConfig[Config](viper.GetViper())
+func BindConfig[T any](v *viper.Viper) (T, error) {
+	var cfg T
+
+	if v == nil {
+		v = viper.GetViper()
+	}
+
+	if err := v.Unmarshal(&cfg); err != nil {
+		return cfg, fmt.Errorf("%w: %s", ErrInvalidConfig, err)
+	}
+
+	if err := newConfigValidator().Struct(&cfg); err != nil {
+		var verrs validator.ValidationErrors
+		if !errors.As(err, &verrs) {
+			return cfg, fmt.Errorf("%w: %s", ErrInvalidConfig, err)
+		}
+
+		return cfg, configValidationError(verrs)
+	}
+
+	return cfg, nil
+}
+
+func newConfigValidator() *validator.Validate {
+	validate := validator.New()
+
+	// report the keys the values were read from instead of the field names
+	validate.RegisterTagNameFunc(func(fld reflect.StructField) string {
+		name, _, _ := strings.Cut(fld.Tag.Get("mapstructure"), ",")
+
+		switch name {
+		case "-":
+			return "-"
+		case "":
+			return strings.ToLower(fld.Name)
+		default:
+			return name
+		}
+	})
+
+	return validate
+}
+
+func configValidationError(verrs validator.ValidationErrors) error {
+	cerr := &ConfigValidationError{Violations: make([]ConfigViolation, 0, len(verrs))}
+
+	for _, fe := range verrs {
+		// the namespace starts with the name of the config type
+		_, key, _ := strings.Cut(fe.Namespace(), ".")
+
+		cerr.Violations = append(cerr.Violations, ConfigViolation{
+			Key:   key,
+			Tag:   fe.Tag(),
+			Param: fe.Param(),
+		})
+	}
+
+	return cerr
+}
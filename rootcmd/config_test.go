@@ -0,0 +1,71 @@
+package rootcmd_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.hollow.sh/toolbox/rootcmd"
+)
+
+type bindTestConfig struct {
+	DB struct {
+		URI  string `mapstructure:"uri" validate:"required,uri"`
+		Port int    `mapstructure:"port" validate:"min=1"`
+	} `mapstructure:"db"`
+	Workers int `validate:"max=10"`
+}
+
+func newBindTestViper(t *testing.T, yaml string) *viper.Viper {
+	t.Helper()
+
+	v := viper.New()
+	v.SetConfigType("yaml")
+	require.NoError(t, v.ReadConfig(strings.NewReader(yaml)))
+
+	return v
+}
+
+func TestBindConfig(t *testing.T) {
+	v := newBindTestViper(t, "db:\n  uri: postgres://db:5432/hollow\n  port: 5432\nworkers: 4\n")
+
+	cfg, err := rootcmd.BindConfig[bindTestConfig](v)
+	require.NoError(t, err)
+
+	assert.Equal(t, "postgres://db:5432/hollow", cfg.DB.URI)
+	assert.Equal(t, 5432, cfg.DB.Port)
+	assert.Equal(t, 4, cfg.Workers)
+}
+
+func TestBindConfigViolations(t *testing.T) {
+	v := newBindTestViper(t, "db:\n  port: 0\nworkers: 11\n")
+
+	_, err := rootcmd.BindConfig[bindTestConfig](v)
+	require.ErrorIs(t, err, rootcmd.ErrInvalidConfig)
+
+	var verr *rootcmd.ConfigValidationError
+	require.ErrorAs(t, err, &verr)
+
+	// all the violations are reported with the keys of the values
+	assert.ElementsMatch(t, []rootcmd.ConfigViolation{
+		{Key: "db.uri", Tag: "required"},
+		{Key: "db.port", Tag: "min", Param: "1"},
+		{Key: "workers", Tag: "max", Param: "10"},
+	}, verr.Violations)
+
+	assert.Contains(t, err.Error(), "db.port: failed min=1 validation")
+	assert.Contains(t, err.Error(), "db.uri: failed required validation")
+}
+
+func TestBindConfigDecodeError(t *testing.T) {
+	v := newBindTestViper(t, "db:\n  uri: postgres://db/hollow\n  port: not-a-port\n")
+
+	_, err := rootcmd.BindConfig[bindTestConfig](v)
+	require.ErrorIs(t, err, rootcmd.ErrInvalidConfig)
+
+	// the key of the value failing to decode is reported
+	assert.Contains(t, err.Error(), "db.port")
+}
@@ -0,0 +1,110 @@
+package rootcmd
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	yesConfigKey            = "yes"
+	nonInteractiveConfigKey = "non_interactive"
+)
+
+var (
+	// ErrConfirmationDeclined is returned when the user didn't confirm the action
+	ErrConfirmationDeclined = errors.New("confirmation declined")
+
+	// ErrConfirmationRequired is returned when the action needs confirming but no prompt can be
+	// shown, either because --non-interactive is set or the input isn't a terminal
+	ErrConfirmationRequired = errors.New("confirmation required, rerun with --yes to proceed")
+)
+
+// Confirmer prompts the user to confirm destructive actions
+type Confirmer struct {
+	// In is where the answer is read from
+	In io.Reader
+	// Out is where the prompt is written to
+	Out io.Writer
+	// Yes assumes the user confirms every action
+	Yes bool
+	// NonInteractive never prompts, actions not assumed confirmed are refused
+	NonInteractive bool
+}
+
+// Confirm asks the user to confirm the action described by msg, it returns nil once confirmed,
+// ErrConfirmationDeclined when the user answered anything but yes and ErrConfirmationRequired when
+// the user can't be prompted.
+func (c Confirmer) Confirm(msg string) error {
+	if c.Yes {
+		return nil
+	}
+
+	if c.NonInteractive || c.In == nil {
+		return fmt.Errorf("%w: %s", ErrConfirmationRequired, msg)
+	}
+
+	fmt.Fprintf(c.Out, "%s [y/N]: ", msg)
+
+	answer, err := bufio.NewReader(c.In).ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return nil
+	default:
+		return fmt.Errorf("%w: %s", ErrConfirmationDeclined, msg)
+	}
+}
+
+// Confirm asks the user on the terminal to confirm the action described by msg, honouring
+// the --yes and --non-interactive flags. The user isn't prompted when stdin isn't a terminal.
+func Confirm(msg string) error {
+	return DefaultConfirmer().Confirm(msg)
+}
+
+// DefaultConfirmer returns a Confirmer prompting on stderr and reading from stdin, configured
+// from the --yes and --non-interactive flags
+func DefaultConfirmer() Confirmer {
+	return Confirmer{
+		In:             os.Stdin,
+		Out:            os.Stderr,
+		Yes:            viper.GetBool(yesConfigKey),
+		NonInteractive: !IsInteractive(),
+	}
+}
+
+// IsInteractive returns true when the user can be prompted, stdin is a terminal and
+// --non-interactive isn't set
+func IsInteractive() bool {
+	if viper.GetBool(nonInteractiveConfigKey) {
+		return false
+	}
+
+	return isTerminal(os.Stdin)
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return fi.Mode()&os.ModeCharDevice != 0
+}
+
+// InitConfirmFlags adds the --yes and --non-interactive flags used by Confirm
+func (r *Root) InitConfirmFlags() {
+	r.Cmd.PersistentFlags().BoolVarP(&r.Options.Yes, "yes", "y", false, "assume yes to confirmation prompts")
+	r.ViperBindFlag(yesConfigKey, "yes")
+
+	r.Cmd.PersistentFlags().BoolVar(&r.Options.NonInteractive, "non-interactive", false, "never prompt, actions requiring confirmation fail unless --yes is set")
+	r.ViperBindFlag(nonInteractiveConfigKey, "non-interactive")
+}
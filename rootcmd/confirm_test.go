@@ -0,0 +1,127 @@
+package rootcmd_test
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.hollow.sh/toolbox/rootcmd"
+)
+
+func TestConfirmer(t *testing.T) {
+	testCases := []struct {
+		name           string
+		input          string
+		yes            bool
+		nonInteractive bool
+		wantErr        error
+		wantPrompt     bool
+	}{
+		{"yes", "yes\n", false, false, nil, true},
+		{"y uppercase", " Y \n", false, false, nil, true},
+		{"answer without newline", "y", false, false, nil, true},
+		{"no", "n\n", false, false, rootcmd.ErrConfirmationDeclined, true},
+		{"empty answer", "\n", false, false, rootcmd.ErrConfirmationDeclined, true},
+		{"no input", "", false, false, rootcmd.ErrConfirmationDeclined, true},
+		{"assumed yes", "", true, false, nil, false},
+		{"assumed yes non interactive", "", true, true, nil, false},
+		{"non interactive", "y\n", false, true, rootcmd.ErrConfirmationRequired, false},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			c := rootcmd.Confirmer{
+				In:             strings.NewReader(tt.input),
+				Out:            &out,
+				Yes:            tt.yes,
+				NonInteractive: tt.nonInteractive,
+			}
+
+			err := c.Confirm("delete server s1?")
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Contains(t, err.Error(), "delete server s1?")
+			} else {
+				assert.NoError(t, err)
+			}
+
+			if tt.wantPrompt {
+				assert.Equal(t, "delete server s1? [y/N]: ", out.String())
+			} else {
+				assert.Empty(t, out.String())
+			}
+		})
+	}
+
+	t.Run("no input reader", func(t *testing.T) {
+		err := rootcmd.Confirmer{}.Confirm("delete?")
+		assert.ErrorIs(t, err, rootcmd.ErrConfirmationRequired)
+	})
+}
+
+func TestConfirmFlags(t *testing.T) {
+	// stdin isn't a terminal, the user can't be prompted
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+
+	stdin := os.Stdin
+	os.Stdin = r
+
+	t.Cleanup(func() {
+		os.Stdin = stdin
+
+		r.Close()
+		w.Close()
+	})
+
+	testCases := []struct {
+		name    string
+		args    []string
+		wantErr error
+	}{
+		{"no flags", nil, rootcmd.ErrConfirmationRequired},
+		{"yes", []string{"--yes"}, nil},
+		{"yes shorthand", []string{"-y"}, nil},
+		{"non interactive", []string{"--non-interactive"}, rootcmd.ErrConfirmationRequired},
+		{"yes and non interactive", []string{"--yes", "--non-interactive"}, nil},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestHome(t)
+
+			root := rootcmd.NewRootCmd("hollow", "hollow test")
+			root.InitConfirmFlags()
+
+			var interactive bool
+
+			root.Cmd.AddCommand(&cobra.Command{
+				Use: "delete",
+				RunE: func(cmd *cobra.Command, args []string) error {
+					interactive = rootcmd.IsInteractive()
+					return rootcmd.Confirm("delete?")
+				},
+			})
+
+			root.Cmd.SilenceErrors = true
+			root.Cmd.SilenceUsage = true
+			root.Cmd.SetArgs(append([]string{"delete"}, tt.args...))
+
+			err := root.Execute()
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			assert.False(t, interactive)
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package rootcmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+const (
+	contextsConfigKey       = "contexts"
+	currentContextConfigKey = "current-context"
+)
+
+var (
+	// ErrNoContext is returned when no context was selected
+	ErrNoContext = errors.New("no context selected")
+
+	// ErrContextNotFound is returned when the selected context isn't in the config file
+	ErrContextNotFound = errors.New("context not found")
+)
+
+// Profile holds the settings of a named context, which allow switching between
+// hollow environments, for example:
+//
+//	current-context: staging
+//	contexts:
+//	  staging:
+//	    server: https://api.staging.example.com
+//	    oidc-issuer: https://auth.staging.example.com
+//	    oidc-client-id: hollow-cli
+//	  production:
+//	    server: https://api.example.com
+//	    oidc-issuer: https://auth.example.com
+//	    credentials-file: ~/.hollow/production.json
+type Profile struct {
+	Name            string `mapstructure:"-"`
+	Server          string `mapstructure:"server"`
+	OIDCIssuer      string `mapstructure:"oidc-issuer"`
+	OIDCClientID    string `mapstructure:"oidc-client-id"`
+	CredentialsFile string `mapstructure:"credentials-file"`
+	// Settings holds any other app specific setting of the context
+	Settings map[string]interface{} `mapstructure:",remain"`
+}
+
+// Contexts returns the contexts defined in the config file by name
+func (o *Options) Contexts() (map[string]Profile, error) {
+	profiles := map[string]Profile{}
+
+	if err := viper.UnmarshalKey(contextsConfigKey, &profiles); err != nil {
+		return nil, err
+	}
+
+	for name, p := range profiles {
+		p.Name = name
+		profiles[name] = p
+	}
+
+	return profiles, nil
+}
+
+// CurrentContextName returns the name of the selected context, from the --context
+// flag when set, otherwise the current-context of the config file
+func (o *Options) CurrentContextName() string {
+	if o.Context != "" {
+		return o.Context
+	}
+
+	return viper.GetString(currentContextConfigKey)
+}
+
+// ActiveContext returns the profile of the selected context
+func (o *Options) ActiveContext() (Profile, error) {
+	name := o.CurrentContextName()
+	if name == "" {
+		return Profile{}, ErrNoContext
+	}
+
+	profiles, err := o.Contexts()
+	if err != nil {
+		return Profile{}, err
+	}
+
+	p, ok := profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("%w: %s", ErrContextNotFound, name)
+	}
+
+	return p, nil
+}
+
+// UseContext sets the current-context in the config file
+func (o *Options) UseContext(name string) error {
+	profiles, err := o.Contexts()
+	if err != nil {
+		return err
+	}
+
+	if _, ok := profiles[name]; !ok {
+		return fmt.Errorf("%w: %s", ErrContextNotFound, name)
+	}
+
+	// only the config file is written back, not the settings coming from flags or the environment.
+	v := viper.New()
+	v.SetConfigFile(o.configFilePath())
+
+	if err := v.ReadInConfig(); err != nil {
+		return err
+	}
+
+	v.Set(currentContextConfigKey, name)
+	viper.Set(currentContextConfigKey, name)
+
+	return v.WriteConfig()
+}
+
+func (o *Options) configFilePath() string {
+	if used := viper.ConfigFileUsed(); used != "" {
+		return used
+	}
+
+	if o.ConfigFile != "" {
+		return o.ConfigFile
+	}
+
+	file, err := o.findConfigFile()
+	cobra.CheckErr(err)
+
+	if file != "" {
+		return file
+	}
+
+	home, err := homedir.Dir()
+	cobra.CheckErr(err)
+
+	return filepath.Join(home, "."+o.App+".yaml")
+}
+
+// InitContexts adds the --context flag and the context subcommands to list and select contexts
+func (r *Root) InitContexts() {
+	r.Cmd.PersistentFlags().StringVar(&r.Options.Context, "context", "", "name of the context from the config file to use")
+
+	cmd := &cobra.Command{
+		Use:   "context",
+		Short: "Manage the contexts defined in the config file",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "list",
+		Short: "List the contexts, the current one is marked with *",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			profiles, err := r.Options.Contexts()
+			if err != nil {
+				return err
+			}
+
+			names := make([]string, 0, len(profiles))
+			for name := range profiles {
+				names = append(names, name)
+			}
+
+			sort.Strings(names)
+
+			current := r.Options.CurrentContextName()
+
+			for _, name := range names {
+				marker := " "
+				if name == current {
+					marker = "*"
+				}
+
+				cmd.Printf("%s %s\t%s\n", marker, name, profiles[name].Server)
+			}
+
+			return nil
+		},
+	})
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "use NAME",
+		Short: "Set the current context in the config file",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := r.Options.UseContext(args[0]); err != nil {
+				return err
+			}
+
+			cmd.Printf("switched to context %s\n", args[0])
+
+			return nil
+		},
+	})
+
+	r.Cmd.AddCommand(cmd)
+}
@@ -0,0 +1,134 @@
+package rootcmd_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.hollow.sh/toolbox/rootcmd"
+)
+
+const contextsTestConfig = `current-context: staging
+contexts:
+  staging:
+    server: https://api.staging.example.com
+    oidc-issuer: https://auth.staging.example.com
+    oidc-client-id: hollow-cli
+  production:
+    server: https://api.example.com
+    credentials-file: ~/.hollow/production.json
+    region: us-east
+`
+
+// newContextsTestRoot returns a root reading the contexts config from a temp dir
+func newContextsTestRoot(t *testing.T) (*rootcmd.Root, string) {
+	t.Helper()
+
+	setTestHome(t)
+
+	file := filepath.Join(t.TempDir(), "hollow.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(contextsTestConfig), 0o600))
+
+	root := rootcmd.NewRootCmd("hollow", "hollow test")
+	root.InitFlags()
+	root.InitContexts()
+
+	_, err := root.Options.NewLogger()
+	require.NoError(t, err)
+
+	root.Options.ConfigFile = file
+	root.Options.InitConfig()
+
+	return root, file
+}
+
+func TestContexts(t *testing.T) {
+	root, _ := newContextsTestRoot(t)
+
+	profiles, err := root.Options.Contexts()
+	require.NoError(t, err)
+	require.Len(t, profiles, 2)
+
+	assert.Equal(t, rootcmd.Profile{
+		Name:         "staging",
+		Server:       "https://api.staging.example.com",
+		OIDCIssuer:   "https://auth.staging.example.com",
+		OIDCClientID: "hollow-cli",
+	}, profiles["staging"])
+
+	assert.Equal(t, "~/.hollow/production.json", profiles["production"].CredentialsFile)
+	assert.Equal(t, map[string]interface{}{"region": "us-east"}, profiles["production"].Settings)
+}
+
+func TestActiveContext(t *testing.T) {
+	root, _ := newContextsTestRoot(t)
+
+	// the current-context of the config file
+	p, err := root.Options.ActiveContext()
+	require.NoError(t, err)
+	assert.Equal(t, "staging", p.Name)
+
+	// overridden by --context
+	root.Options.Context = "production"
+
+	p, err = root.Options.ActiveContext()
+	require.NoError(t, err)
+	assert.Equal(t, "https://api.example.com", p.Server)
+
+	root.Options.Context = "dev"
+
+	_, err = root.Options.ActiveContext()
+	assert.ErrorIs(t, err, rootcmd.ErrContextNotFound)
+
+	root.Options.Context = ""
+	viper.Set("current-context", "")
+
+	_, err = root.Options.ActiveContext()
+	assert.ErrorIs(t, err, rootcmd.ErrNoContext)
+}
+
+func TestUseContext(t *testing.T) {
+	root, file := newContextsTestRoot(t)
+
+	assert.ErrorIs(t, root.Options.UseContext("dev"), rootcmd.ErrContextNotFound)
+
+	require.NoError(t, root.Options.UseContext("production"))
+	assert.Equal(t, "production", root.Options.CurrentContextName())
+
+	// the config file is written back with the contexts kept
+	v := viper.New()
+	v.SetConfigFile(file)
+	require.NoError(t, v.ReadInConfig())
+
+	assert.Equal(t, "production", v.GetString("current-context"))
+	assert.Equal(t, "https://api.staging.example.com", v.GetString("contexts.staging.server"))
+}
+
+func TestContextCommands(t *testing.T) {
+	root, _ := newContextsTestRoot(t)
+
+	var out bytes.Buffer
+
+	root.Cmd.SetOut(&out)
+	root.Cmd.SetArgs([]string{"context", "list"})
+	require.NoError(t, root.Execute())
+
+	assert.Equal(t, "  production\thttps://api.example.com\n* staging\thttps://api.staging.example.com\n", out.String())
+
+	out.Reset()
+	root.Cmd.SetArgs([]string{"context", "use", "production"})
+	require.NoError(t, root.Execute())
+
+	assert.Equal(t, "switched to context production\n", out.String())
+
+	out.Reset()
+	root.Cmd.SetArgs([]string{"context", "list"})
+	require.NoError(t, root.Execute())
+
+	assert.Contains(t, out.String(), "* production")
+}
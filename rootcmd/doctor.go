@@ -0,0 +1,349 @@
+package rootcmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// DefaultDoctorCheckTimeout is how long each doctor check is given when no timeout is set
+const DefaultDoctorCheckTimeout = 10 * time.Second
+
+var (
+	// ErrCheckWarning is wrapped by the errors of checks reporting an issue that doesn't
+	// prevent the service from running, such checks are reported as warnings
+	ErrCheckWarning = errors.New("warning")
+
+	// ErrDoctorFailed is returned by the doctor command when a check failed
+	ErrDoctorFailed = errors.New("doctor checks failed")
+)
+
+// CheckStatus is the outcome of a doctor check
+type CheckStatus string
+
+const (
+	// CheckOK is the status of checks that passed
+	CheckOK CheckStatus = "ok"
+	// CheckWarn is the status of checks that returned an error wrapping ErrCheckWarning
+	CheckWarn CheckStatus = "warn"
+	// CheckFail is the status of checks that returned any other error
+	CheckFail CheckStatus = "fail"
+)
+
+// CheckFunc diagnoses a part of the environment, it returns details on what was checked and
+// an error when the check didn't pass. It should return once the context is done.
+type CheckFunc func(ctx context.Context) (string, error)
+
+// CheckResult is the outcome of a doctor check
+type CheckResult struct {
+	Name     string        `json:"name"`
+	Status   CheckStatus   `json:"status"`
+	Details  string        `json:"details,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration_ns"`
+}
+
+// DoctorReport holds the results of the doctor checks, in the order they were registered
+type DoctorReport struct {
+	Status CheckStatus   `json:"status"`
+	Checks []CheckResult `json:"checks"`
+}
+
+type namedCheck struct {
+	name  string
+	check CheckFunc
+}
+
+// RegisterCheck registers a check run by the doctor command, components register the checks
+// of the dependencies they need, e.g. the connectivity to a NATS server or an IdP
+func (r *Root) RegisterCheck(name string, check CheckFunc) {
+	r.checksMu.Lock()
+	defer r.checksMu.Unlock()
+
+	r.checks = append(r.checks, namedCheck{name: name, check: check})
+}
+
+// RunChecks runs the registered checks concurrently, each one given the timeout
+func (r *Root) RunChecks(ctx context.Context, timeout time.Duration) DoctorReport {
+	r.checksMu.Lock()
+	checks := r.checks
+	r.checksMu.Unlock()
+
+	if timeout <= 0 {
+		timeout = DefaultDoctorCheckTimeout
+	}
+
+	report := DoctorReport{Status: CheckOK, Checks: make([]CheckResult, len(checks))}
+
+	indexes := make([]int, len(checks))
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	// checks don't fail RunParallel, their errors are reported in the results
+	_ = RunParallel(ctx, indexes, len(checks), func(ctx context.Context, i int) error {
+		report.Checks[i] = runCheck(ctx, checks[i], timeout)
+		return nil
+	})
+
+	for i, result := range report.Checks {
+		// checks skipped once the context was done
+		if result.Name == "" {
+			report.Checks[i] = CheckResult{Name: checks[i].name, Status: CheckFail, Error: ctx.Err().Error()}
+		}
+
+		switch report.Checks[i].Status {
+		case CheckFail:
+			report.Status = CheckFail
+		case CheckWarn:
+			if report.Status == CheckOK {
+				report.Status = CheckWarn
+			}
+		}
+	}
+
+	return report
+}
+
+func runCheck(ctx context.Context, c namedCheck, timeout time.Duration) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	start := time.Now()
+	details, err := c.check(ctx)
+
+	result := CheckResult{
+		Name:     c.name,
+		Status:   CheckOK,
+		Details:  details,
+		Duration: time.Since(start),
+	}
+
+	if err != nil {
+		result.Status = CheckFail
+		if errors.Is(err, ErrCheckWarning) {
+			result.Status = CheckWarn
+		}
+
+		result.Error = err.Error()
+	}
+
+	return result
+}
+
+// WriteText writes the report for humans, colored when w is a terminal
+func (d DoctorReport) WriteText(w io.Writer) {
+	color := false
+	if f, ok := w.(*os.File); ok {
+		color = isTerminal(f)
+	}
+
+	for _, result := range d.Checks {
+		fmt.Fprintf(w, "%s %s (%s)\n", statusMark(result.Status, color), result.Name, result.Duration.Round(time.Millisecond))
+
+		if result.Details != "" {
+			fmt.Fprintf(w, "    %s\n", result.Details)
+		}
+
+		if result.Error != "" {
+			fmt.Fprintf(w, "    %s\n", result.Error)
+		}
+	}
+
+	fmt.Fprintf(w, "\n%d checks, status %s\n", len(d.Checks), statusMark(d.Status, color))
+}
+
+// WriteJSON writes the report as JSON for automation
+func (d DoctorReport) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	return enc.Encode(d)
+}
+
+func statusMark(status CheckStatus, color bool) string {
+	mark, code := "[ok]", "32"
+
+	switch status {
+	case CheckWarn:
+		mark, code = "[warn]", "33"
+	case CheckFail:
+		mark, code = "[fail]", "31"
+	}
+
+	if !color {
+		return mark
+	}
+
+	return "\033[" + code + "m" + mark + "\033[0m"
+}
+
+// AddDoctorCommand adds the doctor subcommand running the registered checks. It reports the
+// results on stdout, as JSON with --json, and fails with ErrDoctorFailed when a check failed.
+func AddDoctorCommand(root *Root) {
+	cmd := &cobra.Command{
+		Use:   "doctor",
+		Short: "Diagnose the environment the service runs in",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			asJSON, err := cmd.Flags().GetBool("json")
+			if err != nil {
+				return err
+			}
+
+			timeout, err := cmd.Flags().GetDuration("check-timeout")
+			if err != nil {
+				return err
+			}
+
+			ctx := cmd.Context()
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			report := root.RunChecks(ctx, timeout)
+
+			if asJSON {
+				if err := report.WriteJSON(cmd.OutOrStdout()); err != nil {
+					return err
+				}
+			} else {
+				report.WriteText(cmd.OutOrStdout())
+			}
+
+			if report.Status == CheckFail {
+				cmd.SilenceUsage = true
+				return ErrDoctorFailed
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().Bool("json", false, "report the results as JSON")
+	cmd.Flags().Duration("check-timeout", DefaultDoctorCheckTimeout, "how long each check is given")
+
+	root.Cmd.AddCommand(cmd)
+}
+
+// DialCheck returns a CheckFunc connecting to the TCP address, e.g. of a NATS server or a database
+func DialCheck(address string) CheckFunc {
+	return func(ctx context.Context) (string, error) {
+		var d net.Dialer
+
+		conn, err := d.DialContext(ctx, "tcp", address)
+		if err != nil {
+			return "", err
+		}
+
+		_ = conn.Close()
+
+		return "connected to " + address, nil
+	}
+}
+
+// PingCheck returns a CheckFunc pinging the pinger, e.g. a *sql.DB
+func PingCheck(pinger interface {
+	PingContext(ctx context.Context) error
+}) CheckFunc {
+	return func(ctx context.Context) (string, error) {
+		return "", pinger.PingContext(ctx)
+	}
+}
+
+// HTTPCheck returns a CheckFunc requesting the URL with client, e.g. the JWKS of an IdP,
+// statuses other than 2xx fail the check. The default client is used when client is nil.
+func HTTPCheck(client *http.Client, url string) CheckFunc {
+	return func(ctx context.Context) (string, error) {
+		resp, err := doCheckRequest(ctx, client, url)
+		if err != nil {
+			return "", err
+		}
+
+		if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+			return "", fmt.Errorf("GET %s: unexpected status %s", url, resp.Status) //nolint:goerr113 // reported as is
+		}
+
+		return fmt.Sprintf("GET %s: %s", url, resp.Status), nil
+	}
+}
+
+// ClockSkewCheck returns a CheckFunc comparing the local clock to the Date header of the
+// response to a request to the URL, a skew above maxSkew is reported as a warning.
+// The default client is used when client is nil.
+func ClockSkewCheck(client *http.Client, url string, maxSkew time.Duration) CheckFunc {
+	return func(ctx context.Context) (string, error) {
+		start := time.Now()
+
+		resp, err := doCheckRequest(ctx, client, url)
+		if err != nil {
+			return "", err
+		}
+
+		remote, err := http.ParseTime(resp.Header.Get("Date"))
+		if err != nil {
+			return "", fmt.Errorf("%s returned no valid Date header: %w", url, err)
+		}
+
+		// the Date header has a second resolution, the local time is taken halfway through the request
+		local := start.Add(time.Since(start) / 2) //nolint:gomnd // halfway
+		skew := local.Sub(remote).Round(time.Second)
+
+		details := fmt.Sprintf("clock skew with %s: %s", url, skew)
+
+		if skew > maxSkew || -skew > maxSkew {
+			return details, fmt.Errorf("%w: clock skew of %s above %s", ErrCheckWarning, skew, maxSkew)
+		}
+
+		return details, nil
+	}
+}
+
+// ConfigCheck returns a CheckFunc binding and validating the config T, see BindConfig
+func ConfigCheck[T any](v *viper.Viper) CheckFunc {
+	if v == nil {
+		v = viper.GetViper()
+	}
+
+	return func(ctx context.Context) (string, error) {
+		if _, err := BindConfig[T](v); err != nil {
+			return "", err
+		}
+
+		if file := v.ConfigFileUsed(); file != "" {
+			return "config file " + file + " is valid", nil
+		}
+
+		return "config is valid", nil
+	}
+}
+
+func doCheckRequest(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	return resp, nil
+}
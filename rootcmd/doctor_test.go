@@ -0,0 +1,264 @@
+package rootcmd_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.hollow.sh/toolbox/rootcmd"
+)
+
+func okCheck(details string) rootcmd.CheckFunc {
+	return func(ctx context.Context) (string, error) {
+		return details, nil
+	}
+}
+
+func errCheck(err error) rootcmd.CheckFunc {
+	return func(ctx context.Context) (string, error) {
+		return "", err
+	}
+}
+
+func TestRunChecks(t *testing.T) {
+	warning := fmt.Errorf("%w: disk almost full", rootcmd.ErrCheckWarning)
+
+	testCases := []struct {
+		name       string
+		checks     []rootcmd.CheckFunc
+		wantStatus rootcmd.CheckStatus
+		want       []rootcmd.CheckStatus
+	}{
+		{"no checks", nil, rootcmd.CheckOK, []rootcmd.CheckStatus{}},
+		{"all ok", []rootcmd.CheckFunc{okCheck("a"), okCheck("b")}, rootcmd.CheckOK, []rootcmd.CheckStatus{rootcmd.CheckOK, rootcmd.CheckOK}},
+		{"warning", []rootcmd.CheckFunc{okCheck("a"), errCheck(warning)}, rootcmd.CheckWarn, []rootcmd.CheckStatus{rootcmd.CheckOK, rootcmd.CheckWarn}},
+		{"failure", []rootcmd.CheckFunc{errCheck(errors.New("down")), errCheck(warning)}, rootcmd.CheckFail, []rootcmd.CheckStatus{rootcmd.CheckFail, rootcmd.CheckWarn}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			root := rootcmd.NewRootCmd("hollow", "hollow test")
+
+			for i, check := range tt.checks {
+				root.RegisterCheck(fmt.Sprintf("check %d", i), check)
+			}
+
+			report := root.RunChecks(context.Background(), 0)
+			assert.Equal(t, tt.wantStatus, report.Status)
+
+			// the results are in the order the checks were registered
+			got := []rootcmd.CheckStatus{}
+
+			for i, result := range report.Checks {
+				assert.Equal(t, fmt.Sprintf("check %d", i), result.Name)
+
+				got = append(got, result.Status)
+			}
+
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRunChecksTimeout(t *testing.T) {
+	root := rootcmd.NewRootCmd("hollow", "hollow test")
+
+	root.RegisterCheck("slow", func(ctx context.Context) (string, error) {
+		<-ctx.Done()
+		return "", ctx.Err()
+	})
+	root.RegisterCheck("fast", okCheck("done"))
+
+	start := time.Now()
+	report := root.RunChecks(context.Background(), 10*time.Millisecond)
+
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, rootcmd.CheckFail, report.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), report.Checks[0].Error)
+	assert.Equal(t, rootcmd.CheckOK, report.Checks[1].Status)
+	assert.Equal(t, "done", report.Checks[1].Details)
+}
+
+func TestRunChecksCanceled(t *testing.T) {
+	root := rootcmd.NewRootCmd("hollow", "hollow test")
+	root.RegisterCheck("nats", okCheck("connected"))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	report := root.RunChecks(ctx, 0)
+
+	assert.Equal(t, rootcmd.CheckFail, report.Status)
+	require.Len(t, report.Checks, 1)
+	assert.Equal(t, "nats", report.Checks[0].Name)
+	assert.Equal(t, context.Canceled.Error(), report.Checks[0].Error)
+}
+
+func TestDoctorCommand(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		check    rootcmd.CheckFunc
+		wantErr  error
+		wantText string
+	}{
+		{"text", nil, okCheck("connected to nats"), nil, "[ok] nats ("},
+		{"text failure", nil, errCheck(errors.New("connection refused")), rootcmd.ErrDoctorFailed, "    connection refused\n\n1 checks, status [fail]\n"},
+		{"json", []string{"--json"}, okCheck("connected to nats"), nil, `"status": "ok"`},
+		{"json warning", []string{"--json"}, errCheck(rootcmd.ErrCheckWarning), nil, `"status": "warn"`},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			root := rootcmd.NewRootCmd("hollow", "hollow test")
+			root.RegisterCheck("nats", tt.check)
+			rootcmd.AddDoctorCommand(root)
+
+			var out bytes.Buffer
+
+			root.Cmd.SilenceErrors = true
+			root.Cmd.SetOut(&out)
+			root.Cmd.SetArgs(append([]string{"doctor"}, tt.args...))
+
+			err := root.Execute()
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			// the output isn't a terminal, it isn't colored
+			assert.NotContains(t, out.String(), "\033[")
+			assert.Contains(t, out.String(), tt.wantText)
+
+			if len(tt.args) > 0 {
+				var report rootcmd.DoctorReport
+				require.NoError(t, json.Unmarshal(out.Bytes(), &report))
+				assert.Equal(t, "nats", report.Checks[0].Name)
+			}
+		})
+	}
+}
+
+func TestDialCheck(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	addr := l.Addr().String()
+
+	details, err := rootcmd.DialCheck(addr)(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "connected to "+addr, details)
+
+	require.NoError(t, l.Close())
+
+	_, err = rootcmd.DialCheck(addr)(context.Background())
+	assert.Error(t, err)
+}
+
+type testPinger struct {
+	err error
+}
+
+func (p testPinger) PingContext(ctx context.Context) error {
+	return p.err
+}
+
+func TestPingCheck(t *testing.T) {
+	_, err := rootcmd.PingCheck(testPinger{})(context.Background())
+	assert.NoError(t, err)
+
+	errPing := errors.New("database is closed")
+
+	_, err = rootcmd.PingCheck(testPinger{err: errPing})(context.Background())
+	assert.ErrorIs(t, err, errPing)
+}
+
+func TestHTTPCheck(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/jwks.json" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	details, err := rootcmd.HTTPCheck(nil, srv.URL+"/jwks.json")(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "GET "+srv.URL+"/jwks.json: 200 OK", details)
+
+	_, err = rootcmd.HTTPCheck(srv.Client(), srv.URL+"/missing")(context.Background())
+	assert.EqualError(t, err, "GET "+srv.URL+"/missing: unexpected status 404 Not Found")
+}
+
+func TestClockSkewCheck(t *testing.T) {
+	testCases := []struct {
+		name     string
+		date     func() string
+		wantErr  error
+		wantSkew string
+	}{
+		{"in sync", func() string { return time.Now().UTC().Format(http.TimeFormat) }, nil, "clock skew with"},
+		{"remote behind", func() string { return time.Now().Add(-time.Minute).UTC().Format(http.TimeFormat) }, rootcmd.ErrCheckWarning, "clock skew with"},
+		{"remote ahead", func() string { return time.Now().Add(time.Minute).UTC().Format(http.TimeFormat) }, rootcmd.ErrCheckWarning, ": -"},
+		{"invalid date", func() string { return "yesterday" }, nil, ""},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Date", tt.date())
+			}))
+			defer srv.Close()
+
+			details, err := rootcmd.ClockSkewCheck(nil, srv.URL, 5*time.Second)(context.Background())
+
+			switch {
+			case tt.wantSkew == "":
+				assert.ErrorContains(t, err, "returned no valid Date header")
+			case tt.wantErr != nil:
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Contains(t, details, tt.wantSkew)
+			default:
+				assert.NoError(t, err)
+				assert.Contains(t, details, tt.wantSkew)
+			}
+		})
+	}
+}
+
+func TestConfigCheck(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "hollow.yaml")
+
+	v := viper.New()
+	v.SetConfigFile(file)
+
+	require.NoError(t, os.WriteFile(file, []byte("db:\n  uri: postgres://db/hollow\n  port: 5432\n"), 0o600))
+	require.NoError(t, v.ReadInConfig())
+
+	details, err := rootcmd.ConfigCheck[bindTestConfig](v)(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "config file "+file+" is valid", details)
+
+	require.NoError(t, os.WriteFile(file, []byte("db:\n  port: 0\n"), 0o600))
+	require.NoError(t, v.ReadInConfig())
+
+	_, err = rootcmd.ConfigCheck[bindTestConfig](v)(context.Background())
+	assert.ErrorIs(t, err, rootcmd.ErrInvalidConfig)
+
+	details, err = rootcmd.ConfigCheck[bindTestConfig](newBindTestViper(t, "db:\n  uri: postgres://db/hollow\n  port: 1\n"))(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, "config is valid", details)
+}
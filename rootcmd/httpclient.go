@@ -0,0 +1,268 @@
+package rootcmd
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/semconv/v1.17.0/httpconv"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// DefaultHTTPTimeout is the timeout of requests made with NewHTTPClient when none is set
+	DefaultHTTPTimeout = 30 * time.Second
+
+	// DefaultHTTPMaxRetries is how many times requests made with NewHTTPClient are retried when no retries are set
+	DefaultHTTPMaxRetries = 3
+
+	// DefaultHTTPRetryWaitMin is the wait before the first retry when none is set, it doubles with each retry
+	DefaultHTTPRetryWaitMin = 500 * time.Millisecond
+
+	// DefaultHTTPRetryWaitMax is the longest wait between retries when none is set
+	DefaultHTTPRetryWaitMax = 10 * time.Second
+
+	httpTimeoutConfigKey = "http.timeout"
+	httpProxyConfigKey   = "http.proxy"
+	httpRetriesConfigKey = "http.retries"
+
+	httpTracerName = "go.hollow.sh/toolbox/rootcmd"
+)
+
+// ErrHTTPClient is returned when the HTTP client options are invalid
+var ErrHTTPClient = errors.New("invalid http client options")
+
+// HTTPClientOptions configures the client returned by NewHTTPClient
+type HTTPClientOptions struct {
+	// Timeout is the timeout of each request, retries included. Defaults to the --http-timeout flag value.
+	Timeout time.Duration
+
+	// Proxy is the URL of the proxy requests are sent through. Defaults to the --http-proxy flag
+	// value, the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables are used when neither is set.
+	Proxy string
+
+	// MaxRetries is how many times requests failing with a connection error or a 5xx status are
+	// retried, negative values disable retries. Defaults to the --http-retries flag value.
+	MaxRetries int
+
+	// RetryNonIdempotent retries the requests with non-idempotent methods, e.g. POST or PATCH, which
+	// may apply a write twice when the failed attempt reached the server. Otherwise only the requests
+	// with idempotent methods or an Idempotency-Key header are retried.
+	RetryNonIdempotent bool
+
+	// RetryWaitMin is the wait before the first retry, it doubles with each retry. Defaults to DefaultHTTPRetryWaitMin.
+	RetryWaitMin time.Duration
+
+	// RetryWaitMax is the longest wait between retries. Defaults to DefaultHTTPRetryWaitMax.
+	RetryWaitMax time.Duration
+
+	// ClientCredentials authenticates requests with a token obtained with the OIDC client credentials grant
+	ClientCredentials *ClientCredentials
+
+	// TokenFile authenticates requests with the bearer token read from the file, the file is read
+	// again for each request so rotated tokens are picked up
+	TokenFile string
+
+	// Transport sends the requests, defaults to a clone of http.DefaultTransport using the Proxy
+	Transport http.RoundTripper
+}
+
+// NewHTTPClient returns an http.Client injecting the configured authentication, retrying idempotent
+// requests failing with a connection error or a 5xx status with an exponential backoff, and tracing
+// requests with OpenTelemetry spans propagated to the server.
+//
+// Requests with a body are only retried when the body can be read again, see http.Request.GetBody.
+// Requests with non-idempotent methods are only retried with RetryNonIdempotent.
+func NewHTTPClient(opts HTTPClientOptions) (*http.Client, error) {
+	if opts.ClientCredentials != nil && opts.TokenFile != "" {
+		return nil, errors.Wrap(ErrHTTPClient, "either ClientCredentials or a TokenFile may be set")
+	}
+
+	opts.setDefaults()
+
+	transport := opts.Transport
+	if transport == nil {
+		base := http.DefaultTransport.(*http.Transport).Clone()
+
+		base.Proxy = http.ProxyFromEnvironment
+
+		if opts.Proxy != "" {
+			proxy, err := url.Parse(opts.Proxy)
+			if err != nil {
+				return nil, errors.Wrap(ErrHTTPClient, "proxy: "+err.Error())
+			}
+
+			base.Proxy = http.ProxyURL(proxy)
+		}
+
+		transport = base
+	}
+
+	switch {
+	case opts.ClientCredentials != nil:
+		tokens, err := newClientCredentialsSource(opts.ClientCredentials, &http.Client{Transport: transport, Timeout: opts.Timeout})
+		if err != nil {
+			return nil, err
+		}
+
+		transport = &authTransport{base: transport, tokens: tokens}
+	case opts.TokenFile != "":
+		transport = &authTransport{base: transport, tokens: tokenFile(opts.TokenFile)}
+	}
+
+	if opts.MaxRetries > 0 {
+		transport = &retryTransport{base: transport, opts: &opts}
+	}
+
+	return &http.Client{
+		Transport: &tracingTransport{base: transport},
+		Timeout:   opts.Timeout,
+	}, nil
+}
+
+func (o *HTTPClientOptions) setDefaults() {
+	if o.Timeout == 0 {
+		o.Timeout = viper.GetDuration(httpTimeoutConfigKey)
+	}
+
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultHTTPTimeout
+	}
+
+	if o.Proxy == "" {
+		o.Proxy = viper.GetString(httpProxyConfigKey)
+	}
+
+	if o.MaxRetries == 0 {
+		o.MaxRetries = DefaultHTTPMaxRetries
+
+		if viper.IsSet(httpRetriesConfigKey) {
+			o.MaxRetries = viper.GetInt(httpRetriesConfigKey)
+		}
+	}
+
+	if o.RetryWaitMin <= 0 {
+		o.RetryWaitMin = DefaultHTTPRetryWaitMin
+	}
+
+	if o.RetryWaitMax <= 0 {
+		o.RetryWaitMax = DefaultHTTPRetryWaitMax
+	}
+}
+
+// InitHTTPClientFlags adds the --http-timeout, --http-proxy and --http-retries flags used by NewHTTPClient
+func (r *Root) InitHTTPClientFlags() {
+	r.Cmd.PersistentFlags().DurationVar(&r.Options.HTTPTimeout, "http-timeout", DefaultHTTPTimeout, "timeout of http requests")
+	r.ViperBindFlag(httpTimeoutConfigKey, "http-timeout")
+
+	r.Cmd.PersistentFlags().StringVar(&r.Options.HTTPProxy, "http-proxy", "", "proxy url http requests are sent through")
+	r.ViperBindFlag(httpProxyConfigKey, "http-proxy")
+
+	r.Cmd.PersistentFlags().IntVar(&r.Options.HTTPRetries, "http-retries", DefaultHTTPMaxRetries, "number of times failed http requests are retried")
+	r.ViperBindFlag(httpRetriesConfigKey, "http-retries")
+}
+
+// retryTransport retries requests failing with a connection error or a 5xx status
+type retryTransport struct {
+	base http.RoundTripper
+	opts *HTTPClientOptions
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// the body was consumed by the first attempt and can't be sent again
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return t.base.RoundTrip(req)
+	}
+
+	if !t.opts.RetryNonIdempotent && !isIdempotent(req) {
+		return t.base.RoundTrip(req)
+	}
+
+	wait := t.opts.RetryWaitMin
+
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(req)
+
+		// requests which can't be authenticated fail the same way when retried
+		retryable := (err != nil && !errors.Is(err, ErrHTTPClientAuth)) || (err == nil && resp.StatusCode >= http.StatusInternalServerError)
+		if !retryable || attempt >= t.opts.MaxRetries || req.Context().Err() != nil {
+			return resp, err
+		}
+
+		if resp != nil {
+			// drained so the connection is reused
+			_, _ = io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+
+		if wait *= 2; wait > t.opts.RetryWaitMax {
+			wait = t.opts.RetryWaitMax
+		}
+	}
+}
+
+// isIdempotent returns true for the requests which can be sent twice without side effects, like
+// http.Transport does: the ones with an idempotent method or an idempotency key header.
+func isIdempotent(req *http.Request) bool {
+	switch req.Method {
+	case "", http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+
+	_, ok := req.Header["Idempotency-Key"]
+	if !ok {
+		_, ok = req.Header["X-Idempotency-Key"]
+	}
+
+	return ok
+}
+
+// tracingTransport traces requests with a client span, the span context is propagated to the server in the request headers
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx, span := otel.Tracer(httpTracerName).Start(req.Context(), "HTTP "+req.Method,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(httpconv.ClientRequest(req)...),
+	)
+
+	req = req.Clone(ctx)
+	otel.GetTextMapPropagator().Inject(ctx, propagation.HeaderCarrier(req.Header))
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		span.RecordError(err)
+		span.End()
+
+		return nil, err
+	}
+
+	span.SetAttributes(httpconv.ClientResponse(resp)...)
+	span.SetStatus(httpconv.ClientStatus(resp.StatusCode))
+	span.End()
+
+	return resp, nil
+}
@@ -0,0 +1,184 @@
+package rootcmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// tokens are renewed this long before they expire, or halfway through their lifetime when shorter
+const tokenExpiryMargin = 30 * time.Second
+
+// ErrHTTPClientAuth is returned when a request can't be authenticated
+var ErrHTTPClientAuth = errors.New("http client authentication")
+
+// ClientCredentials configures the OIDC client credentials grant used to authenticate requests
+type ClientCredentials struct {
+	// TokenURL is the token endpoint of the identity provider
+	TokenURL     string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+	// Audience is requested for providers issuing tokens for an audience, e.g. Auth0
+	Audience string
+}
+
+// tokenSource returns the bearer token requests are authenticated with
+type tokenSource interface {
+	token(ctx context.Context) (string, error)
+}
+
+// authTransport sets the bearer token on the requests
+type authTransport struct {
+	base   http.RoundTripper
+	tokens tokenSource
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	token, err := t.tokens.token(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := t.base.RoundTrip(req)
+
+	// the token was revoked or rotated, a new one is requested for the next request
+	if err == nil && resp.StatusCode == http.StatusUnauthorized {
+		if cc, ok := t.tokens.(*clientCredentialsSource); ok {
+			cc.invalidate(token)
+		}
+	}
+
+	return resp, err
+}
+
+// tokenFile reads the token from the file for each request, so rotated tokens are picked up
+type tokenFile string
+
+func (f tokenFile) token(_ context.Context) (string, error) {
+	b, err := os.ReadFile(string(f))
+	if err != nil {
+		return "", errors.Wrap(ErrHTTPClientAuth, err.Error())
+	}
+
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return "", errors.Wrap(ErrHTTPClientAuth, "token file "+string(f)+" is empty")
+	}
+
+	return token, nil
+}
+
+// clientCredentialsSource requests tokens with the client credentials grant, caching them until they near expiry
+// or are rejected
+type clientCredentialsSource struct {
+	cfg    *ClientCredentials
+	client *http.Client
+
+	mu      sync.Mutex
+	cached  string
+	expires time.Time
+}
+
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+func newClientCredentialsSource(cfg *ClientCredentials, client *http.Client) (*clientCredentialsSource, error) {
+	if cfg.TokenURL == "" || cfg.ClientID == "" || cfg.ClientSecret == "" {
+		return nil, errors.Wrap(ErrHTTPClient, "client credentials require a TokenURL, a ClientID and a ClientSecret")
+	}
+
+	return &clientCredentialsSource{cfg: cfg, client: client}, nil
+}
+
+func (s *clientCredentialsSource) token(ctx context.Context) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cached != "" && (s.expires.IsZero() || time.Now().Before(s.expires)) {
+		return s.cached, nil
+	}
+
+	form := url.Values{"grant_type": {"client_credentials"}}
+
+	if len(s.cfg.Scopes) > 0 {
+		form.Set("scope", strings.Join(s.cfg.Scopes, " "))
+	}
+
+	if s.cfg.Audience != "" {
+		form.Set("audience", s.cfg.Audience)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.cfg.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", errors.Wrap(ErrHTTPClientAuth, err.Error())
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(s.cfg.ClientID), url.QueryEscape(s.cfg.ClientSecret))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", errors.Wrap(ErrHTTPClientAuth, err.Error())
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.Wrap(ErrHTTPClientAuth, fmt.Sprintf("token endpoint returned %d", resp.StatusCode))
+	}
+
+	var tr tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tr); err != nil {
+		return "", errors.Wrap(ErrHTTPClientAuth, "decoding token response: "+err.Error())
+	}
+
+	if tr.AccessToken == "" {
+		return "", errors.Wrap(ErrHTTPClientAuth, "token endpoint returned no access token")
+	}
+
+	s.cached = tr.AccessToken
+	s.expires = tokenRenewal(time.Now(), time.Duration(tr.ExpiresIn)*time.Second)
+
+	return s.cached, nil
+}
+
+// tokenRenewal returns when a token issued at now for the lifetime is to be renewed, zero when the
+// lifetime is unknown so the token is used until it is rejected.
+func tokenRenewal(now time.Time, lifetime time.Duration) time.Time {
+	if lifetime <= 0 {
+		return time.Time{}
+	}
+
+	margin := tokenExpiryMargin
+	if margin > lifetime/2 {
+		margin = lifetime / 2
+	}
+
+	return now.Add(lifetime - margin)
+}
+
+// invalidate drops the cached token unless it was renewed already
+func (s *clientCredentialsSource) invalidate(token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cached == token {
+		s.cached = ""
+	}
+}
@@ -0,0 +1,313 @@
+package rootcmd_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.hollow.sh/toolbox/rootcmd"
+)
+
+// newFlakyServer fails the first requests with the status, the body of every request must be "payload"
+func newFlakyServer(t *testing.T, failures int32, status int) (*httptest.Server, *int32) {
+	t.Helper()
+
+	var requests int32
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&requests, 1)
+
+		if r.Method != http.MethodGet {
+			body, _ := io.ReadAll(r.Body)
+			if string(body) != "payload" {
+				w.WriteHeader(http.StatusBadRequest)
+				return
+			}
+		}
+
+		if n <= failures {
+			w.WriteHeader(status)
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &requests
+}
+
+func TestHTTPClientRetries(t *testing.T) {
+	testCases := []struct {
+		name               string
+		method             string
+		headers            map[string]string
+		retryNonIdempotent bool
+		maxRetries         int
+		failures           int32
+		status             int
+		wantRequests       int32
+		wantStatus         int
+	}{
+		{"get retried until it succeeds", http.MethodGet, nil, false, 3, 2, http.StatusServiceUnavailable, 3, http.StatusOK},
+		{"get retries exhausted", http.MethodGet, nil, false, 2, 10, http.StatusBadGateway, 3, http.StatusBadGateway},
+		{"client errors aren't retried", http.MethodGet, nil, false, 3, 1, http.StatusNotFound, 1, http.StatusNotFound},
+		{"retries disabled", http.MethodGet, nil, false, -1, 1, http.StatusServiceUnavailable, 1, http.StatusServiceUnavailable},
+		{"put body replayed", http.MethodPut, nil, false, 3, 1, http.StatusInternalServerError, 2, http.StatusOK},
+		{"post isn't retried", http.MethodPost, nil, false, 3, 1, http.StatusInternalServerError, 1, http.StatusInternalServerError},
+		{"patch isn't retried", http.MethodPatch, nil, false, 3, 1, http.StatusInternalServerError, 1, http.StatusInternalServerError},
+		{"post with an idempotency key", http.MethodPost, map[string]string{"Idempotency-Key": "k1"}, false, 3, 1, http.StatusInternalServerError, 2, http.StatusOK},
+		{"post retried when opted in", http.MethodPost, nil, true, 3, 2, http.StatusInternalServerError, 3, http.StatusOK},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := newFlakyServer(t, tt.failures, tt.status)
+
+			client, err := rootcmd.NewHTTPClient(rootcmd.HTTPClientOptions{
+				MaxRetries:         tt.maxRetries,
+				RetryWaitMin:       time.Millisecond,
+				RetryWaitMax:       2 * time.Millisecond,
+				RetryNonIdempotent: tt.retryNonIdempotent,
+			})
+			require.NoError(t, err)
+
+			var body io.Reader
+			if tt.method != http.MethodGet {
+				body = strings.NewReader("payload")
+			}
+
+			req, err := http.NewRequest(tt.method, srv.URL, body)
+			require.NoError(t, err)
+
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+
+			resp.Body.Close()
+
+			assert.Equal(t, tt.wantStatus, resp.StatusCode)
+			assert.Equal(t, tt.wantRequests, atomic.LoadInt32(requests))
+		})
+	}
+}
+
+func TestHTTPClientRetriesCanceled(t *testing.T) {
+	srv, requests := newFlakyServer(t, 100, http.StatusServiceUnavailable)
+
+	client, err := rootcmd.NewHTTPClient(rootcmd.HTTPClientOptions{
+		MaxRetries:   10,
+		RetryWaitMin: time.Hour,
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+
+	start := time.Now()
+
+	_, err = client.Do(req)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+}
+
+// tokenServer issues numbered tokens with the client credentials grant and serves an API rejecting revoked tokens
+type tokenServer struct {
+	*httptest.Server
+
+	expiresIn int64
+	issued    int32
+
+	mu      sync.Mutex
+	revoked map[string]bool
+}
+
+func newTokenServer(t *testing.T, expiresIn int64) *tokenServer {
+	t.Helper()
+
+	ts := &tokenServer{expiresIn: expiresIn, revoked: map[string]bool{}}
+
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+		id, secret, _ := r.BasicAuth()
+		if id != "client" || secret != "s3cr3t%25" || r.FormValue("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		n := atomic.AddInt32(&ts.issued, 1)
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": fmt.Sprintf("token-%d", n),
+			"token_type":   "Bearer",
+			"expires_in":   ts.expiresIn,
+		})
+	})
+
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		ts.mu.Lock()
+		defer ts.mu.Unlock()
+
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if token == "" || ts.revoked[token] {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		_, _ = io.WriteString(w, token)
+	})
+
+	ts.Server = httptest.NewServer(mux)
+	t.Cleanup(ts.Close)
+
+	return ts
+}
+
+func (ts *tokenServer) revoke(token string) {
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+
+	ts.revoked[token] = true
+}
+
+// get requests the API and returns the status and the token it was authenticated with
+func get(t *testing.T, client *http.Client, url string) (int, string) {
+	t.Helper()
+
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+
+	return resp.StatusCode, string(body)
+}
+
+func TestHTTPClientClientCredentials(t *testing.T) {
+	testCases := []struct {
+		name      string
+		expiresIn int64
+	}{
+		{"long lived tokens", 3600},
+		{"tokens shorter than the renewal margin", 10},
+		{"tokens without expiry", 0},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTokenServer(t, tt.expiresIn)
+
+			client, err := rootcmd.NewHTTPClient(rootcmd.HTTPClientOptions{
+				MaxRetries: -1,
+				ClientCredentials: &rootcmd.ClientCredentials{
+					TokenURL:     ts.URL + "/token",
+					ClientID:     "client",
+					ClientSecret: "s3cr3t%",
+				},
+			})
+			require.NoError(t, err)
+
+			// the token is cached
+			for i := 0; i < 3; i++ {
+				code, token := get(t, client, ts.URL+"/api")
+				assert.Equal(t, http.StatusOK, code)
+				assert.Equal(t, "token-1", token)
+			}
+
+			assert.Equal(t, int32(1), atomic.LoadInt32(&ts.issued))
+
+			// a rejected token is renewed for the next request
+			ts.revoke("token-1")
+
+			code, _ := get(t, client, ts.URL+"/api")
+			assert.Equal(t, http.StatusUnauthorized, code)
+
+			code, token := get(t, client, ts.URL+"/api")
+			assert.Equal(t, http.StatusOK, code)
+			assert.Equal(t, "token-2", token)
+			assert.Equal(t, int32(2), atomic.LoadInt32(&ts.issued))
+		})
+	}
+}
+
+func TestHTTPClientClientCredentialsRejected(t *testing.T) {
+	ts := newTokenServer(t, 3600)
+
+	client, err := rootcmd.NewHTTPClient(rootcmd.HTTPClientOptions{
+		ClientCredentials: &rootcmd.ClientCredentials{
+			TokenURL:     ts.URL + "/token",
+			ClientID:     "client",
+			ClientSecret: "wrong",
+		},
+	})
+	require.NoError(t, err)
+
+	_, err = client.Get(ts.URL + "/api")
+	assert.ErrorIs(t, err, rootcmd.ErrHTTPClientAuth)
+}
+
+func TestHTTPClientTokenFile(t *testing.T) {
+	ts := newTokenServer(t, 0)
+	file := filepath.Join(t.TempDir(), "token")
+
+	client, err := rootcmd.NewHTTPClient(rootcmd.HTTPClientOptions{TokenFile: file})
+	require.NoError(t, err)
+
+	_, err = client.Get(ts.URL + "/api")
+	assert.ErrorIs(t, err, rootcmd.ErrHTTPClientAuth)
+
+	require.NoError(t, os.WriteFile(file, []byte("  \n"), 0o600))
+
+	_, err = client.Get(ts.URL + "/api")
+	assert.ErrorIs(t, err, rootcmd.ErrHTTPClientAuth)
+
+	// rotated tokens are picked up
+	for _, token := range []string{"first", "second"} {
+		require.NoError(t, os.WriteFile(file, []byte(token+"\n"), 0o600))
+
+		code, got := get(t, client, ts.URL+"/api")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, token, got)
+	}
+}
+
+func TestNewHTTPClientInvalidOptions(t *testing.T) {
+	testCases := []struct {
+		name string
+		opts rootcmd.HTTPClientOptions
+	}{
+		{"client credentials and token file", rootcmd.HTTPClientOptions{ClientCredentials: &rootcmd.ClientCredentials{}, TokenFile: "token"}},
+		{"incomplete client credentials", rootcmd.HTTPClientOptions{ClientCredentials: &rootcmd.ClientCredentials{TokenURL: "http://idp/token"}}},
+		{"invalid proxy", rootcmd.HTTPClientOptions{Proxy: "http://[::1"}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := rootcmd.NewHTTPClient(tt.opts)
+			assert.ErrorIs(t, err, rootcmd.ErrHTTPClient)
+		})
+	}
+}
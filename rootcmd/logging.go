@@ -0,0 +1,110 @@
+package rootcmd
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+
+	"go.hollow.sh/toolbox/version"
+)
+
+// LoggerOption configures the logger built by NewLogger
+type LoggerOption func(*loggerOptions)
+
+type loggerOptions struct {
+	cores   []zapcore.Core
+	globals bool
+}
+
+// WithLogCores tees the log entries to the cores too, e.g. an OpenTelemetry log bridge such as
+// otelzap.NewCore exporting them along with the traces
+func WithLogCores(cores ...zapcore.Core) LoggerOption {
+	return func(o *loggerOptions) {
+		o.cores = append(o.cores, cores...)
+	}
+}
+
+// WithGlobalLogger replaces the zap global loggers, zap.L and zap.S, with the logger built
+func WithGlobalLogger() LoggerOption {
+	return func(o *loggerOptions) {
+		o.globals = true
+	}
+}
+
+// NewLogger builds the logger configured by the --debug and --pretty flags, with the app name and
+// version fields, and sets it as the logger returned by GetLogger. A logger set before is synced
+// before it is replaced.
+func (o *Options) NewLogger(opts ...LoggerOption) (*zap.Logger, error) {
+	var lo loggerOptions
+	for _, opt := range opts {
+		opt(&lo)
+	}
+
+	cfg := zap.NewProductionConfig()
+	if o.PrettyPrint {
+		cfg = zap.NewDevelopmentConfig()
+	}
+
+	if o.Debug {
+		cfg.Level = zap.NewAtomicLevelAt(zap.DebugLevel)
+	} else {
+		cfg.Level = zap.NewAtomicLevelAt(zap.InfoLevel)
+	}
+
+	var zapOpts []zap.Option
+
+	if len(lo.cores) > 0 {
+		cores := lo.cores
+
+		zapOpts = append(zapOpts, zap.WrapCore(func(core zapcore.Core) zapcore.Core {
+			return zapcore.NewTee(append([]zapcore.Core{core}, cores...)...)
+		}))
+	}
+
+	l, err := cfg.Build(zapOpts...)
+	if err != nil {
+		return nil, err
+	}
+
+	l = l.With(zap.String("app", o.App), zap.String("version", version.Version()))
+
+	if o.logger != nil {
+		_ = o.logger.Sync()
+	}
+
+	o.logger = l.Sugar()
+
+	if lo.globals {
+		zap.ReplaceGlobals(l)
+	}
+
+	return l, nil
+}
+
+// NewSugaredLogger builds the logger like NewLogger and returns its sugared variant
+func (o *Options) NewSugaredLogger(opts ...LoggerOption) (*zap.SugaredLogger, error) {
+	l, err := o.NewLogger(opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return l.Sugar(), nil
+}
+
+// LoggerFromContext returns the logger returned by GetLogger with the trace_id and span_id fields
+// of the span in the context, correlating the log entries with the traces. The logger is returned
+// as is when the context holds no span.
+func (o *Options) LoggerFromContext(ctx context.Context) *zap.SugaredLogger {
+	if o.logger == nil {
+		return nil
+	}
+
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return o.logger
+	}
+
+	return o.logger.With("trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
+}
@@ -0,0 +1,109 @@
+package rootcmd_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+
+	"go.hollow.sh/toolbox/rootcmd"
+	"go.hollow.sh/toolbox/version"
+)
+
+func TestNewLogger(t *testing.T) {
+	testCases := []struct {
+		name      string
+		debug     bool
+		wantLevel zapcore.Level
+	}{
+		{"info", false, zap.InfoLevel},
+		{"debug", true, zap.DebugLevel},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			o := &rootcmd.Options{App: "hollow", Debug: tt.debug}
+
+			l, err := o.NewLogger()
+			require.NoError(t, err)
+
+			assert.True(t, l.Core().Enabled(tt.wantLevel))
+			assert.False(t, l.Core().Enabled(tt.wantLevel-1))
+
+			// the teed cores filter the entries with their own level
+			core, logs := observer.New(tt.wantLevel)
+
+			_, err = o.NewLogger(rootcmd.WithLogCores(core))
+			require.NoError(t, err)
+
+			// the logger is the one returned by GetLogger
+			o.GetLogger().Infow("started")
+
+			// the entries are teed to the cores with the app and version fields
+			entries := logs.FilterMessage("started").All()
+			require.Len(t, entries, 1)
+			assert.Equal(t, map[string]interface{}{"app": "hollow", "version": version.Version()}, entries[0].ContextMap())
+		})
+	}
+}
+
+func TestNewLoggerGlobals(t *testing.T) {
+	prev := zap.L()
+	t.Cleanup(func() { zap.ReplaceGlobals(prev) })
+
+	o := &rootcmd.Options{App: "hollow"}
+
+	l, err := o.NewLogger()
+	require.NoError(t, err)
+	assert.NotSame(t, l, zap.L())
+
+	l, err = o.NewLogger(rootcmd.WithGlobalLogger())
+	require.NoError(t, err)
+	assert.Same(t, l, zap.L())
+}
+
+func TestNewSugaredLogger(t *testing.T) {
+	o := &rootcmd.Options{App: "hollow", PrettyPrint: true}
+
+	l, err := o.NewSugaredLogger()
+	require.NoError(t, err)
+	assert.NotNil(t, l)
+	assert.NotNil(t, o.GetLogger())
+
+	// replaced by the deprecated SetupLogging
+	o.SetupLogging(nil)
+	assert.NotSame(t, l, o.GetLogger())
+}
+
+func TestLoggerFromContext(t *testing.T) {
+	o := &rootcmd.Options{App: "hollow"}
+
+	assert.Nil(t, o.LoggerFromContext(context.Background()))
+
+	core, logs := observer.New(zap.InfoLevel)
+
+	_, err := o.NewLogger(rootcmd.WithLogCores(core))
+	require.NoError(t, err)
+
+	// without a span the logger is returned as is
+	assert.Same(t, o.GetLogger(), o.LoggerFromContext(context.Background()))
+
+	provider := sdktrace.NewTracerProvider()
+
+	ctx, span := provider.Tracer("logging").Start(context.Background(), "request")
+	defer span.End()
+
+	o.LoggerFromContext(ctx).Info("handled")
+
+	entries := logs.FilterMessage("handled").All()
+	require.Len(t, entries, 1)
+
+	fields := entries[0].ContextMap()
+	assert.Equal(t, span.SpanContext().TraceID().String(), fields["trace_id"])
+	assert.Equal(t, span.SpanContext().SpanID().String(), fields["span_id"])
+}
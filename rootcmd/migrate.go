@@ -0,0 +1,124 @@
+package rootcmd
+
+import (
+	"context"
+	"errors"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+const dbURIConfigKey = "db.uri"
+
+// ErrMissingDBURI is returned when running migrations without a database URI
+var ErrMissingDBURI = errors.New("database URI required, set --db-uri")
+
+// MigrateDirection is the migration operation requested
+type MigrateDirection string
+
+const (
+	// MigrateUp applies the pending migrations
+	MigrateUp MigrateDirection = "up"
+	// MigrateDown rolls back migrations
+	MigrateDown MigrateDirection = "down"
+	// MigrateStatus reports the state of the migrations without changing anything
+	MigrateStatus MigrateDirection = "status"
+)
+
+// MigrateOptions holds what a MigrateFunc is asked to do
+type MigrateOptions struct {
+	Direction MigrateDirection
+	// DBURI is the URI of the database to migrate, from --db-uri or the db.uri setting
+	DBURI string
+	// Version is the version to migrate up or down to, when empty all pending migrations
+	// are applied on up and the last one is rolled back on down
+	Version string
+	// DryRun asks for the migrations to be reported and not applied
+	DryRun bool
+	Logger *zap.SugaredLogger
+}
+
+// MigrateFunc runs the migrations of a service, it wraps the migration tool used by the service
+type MigrateFunc func(ctx context.Context, opts MigrateOptions) error
+
+// AddMigrateCommand adds the migrate up, down and status subcommands running migrateFn
+func AddMigrateCommand(root *Root, migrateFn MigrateFunc) {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Run the database migrations",
+	}
+
+	cmd.PersistentFlags().String("db-uri", "", "URI of the database to migrate")
+
+	if err := viper.BindPFlag(dbURIConfigKey, cmd.PersistentFlags().Lookup("db-uri")); err != nil {
+		panic(err)
+	}
+
+	registerConfigFlag(dbURIConfigKey, cmd.PersistentFlags().Lookup("db-uri"))
+
+	cmd.PersistentFlags().Bool("dry-run", false, "report the migrations without applying them")
+
+	run := func(direction MigrateDirection) func(*cobra.Command, []string) error {
+		return func(cmd *cobra.Command, args []string) error {
+			opts := MigrateOptions{
+				Direction: direction,
+				DBURI:     viper.GetString(dbURIConfigKey),
+				Logger:    root.Options.GetLogger(),
+			}
+
+			if opts.DBURI == "" {
+				return ErrMissingDBURI
+			}
+
+			if len(args) > 0 {
+				opts.Version = args[0]
+			}
+
+			dryRun, err := cmd.Flags().GetBool("dry-run")
+			if err != nil {
+				return err
+			}
+
+			opts.DryRun = dryRun
+
+			if opts.Logger == nil {
+				opts.Logger = zap.NewNop().Sugar()
+			}
+
+			opts.Logger.Infow("running migrations", "direction", direction, "version", opts.Version, "dry_run", opts.DryRun)
+
+			if err := migrateFn(cmd.Context(), opts); err != nil {
+				opts.Logger.Errorw("migrations failed", "direction", direction, "error", err)
+				return err
+			}
+
+			opts.Logger.Infow("migrations done", "direction", direction)
+
+			return nil
+		}
+	}
+
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "up [VERSION]",
+			Short: "Apply the pending migrations, up to VERSION when given",
+			Args:  cobra.MaximumNArgs(1),
+			RunE:  run(MigrateUp),
+		},
+		&cobra.Command{
+			Use:   "down [VERSION]",
+			Short: "Roll back the last migration, or down to VERSION when given",
+			Args:  cobra.MaximumNArgs(1),
+			RunE:  run(MigrateDown),
+		},
+		&cobra.Command{
+			Use:   "status",
+			Short: "Report the state of the migrations",
+			Args:  cobra.NoArgs,
+			RunE:  run(MigrateStatus),
+		},
+	)
+
+	root.Cmd.AddCommand(cmd)
+}
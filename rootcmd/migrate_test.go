@@ -0,0 +1,125 @@
+package rootcmd_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.hollow.sh/toolbox/rootcmd"
+)
+
+func TestMigrateCommand(t *testing.T) {
+	errMigrate := errors.New("migration failed")
+
+	testCases := []struct {
+		name       string
+		args       []string
+		env        string
+		migrateErr error
+		want       rootcmd.MigrateOptions
+		wantErr    error
+	}{
+		{
+			"up",
+			[]string{"migrate", "up", "--db-uri", "postgres://db/hollow"},
+			"",
+			nil,
+			rootcmd.MigrateOptions{Direction: rootcmd.MigrateUp, DBURI: "postgres://db/hollow"},
+			nil,
+		},
+		{
+			"down to a version dry run",
+			[]string{"migrate", "down", "20230101", "--db-uri", "postgres://db/hollow", "--dry-run"},
+			"",
+			nil,
+			rootcmd.MigrateOptions{Direction: rootcmd.MigrateDown, DBURI: "postgres://db/hollow", Version: "20230101", DryRun: true},
+			nil,
+		},
+		{
+			"status with the uri from the environment",
+			[]string{"migrate", "status"},
+			"postgres://env/hollow",
+			nil,
+			rootcmd.MigrateOptions{Direction: rootcmd.MigrateStatus, DBURI: "postgres://env/hollow"},
+			nil,
+		},
+		{
+			"missing uri",
+			[]string{"migrate", "up"},
+			"",
+			nil,
+			rootcmd.MigrateOptions{},
+			rootcmd.ErrMissingDBURI,
+		},
+		{
+			"migrations failed",
+			[]string{"migrate", "up", "--db-uri", "postgres://db/hollow"},
+			"",
+			errMigrate,
+			rootcmd.MigrateOptions{Direction: rootcmd.MigrateUp, DBURI: "postgres://db/hollow"},
+			errMigrate,
+		},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			setTestHome(t)
+			t.Setenv("HOLLOW_DB_URI", tt.env)
+
+			root := rootcmd.NewRootCmd("hollow", "hollow test")
+			root.InitFlags()
+			root.Options.InitConfig()
+
+			var got *rootcmd.MigrateOptions
+
+			rootcmd.AddMigrateCommand(root, func(ctx context.Context, opts rootcmd.MigrateOptions) error {
+				got = &opts
+				return tt.migrateErr
+			})
+
+			root.Cmd.SilenceErrors = true
+			root.Cmd.SilenceUsage = true
+			root.Cmd.SetArgs(tt.args)
+
+			err := root.Execute()
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				require.NoError(t, err)
+			}
+
+			if tt.want.Direction == "" {
+				assert.Nil(t, got)
+				return
+			}
+
+			require.NotNil(t, got)
+
+			// a nop logger is used without a configured one
+			assert.NotNil(t, got.Logger)
+
+			got.Logger = nil
+			assert.Equal(t, tt.want, *got)
+		})
+	}
+
+	t.Run("too many arguments", func(t *testing.T) {
+		setTestHome(t)
+
+		root := rootcmd.NewRootCmd("hollow", "hollow test")
+
+		rootcmd.AddMigrateCommand(root, func(ctx context.Context, opts rootcmd.MigrateOptions) error {
+			t.Fatal("migrations run")
+			return nil
+		})
+
+		root.Cmd.SilenceErrors = true
+		root.Cmd.SilenceUsage = true
+		root.Cmd.SetArgs([]string{"migrate", "status", "1", "--db-uri", "postgres://db/hollow"})
+
+		assert.Error(t, root.Execute())
+	})
+}
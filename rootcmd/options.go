@@ -2,13 +2,11 @@ package rootcmd
 
 import (
 	"strings"
+	"time"
 
-	"github.com/mitchellh/go-homedir"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
-	"go.hollow.sh/toolbox/version"
-
 	"go.uber.org/zap"
 )
 
@@ -18,7 +16,26 @@ type Options struct {
 	ConfigFile  string
 	Debug       bool
 	PrettyPrint bool
+	Context     string
 	logger      *zap.SugaredLogger
+
+	// Yes and NonInteractive are set by the flags added with InitConfirmFlags
+	Yes            bool
+	NonInteractive bool
+
+	// ShutdownGracePeriod is how long the shutdown hooks are given, defaults to DefaultShutdownGracePeriod
+	ShutdownGracePeriod time.Duration
+
+	// Concurrency is set by the flag added with InitConcurrencyFlag
+	Concurrency int
+
+	// HTTPTimeout, HTTPProxy and HTTPRetries are set by the flags added with InitHTTPClientFlags
+	HTTPTimeout time.Duration
+	HTTPProxy   string
+	HTTPRetries int
+
+	// NoTelemetry is set by the flag added with EnableTelemetry
+	NoTelemetry bool
 }
 
 // GetLogger returns the zap.SugarLogger
@@ -31,43 +48,31 @@ func (o *Options) GetConfigFile() string {
 	return o.ConfigFile
 }
 
-// SetupLogging is a common configuraion of a zap.SugaredLogger, set to the logger passed
-func (o *Options) SetupLogging(logger *zap.SugaredLogger) {
-	defer logger.Sync() //nolint:errcheck
-
-	cfg := zap.NewProductionConfig()
-	if o.PrettyPrint {
-		cfg = zap.NewDevelopmentConfig()
-	}
-
-	if o.Debug {
-		cfg.Level = zap.NewAtomicLevelAt(zap.DebugLevel)
-	} else {
-		cfg.Level = zap.NewAtomicLevelAt(zap.InfoLevel)
-	}
-
-	l, err := cfg.Build()
-	if err != nil {
+// SetupLogging is a common configuraion of a zap.SugaredLogger, retrieved with GetLogger.
+// The logger passed is ignored, it was never set to the configured one.
+//
+// Deprecated: use NewSugaredLogger or NewLogger, returning the logger and the error building it.
+func (o *Options) SetupLogging(_ *zap.SugaredLogger) {
+	if _, err := o.NewLogger(); err != nil {
 		panic(err)
 	}
-
-	logger = l.Sugar().With("app", o.App, "version", version.Version())
-	o.logger = logger
 }
 
-// InitConfig reads in config file and ENV variables if set.
+// InitConfig reads in config file and ENV variables if set. Without --config the config file is
+// config.yaml in the ConfigDir, e.g. $XDG_CONFIG_HOME/hollow/config.yaml, or else the legacy
+// $HOME/.hollow.yaml, with any extension supported by viper.
 func (o *Options) InitConfig() {
 	if o.ConfigFile != "" {
 		// Use config file from the flag.
 		viper.SetConfigFile(o.ConfigFile)
 	} else {
-		// Find home directory.
-		home, err := homedir.Dir()
+		// Search config in the config directory, then in the home directory with name ".hollow" (without extension).
+		file, err := o.findConfigFile()
 		cobra.CheckErr(err)
 
-		// Search config in home directory with name ".hollow" (without extension).
-		viper.AddConfigPath(home)
-		viper.SetConfigName("." + o.App)
+		if file != "" {
+			viper.SetConfigFile(file)
+		}
 	}
 
 	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
@@ -0,0 +1,224 @@
+package rootcmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/spf13/viper"
+)
+
+// DefaultConcurrency is the number of items processed at once by RunParallel when no concurrency is set
+const DefaultConcurrency = 8
+
+const (
+	concurrencyConfigKey = "concurrency"
+
+	progressBarWidth = 40
+	// progressSteps is how many progress lines are written when the progress writer isn't a terminal
+	progressSteps = 10
+)
+
+// ParallelOption configures RunParallel
+type ParallelOption func(*parallelOptions)
+
+type parallelOptions struct {
+	progress   io.Writer
+	onProgress func(done, failed, total int)
+	failFast   bool
+}
+
+// WithProgress writes the progress to w, as a progress bar when w is a terminal and as
+// a line every tenth of the items otherwise
+func WithProgress(w io.Writer) ParallelOption {
+	return func(o *parallelOptions) {
+		o.progress = w
+	}
+}
+
+// WithProgressFunc calls fn each time an item was processed, with the number of items processed
+// and failed so far. Calls aren't concurrent.
+func WithProgressFunc(fn func(done, failed, total int)) ParallelOption {
+	return func(o *parallelOptions) {
+		o.onProgress = fn
+	}
+}
+
+// WithFailFast stops processing items once one failed, items not started yet are skipped
+func WithFailFast() ParallelOption {
+	return func(o *parallelOptions) {
+		o.failFast = true
+	}
+}
+
+// RunParallel calls fn for each of the items, with up to concurrency calls running at once.
+// When concurrency isn't positive the --concurrency flag value is used, see Concurrency.
+//
+// All the items are processed even when some fail, unless WithFailFast is set, and the errors
+// are returned together ordered by item. Once the context is done the items not started yet are
+// skipped and the context error is returned along with any other.
+func RunParallel[T any](ctx context.Context, items []T, concurrency int, fn func(ctx context.Context, item T) error, opts ...ParallelOption) error {
+	var o parallelOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	if concurrency <= 0 {
+		concurrency = Concurrency()
+	}
+
+	parent := ctx
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	progress := newProgressReporter(len(items), &o)
+	itemErrs := make([]error, len(items))
+	work := make(chan int)
+
+	var (
+		wg      sync.WaitGroup
+		skipped int32
+	)
+
+	for w := 0; w < concurrency && w < len(items); w++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for i := range work {
+				// dispatched while another item failed or the context was done
+				if ctx.Err() != nil {
+					atomic.StoreInt32(&skipped, 1)
+					continue
+				}
+
+				err := fn(ctx, items[i])
+				if err != nil {
+					itemErrs[i] = fmt.Errorf("item %d: %w", i, err)
+
+					if o.failFast {
+						cancel()
+					}
+				}
+
+				progress.done(err != nil)
+			}
+		}()
+	}
+
+	dispatched := 0
+
+	for dispatched < len(items) && ctx.Err() == nil {
+		select {
+		case work <- dispatched:
+			dispatched++
+		case <-ctx.Done():
+		}
+	}
+
+	close(work)
+	wg.Wait()
+	progress.finish()
+
+	var errs error
+
+	for _, err := range itemErrs {
+		if err != nil {
+			errs = multierror.Append(errs, err)
+		}
+	}
+
+	// items skipped because of an item failure are reported by the failure itself
+	if (dispatched < len(items) || atomic.LoadInt32(&skipped) == 1) && parent.Err() != nil {
+		errs = multierror.Append(errs, parent.Err())
+	}
+
+	return errs
+}
+
+// Concurrency returns the value of the --concurrency flag, DefaultConcurrency when it isn't set
+func Concurrency() int {
+	if c := viper.GetInt(concurrencyConfigKey); c > 0 {
+		return c
+	}
+
+	return DefaultConcurrency
+}
+
+// InitConcurrencyFlag adds the --concurrency flag used by RunParallel
+func (r *Root) InitConcurrencyFlag() {
+	r.Cmd.PersistentFlags().IntVar(&r.Options.Concurrency, "concurrency", DefaultConcurrency, "number of items processed at once")
+	r.ViperBindFlag(concurrencyConfigKey, "concurrency")
+}
+
+// progressReporter reports the progress of RunParallel
+type progressReporter struct {
+	mu       sync.Mutex
+	opts     *parallelOptions
+	terminal bool
+	total    int
+	finished int
+	failed   int
+	step     int
+}
+
+func newProgressReporter(total int, opts *parallelOptions) *progressReporter {
+	p := &progressReporter{opts: opts, total: total}
+
+	if f, ok := opts.progress.(*os.File); ok {
+		p.terminal = isTerminal(f)
+	}
+
+	return p
+}
+
+func (p *progressReporter) done(failed bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.finished++
+
+	if failed {
+		p.failed++
+	}
+
+	if p.opts.onProgress != nil {
+		p.opts.onProgress(p.finished, p.failed, p.total)
+	}
+
+	if p.opts.progress == nil {
+		return
+	}
+
+	if p.terminal {
+		fmt.Fprintf(p.opts.progress, "\r%s", p.bar())
+		return
+	}
+
+	// a line each time another tenth of the items was processed
+	if step := p.finished * progressSteps / p.total; step > p.step {
+		p.step = step
+		fmt.Fprintf(p.opts.progress, "processed %d/%d items (%d failed)\n", p.finished, p.total, p.failed)
+	}
+}
+
+// finish ends the progress bar line
+func (p *progressReporter) finish() {
+	if p.terminal && p.finished > 0 {
+		fmt.Fprintln(p.opts.progress)
+	}
+}
+
+func (p *progressReporter) bar() string {
+	filled := p.finished * progressBarWidth / p.total
+
+	return fmt.Sprintf("[%s%s] %d/%d (%d failed)",
+		strings.Repeat("=", filled), strings.Repeat(" ", progressBarWidth-filled), p.finished, p.total, p.failed)
+}
@@ -0,0 +1,175 @@
+package rootcmd_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.hollow.sh/toolbox/rootcmd"
+)
+
+var errItem = errors.New("item failed")
+
+func TestRunParallel(t *testing.T) {
+	items := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+
+	testCases := []struct {
+		name        string
+		concurrency int
+		failing     map[int]bool
+		failFast    bool
+		wantErrs    []string
+		// wantMaxProcessed bounds the items processed, fail fast skips the items not started yet
+		wantMaxProcessed int32
+	}{
+		{"all succeed", 3, nil, false, nil, 10},
+		{"default concurrency", 0, nil, false, nil, 10},
+		{"errors ordered by item", 4, map[int]bool{7: true, 2: true}, false, []string{"item 2: item failed", "item 7: item failed"}, 10},
+		{"fail fast skips the items not started", 1, map[int]bool{2: true}, true, []string{"item 2: item failed"}, 3},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			var running, maxRunning, processed int32
+
+			var opts []rootcmd.ParallelOption
+			if tt.failFast {
+				opts = append(opts, rootcmd.WithFailFast())
+			}
+
+			err := rootcmd.RunParallel(context.Background(), items, tt.concurrency, func(ctx context.Context, item int) error {
+				n := atomic.AddInt32(&running, 1)
+				defer atomic.AddInt32(&running, -1)
+
+				for {
+					m := atomic.LoadInt32(&maxRunning)
+					if n <= m || atomic.CompareAndSwapInt32(&maxRunning, m, n) {
+						break
+					}
+				}
+
+				atomic.AddInt32(&processed, 1)
+
+				time.Sleep(time.Millisecond)
+
+				if tt.failing[item] {
+					return errItem
+				}
+
+				return nil
+			}, opts...)
+
+			concurrency := tt.concurrency
+			if concurrency <= 0 {
+				concurrency = rootcmd.DefaultConcurrency
+			}
+
+			assert.LessOrEqual(t, atomic.LoadInt32(&maxRunning), int32(concurrency))
+			assert.LessOrEqual(t, atomic.LoadInt32(&processed), tt.wantMaxProcessed)
+
+			if tt.wantErrs == nil {
+				assert.NoError(t, err)
+				assert.Equal(t, int32(len(items)), atomic.LoadInt32(&processed))
+
+				return
+			}
+
+			var merr *multierror.Error
+			require.ErrorAs(t, err, &merr)
+			require.Len(t, merr.Errors, len(tt.wantErrs))
+
+			for i, want := range tt.wantErrs {
+				assert.EqualError(t, merr.Errors[i], want)
+				assert.ErrorIs(t, merr.Errors[i], errItem)
+			}
+		})
+	}
+}
+
+func TestRunParallelFailFastCancelsRunningItems(t *testing.T) {
+	started := make(chan struct{})
+
+	var canceled int32
+
+	err := rootcmd.RunParallel(context.Background(), []int{0, 1}, 2, func(ctx context.Context, item int) error {
+		if item == 0 {
+			// fails once the other item is running
+			<-started
+			return errItem
+		}
+
+		close(started)
+
+		select {
+		case <-ctx.Done():
+			atomic.AddInt32(&canceled, 1)
+			return ctx.Err()
+		case <-time.After(5 * time.Second):
+			return nil
+		}
+	}, rootcmd.WithFailFast())
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&canceled))
+	assert.ErrorIs(t, err, errItem)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRunParallelContextCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var processed int32
+
+	err := rootcmd.RunParallel(ctx, make([]int, 100), 1, func(ctx context.Context, _ int) error {
+		if atomic.AddInt32(&processed, 1) == 3 {
+			cancel()
+		}
+
+		return nil
+	})
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Less(t, atomic.LoadInt32(&processed), int32(100))
+}
+
+func TestRunParallelProgress(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls [][3]int
+		buf   bytes.Buffer
+	)
+
+	err := rootcmd.RunParallel(context.Background(), []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}, 1, func(_ context.Context, item int) error {
+		if item%5 == 0 {
+			return errItem
+		}
+
+		return nil
+	},
+		rootcmd.WithProgress(&buf),
+		rootcmd.WithProgressFunc(func(done, failed, total int) {
+			mu.Lock()
+			defer mu.Unlock()
+
+			calls = append(calls, [3]int{done, failed, total})
+		}),
+	)
+	require.Error(t, err)
+
+	require.Len(t, calls, 10)
+	assert.Equal(t, [3]int{1, 1, 10}, calls[0])
+	assert.Equal(t, [3]int{10, 2, 10}, calls[9])
+
+	// the writer isn't a terminal, a line is written every tenth of the items
+	assert.Contains(t, buf.String(), "processed 1/10 items (1 failed)\n")
+	assert.Contains(t, buf.String(), "processed 10/10 items (2 failed)\n")
+	assert.Equal(t, 10, bytes.Count(buf.Bytes(), []byte("\n")))
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"regexp"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
@@ -15,6 +17,14 @@ var alphaRegex *regexp.Regexp
 type Root struct {
 	Cmd     *cobra.Command
 	Options *Options
+
+	shutdownMu    sync.Mutex
+	shutdownHooks []namedShutdownHook
+
+	checksMu sync.Mutex
+	checks   []namedCheck
+
+	telemetry TelemetrySink
 }
 
 func init() {
@@ -43,7 +53,7 @@ func NewRootCmd(app, short string) *Root {
 
 // InitFlags are the 3 common flags for rootcmd
 func (r *Root) InitFlags() {
-	r.Cmd.PersistentFlags().StringVar(&r.Options.ConfigFile, "config", "", "config file (default is $HOME/."+r.Options.App+".yaml)")
+	r.Cmd.PersistentFlags().StringVar(&r.Options.ConfigFile, "config", "", "config file (default is $XDG_CONFIG_HOME/"+r.Options.App+"/config.yaml or $HOME/."+r.Options.App+".yaml)")
 
 	r.Cmd.PersistentFlags().BoolVar(&r.Options.Debug, "debug", false, "enable debug logging")
 	r.ViperBindFlag("logging.debug", "debug")
@@ -52,14 +62,29 @@ func (r *Root) InitFlags() {
 	r.ViperBindFlag("logging.pretty", "pretty")
 }
 
-// ViperBindFlag provides a wrapper around the viper bindings that handles error checks
+// ViperBindFlag provides a wrapper around the viper bindings that handles error checks,
+// the key is described in the ConfigSchema with the flag type, default and usage
 func (r *Root) ViperBindFlag(name, flag string) {
-	if err := viper.BindPFlag(name, r.Cmd.PersistentFlags().Lookup(flag)); err != nil {
+	f := r.Cmd.PersistentFlags().Lookup(flag)
+
+	if err := viper.BindPFlag(name, f); err != nil {
 		panic(err)
 	}
+
+	registerConfigFlag(name, f)
 }
 
-// Execute is a vanity wrapper on cobra.Command.Execute()
+// Execute is a vanity wrapper on cobra.Command.Execute(), it sends the telemetry of the
+// command run when enabled with EnableTelemetry
 func (r *Root) Execute() error {
-	return r.Cmd.Execute()
+	if r.telemetry == nil {
+		return r.Cmd.Execute()
+	}
+
+	start := time.Now()
+	cmd, err := r.Cmd.ExecuteC()
+
+	r.sendTelemetry(cmd, start, err)
+
+	return err
 }
@@ -0,0 +1,268 @@
+package rootcmd
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// JSONSchemaDraft is the JSON Schema version of the ConfigSchema
+const JSONSchemaDraft = "https://json-schema.org/draft/2020-12/schema"
+
+// JSONSchema is the subset of a JSON Schema describing the config keys
+type JSONSchema struct {
+	Schema      string                 `json:"$schema,omitempty"`
+	Title       string                 `json:"title,omitempty"`
+	Description string                 `json:"description,omitempty"`
+	Type        string                 `json:"type,omitempty"`
+	Default     interface{}            `json:"default,omitempty"`
+	Items       *JSONSchema            `json:"items,omitempty"`
+	Properties  map[string]*JSONSchema `json:"properties,omitempty"`
+}
+
+// configKey describes a config key registered with RegisterConfigKey or ViperBindFlag
+type configKey struct {
+	flag        *pflag.Flag
+	description string
+	def         interface{}
+}
+
+var configKeys = struct {
+	sync.Mutex
+	keys map[string]configKey
+}{keys: map[string]configKey{}}
+
+// RegisterConfigKey describes a config key for the ConfigSchema, for keys bound to viper without
+// ViperBindFlag, e.g. by other packages or with viper.SetDefault. The type is the one of the default.
+func RegisterConfigKey(key, description string, def interface{}) {
+	configKeys.Lock()
+	defer configKeys.Unlock()
+
+	configKeys.keys[strings.ToLower(key)] = configKey{description: description, def: def}
+}
+
+// registerConfigFlag describes the config key with the type, default and usage of the flag
+func registerConfigFlag(key string, flag *pflag.Flag) {
+	configKeys.Lock()
+	defer configKeys.Unlock()
+
+	configKeys.keys[strings.ToLower(key)] = configKey{flag: flag}
+}
+
+// ConfigSchema returns a JSON Schema of the config keys bound to viper, for deployment tooling such
+// as Helm charts. The keys bound with ViperBindFlag have the type, default and usage of their flag,
+// those described with RegisterConfigKey the given description and default. The other keys viper
+// knows of only have the type of their current value.
+//
+// Nested keys, e.g. logging.debug, are properties of objects.
+func ConfigSchema() *JSONSchema {
+	configKeys.Lock()
+
+	keys := make(map[string]*JSONSchema, len(configKeys.keys))
+
+	for key, ck := range configKeys.keys {
+		if ck.flag != nil {
+			keys[key] = flagSchema(ck.flag)
+		} else {
+			keys[key] = valueSchema(ck.def, ck.description)
+		}
+	}
+
+	configKeys.Unlock()
+
+	for _, key := range viper.AllKeys() {
+		if _, ok := keys[key]; !ok && !hasRegisteredParent(keys, key) {
+			keys[key] = &JSONSchema{Type: jsonType(viper.Get(key))}
+		}
+	}
+
+	names := make([]string, 0, len(keys))
+	for key := range keys {
+		names = append(names, key)
+	}
+
+	sort.Strings(names)
+
+	root := &JSONSchema{Schema: JSONSchemaDraft, Type: "object", Properties: map[string]*JSONSchema{}}
+
+	for _, key := range names {
+		parent := root
+		path := strings.Split(key, ".")
+
+		for _, name := range path[:len(path)-1] {
+			child, ok := parent.Properties[name]
+			if !ok || child.Type != "object" {
+				child = &JSONSchema{Type: "object", Properties: map[string]*JSONSchema{}}
+				parent.Properties[name] = child
+			}
+
+			if child.Properties == nil {
+				child.Properties = map[string]*JSONSchema{}
+			}
+
+			parent = child
+		}
+
+		if _, ok := parent.Properties[path[len(path)-1]]; !ok {
+			parent.Properties[path[len(path)-1]] = keys[key]
+		}
+	}
+
+	return root
+}
+
+// hasRegisteredParent returns true when a registered key holds the key, e.g. a map of settings
+func hasRegisteredParent(keys map[string]*JSONSchema, key string) bool {
+	for i := strings.LastIndex(key, "."); i > 0; i = strings.LastIndex(key[:i], ".") {
+		if _, ok := keys[key[:i]]; ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// flagSchema returns the schema of the values of the flag
+func flagSchema(flag *pflag.Flag) *JSONSchema {
+	s := &JSONSchema{Description: flag.Usage}
+	typ := flag.Value.Type()
+
+	switch {
+	case typ == "bool":
+		s.Type = "boolean"
+
+		if def, err := strconv.ParseBool(flag.DefValue); err == nil {
+			s.Default = def
+		}
+	case strings.HasPrefix(typ, "int") || strings.HasPrefix(typ, "uint") || typ == "count":
+		if strings.HasSuffix(typ, "Slice") {
+			s.Type = "array"
+			s.Items = &JSONSchema{Type: "integer"}
+			s.Default = sliceDefault(flag.DefValue, func(v string) (interface{}, error) { return strconv.ParseInt(v, 10, 64) })
+
+			break
+		}
+
+		s.Type = "integer"
+
+		if def, err := strconv.ParseInt(flag.DefValue, 10, 64); err == nil {
+			s.Default = def
+		}
+	case strings.HasPrefix(typ, "float"):
+		s.Type = "number"
+
+		if def, err := strconv.ParseFloat(flag.DefValue, 64); err == nil {
+			s.Default = def
+		}
+	case typ == "stringSlice" || typ == "stringArray":
+		s.Type = "array"
+		s.Items = &JSONSchema{Type: "string"}
+		s.Default = sliceDefault(flag.DefValue, func(v string) (interface{}, error) { return v, nil })
+	case strings.HasPrefix(typ, "stringTo"):
+		s.Type = "object"
+	default:
+		// strings, durations and the other values parsed from text
+		s.Type = "string"
+
+		if flag.DefValue != "" {
+			s.Default = flag.DefValue
+		}
+	}
+
+	return s
+}
+
+// sliceDefault parses the default of a slice flag, formatted as [a,b]
+func sliceDefault(def string, parse func(string) (interface{}, error)) interface{} {
+	def = strings.TrimSuffix(strings.TrimPrefix(def, "["), "]")
+	if def == "" {
+		return nil
+	}
+
+	values := []interface{}{}
+
+	for _, v := range strings.Split(def, ",") {
+		parsed, err := parse(v)
+		if err != nil {
+			return nil
+		}
+
+		values = append(values, parsed)
+	}
+
+	return values
+}
+
+// valueSchema returns the schema of a key described with RegisterConfigKey
+func valueSchema(def interface{}, description string) *JSONSchema {
+	s := &JSONSchema{Type: jsonType(def), Description: description, Default: def}
+
+	if s.Type == "array" {
+		if v := reflect.ValueOf(def); v.Len() > 0 {
+			s.Items = &JSONSchema{Type: jsonType(v.Index(0).Interface())}
+		}
+	}
+
+	return s
+}
+
+// jsonType returns the JSON Schema type of the value, empty when it is nil
+func jsonType(v interface{}) string {
+	if v == nil {
+		return ""
+	}
+
+	switch reflect.TypeOf(v).Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		// time.Duration values are configured as strings, e.g. 30s
+		if reflect.TypeOf(v).String() == "time.Duration" {
+			return "string"
+		}
+
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array"
+	case reflect.Map, reflect.Struct:
+		return "object"
+	default:
+		return "string"
+	}
+}
+
+// AddConfigCommand adds the config subcommand, with the schema subcommand writing the ConfigSchema
+// of the app as JSON on stdout, e.g. for Helm charts to validate their values.
+func AddConfigCommand(root *Root) {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration of the app",
+	}
+
+	cmd.AddCommand(&cobra.Command{
+		Use:   "schema",
+		Short: "Write the JSON Schema of the config keys",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, _ []string) error {
+			schema := ConfigSchema()
+			schema.Title = root.Options.App
+
+			enc := json.NewEncoder(cmd.OutOrStdout())
+			enc.SetIndent("", "  ")
+
+			return enc.Encode(schema)
+		},
+	})
+
+	root.Cmd.AddCommand(cmd)
+}
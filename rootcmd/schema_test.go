@@ -0,0 +1,107 @@
+package rootcmd_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.hollow.sh/toolbox/rootcmd"
+)
+
+func TestConfigSchema(t *testing.T) {
+	setTestHome(t)
+
+	root := rootcmd.NewRootCmd("hollow", "hollow test")
+	root.InitFlags()
+
+	flags := root.Cmd.PersistentFlags()
+	flags.Int("schema-workers", 4, "number of workers")
+	flags.Float64("schema-ratio", 0.5, "sampling ratio")
+	flags.Duration("schema-timeout", 30*time.Second, "request timeout")
+	flags.StringSlice("schema-subjects", []string{"a", "b"}, "subjects to subscribe to")
+	flags.IntSlice("schema-ports", nil, "ports to listen on")
+	flags.StringToString("schema-labels", nil, "labels")
+	flags.String("schema-name", "", "name of the instance")
+
+	root.ViperBindFlag("schema.workers", "schema-workers")
+	root.ViperBindFlag("schema.ratio", "schema-ratio")
+	root.ViperBindFlag("schema.timeout", "schema-timeout")
+	root.ViperBindFlag("schema.subjects", "schema-subjects")
+	root.ViperBindFlag("schema.ports", "schema-ports")
+	root.ViperBindFlag("schema.labels", "schema-labels")
+	root.ViperBindFlag("schema.nested.name", "schema-name")
+
+	rootcmd.RegisterConfigKey("schema.tags", "tags of the instance", []string{"edge"})
+	rootcmd.RegisterConfigKey("schema.Settings", "app settings", map[string]interface{}{})
+
+	viper.Set("schema.unregistered", true)
+	viper.Set("schema.settings.key", "value")
+
+	schema := rootcmd.ConfigSchema()
+
+	assert.Equal(t, rootcmd.JSONSchemaDraft, schema.Schema)
+	assert.Equal(t, "object", schema.Type)
+
+	// the flags of InitFlags
+	logging := schema.Properties["logging"]
+	require.NotNil(t, logging)
+	assert.Equal(t, &rootcmd.JSONSchema{Type: "boolean", Description: "enable debug logging", Default: false}, logging.Properties["debug"])
+
+	s := schema.Properties["schema"]
+	require.NotNil(t, s)
+	assert.Equal(t, "object", s.Type)
+
+	testCases := []struct {
+		key  string
+		want *rootcmd.JSONSchema
+	}{
+		{"workers", &rootcmd.JSONSchema{Type: "integer", Description: "number of workers", Default: int64(4)}},
+		{"ratio", &rootcmd.JSONSchema{Type: "number", Description: "sampling ratio", Default: 0.5}},
+		{"timeout", &rootcmd.JSONSchema{Type: "string", Description: "request timeout", Default: "30s"}},
+		{"subjects", &rootcmd.JSONSchema{Type: "array", Description: "subjects to subscribe to", Items: &rootcmd.JSONSchema{Type: "string"}, Default: []interface{}{"a", "b"}}},
+		{"ports", &rootcmd.JSONSchema{Type: "array", Description: "ports to listen on", Items: &rootcmd.JSONSchema{Type: "integer"}}},
+		{"labels", &rootcmd.JSONSchema{Type: "object", Description: "labels"}},
+		{"tags", &rootcmd.JSONSchema{Type: "array", Description: "tags of the instance", Items: &rootcmd.JSONSchema{Type: "string"}, Default: []string{"edge"}}},
+		// the keys held by a registered key aren't properties of it
+		{"settings", &rootcmd.JSONSchema{Type: "object", Description: "app settings", Default: map[string]interface{}{}}},
+		{"unregistered", &rootcmd.JSONSchema{Type: "boolean"}},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.key, func(t *testing.T) {
+			assert.Equal(t, tt.want, s.Properties[tt.key])
+		})
+	}
+
+	// nested keys are properties of objects
+	nested := s.Properties["nested"]
+	require.NotNil(t, nested)
+	assert.Equal(t, "object", nested.Type)
+	assert.Equal(t, &rootcmd.JSONSchema{Type: "string", Description: "name of the instance"}, nested.Properties["name"])
+}
+
+func TestConfigSchemaCommand(t *testing.T) {
+	setTestHome(t)
+
+	root := rootcmd.NewRootCmd("hollow", "hollow test")
+	root.InitFlags()
+	rootcmd.AddConfigCommand(root)
+
+	var out bytes.Buffer
+
+	root.Cmd.SetOut(&out)
+	root.Cmd.SetArgs([]string{"config", "schema"})
+	require.NoError(t, root.Execute())
+
+	var schema map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &schema))
+
+	assert.Equal(t, rootcmd.JSONSchemaDraft, schema["$schema"])
+	assert.Equal(t, "hollow", schema["title"])
+	assert.Contains(t, schema["properties"], "logging")
+}
@@ -0,0 +1,72 @@
+package rootcmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/pkg/errors"
+)
+
+// DefaultShutdownGracePeriod is how long shutdown hooks are given when no grace period is set
+const DefaultShutdownGracePeriod = 30 * time.Second
+
+// ShutdownHook is run when the process shuts down, it should return once the context is done
+type ShutdownHook func(ctx context.Context) error
+
+type namedShutdownHook struct {
+	name string
+	hook ShutdownHook
+}
+
+// OnShutdown registers a hook run on shutdown, hooks are run in the reverse order they were registered
+func (r *Root) OnShutdown(name string, hook ShutdownHook) {
+	r.shutdownMu.Lock()
+	defer r.shutdownMu.Unlock()
+
+	r.shutdownHooks = append(r.shutdownHooks, namedShutdownHook{name: name, hook: hook})
+}
+
+// WaitForShutdown blocks until the process receives SIGINT or SIGTERM, or the context is done,
+// then runs the shutdown hooks
+func (r *Root) WaitForShutdown(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	<-ctx.Done()
+
+	return r.Shutdown()
+}
+
+// Shutdown runs the shutdown hooks, together they are given the Options.ShutdownGracePeriod to return
+func (r *Root) Shutdown() error {
+	r.shutdownMu.Lock()
+	hooks := r.shutdownHooks
+	r.shutdownHooks = nil
+	r.shutdownMu.Unlock()
+
+	grace := r.Options.ShutdownGracePeriod
+	if grace == 0 {
+		grace = DefaultShutdownGracePeriod
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), grace)
+	defer cancel()
+
+	var errs error
+
+	for i := len(hooks) - 1; i >= 0; i-- {
+		if logger := r.Options.GetLogger(); logger != nil {
+			logger.Infow("running shutdown hook", "hook", hooks[i].name)
+		}
+
+		if err := hooks[i].hook(ctx); err != nil {
+			errs = multierror.Append(errs, errors.Wrap(err, "shutdown hook "+hooks[i].name))
+		}
+	}
+
+	return errs
+}
@@ -0,0 +1,171 @@
+package rootcmd_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-multierror"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.hollow.sh/toolbox/rootcmd"
+)
+
+func TestShutdownOrder(t *testing.T) {
+	root := rootcmd.NewRootCmd("hollow", "hollow test")
+
+	var ran []string
+
+	for _, name := range []string{"db", "events", "server"} {
+		name := name
+
+		root.OnShutdown(name, func(ctx context.Context) error {
+			ran = append(ran, name)
+			return nil
+		})
+	}
+
+	require.NoError(t, root.Shutdown())
+	assert.Equal(t, []string{"server", "events", "db"}, ran)
+
+	// the hooks are only run once
+	require.NoError(t, root.Shutdown())
+	assert.Len(t, ran, 3)
+}
+
+func TestShutdownGracePeriod(t *testing.T) {
+	testCases := []struct {
+		name  string
+		grace time.Duration
+		want  time.Duration
+	}{
+		{"default", 0, rootcmd.DefaultShutdownGracePeriod},
+		{"set", time.Minute, time.Minute},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			root := rootcmd.NewRootCmd("hollow", "hollow test")
+			root.Options.ShutdownGracePeriod = tt.grace
+
+			var deadline time.Time
+
+			root.OnShutdown("server", func(ctx context.Context) error {
+				var ok bool
+
+				deadline, ok = ctx.Deadline()
+				assert.True(t, ok)
+
+				return nil
+			})
+
+			start := time.Now()
+
+			require.NoError(t, root.Shutdown())
+			assert.WithinDuration(t, start.Add(tt.want), deadline, time.Second)
+		})
+	}
+
+	t.Run("timeout", func(t *testing.T) {
+		root := rootcmd.NewRootCmd("hollow", "hollow test")
+		root.Options.ShutdownGracePeriod = 10 * time.Millisecond
+
+		// the hooks share the grace period
+		root.OnShutdown("events", func(ctx context.Context) error {
+			return ctx.Err()
+		})
+
+		root.OnShutdown("server", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		err := root.Shutdown()
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+		var merr *multierror.Error
+		require.ErrorAs(t, err, &merr)
+		assert.Len(t, merr.Errors, 2)
+	})
+}
+
+func TestShutdownErrors(t *testing.T) {
+	errServer := errors.New("server didn't stop")
+	errEvents := errors.New("events didn't drain")
+
+	root := rootcmd.NewRootCmd("hollow", "hollow test")
+
+	var ran []string
+
+	root.OnShutdown("events", func(ctx context.Context) error {
+		ran = append(ran, "events")
+		return errEvents
+	})
+
+	root.OnShutdown("db", func(ctx context.Context) error {
+		ran = append(ran, "db")
+		return nil
+	})
+
+	root.OnShutdown("server", func(ctx context.Context) error {
+		ran = append(ran, "server")
+		return errServer
+	})
+
+	err := root.Shutdown()
+
+	// a failing hook doesn't stop the others
+	assert.Equal(t, []string{"server", "db", "events"}, ran)
+
+	var merr *multierror.Error
+	require.ErrorAs(t, err, &merr)
+	require.Len(t, merr.Errors, 2)
+
+	assert.ErrorIs(t, merr.Errors[0], errServer)
+	assert.Contains(t, merr.Errors[0].Error(), "shutdown hook server")
+	assert.ErrorIs(t, merr.Errors[1], errEvents)
+	assert.Contains(t, merr.Errors[1].Error(), "shutdown hook events")
+}
+
+func TestWaitForShutdown(t *testing.T) {
+	root := rootcmd.NewRootCmd("hollow", "hollow test")
+
+	ran := make(chan struct{})
+
+	root.OnShutdown("server", func(ctx context.Context) error {
+		close(ran)
+		return nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	done := make(chan error, 1)
+
+	go func() {
+		done <- root.WaitForShutdown(ctx)
+	}()
+
+	// the hooks aren't run until the context is done
+	select {
+	case <-ran:
+		t.Fatal("shutdown hook ran before the context was done")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	cancel()
+
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("WaitForShutdown didn't return")
+	}
+
+	select {
+	case <-ran:
+	default:
+		t.Fatal("shutdown hook didn't run")
+	}
+}
@@ -0,0 +1,170 @@
+package rootcmd
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"runtime"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"go.hollow.sh/toolbox/version"
+)
+
+const (
+	// DefaultTelemetryTimeout is how long sending the telemetry of an invocation may delay the exit
+	DefaultTelemetryTimeout = 2 * time.Second
+
+	noTelemetryConfigKey = "no_telemetry"
+)
+
+// TelemetryEvent is the anonymous usage record of a command invocation. Argument and flag values
+// are never recorded, only the names of the flags set and the number of arguments.
+type TelemetryEvent struct {
+	App     string `json:"app"`
+	Version string `json:"version"`
+	// Command is the path of the command invoked, e.g. "hollow server list"
+	Command string `json:"command"`
+	// Flags are the names of the flags set
+	Flags []string `json:"flags,omitempty"`
+	// Args is the number of positional arguments
+	Args       int           `json:"args"`
+	Start      time.Time     `json:"start"`
+	Duration   time.Duration `json:"duration_ns"`
+	ExitStatus int           `json:"exit_status"`
+	OS         string        `json:"os"`
+	Arch       string        `json:"arch"`
+}
+
+// TelemetrySink sends the telemetry events, e.g. to a NATS subject or an OTLP collector
+type TelemetrySink interface {
+	Send(ctx context.Context, event TelemetryEvent) error
+}
+
+// TelemetrySinkFunc is a function implementing TelemetrySink
+type TelemetrySinkFunc func(ctx context.Context, event TelemetryEvent) error
+
+// Send calls the function
+func (f TelemetrySinkFunc) Send(ctx context.Context, event TelemetryEvent) error {
+	return f(ctx, event)
+}
+
+// EnableTelemetry opts the app in to sending a TelemetryEvent for each command run with Execute,
+// and adds the --no-telemetry flag opting out of it. Telemetry is also disabled with no_telemetry
+// in the config, the <APP>_NO_TELEMETRY environment variable or DO_NOT_TRACK=1.
+func (r *Root) EnableTelemetry(sink TelemetrySink) {
+	r.telemetry = sink
+
+	r.Cmd.PersistentFlags().BoolVar(&r.Options.NoTelemetry, "no-telemetry", false, "don't send anonymous usage telemetry")
+	r.ViperBindFlag(noTelemetryConfigKey, "no-telemetry")
+}
+
+// telemetryDisabled returns true when the user opted out of the telemetry
+func telemetryDisabled() bool {
+	if dnt := os.Getenv("DO_NOT_TRACK"); dnt != "" && dnt != "0" {
+		return true
+	}
+
+	return viper.GetBool(noTelemetryConfigKey)
+}
+
+// sendTelemetry sends the event of the invocation of cmd, failures are only logged at debug level
+// so telemetry never fails a command.
+func (r *Root) sendTelemetry(cmd *cobra.Command, start time.Time, err error) {
+	if r.telemetry == nil || cmd == nil || telemetryDisabled() {
+		return
+	}
+
+	event := TelemetryEvent{
+		App:        r.Options.App,
+		Version:    version.Version(),
+		Command:    cmd.CommandPath(),
+		Args:       len(cmd.Flags().Args()),
+		Start:      start,
+		Duration:   time.Since(start),
+		ExitStatus: exitStatus(err),
+		OS:         runtime.GOOS,
+		Arch:       runtime.GOARCH,
+	}
+
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		event.Flags = append(event.Flags, f.Name)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), DefaultTelemetryTimeout)
+	defer cancel()
+
+	if err := r.telemetry.Send(ctx, event); err != nil {
+		if logger := r.Options.GetLogger(); logger != nil {
+			logger.Debugw("telemetry not sent", "error", err)
+		}
+	}
+}
+
+// exitStatus returns the status the process exits with for the error returned by the command,
+// errors with an ExitCode method, such as *exec.ExitError, set their own status
+func exitStatus(err error) int {
+	if err == nil {
+		return 0
+	}
+
+	if e, ok := err.(interface{ ExitCode() int }); ok { //nolint:errorlint // the status of the error returned
+		return e.ExitCode()
+	}
+
+	return 1
+}
+
+// NatsTelemetrySink returns a TelemetrySink publishing the events as JSON on the subject,
+// the connection is flushed so the event is sent before the process exits
+func NatsTelemetrySink(conn *nats.Conn, subject string) TelemetrySink {
+	return TelemetrySinkFunc(func(ctx context.Context, event TelemetryEvent) error {
+		data, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+
+		if err := conn.Publish(subject, data); err != nil {
+			return err
+		}
+
+		return conn.FlushWithContext(ctx)
+	})
+}
+
+// OTelTelemetrySink returns a TelemetrySink recording the events as spans of the tracer, they are
+// exported by its provider, e.g. to an OTLP collector. The provider must be shut down, flushing the
+// spans, before the process exits.
+func OTelTelemetrySink(tracer trace.Tracer) TelemetrySink {
+	return TelemetrySinkFunc(func(ctx context.Context, event TelemetryEvent) error {
+		_, span := tracer.Start(ctx, event.Command,
+			trace.WithNewRoot(),
+			trace.WithTimestamp(event.Start),
+			trace.WithAttributes(
+				attribute.String("cli.app", event.App),
+				attribute.String("cli.version", event.Version),
+				attribute.String("cli.command", event.Command),
+				attribute.StringSlice("cli.flags", event.Flags),
+				attribute.Int("cli.args", event.Args),
+				attribute.Int("cli.exit_status", event.ExitStatus),
+				attribute.String("os.type", event.OS),
+				attribute.String("host.arch", event.Arch),
+			),
+		)
+
+		if event.ExitStatus != 0 {
+			span.SetStatus(codes.Error, "command failed")
+		}
+
+		span.End(trace.WithTimestamp(event.Start.Add(event.Duration)))
+
+		return nil
+	})
+}
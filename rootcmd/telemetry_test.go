@@ -0,0 +1,204 @@
+package rootcmd_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"runtime"
+	"testing"
+	"time"
+
+	srvtest "github.com/nats-io/nats-server/v2/test"
+	"github.com/nats-io/nats.go"
+	"github.com/spf13/cobra"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"go.hollow.sh/toolbox/rootcmd"
+	"go.hollow.sh/toolbox/version"
+)
+
+type exitError int
+
+func (e exitError) Error() string { return "exited" }
+
+func (e exitError) ExitCode() int { return int(e) }
+
+// newTelemetryTestRoot returns a root with a delete subcommand returning runErr, the events sent are
+// appended to events
+func newTelemetryTestRoot(t *testing.T, runErr error, events *[]rootcmd.TelemetryEvent) *rootcmd.Root {
+	t.Helper()
+
+	setTestHome(t)
+
+	root := rootcmd.NewRootCmd("hollow", "hollow test")
+	root.InitFlags()
+
+	root.EnableTelemetry(rootcmd.TelemetrySinkFunc(func(ctx context.Context, event rootcmd.TelemetryEvent) error {
+		*events = append(*events, event)
+		return errors.New("sink unavailable")
+	}))
+
+	cmd := &cobra.Command{
+		Use: "delete",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runErr
+		},
+	}
+	cmd.Flags().Bool("force", false, "")
+	cmd.Flags().String("reason", "", "")
+
+	root.Cmd.AddCommand(cmd)
+	root.Cmd.SilenceErrors = true
+	root.Cmd.SilenceUsage = true
+
+	root.Options.InitConfig()
+
+	return root
+}
+
+func TestTelemetry(t *testing.T) {
+	testCases := []struct {
+		name       string
+		runErr     error
+		wantStatus int
+	}{
+		{"success", nil, 0},
+		{"failure", errors.New("not found"), 1},
+		{"exit code", exitError(3), 3},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DO_NOT_TRACK", "")
+
+			var events []rootcmd.TelemetryEvent
+
+			root := newTelemetryTestRoot(t, tt.runErr, &events)
+			root.Cmd.SetArgs([]string{"delete", "s1", "s2", "--force", "--reason", "secret reason"})
+
+			start := time.Now()
+
+			err := root.Execute()
+			assert.ErrorIs(t, err, tt.runErr)
+
+			require.Len(t, events, 1)
+
+			event := events[0]
+
+			// the flag and argument values aren't recorded
+			assert.Equal(t, "hollow", event.App)
+			assert.Equal(t, version.Version(), event.Version)
+			assert.Equal(t, "hollow delete", event.Command)
+			assert.ElementsMatch(t, []string{"force", "reason"}, event.Flags)
+			assert.Equal(t, 2, event.Args)
+			assert.Equal(t, tt.wantStatus, event.ExitStatus)
+			assert.Equal(t, runtime.GOOS, event.OS)
+			assert.Equal(t, runtime.GOARCH, event.Arch)
+			assert.WithinDuration(t, start, event.Start, time.Second)
+
+			data, err := json.Marshal(event)
+			require.NoError(t, err)
+			assert.NotContains(t, string(data), "secret reason")
+			assert.NotContains(t, string(data), "s1")
+		})
+	}
+}
+
+func TestTelemetryOptOut(t *testing.T) {
+	testCases := []struct {
+		name string
+		args []string
+		env  map[string]string
+		want int
+	}{
+		{"enabled", nil, nil, 1},
+		{"flag", []string{"--no-telemetry"}, nil, 0},
+		{"environment", nil, map[string]string{"HOLLOW_NO_TELEMETRY": "true"}, 0},
+		{"do not track", nil, map[string]string{"DO_NOT_TRACK": "1"}, 0},
+		{"do not track 0", nil, map[string]string{"DO_NOT_TRACK": "0"}, 1},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("DO_NOT_TRACK", "")
+
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			var events []rootcmd.TelemetryEvent
+
+			root := newTelemetryTestRoot(t, nil, &events)
+			root.Cmd.SetArgs(append([]string{"delete"}, tt.args...))
+
+			require.NoError(t, root.Execute())
+			assert.Len(t, events, tt.want)
+		})
+	}
+}
+
+func TestNatsTelemetrySink(t *testing.T) {
+	opts := srvtest.DefaultTestOptions
+	opts.Port = -1
+
+	srv := srvtest.RunServer(&opts)
+	defer srv.Shutdown()
+
+	nc, err := nats.Connect(srv.ClientURL())
+	require.NoError(t, err)
+
+	defer nc.Close()
+
+	sub, err := nc.SubscribeSync("hollow.telemetry")
+	require.NoError(t, err)
+
+	event := rootcmd.TelemetryEvent{App: "hollow", Command: "hollow delete", Flags: []string{"force"}, ExitStatus: 1}
+
+	ctx, cancel := context.WithTimeout(context.Background(), rootcmd.DefaultTelemetryTimeout)
+	defer cancel()
+
+	require.NoError(t, rootcmd.NatsTelemetrySink(nc, "hollow.telemetry").Send(ctx, event))
+
+	msg, err := sub.NextMsg(time.Second)
+	require.NoError(t, err)
+
+	var got rootcmd.TelemetryEvent
+	require.NoError(t, json.Unmarshal(msg.Data, &got))
+	assert.Equal(t, event, got)
+}
+
+func TestOTelTelemetrySink(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	start := time.Now().Add(-time.Second)
+
+	sink := rootcmd.OTelTelemetrySink(provider.Tracer("telemetry"))
+
+	require.NoError(t, sink.Send(context.Background(), rootcmd.TelemetryEvent{
+		App:        "hollow",
+		Command:    "hollow delete",
+		Flags:      []string{"force"},
+		Args:       1,
+		Start:      start,
+		Duration:   500 * time.Millisecond,
+		ExitStatus: 2,
+	}))
+
+	spans := recorder.Ended()
+	require.Len(t, spans, 1)
+
+	span := spans[0]
+
+	assert.Equal(t, "hollow delete", span.Name())
+	assert.True(t, span.StartTime().Equal(start))
+	assert.True(t, span.EndTime().Equal(start.Add(500*time.Millisecond)))
+	assert.Equal(t, codes.Error, span.Status().Code)
+	assert.Contains(t, span.Attributes(), attribute.StringSlice("cli.flags", []string{"force"}))
+	assert.Contains(t, span.Attributes(), attribute.Int("cli.exit_status", 2))
+}
@@ -0,0 +1,91 @@
+package rootcmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+)
+
+// xdgConfigName is the name of the config file in the config directory, without extension
+const xdgConfigName = "config"
+
+// ConfigDir returns the directory of the config files of the app, $XDG_CONFIG_HOME/<app> or
+// $HOME/.config/<app> when unset, following the XDG base directory specification. The directory
+// may not exist, it is to be created with os.MkdirAll before writing to it.
+func ConfigDir(app string) (string, error) {
+	return xdgDir("XDG_CONFIG_HOME", app, ".config")
+}
+
+// CacheDir returns the directory of the cached files of the app, $XDG_CACHE_HOME/<app> or
+// $HOME/.cache/<app> when unset. The files may be deleted by the user at any time.
+func CacheDir(app string) (string, error) {
+	return xdgDir("XDG_CACHE_HOME", app, ".cache")
+}
+
+// DataDir returns the directory of the data files of the app, $XDG_DATA_HOME/<app> or
+// $HOME/.local/share/<app> when unset.
+func DataDir(app string) (string, error) {
+	return xdgDir("XDG_DATA_HOME", app, ".local", "share")
+}
+
+// xdgDir returns the app directory in the base directory set by env, relative paths are invalid
+// according to the specification and ignored for the default in the home directory.
+func xdgDir(env, app string, defaultBase ...string) (string, error) {
+	if base := os.Getenv(env); base != "" && filepath.IsAbs(base) {
+		return filepath.Join(base, app), nil
+	}
+
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(append(append([]string{home}, defaultBase...), app)...), nil
+}
+
+// ConfigDir returns the config directory of the app, see ConfigDir
+func (o *Options) ConfigDir() (string, error) {
+	return ConfigDir(o.App)
+}
+
+// CacheDir returns the cache directory of the app, see CacheDir
+func (o *Options) CacheDir() (string, error) {
+	return CacheDir(o.App)
+}
+
+// DataDir returns the data directory of the app, see DataDir
+func (o *Options) DataDir() (string, error) {
+	return DataDir(o.App)
+}
+
+// findConfigFile returns the config file used when --config isn't set, config.<ext> in the
+// config directory or else the legacy $HOME/.<app>.<ext>, with any of the extensions supported
+// by viper. An empty string is returned when neither exist.
+func (o *Options) findConfigFile() (string, error) {
+	var candidates []string
+
+	if dir, err := o.ConfigDir(); err == nil {
+		candidates = append(candidates, filepath.Join(dir, xdgConfigName))
+	}
+
+	home, err := homedir.Dir()
+	if err != nil {
+		return "", err
+	}
+
+	candidates = append(candidates, filepath.Join(home, "."+o.App))
+
+	for _, candidate := range candidates {
+		for _, ext := range viper.SupportedExts {
+			file := candidate + "." + ext
+
+			if fi, err := os.Stat(file); err == nil && !fi.IsDir() {
+				return file, nil
+			}
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,145 @@
+package rootcmd_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"go.hollow.sh/toolbox/rootcmd"
+)
+
+// setTestHome points $HOME to a temp dir, unsets the XDG base directories and resets the global viper
+func setTestHome(t *testing.T) string {
+	t.Helper()
+
+	home := t.TempDir()
+
+	t.Setenv("HOME", home)
+	t.Setenv("XDG_CONFIG_HOME", "")
+	t.Setenv("XDG_CACHE_HOME", "")
+	t.Setenv("XDG_DATA_HOME", "")
+
+	// homedir caches $HOME
+	homedir.DisableCache = true
+
+	viper.Reset()
+
+	t.Cleanup(func() {
+		homedir.DisableCache = false
+		homedir.Reset()
+		viper.Reset()
+	})
+
+	return home
+}
+
+func TestXDGDirs(t *testing.T) {
+	testCases := []struct {
+		name    string
+		env     string
+		dir     func(string) (string, error)
+		options func(*rootcmd.Options) (string, error)
+		unset   string
+	}{
+		{"config", "XDG_CONFIG_HOME", rootcmd.ConfigDir, (*rootcmd.Options).ConfigDir, ".config/hollow"},
+		{"cache", "XDG_CACHE_HOME", rootcmd.CacheDir, (*rootcmd.Options).CacheDir, ".cache/hollow"},
+		{"data", "XDG_DATA_HOME", rootcmd.DataDir, (*rootcmd.Options).DataDir, ".local/share/hollow"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			home := setTestHome(t)
+			o := &rootcmd.Options{App: "hollow"}
+
+			// unset, the default in the home directory
+			dir, err := tt.dir("hollow")
+			require.NoError(t, err)
+			assert.Equal(t, filepath.Join(home, tt.unset), dir)
+
+			// relative paths are ignored
+			t.Setenv(tt.env, "relative/dir")
+
+			dir, err = tt.dir("hollow")
+			require.NoError(t, err)
+			assert.Equal(t, filepath.Join(home, tt.unset), dir)
+
+			base := t.TempDir()
+			t.Setenv(tt.env, base)
+
+			dir, err = tt.dir("hollow")
+			require.NoError(t, err)
+			assert.Equal(t, filepath.Join(base, "hollow"), dir)
+
+			dir, err = tt.options(o)
+			require.NoError(t, err)
+			assert.Equal(t, filepath.Join(base, "hollow"), dir)
+		})
+	}
+}
+
+func TestInitConfigLookup(t *testing.T) {
+	writeConfig := func(t *testing.T, file string) {
+		t.Helper()
+
+		require.NoError(t, os.MkdirAll(filepath.Dir(file), 0o755))
+		require.NoError(t, os.WriteFile(file, []byte("server: "+file+"\n"), 0o600))
+	}
+
+	testCases := []struct {
+		name  string
+		files []string
+		want  string
+	}{
+		{"none", nil, ""},
+		{"legacy home file", []string{".hollow.yaml"}, ".hollow.yaml"},
+		{"config dir", []string{".config/hollow/config.yaml"}, ".config/hollow/config.yaml"},
+		{"config dir first", []string{".hollow.yaml", ".config/hollow/config.yml"}, ".config/hollow/config.yml"},
+	}
+
+	for _, tt := range testCases {
+		t.Run(tt.name, func(t *testing.T) {
+			home := setTestHome(t)
+
+			for _, f := range tt.files {
+				writeConfig(t, filepath.Join(home, f))
+			}
+
+			o := &rootcmd.Options{App: "hollow"}
+			_, err := o.NewLogger()
+			require.NoError(t, err)
+
+			o.InitConfig()
+
+			if tt.want == "" {
+				assert.Empty(t, viper.ConfigFileUsed())
+				return
+			}
+
+			want := filepath.Join(home, tt.want)
+			assert.Equal(t, want, viper.ConfigFileUsed())
+			assert.Equal(t, want, viper.GetString("server"))
+		})
+	}
+
+	t.Run("XDG_CONFIG_HOME", func(t *testing.T) {
+		home := setTestHome(t)
+		base := t.TempDir()
+		t.Setenv("XDG_CONFIG_HOME", base)
+
+		writeConfig(t, filepath.Join(home, ".config", "hollow", "config.yaml"))
+		writeConfig(t, filepath.Join(base, "hollow", "config.yml"))
+
+		o := &rootcmd.Options{App: "hollow"}
+		_, err := o.NewLogger()
+		require.NoError(t, err)
+
+		o.InitConfig()
+
+		assert.Equal(t, filepath.Join(base, "hollow", "config.yml"), viper.ConfigFileUsed())
+	})
+}